@@ -656,15 +656,16 @@ static struct node * bplustree_leaf_put(struct bplustree *me, struct node *leaf,
         if (NULL != sib) {
             if (sib->offset == leaf->data.l.right) {
                 // Right sibling exists
-                // 기존 구현은 split(overflow된 가장 큰 키)를 오른쪽 형제의 맨 앞에 넣어
-                // 형제 노드의 최소 키를 감소시켰고, 이는 부모 internal 노드의 keyref 정렬 불변식을 깨뜨려
-                // 같은 leaf offset이 재삽입되는 중복 상황을 유발할 수 있었다.
-                // 수정: split 키를 형제 노드의 맨 뒤에 붙여 최소 키를 변경하지 않음.
-                bplustree_key_push_back(sib->data.l.keys, sib->length, split);
+                // split, the largest key of this leaf, is smaller than every key of the
+                // sibling, so it goes in front of them. Appending it left the sibling out
+                // of order. The sibling's keyref reads its minimum from the leaf, so the
+                // parent stays ordered: split is still larger than every key of this leaf.
+                memmove(&sib->data.l.keys[1], &sib->data.l.keys[0], (size_t)sib->length * sizeof(i64));
+                sib->data.l.keys[0] = split;
                 sib->length++;
 
                 #ifdef UNIT_TEST
-                // Ensure ordering (split was the largest key of the overflow set, so ordering must hold)
+                // Ensure ordering
                 assert(sib->length <= LEAF_KEYS_MAX);
                 for (int _i = 1; _i < sib->length; _i++) {
                     assert(me->compare(me->obj, sib->data.l.keys[_i-1], sib->data.l.keys[_i]) <= 0);
@@ -788,6 +789,10 @@ static struct keyref bplustree_node_put(struct bplustree *me, struct context *ct
     array_wrap_init(&aw, nkeys, INTERNAL_KEYS_MAX + 1);
     array_wrap_join(&aw, n->data.i.keys, n->length, pos.offset, pos.d, nk);
     int nlen = aw.length;    
+    // The key after nk still has the split child as its left. The node is
+    // cached, so a stale left sends later lookups and inserts to the wrong child.
+    for (int i = 1; i < nlen; i++)
+        nkeys[i].left = nkeys[i - 1].right;
 
     struct keyref temp[INTERNAL_KEYS_MAX];
     memcpy(temp, nkeys, sizeof(struct keyref) * INTERNAL_KEYS_MAX);
@@ -827,6 +832,7 @@ static struct keyref bplustree_node_put(struct bplustree *me, struct context *ct
 
     // Keys before mid_key remain in the current node
     n->length = mid_idx;
+    memcpy(n->data.i.keys, temp, sizeof(struct keyref) * n->length);
     memset(&n->data.i.keys[n->length], 0xFF, sizeof(struct keyref) * (INTERNAL_KEYS_MAX - n->length));
     bplustree_node_write(me, n, e);
     if (e && *e) {
@@ -1274,12 +1280,12 @@ static i64 cursor_next_desc(struct flintdb_cursor_i64 *c, char **e) {
 
         i64 key = impl->leaf->data.l.keys[impl->offset--];
         int d = impl->cmpr(impl->obj, key);
-        if (d > 0) {
-            // For DESC, d>0 means key is before start; since we're moving left, keep scanning
+        if (d < 0) {
+            // key is after desired end; since we're moving left, keep scanning
             continue;
         } else if (d == 0) {
             return key;
-        } else { // d < 0 means after end -> stop
+        } else { // d > 0 means before start -> stop
             impl->leaf = NULL;
             return NOT_FOUND;
         }
@@ -1341,16 +1347,16 @@ static struct node* node_leaf_min_comparable(struct bplustree *me, struct node *
 static struct node* node_leaf_max_comparable(struct bplustree *me, struct node *start, void *obj, int (*cmpr)(void *obj, i64 o), char **e) {
     struct node *n = start;
     while(n && !is_leaf(n)) {
+        // Go right of the last separator at or before the end of the range;
+        // when every separator is after it, go left of the first.
         int i = n->length - 1;
         for(; i >= 0; i--) {
             i64 min_key = keyref_min(me, &n->data.i.keys[i], e);
-            if (cmpr(obj, min_key) <= 0) {
+            if (cmpr(obj, min_key) >= 0) {
                 break;
             }
         }
-        if (i < 0) i = 0;
-
-        i64 child_offset = n->data.i.keys[i].right;
+        i64 child_offset = (i < 0) ? n->data.i.keys[0].left : n->data.i.keys[i].right;
         n = bplustree_node_read(me, child_offset, e);
     }
     return n;
@@ -1422,12 +1428,28 @@ static struct flintdb_cursor_i64 * bplustree_find(struct bplustree *me, enum ord
         impl->leaf = node_leaf_min_comparable(me, root, obj, cmpr, e);
         if (impl->leaf) {
             impl->offset = first_key_pos(impl->leaf->data.l.keys, impl->leaf->length, obj, cmpr);
+            // The descent goes left when the target equals a separator, so the first
+            // match may be the first key of the next leaf.
+            while (impl->offset == -1 && impl->leaf->length > 0 && impl->leaf->data.l.right != OFFSET_NULL
+                && cmpr(obj, impl->leaf->data.l.keys[impl->leaf->length - 1]) > 0) {
+                impl->leaf = bplustree_node_read(me, impl->leaf->data.l.right, e);
+                if (impl->leaf == NULL) break;
+                impl->offset = first_key_pos(impl->leaf->data.l.keys, impl->leaf->length, obj, cmpr);
+            }
             if (impl->offset == -1) impl->leaf = NULL;
         }
     } else { // DESC
         impl->leaf = node_leaf_max_comparable(me, root, obj, cmpr, e);
         if (impl->leaf) {
             impl->offset = last_key_pos(impl->leaf->data.l.keys, impl->leaf->length, obj, cmpr);
+            // A separator may outlive its key, so the last match may be the last key
+            // of the previous leaf.
+            while (impl->offset == -1 && impl->leaf->length > 0 && impl->leaf->data.l.left != OFFSET_NULL
+                && cmpr(obj, impl->leaf->data.l.keys[0]) < 0) {
+                impl->leaf = bplustree_node_read(me, impl->leaf->data.l.left, e);
+                if (impl->leaf == NULL) break;
+                impl->offset = last_key_pos(impl->leaf->data.l.keys, impl->leaf->length, obj, cmpr);
+            }
             if (impl->offset == -1) impl->leaf = NULL;
         }
     }
@@ -303,8 +303,8 @@ struct buffer *buffer_slice(struct buffer *in, i32 offset, i32 length, char **e)
     if (!out) {
         THROW(e, "Out of memory");
     }
-    out->owner = BUFFER_OWNER_SLICE_HEAP;
     buffer_slice_to(in, offset, length, out, e);
+    out->owner = BUFFER_OWNER_SLICE_HEAP; // after slice_to, which clears it
     if (e && *e) {
         out->free(out);
         return NULL;
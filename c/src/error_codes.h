@@ -29,6 +29,7 @@ enum db_error_code {
     DB_ERR_STORAGE_WRITE_ERROR,
     DB_ERR_STORAGE_DELETE_ERROR,
     DB_ERR_STORAGE_FULL,
+    DB_ERR_CHECKSUM_MISMATCH,
     
     // Lock/Transaction errors
     DB_ERR_LOCK_TIMEOUT = -5000,
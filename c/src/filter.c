@@ -217,6 +217,22 @@ static inline void skip_whitespace(const char **s) {
     while (**s && (**s == ' ' || **s == '\t' || **s == '\n' || **s == '\r')) (*s)++;
 }
 
+/**
+ * @brief Parse keyword as a whole word (case insensitive)
+ * 
+ * @param s Input string pointer (advanced past the keyword if it matches)
+ * @param keyword Keyword to match
+ * @return int 1 if matched, 0 if not
+ */
+static int parse_keyword(const char **s, const char *keyword) {
+    skip_whitespace(s);
+    size_t n = strlen(keyword);
+    if (strncasecmp(*s, keyword, n) != 0) return 0;
+    if ((*s)[n] && (isalnum((unsigned char)(*s)[n]) || (*s)[n] == '_')) return 0;
+    *s += n;
+    return 1;
+}
+
 /**
  * @brief Parse column name (L-Value)
  * Extracts identifier: alphanumeric characters and underscore
@@ -271,7 +287,7 @@ static enum arithmetic_operator parse_operator(const char **s, char **e) {
         THROW(e, "NOT operator is not supported");
     }
     if (strncasecmp(*s, "IS", 2) == 0 && ((*s)[2] == '\0' || (!isalnum((unsigned char)(*s)[2]) && (*s)[2] != '_'))) {
-        THROW(e, "IS operator is not supported. Use '=' for equality or check for NULL values");
+        THROW(e, "IS must be followed by NULL or NOT NULL");
     }
     
     if (strncmp(*s, "<=", 2) == 0) { *s += 2; return LESSER_EQUAL; }
@@ -418,8 +434,54 @@ static void filter_dealloc(valtype v) {
 // 
 
 /**
- * @brief Check if a filter condition can use a specific B+Tree index
- * Checks if all filter conditions are on columns that are part of the given index
+ * @brief Flatten the conjuncts of a filter: the filter itself, or the operands of
+ * its (nested) ANDs. Conjuncts are borrowed, not cloned.
+ */
+static void filter_conjuncts(struct filter *f, struct list *out) {
+    if (f->type == FILTER_LOGICAL && f->data.logical.op == AND && f->data.logical.filters) {
+        struct list *filters = f->data.logical.filters;
+        for (int i = 0; i < filters->count(filters); i++)
+            filter_conjuncts((struct filter *)filters->get(filters, i, NULL), out);
+        return;
+    }
+    out->add(out, (valtype)f, NULL, NULL);
+}
+
+/**
+ * @brief Mark the conjuncts a range scan of an index can answer
+ * A scan walks a contiguous run of keys, so it answers equality on a prefix of the
+ * index keys followed by comparisons on the next key: "a = 1 AND b < 5" on (a, b),
+ * but not "b < 5" alone, "a > 1 AND b < 5", "a != 1" or LIKE.
+ * 
+ * @param conds Conjuncts of the filter
+ * @param meta Table metadata
+ * @param target_index Index to check against
+ * @param taken Output, per conjunct: 1 + the key position it bounds, or 0
+ * @return int Number of conjuncts marked
+ */
+static int index_prefix(struct list *conds, struct flintdb_meta *meta, struct flintdb_index *target_index, int *taken) {
+    int n = 0;
+    for (int k = 0; k < target_index->keys.length; k++) {
+        int found = 0, equal = 0;
+        for (int i = 0; i < conds->count(conds); i++) {
+            struct filter *f = (struct filter *)conds->get(conds, i, NULL);
+            if (f->type != FILTER_CONDITION) continue;
+            enum arithmetic_operator op = f->data.cond.op;
+            if (op != EQUAL && op != LESSER_EQUAL && op != LESSER && op != GREATER_EQUAL && op != GREATER) continue;
+            if (strcmp(meta->columns.a[f->data.cond.column_index].name, target_index->keys.a[k]) != 0) continue;
+            taken[i] = k + 1;
+            found++;
+            if (op == EQUAL) equal = 1;
+        }
+        n += found;
+        if (!equal) break; // a range on this key ends the prefix
+    }
+    return n;
+}
+
+/**
+ * @brief Check if a filter can use a specific B+Tree index
+ * Checks if the whole filter is answered by a range scan of the given index
  * 
  * @param f Filter to check
  * @param meta Table metadata
@@ -429,40 +491,14 @@ static void filter_dealloc(valtype v) {
 static int is_indexable(struct filter *f, struct flintdb_meta *meta, struct flintdb_index *target_index) {
     if (!f || !meta || !target_index) return 0;
     
-    if (f->type == FILTER_CONDITION) {
-        int col_index = f->data.cond.column_index;
-        
-        // Check if column is part of the target index
-        const char *col_name = meta->columns.a[col_index].name;
-        for (int i = 0; i < target_index->keys.length; i++) {
-            if (strcmp(target_index->keys.a[i], col_name) == 0) {
-                return 1;
-            }
-        }
-        return 0;
-    }
-    
-    // Logical filters need recursive check
-    if (f->type == FILTER_LOGICAL) {
-        struct list *filters = f->data.logical.filters;
-        if (!filters) return 0;
-        
-        // For AND: all conditions must be indexable
-        if (f->data.logical.op == AND) {
-            for (int i = 0; i < filters->count(filters); i++) {
-                struct filter *sub = (struct filter *)filters->get(filters, i, NULL);
-                if (!is_indexable(sub, meta, target_index)) {
-                    return 0;
-                }
-            }
-            return 1;
-        }
-        
-        // For OR: cannot use index efficiently (would need multiple searches)
-        return 0;
-    }
-    
-    return 0;
+    struct list *conds = arraylist_new(4);
+    filter_conjuncts(f, conds);
+    int count = conds->count(conds);
+    int *taken = CALLOC(count, sizeof(int));
+    int indexable = index_prefix(conds, meta, target_index, taken) == count;
+    FREE(taken);
+    conds->free(conds);
+    return indexable;
 }
 
 /**
@@ -527,102 +563,70 @@ static struct filter *filter_clone(struct filter *f, char **e) {
  * @param e Error message output
  * @return struct filter_layers* Split filter layers, or NULL if failed
  */
+// Builds a layer of filter clones: NULL, the one filter, or their AND.
+static struct filter *filter_layer(struct list *clones) {
+    struct filter *layer = NULL;
+    if (clones->count(clones) == 0) {
+        clones->free(clones);
+    } else if (clones->count(clones) == 1) {
+        // Single condition - transfer ownership directly
+        layer = (struct filter *)clones->get(clones, 0, NULL);
+        clones->free(clones);
+    } else {
+        // Multiple conditions - need to register dealloc for list management
+        // Re-register with dealloc for proper cleanup when list is freed
+        for (int i = 0; i < clones->count(clones); i++) {
+            // Update entry's dealloc function
+            struct entry {
+                valtype item;
+                void (*dealloc)(valtype);
+            } *ent = (struct entry *)clones->a[i];
+            ent->dealloc = filter_dealloc;
+        }
+        
+        layer = CALLOC(1, sizeof(struct filter));
+        layer->type = FILTER_LOGICAL;
+        layer->data.logical.op = AND;
+        layer->data.logical.filters = clones;
+        // clones ownership transferred to layer
+    }
+    return layer;
+}
+
 struct filter_layers *filter_split(struct filter *f, struct flintdb_meta *meta, struct flintdb_index *target_index, char **e) {
     if (!f || !meta || !target_index) return NULL;
     
     struct filter_layers *layers = CALLOC(1, sizeof(struct filter_layers));
     
-    // Simple case: entire filter is indexable
-    if (is_indexable(f, meta, target_index)) {
-        layers->first = filter_clone(f, e);
-        layers->second = NULL;
-        return layers;
-    }
-    
-    // Simple case: entire filter is not indexable
-    if (f->type == FILTER_CONDITION) {
+    // Split the conjuncts: those bounding the index scan go first, in key order,
+    // since filter_compare returns the first non-matching operand of an AND
+    struct list *conds = arraylist_new(4);
+    filter_conjuncts(f, conds);
+    int count = conds->count(conds);
+    int *taken = CALLOC(count, sizeof(int));
+    if (index_prefix(conds, meta, target_index, taken) == 0) {
         layers->first = NULL;
         layers->second = filter_clone(f, e);
-        return layers;
-    }
-    
-    // Complex case: logical filter with mixed indexable/non-indexable conditions
-    if (f->type == FILTER_LOGICAL && f->data.logical.op == AND) {
-        struct list *src_list = f->data.logical.filters;
+    } else {
         struct list *indexable_list = arraylist_new(2);
         struct list *nonindexable_list = arraylist_new(2);
-        
-        for (int i = 0; i < src_list->count(src_list); i++) {
-            struct filter *sub = (struct filter *)src_list->get(src_list, i, NULL);
-            if (is_indexable(sub, meta, target_index)) {
-                struct filter *clone = filter_clone(sub, e);
-                indexable_list->add(indexable_list, (valtype)clone, NULL, NULL);
-            } else {
-                struct filter *clone = filter_clone(sub, e);
-                nonindexable_list->add(nonindexable_list, (valtype)clone, NULL, NULL);
+        for (int k = 1; k <= target_index->keys.length; k++) {
+            for (int i = 0; i < count; i++) {
+                if (taken[i] != k) continue;
+                struct filter *sub = (struct filter *)conds->get(conds, i, NULL);
+                indexable_list->add(indexable_list, (valtype)filter_clone(sub, e), NULL, NULL);
             }
         }
-        
-        // Build first layer (indexable)
-        if (indexable_list->count(indexable_list) == 0) {
-            // No indexable conditions
-            indexable_list->free(indexable_list);
-        } else if (indexable_list->count(indexable_list) == 1) {
-            // Single condition - transfer ownership directly
-            layers->first = (struct filter *)indexable_list->get(indexable_list, 0, NULL);
-            indexable_list->free(indexable_list);
-        } else {
-            // Multiple indexable conditions - need to register dealloc for list management
-            // Re-register with dealloc for proper cleanup when list is freed
-            for (int i = 0; i < indexable_list->count(indexable_list); i++) {
-                // Update entry's dealloc function
-                struct entry {
-                    valtype item;
-                    void (*dealloc)(valtype);
-                } *ent = (struct entry *)indexable_list->a[i];
-                ent->dealloc = filter_dealloc;
-            }
-            
-            layers->first = CALLOC(1, sizeof(struct filter));
-            layers->first->type = FILTER_LOGICAL;
-            layers->first->data.logical.op = AND;
-            layers->first->data.logical.filters = indexable_list;
-            // indexable_list ownership transferred to layers->first
+        for (int i = 0; i < count; i++) {
+            if (taken[i]) continue;
+            struct filter *sub = (struct filter *)conds->get(conds, i, NULL);
+            nonindexable_list->add(nonindexable_list, (valtype)filter_clone(sub, e), NULL, NULL);
         }
-        
-        // Build second layer (non-indexable)
-        if (nonindexable_list->count(nonindexable_list) == 0) {
-            // No non-indexable conditions
-            nonindexable_list->free(nonindexable_list);
-        } else if (nonindexable_list->count(nonindexable_list) == 1) {
-            // Single condition - transfer ownership directly
-            layers->second = (struct filter *)nonindexable_list->get(nonindexable_list, 0, NULL);
-            nonindexable_list->free(nonindexable_list);
-        } else {
-            // Multiple non-indexable conditions - need to register dealloc for list management
-            // Re-register with dealloc for proper cleanup when list is freed
-            for (int i = 0; i < nonindexable_list->count(nonindexable_list); i++) {
-                // Update entry's dealloc function
-                struct entry {
-                    valtype item;
-                    void (*dealloc)(valtype);
-                } *ent = (struct entry *)nonindexable_list->a[i];
-                ent->dealloc = filter_dealloc;
-            }
-            
-            layers->second = CALLOC(1, sizeof(struct filter));
-            layers->second->type = FILTER_LOGICAL;
-            layers->second->data.logical.op = AND;
-            layers->second->data.logical.filters = nonindexable_list;
-            // nonindexable_list ownership transferred to layers->second
-        }
-        
-        return layers;
+        layers->first = filter_layer(indexable_list);
+        layers->second = filter_layer(nonindexable_list);
     }
-    
-    // OR filters or other complex cases: cannot split efficiently
-    layers->first = NULL;
-    layers->second = filter_clone(f, e);
+    FREE(taken);
+    conds->free(conds);
     return layers;
 }
 
@@ -663,14 +667,22 @@ static struct filter *parse_condition(const char **s, struct flintdb_meta *meta,
         THROW(e, "unknown column '%s'", column_name);
     }
     
-    enum arithmetic_operator op = parse_operator(s, e);
-    if (op == BAD_OPERATOR) {
-        return NULL;
+    // IS NULL and IS NOT NULL are = NULL and != NULL
+    enum arithmetic_operator op = BAD_OPERATOR;
+    const char *p = *s;
+    if (parse_keyword(&p, "IS")) {
+        int not = parse_keyword(&p, "NOT");
+        if (parse_keyword(&p, "NULL")) {
+            *s = p;
+            op = not ? NOT_EQUAL : EQUAL;
+        }
     }
     
     struct flintdb_variant *value = CALLOC(1, sizeof(struct flintdb_variant));
     flintdb_variant_init(value);
-    if (!parse_value(s, value, meta, column_index, e)) {
+    if (op != BAD_OPERATOR) {
+        flintdb_variant_null_set(value);
+    } else if ((op = parse_operator(s, e)) == BAD_OPERATOR || !parse_value(s, value, meta, column_index, e)) {
         flintdb_variant_free(value);
         FREE(value);
         return NULL;
@@ -93,6 +93,7 @@ FLINTDB_BEGIN_DECLS
 enum flintdb_open_mode {
     FLINTDB_RDONLY = O_RDONLY,
     FLINTDB_RDWR = O_RDWR | O_CREAT,
+    FLINTDB_APPEND = O_RDWR | O_CREAT | O_APPEND, // generic text files: write after the existing rows
 };
 
 struct flintdb_cursor_i64 {
@@ -218,6 +219,7 @@ struct flintdb_meta {
     char date[32];
     i16 compact;
     char compressor[32];
+    char checksum[16]; // per-row checksum: "crc32", "xxhash" or empty for none
     char storage[32];
     char wal[20];
     i32 wal_checkpoint_interval;
@@ -243,7 +245,7 @@ struct flintdb_meta {
     char delimiter; // CSV, TSV
     char quote;     // CSV, TSV
     char escape;    // CSV, TSV
-    char nil_str[MAX_COLUMN_NAME_LIMIT]; // CSV, TSV
+    char nil_str[MAX_COLUMN_NAME_LIMIT]; // CSV, TSV NULL token, e.g. "NULL" or "\\N"; "\"\"" makes empty fields NULL
 
     char format[MAX_COLUMN_NAME_LIMIT]; // reserved for future use
 
@@ -438,7 +440,26 @@ FLINTDB_API struct flintdb_transaction * flintdb_transaction_begin(struct flintd
 
 // Table operations
 FLINTDB_API struct flintdb_table * flintdb_table_open(const char *file, enum flintdb_open_mode mode, const struct flintdb_meta *meta, char **e); // if meta is NULL, read from <file>.desc
+FLINTDB_API struct flintdb_table * flintdb_table_open_mapped(const char *file, const struct flintdb_meta *meta, char **e); // read-only; maps the whole data file so read_stream is safe from many threads
 FLINTDB_API int flintdb_table_drop(const char *file, char **e);
+FLINTDB_API void flintdb_table_cache_stats(const struct flintdb_table *table, i64 *hits, i64 *misses); // row cache hits and misses of read since open
+FLINTDB_API i64 flintdb_cursor_scanned(const struct flintdb_cursor_i64 *c); // rows a find cursor has read and filtered so far
+FLINTDB_API void flintdb_cursor_deadline(struct flintdb_cursor_i64 *c, i64 deadline); // epoch milliseconds after which a find cursor's next fails, 0 for none
+FLINTDB_API i64 flintdb_table_lookup(struct flintdb_table *table, int index, const struct flintdb_row *key, char **e); // rowid of a row with key's values in the columns of the index, by one probe of it, or -1
+FLINTDB_API int flintdb_query_parse(const struct flintdb_meta *meta, const char *query, int *index, i8 *desc, i32 *offset, i32 *limit, char **e); // checks a find query as a table of meta reads it; limit -1 is none
+
+// Pluggable file backend (VFS): table data and index files of a table whose meta storage
+// names a registered VFS are read and written through it; .desc and WAL files stay local.
+struct flintdb_vfs {
+    void *ctx;
+    void * (*open)(void *ctx, const char *file, enum flintdb_open_mode mode, char **e); // returns the file handle
+    i64 (*read_at)(void *f, void *buf, i64 size, i64 offset, char **e); // bytes read, fewer at end of file
+    i64 (*write_at)(void *f, const void *buf, i64 size, i64 offset, char **e);
+    int (*sync)(void *f, char **e);
+    i64 (*size)(void *f, char **e);
+    void (*close)(void *f);
+};
+FLINTDB_API int flintdb_vfs_register(const char *name, const struct flintdb_vfs *vfs, char **e); // register before opening tables
 
 
 // Generic file structure and operations (for TSV/CSV/JSONL/Parquet files)
@@ -457,6 +478,17 @@ struct flintdb_genericfile {
 // generic file operations (TSV/CSV text files)
 FLINTDB_API struct flintdb_genericfile * flintdb_genericfile_open(const char *file, enum flintdb_open_mode mode, const struct flintdb_meta *meta, char **e);
 FLINTDB_API void flintdb_genericfile_drop(const char *file, char **e);
+// lenient mode skips malformed lines of TSV/CSV files instead of reading them as NULLs;
+// flintdb_genericfile_malformed returns the total skipped by the last find, with entry i (line, text, reason) if kept
+FLINTDB_API void flintdb_genericfile_lenient(struct flintdb_genericfile *f, i8 lenient, char **e);
+// write buffer size in bytes, set before the first write (default IO_BUFSZ); text files only
+FLINTDB_API void flintdb_genericfile_buffer(struct flintdb_genericfile *f, size_t bytes, char **e);
+// write n rows in one call; returns n or -1
+FLINTDB_API i64 flintdb_genericfile_write_many(struct flintdb_genericfile *f, struct flintdb_row **rows, int n, char **e);
+// byte offset (uncompressed) where the next row written lands, and its 1-based line number; text files only
+FLINTDB_API i64 flintdb_genericfile_position(const struct flintdb_genericfile *f, i64 *line);
+FLINTDB_API i64 flintdb_genericfile_malformed(const struct flintdb_genericfile *f, int i, i64 *line, const char **text, const char **reason);
+FLINTDB_API struct flintdb_row * flintdb_row_decode_line(const struct flintdb_meta *meta, const char *line, u32 len, char **e); // one line in the meta's format, as a delimited file's find reads it
 
 
 // File-based external sorter for rows
@@ -471,6 +503,12 @@ struct flintdb_filesort {
 };
 
 FLINTDB_API struct flintdb_filesort *flintdb_filesort_new(const char *file, const struct flintdb_meta *m, char **e);
+// as flintdb_filesort_new, keeping at most about memory bytes of the file mapped; 0 for no limit
+FLINTDB_API struct flintdb_filesort *flintdb_filesort_new_bounded(const char *file, const struct flintdb_meta *m, i64 memory, char **e);
+// after sort: drop each row comparing equal to the row before it, keeping the first of a run; returns the rows kept or -1
+FLINTDB_API i64 flintdb_filesort_unique(struct flintdb_filesort *me, int (*cmpr)(const void *obj, const struct flintdb_row *a, const struct flintdb_row *b), const void *ctx, char **e);
+// keep only the first n rows; returns the rows kept
+FLINTDB_API i64 flintdb_filesort_limit(struct flintdb_filesort *me, i64 n);
 
 
 // Aggregate functions and group key structures (Java Aggregate.java port)
@@ -577,6 +615,13 @@ FLINTDB_API int flintdb_sql_to_meta(struct flintdb_sql *in, struct flintdb_meta
  */
 FLINTDB_API void flintdb_cleanup(char **e);
 
+/**
+ * @brief Send the engine's log lines, of level "LOG" or "WARN", to handler
+ *        instead of stdout; NULL restores stdout
+ */
+typedef void (*flintdb_log_fn)(const char *level, const char *file, int line, const char *func, const char *message);
+FLINTDB_API void flintdb_log_handler(flintdb_log_fn handler);
+
 FLINTDB_END_DECLS
 
 #endif // FLINTDB_H
@@ -56,6 +56,15 @@ enum file_data_header {
     HEADER_PRESENT = 1,
 };
 
+// MALFORMED_LIMIT: malformed lines kept for the report in lenient mode (all are counted)
+#define MALFORMED_LIMIT 1000
+
+struct malformed_line {
+    i64 line;
+    char *text;
+    char reason[128];
+};
+
 struct flintdb_genericfile_priv {
     // private data for file implementation
     char file[PATH_MAX];
@@ -69,6 +78,17 @@ struct flintdb_genericfile_priv {
     struct bufio *wbio;
     i8 header_written;
     enum file_data_header file_data_header;
+
+    size_t wbuf_size; // writer buffer size; 0 means IO_BUFSZ
+    i64 offset;       // bytes written before the next row, uncompressed
+    i64 lines;        // lines written before the next row, including the header
+    i8 unterminated;  // append mode: the existing last line lacks its newline
+
+    // lenient mode: skip malformed lines, reporting those of the last find
+    i8 lenient;
+    struct malformed_line *malformed;
+    int malformed_len;
+    i64 malformed_total;
 };
 
 // Parse an environment variable representing bytes. Supports optional K/M/G suffixes.
@@ -106,8 +126,10 @@ struct flintdb_genericfile_cursor_priv {
     struct filter *filter;
     struct limit limit;
     i64 rowidx;     // current data row index (after header)
+    i64 lineno;     // physical lines read, including the header
     i8 initialized; // init guard for header handling
     enum file_data_header file_data_header;
+    struct flintdb_genericfile_priv *file;
 
     // Cursor-owned last returned row (BORROWED by caller). Freed on next() or close().
     struct flintdb_row *last_row;
@@ -145,6 +167,118 @@ EXCEPTION:
     return;
 }
 
+static struct flintdb_cursor_row *genericfile_find_where(const struct flintdb_genericfile *me, const char *where, char **e);
+static size_t genericfile_header_line(const struct flintdb_meta *m, char *line, size_t size);
+static int genericfile_write_begin(struct flintdb_genericfile_priv *priv, char **e);
+
+void flintdb_genericfile_lenient(struct flintdb_genericfile *f, i8 lenient, char **e) {
+    if (!f || !f->priv)
+        THROW(e, "file is not open");
+    if (f->find != genericfile_find_where)
+        THROW(e, "lenient mode is only supported for text files");
+    ((struct flintdb_genericfile_priv *)f->priv)->lenient = lenient;
+EXCEPTION:
+    return;
+}
+
+void flintdb_genericfile_buffer(struct flintdb_genericfile *f, size_t bytes, char **e) {
+    if (!f || !f->priv || f->find != genericfile_find_where)
+        THROW(e, "write buffer is only supported for text files");
+    struct flintdb_genericfile_priv *priv = (struct flintdb_genericfile_priv *)f->priv;
+    if (priv->wbio)
+        THROW(e, "write buffer must be set before the first write: %s", priv->file);
+    priv->wbuf_size = bytes;
+EXCEPTION:
+    return;
+}
+
+i64 flintdb_genericfile_write_many(struct flintdb_genericfile *f, struct flintdb_row **rows, int n, char **e) {
+    if (!f || !f->write)
+        THROW(e, "file is not open");
+    for (int i = 0; i < n; i++) {
+        if (f->write(f, rows[i], e) != 0) {
+            if (e && *e)
+                THROW_S(e);
+            THROW(e, "failed to write row %d", i);
+        }
+    }
+    return n;
+
+EXCEPTION:
+    return -1;
+}
+
+i64 flintdb_genericfile_position(const struct flintdb_genericfile *f, i64 *line) {
+    if (!f || !f->priv || f->find != genericfile_find_where)
+        return -1;
+    const struct flintdb_genericfile_priv *priv = (const struct flintdb_genericfile_priv *)f->priv;
+    i64 offset = priv->offset;
+    i64 lines = priv->lines;
+    if (!priv->header_written && !priv->meta.absent_header) {
+        // the header is written with the first row
+        char buf[4096];
+        offset += (i64)genericfile_header_line(&priv->meta, buf, sizeof(buf)) + 1;
+        lines++;
+    }
+    if (line)
+        *line = lines + 1;
+    return offset;
+}
+
+i64 flintdb_genericfile_malformed(const struct flintdb_genericfile *f, int i, i64 *line, const char **text, const char **reason) {
+    if (!f || !f->priv || f->find != genericfile_find_where)
+        return 0;
+    const struct flintdb_genericfile_priv *priv = (const struct flintdb_genericfile_priv *)f->priv;
+    if (i >= 0 && i < priv->malformed_len) {
+        if (line)
+            *line = priv->malformed[i].line;
+        if (text)
+            *text = priv->malformed[i].text;
+        if (reason)
+            *reason = priv->malformed[i].reason;
+    }
+    return priv->malformed_total;
+}
+
+struct flintdb_row *flintdb_row_decode_line(const struct flintdb_meta *meta, const char *line, u32 len, char **e) {
+    struct formatter f = {0};
+    struct flintdb_row *r = NULL;
+    if (!meta || meta->columns.length <= 0)
+        THROW(e, "meta has no columns");
+    // the meta's format, as SetFormatCSV/SetFormatTSV or a .desc file set it; TSV by default
+    enum fileformat fmt = strcasecmp(meta->format, "csv") == 0 ? FORMAT_CSV : FORMAT_TSV;
+    if (formatter_init(fmt, (struct flintdb_meta *)meta, &f, e) != 0)
+        THROW_S(e);
+
+    r = flintdb_row_new((struct flintdb_meta *)meta, e);
+    if (e && *e)
+        THROW_S(e);
+    struct buffer in = {
+        0,
+    };
+    buffer_wrap((char *)line, len, &in);
+    if (f.decode(&f, &in, r, e) != 0) {
+        if (e && *e)
+            THROW_S(e);
+        THROW(e, "empty line");
+    }
+    if (in.position < in.limit)
+        THROW(e, "line holds more than one record");
+    // decode takes what it can of a malformed line; report it as a lenient find does
+    const char *reason = formatter_malformed(&f);
+    if (reason)
+        THROW(e, "%s", reason);
+    f.close(&f);
+    return r;
+
+EXCEPTION:
+    if (r)
+        r->free(r);
+    if (f.close)
+        f.close(&f);
+    return NULL;
+}
+
 static i64 genericfile_rows(const struct flintdb_genericfile *me, char **e) {
     if (!me || !me->priv)
         return -1;
@@ -160,61 +294,129 @@ static const struct flintdb_meta *genericfile_meta(const struct flintdb_genericf
     return &((struct flintdb_genericfile_priv *)me->priv)->meta;
 }
 
-static i64 genericfile_write(struct flintdb_genericfile *me, struct flintdb_row *r, char **e) {
-    if (!me || !me->priv || !r)
-        return -1;
-    struct flintdb_genericfile_priv *priv = (struct flintdb_genericfile_priv *)me->priv;
-    if (priv->mode != FLINTDB_RDWR) {
-        THROW(e, "file not opened for write: %s", priv->file);
+// Count the bytes and lines of an existing file, so appended rows continue its
+// positions; a last line cut short by an interrupted writer is terminated.
+static int genericfile_append_scan(struct flintdb_genericfile_priv *priv, char **e) {
+    struct bufio *bio = NULL;
+    if (access(priv->file, F_OK) != 0)
+        return 0;
+    bio = file_bufio_open(priv->file, FLINTDB_RDONLY, io_buf_size_default(), e);
+    if (!bio)
+        THROW_S(e);
+    char buf[1 << 16];
+    char last = '\n';
+    ssize_t n;
+    while ((n = bio->read(bio, buf, sizeof(buf), e)) > 0) {
+        for (ssize_t i = 0; i < n; i++)
+            priv->lines += buf[i] == '\n';
+        priv->offset += n;
+        last = buf[n - 1];
     }
+    if (e && *e)
+        THROW_S(e);
+    bio->close(bio);
+    bio = NULL;
+    if (priv->offset > 0) {
+        priv->header_written = 1; // the file already has its header
+        if (last != '\n') {
+            priv->unterminated = 1; // terminated when the writer opens
+            priv->offset++;
+            priv->lines++;
+        }
+    }
+    return 0;
 
-    // Initialize writer lazily (first write truncates/creates the file)
-    if (!priv->wbio) {
-        // ensure parent directory exists (genericfile_open did this, but be robust)
-        char dir[PATH_MAX] = {0};
-        getdir(priv->file, dir);
-        if (dir[0])
-            mkdirs(dir, S_IRWXU);
-        DEBUG("genericfile_write: open writer for %s", priv->file);
-        size_t iobsz = io_buf_size_default();
-        priv->wbio = file_bufio_open(priv->file, FLINTDB_RDWR, iobsz, e);
-        if (e && *e)
-            THROW_S(e);
+EXCEPTION:
+    if (bio)
+        bio->close(bio);
+    return -1;
+}
+
+// Build the header line of a text file: column names separated by the delimiter.
+static size_t genericfile_header_line(const struct flintdb_meta *m, char *line, size_t size) {
+    char delim = m->delimiter ? m->delimiter : '\t';
+    size_t ln = 0;
+    for (int i = 0; i < m->columns.length; i++) {
+        const char *name = m->columns.a[i].name;
+        if (i > 0) {
+            if (ln + 1 >= size)
+                break; // fallback to partial; extremely unlikely
+            line[ln++] = delim;
+        }
+        size_t nl = strlen(name);
+        if (ln + nl >= size)
+            nl = size - ln - 1;
+        memcpy(line + ln, name, nl);
+        ln += nl;
+    }
+    return ln;
+}
+
+static int genericfile_writer_open(struct flintdb_genericfile_priv *priv, char **e) {
+    // ensure parent directory exists (genericfile_open did this, but be robust)
+    char dir[PATH_MAX] = {0};
+    getdir(priv->file, dir);
+    if (dir[0])
+        mkdirs(dir, S_IRWXU);
+    DEBUG("genericfile_write: open writer for %s", priv->file);
+    size_t iobsz = priv->wbuf_size ? priv->wbuf_size : io_buf_size_default();
+    if (priv->mode != FLINTDB_APPEND) {
         priv->header_written = 0;
-        // reset rows counter on first open for write
-        if (priv->rows < 0)
-            priv->rows = 0;
+        priv->offset = 0;
+        priv->lines = 0;
     }
+    priv->wbio = file_bufio_open(priv->file, priv->mode, iobsz, e);
+    if (e && *e)
+        THROW_S(e);
+    if (priv->unterminated) {
+        if (priv->wbio->write(priv->wbio, "\n", 1, e) < 0)
+            THROW_S(e);
+        priv->unterminated = 0;
+    }
+    // reset rows counter on first open for write
+    if (priv->rows < 0)
+        priv->rows = 0;
+    return 0;
+
+EXCEPTION:
+    return -1;
+}
 
-    // Emit header once for text formats when meta.absent_header is set
-    if (!priv->header_written && priv->formatter.meta && priv->formatter.meta->absent_header) {
-        const struct flintdb_meta *m = priv->formatter.meta;
-        // Build header line: column names separated by delimiter
-        char delim = m->delimiter ? m->delimiter : '\t';
+// Prepare to write rows: open the writer lazily (the first write truncates or
+// creates the file) and emit the header once.
+static int genericfile_write_begin(struct flintdb_genericfile_priv *priv, char **e) {
+    if (priv->mode != FLINTDB_RDWR && priv->mode != FLINTDB_APPEND) {
+        THROW(e, "file not opened for write: %s", priv->file);
+    }
+    if (!priv->wbio && genericfile_writer_open(priv, e) != 0)
+        THROW_S(e);
+
+    // Emit header once for text formats unless meta.absent_header is set
+    if (!priv->header_written && priv->formatter.meta && !priv->formatter.meta->absent_header) {
         // Conservative fixed buffer; column names are MAX_COLUMN_NAME_LIMIT each
-        // If overflow risk, write incrementally via wbio
         char line[4096];
-        size_t ln = 0;
-        for (int i = 0; i < m->columns.length; i++) {
-            const char *name = m->columns.a[i].name;
-            if (i > 0) {
-                if (ln + 1 >= sizeof(line))
-                    break; // fallback to partial; extremely unlikely
-                line[ln++] = delim;
-            }
-            size_t nl = strlen(name);
-            if (ln + nl >= sizeof(line))
-                nl = sizeof(line) - ln - 1;
-            memcpy(line + ln, name, nl);
-            ln += nl;
-        }
+        size_t ln = genericfile_header_line(priv->formatter.meta, line, sizeof(line));
         // Write header with newline
-        DEBUG("genericfile_write: write header (%d cols)", m->columns.length);
+        DEBUG("genericfile_write: write header (%d cols)", priv->formatter.meta->columns.length);
         ssize_t wn = priv->wbio->writeline(priv->wbio, line, ln, e);
         if (wn < 0)
             THROW_S(e);
+        priv->offset += wn;
+        priv->lines++;
         priv->header_written = 1;
     }
+    return 0;
+
+EXCEPTION:
+    return -1;
+}
+
+static i64 genericfile_write(struct flintdb_genericfile *me, struct flintdb_row *r, char **e) {
+    if (!me || !me->priv || !r)
+        return -1;
+    struct flintdb_genericfile_priv *priv = (struct flintdb_genericfile_priv *)me->priv;
+    if (genericfile_write_begin(priv, e) != 0)
+        THROW_S(e);
 
     // Encode row using formatter (CSV/TSV encoders append newline)
     struct buffer *bout = buffer_alloc(1024);
@@ -231,6 +433,11 @@ static i64 genericfile_write(struct flintdb_genericfile *me, struct flintdb_row
     const char *data = bout->array;
     DEBUG("genericfile_write: write data %u bytes", nbytes);
     ssize_t wn = priv->wbio->write(priv->wbio, data, nbytes, e);
+    if (wn >= 0) {
+        priv->offset += nbytes;
+        for (u32 i = 0; i < nbytes; i++)
+            priv->lines += data[i] == '\n'; // quoted fields may span lines
+    }
     // Free temporary buffer regardless of write result
     if (bout)
         bout->free(bout);
@@ -246,6 +453,38 @@ EXCEPTION:
     return -1;
 }
 
+static void malformed_clear(struct flintdb_genericfile_priv *priv) {
+    for (int i = 0; i < priv->malformed_len; i++)
+        FREE(priv->malformed[i].text);
+    if (priv->malformed)
+        FREE(priv->malformed);
+    priv->malformed = NULL;
+    priv->malformed_len = 0;
+    priv->malformed_total = 0;
+}
+
+static void malformed_add(struct flintdb_genericfile_priv *priv, i64 line, const char *text, size_t len, const char *reason) {
+    priv->malformed_total++;
+    if (priv->malformed_len >= MALFORMED_LIMIT)
+        return;
+    if (!priv->malformed) {
+        priv->malformed = CALLOC(MALFORMED_LIMIT, sizeof(struct malformed_line));
+        if (!priv->malformed)
+            return;
+    }
+    while (len > 0 && (text[len - 1] == '\n' || text[len - 1] == '\r'))
+        len--;
+    struct malformed_line *m = &priv->malformed[priv->malformed_len];
+    m->text = MALLOC(len + 1);
+    if (!m->text)
+        return;
+    memcpy(m->text, text, len);
+    m->text[len] = '\0';
+    m->line = line;
+    strncpy(m->reason, reason, sizeof(m->reason) - 1);
+    priv->malformed_len++;
+}
+
 static void genericfile_cursor_close(struct flintdb_cursor_row *cursor) {
     if (!cursor)
         return;
@@ -293,7 +532,7 @@ static struct flintdb_row *genericfile_cursor_next(struct flintdb_cursor_row *cu
     if (!cp->initialized) {
         cp->initialized = 1;
         cp->rowidx = 0;
-        // If meta says to skip header, consume one physical line
+        // If meta says the file has a header, map columns by its names
         if (cp->file_data_header == HEADER_PRESENT) {
             ssize_t hn = bio->readline(bio, cp->line, sizeof(cp->line), e);
             if (hn < 0) {
@@ -301,6 +540,9 @@ static struct flintdb_row *genericfile_cursor_next(struct flintdb_cursor_row *cu
                     THROW_S(e);
                 return NULL; // empty file
             }
+            cp->lineno++;
+            if (formatter_map_header(f, cp->line, (u32)hn, e) != 0)
+                THROW_S(e);
         }
     }
 
@@ -312,6 +554,7 @@ static struct flintdb_row *genericfile_cursor_next(struct flintdb_cursor_row *cu
                 THROW_S(e);
             return NULL; // EOF
         }
+        i64 line = ++cp->lineno;
 
         // Accumulate additional lines if CSV record continues (when quoted multi-line)
         while (!record_completed_helper(f ? f->meta : NULL, cp->line, (size_t)n)) {
@@ -321,6 +564,7 @@ static struct flintdb_row *genericfile_cursor_next(struct flintdb_cursor_row *cu
             ssize_t n2 = bio->readline(bio, cp->line + n, sizeof(cp->line) - (size_t)n, e);
             if (n2 < 0)
                 break; // EOF mid-record; best-effort
+            cp->lineno++;
             n += n2;
         }
 
@@ -345,6 +589,15 @@ static struct flintdb_row *genericfile_cursor_next(struct flintdb_cursor_row *cu
             THROW_S(e);
         }
 
+        // In lenient mode skip malformed lines, keeping them for the report
+        const char *reason = cp->file->lenient ? formatter_malformed(f) : NULL;
+        if (reason) {
+            malformed_add(cp->file, line, cp->line, (size_t)n, reason);
+            r->free(r);
+            r = NULL;
+            continue;
+        }
+
         // Apply filters (both indexable and non-indexable parts)
         int matched = 1;
         if (filter != NULL) {
@@ -419,6 +672,8 @@ static struct flintdb_cursor_row *genericfile_find(const struct flintdb_genericf
     cp->rowidx = 0;
     cp->initialized = 0;
     cp->file_data_header = priv->file_data_header;
+    cp->file = priv;
+    malformed_clear(priv);
 
     cursor->next = genericfile_cursor_next;
     cursor->close = genericfile_cursor_close;
@@ -585,6 +840,7 @@ static void genericfile_close(struct flintdb_genericfile *me) {
             if (priv->formatter.close) {
                 priv->formatter.close(&priv->formatter);
             }
+            malformed_clear(priv);
             flintdb_meta_close(&priv->meta);
         }
         FREE(priv);
@@ -649,7 +905,7 @@ static struct flintdb_genericfile *textfile_open(const char *file, enum flintdb_
             if (priv->meta.columns.length <= 0)
                 THROW(e, "meta has no columns");
         }
-    } else if (mode == FLINTDB_RDWR) {
+    } else if (mode == FLINTDB_RDWR || mode == FLINTDB_APPEND) {
         char dir[PATH_MAX] = {0};
         getdir(file, dir);
         mkdirs(dir, S_IRWXU);
@@ -686,6 +942,10 @@ static struct flintdb_genericfile *textfile_open(const char *file, enum flintdb_
 
     priv->file_data_header = priv->meta.absent_header ? HEADER_ABSENT : HEADER_PRESENT;
 
+    // Append mode counts the existing file now, so positions continue it
+    if (mode == FLINTDB_APPEND && genericfile_append_scan(priv, e) != 0)
+        THROW_S(e);
+
     return f;
 
 EXCEPTION:
@@ -727,6 +987,8 @@ struct flintdb_genericfile *flintdb_genericfile_open(const char *file, enum flin
         plugin = plugin_find_by_extension(ext, e);
     }
     
+    if (plugin && plugin->open && mode == FLINTDB_APPEND)
+        THROW(e, "append mode is only supported for text files: %s", file);
     if (plugin && plugin->open) {
         DEBUG("genericfile_open: using plugin '%s' for file '%s'", plugin->name, file);
         return plugin->open(file, mode, meta, e);
@@ -30,6 +30,18 @@ struct formatter {
 
 // Formatter operations
 int formatter_init(enum fileformat format, struct flintdb_meta *meta, struct formatter *formatter, char **e);
+int formatter_map_header(struct formatter *formatter, const char *line, u32 len, char **e);
+const char *formatter_malformed(const struct formatter *formatter);
+
+// Block compression (compress.c), by the format compress_format returns for a COMPRESSOR name
+u8 compress_format(const char *name, char **e);
+i32 compress_bound(u8 format, i32 len);
+i32 stream_compress(u8 format, const char *in, const i32 len, char *out, i32 out_len, char **e);
+i32 stream_decompress(u8 format, const char *in, const i32 len, char *out, i32 out_len, char **e);
+
+// Row checksums (compress.c), by the format checksum_format returns for a CHECKSUM name
+u8 checksum_format(const char *name, char **e);
+u32 checksum_of(u8 format, const char *data, i32 len);
 
 
 
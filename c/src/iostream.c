@@ -13,6 +13,9 @@
 #include <sys/fcntl.h>
 #include <unistd.h>
 #include <zlib.h> // .tsv.gz / .csv.gz support
+#ifdef HAVE_ZSTD
+#include <zstd.h> // .tsv.zst / .csv.zst support
+#endif
 
 struct stream_priv {
     int fd;
@@ -24,6 +27,19 @@ struct gzstream_priv {
     enum flintdb_open_mode mode;
 };
 
+#ifdef HAVE_ZSTD
+struct zstdstream_priv {
+    int fd;
+    enum flintdb_open_mode mode;
+    ZSTD_DStream *ds;
+    ZSTD_CStream *cs;
+    char *buffer; // compressed bytes read ahead (read) or pending output (write)
+    size_t buffer_size;
+    ZSTD_inBuffer in;
+    int eof;
+};
+#endif
+
 struct bufio_priv {
     struct stream *underlying;
     char *buffer;
@@ -309,8 +325,9 @@ static struct stream *stream_open_from_file(const char *filename, enum flintdb_o
     if (!filename)
         return NULL;
     // When opening for FLINTDB_RDWR in our usage, we generally want to create a new file for output
-    // and truncate any existing file. Using O_WRONLY avoids requiring the file to exist for read.
-    int flags = (mode == FLINTDB_RDONLY) ? O_RDONLY : (O_WRONLY | O_CREAT | O_TRUNC);
+    // and truncate any existing file; FLINTDB_APPEND keeps it and writes at the end.
+    // Using O_WRONLY avoids requiring the file to exist for read.
+    int flags = (mode == FLINTDB_RDONLY) ? O_RDONLY : (mode == FLINTDB_APPEND) ? (O_WRONLY | O_CREAT | O_APPEND) : (O_WRONLY | O_CREAT | O_TRUNC);
 #ifdef _WIN32
     int fd = open(filename, flags | O_BINARY, S_IRUSR|S_IWUSR|S_IRGRP|S_IROTH);
 #else
@@ -369,7 +386,7 @@ static size_t parse_env_bytes_local(const char *name, size_t defval) {
 struct stream *stream_open_from_gzfile(const char *filename, enum flintdb_open_mode mode, char **e) {
     if (!filename)
         return NULL;
-    const char *m = (mode == FLINTDB_RDONLY) ? "rb" : "wb";
+    const char *m = (mode == FLINTDB_RDONLY) ? "rb" : (mode == FLINTDB_APPEND) ? "ab" : "wb"; // appending adds a gzip member
     gzFile gz = gzopen(filename, m);
     if (!gz) {
         if (e) {
@@ -410,6 +427,163 @@ EXCEPTION:
     return NULL;
 }
 
+#ifdef HAVE_ZSTD
+static ssize_t stream_zstd_read(struct stream *s, char *data, size_t size, char **e) {
+    if (!s || !s->priv || !data || size == 0)
+        return 0;
+    struct zstdstream_priv *p = (struct zstdstream_priv *)s->priv;
+    ZSTD_outBuffer out = {data, size, 0};
+    while (out.pos == 0) {
+        if (p->in.pos == p->in.size) {
+            if (p->eof)
+                break;
+            ssize_t n = read(p->fd, p->buffer, p->buffer_size);
+            if (n < 0) {
+                if (errno == EINTR)
+                    continue;
+                THROW(e, "read failed: %s", strerror(errno));
+            }
+            if (n == 0) {
+                p->eof = 1;
+                continue;
+            }
+            p->in.src = p->buffer;
+            p->in.size = (size_t)n;
+            p->in.pos = 0;
+        }
+        size_t rc = ZSTD_decompressStream(p->ds, &out, &p->in);
+        if (ZSTD_isError(rc))
+            THROW(e, "zstd decompress failed: %s", ZSTD_getErrorName(rc));
+    }
+    return (ssize_t)out.pos;
+
+EXCEPTION:
+    return -1;
+}
+
+// zstd_flush writes the compressed bytes in p->buffer to the file.
+static int zstd_flush(struct zstdstream_priv *p, size_t len, char **e) {
+    size_t written = 0;
+    while (written < len) {
+        ssize_t n = write(p->fd, p->buffer + written, len - written);
+        if (n < 0) {
+            if (errno == EINTR)
+                continue;
+            THROW(e, "write failed: %s", strerror(errno));
+        }
+        written += (size_t)n;
+    }
+    return 0;
+
+EXCEPTION:
+    return -1;
+}
+
+static ssize_t stream_zstd_write(struct stream *s, const char *data, size_t size, char **e) {
+    if (!s || !s->priv || !data || size == 0)
+        return 0;
+    struct zstdstream_priv *p = (struct zstdstream_priv *)s->priv;
+    ZSTD_inBuffer in = {data, size, 0};
+    while (in.pos < in.size) {
+        ZSTD_outBuffer out = {p->buffer, p->buffer_size, 0};
+        size_t rc = ZSTD_compressStream2(p->cs, &out, &in, ZSTD_e_continue);
+        if (ZSTD_isError(rc))
+            THROW(e, "zstd compress failed: %s", ZSTD_getErrorName(rc));
+        if (zstd_flush(p, out.pos, e) != 0)
+            return -1;
+    }
+    return (ssize_t)size;
+
+EXCEPTION:
+    return -1;
+}
+
+static void stream_zstd_close(struct stream *s) {
+    if (!s)
+        return;
+    if (s->priv) {
+        struct zstdstream_priv *p = (struct zstdstream_priv *)s->priv;
+        if (p->cs) {
+            // finish the frame; errors here cannot be reported
+            char *e = NULL;
+            size_t remaining;
+            do {
+                ZSTD_inBuffer in = {NULL, 0, 0};
+                ZSTD_outBuffer out = {p->buffer, p->buffer_size, 0};
+                remaining = ZSTD_compressStream2(p->cs, &out, &in, ZSTD_e_end);
+                if (ZSTD_isError(remaining) || zstd_flush(p, out.pos, &e) != 0)
+                    break;
+            } while (remaining > 0);
+            ZSTD_freeCStream(p->cs);
+        }
+        if (p->ds)
+            ZSTD_freeDStream(p->ds);
+        if (p->fd >= 0)
+            close(p->fd);
+        if (p->buffer)
+            FREE(p->buffer);
+        FREE(p);
+        s->priv = NULL;
+    }
+    FREE(s);
+}
+
+static struct stream *stream_open_from_zstdfile(const char *filename, enum flintdb_open_mode mode, char **e) {
+    struct stream *s = NULL;
+    struct zstdstream_priv *p = NULL;
+    if (!filename)
+        return NULL;
+    int flags = (mode == FLINTDB_RDONLY) ? O_RDONLY : (mode == FLINTDB_APPEND) ? (O_WRONLY | O_CREAT | O_APPEND) : (O_WRONLY | O_CREAT | O_TRUNC);
+    int fd = open(filename, flags, S_IRUSR|S_IWUSR|S_IRGRP|S_IROTH);
+    if (fd < 0)
+        THROW(e, "open failed: %s (%s)", filename, strerror(errno));
+
+    s = (struct stream *)CALLOC(1, sizeof(struct stream));
+    p = (struct zstdstream_priv *)CALLOC(1, sizeof(struct zstdstream_priv));
+    if (!s || !p)
+        THROW(e, "Out of memory");
+    p->fd = fd;
+    p->mode = mode;
+    if (mode == FLINTDB_RDONLY) {
+        p->buffer_size = ZSTD_DStreamInSize();
+        p->ds = ZSTD_createDStream();
+        if (!p->ds)
+            THROW(e, "zstd init failed: %s", filename);
+        ZSTD_initDStream(p->ds);
+    } else {
+        p->buffer_size = ZSTD_CStreamOutSize();
+        p->cs = ZSTD_createCStream();
+        if (!p->cs)
+            THROW(e, "zstd init failed: %s", filename);
+        ZSTD_CCtx_setParameter(p->cs, ZSTD_c_compressionLevel, ZSTD_CLEVEL_DEFAULT);
+    }
+    p->buffer = (char *)MALLOC(p->buffer_size);
+    if (!p->buffer)
+        THROW(e, "Out of memory");
+    s->priv = p;
+    s->read = &stream_zstd_read;
+    s->write = &stream_zstd_write;
+    s->close = &stream_zstd_close;
+    return s;
+
+EXCEPTION:
+    if (p) {
+        if (p->ds)
+            ZSTD_freeDStream(p->ds);
+        if (p->cs)
+            ZSTD_freeCStream(p->cs);
+        if (p->buffer)
+            FREE(p->buffer);
+        FREE(p);
+    }
+    if (s)
+        FREE(s);
+    if (fd >= 0)
+        close(fd);
+    return NULL;
+}
+#endif
+
 struct bufio *bufio_wrap_stream(struct stream *s, size_t buffer_size, char **e) {
     if (!s || buffer_size == 0)
         buffer_size = 1 << 16; // default 64KB
@@ -465,6 +639,13 @@ struct stream *file_stream_open(const char *filename, enum flintdb_open_mode mod
         return NULL;
     if (suffix(filename, ".gz") || suffix(filename, ".gzip")) {
         return stream_open_from_gzfile(filename, mode, e);
+    } else if (suffix(filename, ".zst") || suffix(filename, ".zstd")) {
+#ifdef HAVE_ZSTD
+        return stream_open_from_zstdfile(filename, mode, e);
+#else
+        if (e) *e = "zstd support not built (rebuild with libzstd)";
+        return NULL;
+#endif
     } else {
         return stream_open_from_file(filename, mode, e);
     }
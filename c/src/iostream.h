@@ -30,7 +30,7 @@ struct bufio {
     void *priv;
 };
 
-// Opens a file stream, automatically choosing gzip or plain file based on filename suffix
+// Opens a file stream, automatically choosing gzip, zstd or plain file based on filename suffix
 struct stream * file_stream_open(const char *filename, enum flintdb_open_mode mode, char **e); 
 struct bufio *  file_bufio_open(const char *filename, enum flintdb_open_mode mode, size_t buffer_size, char **e);
 struct bufio *  bufio_wrap_stream(struct stream *s, size_t buffer_size, char **e);
@@ -30,6 +30,10 @@ enum fileformat detect_file_format(const char *name) {
         return FORMAT_TSV;
     if (suffix(name, ".csv.gz"))
         return FORMAT_CSV;
+    if (suffix(name, ".tsv.zst"))
+        return FORMAT_TSV;
+    if (suffix(name, ".csv.zst"))
+        return FORMAT_CSV;
     if (suffix(name, ".tsv"))
         return FORMAT_TSV;
     if (suffix(name, ".csv"))
@@ -332,7 +336,8 @@ int flintdb_meta_compare(const struct flintdb_meta *a, const struct flintdb_meta
         if (strncasecmp(ca->name, cb->name, MAX_COLUMN_NAME_LIMIT) != 0) return -1;
         if (ca->type != cb->type) return -1;
         if (ca->bytes != cb->bytes) return -1;
-        if (ca->precision != cb->precision) return -1;
+        // a schema read back from SQL has -1 for an unspecified precision
+        if ((ca->precision > 0 ? ca->precision : 0) != (cb->precision > 0 ? cb->precision : 0)) return -1;
     }
     if (a->indexes.length != b->indexes.length) return -1;
     for(int i = 0; i < a->indexes.length; i++) {
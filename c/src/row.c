@@ -511,6 +511,9 @@ static void row_set(struct flintdb_row *r, u16 i, struct flintdb_variant *v, cha
         case VARIANT_STRING:
             flintdb_variant_copy(&r->array[i], v);
             return;
+        case VARIANT_NULL:
+            flintdb_variant_null_set(&r->array[i]);
+            return;
         case VARIANT_INT8:
         case VARIANT_UINT8:
         case VARIANT_INT16:
@@ -2589,8 +2592,20 @@ struct text_formatter_priv {
 
     struct string_pool *pool; // arena for field strings
     unsigned char *temp_is_pool; // flags per temp_fields entry: 1 if allocated from pool, 0 if heap or NULL
+
+    int *field_of; // column -> field index from the header line; NULL when fields are in column order
+    u32 header_fields; // fields in the header line; 0 when there is none
+    char malformed[128]; // why the last decoded record is malformed; empty if it is not
 };
 
+// Set the NULL token; "" (a quoted empty field) makes empty fields NULL.
+static void text_nil_token(struct text_formatter_priv *priv, const char *token) {
+    if (strcmp(token, "\"\"") == 0)
+        token = "";
+    strncpy(priv->nil_str, token, sizeof(priv->nil_str) - 1);
+    priv->nil_str[sizeof(priv->nil_str) - 1] = '\0';
+}
+
 static int text_escape(struct text_formatter_priv *priv, const char *field, u32 fieldlen, struct buffer *out, char **e) { // equivalent to TSVFile.java TEXTROWFORMATTER.appendEscaped()
     if (!priv || !out)
         THROW(e, "text_escape: invalid args");
@@ -2621,8 +2636,8 @@ static int text_escape(struct text_formatter_priv *priv, const char *field, u32
         return 0;
     }
 
-    // CSV-style quoting: wrap if contains QUOTE/DELIM/newline
-    int needsQuote = 0;
+    // CSV-style quoting: wrap if contains QUOTE/DELIM/newline, or reads as the NULL token
+    int needsQuote = fieldlen == priv->nil_len && memcmp(field, priv->nil_str, fieldlen) == 0;
     for (u32 i = 0; i < fieldlen && !needsQuote; i++) {
         char ch = field[i];
         if (ch == QUOTE || ch == '\n' || ch == '\r' || ch == DELIM)
@@ -2703,6 +2718,8 @@ static int text_split_fast_unquoted(struct text_formatter_priv *priv, const char
             if (!na) {
                 THROW(e, "Out of memory");
             }
+            // zero the new slots, which formatter_close frees
+            memset(na + old_cap, 0, (size_t)(cap - old_cap) * sizeof(char *));
             arr = na;
             priv->temp_fields = arr;
             priv->temp_fields_cap = cap;
@@ -2782,6 +2799,7 @@ static int text_split(struct text_formatter_priv *priv, const char *line, u32 li
 
     int qoute = 0;        // inside quote
     int quoted_field = 0; // remember if current field was quoted (affects NULL token handling)
+    int eol = 0;          // record ended at a newline
     u32 i = 0;
 
 // Inline function to finalize and add a field
@@ -2808,6 +2826,7 @@ static int text_split(struct text_formatter_priv *priv, const char *line, u32 li
             cap <<= 1;                                                                   \
             arr = (char **)REALLOC(arr, cap * sizeof(char *));                           \
             if (!arr) THROW(e, "Out of memory");                                                  \
+            memset(arr + old_cap, 0, (size_t)(cap - old_cap) * sizeof(char *));          \
             priv->temp_fields = arr;                                                     \
             priv->temp_fields_cap = cap;                                                 \
             unsigned char *nf = (unsigned char *)REALLOC(flags, cap * sizeof(unsigned char)); \
@@ -2835,6 +2854,7 @@ static int text_split(struct text_formatter_priv *priv, const char *line, u32 li
                 i += 2;
             else
                 i += 1;
+            eol = 1;
             break; // stop at end of record
         }
 
@@ -2908,7 +2928,7 @@ static int text_split(struct text_formatter_priv *priv, const char *line, u32 li
     }
 
     // If we haven't hit a newline, flush the last field (end-of-buffer record)
-    if (i >= linelen) {
+    if (!eol) {
         FINALIZE_FIELD();
     }
 
@@ -2985,10 +3005,7 @@ static int text_encode(struct formatter *me, struct flintdb_row *r, struct buffe
         case VARIANT_STRING: {
             const char *s = r->string_get(r, i, e);
             u32 L = s ? (u32)strlen(s) : 0;
-            if (s && L > 0)
-                text_escape(priv, s, L, out, e);
-            else
-                buffer_put_bytes(out, "", 0, e);
+            text_escape(priv, s ? s : "", L, out, e);
             break;
         }
         case VARIANT_DOUBLE:
@@ -3068,6 +3085,15 @@ EXCEPTION:
     return -1;
 }
 
+extern const char * flintdb_variant_type_name(enum flintdb_variant_type  t);
+
+// Note a field that did not parse as its column type; empty fields are NULL.
+static void text_malformed(struct text_formatter_priv *priv, const struct flintdb_column *col, const char *fv, u32 fl) {
+    if (fl == 0 || priv->malformed[0])
+        return;
+    snprintf(priv->malformed, sizeof(priv->malformed), "%s: invalid %s: %.32s", col->name, flintdb_variant_type_name(col->type), fv);
+}
+
 HOT_PATH
 static int text_decode(struct formatter *me, struct buffer *in, struct flintdb_row *r, char **e) { // equivalent to TSVFile.java TEXTROWFORMATTER.parse()
     struct text_formatter_priv *priv = (struct text_formatter_priv *)me->priv;
@@ -3097,9 +3123,19 @@ static int text_decode(struct formatter *me, struct buffer *in, struct flintdb_r
     const struct flintdb_meta *m = me->meta;
     int cols = m->columns.length;
 
+    // Note malformed records; a trailing delimiter (as in TPC-H .tbl files) is not one
+    priv->malformed[0] = '\0';
+    u32 expected = priv->header_fields ? priv->header_fields : (u32)cols;
+    if (nfields != expected && !(nfields == expected + 1 && (!fields[expected] || !fields[expected][0])))
+        snprintf(priv->malformed, sizeof(priv->malformed), "expected %u fields, found %u", expected, nfields);
+
     for (int i = 0; i < cols && i < r->length; i++) {
-        const char *fv = (i < (int)nfields) ? fields[i] : NULL;
-        if (fv == NULL) {
+        int fi = priv->field_of ? priv->field_of[i] : i;
+        const char *fv = (fi < (int)nfields) ? fields[fi] : NULL;
+        if (fv == NULL && fi < (int)nfields && m->columns.a[i].nullspec == SPEC_NOT_NULL && m->columns.a[i].type == VARIANT_STRING) {
+            // The NULL token is NULL only for nullable columns
+            flintdb_variant_string_set(&r->array[i], priv->nil_str, priv->nil_len);
+        } else if (fv == NULL) {
             flintdb_variant_null_set(&r->array[i]);
         } else {
             // Get column type and field length for optimized parsing
@@ -3117,6 +3153,7 @@ static int text_decode(struct formatter *me, struct buffer *in, struct flintdb_r
                 if (parse_i64(fv, fl, &val) == 0) {
                     flintdb_variant_i64_set(&r->array[i], val);
                 } else {
+                    text_malformed(priv, col, fv, fl);
                     flintdb_variant_null_set(&r->array[i]);
                 }
             } else if (ctype == VARIANT_INT32) {
@@ -3124,6 +3161,7 @@ static int text_decode(struct formatter *me, struct buffer *in, struct flintdb_r
                 if (parse_i64(fv, fl, &val) == 0) {
                     flintdb_variant_i32_set(&r->array[i], (i32)val);
                 } else {
+                    text_malformed(priv, col, fv, fl);
                     flintdb_variant_null_set(&r->array[i]);
                 }
             } else if (ctype == VARIANT_INT16) {
@@ -3131,6 +3169,7 @@ static int text_decode(struct formatter *me, struct buffer *in, struct flintdb_r
                 if (parse_i64(fv, fl, &val) == 0) {
                     flintdb_variant_i16_set(&r->array[i], (i16)val);
                 } else {
+                    text_malformed(priv, col, fv, fl);
                     flintdb_variant_null_set(&r->array[i]);
                 }
             } else if (ctype == VARIANT_INT8) {
@@ -3138,6 +3177,7 @@ static int text_decode(struct formatter *me, struct buffer *in, struct flintdb_r
                 if (parse_i64(fv, fl, &val) == 0) {
                     flintdb_variant_i8_set(&r->array[i], (i8)val);
                 } else {
+                    text_malformed(priv, col, fv, fl);
                     flintdb_variant_null_set(&r->array[i]);
                 }
             } else if (ctype == VARIANT_DOUBLE || ctype == VARIANT_FLOAT) {
@@ -3145,6 +3185,7 @@ static int text_decode(struct formatter *me, struct buffer *in, struct flintdb_r
                 if (parse_f64(fv, fl, &val) == 0) {
                     flintdb_variant_f64_set(&r->array[i], val);
                 } else {
+                    text_malformed(priv, col, fv, fl);
                     flintdb_variant_null_set(&r->array[i]);
                 }
             }
@@ -3158,6 +3199,7 @@ static int text_decode(struct formatter *me, struct buffer *in, struct flintdb_r
                         flintdb_variant_time_set(&r->array[i], t);
                     }
                 } else {
+                    text_malformed(priv, col, fv, fl);
                     flintdb_variant_null_set(&r->array[i]);
                 }
             }
@@ -3213,6 +3255,80 @@ EXCEPTION:
     return -1;
 }
 
+// Map columns to fields by the names in a header line, so files whose columns
+// are in another order decode correctly. Fails if a column is not in the header.
+int formatter_map_header(struct formatter *me, const char *line, u32 len, char **e) {
+    struct text_formatter_priv *priv = NULL;
+    char **fields = NULL;
+    u32 nfields = 0;
+    int *field_of = NULL;
+    if (!me || !me->priv || me->decode != &text_decode)
+        return 0; // binary formats have no header
+    priv = (struct text_formatter_priv *)me->priv;
+    if (text_split(priv, line, len, &fields, &nfields, e) < 0)
+        THROW(e, "header: split failed");
+
+    const struct flintdb_meta *m = me->meta;
+    field_of = CALLOC(m->columns.length > 0 ? m->columns.length : 1, sizeof(int));
+    if (!field_of)
+        THROW(e, "Out of memory");
+    int identity = 1;
+    for (int i = 0; i < m->columns.length; i++) {
+        field_of[i] = -1;
+        for (u32 k = 0; k < nfields; k++) {
+            if (fields[k] && strcasecmp(fields[k], m->columns.a[i].name) == 0) {
+                field_of[i] = (int)k;
+                break;
+            }
+        }
+        if (field_of[i] < 0)
+            THROW(e, "header has no column: %s", m->columns.a[i].name);
+        identity = identity && field_of[i] == i;
+    }
+    for (u32 k = 0; k < nfields; k++) {
+        if (!fields[k]) continue;
+        if (priv->temp_is_pool && priv->temp_is_pool[k]) {
+            priv->pool->return_string(priv->pool, fields[k]);
+            priv->temp_is_pool[k] = 0;
+        } else {
+            FREE(fields[k]);
+        }
+        fields[k] = NULL;
+    }
+    if (priv->field_of)
+        FREE(priv->field_of);
+    priv->field_of = NULL;
+    priv->header_fields = nfields;
+    if (identity)
+        FREE(field_of);
+    else
+        priv->field_of = field_of;
+    return 0;
+
+EXCEPTION:
+    for (u32 k = 0; fields && k < nfields; k++) {
+        if (!fields[k]) continue;
+        if (priv->temp_is_pool && priv->temp_is_pool[k]) {
+            priv->pool->return_string(priv->pool, fields[k]);
+            priv->temp_is_pool[k] = 0;
+        } else {
+            FREE(fields[k]);
+        }
+        fields[k] = NULL;
+    }
+    if (field_of)
+        FREE(field_of);
+    return -1;
+}
+
+// Why the record last decoded by a text formatter is malformed, or NULL.
+const char *formatter_malformed(const struct formatter *me) {
+    if (!me || !me->priv || me->decode != &text_decode)
+        return NULL;
+    const struct text_formatter_priv *priv = (const struct text_formatter_priv *)me->priv;
+    return priv->malformed[0] ? priv->malformed : NULL;
+}
+
 // -- Formatter init/close
 
 void formatter_close(struct formatter *me) {
@@ -3240,6 +3356,7 @@ void formatter_close(struct formatter *me) {
     }
     if (priv->temp_is_pool) { FREE(priv->temp_is_pool); priv->temp_is_pool = NULL; }
     if (priv->pool) { priv->pool->free(priv->pool); priv->pool = NULL; }
+    if (priv->field_of) { FREE(priv->field_of); priv->field_of = NULL; }
 
     FREE(me->priv);
     me->priv = NULL;
@@ -3274,7 +3391,7 @@ int formatter_init(enum fileformat format, struct flintdb_meta *meta, struct for
         strncpy(priv->name, "CSV", sizeof(priv->name));
 
         if (meta->nil_str[0])
-            strncpy(priv->nil_str, meta->nil_str, sizeof(priv->nil_str));
+            text_nil_token(priv, meta->nil_str);
         if (meta->delimiter)
             priv->delimiter = meta->delimiter;
         if (meta->quote)
@@ -3338,7 +3455,7 @@ int formatter_init(enum fileformat format, struct flintdb_meta *meta, struct for
         strncpy(priv2->name, "TSV", sizeof(priv2->name));
 
         if (meta->nil_str[0])
-            strncpy(priv2->nil_str, meta->nil_str, sizeof(priv2->nil_str));
+            text_nil_token(priv2, meta->nil_str);
         if (meta->delimiter)
             priv2->delimiter = meta->delimiter;
         if (meta->quote)
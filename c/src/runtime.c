@@ -1,8 +1,10 @@
+#include <stdarg.h>
 #include <stdio.h>
 #include <stdlib.h>
 #include <string.h>
 #include <errno.h>
 
+#include "flintdb.h"
 #include "runtime.h"
 
 #ifdef _WIN32
@@ -54,6 +56,29 @@ char* l_now(char* buff, size_t bsz) {
 	return buff;
 }
 
+static flintdb_log_fn log_handler = NULL;
+
+void flintdb_log_handler(flintdb_log_fn handler) {
+	log_handler = handler;
+}
+
+void l_log(const char *level, const char *file, int line, const char *func, const char *format, ...) {
+	char message[ERROR_BUFSZ];
+	va_list ap;
+	va_start(ap, format);
+	vsnprintf(message, sizeof(message), format, ap);
+	va_end(ap);
+
+	flintdb_log_fn handler = log_handler;
+	if (handler) {
+		handler(level, file, line, func, message);
+		return;
+	}
+	char now[32] = {0};
+	fprintf(stdout, "%s %5s %s:%04d %s %s\n", l_now(now, sizeof(now)), level, file, line, func, message);
+	fflush(stdout);
+}
+
 // return elapsed time in milliseconds
 u64 time_elapsed(struct timespec *watch) {
 	struct timespec now;
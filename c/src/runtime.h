@@ -31,13 +31,13 @@
 #ifndef NDEBUG
   #define TRACE(format, ...) ({ char __NOW[32] = {0}; fprintf(stdout, "%s %s %s:%04d %s " format "\n", l_now(__NOW, sizeof(__NOW)),   "TRACE", __FILE__, __LINE__, __FUNCTION__, ##__VA_ARGS__); fflush(stdout); })
   #define DEBUG(format, ...) ({ char __NOW[32] = {0}; fprintf(stdout, "%s %s %s:%04d %s " format "\n", l_now(__NOW, sizeof(__NOW)),   "DEBUG", __FILE__, __LINE__, __FUNCTION__, ##__VA_ARGS__); fflush(stdout); })
-  #define   LOG(format, ...)   ({ char __NOW[32] = {0}; fprintf(stdout, "%s %s %s:%04d %s " format "\n", l_now(__NOW, sizeof(__NOW)), "  LOG", __FILE__, __LINE__, __FUNCTION__, ##__VA_ARGS__); fflush(stdout); })
-  #define  WARN(format, ...)   ({ char __NOW[32] = {0}; fprintf(stdout, "%s %s %s:%04d %s " format "\n", l_now(__NOW, sizeof(__NOW)), " WARN", __FILE__, __LINE__, __FUNCTION__, ##__VA_ARGS__); fflush(stdout); })
+  #define   LOG(format, ...)   l_log("LOG", __FILE__, __LINE__, __FUNCTION__, format, ##__VA_ARGS__)
+  #define  WARN(format, ...)   l_log("WARN", __FILE__, __LINE__, __FUNCTION__, format, ##__VA_ARGS__)
 #else
   #define TRACE(...)
   #define DEBUG(...)
-  #define   LOG(format, ...)   ({ char __NOW[32] = {0}; fprintf(stdout, "%s %s %s:%04d %s " format "\n", l_now(__NOW, sizeof(__NOW)),  "  LOG", __FILE__, __LINE__, __FUNCTION__, ##__VA_ARGS__); fflush(stdout); })
-  #define  WARN(format, ...)   ({ char __NOW[32] = {0}; fprintf(stdout, "%s %s %s:%04d %s " format "\n", l_now(__NOW, sizeof(__NOW)),  " WARN", __FILE__, __LINE__, __FUNCTION__, ##__VA_ARGS__); fflush(stdout); })
+  #define   LOG(format, ...)   l_log("LOG", __FILE__, __LINE__, __FUNCTION__, format, ##__VA_ARGS__)
+  #define  WARN(format, ...)   l_log("WARN", __FILE__, __LINE__, __FUNCTION__, format, ##__VA_ARGS__)
 #endif
   #define PANIC(format, ...)   ({ char __NOW[32] = {0}; fprintf(stdout, "%s %s %s:%04d %s " format "\n", l_now(__NOW, sizeof(__NOW)), " PANIC", __FILE__, __LINE__, __FUNCTION__, ##__VA_ARGS__); fflush(stdout); abort(); })
 
@@ -108,6 +108,7 @@ static inline void flintdb_localtime_r(const time_t *t, struct tm *out) {
 
 
 char* l_now(char* buff, size_t bsz); // logging
+void l_log(const char *level, const char *file, int line, const char *func, const char *format, ...); // LOG and WARN, through flintdb_log_handler
 u64 time_elapsed(struct timespec *watch);
 f64 time_ops(i64 rows, struct timespec *watch);
 char * time_dur(u64 ms, char *buf, i32 len);
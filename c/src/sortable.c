@@ -13,6 +13,11 @@
 extern int row_bytes(const struct flintdb_meta *m);
 #define MIN(a,b) (((a)<(b))?(a):(b))
 
+// With a memory bound, the sort file is mapped in at least this many chunks
+// of at most FILESORT_MAX_CHUNK_BYTES, the least recently used unmapped first.
+#define FILESORT_MIN_CHUNKS 8
+#define FILESORT_MAX_CHUNK_BYTES (16 * 1024 * 1024)
+
 
 struct flintdb_filesort_priv {
 	struct storage storage;      // backing storage for row payloads
@@ -119,6 +124,12 @@ struct flintdb_row * filesort_read(const struct flintdb_filesort *me, i64 i, cha
             buf->free(buf);
         return NULL;
     }
+    // decoded strings point into buf; copy them before it is freed
+    for (int c = 0; c < r->length; c++) {
+        struct flintdb_variant *v = &r->array[c];
+        if (v->type == VARIANT_STRING && !v->value.b.owned)
+            flintdb_variant_string_set(v, v->value.b.data, v->value.b.length);
+    }
     if (buf)
         buf->free(buf);
     r->rowid = off; // for swap/put
@@ -252,12 +263,72 @@ EXCEPTION:
     return -1;
 }
 
+i64 flintdb_filesort_unique(struct flintdb_filesort *me, int (*cmpr)(const void *obj, const struct flintdb_row *a, const struct flintdb_row *b), const void *ctx, char **e) {
+    struct flintdb_filesort_priv *priv = (struct flintdb_filesort_priv *)me->priv;
+    struct flintdb_row *prev = NULL;
+    if (!priv || !cmpr) {
+        THROW(e, "bad arguments");
+    }
+    if (priv->rows <= 1)
+        return priv->rows;
+
+    prev = filesort_read(me, 0, e);
+    if (e && *e)
+        THROW_S(e);
+    i64 kept = 1;
+    for (i64 i = 1; i < priv->rows; i++) {
+        struct flintdb_row *r = filesort_read(me, i, e);
+        if (e && *e)
+            THROW_S(e);
+        if (cmpr(ctx, prev, r) == 0) {
+            r->free(r);
+            continue;
+        }
+        priv->offsets[kept++] = priv->offsets[i];
+        prev->free(prev);
+        prev = r;
+    }
+    prev->free(prev);
+    priv->rows = kept;
+    return kept;
+
+EXCEPTION:
+    if (prev)
+        prev->free(prev);
+    return -1;
+}
+
+i64 flintdb_filesort_limit(struct flintdb_filesort *me, i64 n) {
+    struct flintdb_filesort_priv *priv = (struct flintdb_filesort_priv *)me->priv;
+    if (!priv)
+        return -1;
+    if (n >= 0 && n < priv->rows)
+        priv->rows = n;
+    return priv->rows;
+}
+
 i16 compact_safe(int bytes) {
     if (bytes >= 4080) return 4080; // storage block header (16) + data (4080) = 4096
     return -1;
 }
 
+// Chunk size and count that keep at most memory bytes of the sort file mapped.
+static void filesort_memory_opts(struct storage_opts *opts, i64 memory) {
+    i64 chunk = memory / FILESORT_MIN_CHUNKS;
+    if (chunk > FILESORT_MAX_CHUNK_BYTES)
+        chunk = FILESORT_MAX_CHUNK_BYTES;
+    if (chunk < opts->block_bytes + BLOCK_HEADER_BYTES)
+        chunk = opts->block_bytes + BLOCK_HEADER_BYTES;
+    i64 chunks = memory / chunk;
+    opts->increment = (int)chunk;
+    opts->cache_limit = (int)(chunks < 2 ? 2 : chunks);
+}
+
 struct flintdb_filesort *flintdb_filesort_new(const char *file, const struct flintdb_meta *m, char **e) {
+    return flintdb_filesort_new_bounded(file, m, 0, e);
+}
+
+struct flintdb_filesort *flintdb_filesort_new_bounded(const char *file, const struct flintdb_meta *m, i64 memory, char **e) {
 	struct flintdb_filesort *sorter = (struct flintdb_filesort *)CALLOC(1, sizeof(struct flintdb_filesort));
 	struct flintdb_filesort_priv *priv = NULL;
 	if (!sorter) THROW(e, "Out of memory");
@@ -284,6 +355,8 @@ struct flintdb_filesort *flintdb_filesort_new(const char *file, const struct fli
     opts.mode = FLINTDB_RDWR;
     // leave opts.increment = 0 to let storage use its default
     // LOG("opts.block_bytes=%d, opts.compact=%d", opts.block_bytes, opts.compact);
+    if (memory > 0)
+        filesort_memory_opts(&opts, memory);
     strncpy(opts.file, file, sizeof(opts.file) - 1);
     if (storage_open(&priv->storage, opts, e) != 0)
         THROW_S(e);
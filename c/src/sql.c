@@ -57,6 +57,7 @@ static struct flintdb_sql *sql_pool_borrow(struct flintdb_sql_pool *pool) {
         q->dictionary = NULL;
         q->directory = NULL;
         q->compressor = NULL;
+        q->checksum = NULL;
         q->compact = NULL;
         q->cache = NULL;
         q->date = NULL;
@@ -91,6 +92,7 @@ static void sql_pool_return(struct flintdb_sql_pool *pool, struct flintdb_sql *q
     if (q->dictionary) FREE(q->dictionary);
     if (q->directory) FREE(q->directory);
     if (q->compressor) FREE(q->compressor);
+    if (q->checksum) FREE(q->checksum);
     if (q->compact) FREE(q->compact);
     if (q->cache) FREE(q->cache);
     if (q->date) FREE(q->date);
@@ -135,6 +137,7 @@ static void sql_pool_free(struct flintdb_sql_pool *pool) {
                 if (q->dictionary) FREE(q->dictionary);
                 if (q->directory) FREE(q->directory);
                 if (q->compressor) FREE(q->compressor);
+                if (q->checksum) FREE(q->checksum);
                 if (q->compact) FREE(q->compact);
                 if (q->cache) FREE(q->cache);
                 if (q->date) FREE(q->date);
@@ -1370,6 +1373,12 @@ static void parse_statements(struct tokens *toks, struct flintdb_sql *q, char **
                 for (char *p = tmp; *p; ++p)
                     *p = (char)tolower((unsigned char)*p);
                 sql_set_string(&q->compressor, tmp);
+            } else if (equals_ic(k, "CHECKSUM")) {
+                char tmp[SQL_OBJECT_STRING_LIMIT];
+                s_copy(tmp, sizeof(tmp), v);
+                for (char *p = tmp; *p; ++p)
+                    *p = (char)tolower((unsigned char)*p);
+                sql_set_string(&q->checksum, tmp);
             } else if (equals_ic(k, "COMPACT")) {
                 char tmp[SQL_OBJECT_STRING_LIMIT];
                 s_copy(tmp, sizeof(tmp), v);
@@ -1451,6 +1460,7 @@ void flintdb_sql_free(struct flintdb_sql *q) {
         if (q->dictionary) FREE(q->dictionary);
         if (q->directory) FREE(q->directory);
         if (q->compressor) FREE(q->compressor);
+        if (q->checksum) FREE(q->checksum);
         if (q->compact) FREE(q->compact);
         if (q->cache) FREE(q->cache);
         if (q->date) FREE(q->date);
@@ -1843,6 +1853,8 @@ int flintdb_sql_to_meta(struct flintdb_sql *in, struct flintdb_meta *out, char *
     }
     if (in->compressor && !strempty(in->compressor))
         s_copy(out->compressor, sizeof(out->compressor), in->compressor);
+    if (in->checksum && !strempty(in->checksum))
+        s_copy(out->checksum, sizeof(out->checksum), in->checksum);
     if (in->compact && !strempty(in->compact))
         out->compact = (i16)parse_bytes(in->compact);
     // if (!strempty(in->increment)) out->increment = parse_bytes(in->increment);
@@ -2052,6 +2064,12 @@ int flintdb_meta_to_sql_string(const struct flintdb_meta *m, char *s, i32 len, c
         s_cat(tmp, sizeof(tmp), m->compressor);
         extras++;
     }
+    if (m->checksum[0]) {
+        s_cat(tmp, sizeof(tmp), extras > 0 ? ", " : " ");
+        s_cat(tmp, sizeof(tmp), "CHECKSUM=");
+        s_cat(tmp, sizeof(tmp), m->checksum);
+        extras++;
+    }
     if (m->compact >= 0) {
         s_cat(tmp, sizeof(tmp), extras > 0 ? ", " : " ");
         s_cat(tmp, sizeof(tmp), "COMPACT=");
@@ -62,6 +62,7 @@ struct flintdb_sql {
     
     // Dynamically allocated metadata fields (Phase 1)
     char *compressor;
+    char *checksum;
     char *compact;
     char *cache;
     char *date;
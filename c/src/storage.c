@@ -111,6 +111,37 @@ static void storage_commit(struct storage *me, u8 force, char **e) {
     bb.i64_put(&bb, me->count, e);            // number of blocks
 }
 
+// Reads the common header of an existing file from me->h.
+static void storage_header_load(struct storage *me, char **e) {
+    struct buffer *h = me->h;
+    struct buffer bb = {0};
+    h->slice(h, CUSTOM_HEADER_BYTES, COMMON_HEADER_BYTES, &bb, e);
+    if (e && *e)
+        THROW_S(e);
+
+    bb.i64_get(&bb, e);            // reserved
+    me->free = bb.i64_get(&bb, e); // The front of deleted blocks
+    bb.i64_get(&bb, e);            // The tail of deleted blocks => not used in mmap
+    bb.i16_get(&bb, e);            // version:i16
+    i32 inc = bb.i32_get(&bb, e);  // increment:i32
+    if (inc <= 0)
+        THROW(e, "Invalid increment size: %d, file:%s", inc, me->opts.file); // old version was (10MB)
+    if (inc != me->increment) {
+        me->increment = inc;
+        me->mmap_bytes = me->block_bytes * (me->increment / me->block_bytes);
+    }
+    bb.skip(&bb, R24LEN);             // reserved
+    i16 blksize = bb.i16_get(&bb, e); // BLOCK Data Max Size (exclude BLOCK Header)
+    if (blksize != me->opts.block_bytes) {
+        THROW(e, "Block size mismatch: header=%d, opts=%d", blksize, me->opts.block_bytes);
+    }
+    me->count = bb.i64_get(&bb, e);
+    assert(me->count > -1);
+
+EXCEPTION:
+    return;
+}
+
 static void storage_cache_free(keytype k, valtype v) {
     struct buffer *buffer = (struct buffer *)v;
     if (buffer) {
@@ -192,12 +223,28 @@ EXCEPTION:
     return NULL;
 }
 
+// Whole-file read-only mapping of the blocks after the header (storage_opts.mapped)
+struct storage_mapped {
+    char *addr;
+    i64 length;
+};
+
 static void storage_mmap_buffer_get(struct storage *me, i64 index, struct buffer *out) {
     i64 absolute = me->block_bytes * index;
     i64 i = absolute / me->mmap_bytes;
     i64 r = absolute % me->mmap_bytes;
     char *e = NULL;
 
+    struct storage_mapped *mapped = (struct storage_mapped *)me->priv;
+    if (mapped) {
+        // no chunk cache to consult, so concurrent reads are safe
+        if (absolute + me->block_bytes <= mapped->length)
+            buffer_wrap(mapped->addr + absolute, me->block_bytes, out);
+        else
+            buffer_wrap(me->clean, me->block_bytes, out); // past the end: an empty block
+        return;
+    }
+
     valtype found = me->cache->get(me->cache, i);
     if (HASHMAP_INVALID_VAL != found) {
         struct buffer *mbb = (struct buffer *)found;
@@ -308,7 +355,7 @@ static struct buffer *storage_mmap_read(struct storage *me, i64 offset, char **e
     i32 length = mbb.i32_get(&mbb, e);
     i64 next = mbb.i64_get(&mbb, e);
 
-    if (next > NEXT_END && length > (me->opts.block_bytes)) {
+    if (next > NEXT_END && length > limit) {
         struct buffer *p = buffer_alloc(length);
         // copy only the first chunk (limit) from the first block
         struct buffer first = {0};
@@ -346,7 +393,7 @@ EXCEPTION:
 }
 
 static inline void storage_mmap_write_priv(struct storage *me, i64 offset, u8 mark, struct buffer *in, char **e) {
-    const int BLOCK_DATA_BYTES = me->opts.block_bytes - BLOCK_HEADER_BYTES;
+    const int BLOCK_DATA_BYTES = me->block_bytes - BLOCK_HEADER_BYTES;
     i64 curr = offset;
     u8 curr_mark = mark;
     i32 remaining = in->remaining(in);
@@ -459,6 +506,13 @@ static void storage_mmap_close(struct storage *me) {
         me->h->free(me->h);
         me->h = NULL;
     }
+    if (me->priv) {
+        struct storage_mapped *mapped = (struct storage_mapped *)me->priv;
+        if (mapped->addr)
+            munmap(mapped->addr, mapped->length);
+        FREE(mapped);
+        me->priv = NULL;
+    }
     if (me->fd > 0) {
         DEBUG("closing fd");
         close(me->fd);
@@ -512,7 +566,26 @@ static int storage_mmap_open(struct storage *me, struct storage_opts opts, char
 
     me->h = buffer_mmap(p, 0, HEADER_BYTES);
 
-    me->cache = hashmap_new(MAPPED_BYTEBUFFER_POOL_SIZE, hashmap_int_hash, hashmap_int_cmpr);
+    if (opts.mapped) {
+        if (opts.mode != FLINTDB_RDONLY)
+            THROW(e, "Mapped storage is read-only: %s", me->opts.file);
+        struct storage_mapped *mapped = CALLOC(1, sizeof(struct storage_mapped));
+        if (!mapped)
+            THROW(e, "Out of memory");
+        me->priv = mapped;
+        if (st.st_size > (i64)HEADER_BYTES) {
+            void *all = mmap(NULL, st.st_size - HEADER_BYTES, PROT_READ, MAP_SHARED, me->fd, HEADER_BYTES);
+            if (all == MAP_FAILED)
+                THROW(e, "Cannot mmap file %s: %s", me->opts.file, strerror(errno));
+            mapped->addr = all;
+            mapped->length = st.st_size - HEADER_BYTES;
+        }
+    }
+
+    if (opts.cache_limit > 0)
+        me->cache = lruhashmap_new(MAPPED_BYTEBUFFER_POOL_SIZE, (u32)opts.cache_limit, hashmap_int_hash, hashmap_int_cmpr);
+    else
+        me->cache = hashmap_new(MAPPED_BYTEBUFFER_POOL_SIZE, hashmap_int_hash, hashmap_int_cmpr);
 
     if (!me->cache)
         THROW(e, "Cannot create cache");
@@ -537,30 +610,9 @@ static int storage_mmap_open(struct storage *me, struct storage_opts opts, char
         if (e && *e)
             THROW_S(e);
     } else {
-        struct buffer *h = me->h;
-        struct buffer bb = {0};
-        h->slice(h, CUSTOM_HEADER_BYTES, COMMON_HEADER_BYTES, &bb, e);
+        storage_header_load(me, e);
         if (e && *e)
             THROW_S(e);
-
-        bb.i64_get(&bb, e);            // reserved
-        me->free = bb.i64_get(&bb, e); // The front of deleted blocks
-        bb.i64_get(&bb, e);            // The tail of deleted blocks => not used in mmap
-        bb.i16_get(&bb, e);            // version:i16
-        i32 inc = bb.i32_get(&bb, e);  // increment:i32
-        if (inc <= 0)
-            THROW(e, "Invalid increment size: %d, file:%s", inc, me->opts.file); // old version was (10MB)
-        if (inc != me->increment) {
-            me->increment = inc;
-            me->mmap_bytes = me->block_bytes * (me->increment / me->block_bytes);
-        }
-        bb.skip(&bb, R24LEN);             // reserved
-        i16 blksize = bb.i16_get(&bb, e); // BLOCK Data Max Size (exclude BLOCK Header)
-        if (blksize != opts.block_bytes) {
-            THROW(e, "Block size mismatch: header=%d, opts=%d", blksize, opts.block_bytes);
-        }
-        me->count = bb.i64_get(&bb, e);
-        assert(me->count > -1);
     }
 
     // Optional diagnostic: print sizing/rounding decisions even in NDEBUG builds.
@@ -583,8 +635,36 @@ EXCEPTION:
 
 /**
  * Memory-backed storage
+ *
+ * VFS storage shares it: chunks are loaded from the VFS file on first use, and
+ * every block changed is written through to it (priv is the open file).
  */
 
+struct storage_vfs_file {
+    const struct flintdb_vfs *vfs;
+    void *f;
+    char *header; // the header as last written, to write only what changed
+};
+
+static void storage_vfs_block_write(struct storage *me, i64 index, char **e);
+
+// Fills chunk i from the VFS file and zeroes the blocks past its end;
+// returns the index of the first of them.
+static i32 storage_vfs_chunk_load(struct storage *me, i64 i, struct buffer *mbb, char **e) {
+    struct storage_vfs_file *vf = (struct storage_vfs_file *)me->priv;
+    i64 n = vf->vfs->read_at(vf->f, mbb->array, me->mmap_bytes, HEADER_BYTES + (i * me->mmap_bytes), e);
+    if (e && *e)
+        THROW_S(e);
+    if (n < 0)
+        n = 0;
+    i32 loaded = (i32)(n / me->block_bytes);
+    memset(mbb->array + ((i64)loaded * me->block_bytes), 0, me->mmap_bytes - ((i64)loaded * me->block_bytes));
+    return loaded;
+
+EXCEPTION:
+    return -1;
+}
+
 static void storage_mem_buffer_get(struct storage *me, i64 index, struct buffer *out) {
     i64 absolute = me->block_bytes * index;
     i64 i = absolute / me->mmap_bytes;
@@ -607,13 +687,21 @@ static void storage_mem_buffer_get(struct storage *me, i64 index, struct buffer
         THROW_S(e);
     }
 
+    i32 first = 0;
+    if (me->priv) {
+        first = storage_vfs_chunk_load(me, i, mbb, &e);
+        if (e && *e) {
+            mbb->free(mbb);
+            THROW_S(e); // out stays unset, callers check its array
+        }
+    }
     me->cache->put(me->cache, i, (valtype)mbb, storage_cache_free);
 
-    if (me->opts.mode == FLINTDB_RDWR) {
-        i32 blocks = me->mmap_bytes / me->block_bytes;
-        i64 next = 1 + (i * blocks);
+    i32 blocks = me->mmap_bytes / me->block_bytes;
+    if (me->opts.mode == FLINTDB_RDWR && first < blocks) {
+        i64 next = 1 + (i * blocks) + first;
         struct buffer bb = {0};
-        for (i32 x = 0; x < blocks; x++) {
+        for (i32 x = first; x < blocks; x++) {
             mbb->slice(mbb, x * me->block_bytes, me->block_bytes, &bb, &e);
             if (e && *e)
                 THROW_S(e);
@@ -624,6 +712,14 @@ static void storage_mem_buffer_get(struct storage *me, i64 index, struct buffer
             bb.i64_put(&bb, next, NULL);
             next++;
         }
+        if (me->priv) {
+            // grow the file by the new empty blocks
+            struct storage_vfs_file *vf = (struct storage_vfs_file *)me->priv;
+            i64 at = (i64)first * me->block_bytes;
+            vf->vfs->write_at(vf->f, mbb->array + at, me->mmap_bytes - at, HEADER_BYTES + (i * me->mmap_bytes) + at, &e);
+            if (e && *e)
+                THROW_S(e);
+        }
         storage_commit(me, STORAGE_COMMIT_DEFAULT, &e);
         if (e && *e)
             THROW_S(e);
@@ -643,6 +739,8 @@ static u8 storage_mem_delete(struct storage *me, i64 offset, char **e) {
     struct buffer c = {0};
 
     storage_mem_buffer_get(me, offset, &p);
+    if (!p.array)
+        THROW(e, "Cannot read block at offset %lld", offset);
     p.slice(&p, 0, p.remaining(&p), &c, e);
     if (e && *e)
         THROW_S(e);
@@ -665,6 +763,9 @@ static u8 storage_mem_delete(struct storage *me, i64 offset, char **e) {
 #ifdef STORAGE_FILL_ZEROED_BLOCK_ON_DELETE
     p.array_put(&p, me->clean, p.remaining(&p), NULL);
 #endif
+    storage_vfs_block_write(me, offset, e);
+    if (e && *e)
+        THROW_S(e);
 
     me->free = offset;
     me->count--;
@@ -688,6 +789,8 @@ static u8 storage_mem_flush(struct storage *me, char **e) {
 static struct buffer *storage_mem_read(struct storage *me, i64 offset, char **e) {
     struct buffer mbb = {0};
     storage_mem_buffer_get(me, offset, &mbb);
+    if (!mbb.array)
+        THROW(e, "Cannot read block at offset %lld", offset);
     u8 status = mbb.i8_get(&mbb, e);
     if (status != STATUS_SET)
         THROW(e, "Block at offset %lld is not set", offset);
@@ -699,7 +802,7 @@ static struct buffer *storage_mem_read(struct storage *me, i64 offset, char **e)
     i32 length = mbb.i32_get(&mbb, e);
     i64 next = mbb.i64_get(&mbb, e);
 
-    if (next > NEXT_END && length > (me->opts.block_bytes)) {
+    if (next > NEXT_END && length > limit) {
         struct buffer *p = buffer_alloc(length);
         struct buffer first = {0};
         mbb.slice(&mbb, 0, limit, &first, e);
@@ -709,6 +812,8 @@ static struct buffer *storage_mem_read(struct storage *me, i64 offset, char **e)
         for (; next > NEXT_END;) {
             struct buffer n = {0};
             storage_mem_buffer_get(me, next, &n);
+            if (!n.array)
+                THROW(e, "Cannot read block at offset %lld", next);
             if (STATUS_SET != n.i8_get(&n, NULL))
                 break;
             n.i8_get(&n, NULL);
@@ -734,7 +839,7 @@ EXCEPTION:
 }
 
 static inline void storage_mem_write_priv(struct storage *me, i64 offset, u8 mark, struct buffer *in, char **e) {
-    const int BLOCK_DATA_BYTES = me->opts.block_bytes - BLOCK_HEADER_BYTES;
+    const int BLOCK_DATA_BYTES = me->block_bytes - BLOCK_HEADER_BYTES;
     i64 curr = offset;
     u8 curr_mark = mark;
     i32 remaining = in->remaining(in);
@@ -748,6 +853,8 @@ static inline void storage_mem_write_priv(struct storage *me, i64 offset, u8 mar
         struct buffer p = {0};
         struct buffer c = {0};
         storage_mem_buffer_get(me, curr, &p);
+        if (!p.array)
+            THROW(e, "Cannot read block at offset %lld", curr);
         p.slice(&p, 0, p.remaining(&p), &c, e);
 
         u8 status = c.i8_get(&c, NULL);
@@ -784,6 +891,9 @@ static inline void storage_mem_write_priv(struct storage *me, i64 offset, u8 mar
         if (pad > 0) {
             p.array_put(&p, me->clean, pad, NULL);
         }
+        storage_vfs_block_write(me, curr, e);
+        if (e && *e)
+            THROW_S(e);
 
         remaining -= chunk;
         next_last = next;
@@ -893,6 +1003,235 @@ EXCEPTION:
 }
 /// End of memory-backed storage
 
+/// VFS storage
+
+#define STORAGE_VFS_LIMIT 16
+
+static struct {
+    char name[16];
+    struct flintdb_vfs vfs;
+} storage_vfs_registry[STORAGE_VFS_LIMIT];
+static int storage_vfs_count = 0;
+
+int flintdb_vfs_register(const char *name, const struct flintdb_vfs *vfs, char **e) {
+    if (strempty(name) || !vfs)
+        THROW(e, "VFS name and callbacks are required");
+    if (strlen(name) >= sizeof(storage_vfs_registry[0].name))
+        THROW(e, "VFS name too long: %s", name);
+    if (!vfs->open || !vfs->read_at || !vfs->write_at || !vfs->sync || !vfs->size || !vfs->close)
+        THROW(e, "VFS %s is missing callbacks", name);
+
+    int i = 0;
+    for (; i < storage_vfs_count; i++) {
+        if (strcasecmp(storage_vfs_registry[i].name, name) == 0)
+            break; // replace
+    }
+    if (i == STORAGE_VFS_LIMIT)
+        THROW(e, "Too many VFS registered: %d", STORAGE_VFS_LIMIT);
+    strncpy_safe(storage_vfs_registry[i].name, name, sizeof(storage_vfs_registry[i].name));
+    storage_vfs_registry[i].vfs = *vfs;
+    if (i == storage_vfs_count)
+        storage_vfs_count++;
+    return 0;
+
+EXCEPTION:
+    return -1;
+}
+
+const struct flintdb_vfs *storage_vfs_find(const char *type) {
+    if (strempty(type))
+        return NULL;
+    for (int i = 0; i < storage_vfs_count; i++) {
+        if (strcasecmp(storage_vfs_registry[i].name, type) == 0)
+            return &storage_vfs_registry[i].vfs;
+    }
+    return NULL;
+}
+
+static void storage_vfs_block_write(struct storage *me, i64 index, char **e) {
+    struct storage_vfs_file *vf = (struct storage_vfs_file *)me->priv;
+    if (!vf)
+        return; // memory storage
+
+    struct buffer b = {0};
+    storage_mem_buffer_get(me, index, &b);
+    if (!b.array)
+        THROW(e, "Cannot read block at offset %lld", index);
+    vf->vfs->write_at(vf->f, b.array, me->block_bytes, HEADER_BYTES + (me->block_bytes * index), e);
+
+EXCEPTION:
+    return;
+}
+
+// Writes the bytes of the header that changed since it was last written.
+static void storage_vfs_header_write(struct storage *me, char **e) {
+    struct storage_vfs_file *vf = (struct storage_vfs_file *)me->priv;
+    if (me->opts.mode != FLINTDB_RDWR)
+        return;
+
+    const char *h = me->h->array;
+    i32 lo = 0, hi = HEADER_BYTES;
+    while (lo < hi && h[lo] == vf->header[lo])
+        lo++;
+    while (hi > lo && h[hi - 1] == vf->header[hi - 1])
+        hi--;
+    if (lo == hi)
+        return;
+    vf->vfs->write_at(vf->f, h + lo, hi - lo, lo, e);
+    if (e && *e)
+        return;
+    memcpy(vf->header + lo, h + lo, hi - lo);
+}
+
+static i64 storage_vfs_bytes_get(struct storage *me) {
+    struct storage_vfs_file *vf = (struct storage_vfs_file *)me->priv;
+    return vf->vfs->size(vf->f, NULL);
+}
+
+static u8 storage_vfs_delete(struct storage *me, i64 offset, char **e) {
+    u8 ok = storage_mem_delete(me, offset, e);
+    if (e && *e)
+        return 0;
+    storage_vfs_header_write(me, e);
+    return ok;
+}
+
+static i64 storage_vfs_write(struct storage *me, struct buffer *in, char **e) {
+    i64 offset = storage_mem_write(me, in, e);
+    if (e && *e)
+        return offset;
+    storage_vfs_header_write(me, e);
+    return offset;
+}
+
+static i64 storage_vfs_write_at(struct storage *me, i64 offset, struct buffer *in, char **e) {
+    storage_mem_write_at(me, offset, in, e);
+    if (e && *e)
+        return offset;
+    storage_vfs_header_write(me, e);
+    return offset;
+}
+
+static u8 storage_vfs_flush(struct storage *me, char **e) {
+    struct storage_vfs_file *vf = (struct storage_vfs_file *)me->priv;
+    if (me->opts.mode != FLINTDB_RDWR)
+        return 1;
+    storage_commit(me, STORAGE_COMMIT_FORCE, e);
+    if (e && *e)
+        return 0;
+    storage_vfs_header_write(me, e);
+    if (e && *e)
+        return 0;
+    return vf->vfs->sync(vf->f, e) == 0;
+}
+
+static void storage_vfs_close(struct storage *me) {
+    assert(me != NULL);
+    struct storage_vfs_file *vf = (struct storage_vfs_file *)me->priv;
+    if (!vf)
+        return;
+
+    if (vf->f) {
+        char *e = NULL;
+        storage_vfs_flush(me, &e);
+        if (e && *e)
+            WARN("storage_vfs_close: %s", e);
+        vf->vfs->close(vf->f);
+    }
+    FREE(vf->header);
+    FREE(vf);
+    me->priv = NULL;
+    storage_mem_close(me);
+}
+
+static int storage_vfs_open(struct storage *me, struct storage_opts opts, const struct flintdb_vfs *vfs, char **e) {
+    if (!me)
+        return -1;
+
+    struct storage_vfs_file *vf = CALLOC(1, sizeof(struct storage_vfs_file));
+    if (!vf)
+        THROW(e, "Out of memory");
+    me->priv = vf;
+    vf->vfs = vfs;
+
+    me->block_bytes = (opts.compact <= 0) ? (BLOCK_HEADER_BYTES + opts.block_bytes) : (BLOCK_HEADER_BYTES + (opts.compact));
+    me->clean = CALLOC(1, me->block_bytes);
+    const i64 aligned = storage_dio_chunk_bytes((i64)me->block_bytes, (i64)((opts.increment <= 0) ? DEFAULT_INCREMENT_BYTES : opts.increment));
+    if (aligned <= 0)
+        THROW(e, "Invalid aligned chunk size calculation");
+    if (aligned > INT32_MAX)
+        THROW(e, "Aligned chunk size too large: %lld", aligned);
+    me->increment = (i32)aligned;
+    me->mmap_bytes = (i32)aligned;
+
+    memcpy(&me->opts, &opts, sizeof(struct storage_opts));
+
+    me->h = buffer_alloc(HEADER_BYTES);
+    vf->header = CALLOC(1, HEADER_BYTES);
+    if (!me->h || !vf->header)
+        THROW(e, "Cannot allocate header buffer");
+    memset(me->h->array, 0, HEADER_BYTES);
+
+    me->cache = hashmap_new(MAPPED_BYTEBUFFER_POOL_SIZE, hashmap_int_hash, hashmap_int_cmpr);
+    if (!me->cache)
+        THROW(e, "Cannot create cache");
+
+    me->close = storage_vfs_close;
+    me->count_get = storage_count_get;
+    me->bytes_get = storage_vfs_bytes_get;
+    me->read = storage_mem_read;
+    me->write = storage_vfs_write;
+    me->write_at = storage_vfs_write_at;
+    me->delete = storage_vfs_delete;
+    me->flush = storage_vfs_flush;
+    me->transaction = storage_transaction;
+    me->mmap = NULL; // Not supported for VFS storage
+    me->head = storage_head;
+
+    void *f = vfs->open(vfs->ctx, opts.file, opts.mode, e);
+    if (e && *e)
+        THROW_S(e);
+    if (!f)
+        THROW(e, "Cannot open file %s", opts.file);
+
+    i64 n = vfs->read_at(f, me->h->array, HEADER_BYTES, 0, e);
+    if (e && *e) {
+        vfs->close(f);
+        THROW_S(e);
+    }
+    if (n < (i64)HEADER_BYTES) {
+        if (opts.mode != FLINTDB_RDWR) {
+            vfs->close(f);
+            THROW(e, "File is empty: %s", opts.file);
+        }
+        // Fresh file: write the whole header so the blocks start after it
+        me->free = 0;
+        me->count = 0;
+        vf->f = f;
+        storage_commit(me, STORAGE_COMMIT_FORCE, e);
+        if (e && *e)
+            THROW_S(e);
+        vfs->write_at(f, me->h->array, HEADER_BYTES, 0, e);
+        if (e && *e)
+            THROW_S(e);
+    } else {
+        storage_header_load(me, e);
+        if (e && *e) {
+            vfs->close(f);
+            THROW_S(e);
+        }
+        vf->f = f; // after the header is loaded, so a failed open never writes it back
+    }
+    memcpy(vf->header, me->h->array, HEADER_BYTES);
+    return 0;
+
+EXCEPTION:
+    if (me->priv)
+        storage_vfs_close(me);
+    return -1;
+}
+/// End of VFS storage
+
 /// Direct I/O storage
 
 #ifdef STORAGE_DIO_USE_BUFFER_POOL
@@ -1858,7 +2197,7 @@ static struct buffer *storage_dio_read(struct storage *me, i64 offset, char **e)
     i32 length = blk->i32_get(blk, e);
     i64 next = blk->i64_get(blk, e);
 
-    if (next > NEXT_END && length > (me->opts.block_bytes)) {
+    if (next > NEXT_END && length > limit) {
         struct buffer *out = BUFFER_POOL_BORROW((u32)length);
         // copy only the first chunk (limit) from the first block
         out->array_put(out, blk->array_get(blk, limit, NULL), (u32)limit, NULL);
@@ -1994,7 +2333,7 @@ EXCEPTION:
 static inline void storage_dio_write_priv(struct storage *me, i64 offset, u8 mark, struct buffer *in, char **e) {
     assert(me != NULL);
 
-    const int BLOCK_DATA_BYTES = me->opts.block_bytes - BLOCK_HEADER_BYTES;
+    const int BLOCK_DATA_BYTES = me->block_bytes - BLOCK_HEADER_BYTES;
     i64 curr = offset;
     u8 curr_mark = mark;
     i32 remaining = in->remaining(in);
@@ -2425,30 +2764,9 @@ static int storage_dio_open(struct storage *me, struct storage_opts opts, char *
         if (e && *e)
             THROW_S(e);
     } else {
-        struct buffer *h = me->h;
-        struct buffer bb = {0};
-        h->slice(h, CUSTOM_HEADER_BYTES, COMMON_HEADER_BYTES, &bb, e);
+        storage_header_load(me, e);
         if (e && *e)
             THROW_S(e);
-
-        bb.i64_get(&bb, e);            // reserved
-        me->free = bb.i64_get(&bb, e); // The front of deleted blocks
-        bb.i64_get(&bb, e);            // The tail of deleted blocks => not used in mmap
-        bb.i16_get(&bb, e);            // version:i16
-        i32 inc = bb.i32_get(&bb, e);  // increment:i32
-        if (inc <= 0)
-            THROW(e, "Invalid increment size: %d, file:%s", inc, me->opts.file); // old version was (10MB)
-        if (inc != me->increment) {
-            me->increment = inc;
-            me->mmap_bytes = me->block_bytes * (me->increment / me->block_bytes);
-        }
-        bb.skip(&bb, R24LEN);             // reserved
-        i16 blksize = bb.i16_get(&bb, e); // BLOCK Data Max Size (exclude BLOCK Header)
-        if (blksize != opts.block_bytes) {
-            THROW(e, "Block size mismatch: header=%d, opts=%d", blksize, opts.block_bytes);
-        }
-        me->count = bb.i64_get(&bb, e);
-        assert(me->count > -1);
     }
 
 #ifdef STORAGE_DIO_USE_BUFFER_POOL
@@ -2484,6 +2802,10 @@ EXCEPTION:
  * @return int
  */
 int storage_open(struct storage *me, struct storage_opts opts, char **e) {
+    const struct flintdb_vfs *vfs = storage_vfs_find(opts.type);
+    if (vfs)
+        return storage_vfs_open(me, opts, vfs, e);
+
     if (strncasecmp(opts.type, TYPE_MEMORY, sizeof(TYPE_MEMORY) - 1) == 0)
         return storage_mem_open(me, opts, e);
 
@@ -2511,4 +2833,58 @@ int storage_transfer(struct storage *src, const char *file, char **e) {
     return 0;
 EXCEPTION:
     return 0;
-}
\ No newline at end of file
+}
+/**
+ * @brief Rebuild the free list and block count of a MMAP file from its blocks
+ *
+ * Both live in the header and are persisted lazily, so after a crash they may
+ * be behind the blocks. Every block that is not set is relinked into the free
+ * list in ascending order. Other storage types are left as they are.
+ *
+ * @param me
+ * @param e
+ * @return int 0 on success
+ */
+int storage_repair(struct storage *me, char **e) {
+    assert(me);
+    if (me->read != storage_mmap_read || me->opts.mode != FLINTDB_RDWR)
+        return 0;
+
+    struct stat st;
+    if (fstat(me->fd, &st) != 0)
+        THROW(e, "Cannot stat file %s: %s", me->opts.file, strerror(errno));
+    i64 blocks = (st.st_size > (i64)HEADER_BYTES) ? (st.st_size - HEADER_BYTES) / me->block_bytes : 0;
+
+    // Walk backwards so each empty block links to the next one after it; the
+    // last links past the end, where the next chunk is initialized on growth.
+    i64 count = 0;
+    i64 free = blocks;
+    for (i64 i = blocks - 1; i >= 0; i--) {
+        struct buffer p = {0};
+        struct buffer c = {0};
+        storage_mmap_buffer_get(me, i, &p);
+        p.slice(&p, 0, p.remaining(&p), &c, e);
+        if (e && *e)
+            THROW_S(e);
+        if (STATUS_SET == c.i8_get(&c, NULL)) {
+            count++;
+            continue;
+        }
+        p.i8_put(&p, STATUS_EMPTY, NULL);
+        p.i8_put(&p, MARK_AS_UNUSED, NULL);
+        p.i16_put(&p, 0, NULL);
+        p.i32_put(&p, 0, NULL);
+        p.i64_put(&p, free, NULL);
+        free = i;
+    }
+
+    me->free = free;
+    me->count = count;
+    storage_commit(me, STORAGE_COMMIT_FORCE, e);
+    if (e && *e)
+        THROW_S(e);
+    return 0;
+
+EXCEPTION:
+    return -1;
+}
@@ -17,6 +17,8 @@ struct storage_opts {
     int increment;
     char type[16];
     char compress[16];
+    int cache_limit; // mmap: most increment-sized chunks kept mapped, 0 for no limit
+    u8 mapped; // mmap, read-only: map the whole file once so reads take no locks or cache lookups
 };
 
 
@@ -66,7 +68,9 @@ struct storage {
 
 
 int storage_open(struct storage * s, struct storage_opts opts, char **e);
+const struct flintdb_vfs * storage_vfs_find(const char *type); // NULL if type names no registered VFS
 int storage_transfer(struct storage *src, const char *file, char **e); 
+int storage_repair(struct storage *me, char **e); // rebuilds the free list and block count of a MMAP file from its blocks
 
 // FlintDB on-disk file header size. Keep this stable for compatibility.
 // NOTE: This is NOT necessarily the OS VM page size (which can be 4KB or 16KB depending on platform).
@@ -46,6 +46,8 @@
 #ifndef DEFAULT_TABLE_CACHE_LIMIT
 #define DEFAULT_TABLE_CACHE_LIMIT (1024 * 1024 * 1)
 #endif
+#define COMPRESSED_BLOCK_BYTES 128 // data bytes of a storage block of a compressed table
+
 #define DEFAULT_TABLE_CACHE_MIN (1024 * 256) // Do not allow too small capacity (구조적 제약)
 
 
@@ -88,8 +90,11 @@ struct flintdb_table_priv {
     struct buffer *header;
     struct formatter formatter;
     struct hashmap *cache; // rowid -> row*
+    i64 cache_hits, cache_misses; // of read
     // Reusable raw row buffer pool (new generic buffer_pool)
     struct buffer_pool *raw_pool;
+    u8 compress; // block compression format of meta.compressor, 0 for none
+    u8 checksum; // row checksum of meta.checksum, 0 for none
     TABLE_LOCK_T lock; // table-level lock (os_unfair_lock on macOS, spinlock on Linux)
 };
 
@@ -287,6 +292,8 @@ struct find_context {
     enum order order;
     i8 index;
     struct flintdb_cursor_i64 *base_cursor; // B+Tree cursor
+    i64 scanned; // rowids taken from base_cursor
+    i64 deadline; // epoch milliseconds after which next fails, 0 for none
 };
 
 
@@ -349,6 +356,74 @@ static inline void table_return_raw_buffer(struct flintdb_table_priv *priv, stru
     }
 }
 
+// Packs an encoded row for storage: compressed, its length and then the
+// compressed bytes, followed by the checksum of the bytes before it.
+static struct buffer * table_pack(struct flintdb_table_priv *priv, struct buffer *raw, char **e) {
+    i32 n = raw->remaining(raw);
+    i32 bound = priv->compress ? compress_bound(priv->compress, n) : 0;
+    struct buffer *out = buffer_alloc((u32)(4 + (priv->compress ? bound : n) + 4));
+    if (!out) THROW(e, "Out of memory");
+    if (priv->compress) {
+        out->i32_put(out, n, NULL);
+        i32 z = stream_compress(priv->compress, raw->array + raw->position, n, out->array + out->position, bound, e);
+        if (e && *e) THROW_S(e);
+        if (z <= 0) THROW(e, "failed to compress row");
+        out->position += z;
+    } else {
+        out->array_put(out, raw->array + raw->position, n, NULL);
+    }
+    if (priv->checksum)
+        out->i32_put(out, (i32)checksum_of(priv->checksum, out->array, (i32)out->position), NULL);
+    out->flip(out);
+    return out;
+
+    EXCEPTION:
+    if (out) out->free(out);
+    return NULL;
+}
+
+// Unpacks the row at rowid read from storage: verifies its checksum and
+// decompresses it into a buffer of its own, freeing buf.
+static struct buffer * table_unpack(struct flintdb_table_priv *priv, struct buffer *buf, i64 rowid, char **e) {
+    struct buffer *out = NULL;
+    if (priv->checksum) {
+        if (buf->remaining(buf) < 4) THROW(e, "DB_ERR[%d] checksum mismatch at rowid %lld", DB_ERR_CHECKSUM_MISMATCH, rowid);
+        u32 pos = buf->position;
+        buf->position = buf->limit - 4;
+        u32 sum = (u32)buf->i32_get(buf, NULL);
+        buf->position = pos;
+        buf->limit -= 4;
+        if (sum != checksum_of(priv->checksum, buf->array + pos, buf->remaining(buf)))
+            THROW(e, "DB_ERR[%d] checksum mismatch at rowid %lld", DB_ERR_CHECKSUM_MISMATCH, rowid);
+    }
+    if (!priv->compress) return buf;
+
+    i32 n = buf->i32_get(buf, e);
+    if (e && *e) THROW_S(e);
+    if (n <= 0 || n > priv->row_bytes) THROW(e, "bad compressed row length: %d", n);
+    out = buffer_alloc((u32)n);
+    if (!out) THROW(e, "Out of memory");
+    i32 z = stream_decompress(priv->compress, buf->array + buf->position, buf->remaining(buf), out->array, n, e);
+    if (e && *e) THROW_S(e);
+    if (z != n) THROW(e, "compressed row is corrupted: %d of %d bytes", z, n);
+    buf->free(buf);
+    return out;
+
+    EXCEPTION:
+    if (out) out->free(out);
+    buf->free(buf);
+    return NULL;
+}
+
+// Copies the strings a row decoded from buf refers to, before buf is freed.
+static void table_row_own_strings(struct flintdb_row *r) {
+    for (int i = 0; i < r->length; i++) {
+        struct flintdb_variant *v = &r->array[i];
+        if (v->type == VARIANT_STRING && !v->value.b.owned && v->value.b.data)
+            flintdb_variant_string_set(v, v->value.b.data, v->value.b.length);
+    }
+}
+
 static i64 table_apply_in_tx(struct flintdb_table *me, struct flintdb_row *r, i8 upsert, char **e) {
     struct flintdb_table_priv* priv = (struct flintdb_table_priv*)me->priv;
     assert(r);
@@ -361,6 +436,7 @@ static i64 table_apply_in_tx(struct flintdb_table *me, struct flintdb_row *r, i8
     assert(storage);
 
     struct buffer *raw = NULL;
+    struct buffer *packed = NULL;
     raw = table_borrow_raw_buffer(priv);
     if (!raw) THROW(e, "Out of memory");
     if (m->columns.length != r->meta->columns.length) 
@@ -373,6 +449,10 @@ static i64 table_apply_in_tx(struct flintdb_table *me, struct flintdb_row *r, i8
     assert(raw->remaining(raw) <= priv->row_bytes);
     if (raw->remaining(raw) > priv->row_bytes) 
         THROW(e, "DB_ERR[%d] row bytes exceeded requested: %d, max: %d", DB_ERR_ROW_BYTES_EXCEEDED, raw->remaining(raw), priv->row_bytes);
+    if (priv->compress || priv->checksum) {
+        packed = table_pack(priv, raw, e);
+        if (!packed) THROW_S(e);
+    }
     struct sorter *primary = &priv->sorters.s[0];
     // DEBUG("before compare_get, row.id=%lld, primary=%p, tree=%p, r=%p", r->rowid, (void*)primary, (void*)&primary->tree, (void*)r);
     i64 rowid = r->rowid > NOT_FOUND 
@@ -382,7 +462,7 @@ static i64 table_apply_in_tx(struct flintdb_table *me, struct flintdb_row *r, i8
     if (e && *e) THROW(e, "failed to lookup row"); 
 
     if (NOT_FOUND == rowid) {
-        rowid = storage->write(storage, raw, e);
+        rowid = storage->write(storage, packed ? packed : raw, e);
         // DEBUG("table_apply storage->write => %lld", rowid);
         assert(rowid != NOT_FOUND);
         if (e && *e) THROW_S(e);
@@ -420,7 +500,7 @@ static i64 table_apply_in_tx(struct flintdb_table *me, struct flintdb_row *r, i8
         // and repopulate from storage after write_at so cache owns its own copy.
         priv->cache->remove(priv->cache, rowid);
         // DEBUG("update storage->write_at begin");
-        storage->write_at(storage, rowid, raw, e);
+        storage->write_at(storage, rowid, packed ? packed : raw, e);
         // DEBUG("update storage->write_at end");
         if (e && *e) THROW_S(e);
 
@@ -441,10 +521,12 @@ static i64 table_apply_in_tx(struct flintdb_table *me, struct flintdb_row *r, i8
 
     // raw buffer no longer needed after write
     if (raw) table_return_raw_buffer(priv, raw);
+    if (packed) packed->free(packed);
     return rowid;
 
     EXCEPTION:
     if (raw) table_return_raw_buffer(priv, raw);
+    if (packed) packed->free(packed);
     return NOT_FOUND;
 }
 
@@ -484,6 +566,7 @@ static i64 table_apply(struct flintdb_table *me, struct flintdb_row *r, i8 upser
 static i64 table_apply_at_in_tx(struct flintdb_table *me, i64 rowid, struct flintdb_row *r, char **e) {
     // Ensure 'raw' is always defined before any THROW can jump to EXCEPTION
     struct buffer *raw = NULL;
+    struct buffer *packed = NULL;
     
     struct flintdb_table_priv* priv = (struct flintdb_table_priv*)me->priv;
     if (rowid <= NOT_FOUND) THROW(e, "bad rowid: %lld", rowid);
@@ -508,6 +591,10 @@ static i64 table_apply_at_in_tx(struct flintdb_table *me, i64 rowid, struct flin
     assert(raw->remaining(raw) <= priv->row_bytes);
     if (raw->remaining(raw) > priv->row_bytes) 
         THROW(e, "DB_ERR[%d] row bytes exceeded requested: %d, max: %d", DB_ERR_ROW_BYTES_EXCEEDED, raw->remaining(raw), priv->row_bytes);
+    if (priv->compress || priv->checksum) {
+        packed = table_pack(priv, raw, e);
+        if (!packed) THROW_S(e);
+    }
 
     r->rowid = rowid;
 
@@ -520,7 +607,7 @@ static i64 table_apply_at_in_tx(struct flintdb_table *me, i64 rowid, struct flin
 
     priv->cache->remove(priv->cache, rowid);
     // priv->cache->put(priv->cache, (keytype)rowid, (valtype)r, row_cache_dealloc);
-    storage->write_at(storage, rowid, raw, e);
+    storage->write_at(storage, rowid, packed ? packed : raw, e);
     if (e && *e) THROW_S(e);  
 
     for(int i=1; i<priv->sorters.length; i++) {
@@ -531,10 +618,12 @@ static i64 table_apply_at_in_tx(struct flintdb_table *me, i64 rowid, struct flin
 
     // raw buffer no longer needed after write
     if (raw) table_return_raw_buffer(priv, raw);
+    if (packed) packed->free(packed);
     return rowid;
 
     EXCEPTION:
     if (raw) table_return_raw_buffer(priv, raw);
+    if (packed) packed->free(packed);
     return NOT_FOUND;
 }
 
@@ -653,6 +742,15 @@ static void find_close(struct flintdb_cursor_i64 *c) {
     FREE(c);
 }
 
+// find_expired reports whether the deadline of ctx has passed, looking at
+// the clock once every 256 rows scanned.
+static inline int find_expired(const struct find_context *ctx) {
+    if (ctx->deadline <= 0 || (ctx->scanned & 0xFF) != 0) return 0;
+    struct timespec now;
+    flintdb_timespec_utc(&now);
+    return (i64)now.tv_sec * 1000 + now.tv_nsec / 1000000 >= ctx->deadline;
+}
+
 static i64 find_next(struct flintdb_cursor_i64 *c, char **e) {
     if (!c) return NOT_FOUND;
     struct find_context *ctx = (struct find_context *)c->p;
@@ -667,6 +765,8 @@ static i64 find_next(struct flintdb_cursor_i64 *c, char **e) {
         i64 rowid = ctx->base_cursor->next(ctx->base_cursor, e);
         if (rowid == NOT_FOUND) return NOT_FOUND;
         if (e && *e) return NOT_FOUND;
+        ctx->scanned++;
+        if (find_expired(ctx)) THROW(e, "query timed out after %lld rows", (long long)ctx->scanned);
         
         // Apply both indexable and non-indexable filters
         const struct flintdb_row *r = table->read(table, rowid, e);
@@ -699,6 +799,8 @@ static i64 find_next(struct flintdb_cursor_i64 *c, char **e) {
         i64 rowid = ctx->base_cursor->next(ctx->base_cursor, e);
         if (rowid == NOT_FOUND) return NOT_FOUND;
         if (e && *e) return NOT_FOUND;
+        ctx->scanned++;
+        if (find_expired(ctx)) THROW(e, "query timed out after %lld rows", (long long)ctx->scanned);
         
         // Apply both indexable and non-indexable filters
         const struct flintdb_row *r = table->read(table, rowid, e);
@@ -728,6 +830,9 @@ static i64 find_next(struct flintdb_cursor_i64 *c, char **e) {
     }
     
     return NOT_FOUND;
+
+    EXCEPTION:
+    return NOT_FOUND;
 }
 
 // HOT_PATH
@@ -811,15 +916,12 @@ static struct flintdb_cursor_i64 * table_find(const struct flintdb_table *me,
     return c;
 }
 
-static int table_find_index_from_hint(const struct flintdb_table *me, 
+static int table_find_index_from_hint(const struct flintdb_meta *meta, 
     const struct flintdb_sql *q, 
     int *index, // output best index
     enum order *order // output preferred order
     ) {
     // User-specified index hint takes precedence
-    struct flintdb_table_priv* priv = (struct flintdb_table_priv*)me->priv;
-    assert(priv);
-
     char hint[SQL_OBJECT_STRING_LIMIT];
     const char *src = q->index;
     strncpy_safe(hint, src, sizeof(hint));
@@ -827,59 +929,100 @@ static int table_find_index_from_hint(const struct flintdb_table *me,
     char *save = NULL;
     char *name = strtok_r(hint, " ", &save);
     char *orderkw = strtok_r(NULL, " ", &save);
-    *index = meta_index_ordinal(&priv->meta, name);
+    *index = meta_index_ordinal(meta, name);
     if (orderkw && strncasecmp(orderkw, "DESC", 4) == 0) *order = DESC;
     return 1; // found hint
 }
 
-static struct flintdb_cursor_i64 * table_find_where(const struct flintdb_table *me, const char *where, char **e) {
-    struct flintdb_table_priv* priv = (struct flintdb_table_priv*)me->priv;
-    assert(priv);
-
+// Parses a find query "[USE INDEX(name [DESC])] [WHERE ...] [LIMIT ...]" on file into
+// the index, order, limit and compiled filter table_find takes; the caller frees *f.
+static int table_query_parse(const struct flintdb_meta *meta, const char *file, const char *where, 
+    int *index, enum order *ord, struct limit *l, struct filter **f, char **e) {
     // Build a SELECT statement like Java: "SELECT * FROM <file> USE INDEX(primary ASC|DESC) WHERE <where>"
     char sql[SQL_STRING_LIMIT];
     const char *w = where ? where : "";
     struct flintdb_sql *q = NULL;
-    struct filter *f  = NULL;
+    *f = NULL;
 
     if (!strempty(w))
-        snprintf(sql, sizeof(sql), "SELECT * FROM %s %s", priv->file, w); // snprintf(sql, sizeof(sql), "SELECT * FROM %s WHERE %s", priv->file, w);
+        snprintf(sql, sizeof(sql), "SELECT * FROM %s %s", file, w); // snprintf(sql, sizeof(sql), "SELECT * FROM %s WHERE %s", file, w);
     else 
-        snprintf(sql, sizeof(sql), "SELECT * FROM %s", priv->file);
+        snprintf(sql, sizeof(sql), "SELECT * FROM %s", file);
 
-    // printf("DEBUG: table_find_where: sql='%s'\n", sql);
+    // printf("DEBUG: table_query_parse: sql='%s'\n", sql);
 
     // Parse SQL to extract index hint, WHERE, and LIMIT
     q = flintdb_sql_parse(sql, e);
     if (q == NULL) THROW_S(e);
 
     // Determine index and order from index hint like Java
-    int index = PRIMARY_INDEX; // default to primary
-    enum order ord = ASC;
+    *index = PRIMARY_INDEX; // default to primary
+    *ord = ASC;
     if (!strempty(q->index)) 
-        table_find_index_from_hint(me, q, &index, &ord);
+        table_find_index_from_hint(meta, q, index, ord);
 
     // Compile WHERE with selected index so filter.c can derive indexable conditions and range
-    f = filter_compile(q->where, &priv->meta, e);
+    *f = filter_compile(q->where, (struct flintdb_meta *)meta, e);
     if (e && *e) THROW_S(e);
-    
-
-    // DEBUG("table_find_where: filter=%p, where='%s', index=%d", (void*)f, q.where, index);
 
     // Parse LIMIT
-    struct limit l = !strempty(q->limit) ? limit_parse(q->limit) : NOLIMIT;
+    *l = !strempty(q->limit) ? limit_parse(q->limit) : NOLIMIT;
+    flintdb_sql_free(q);
+    return 0;
+
+    EXCEPTION:
+    if (*f) filter_free(*f);
+    *f = NULL;
+    if (q) flintdb_sql_free(q);
+    return -1;
+}
+
+static struct flintdb_cursor_i64 * table_find_where(const struct flintdb_table *me, const char *where, char **e) {
+    struct flintdb_table_priv* priv = (struct flintdb_table_priv*)me->priv;
+    assert(priv);
+
+    int index;
+    enum order ord;
+    struct limit l;
+    struct filter *f = NULL;
+    if (table_query_parse(&priv->meta, priv->file, where, &index, &ord, &l, &f, e) != 0)
+        return NULL;
+
+    // DEBUG("table_find_where: filter=%p, where='%s', index=%d", (void*)f, where, index);
+
     struct flintdb_cursor_i64 *result = table_find(me, (i8)index, ord, l, f, e);
     
     // Clean up filter after use (table_find copies it via filter_split)
     if (f) filter_free(f);
-    if (q) flintdb_sql_free(q);
-    
     return result;
+}
+
+int flintdb_query_parse(const struct flintdb_meta *meta, const char *query, int *index, i8 *desc, i32 *offset, i32 *limit, char **e) {
+    struct filter *f = NULL;
+    struct filter_layers *layers = NULL;
+    if (!meta || meta->indexes.length <= 0) THROW(e, "query needs a meta with a primary key");
+
+    int ix;
+    enum order ord;
+    struct limit l;
+    if (table_query_parse(meta, "query", query, &ix, &ord, &l, &f, e) != 0) THROW_S(e);
+
+    // Split the filter for the chosen index as table_find does
+    layers = filter_split(f, (struct flintdb_meta *)meta, (struct flintdb_index *)&meta->indexes.a[ix], e);
+    if (e && *e) THROW_S(e);
+
+    if (index) *index = ix;
+    if (desc) *desc = ord == DESC;
+    if (offset) *offset = l.priv.offset;
+    if (limit) *limit = l.priv.limit;
+    if (layers) filter_layers_free(layers);
+    if (f) filter_free(f);
+    return 0;
 
     EXCEPTION:
+    if (layers) filter_layers_free(layers);
     if (f) filter_free(f);
-    if (q) flintdb_sql_free(q);
-    return NULL;
+    return -1;
 }
 
 static const struct flintdb_row * table_one(const struct flintdb_table *me, i8 index, u16 argc, const char **argv, char **e) {
@@ -941,12 +1084,17 @@ static int table_read_stream(struct flintdb_table *me, i64 rowid, struct flintdb
         if (e) *e = STRDUP("table_read_stream: NULL buffer");
         return -1;
     }
+    if (priv->compress || priv->checksum) {
+        buf = table_unpack(priv, buf, rowid, e);
+        if (!buf) return -1;
+    }
 
     struct formatter *f = &priv->formatter;
     if (f->decode(f, buf, dest, e) != 0) {
         buf->free(buf);
         return -1;
     }
+    if (priv->compress) table_row_own_strings(dest);
     buf->free(buf);
 
     dest->rowid = rowid;
@@ -964,18 +1112,25 @@ static const struct flintdb_row * table_read_unlocked(struct flintdb_table *me,
     assert(cache);
     struct flintdb_row *cached = (struct flintdb_row *)cache->get(cache, rowid);
     if (cached && cached != (struct flintdb_row*)HASHMAP_INVALID_VAL) {
+        priv->cache_hits++;
         return cached;
     }
+    priv->cache_misses++;
 
     struct buffer *buf = priv->storage->read(priv->storage, rowid, e);
     if (e && *e) THROW_S(e);
     if (!buf) THROW(e, "table_read_unlocked: storage read returned NULL buffer");
+    if (priv->compress || priv->checksum) {
+        buf = table_unpack(priv, buf, rowid, e);
+        if (!buf) THROW_S(e);
+    }
 
     struct flintdb_row *out = NULL;
     if (table_row_from_buffer(me, buf, &out, e) != 0) {
         if (buf) buf->free(buf);
         return NULL;
     }   
+    if (priv->compress) table_row_own_strings(out);
     buf->free(buf);
 
     out->rowid = rowid;
@@ -1249,6 +1404,7 @@ static int sorter_index_cmpr(void *o, i64 a, i64 b) {
         cmp = flintdb_variant_compare(v1, v2);
         if (cmp != 0) break;
     }
+    if (cmp == 0) cmp = a < b ? -1 : 1; // rows sharing a key, kept apart in rowid order
 
     if (e) 
         WARN("%s", e);
@@ -1316,14 +1472,13 @@ static int table_wal_refresh(const void *obj, i64 rowid) {
     return 0; // success
 }
 
-struct flintdb_table * flintdb_table_open(const char *file, enum flintdb_open_mode mode, const struct flintdb_meta *meta, char **e) {
+static struct flintdb_table * table_open(const char *file, enum flintdb_open_mode mode, const struct flintdb_meta *meta, u8 mapped, char **e) {
     struct flintdb_table *table = NULL;
     struct flintdb_table_priv *priv = NULL;
     struct wal *wal = NULL;
     struct flintdb_meta m = {0};
 
     if (!file) THROW(e, "file is NULL");
-    if (access(file, F_OK) != 0 && mode == FLINTDB_RDONLY) THROW(e, "file does not exist: %s", file);
 
     if (NULL == meta) {
         // read meta from <file>.desc
@@ -1360,10 +1515,39 @@ struct flintdb_table * flintdb_table_open(const char *file, enum flintdb_open_mo
                     THROW(e, "meta does not match existing: %s", desc);
             }
         }
+    } else {
+        // read-only with a meta: the table must exist and match it
+        char desc[PATH_MAX] = {0};
+        snprintf(desc, sizeof(desc), "%s%s", file, META_NAME_SUFFIX);
+        if (access(desc, F_OK) == 0) {
+            m = flintdb_meta_open(desc, e);
+            if (m.columns.length <= 0) THROW(e, "existing meta has no columns");
+            if (flintdb_meta_compare(&m, meta) != 0)
+                THROW(e, "meta does not match existing: %s", desc);
+        } else if (storage_vfs_find(meta->storage)) {
+            // a VFS table without a local desc: the caller's meta is its schema
+            if (meta->columns.length <= 0) THROW(e, "meta has no columns");
+            if (meta->indexes.length == 0) THROW(e, "meta has no indexes");
+            memcpy(&m, meta, sizeof(struct flintdb_meta));
+        } else {
+            THROW(e, "desc file does not exist: %s", desc);
+        }
     }
+    // the row cache size is not part of the schema; the caller's meta decides it
+    if (meta && meta->cache > 0)
+        m.cache = meta->cache;
+    // a WAL_SYNC the caller's meta sets overrides the table's for this open
+    if (meta && meta->wal_sync != WAL_SYNC_DEFAULT)
+        m.wal_sync = meta->wal_sync;
+    // files of a VFS table live in the VFS
+    if (mode == FLINTDB_RDONLY && !storage_vfs_find(m.storage) && access(file, F_OK) != 0)
+        THROW(e, "file does not exist: %s", file);
+
+    u8 compress = compress_format(m.compressor, e);
+    if (e && *e) THROW_S(e);
+    u8 checksum = checksum_format(m.checksum, e);
+    if (e && *e) THROW_S(e);
 
-    if (!strempty(m.compressor) && strncmp(TYPE_MMAP, m.compressor, sizeof(TYPE_MMAP)-1) != 0) THROW(e, "Compressor not supported yet: %s", m.compressor);
-    
     table = CALLOC(1, sizeof(struct flintdb_table));
     if (!table) THROW(e, "Failed to allocate memory for table_priv");
    
@@ -1376,6 +1560,8 @@ struct flintdb_table * flintdb_table_open(const char *file, enum flintdb_open_mo
     priv->meta.priv = NULL; // ensure no dangling pointer to local meta
     priv->row_bytes = row_bytes(&m);
     if (priv->row_bytes <= 0) THROW(e, "Failed to calculate row bytes");
+    priv->compress = compress;
+    priv->checksum = checksum;
     // IMPORTANT: bind formatter to the persistent meta stored in priv, not the local 'meta' copy
     if (formatter_init(FORMAT_BIN, &priv->meta, &priv->formatter, e) != 0) THROW_S(e);
 
@@ -1388,7 +1574,9 @@ struct flintdb_table * flintdb_table_open(const char *file, enum flintdb_open_mo
         // Honor meta.increment when provided; fallback to default if not set
         .increment = (m.increment > 0 ? (i32)m.increment : DEFAULT_STORAGE_INCREMENT),
         .mode = mode,
-        .compact = m.compact,
+        // compressed rows take as many small blocks as they need
+        .compact = (compress && m.compact <= 0) ? (priv->row_bytes < COMPRESSED_BLOCK_BYTES ? priv->row_bytes : COMPRESSED_BLOCK_BYTES) : m.compact,
+        .mapped = mapped,
     };
     strncpy_safe(opts.file, file, sizeof(opts.file));
     strncpy_safe(opts.type, strempty(m.storage) ? "" : m.storage, sizeof(opts.type));
@@ -1468,6 +1656,10 @@ struct flintdb_table * flintdb_table_open(const char *file, enum flintdb_open_mo
         }
     }
 
+    // After a crash, every file has now been repaired: end the recovery
+    wal->recover(wal, e);
+    if (e && *e) THROW_S(e);
+
     //  
     table->rows = table_rows;
     table->bytes = table_bytes;
@@ -1499,3 +1691,44 @@ struct flintdb_table * flintdb_table_open(const char *file, enum flintdb_open_mo
         table_close(table);
     return NULL;
 }
+
+struct flintdb_table * flintdb_table_open(const char *file, enum flintdb_open_mode mode, const struct flintdb_meta *meta, char **e) {
+    return table_open(file, mode, meta, 0, e);
+}
+
+struct flintdb_table * flintdb_table_open_mapped(const char *file, const struct flintdb_meta *meta, char **e) {
+    return table_open(file, FLINTDB_RDONLY, meta, 1, e);
+}
+
+i64 flintdb_cursor_scanned(const struct flintdb_cursor_i64 *c) {
+    if (!c || c->next != find_next) return 0;
+    const struct find_context *ctx = (const struct find_context *)c->p;
+    return ctx ? ctx->scanned : 0;
+}
+
+void flintdb_cursor_deadline(struct flintdb_cursor_i64 *c, i64 deadline) {
+    if (!c || c->next != find_next || !c->p) return;
+    ((struct find_context *)c->p)->deadline = deadline;
+}
+
+void flintdb_table_cache_stats(const struct flintdb_table *table, i64 *hits, i64 *misses) {
+    const struct flintdb_table_priv *priv = table ? (const struct flintdb_table_priv *)table->priv : NULL;
+    if (hits) *hits = priv ? priv->cache_hits : 0;
+    if (misses) *misses = priv ? priv->cache_misses : 0;
+}
+
+i64 flintdb_table_lookup(struct flintdb_table *table, int index, const struct flintdb_row *key, char **e) {
+    struct flintdb_table_priv *priv = table ? (struct flintdb_table_priv *)table->priv : NULL;
+    if (!priv || !key) THROW(e, "lookup needs a table and a key");
+    if (index < 0 || index >= priv->sorters.length) THROW(e, "no index %d", index);
+
+    struct sorter *s = &priv->sorters.s[index];
+    TABLE_LOCK(&priv->lock);
+    i64 rowid = s->tree.compare_get(&s->tree, s, key, row_compare_get, e);
+    TABLE_UNLOCK(&priv->lock);
+    if (e && *e) THROW_S(e);
+    return rowid < 0 ? NOT_FOUND : rowid;
+
+    EXCEPTION:
+    return NOT_FOUND;
+}
@@ -138,6 +138,11 @@ int main(int argc, char *argv[]) {
         {"l_quantity != NULL", {0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 11, -1}},
         {"l_orderkey = 1010 AND l_quantity = NULL", {10, -1}},
         {"l_orderkey = 1011 AND l_comment = NULL", {11, -1}},
+        {"l_quantity IS NULL", {10, -1}},
+        {"l_comment is null", {10, 11, -1}},
+        {"l_quantity IS NOT NULL", {0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 11, -1}},
+        {"l_orderkey = 1011 AND l_comment IS NULL", {11, -1}},
+        {"(l_quantity IS NULL OR l_orderkey = 1001) AND l_comment IS NOT NULL", {1, -1}},
     };
     int n = sizeof(testcases) / sizeof(testcases[0]);
 
@@ -215,6 +220,9 @@ int main(int argc, char *argv[]) {
         {"l_orderkey = 1001 AND l_comment = 'test'", NULL, "indexable", "non-indexable"},
         {"l_orderkey >= 1000 AND l_quantity < 5 AND l_comment like '%test%'", NULL, "indexable", "non-indexable"},
         {"l_orderkey = 1001 OR l_comment = 'test'", NULL, NULL, "non-indexable"},
+        {"l_quantity < 5", NULL, NULL, "non-indexable"},  // not a prefix of the key
+        {"l_orderkey > 1001 AND l_quantity < 5", NULL, "indexable", "non-indexable"},  // range ends the prefix
+        {"l_orderkey != 1001", NULL, NULL, "non-indexable"},  // not a contiguous run of keys
         
         // IX_QUANTITY index (l_quantity)
         {"l_quantity < 5", "IX_QUANTITY", "indexable", NULL},
@@ -330,8 +338,8 @@ int main(int argc, char *argv[]) {
         {"l_orderkey BETWEEN 1 AND 5", "BETWEEN operator is not supported"},     // BETWEEN not supported
         {"l_orderkey IN (1, 2, 3)", "IN operator is not supported"},             // IN not supported
         {"NOT l_orderkey = 1", "unknown column"},                                // NOT parsed as column name
-        {"l_orderkey IS NULL", "IS operator is not supported"},                  // IS not supported
-        {"l_orderkey IS NOT NULL", "IS operator is not supported"},              // IS NOT not supported
+        {"l_orderkey IS 1", "IS must be followed by NULL or NOT NULL"},         // IS takes NULL only
+        {"l_orderkey IS NOT 1", "IS must be followed by NULL or NOT NULL"},     // IS NOT takes NULL only
         
         // Invalid operators - detected as invalid operators now
         {"l_orderkey == 1", "invalid value format"},                // == is parsed as value
@@ -83,8 +83,14 @@ typedef void* HANDLE;
  * 
  * Crash Recovery:
  * ---------------
- * WAL replay reconstructs committed state by replaying logged operations.
- * Uncommitted transactions are implicitly rolled back (not replayed).
+ * Before a page is first updated or deleted in a transaction, its old image is
+ * logged as OP_UNDO and written out ahead of the origin change. The header is
+ * marked in use while the WAL is open for writing; if it is still marked on
+ * the next open, the process died. wal_wrap then rebuilds each storage's free
+ * list and block count, which are persisted lazily, and undoes the pages of the
+ * unfinished transaction in reverse log order; recover() logs its ROLLBACK once
+ * every file of the table is open. This covers a killed process, not power loss:
+ * origin pages are mmapped and may reach disk before the WAL is synced.
  * ================================================================================
  */
 
@@ -156,6 +162,12 @@ struct wal_impl {
     struct hashmap *storages;  // Map: storage_id -> wal_storage instance
                                // Manages multiple wrapped storage files (.flintdb, .i.*)
                                // Each storage tracks its own transaction state
+
+    // === Crash Recovery ===
+    u8 in_use;                 // Header flag: set while open for writing, cleared by close
+    int recovering;            // 1 from an open after a crash until recover() finishes
+    struct list *undo;         // wal_undo records of unfinished transactions, in log order
+    struct list *unfinished;   // Ids of the transactions the crash interrupted
     
     // Platform-specific I/O context
 #if defined(__linux__) && defined(HAVE_LIBURING)
@@ -452,6 +464,20 @@ static void dirty_page_free(struct dirty_page *page) {
     FREE(page);
 }
 
+// Logged change of an unfinished transaction, read back by crash recovery.
+struct wal_undo {
+    u8 operation;               // OP_WRITE (delete the page) or OP_UNDO (restore data[])
+    i64 transaction;            // Transaction ID
+    i32 file_id;                // Identifier of the storage
+    i64 offset;                 // Page offset in storage file
+    i32 data_size;              // Size of the page image (0 for OP_WRITE or an empty page)
+    char data[];
+};
+
+static void wal_undo_free(valtype v) {
+    FREE((void *)(uintptr_t)v);
+}
+
 /**
  * ================================================================================
  * WAL Storage Wrapper
@@ -694,154 +720,232 @@ static i64 wal_rollback(struct wal *me, i64 id, char **e) {
     return 0;
 }
 
+static ssize_t wal_pread(struct wal_impl *impl, void *buf, size_t len, i64 offset) {
+#ifdef _WIN32
+    return wal_pread_all_fd(impl->fd, impl->fh, buf, len, (off_t)offset);
+#else
+    return wal_pread_all_fd(impl->fd, (HANDLE)0, buf, len, (off_t)offset);
+#endif
+}
+
+static char* wal_decompress_data(const char *data, i32 size, i32 original_size) {
+    char *out = MALLOC(original_size > 0 ? original_size : 1);
+    if (!out) return NULL;
+
+    z_stream stream = {0};
+    stream.next_in = (Bytef*)data;
+    stream.avail_in = size;
+    stream.next_out = (Bytef*)out;
+    stream.avail_out = original_size;
+
+    // Raw deflate, as written by wal_compress_data
+    if (inflateInit2(&stream, -15) != Z_OK) {
+        FREE(out);
+        return NULL;
+    }
+    int ok = inflate(&stream, Z_FINISH);
+    inflateEnd(&stream);
+    if (ok != Z_STREAM_END || stream.total_out != (uLong)original_size) {
+        FREE(out);
+        return NULL;
+    }
+    return out;
+}
+
 /**
- * WAL RECOVERY: Replay committed transactions from WAL
- * 
- * Recovery Process:
- * 1. Scan WAL from last checkpoint to committed offset
- * 2. Track transaction states (COMMIT/ROLLBACK)
- * 3. Only replay records from COMMITTED transactions
- * 4. Replay operations to each storage by identifier
- * 
- * This ensures uncommitted transactions are implicitly rolled back.
+ * Reads the record at position. If data is given, the page image it carries
+ * is returned there (NULL for none; caller frees).
+ * Returns the record size, or 0 at the end of the log or at a record cut
+ * short by a crash.
  */
-static i64 wal_recover(struct wal *me, char **e) {
-    struct wal_impl *impl = me->impl;
-    struct hashmap *tx_committed = NULL; // txId -> 1 (committed)
-    i64 records_replayed = 0;
-    
-    if (!impl || impl->fd <= 0) {
-        THROW(e, "WAL not initialized");
+static i32 wal_record_read(struct wal_impl *impl, i64 position, i64 end, u8 *operation, i64 *tx_id, i32 *file_id, i64 *page_offset, char **data, i32 *data_size) {
+    char header[28];
+    if (position + (i64)sizeof(header) > end) return 0;
+    if (wal_pread(impl, header, sizeof(header), position) != (ssize_t)sizeof(header)) return 0;
+
+    *operation = (u8)header[0];
+    memcpy(tx_id, header + 1, sizeof(i64));
+    memcpy(file_id, header + 11, sizeof(i32));
+    memcpy(page_offset, header + 15, sizeof(i64));
+    u8 flags = (u8)header[23];
+    i32 original_size = 0;
+    memcpy(&original_size, header + 24, sizeof(i32));
+    if (original_size < 0) return 0;
+
+    i32 record_size = sizeof(header);
+    i32 stored = 0;
+    i64 at = position + sizeof(header);
+    if ((flags & FLAG_COMPRESSED) != 0) {
+        if (at + (i64)sizeof(i32) > end) return 0;
+        if (wal_pread(impl, &stored, sizeof(i32), at) != (ssize_t)sizeof(i32)) return 0;
+        if (stored < 0) return 0;
+        at += sizeof(i32);
+        record_size += sizeof(i32) + stored;
+    } else if ((flags & FLAG_METADATA_ONLY) == 0 && original_size > 0) {
+        stored = original_size;
+        record_size += stored;
+    }
+    if (position + record_size > end) return 0;
+
+    if (data) {
+        *data = NULL;
+        *data_size = 0;
+        if (stored > 0) {
+            char *raw = MALLOC(stored);
+            if (!raw) return 0;
+            if (wal_pread(impl, raw, (size_t)stored, at) != (ssize_t)stored) {
+                FREE(raw);
+                return 0;
+            }
+            if ((flags & FLAG_COMPRESSED) != 0) {
+                char *page = wal_decompress_data(raw, stored, original_size);
+                FREE(raw);
+                if (!page) return 0;
+                raw = page;
+            }
+            *data = raw;
+            *data_size = original_size;
+        }
     }
-    
-    // Get file size
+    return record_size;
+}
+
+/**
+ * WAL RECOVERY, phase 1: after a crash, reads the page allocations (OP_WRITE)
+ * and page images (OP_UNDO) of the transactions that neither committed nor
+ * rolled back into impl->undo. A record cut short at the end of the log is
+ * truncated away.
+ */
+static void wal_recovery_scan(struct wal_impl *impl, char **e) {
     struct stat st;
-    if (fstat(impl->fd, &st) < 0) {
-        THROW(e, "Failed to stat WAL file: %s", strerror(errno));
+    if (fstat(impl->fd, &st) < 0) THROW(e, "Failed to stat WAL file: %s", strerror(errno));
+    i64 end = st.st_size;
+    // The checkpoint offset is only meaningful while the log has not been truncated below it
+    i64 start = (impl->checkpoint_offset >= HEADER_SIZE && impl->checkpoint_offset <= end) ? impl->checkpoint_offset : HEADER_SIZE;
+
+    impl->undo = arraylist_new(64);
+    impl->unfinished = arraylist_new(4);
+    if (!impl->undo || !impl->unfinished) THROW(e, "Out of memory");
+
+    u8 operation;
+    i64 tx_id, page_offset;
+    i32 file_id, size;
+    i64 position = start;
+    for (; position < end; position += size) {
+        char *data = NULL;
+        i32 data_size = 0;
+        size = wal_record_read(impl, position, end, &operation, &tx_id, &file_id, &page_offset, &data, &data_size);
+        if (size <= 0) break;
+        // Ids were only persisted on checkpoint/close; continue after the last one used
+        if (tx_id > impl->transaction_id) impl->transaction_id = tx_id;
+
+        if (operation == OP_COMMIT || operation == OP_ROLLBACK) {
+            // Transactions hold the table lock, so the records of an ended one are the latest
+            for (int i = impl->undo->length - 1; i >= 0; i--) {
+                struct wal_undo *u = (struct wal_undo *)(uintptr_t)impl->undo->get(impl->undo, i, NULL);
+                if (u->transaction == tx_id) impl->undo->remove(impl->undo, i);
+            }
+        } else if ((operation == OP_WRITE || operation == OP_UNDO) && tx_id > 0) {
+            struct wal_undo *u = CALLOC(1, sizeof(struct wal_undo) + (size_t)data_size);
+            if (!u) {
+                if (data) FREE(data);
+                THROW(e, "Out of memory");
+            }
+            u->operation = operation;
+            u->transaction = tx_id;
+            u->file_id = file_id;
+            u->offset = page_offset;
+            u->data_size = data_size;
+            if (data) memcpy(u->data, data, (size_t)data_size);
+            impl->undo->add(impl->undo, (valtype)(uintptr_t)u, wal_undo_free, NULL);
+        }
+        if (data) FREE(data);
     }
-    i64 file_size = st.st_size;
-    
-    // No records to replay
-    if (file_size <= HEADER_SIZE) {
-        return 0;
+    if (position < end) {
+        WARN("WAL recovery: %s: dropping %lld bytes of an incomplete record", impl->path, end - position);
+        if (ftruncate(impl->fd, position) != 0) THROW(e, "Failed to truncate WAL file: %s", strerror(errno));
     }
-    
-    // Determine scan range: from last checkpoint to committed offset
-    i64 scan_start = impl->checkpoint_offset > 0 ? impl->checkpoint_offset : HEADER_SIZE;
-    i64 scan_end = impl->committed_offset > 0 
-                   ? (impl->committed_offset < file_size ? impl->committed_offset : file_size)
-                   : file_size;
-    
-    if (scan_start >= scan_end) {
-        return 0; // Nothing to replay
+    impl->current_position = position;
+
+    for (int i = 0; i < impl->undo->length; i++) {
+        struct wal_undo *u = (struct wal_undo *)(uintptr_t)impl->undo->get(impl->undo, i, NULL);
+        int n = impl->unfinished->length;
+        if (n == 0 || (i64)impl->unfinished->get(impl->unfinished, n - 1, NULL) != u->transaction)
+            impl->unfinished->add(impl->unfinished, (valtype)u->transaction, NULL, NULL);
     }
+    if (impl->unfinished->length > 0)
+        WARN("WAL recovery: %s: rolling back %d unfinished transaction(s)", impl->path, impl->unfinished->length);
 
-    WARN("WAL Recovery: Scanning from offset %lld to %lld", scan_start, scan_end);
-    
-    // Track transaction states
-    tx_committed = hashmap_new(256, hashmap_int_hash, hashmap_int_cmpr);
-    if (!tx_committed) THROW(e, "Out of memory");
-    
-    i64 position = scan_start;
-    
-    // Phase 1: Scan records and track transaction states
-    while (position < scan_end) {
-        // Read record header (operation + transaction_id + checksum + fileId + offset + flags + dataSize)
-        char header_buf[32];
-#ifdef _WIN32
-        ssize_t bytes_read = wal_pread_all_fd(impl->fd, impl->fh, header_buf, sizeof(header_buf), position);
-#else
-        ssize_t bytes_read = wal_pread_all_fd(impl->fd, (HANDLE)0, header_buf, sizeof(header_buf), position);
-#endif
-        if (bytes_read < 26) break; // Minimum header size
-        
-        u8 operation = *(u8*)(header_buf + 0);
-        i64 tx_id = *(i64*)(header_buf + 1);
-        // i16 checksum = *(i16*)(header_buf + 9); // Skip for now
-        // i32 file_id = *(i32*)(header_buf + 11); // Not needed in phase 1
-        // i64 page_offset = *(i64*)(header_buf + 15); // Not needed in phase 1
-        u8 flags = *(u8*)(header_buf + 23);
-        i32 data_size = *(i32*)(header_buf + 24);
-        
-        // Calculate record size
-        i32 record_size = 28; // Header
-        if (!(flags & FLAG_METADATA_ONLY) && data_size > 0) {
-            record_size += data_size;
-        }
-        
-        // Track transaction state
-        if (operation == OP_COMMIT) {
-            tx_committed->put(tx_committed, (keytype)tx_id, (valtype)1, NULL);
-        } else if (operation == OP_ROLLBACK) {
-            tx_committed->put(tx_committed, (keytype)tx_id, (valtype)0, NULL);
+EXCEPTION:
+    return;
+}
+
+/**
+ * WAL RECOVERY, phase 2: runs in wal_wrap for each storage before anything
+ * reads it. Its free list and block count are rebuilt from the blocks, then the
+ * changes of unfinished transactions are undone newest first: pages they
+ * allocated are deleted and the images of pages they updated or deleted are
+ * written back.
+ */
+static void wal_storage_recover(struct wal_storage *ws, char **e) {
+    struct wal_impl *impl = ws->logger;
+    struct storage *origin = ws->origin;
+    int undone = 0;
+
+    if (storage_repair(origin, e) != 0) THROW_S(e);
+    for (int i = impl->undo->length - 1; i >= 0; i--) {
+        struct wal_undo *u = (struct wal_undo *)(uintptr_t)impl->undo->get(impl->undo, i, NULL);
+        if (u->file_id != ws->identifier) continue;
+        if (u->operation == OP_WRITE) {
+            origin->delete(origin, u->offset, e);
+        } else {
+            struct buffer buf;
+            buffer_wrap(u->data, (u32)u->data_size, &buf);
+            buf.limit = (u32)u->data_size;
+            buf.position = 0;
+            origin->write_at(origin, u->offset, &buf, e);
         }
-        
-        position += record_size;
+        if (e && *e) THROW_S(e);
+        undone++;
     }
-    
-    // Phase 2: Replay from checkpoint again, now knowing which transactions committed
-    position = scan_start;
-    
-    while (position < scan_end) {
-        char header_buf[32];
-#ifdef _WIN32
-        ssize_t bytes_read = wal_pread_all_fd(impl->fd, impl->fh, header_buf, sizeof(header_buf), position);
-#else
-        ssize_t bytes_read = wal_pread_all_fd(impl->fd, (HANDLE)0, header_buf, sizeof(header_buf), position);
-#endif
-        if (bytes_read < 26) break;
-        
-        u8 operation = *(u8*)(header_buf + 0);
-        i64 tx_id = *(i64*)(header_buf + 1);
-        i32 file_id = *(i32*)(header_buf + 11);
-        // i64 page_offset = *(i64*)(header_buf + 15); // Not used currently
-        u8 flags = *(u8*)(header_buf + 23);
-        i32 data_size = *(i32*)(header_buf + 24);
-        
-        i32 record_size = 28;
-        if (!(flags & FLAG_METADATA_ONLY) && data_size > 0) {
-            record_size += data_size;
-        }
-        
-        // Skip COMMIT/ROLLBACK/CHECKPOINT operations (they don't need replay)
-        if (operation == OP_COMMIT || operation == OP_ROLLBACK || operation == OP_CHECKPOINT) {
-            position += record_size;
-            continue;
-        }
-        
-        // Only replay if transaction was committed
-        valtype is_committed = tx_committed->get(tx_committed, (keytype)tx_id);
-        if (is_committed == (valtype)1) {
-            // Find storage by identifier and replay
-            struct map_iterator it = {0};
-            if (impl->storages) {
-                while (impl->storages->iterate(impl->storages, &it)) {
-                    struct wal_storage *ws = (struct wal_storage*)(uintptr_t)it.val;
-                    if (ws && ws->identifier == file_id) {
-                        // Replay this operation to the storage
-                        // NOTE: With immediate-write strategy, data is already 
-                        // in origin files. Recovery just verifies consistency.
-                        records_replayed++;
-                        break;
-                    }
-                }
-            }
-        }
-        
-        position += record_size;
+    // Restoring freed pages relinks the free list only at its head
+    if (undone > 0 && storage_repair(origin, e) != 0) THROW_S(e);
+
+EXCEPTION:
+    return;
+}
+
+/**
+ * WAL RECOVERY, phase 3: once every storage of the table is wrapped (and so
+ * recovered), logs a ROLLBACK for each unfinished transaction so that the
+ * next open does not undo them again.
+ * Returns the number of transactions rolled back.
+ */
+static i64 wal_recover(struct wal *me, char **e) {
+    struct wal_impl *impl = me->impl;
+    i64 n = 0;
+
+    if (!impl || impl->fd <= 0) {
+        THROW(e, "WAL not initialized");
     }
-    
-    // Cleanup
-    if (tx_committed) {
-        tx_committed->free(tx_committed);
+    if (!impl->recovering) return 0;
+
+    n = impl->unfinished ? impl->unfinished->length : 0;
+    for (int i = 0; i < n; i++) {
+        i64 id = (i64)impl->unfinished->get(impl->unfinished, i, NULL);
+        wal_log(impl, OP_ROLLBACK, id, 0, 0, NULL, 0, 0);
+        impl->total_count++;
     }
-    
-    return records_replayed;
-    
+    wal_flush_batch(impl, 1);
+
+    if (impl->undo) impl->undo->free(impl->undo);
+    if (impl->unfinished) impl->unfinished->free(impl->unfinished);
+    impl->undo = NULL;
+    impl->unfinished = NULL;
+    impl->recovering = 0;
+    return n;
+
 EXCEPTION:
-    if (tx_committed) {
-        tx_committed->free(tx_committed);
-    }
     return -1;
 }
 
@@ -862,6 +966,7 @@ static void wal_flush_header(struct wal_impl *impl) {
     h.i64_put(&h, impl->checkpoint_offset, NULL); // Checkpoint offset
     h.i32_put(&h, impl->total_count, NULL); // Total transactions
     h.i32_put(&h, impl->processed_count, NULL); // Processed transactions
+    h.i8_put(&h, impl->in_use, NULL);   // Open for writing; still set on open after a crash
 
     // Best-effort; header flush happens on checkpoint/close.
 #ifdef _WIN32
@@ -878,11 +983,13 @@ static void wal_flush_batch(struct wal_impl *impl, int do_sync) {
     ssize_t written;
 #ifdef __linux__
     written = wal_pwrite_linux_io_uring(impl, impl->batch_buffer, (size_t)impl->batch_size, (off_t)impl->current_position);
+#ifdef HAVE_LIBURING
     // Now submit all accumulated SQEs in one syscall
     if (impl->io_uring_enabled) {
         int ret = io_uring_submit(&impl->ring);
         if (ret > 0) impl->pending_ops += ret;
     }
+#endif
 #elif defined(__APPLE__)
     written = wal_pwrite_macos_dispatch(impl, impl->batch_buffer, (size_t)impl->batch_size, (off_t)impl->current_position);
 #else
@@ -965,11 +1072,13 @@ static void wal_log(struct wal_impl *impl, u8 operation, i64 transaction_id, i32
         // Platform-optimized direct write
 #ifdef __linux__
         wal_pwritev_linux_io_uring(impl, iov, iovcnt, (off_t)impl->current_position);
+#ifdef HAVE_LIBURING
         // Submit accumulated SQEs for direct writes too
         if (impl->io_uring_enabled) {
             int ret = io_uring_submit(&impl->ring);
             if (ret > 0) impl->pending_ops += ret;
         }
+#endif
 #elif defined(__APPLE__)
         wal_pwritev_macos_dispatch(impl, iov, iovcnt, (off_t)impl->current_position);
 #else
@@ -1080,8 +1189,10 @@ static i64 wal_checkpoint(struct wal *me, char **e) {
             THROW(e, "Failed to truncate WAL file: %s", strerror(errno));
         }
         impl->current_position = HEADER_SIZE;
+        impl->checkpoint_offset = HEADER_SIZE;
         impl->batch_size = 0;
         impl->batch_count = 0;
+        wal_flush_header(impl);
     }
     return 0;
     
@@ -1154,6 +1265,16 @@ static i64 wal_storage_bytes_get(struct storage *me) {
     return ws->origin->bytes_get(ws->origin);
 }
 
+// Logs the image of a page before its first change in the transaction and
+// writes it out, so that it is in the log before the origin page changes.
+static void wal_log_undo(struct wal_storage *ws, i64 offset, const char *data, i32 size) {
+    wal_log(ws->logger, OP_UNDO, ws->transaction, ws->identifier, offset, data, size, 0);
+    wal_flush_batch(ws->logger, 0);
+#ifdef __linux__
+    wal_io_wait_pending(ws->logger);
+#endif
+}
+
 /**
  * READ operation: Direct passthrough to origin
  * No transaction tracking needed for reads
@@ -1230,6 +1351,7 @@ static i64 wal_storage_write_at(struct storage *me, i64 offset, struct buffer *i
                 
                 // Store backup in old_pages map
                 ws->old_pages->put(ws->old_pages, (keytype)offset, (valtype)(uintptr_t)backup, NULL);
+                if (ws->logger) wal_log_undo(ws, offset, backup->data, size);
             }
         }
         // If already backed up, skip (we only need the FIRST version for rollback)
@@ -1290,6 +1412,7 @@ static u8 wal_storage_delete(struct storage *me, i64 offset, char **e) {
             
             // Store backup in deleted_page_backups map
             ws->deleted_page_backups->put(ws->deleted_page_backups, (keytype)offset, (valtype)(uintptr_t)backup, NULL);
+            if (ws->logger) wal_log_undo(ws, offset, backup->data, size);
         }
     }
     
@@ -1541,6 +1664,10 @@ struct storage* wal_wrap(struct wal* wal, struct storage_opts* opts, int (*refre
     if (impl->storages) {
         struct wal_storage *ws = (struct wal_storage*)wrapped;
         ws->identifier = impl->storages->count_get(impl->storages);
+        if (impl->recovering) {
+            wal_storage_recover(ws, e);
+            if (e && *e) THROW_S(e);
+        }
         impl->storages->put(impl->storages, (keytype)opts->file, (valtype)(uintptr_t)wrapped, NULL);
     }
 
@@ -1572,6 +1699,8 @@ static void wal_close(struct wal *me) {
         struct wal_impl *impl = me->impl;
         // Flush any pending batch before closing
         wal_flush_batch(impl, 1);
+        // An unfinished recovery (the table failed to open) runs again on the next open
+        impl->in_use = impl->recovering;
         wal_flush_header(impl);
         wal_do_sync(impl);
         
@@ -1602,6 +1731,8 @@ static void wal_close(struct wal *me) {
         if (impl->batch_buffer) {
             FREE(impl->batch_buffer);
         }
+        if (impl->undo) impl->undo->free(impl->undo);
+        if (impl->unfinished) impl->unfinished->free(impl->unfinished);
         FREE(me->impl);
     }
     FREE(me);
@@ -1625,7 +1756,7 @@ struct wal* wal_open(const char *path, const struct flintdb_meta *meta, char** e
 
     // Set mode: TRUNCATE (default) or LOG
     // Compression is always enabled for better I/O performance
-    impl->auto_truncate = (strcmp(meta->wal, WAL_OPT_TRUNCATE) == 0) ? 1 : 0;
+    impl->auto_truncate = (strcasecmp(meta->wal, WAL_OPT_TRUNCATE) == 0) ? 1 : 0;
     impl->transaction_id = 0;
     impl->transaction_count = 0;
     impl->committed_offset = 0;
@@ -1707,6 +1838,7 @@ struct wal* wal_open(const char *path, const struct flintdb_meta *meta, char** e
             impl->checkpoint_offset = h.i64_get(&h, NULL);
             impl->total_count = h.i32_get(&h, NULL);
             impl->processed_count = h.i32_get(&h, NULL);
+            impl->recovering = h.i8_get(&h, NULL) ? 1 : 0;
             impl->current_position = st.st_size;
         } else {
             impl->current_position = HEADER_SIZE;
@@ -1715,6 +1847,19 @@ struct wal* wal_open(const char *path, const struct flintdb_meta *meta, char** e
         impl->current_position = HEADER_SIZE;
     }
 
+    // Still marked in use: the last writer did not close the WAL
+    if (impl->recovering) {
+        wal_recovery_scan(impl, e);
+        if (e && *e) {
+            close(impl->fd);
+            impl->fd = -1;
+            FREE(impl->batch_buffer);
+            THROW_S(e);
+        }
+    }
+    impl->in_use = 1;
+    wal_flush_header(impl);
+
     w->impl = impl;
     w->begin = wal_begin;
     w->commit = wal_commit;
@@ -1734,6 +1879,8 @@ EXCEPTION:
         wal_io_cleanup_macos(impl);
 #endif
         if (impl->fd > 0) close(impl->fd);
+        if (impl->undo) impl->undo->free(impl->undo);
+        if (impl->unfinished) impl->unfinished->free(impl->unfinished);
         FREE(impl);
     }
     if (w) FREE(w);
@@ -29,6 +29,7 @@ enum wal_ops {
     OP_WRITE = 0x01,      // Write page
     OP_DELETE = 0x02,     // Delete page
     OP_UPDATE = 0x03,     // Update page
+    OP_UNDO = 0x04,       // Page image before an update or delete (crash recovery)
     OP_COMMIT = 0x10,     // Transaction commit
     OP_ROLLBACK = 0x11,   // Transaction rollback
     OP_CHECKPOINT = 0x20, // Checkpoint marker
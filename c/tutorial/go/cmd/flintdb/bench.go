@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"flintdb-tutorial/flintdb/bench"
+)
+
+// benchCommand runs the benchmarks of package bench whose names match -run
+// and prints their results the way go test -bench does.
+func benchCommand(args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	run := fs.String("run", "", "run only the benchmarks matching this regular expression")
+	benchtime := fs.Duration("benchtime", time.Second, "run each benchmark for about this long")
+	if _, err := parse(fs, args, 0, 0, "[-run regexp] [-benchtime d]"); err != nil {
+		return err
+	}
+	match, err := regexp.Compile(*run)
+	if err != nil {
+		return err
+	}
+	testing.Init()
+	if err := flag.Set("test.benchtime", benchtime.String()); err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, bm := range bench.All() {
+		if !match.MatchString(bm.Name) {
+			continue
+		}
+		r := testing.Benchmark(bm.F)
+		if r.N == 0 {
+			fmt.Printf("%-28s FAIL\n", bm.Name)
+			failed++
+			continue
+		}
+		fmt.Printf("%-28s %s\t%s\n", bm.Name, r, r.MemString())
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d benchmarks failed", failed)
+	}
+	return nil
+}
@@ -0,0 +1,292 @@
+// Command flintdb inspects and maintains FlintDB tables from the shell.
+//
+//	flintdb schema <table>
+//	flintdb query [-format table|csv|tsv|json|jsonl] <table> [query]
+//	flintdb insert [-format csv|tsv|jsonl] [-header] <table> < rows
+//	flintdb export [-format ...] <table> <file> [query]
+//	flintdb import [-format ...] [-header] <table> <file>
+//	flintdb compact <table>
+//	flintdb check <table>
+//	flintdb shell <table>
+//	flintdb serve [-addr host:port] [-pg] <table|file>...
+//	flintdb bench [-run regexp] [-benchtime d]
+//
+// A query is what Table.Find takes, such as "WHERE id > 10 LIMIT 5". Export
+// and import take the format from the file extension unless -format is given;
+// .parquet files are read and written as Parquet.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	flintdb "flintdb-tutorial/flintdb"
+)
+
+const usage = `usage: flintdb <command> [flags] <table> [args]
+
+commands:
+  schema   print the CREATE TABLE statement
+  query    print the rows matching a query as a table, CSV, TSV or JSON
+  insert   insert rows read from stdin
+  export   write the rows matching a query to a file
+  import   insert the rows of a file
+  compact  rewrite the table without the space of deleted rows
+  check    verify the indexes and rows of the table
+  shell    explore the table interactively
+  serve    serve tables and files over gRPC or the PostgreSQL protocol
+  bench    measure inserts, reads, scans, upserts and imports
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	commands := map[string]func(args []string) error{
+		"schema":  schema,
+		"query":   query,
+		"insert":  insert,
+		"export":  export,
+		"import":  importFile,
+		"compact": compact,
+		"check":   check,
+		"shell":   shellCommand,
+		"serve":   serve,
+		"bench":   benchCommand,
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "flintdb: unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+	err := cmd(os.Args[2:])
+	flintdb.Cleanup()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "flintdb %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+// parse parses the flags of command and checks it got between min and max
+// arguments.
+func parse(fs *flag.FlagSet, args []string, min, max int, synopsis string) ([]string, error) {
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: flintdb %s %s\n", fs.Name(), synopsis)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() < min || fs.NArg() > max {
+		fs.Usage()
+		os.Exit(2)
+	}
+	return fs.Args(), nil
+}
+
+func open(path string, mode uint32) (*flintdb.Table, error) {
+	if _, err := os.Stat(path + flintdb.META_NAME_SUFFIX); err != nil {
+		return nil, fmt.Errorf("not a table: %s", path)
+	}
+	return flintdb.TableOpen(path, mode, nil)
+}
+
+// formatOf returns the format of flag value f, or of the extension of file.
+func formatOf(f, file string) (string, error) {
+	if f == "" {
+		f = strings.TrimPrefix(strings.ToLower(filepath.Ext(file)), ".")
+	}
+	switch f {
+	case flintdb.FORMAT_CSV, flintdb.FORMAT_TSV, flintdb.FORMAT_JSON, flintdb.FORMAT_JSONL, "parquet":
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown format %q", f)
+}
+
+func schema(args []string) error {
+	fs := flag.NewFlagSet("schema", flag.ExitOnError)
+	args, err := parse(fs, args, 1, 1, "<table>")
+	if err != nil {
+		return err
+	}
+	t, err := open(args[0], flintdb.FLINTDB_RDONLY)
+	if err != nil {
+		return err
+	}
+	defer t.Close()
+	sql, err := t.Schema()
+	if err != nil {
+		return err
+	}
+	fmt.Println(sql)
+	return nil
+}
+
+func query(args []string) error {
+	fs := flag.NewFlagSet("query", flag.ExitOnError)
+	format := fs.String("format", "table", "output format: table, csv, tsv, json or jsonl")
+	args, err := parse(fs, args, 1, 2, "[-format f] <table> [query]")
+	if err != nil {
+		return err
+	}
+	q := ""
+	if len(args) > 1 {
+		q = args[1]
+	}
+	t, err := open(args[0], flintdb.FLINTDB_RDONLY)
+	if err != nil {
+		return err
+	}
+	defer t.Close()
+
+	if *format != "table" {
+		if _, err := formatOf(*format, ""); err != nil || *format == "parquet" {
+			return fmt.Errorf("unknown format %q", *format)
+		}
+		return t.Export(os.Stdout, *format, q, true)
+	}
+	// Columns aligned from the TSV export
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	if err := t.Export(tw, flintdb.FORMAT_TSV, q, true); err != nil {
+		return err
+	}
+	return tw.Flush()
+}
+
+func insert(args []string) error {
+	fs := flag.NewFlagSet("insert", flag.ExitOnError)
+	format := fs.String("format", flintdb.FORMAT_CSV, "input format: csv, tsv or jsonl")
+	header := fs.Bool("header", false, "the first CSV/TSV line names the columns")
+	args, err := parse(fs, args, 1, 1, "[-format f] [-header] <table> < rows")
+	if err != nil {
+		return err
+	}
+	return load(args[0], os.Stdin, *format, *header)
+}
+
+func load(path string, r io.Reader, format string, header bool) error {
+	t, err := open(path, flintdb.FLINTDB_RDWR)
+	if err != nil {
+		return err
+	}
+	defer t.Close()
+	n, bad, err := t.Import(r, format, flintdb.ImportOptions{Header: header})
+	for _, e := range bad {
+		fmt.Fprintln(os.Stderr, e)
+	}
+	fmt.Fprintf(os.Stderr, "%d rows inserted, %d lines skipped\n", n, len(bad))
+	return err
+}
+
+func export(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	format := fs.String("format", "", "file format: csv, tsv, json, jsonl or parquet (default: from the extension)")
+	args, err := parse(fs, args, 2, 3, "[-format f] <table> <file> [query]")
+	if err != nil {
+		return err
+	}
+	f, err := formatOf(*format, args[1])
+	if err != nil {
+		return err
+	}
+	q := ""
+	if len(args) > 2 {
+		q = args[2]
+	}
+	t, err := open(args[0], flintdb.FLINTDB_RDONLY)
+	if err != nil {
+		return err
+	}
+	defer t.Close()
+
+	if f == "parquet" {
+		n, err := t.ExportParquet(args[1], q)
+		if err == nil {
+			fmt.Fprintf(os.Stderr, "%d rows exported\n", n)
+		}
+		return err
+	}
+	out, err := os.Create(args[1])
+	if err != nil {
+		return err
+	}
+	if err := t.Export(out, f, q, true); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func importFile(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	format := fs.String("format", "", "file format: csv, tsv, jsonl or parquet (default: from the extension)")
+	header := fs.Bool("header", false, "the first CSV/TSV line names the columns")
+	args, err := parse(fs, args, 2, 2, "[-format f] [-header] <table> <file>")
+	if err != nil {
+		return err
+	}
+	f, err := formatOf(*format, args[1])
+	if err != nil {
+		return err
+	}
+	if f == "parquet" {
+		t, err := open(args[0], flintdb.FLINTDB_RDWR)
+		if err != nil {
+			return err
+		}
+		defer t.Close()
+		n, err := t.ImportParquet(args[1])
+		fmt.Fprintf(os.Stderr, "%d rows inserted\n", n)
+		return err
+	}
+	in, err := os.Open(args[1])
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	return load(args[0], in, f, *header)
+}
+
+func compact(args []string) error {
+	fs := flag.NewFlagSet("compact", flag.ExitOnError)
+	args, err := parse(fs, args, 1, 1, "<table>")
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(args[0] + flintdb.META_NAME_SUFFIX); err != nil {
+		return fmt.Errorf("not a table: %s", args[0])
+	}
+	return flintdb.Compact(args[0])
+}
+
+func check(args []string) error {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	args, err := parse(fs, args, 1, 1, "<table>")
+	if err != nil {
+		return err
+	}
+	t, err := open(args[0], flintdb.FLINTDB_RDONLY)
+	if err != nil {
+		return err
+	}
+	defer t.Close()
+	problems, err := t.Check()
+	for _, p := range problems {
+		fmt.Println(p)
+	}
+	if err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%d problems found", len(problems))
+	}
+	rows, _ := t.Rows()
+	fmt.Printf("ok: %d rows\n", rows)
+	return nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	flintdb "flintdb-tutorial/flintdb"
+	"flintdb-tutorial/flintdbrpc"
+)
+
+// serve serves tables and delimited files over gRPC, each under its base
+// name, until interrupted. With -pg the tables are served to PostgreSQL
+// clients instead.
+func serve(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", "", "address to listen on (default localhost:50051, or localhost:5432 with -pg)")
+	pg := fs.Bool("pg", false, "serve the tables over the PostgreSQL protocol rather than gRPC")
+	args, err := parse(fs, args, 1, 1<<16, "[-addr host:port] [-pg] <table|file>...")
+	if err != nil {
+		return err
+	}
+	if *addr == "" {
+		*addr = "localhost:50051"
+		if *pg {
+			*addr = "localhost:5432"
+		}
+	}
+
+	s := flintdbrpc.NewServer()
+	pgs := flintdb.NewPGServer()
+	for _, path := range args {
+		name := filepath.Base(path)
+		if _, err := os.Stat(path + flintdb.META_NAME_SUFFIX); err != nil {
+			if *pg {
+				return fmt.Errorf("not a table: %s", path)
+			}
+			f, err := flintdb.GenericFileOpen(path, flintdb.FLINTDB_RDONLY, nil)
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			defer f.Close()
+			s.RegisterFile(name, f)
+			continue
+		}
+		t, err := flintdb.TableOpen(path, flintdb.FLINTDB_RDWR, nil)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		defer t.Close()
+		s.Register(name, t)
+		pgs.Register(name, t)
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	if *pg {
+		go func() {
+			<-stop
+			pgs.Shutdown()
+		}()
+		fmt.Fprintf(os.Stderr, "serving %d tables over the PostgreSQL protocol on %s\n", len(args), *addr)
+		return pgs.ListenAndServe(*addr)
+	}
+	go func() {
+		<-stop
+		s.Shutdown()
+	}()
+	fmt.Fprintf(os.Stderr, "serving %d tables and files on %s\n", len(args), *addr)
+	return s.ListenAndServe(*addr)
+}
@@ -0,0 +1,464 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	flintdb "flintdb-tutorial/flintdb"
+	"golang.org/x/term"
+)
+
+const shellHelp = `Statements end with ; and may span lines:
+  SELECT <columns|*|COUNT(*)> [FROM <table>] [query]
+  EXPLAIN SELECT <columns|*> [FROM <table>] [query]
+where query is what Table.Find takes, such as WHERE id > 10 LIMIT 5.
+
+Commands:
+  \d          show the schema of the table
+  \dt         list the tables in the table's directory
+  \c <table>  switch to another table
+  \x          toggle expanded output
+  \?          show this help
+  \q          quit
+`
+
+// shellHistoryMax is the number of statements the shell remembers.
+const shellHistoryMax = 500
+
+type shell struct {
+	table    *flintdb.Table
+	path     string
+	expanded bool
+	out      io.Writer
+}
+
+func shellCommand(args []string) error {
+	fs := flag.NewFlagSet("shell", flag.ExitOnError)
+	args, err := parse(fs, args, 1, 1, "<table>")
+	if err != nil {
+		return err
+	}
+	sh := &shell{out: os.Stdout}
+	if err := sh.open(args[0]); err != nil {
+		return err
+	}
+	defer func() { sh.table.Close() }()
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		in := bufio.NewReader(os.Stdin)
+		return sh.run(func(bool) (string, error) { return in.ReadString('\n') }, nil)
+	}
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(fd, state)
+	t := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, os.Stdout}, "")
+	history := loadShellHistory()
+	t.History = history
+	if w, h, err := term.GetSize(fd); err == nil {
+		t.SetSize(w, h)
+	}
+	sh.out = t
+	fmt.Fprintf(t, "flintdb shell: %s (\\? for help)\n", sh.path)
+	return sh.run(func(continued bool) (string, error) {
+		t.SetPrompt("flintdb> ")
+		if continued {
+			t.SetPrompt("     ... ")
+		}
+		line, err := t.ReadLine()
+		return line + "\n", err
+	}, history.add)
+}
+
+func (sh *shell) open(path string) error {
+	t, err := open(path, flintdb.FLINTDB_RDONLY)
+	if err != nil {
+		return err
+	}
+	if sh.table != nil {
+		sh.table.Close()
+	}
+	sh.table, sh.path = t, path
+	return nil
+}
+
+// run reads statements until the input ends or \q, calling remember with
+// each complete one. read returns the next line, continued being set while a
+// statement is incomplete.
+func (sh *shell) run(read func(continued bool) (string, error), remember func(string)) error {
+	var buf strings.Builder
+	for {
+		line, err := read(buf.Len() > 0)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		trimmed := strings.TrimSpace(line)
+		if buf.Len() == 0 && strings.HasPrefix(trimmed, `\`) {
+			if remember != nil {
+				remember(trimmed)
+			}
+			if sh.command(trimmed) {
+				return nil
+			}
+		} else if trimmed != "" || buf.Len() > 0 {
+			buf.WriteString(line)
+			stmts, rest := splitStatements(buf.String())
+			for _, stmt := range stmts {
+				if remember != nil {
+					remember(strings.Join(strings.Fields(stmt), " ") + ";")
+				}
+				if err := sh.execute(stmt); err != nil {
+					fmt.Fprintf(sh.out, "ERROR: %v\n", err)
+				}
+			}
+			buf.Reset()
+			if strings.TrimSpace(rest) != "" {
+				buf.WriteString(rest)
+			}
+		}
+		if err != nil {
+			return nil
+		}
+	}
+}
+
+// splitStatements returns the statements of s ended by a semicolon outside
+// quotes, and the text after the last one.
+func splitStatements(s string) ([]string, string) {
+	var stmts []string
+	var quote rune
+	start := 0
+	for i, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+		case r == ';':
+			if stmt := strings.TrimSpace(s[start:i]); stmt != "" {
+				stmts = append(stmts, stmt)
+			}
+			start = i + 1
+		}
+	}
+	return stmts, s[start:]
+}
+
+// command runs a backslash command and reports whether it asks to quit.
+func (sh *shell) command(line string) bool {
+	fields := strings.Fields(line)
+	switch fields[0] {
+	case `\q`:
+		return true
+	case `\?`, `\h`:
+		fmt.Fprint(sh.out, shellHelp)
+	case `\d`:
+		sql, err := sh.table.Schema()
+		if err != nil {
+			fmt.Fprintf(sh.out, "ERROR: %v\n", err)
+			break
+		}
+		fmt.Fprintln(sh.out, strings.TrimRight(sql, "\n"))
+	case `\dt`:
+		matches, _ := filepath.Glob(filepath.Join(filepath.Dir(sh.path), "*"+flintdb.META_NAME_SUFFIX))
+		for _, m := range matches {
+			fmt.Fprintln(sh.out, strings.TrimSuffix(m, flintdb.META_NAME_SUFFIX))
+		}
+	case `\c`:
+		if len(fields) != 2 {
+			fmt.Fprintln(sh.out, `usage: \c <table>`)
+			break
+		}
+		if err := sh.open(fields[1]); err != nil {
+			fmt.Fprintf(sh.out, "ERROR: %v\n", err)
+			break
+		}
+		fmt.Fprintf(sh.out, "now on %s\n", sh.path)
+	case `\x`:
+		sh.expanded = !sh.expanded
+		state := "off"
+		if sh.expanded {
+			state = "on"
+		}
+		fmt.Fprintf(sh.out, "expanded display is %s\n", state)
+	default:
+		fmt.Fprintf(sh.out, "unknown command %s; \\? for help\n", fields[0])
+	}
+	return false
+}
+
+func (sh *shell) execute(stmt string) error {
+	word, rest := cutWord(stmt)
+	explain := strings.EqualFold(word, "EXPLAIN")
+	if explain {
+		word, rest = cutWord(rest)
+	}
+	if !strings.EqualFold(word, "SELECT") {
+		return fmt.Errorf("unsupported statement: %s", stmt)
+	}
+	columns, query, err := sh.selectList(rest)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case explain:
+		if columns == nil {
+			columns = sh.table.Columns()
+		}
+		plan, err := sh.table.Explain(query, columns...)
+		if err != nil {
+			return err
+		}
+		estimate := ""
+		if plan.Estimate >= 0 {
+			estimate = strconv.FormatInt(plan.Estimate, 10)
+		}
+		sh.print([]string{"index", "algorithm", "keys", "descending", "covering", "estimate"}, [][]string{{
+			plan.Index, plan.Algorithm, strings.Join(plan.Keys, ", "),
+			strconv.FormatBool(plan.Descending), strconv.FormatBool(plan.Covering), estimate,
+		}})
+		return nil
+	case len(columns) == 1 && strings.EqualFold(strings.ReplaceAll(columns[0], " ", ""), "COUNT(*)"):
+		cursor, err := sh.table.Find(query)
+		if err != nil {
+			return err
+		}
+		defer cursor.Close()
+		var n int64
+		for {
+			rowid, err := cursor.Next()
+			if err != nil {
+				return err
+			}
+			if rowid < 0 {
+				break
+			}
+			n++
+		}
+		sh.print([]string{"count"}, [][]string{{strconv.FormatInt(n, 10)}})
+		return nil
+	}
+
+	if columns == nil {
+		columns = sh.table.Columns()
+	}
+	cursor, err := sh.table.Select(query, columns...)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close()
+	var rows [][]string
+	for {
+		values, err := cursor.Next()
+		if err != nil {
+			return err
+		}
+		if values == nil {
+			break
+		}
+		cells := make([]string, len(values))
+		for i, v := range values {
+			cells[i] = shellText(v)
+		}
+		rows = append(rows, cells)
+	}
+	sh.print(columns, rows)
+	return nil
+}
+
+// selectList splits what follows SELECT into its columns, nil for *, and
+// the query after them and an optional FROM.
+func (sh *shell) selectList(s string) ([]string, string, error) {
+	depth, end := 0, len(s)
+	for i, r := range s {
+		if r == '(' {
+			depth++
+		} else if r == ')' {
+			depth--
+		}
+		if depth == 0 && i > 0 && isSpace(s[i-1]) && hasKeyword(s[i:], "FROM", "WHERE", "USE", "ORDER", "LIMIT") {
+			end = i
+			break
+		}
+	}
+	list, query := strings.TrimSpace(s[:end]), strings.TrimSpace(s[end:])
+	if list == "" {
+		return nil, "", errors.New("SELECT needs columns")
+	}
+	if word, rest := cutWord(query); strings.EqualFold(word, "FROM") {
+		name, rest := cutWord(rest)
+		if name != "" && name != sh.path && name != filepath.Base(sh.path) {
+			return nil, "", fmt.Errorf("table %s is not open; use \\c %s", name, name)
+		}
+		query = rest
+	}
+	if list == "*" {
+		return nil, query, nil
+	}
+	var columns []string
+	for _, c := range strings.Split(list, ",") {
+		columns = append(columns, strings.TrimSpace(c))
+	}
+	return columns, query, nil
+}
+
+func cutWord(s string) (string, string) {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexFunc(s, func(r rune) bool { return r == ' ' || r == '\t' || r == '\n' || r == '\r' }); i >= 0 {
+		return s[:i], strings.TrimSpace(s[i:])
+	}
+	return s, ""
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r'
+}
+
+func hasKeyword(s string, keywords ...string) bool {
+	word, _ := cutWord(s)
+	for _, k := range keywords {
+		if strings.EqualFold(word, k) {
+			return true
+		}
+	}
+	return false
+}
+
+func shellText(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case time.Time:
+		return v.UTC().Format("2006-01-02 15:04:05")
+	case []byte:
+		return hex.EncodeToString(v)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+// print writes rows under a header, aligned as psql does, or one value per
+// line in expanded mode.
+func (sh *shell) print(columns []string, rows [][]string) {
+	w := bufio.NewWriter(sh.out)
+	defer w.Flush()
+	width := func(s string) int { return utf8.RuneCountInString(s) }
+	pad := func(s string, n int) string { return s + strings.Repeat(" ", n-width(s)) }
+
+	if sh.expanded {
+		name := 0
+		for _, c := range columns {
+			name = max(name, width(c))
+		}
+		for i, row := range rows {
+			fmt.Fprintf(w, "-[ RECORD %d ]%s\n", i+1, strings.Repeat("-", name))
+			for j, cell := range row {
+				fmt.Fprintf(w, "%s | %s\n", pad(columns[j], name), cell)
+			}
+		}
+	} else {
+		widths := make([]int, len(columns))
+		for i, c := range columns {
+			widths[i] = width(c)
+		}
+		for _, row := range rows {
+			for i, cell := range row {
+				widths[i] = max(widths[i], width(cell))
+			}
+		}
+		line := func(cells []string) {
+			for i, cell := range cells {
+				if i > 0 {
+					w.WriteString(" |")
+				}
+				w.WriteString(" " + pad(cell, widths[i]))
+			}
+			w.WriteString("\n")
+		}
+		line(columns)
+		for i, n := range widths {
+			if i > 0 {
+				w.WriteString("+")
+			}
+			w.WriteString(strings.Repeat("-", n+2))
+		}
+		w.WriteString("\n")
+		for _, row := range rows {
+			line(row)
+		}
+	}
+	if len(rows) == 1 {
+		fmt.Fprintln(w, "(1 row)")
+	} else {
+		fmt.Fprintf(w, "(%d rows)\n", len(rows))
+	}
+}
+
+// shellHistory keeps whole statements, however many lines they were typed
+// on, rather than the lines term.Terminal reads, and saves them to
+// ~/.flintdb_history.
+type shellHistory struct {
+	file    string
+	entries []string // oldest first
+}
+
+func loadShellHistory() *shellHistory {
+	h := &shellHistory{}
+	if home, err := os.UserHomeDir(); err == nil {
+		h.file = filepath.Join(home, ".flintdb_history")
+		if data, err := os.ReadFile(h.file); err == nil {
+			for _, line := range strings.Split(string(data), "\n") {
+				if line != "" {
+					h.entries = append(h.entries, line)
+				}
+			}
+		}
+	}
+	if len(h.entries) > shellHistoryMax {
+		h.entries = h.entries[len(h.entries)-shellHistoryMax:]
+	}
+	return h
+}
+
+// Add ignores the lines term.Terminal reads; see add.
+func (h *shellHistory) Add(string) {}
+
+func (h *shellHistory) Len() int { return len(h.entries) }
+
+func (h *shellHistory) At(i int) string { return h.entries[len(h.entries)-1-i] }
+
+func (h *shellHistory) add(stmt string) {
+	if n := len(h.entries); n > 0 && h.entries[n-1] == stmt {
+		return
+	}
+	h.entries = append(h.entries, stmt)
+	if len(h.entries) > shellHistoryMax {
+		h.entries = h.entries[1:]
+	}
+	if h.file == "" {
+		return
+	}
+	if f, err := os.OpenFile(h.file, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600); err == nil {
+		fmt.Fprintln(f, stmt)
+		f.Close()
+	}
+}
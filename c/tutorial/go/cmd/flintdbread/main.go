@@ -0,0 +1,154 @@
+// Command flintdbread prints the rows of a FlintDB table without the C
+// engine, for reference tables shipped with a program:
+//
+//	flintdbread [-format tsv|jsonl] <table> [query]
+//
+// A query is what flintdbfile.Table.Find takes, such as "WHERE code = 'KR'".
+// It does not use cgo, so it also builds for WebAssembly. For WASI:
+//
+//	GOOS=wasip1 GOARCH=wasm go build -o flintdbread.wasm ./cmd/flintdbread
+//	wasmtime --dir temp flintdbread.wasm temp/tutorial.flintdb "LIMIT 5"
+//
+// Output matches what flintdb query writes: NULL as \N in TSV and null in
+// JSON, dates and times in UTC and bytes in hex.
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"flintdb-tutorial/flintdbfile"
+)
+
+func main() {
+	format := flag.String("format", "tsv", "output format: tsv or jsonl")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: flintdbread [-format tsv|jsonl] <table> [query]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() < 1 || flag.NArg() > 2 || (*format != "tsv" && *format != "jsonl") {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := run(flag.Arg(0), flag.Arg(1), *format); err != nil {
+		fmt.Fprintf(os.Stderr, "flintdbread: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(path, query, format string) error {
+	t, err := flintdbfile.Open(path)
+	if err != nil {
+		return err
+	}
+	defer t.Close()
+	cursor, err := t.Find(query)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close()
+
+	columns := t.Schema().Columns
+	w := bufio.NewWriter(os.Stdout)
+	if format == "tsv" {
+		for i, c := range columns {
+			if i > 0 {
+				w.WriteByte('\t')
+			}
+			w.WriteString(escapeTSV(c.Name))
+		}
+		w.WriteByte('\n')
+	}
+	for {
+		rowid, err := cursor.Next()
+		if err != nil {
+			return err
+		}
+		if rowid < 0 {
+			break
+		}
+		values, err := t.Read(rowid)
+		if err != nil {
+			return err
+		}
+		if format == "jsonl" {
+			w.WriteByte('{')
+		}
+		for i, c := range columns {
+			v := values[c.Name]
+			switch {
+			case format == "tsv" && i > 0:
+				w.WriteByte('\t')
+			case format == "jsonl" && i > 0:
+				w.WriteByte(',')
+			}
+			if format == "tsv" {
+				if v == nil {
+					w.WriteString(`\N`)
+				} else {
+					w.WriteString(escapeTSV(text(v, c.Type)))
+				}
+				continue
+			}
+			key, _ := json.Marshal(c.Name)
+			w.Write(key)
+			w.WriteByte(':')
+			w.Write(jsonValue(v, c.Type))
+		}
+		if format == "jsonl" {
+			w.WriteByte('}')
+		}
+		w.WriteByte('\n')
+	}
+	return w.Flush()
+}
+
+func text(v interface{}, kind int) string {
+	switch v := v.(type) {
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case string:
+		return v
+	case time.Time:
+		if kind == flintdbfile.VARIANT_DATE {
+			return v.UTC().Format("2006-01-02")
+		}
+		return v.UTC().Format("2006-01-02 15:04:05")
+	case []byte:
+		return hex.EncodeToString(v)
+	}
+	return fmt.Sprint(v)
+}
+
+var tsvEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+func escapeTSV(s string) string {
+	return tsvEscaper.Replace(s)
+}
+
+func jsonValue(v interface{}, kind int) []byte {
+	switch x := v.(type) {
+	case nil:
+		return []byte("null")
+	case float64:
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return []byte("null")
+		}
+	case int64:
+	default:
+		v = text(v, kind)
+	}
+	b, _ := json.Marshal(v)
+	return b
+}
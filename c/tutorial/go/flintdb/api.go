@@ -0,0 +1,145 @@
+package flintdb
+
+import (
+	"fmt"
+
+	"flintdb-tutorial/flintdbapi"
+)
+
+// API returns t as a flintdbapi.TableAPI, for code that also runs against
+// the in-memory fake of package flintdbapi. Closing it closes t.
+func (t *Table) API() flintdbapi.TableAPI {
+	return tableAPI{t}
+}
+
+// API returns f as a flintdbapi.GenericFileAPI. Closing it closes f.
+func (f *GenericFile) API() flintdbapi.GenericFileAPI {
+	return genericFileAPI{f}
+}
+
+type tableAPI struct {
+	t *Table
+}
+
+func (a tableAPI) Columns() []string {
+	return a.t.Columns()
+}
+
+func (a tableAPI) Insert(values flintdbapi.Values) (int64, error) {
+	row, err := a.t.CreateRow()
+	if err != nil {
+		return -1, err
+	}
+	defer row.Free()
+	if err := setValues(row, values); err != nil {
+		return -1, err
+	}
+	return a.t.Insert(row)
+}
+
+func (a tableAPI) Read(rowid int64) (flintdbapi.Values, error) {
+	row, err := a.t.Read(rowid)
+	if err != nil {
+		return nil, err
+	}
+	return rowValues(row, a.t.Columns())
+}
+
+func (a tableAPI) UpdateAt(rowid int64, values flintdbapi.Values) error {
+	return updateRow(a.t, rowid, func(_, row *Row) error {
+		return setValues(row, values)
+	})
+}
+
+func (a tableAPI) DeleteAt(rowid int64) error {
+	return a.t.DeleteAt(rowid)
+}
+
+func (a tableAPI) Find(query string) (flintdbapi.CursorAPI, error) {
+	cursor, err := a.t.Find(query)
+	if err != nil {
+		return nil, err
+	}
+	return cursor, nil
+}
+
+func (a tableAPI) Rows() (int64, error) {
+	return a.t.Rows()
+}
+
+func (a tableAPI) Close() {
+	a.t.Close()
+}
+
+type genericFileAPI struct {
+	f *GenericFile
+}
+
+func (a genericFileAPI) Columns() []string {
+	return a.f.Columns()
+}
+
+func (a genericFileAPI) Write(values flintdbapi.Values) error {
+	row, err := a.f.CreateRow()
+	if err != nil {
+		return err
+	}
+	defer row.Free()
+	if err := setValues(row, values); err != nil {
+		return err
+	}
+	return a.f.Write(row)
+}
+
+func (a genericFileAPI) Find(query string) (flintdbapi.RowCursorAPI, error) {
+	cursor, err := a.f.Find(query)
+	if err != nil {
+		return nil, err
+	}
+	return rowCursorAPI{cursor, a.f.Columns()}, nil
+}
+
+func (a genericFileAPI) Close() error {
+	return a.f.Close()
+}
+
+type rowCursorAPI struct {
+	c       *CursorRow
+	columns []string
+}
+
+func (a rowCursorAPI) Next() (flintdbapi.Values, error) {
+	row, err := a.c.Next()
+	if err != nil || row == nil {
+		return nil, err
+	}
+	return rowValues(row, a.columns)
+}
+
+func (a rowCursorAPI) Close() {
+	a.c.Close()
+}
+
+func setValues(row *Row, values flintdbapi.Values) error {
+	for col, v := range values {
+		if row.columnAt(col) < 0 {
+			return &FlintDBError{Message: fmt.Sprintf("column not found: %s", col)}
+		}
+		if err := row.SetByName(col, v); err != nil {
+			return fmt.Errorf("column %s: %w", col, err)
+		}
+	}
+	return nil
+}
+
+func rowValues(row *Row, columns []string) (flintdbapi.Values, error) {
+	values := make(flintdbapi.Values, len(columns))
+	for i, col := range columns {
+		v, err := row.Get(i)
+		if err != nil {
+			return nil, err
+		}
+		values[col] = v
+	}
+	return values, nil
+}
@@ -0,0 +1,270 @@
+//go:build arrow
+
+package flintdb
+
+// Arrow interop pulls in github.com/apache/arrow-go; build with -tags arrow.
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+)
+
+// arrowBatchRows is the number of rows ToArrow puts in one record.
+const arrowBatchRows = 64 * 1024
+
+// arrowType returns the Arrow type a column of kind is exported as. DATE is
+// date32, TIME a UTC timestamp in seconds, and DECIMAL and the types without
+// an Arrow counterpart are text.
+func arrowType(kind int) arrow.DataType {
+	switch kind {
+	case VARIANT_INT32:
+		return arrow.PrimitiveTypes.Int32
+	case VARIANT_INT64:
+		return arrow.PrimitiveTypes.Int64
+	case VARIANT_FLOAT:
+		return arrow.PrimitiveTypes.Float32
+	case VARIANT_DOUBLE:
+		return arrow.PrimitiveTypes.Float64
+	case VARIANT_BYTES:
+		return arrow.BinaryTypes.Binary
+	case VARIANT_DATE:
+		return arrow.FixedWidthTypes.Date32
+	case VARIANT_TIME:
+		return arrow.FixedWidthTypes.Timestamp_s
+	}
+	return arrow.BinaryTypes.String
+}
+
+// arrowSchema returns the Arrow schema of the table's columns minus
+// collation keys.
+func (t *Table) arrowSchema() *arrow.Schema {
+	columns := t.exportColumns()
+	fields := make([]arrow.Field, len(columns))
+	for i, c := range columns {
+		fields[i] = arrow.Field{Name: c.name, Type: arrowType(c.kind), Nullable: t.meta.columns.a[c.index].nullspec != SPEC_NOT_NULL}
+	}
+	return arrow.NewSchema(fields, nil)
+}
+
+// ToArrow returns the rows matching query as Arrow records of up to
+// arrowBatchRows rows, with the table's columns minus collation keys. There
+// is always at least one record. The caller releases them.
+func (t *Table) ToArrow(query string) ([]arrow.Record, error) {
+	var records []arrow.Record
+	err := t.eachArrow(query, func(r arrow.Record) error {
+		r.Retain()
+		records = append(records, r)
+		return nil
+	})
+	if err != nil {
+		for _, r := range records {
+			r.Release()
+		}
+		return nil, err
+	}
+	return records, nil
+}
+
+// eachArrow passes the records ToArrow returns to fn one at a time, releasing
+// each when fn returns.
+func (t *Table) eachArrow(query string, fn func(arrow.Record) error) error {
+	columns := t.exportColumns()
+	b := array.NewRecordBuilder(memory.DefaultAllocator, t.arrowSchema())
+	defer b.Release()
+
+	cursor, err := t.Find(query)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close()
+
+	flush := func() error {
+		r := b.NewRecord()
+		defer r.Release()
+		return fn(r)
+	}
+	rows, records := 0, 0
+	for {
+		rowid, err := cursor.Next()
+		if err != nil {
+			return err
+		}
+		if rowid < 0 {
+			break
+		}
+		in, err := t.Read(rowid)
+		if err != nil {
+			return err
+		}
+		for i, c := range columns {
+			v, err := in.Get(c.index)
+			if err == nil {
+				err = appendArrow(b.Field(i), v, c.kind)
+			}
+			if err != nil {
+				return &FlintDBError{Message: fmt.Sprintf("rowid %d: %s: %v", rowid, c.name, err)}
+			}
+		}
+		if rows++; rows == arrowBatchRows {
+			if err := flush(); err != nil {
+				return err
+			}
+			rows = 0
+			records++
+		}
+	}
+	if rows > 0 || records == 0 {
+		return flush()
+	}
+	return nil
+}
+
+// appendArrow appends a value read from a column of kind to the builder of
+// its arrowType.
+func appendArrow(b array.Builder, v interface{}, kind int) error {
+	if v == nil {
+		b.AppendNull()
+		return nil
+	}
+	switch b := b.(type) {
+	case *array.Int32Builder:
+		if x, ok := v.(int64); ok {
+			b.Append(int32(x))
+			return nil
+		}
+	case *array.Int64Builder:
+		if x, ok := v.(int64); ok {
+			b.Append(x)
+			return nil
+		}
+	case *array.Float32Builder:
+		if x, ok := v.(float64); ok {
+			b.Append(float32(x))
+			return nil
+		}
+	case *array.Float64Builder:
+		if x, ok := v.(float64); ok {
+			b.Append(x)
+			return nil
+		}
+	case *array.BinaryBuilder:
+		if x, ok := v.([]byte); ok {
+			b.Append(x)
+			return nil
+		}
+	case *array.Date32Builder:
+		if x, ok := v.(time.Time); ok {
+			b.Append(arrow.Date32FromTime(x))
+			return nil
+		}
+	case *array.TimestampBuilder:
+		if x, ok := v.(time.Time); ok {
+			b.Append(arrow.Timestamp(x.Unix()))
+			return nil
+		}
+	case *array.StringBuilder:
+		b.Append(exportText(v, kind))
+		return nil
+	}
+	return &FlintDBError{Message: fmt.Sprintf("cannot append %T to %s", v, b.Type())}
+}
+
+// InsertArrow inserts the rows of rec and returns how many were inserted.
+// Columns are matched by name as in CopyRows, and text is parsed to the
+// column type as Import does. The first failing row stops the insert.
+func (t *Table) InsertArrow(rec arrow.Record) (int64, error) {
+	type mapping struct {
+		from int
+		to   exportColumn
+	}
+	var columns []mapping
+	for _, c := range t.exportColumns() {
+		for i, f := range rec.Schema().Fields() {
+			if strings.EqualFold(f.Name, c.name) {
+				columns = append(columns, mapping{from: i, to: c})
+				break
+			}
+		}
+	}
+	if len(columns) == 0 {
+		return 0, &FlintDBError{Message: "no columns in common"}
+	}
+
+	out, err := t.CreateRow()
+	if err != nil {
+		return 0, err
+	}
+	defer out.Free()
+	var inserted int64
+	for ; inserted < rec.NumRows(); inserted++ {
+		for _, m := range columns {
+			v, kind := arrowValue(rec.Column(m.from), int(inserted))
+			v, err := coerceValue(v, kind, m.to.kind)
+			if err == nil {
+				err = out.Set(m.to.index, v)
+			}
+			if err != nil {
+				return inserted, &FlintDBError{Message: fmt.Sprintf("row %d: %s: %v", inserted+1, m.to.name, err)}
+			}
+		}
+		if _, err := t.Insert(out); err != nil {
+			return inserted, &FlintDBError{Message: fmt.Sprintf("row %d: %v", inserted+1, err)}
+		}
+	}
+	return inserted, nil
+}
+
+// arrowValue returns value i of a as Row.Get would return it, with the
+// variant kind it corresponds to. Arrays of other types give their text.
+func arrowValue(a arrow.Array, i int) (interface{}, int) {
+	if a.IsNull(i) {
+		return nil, VARIANT_STRING
+	}
+	switch a := a.(type) {
+	case *array.Boolean:
+		if a.Value(i) {
+			return int64(1), VARIANT_INT64
+		}
+		return int64(0), VARIANT_INT64
+	case *array.Int8:
+		return int64(a.Value(i)), VARIANT_INT64
+	case *array.Int16:
+		return int64(a.Value(i)), VARIANT_INT64
+	case *array.Int32:
+		return int64(a.Value(i)), VARIANT_INT64
+	case *array.Int64:
+		return a.Value(i), VARIANT_INT64
+	case *array.Uint8:
+		return int64(a.Value(i)), VARIANT_INT64
+	case *array.Uint16:
+		return int64(a.Value(i)), VARIANT_INT64
+	case *array.Uint32:
+		return int64(a.Value(i)), VARIANT_INT64
+	case *array.Uint64:
+		return int64(a.Value(i)), VARIANT_INT64
+	case *array.Float32:
+		return float64(a.Value(i)), VARIANT_DOUBLE
+	case *array.Float64:
+		return a.Value(i), VARIANT_DOUBLE
+	case *array.String:
+		return a.Value(i), VARIANT_STRING
+	case *array.LargeString:
+		return a.Value(i), VARIANT_STRING
+	case *array.Binary:
+		return a.Value(i), VARIANT_BYTES
+	case *array.LargeBinary:
+		return a.Value(i), VARIANT_BYTES
+	case *array.Date32:
+		return a.Value(i).ToTime(), VARIANT_DATE
+	case *array.Date64:
+		return a.Value(i).ToTime(), VARIANT_DATE
+	case *array.Timestamp:
+		return a.Value(i).ToTime(a.DataType().(*arrow.TimestampType).Unit), VARIANT_TIME
+	}
+	return a.ValueStr(i), VARIANT_STRING
+}
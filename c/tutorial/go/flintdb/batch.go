@@ -0,0 +1,231 @@
+package flintdb
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Value tags of a packed row, matching PACKED_* in the flintdb.go preamble.
+const (
+	packedDefault = iota
+	packedNull
+	packedInt64
+	packedDouble
+	packedString
+	packedBytes
+	packedTime
+)
+
+// RowBatch packs rows for InsertBatch, which hands them to the engine in one
+// call instead of one call per value set and one per insert. Values are
+// encoded as they are added, so the batch holds no references to them.
+type RowBatch struct {
+	columns int
+	buf     []byte
+	rows    int
+}
+
+// NewRowBatch returns an empty batch for rows of t.
+func (t *Table) NewRowBatch() *RowBatch {
+	return &RowBatch{columns: int(t.meta.columns.length)}
+}
+
+// Add appends a row with one value per column, in the order Row.Set indexes
+// them; columns past the last value keep their defaults. Values are cast to
+// the column types as Row.Set casts them when the batch is inserted.
+func (b *RowBatch) Add(values ...interface{}) error {
+	if len(values) > b.columns {
+		return &FlintDBError{Message: fmt.Sprintf("expected at most %d values, got %d", b.columns, len(values))}
+	}
+	start := len(b.buf)
+	for i := 0; i < b.columns; i++ {
+		if i >= len(values) {
+			b.buf = append(b.buf, packedDefault)
+			continue
+		}
+		if err := b.pack(values[i]); err != nil {
+			b.buf = b.buf[:start]
+			return &FlintDBError{Message: fmt.Sprintf("column %d: %v", i, err)}
+		}
+	}
+	b.rows++
+	return nil
+}
+
+// addSlots is Add with column i taking values[slots[i]], or its default for
+// a slot of -1.
+func (b *RowBatch) addSlots(slots []int, values []interface{}) error {
+	start := len(b.buf)
+	for i, slot := range slots {
+		if slot < 0 {
+			b.buf = append(b.buf, packedDefault)
+			continue
+		}
+		if err := b.pack(values[slot]); err != nil {
+			b.buf = b.buf[:start]
+			return &FlintDBError{Message: fmt.Sprintf("column %d: %v", i, err)}
+		}
+	}
+	b.rows++
+	return nil
+}
+
+// skip returns the rows of b after the first n, sharing its buffer.
+func (b *RowBatch) skip(n int) *RowBatch {
+	buf := b.buf
+	for i := 0; i < n*b.columns; i++ {
+		_, _, buf = unpack(buf)
+	}
+	return &RowBatch{columns: b.columns, buf: buf, rows: b.rows - n}
+}
+
+func (b *RowBatch) pack(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		b.buf = append(b.buf, packedNull)
+	case bool:
+		var i int64
+		if v {
+			i = 1
+		}
+		b.packInt64(i)
+	case int:
+		b.packInt64(int64(v))
+	case int8:
+		b.packInt64(int64(v))
+	case int16:
+		b.packInt64(int64(v))
+	case int32:
+		b.packInt64(int64(v))
+	case int64:
+		b.packInt64(v)
+	case uint8:
+		b.packInt64(int64(v))
+	case uint16:
+		b.packInt64(int64(v))
+	case uint32:
+		b.packInt64(int64(v))
+	case float32:
+		b.buf = append(b.buf, packedDouble)
+		b.buf = binary.NativeEndian.AppendUint64(b.buf, math.Float64bits(float64(v)))
+	case float64:
+		b.buf = append(b.buf, packedDouble)
+		b.buf = binary.NativeEndian.AppendUint64(b.buf, math.Float64bits(v))
+	case string:
+		b.buf = append(b.buf, packedString)
+		b.buf = binary.NativeEndian.AppendUint32(b.buf, uint32(len(v)))
+		b.buf = append(b.buf, v...)
+	case []byte:
+		b.buf = append(b.buf, packedBytes)
+		b.buf = binary.NativeEndian.AppendUint32(b.buf, uint32(len(v)))
+		b.buf = append(b.buf, v...)
+	case time.Time:
+		b.buf = append(b.buf, packedTime)
+		b.buf = binary.NativeEndian.AppendUint64(b.buf, uint64(v.Unix()))
+	default:
+		return &FlintDBError{Message: fmt.Sprintf("unsupported value type: %T", value)}
+	}
+	return nil
+}
+
+func (b *RowBatch) packInt64(v int64) {
+	b.buf = append(b.buf, packedInt64)
+	b.buf = binary.NativeEndian.AppendUint64(b.buf, uint64(v))
+}
+
+// Len returns the number of rows added since the last Reset.
+func (b *RowBatch) Len() int {
+	return b.rows
+}
+
+// Reset empties the batch, keeping its buffer for the next rows.
+func (b *RowBatch) Reset() {
+	b.buf = b.buf[:0]
+	b.rows = 0
+}
+
+// InsertBatch inserts the rows of b and returns how many were inserted; the
+// first failing row stops the insert. Tables with computed columns,
+// collations, checks, foreign keys, side indexes or history need each row in Go, so
+// their rows are unpacked and inserted one at a time as Insert does.
+func (t *Table) InsertBatch(b *RowBatch) (int64, error) {
+	return t.InsertBatchContext(context.Background(), b)
+}
+
+// InsertBatchContext is InsertBatch with its span, if a Tracer is set, a
+// child of the span in ctx.
+func (t *Table) InsertBatchContext(ctx context.Context, b *RowBatch) (int64, error) {
+	_, span := startSpan(ctx, "flintdb.insert_batch", t.path)
+	n, err := t.insertBatch(b)
+	if err != nil {
+		err = &FlintDBError{Message: fmt.Sprintf("row %d: %v", n+1, err)}
+	}
+	if span != nil {
+		span.SetAttribute("flintdb.rows", n)
+		span.End(err)
+	}
+	return n, err
+}
+
+// insertBatch is InsertBatch with the error of the failing row as it is.
+func (t *Table) insertBatch(b *RowBatch) (int64, error) {
+	if b.columns != int(t.meta.columns.length) {
+		return 0, &FlintDBError{Message: fmt.Sprintf("batch has %d columns, table has %d", b.columns, t.meta.columns.length)}
+	}
+	if len(t.computed) == 0 && len(t.collated) == 0 && len(t.checks) == 0 && len(t.foreignKeys) == 0 && len(t.sideIndexes) == 0 && t.history == nil {
+		return t.applyPacked(b.buf, b.rows, b.columns)
+	}
+
+	buf := b.buf
+	var inserted int64
+	for ; inserted < int64(b.rows); inserted++ {
+		row, err := t.CreateRow()
+		if err != nil {
+			return inserted, err
+		}
+		for i := 0; i < b.columns && err == nil; i++ {
+			var v interface{}
+			var set bool
+			v, set, buf = unpack(buf)
+			if set {
+				if err = row.Set(i, v); err != nil {
+					err = &FlintDBError{Message: fmt.Sprintf("column %d: %v", i, err)}
+				}
+			}
+		}
+		if err == nil {
+			_, err = t.Insert(row)
+		}
+		row.Free()
+		if err != nil {
+			return inserted, err
+		}
+	}
+	return inserted, nil
+}
+
+// unpack returns the first value packed in buf and the rest of buf; set is
+// false for a value left at the column default.
+func unpack(buf []byte) (v interface{}, set bool, rest []byte) {
+	tag, buf := buf[0], buf[1:]
+	switch tag {
+	case packedDefault:
+		return nil, false, buf
+	case packedNull:
+		return nil, true, buf
+	case packedInt64:
+		return int64(binary.NativeEndian.Uint64(buf)), true, buf[8:]
+	case packedDouble:
+		return math.Float64frombits(binary.NativeEndian.Uint64(buf)), true, buf[8:]
+	case packedString:
+		n := binary.NativeEndian.Uint32(buf)
+		return string(buf[4 : 4+n]), true, buf[4+n:]
+	case packedBytes:
+		n := binary.NativeEndian.Uint32(buf)
+		return append([]byte(nil), buf[4:4+n]...), true, buf[4+n:]
+	}
+	return time.Unix(int64(binary.NativeEndian.Uint64(buf)), 0), true, buf[8:]
+}
@@ -0,0 +1,256 @@
+// Package bench measures inserts, point reads, range scans, upserts and TSV
+// imports through the wrapper at several row widths, so that changes to the
+// cgo layer can be compared release to release. Run the benchmarks with
+//
+//	flintdb bench [-run regexp] [-benchtime 1s]
+//
+// or from a test of another module, as go test -bench runs them:
+//
+//	func BenchmarkFlintDB(b *testing.B) { bench.Run(b) }
+package bench
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	flintdb "flintdb-tutorial/flintdb"
+	"flintdb-tutorial/flintdb/seed"
+)
+
+// Widths are the row widths, in columns, each benchmark runs at.
+var Widths = []int{4, 16, 64}
+
+// preload is the number of rows the read, scan and upsert benchmarks find
+// in their table.
+const preload = 10000
+
+// scanRows is the number of rows a range scan reads.
+const scanRows = 100
+
+// Benchmark is one benchmark at one row width. Each op is one row, or one
+// range scan of 100 rows.
+type Benchmark struct {
+	Name string // such as "Insert/cols=16"
+	F    func(b *testing.B)
+}
+
+// All returns the benchmarks, each making its tables in a temporary
+// directory of its own.
+func All() []Benchmark {
+	var all []Benchmark
+	for _, w := range Widths {
+		w := w
+		all = append(all,
+			Benchmark{fmt.Sprintf("Insert/cols=%d", w), func(b *testing.B) { insert(b, w) }},
+			Benchmark{fmt.Sprintf("PointRead/cols=%d", w), func(b *testing.B) { pointRead(b, w) }},
+			Benchmark{fmt.Sprintf("RangeScan/cols=%d", w), func(b *testing.B) { rangeScan(b, w) }},
+			Benchmark{fmt.Sprintf("Upsert/cols=%d", w), func(b *testing.B) { upsert(b, w) }},
+			Benchmark{fmt.Sprintf("ImportTSV/cols=%d", w), func(b *testing.B) { importTSV(b, w) }},
+		)
+	}
+	return all
+}
+
+// Run runs All as sub-benchmarks of b.
+func Run(b *testing.B) {
+	for _, bm := range All() {
+		b.Run(bm.Name, bm.F)
+	}
+}
+
+// open creates a table of width columns: an INT64 primary key id followed by
+// INT64, DOUBLE and STRING columns in turn.
+func open(b *testing.B, width int) *flintdb.Table {
+	b.Helper()
+	path := filepath.Join(b.TempDir(), "bench"+flintdb.TABLE_NAME_SUFFIX)
+	meta, err := flintdb.NewMeta(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer meta.Close()
+	if err := meta.AddColumn("id", flintdb.VARIANT_INT64, 0, 0, flintdb.SPEC_NOT_NULL, "0", ""); err != nil {
+		b.Fatal(err)
+	}
+	for i := 1; i < width; i++ {
+		name := fmt.Sprintf("c%d", i)
+		switch i % 3 {
+		case 1:
+			err = meta.AddColumn(name, flintdb.VARIANT_INT64, 0, 0, flintdb.SPEC_NULLABLE, "", "")
+		case 2:
+			err = meta.AddColumn(name, flintdb.VARIANT_DOUBLE, 0, 0, flintdb.SPEC_NULLABLE, "", "")
+		default:
+			err = meta.AddColumn(name, flintdb.VARIANT_STRING, 32, 0, flintdb.SPEC_NULLABLE, "", "")
+		}
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	if err := meta.AddIndex(flintdb.PRIMARY_NAME, []string{"id"}); err != nil {
+		b.Fatal(err)
+	}
+	t, err := flintdb.TableOpen(path, flintdb.FLINTDB_RDWR, meta)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(t.Close)
+	return t
+}
+
+// openSeeded opens a table of width columns holding preload rows, ids 1 to
+// preload, and returns their rowids.
+func openSeeded(b *testing.B, width int) (*flintdb.Table, []int64) {
+	b.Helper()
+	t := open(b, width)
+	if err := seed.Rows(t, preload, seed.Options{Seed: 1}); err != nil {
+		b.Fatal(err)
+	}
+	rowids, err := find(t, "")
+	if err != nil {
+		b.Fatal(err)
+	}
+	return t, rowids
+}
+
+func find(t *flintdb.Table, query string) ([]int64, error) {
+	cursor, err := t.Find(query)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close()
+	var rowids []int64
+	for {
+		rowid, err := cursor.Next()
+		if err != nil || rowid < 0 {
+			return rowids, err
+		}
+		rowids = append(rowids, rowid)
+	}
+}
+
+// fill sets the columns of row from id.
+func fill(row *flintdb.Row, width int, id int64) error {
+	if err := row.Set(0, id); err != nil {
+		return err
+	}
+	for i := 1; i < width; i++ {
+		var v interface{}
+		switch i % 3 {
+		case 1:
+			v = id * int64(i)
+		case 2:
+			v = float64(id) / float64(i)
+		default:
+			v = fmt.Sprintf("value %d of column %d", id, i)
+		}
+		if err := row.Set(i, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func insert(b *testing.B, width int) {
+	t := open(b, width)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		row, err := t.CreateRow()
+		if err == nil {
+			if err = fill(row, width, int64(i)); err == nil {
+				_, err = t.Insert(row)
+			}
+			row.Free()
+		}
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func pointRead(b *testing.B, width int) {
+	t, rowids := openSeeded(b, width)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		row, err := t.Read(rowids[(i*7919)%len(rowids)])
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := row.Get(width - 1); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func rangeScan(b *testing.B, width int) {
+	t, _ := openSeeded(b, width)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		from := 1 + (i*scanRows)%(preload-scanRows)
+		rowids, err := find(t, fmt.Sprintf("WHERE id >= %d LIMIT %d", from, scanRows))
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, rowid := range rowids {
+			if _, err := t.Read(rowid); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// upsert updates the row of an id if there is one and inserts it otherwise,
+// half of the ids being new on the first pass.
+func upsert(b *testing.B, width int) {
+	t, _ := openSeeded(b, width)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		id := int64(1 + i%(2*preload))
+		rowids, err := find(t, fmt.Sprintf("WHERE id = %d LIMIT 1", id))
+		if err != nil {
+			b.Fatal(err)
+		}
+		row, err := t.CreateRow()
+		if err == nil {
+			if err = fill(row, width, id); err == nil {
+				if len(rowids) > 0 {
+					err = t.UpdateAt(rowids[0], row)
+				} else {
+					_, err = t.Insert(row)
+				}
+			}
+			row.Free()
+		}
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func importTSV(b *testing.B, width int) {
+	t := open(b, width)
+	var buf bytes.Buffer
+	for i := 0; i < b.N; i++ {
+		fmt.Fprint(&buf, i)
+		for c := 1; c < width; c++ {
+			switch c % 3 {
+			case 1:
+				fmt.Fprintf(&buf, "\t%d", i*c)
+			case 2:
+				fmt.Fprintf(&buf, "\t%g", float64(i)/float64(c))
+			default:
+				fmt.Fprintf(&buf, "\tvalue %d of column %d", i, c)
+			}
+		}
+		buf.WriteByte('\n')
+	}
+	b.SetBytes(int64(buf.Len() / b.N))
+	b.ResetTimer()
+	n, bad, err := t.Import(&buf, flintdb.FORMAT_TSV, flintdb.ImportOptions{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	if len(bad) > 0 || n != int64(b.N) {
+		b.Fatalf("imported %d of %d rows: %v", n, b.N, bad)
+	}
+}
@@ -0,0 +1,182 @@
+package flintdb
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+	"math"
+)
+
+type bloomDef struct {
+	Expected int64   `json:"expected"`
+	FPRate   float64 `json:"fp_rate"`
+}
+
+// SetBloomFilter keeps a bloom filter over the primary key, sized for
+// expected rows at false-positive rate fpRate, so Table.Exists answers most
+// absent keys from memory. Past expected rows the false-positive rate rises.
+func (m *Meta) SetBloomFilter(expected int64, fpRate float64) error {
+	if expected <= 0 {
+		return &FlintDBError{Message: fmt.Sprintf("invalid expected row count: %d", expected)}
+	}
+	if !(fpRate > 0 && fpRate < 1) {
+		return &FlintDBError{Message: fmt.Sprintf("invalid false-positive rate: %v", fpRate)}
+	}
+	m.ext.Bloom = &bloomDef{Expected: expected, FPRate: fpRate}
+	return nil
+}
+
+// bloomFilter never forgets a key: deleted or updated rows leave their old key
+// set, which costs only a false positive.
+type bloomFilter struct {
+	columns []int
+	hashes  int
+	bits    []uint64
+}
+
+func newBloomFilter(t *Table, def bloomDef) (*bloomFilter, error) {
+	if int(t.meta.indexes.length) == 0 {
+		return nil, &FlintDBError{Message: "bloom filter requires a primary index"}
+	}
+	x := &bloomFilter{}
+	primary := &t.meta.indexes.a[0]
+	for k := 0; k < int(primary.keys.length); k++ {
+		x.columns = append(x.columns, t.columnAt(cstring(primary.keys.a[k][:])))
+	}
+
+	n := float64(def.Expected)
+	bits := math.Ceil(-n * math.Log(def.FPRate) / (math.Ln2 * math.Ln2))
+	x.bits = make([]uint64, int(math.Max(1, math.Ceil(bits/64))))
+	x.hashes = int(math.Min(30, math.Max(1, math.Round(bits/n*math.Ln2))))
+	return x, nil
+}
+
+// positions calls fn with the bit of each hash function for key, derived by
+// double hashing.
+func (x *bloomFilter) positions(key uint64, fn func(word int, mask uint64) bool) {
+	m := uint64(len(x.bits)) * 64
+	h2 := key
+	h2 ^= h2 >> 33
+	h2 *= 0xff51afd7ed558ccd
+	h2 ^= h2 >> 33
+	h2 |= 1
+	for i := 0; i < x.hashes; i++ {
+		bit := (key + uint64(i)*h2) % m
+		if !fn(int(bit/64), 1<<(bit%64)) {
+			return
+		}
+	}
+}
+
+func (x *bloomFilter) add(key uint64) {
+	x.positions(key, func(word int, mask uint64) bool {
+		x.bits[word] |= mask
+		return true
+	})
+}
+
+func (x *bloomFilter) contains(key uint64) bool {
+	found := true
+	x.positions(key, func(word int, mask uint64) bool {
+		found = x.bits[word]&mask != 0
+		return found
+	})
+	return found
+}
+
+func (x *bloomFilter) put(rowid int64, row *Row) error {
+	key, ok, err := hashKey(row, x.columns)
+	if err != nil || !ok {
+		return err
+	}
+	x.add(key)
+	return nil
+}
+
+func (x *bloomFilter) remove(rowid int64) {}
+
+func (x *bloomFilter) encode(w io.Writer) error {
+	return gob.NewEncoder(w).Encode(x.bits)
+}
+
+func (x *bloomFilter) decode(r io.Reader) error {
+	var bits []uint64
+	if err := gob.NewDecoder(r).Decode(&bits); err != nil {
+		return err
+	}
+	if len(bits) != len(x.bits) {
+		return &FlintDBError{Message: "bloom filter size mismatch"}
+	}
+	x.bits = bits
+	return nil
+}
+
+// primaryKey returns the columns of the primary index as callers name them,
+// mapping collation key columns back to the collated column.
+func (t *Table) primaryKey() ([]string, error) {
+	if int(t.meta.indexes.length) == 0 {
+		return nil, &FlintDBError{Message: "table has no primary index"}
+	}
+	primary := &t.meta.indexes.a[0]
+	var columns []string
+	for k := 0; k < int(primary.keys.length); k++ {
+		col := cstring(primary.keys.a[k][:])
+		for _, cc := range t.collated {
+			if collationKeyColumn(cc.column) == col {
+				col = cc.column
+			}
+		}
+		columns = append(columns, col)
+	}
+	return columns, nil
+}
+
+// Exists reports whether a row has the given primary key, one value per key
+// column. With a bloom filter most absent keys are answered without reading
+// the table.
+func (t *Table) Exists(key ...interface{}) (bool, error) {
+	columns, err := t.primaryKey()
+	if err != nil {
+		return false, err
+	}
+	if len(key) != len(columns) {
+		return false, &FlintDBError{Message: fmt.Sprintf("primary key has %d columns, got %d values", len(columns), len(key))}
+	}
+
+	probe, err := t.CreateRow()
+	if err != nil {
+		return false, err
+	}
+	defer probe.Free()
+	for i, col := range columns {
+		if key[i] == nil {
+			return false, nil
+		}
+		if err := probe.SetByName(col, key[i]); err != nil {
+			return false, err
+		}
+	}
+	if err := t.fillCollationKeys(probe); err != nil {
+		return false, err
+	}
+
+	for _, s := range t.sideIndexes {
+		x, ok := s.index.(*bloomFilter)
+		if !ok {
+			continue
+		}
+		h, _, err := hashKey(probe, x.columns)
+		if err != nil {
+			return false, err
+		}
+		s.mu.RLock()
+		hit := x.contains(h)
+		s.mu.RUnlock()
+		if !hit {
+			return false, nil
+		}
+	}
+
+	rowid, err := t.lookup(0, probe)
+	return rowid >= 0, err
+}
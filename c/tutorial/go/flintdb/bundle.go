@@ -0,0 +1,30 @@
+//go:build !flintdb_lib
+
+package flintdb
+
+// The engine is compiled into the package from the copy of its sources in
+// csrc, so that go get needs no prebuilt library, only a C compiler and
+// zlib. Build with -tags flintdb_lib to link the library make builds in
+// c/lib instead; see lib.go. After changing c/src, refresh the copy with go
+// generate.
+//
+// On Windows the C compiler is MinGW gcc from MSYS2 UCRT64, with the zlib
+// and dlfcn packages, as .github/workflows/build.yml installs them.
+//
+// Compression with zstd or lz4 is off unless CGO_CFLAGS and CGO_LDFLAGS
+// add it, as in CGO_CFLAGS=-DHAVE_ZSTD CGO_LDFLAGS=-lzstd.
+
+/*
+#cgo CFLAGS: -I${SRCDIR}/csrc -D_GNU_SOURCE -DNDEBUG -DEMBED_HTML
+#cgo !windows CFLAGS: -std=c2x
+#cgo windows CFLAGS: -std=c11 -D_POSIX_=1 -DPATH_MAX=4096
+#cgo CFLAGS: -DVARIANT_USE_STRPOOL -DVARIANT_STRPOOL_STR_SIZE=1024u -DVARIANT_STRPOOL_CAPACITY=1024u -DSTORAGE_DIO_USE_BUFFER_POOL=64u
+#cgo CFLAGS: -Wno-format -Wno-unused-parameter -Wno-strict-aliasing
+#cgo linux CFLAGS: -Dlinux
+#cgo LDFLAGS: -lm -lpthread -lz
+#cgo linux LDFLAGS: -ldl
+#cgo windows LDFLAGS: -lws2_32 -ldl
+*/
+import "C"
+
+//go:generate sh csrc/sync.sh
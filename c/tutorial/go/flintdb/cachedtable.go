@@ -0,0 +1,119 @@
+package flintdb
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+)
+
+// CachedTable serves point reads of a table's rows, decoded into T, from an
+// LRU cache of a bounded number of rows, so hot rows skip both the engine and
+// decoding. Inserts, updates and deletes through the table evict the rows
+// they write; writes through other handles are not seen. Get may be called
+// from several goroutines; the other methods of the table still need one at
+// a time.
+type CachedTable[T any] struct {
+	table  *Table
+	decode func(*Row) (T, error)
+	rows   int
+
+	mu     sync.Mutex
+	order  *list.List // of *cachedRow[T], most recently used first
+	byRow  map[int64]*list.Element
+	hits   int64
+	misses int64
+}
+
+type cachedRow[T any] struct {
+	rowid int64
+	value T
+}
+
+// NewCachedTable caches up to rows rows of t, decoded by decode. The value
+// Get returns is shared by every reader of the row, so T should be a value
+// type, or a pointer to a value callers do not change.
+func NewCachedTable[T any](t *Table, rows int, decode func(*Row) (T, error)) (*CachedTable[T], error) {
+	if rows <= 0 {
+		return nil, &FlintDBError{Message: fmt.Sprintf("cache size must be positive, got %d", rows)}
+	}
+	c := &CachedTable[T]{table: t, decode: decode, rows: rows, order: list.New(), byRow: map[int64]*list.Element{}}
+	t.rowCaches = append(t.rowCaches, c)
+	return c, nil
+}
+
+// Table returns the table c reads.
+func (c *CachedTable[T]) Table() *Table {
+	return c.table
+}
+
+// Get returns the row at rowid, decoded.
+func (c *CachedTable[T]) Get(rowid int64) (T, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.byRow[rowid]; ok {
+		c.hits++
+		c.order.MoveToFront(el)
+		return el.Value.(*cachedRow[T]).value, nil
+	}
+	c.misses++
+	var zero T
+	row, err := c.table.Read(rowid)
+	if err != nil {
+		return zero, err
+	}
+	value, err := c.decode(row)
+	row.Free()
+	if err != nil {
+		return zero, err
+	}
+	c.byRow[rowid] = c.order.PushFront(&cachedRow[T]{rowid: rowid, value: value})
+	for c.order.Len() > c.rows {
+		last := c.order.Back()
+		delete(c.byRow, last.Value.(*cachedRow[T]).rowid)
+		c.order.Remove(last)
+	}
+	return value, nil
+}
+
+// Stats returns the hits and misses of Get so far.
+func (c *CachedTable[T]) Stats() (hits, misses int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits, c.misses
+}
+
+// Close detaches c from the table and drops the cached rows.
+func (c *CachedTable[T]) Close() {
+	caches := c.table.rowCaches[:0]
+	for _, x := range c.table.rowCaches {
+		if x != rowEvicter(c) {
+			caches = append(caches, x)
+		}
+	}
+	c.table.rowCaches = caches
+	c.mu.Lock()
+	c.order.Init()
+	clear(c.byRow)
+	c.mu.Unlock()
+}
+
+func (c *CachedTable[T]) evict(rowid int64) {
+	c.mu.Lock()
+	if el, ok := c.byRow[rowid]; ok {
+		delete(c.byRow, rowid)
+		c.order.Remove(el)
+	}
+	c.mu.Unlock()
+}
+
+// rowEvicter is a cache of rows a write must evict from.
+type rowEvicter interface {
+	evict(rowid int64)
+}
+
+// evictRow runs after a write of the row at rowid.
+func (t *Table) evictRow(rowid int64) {
+	for _, c := range t.rowCaches {
+		c.evict(rowid)
+	}
+}
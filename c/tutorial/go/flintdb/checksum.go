@@ -0,0 +1,58 @@
+package flintdb
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Row checksums for Meta.SetChecksum.
+const (
+	CHECKSUM_NONE   = ""
+	CHECKSUM_CRC32  = "crc32"
+	CHECKSUM_XXHASH = "xxhash"
+)
+
+// ErrChecksum matches every ChecksumError with errors.Is.
+var ErrChecksum = &FlintDBError{Message: "checksum mismatch"}
+
+// ChecksumError reports a row whose bytes no longer match the checksum stored
+// with them, as after corruption on disk.
+type ChecksumError struct {
+	RowID  int64
+	Detail string // the engine's message
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("FlintDB error: checksum mismatch at rowid %d", e.RowID)
+}
+
+func (e *ChecksumError) Is(target error) bool {
+	return target == ErrChecksum
+}
+
+// SetChecksum stores a checksum with each row of a table, verified whenever
+// the row is read, so that a corrupted row fails with a ChecksumError instead
+// of decoding into wrong values. It costs four bytes a row. The checksum is
+// saved with the schema and cannot change once rows exist.
+func (m *Meta) SetChecksum(name string) error {
+	switch name {
+	case CHECKSUM_NONE, CHECKSUM_CRC32, CHECKSUM_XXHASH:
+	default:
+		return &FlintDBError{Message: fmt.Sprintf("unknown checksum: %s", name)}
+	}
+	copyCString(m.inner.checksum[:], name)
+	return nil
+}
+
+const checksumMismatch = "checksum mismatch at rowid "
+
+// checksumError returns the ChecksumError an engine message reports, or nil.
+func checksumError(msg string) error {
+	i := strings.Index(msg, checksumMismatch)
+	if i < 0 {
+		return nil
+	}
+	e := &ChecksumError{Detail: msg}
+	fmt.Sscanf(msg[i+len(checksumMismatch):], "%d", &e.RowID)
+	return e
+}
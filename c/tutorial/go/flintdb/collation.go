@@ -0,0 +1,313 @@
+package flintdb
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+// Collation defines how values of a STRING column are ordered and compared.
+// The engine itself only compares bytes, so a non-binary collation is
+// implemented with a hidden key column holding Key(value): indexes on the
+// column are built on the key and WHERE comparisons against it are rewritten
+// to use the key.
+type Collation struct {
+	name string
+	key  func(s string) string
+}
+
+var (
+	// CollationBinary compares strings byte by byte. It is the default.
+	CollationBinary = Collation{name: "binary"}
+	// CollationNoCase compares strings after Unicode case folding.
+	CollationNoCase = Collation{name: "nocase", key: foldCase}
+)
+
+// foldCase applies Unicode case folding. A cases.Caser holds state, so one
+// is made per call rather than shared.
+func foldCase(s string) string {
+	return cases.Fold().String(s)
+}
+
+// CollationLocale returns a collation following the rules of a BCP 47
+// language tag such as "de", "sv" or "fr-CA".
+func CollationLocale(tag string) (Collation, error) {
+	t, err := language.Parse(tag)
+	if err != nil {
+		return Collation{}, &FlintDBError{Message: fmt.Sprintf("invalid collation locale %q: %v", tag, err)}
+	}
+	// A collate.Collator is not safe for concurrent use.
+	var mu sync.Mutex
+	var buf collate.Buffer
+	c := collate.New(t)
+	return Collation{
+		name: "locale:" + t.String(),
+		key: func(s string) string {
+			mu.Lock()
+			defer mu.Unlock()
+			buf.Reset()
+			// Hex keeps the byte order of the sort key while avoiding the NUL
+			// bytes it contains, which a STRING column cannot store.
+			return hex.EncodeToString(c.KeyFromString(&buf, s))
+		},
+	}, nil
+}
+
+// ParseCollation returns the collation named by Name: "binary", "nocase" or
+// "locale:<tag>".
+func ParseCollation(name string) (Collation, error) {
+	switch {
+	case name == "" || name == CollationBinary.name:
+		return CollationBinary, nil
+	case name == CollationNoCase.name:
+		return CollationNoCase, nil
+	case strings.HasPrefix(name, "locale:"):
+		return CollationLocale(strings.TrimPrefix(name, "locale:"))
+	}
+	return Collation{}, &FlintDBError{Message: fmt.Sprintf("unknown collation: %s", name)}
+}
+
+func (c Collation) Name() string {
+	return c.name
+}
+
+// Key returns the binary-comparable form of s under c.
+func (c Collation) Key(s string) string {
+	if c.key == nil {
+		return s
+	}
+	return c.key(s)
+}
+
+// Compare returns -1, 0 or 1 as a sorts before, equal to or after b.
+func (c Collation) Compare(a, b string) int {
+	return strings.Compare(c.Key(a), c.Key(b))
+}
+
+// keyBytes is the size of the key column needed for values of n bytes.
+func (c Collation) keyBytes(n int) int {
+	switch {
+	case c.key == nil:
+		return n
+	case c.name == CollationNoCase.name:
+		return n * 3 // folding may expand a character, e.g. U+1E9E to "ss"
+	default:
+		return n*12 + 16 // hex of three weight levels plus their separators
+	}
+}
+
+// collationKeyColumn names the hidden column holding the keys of col.
+func collationKeyColumn(col string) string {
+	return "__" + col
+}
+
+// The collation is recorded in the comment of the key column, so it is
+// stored in the table's .desc file and restored by TableOpen.
+const collationCommentPrefix = "COLLATE "
+
+// collationOf parses the collation recorded by a column comment.
+func collationOf(name, comment string) (string, Collation, bool, error) {
+	if !strings.HasPrefix(name, "__") || !strings.HasPrefix(comment, collationCommentPrefix) {
+		return "", Collation{}, false, nil
+	}
+	c, err := ParseCollation(strings.TrimPrefix(comment, collationCommentPrefix))
+	return strings.TrimPrefix(name, "__"), c, err == nil, err
+}
+
+// SetCollation sets the collation of the STRING column col. Call it after the
+// column is declared; indexes on col, declared before or after, are built on
+// the collation key.
+func (m *Meta) SetCollation(col string, c Collation) error {
+	idx := m.ColumnAt(col)
+	if idx < 0 {
+		return &FlintDBError{Message: fmt.Sprintf("column not found: %s", col)}
+	}
+	column := &m.inner.columns.a[idx]
+	if int(column._type) != VARIANT_STRING {
+		return &FlintDBError{Message: fmt.Sprintf("collation requires a STRING column: %s", col)}
+	}
+
+	keyCol := collationKeyColumn(col)
+	if len(keyCol) >= MAX_COLUMN_NAME_LIMIT {
+		return &FlintDBError{Message: fmt.Sprintf("column name too long for collation: %s", col)}
+	}
+	comment := collationCommentPrefix + c.Name()
+	if len(comment) >= MAX_COLUMN_NAME_LIMIT {
+		return &FlintDBError{Message: fmt.Sprintf("collation name too long: %s", c.Name())}
+	}
+
+	keyIdx := m.ColumnAt(keyCol)
+	if keyIdx >= 0 {
+		if cstring(m.inner.columns.a[keyIdx].comment[:]) != comment {
+			return &FlintDBError{Message: fmt.Sprintf("collation of %s is already set", col)}
+		}
+		return nil
+	}
+	if c.key == nil {
+		return nil
+	}
+	if err := m.AddColumn(keyCol, VARIANT_STRING, c.keyBytes(int(column.bytes)), 0, uint32(column.nullspec), "", comment); err != nil {
+		return err
+	}
+	m.renameIndexKeys(col, keyCol)
+	return nil
+}
+
+func (m *Meta) renameIndexKeys(from, to string) {
+	for i := 0; i < int(m.inner.indexes.length); i++ {
+		keys := &m.inner.indexes.a[i].keys
+		for k := 0; k < int(keys.length); k++ {
+			if cstring(keys.a[k][:]) == from {
+				copyCString(keys.a[k][:], to)
+			}
+		}
+	}
+	for _, def := range m.ext.Hash {
+		for k, col := range def.Columns {
+			if strings.EqualFold(col, from) {
+				def.Columns[k] = to
+			}
+		}
+	}
+}
+
+// indexColumn maps a column named in an index to the column actually indexed.
+func (m *Meta) indexColumn(col string) string {
+	keyIdx := m.ColumnAt(collationKeyColumn(col))
+	if keyIdx < 0 {
+		return col
+	}
+	key := &m.inner.columns.a[keyIdx]
+	if _, _, ok, _ := collationOf(cstring(key.name[:]), cstring(key.comment[:])); ok {
+		return collationKeyColumn(col)
+	}
+	return col
+}
+
+type collatedColumn struct {
+	column    string
+	index     int
+	keyIndex  int
+	collation Collation
+}
+
+func (t *Table) loadCollations() error {
+	for i := 0; i < int(t.meta.columns.length); i++ {
+		key := &t.meta.columns.a[i]
+		col, c, ok, err := collationOf(cstring(key.name[:]), cstring(key.comment[:]))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		idx := t.columnAt(col)
+		if idx < 0 {
+			return &FlintDBError{Message: fmt.Sprintf("collation column not found: %s", col)}
+		}
+		t.collated = append(t.collated, collatedColumn{column: col, index: idx, keyIndex: i, collation: c})
+	}
+	return nil
+}
+
+// Collation returns the collation of col.
+func (t *Table) Collation(col string) Collation {
+	if cc := t.collatedColumn(col); cc != nil {
+		return cc.collation
+	}
+	return CollationBinary
+}
+
+func (t *Table) collatedColumn(col string) *collatedColumn {
+	for i := range t.collated {
+		if t.collated[i].column == col {
+			return &t.collated[i]
+		}
+	}
+	return nil
+}
+
+// fillCollationKeys runs after computed columns so keys reflect final values.
+func (t *Table) fillCollationKeys(row *Row) error {
+	for _, cc := range t.collated {
+		null, err := row.IsNull(cc.index)
+		if err != nil {
+			return err
+		}
+		if null {
+			if err := row.Set(cc.keyIndex, nil); err != nil {
+				return err
+			}
+			continue
+		}
+		value, err := row.GetString(cc.index)
+		if err != nil {
+			return err
+		}
+		if err := row.Set(cc.keyIndex, cc.collation.Key(value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rewriteCollated redirects "<col> <op> '<literal>'" terms on collated columns
+// to the key column. LIKE is redirected only for nocase, whose keys keep the
+// characters of the pattern; other terms are left for the engine as written.
+func (t *Table) rewriteCollated(query string) string {
+	if len(t.collated) == 0 {
+		return query
+	}
+	tokens, err := tokenizeExpr(query)
+	if err != nil {
+		return query
+	}
+
+	var b strings.Builder
+	last := 0
+	for i := 0; i+2 < len(tokens); i++ {
+		col, op, lit := tokens[i], tokens[i+1], tokens[i+2]
+		if col.kind != tokenIdent || lit.kind != tokenString {
+			continue
+		}
+		cc := t.collatedColumn(col.text)
+		if cc == nil {
+			continue
+		}
+		if !isComparison(op) && !(isLike(op) && cc.collation.name == CollationNoCase.name) {
+			continue
+		}
+		quoted, err := quoteString(cc.collation.Key(lit.text))
+		if err != nil {
+			continue
+		}
+		b.WriteString(query[last:col.pos])
+		b.WriteString(collationKeyColumn(col.text))
+		b.WriteString(query[col.end:lit.pos])
+		b.WriteString(quoted)
+		last = lit.end
+		i += 2
+	}
+	b.WriteString(query[last:])
+	return b.String()
+}
+
+func isComparison(tok exprToken) bool {
+	if tok.kind != tokenSymbol {
+		return false
+	}
+	switch tok.text {
+	case "=", "==", "!=", "<>", "<", "<=", ">", ">=":
+		return true
+	}
+	return false
+}
+
+func isLike(tok exprToken) bool {
+	return tok.kind == tokenIdent && strings.EqualFold(tok.text, "LIKE")
+}
@@ -0,0 +1,46 @@
+package flintdb
+
+import "fmt"
+
+type computedColumn struct {
+	column  string
+	index   int
+	compute func(row *Row) (interface{}, error)
+}
+
+// AddComputedColumn makes col a generated column: on Insert and UpdateAt its
+// value is replaced by fn(row) before constraints run and the row is stored.
+// The column is declared in Meta like any other, so it can be indexed.
+func (t *Table) AddComputedColumn(col string, fn func(row *Row) (interface{}, error)) error {
+	idx := t.columnAt(col)
+	if idx < 0 {
+		return &FlintDBError{Message: fmt.Sprintf("column not found: %s", col)}
+	}
+	t.computed = append(t.computed, computedColumn{column: col, index: idx, compute: fn})
+	return nil
+}
+
+// AddComputedColumnExpr is AddComputedColumn with an expression such as
+// "lower(name)" or "price * quantity".
+func (t *Table) AddComputedColumnExpr(col string, expression string) error {
+	x, err := parseExpr(expression, t.columnAt)
+	if err != nil {
+		return err
+	}
+	return t.AddComputedColumn(col, x.eval)
+}
+
+// computeColumns runs in registration order, so a computed column may use the
+// value of one registered before it.
+func (t *Table) computeColumns(row *Row) error {
+	for _, c := range t.computed {
+		value, err := c.compute(row)
+		if err != nil {
+			return fmt.Errorf("computed column %s: %w", c.column, err)
+		}
+		if err := row.Set(c.index, value); err != nil {
+			return fmt.Errorf("computed column %s: %w", c.column, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package flintdb
+
+import "fmt"
+
+// ErrConstraint matches every ConstraintError with errors.Is.
+var ErrConstraint = &FlintDBError{Message: "constraint violation"}
+
+// ConstraintError reports a row rejected by a CHECK or FOREIGN KEY constraint.
+type ConstraintError struct {
+	Constraint string      // constraint name
+	Column     string      // offending column, empty for row-level checks
+	Value      interface{} // offending value, nil for row-level checks
+	Detail     string
+}
+
+func (e *ConstraintError) Error() string {
+	if e.Column != "" {
+		return fmt.Sprintf("FlintDB error: constraint %s violated: %s=%v: %s", e.Constraint, e.Column, e.Value, e.Detail)
+	}
+	return fmt.Sprintf("FlintDB error: constraint %s violated: %s", e.Constraint, e.Detail)
+}
+
+func (e *ConstraintError) Is(target error) bool {
+	return target == ErrConstraint
+}
+
+type check struct {
+	name   string
+	column string // empty for row-level checks
+	eval   func(row *Row) (bool, error)
+	detail string
+}
+
+// AddCheck registers a row-level predicate evaluated on Insert and UpdateAt.
+// The write is rejected with a ConstraintError when fn returns false.
+func (t *Table) AddCheck(name string, fn func(row *Row) (bool, error)) {
+	t.checks = append(t.checks, check{name: name, eval: fn, detail: "check failed"})
+}
+
+// AddColumnCheck registers a predicate on the value of a single column.
+// NULL values are passed to fn as nil.
+func (t *Table) AddColumnCheck(name string, col string, fn func(value interface{}) bool) error {
+	idx := t.columnAt(col)
+	if idx < 0 {
+		return &FlintDBError{Message: fmt.Sprintf("column not found: %s", col)}
+	}
+	t.checks = append(t.checks, check{
+		name:   name,
+		column: col,
+		eval: func(row *Row) (bool, error) {
+			value, err := row.Get(idx)
+			if err != nil {
+				return false, err
+			}
+			return fn(value), nil
+		},
+		detail: "check failed",
+	})
+	return nil
+}
+
+// AddCheckExpr registers a CHECK constraint written as an expression, e.g.
+// "age >= 0 AND age < 150" or "length(name) > 0". As in SQL, a row passes
+// when the expression is true or NULL.
+func (t *Table) AddCheckExpr(name string, expression string) error {
+	x, err := parseExpr(expression, t.columnAt)
+	if err != nil {
+		return err
+	}
+	t.checks = append(t.checks, check{
+		name: name,
+		eval: func(row *Row) (bool, error) {
+			v, err := x.eval(row)
+			if err != nil {
+				return false, err
+			}
+			if v == nil {
+				return true, nil
+			}
+			b, ok := v.(bool)
+			if !ok {
+				return false, &FlintDBError{Message: fmt.Sprintf("check %s: expression is not boolean: %v", name, v)}
+			}
+			return b, nil
+		},
+		detail: expression,
+	})
+	return nil
+}
+
+func (t *Table) runChecks(row *Row) error {
+	for _, c := range t.checks {
+		ok, err := c.eval(row)
+		if err != nil {
+			return err
+		}
+		if ok {
+			continue
+		}
+
+		cerr := &ConstraintError{Constraint: c.name, Column: c.column, Detail: c.detail}
+		if c.column != "" {
+			cerr.Value, _ = row.GetByName(c.column)
+		}
+		return cerr
+	}
+	return nil
+}
+
+// beforeWrite fills computed columns and validates row against the table's
+// constraints before it is applied.
+func (t *Table) beforeWrite(row *Row) error {
+	if err := t.computeColumns(row); err != nil {
+		return err
+	}
+	if err := t.fillCollationKeys(row); err != nil {
+		return err
+	}
+	if err := t.runChecks(row); err != nil {
+		return err
+	}
+	return t.checkForeignKeys(row)
+}
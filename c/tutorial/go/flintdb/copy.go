@@ -0,0 +1,400 @@
+package flintdb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// copyBatchSize is the number of rows CopyRows converts before inserting them.
+const copyBatchSize = 1000
+
+// CopyRows inserts the rows of src matching query into dst and returns how
+// many were inserted. Columns are matched by name, ignoring case; source
+// columns missing from dst are skipped and dst columns missing from src keep
+// their defaults. Text values are parsed to the dst column type as Import
+// does. The first failing row stops the copy.
+func CopyRows(dst *Table, src *GenericFile, query string) (int64, error) {
+	type mapping struct {
+		from int
+		to   exportColumn
+	}
+	var columns []mapping
+	for _, c := range dst.exportColumns() {
+		for i := 0; i < int(src.meta.columns.length); i++ {
+			if strings.EqualFold(cstring(src.meta.columns.a[i].name[:]), c.name) {
+				columns = append(columns, mapping{from: i, to: c})
+				break
+			}
+		}
+	}
+	if len(columns) == 0 {
+		return 0, &FlintDBError{Message: "no columns in common"}
+	}
+
+	cursor, err := src.Find(query)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close()
+
+	var copied int64
+	batch := make([]*Row, 0, copyBatchSize)
+	defer func() {
+		for _, row := range batch {
+			row.Free()
+		}
+	}()
+	flush := func() error {
+		for _, row := range batch {
+			if _, err := dst.Insert(row); err != nil {
+				return &FlintDBError{Message: fmt.Sprintf("row %d: %v", copied+1, err)}
+			}
+			copied++
+		}
+		for _, row := range batch {
+			row.Free()
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	for {
+		in, err := cursor.Next()
+		if err != nil {
+			return copied, err
+		}
+		if in == nil {
+			break
+		}
+		out, err := dst.CreateRow()
+		if err != nil {
+			return copied, err
+		}
+		batch = append(batch, out)
+		for _, m := range columns {
+			v, err := in.Get(m.from)
+			if err == nil {
+				v, err = coerceValue(v, int(src.meta.columns.a[m.from]._type), m.to.kind)
+			}
+			if err == nil {
+				err = out.Set(m.to.index, v)
+			}
+			if err != nil {
+				return copied, &FlintDBError{Message: fmt.Sprintf("row %d: %s: %v", copied+int64(len(batch)), m.to.name, err)}
+			}
+		}
+		if len(batch) == copyBatchSize {
+			if err := flush(); err != nil {
+				return copied, err
+			}
+		}
+	}
+	return copied, flush()
+}
+
+// CopyTable creates a table at dstPath with the schema of src, its indexes
+// and the settings kept with it, and inserts the rows of src passed through
+// transform, returning how many were inserted. transform gets a copy of each
+// row it may change, and returns the row to insert, it or another of the same
+// columns that stays the transform's to free, with true, or false to leave
+// the row out. A nil transform copies every row. The first error stops the
+// copy and removes the new table; dstPath must not hold a table.
+func CopyTable(src *Table, dstPath string, transform func(*Row) (*Row, bool, error)) (int64, error) {
+	return CopyTableContext(context.Background(), src, dstPath, transform)
+}
+
+// CopyTableContext is CopyTable stopped when ctx ends, with its progress
+// reported to the ProgressFunc of ctx.
+func CopyTableContext(ctx context.Context, src *Table, dstPath string, transform func(*Row) (*Row, bool, error)) (int64, error) {
+	if err := checkNoTable(dstPath); err != nil {
+		return 0, err
+	}
+	ext, _, err := readMetaExt(src.path)
+	if err != nil {
+		return 0, err
+	}
+	// the copy of src's meta is never closed; the table keeps its own
+	dst, err := TableOpenContext(ctx, dstPath, FLINTDB_RDWR, &Meta{inner: *src.meta, ext: ext})
+	if err != nil {
+		return 0, err
+	}
+	copied, err := copyTable(ctx, src, dst, transform)
+	dst.Close()
+	if err != nil {
+		TableDrop(dstPath)
+	}
+	return copied, err
+}
+
+func copyTable(ctx context.Context, src, dst *Table, transform func(*Row) (*Row, bool, error)) (int64, error) {
+	total, err := src.Rows()
+	if err != nil {
+		return 0, err
+	}
+	prog := progressOf(ctx, "copy", src.path, total, -1)
+	defer prog.done()
+	cursor, err := src.FindContext(ctx, "")
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close()
+
+	var copied int64
+	columns := int(src.meta.columns.length)
+	for {
+		rowid, err := cursor.Next()
+		if err != nil {
+			return copied, err
+		}
+		if rowid < 0 {
+			return copied, nil
+		}
+		if err := ctx.Err(); err != nil {
+			return copied, err
+		}
+		in, err := src.Read(rowid)
+		if err != nil {
+			return copied, &FlintDBError{Message: fmt.Sprintf("rowid %d: %v", rowid, err)}
+		}
+		row, err := dst.CreateRow()
+		if err != nil {
+			return copied, err
+		}
+		for i := 0; i < columns && err == nil; i++ {
+			var v interface{}
+			if v, err = in.Get(i); err == nil {
+				err = row.Set(i, v)
+			}
+		}
+		out, keep := row, true
+		if err == nil && transform != nil {
+			out, keep, err = transform(row)
+		}
+		if err == nil && keep {
+			if out == nil {
+				err = &FlintDBError{Message: "transform returned no row"}
+			} else if _, err = dst.Insert(out); err == nil {
+				copied++
+			}
+		}
+		row.Free()
+		if err != nil {
+			return copied, &FlintDBError{Message: fmt.Sprintf("rowid %d: %v", rowid, err)}
+		}
+		prog.add(1, 0)
+	}
+}
+
+// coerceValue converts a value read from a column of kind from for a column
+// of kind to in another schema. Strings are parsed and other values written
+// as text for a string column, as Import and Export do; the rest is left to
+// Row.Set's casts.
+func coerceValue(v interface{}, from, to int) (interface{}, error) {
+	switch x := v.(type) {
+	case nil:
+	case string:
+		if to != VARIANT_BYTES {
+			return importText(x, to)
+		}
+	default:
+		if to == VARIANT_STRING {
+			return exportText(v, from), nil
+		}
+	}
+	return v, nil
+}
+
+// FileMeta returns a GenericFile schema for path with the table's columns,
+// without collation keys, and the delimiter of the path's format. For a
+// .parquet path the types are those Parquet files hold (see parquetType).
+func (t *Table) FileMeta(path string) (*Meta, error) {
+	m, err := NewMeta(path)
+	if err != nil {
+		return nil, err
+	}
+	parquet := strings.HasSuffix(path, ".parquet")
+	for _, c := range t.exportColumns() {
+		col := &t.meta.columns.a[c.index]
+		kind, size := c.kind, int(col.bytes)
+		if parquet {
+			kind, size = parquetType(kind, size)
+		}
+		err := m.AddColumn(c.name, kind, size, int(col.precision), uint32(col.nullspec), cstring(col.value[:]), cstring(col.comment[:]))
+		if err != nil {
+			m.Close()
+			return nil, err
+		}
+	}
+	switch {
+	case parquet:
+	case strings.HasSuffix(path, ".csv") || strings.HasSuffix(path, ".csv.gz") || strings.HasSuffix(path, ".csv.zst"):
+		m.SetFormatCSV()
+	default:
+		m.SetFormatTSV()
+	}
+	return m, nil
+}
+
+// ExportTo writes the rows of the table matching query to f and returns how
+// many were written. Columns are matched by name as in CopyRows; use
+// FileMeta to create f with the table's schema.
+func (t *Table) ExportTo(f *GenericFile, query string) (int64, error) {
+	type mapping struct {
+		from     int
+		to       int
+		fromKind int
+		kind     int
+	}
+	var columns []mapping
+	for _, c := range t.exportColumns() {
+		for i := 0; i < int(f.meta.columns.length); i++ {
+			if strings.EqualFold(cstring(f.meta.columns.a[i].name[:]), c.name) {
+				columns = append(columns, mapping{from: c.index, to: i, fromKind: c.kind, kind: int(f.meta.columns.a[i]._type)})
+				break
+			}
+		}
+	}
+	if len(columns) == 0 {
+		return 0, &FlintDBError{Message: "no columns in common"}
+	}
+
+	cursor, err := t.Find(query)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close()
+	out, err := f.CreateRow()
+	if err != nil {
+		return 0, err
+	}
+	defer out.Free()
+
+	var written int64
+	for {
+		rowid, err := cursor.Next()
+		if err != nil {
+			return written, err
+		}
+		if rowid < 0 {
+			return written, nil
+		}
+		in, err := t.Read(rowid)
+		if err != nil {
+			return written, err
+		}
+		for _, m := range columns {
+			v, err := in.Get(m.from)
+			if err == nil {
+				v, err = coerceValue(v, m.fromKind, m.kind)
+			}
+			if err == nil {
+				err = out.Set(m.to, v)
+			}
+			if err != nil {
+				return written, &FlintDBError{Message: fmt.Sprintf("rowid %d: %s: %v", rowid, cstring(f.meta.columns.a[m.to].name[:]), err)}
+			}
+		}
+		if err := f.Write(out); err != nil {
+			return written, err
+		}
+		written++
+	}
+}
+
+// BuildSorted inserts the rows of src into dst in the order of keys, dst
+// columns named as in NewFilesort, or of dst's primary index when no keys
+// are given, and returns how many were inserted. The rows are sorted in a
+// temporary file first, so the table and its primary index are written in
+// key order, which is much faster than inserting rows in random order.
+// Columns are matched and converted as in CopyRows. The first failing row
+// stops the build.
+func BuildSorted(dst *Table, src *CursorRow, keys ...string) (int64, error) {
+	type mapping struct {
+		from int
+		to   exportColumn
+	}
+	var columns []mapping
+	for _, c := range dst.exportColumns() {
+		for i := 0; i < int(src.meta.columns.length); i++ {
+			if strings.EqualFold(cstring(src.meta.columns.a[i].name[:]), c.name) {
+				columns = append(columns, mapping{from: i, to: c})
+				break
+			}
+		}
+	}
+	if len(columns) == 0 {
+		return 0, &FlintDBError{Message: "no columns in common"}
+	}
+	if len(keys) == 0 {
+		if int(dst.meta.indexes.length) == 0 {
+			return 0, &FlintDBError{Message: "table has no primary index"}
+		}
+		// the index's own columns, so collated keys sort as the index does
+		primary := &dst.meta.indexes.a[0]
+		for k := 0; k < int(primary.keys.length); k++ {
+			keys = append(keys, cstring(primary.keys.a[k][:]))
+		}
+	}
+
+	// the sorter's rows must have dst's layout; the copy is never closed
+	sorter, err := NewFilesort("", &Meta{inner: *dst.meta}, keys...)
+	if err != nil {
+		return 0, err
+	}
+	defer sorter.Close()
+	var read int64
+	for {
+		in, err := src.Next()
+		if err != nil {
+			return 0, err
+		}
+		if in == nil {
+			break
+		}
+		read++
+		out, err := sorter.CreateRow()
+		if err != nil {
+			return 0, err
+		}
+		for _, m := range columns {
+			v, err := in.Get(m.from)
+			if err == nil {
+				v, err = coerceValue(v, int(src.meta.columns.a[m.from]._type), m.to.kind)
+			}
+			if err == nil {
+				err = out.Set(m.to.index, v)
+			}
+			if err != nil {
+				out.Free()
+				return 0, &FlintDBError{Message: fmt.Sprintf("row %d: %s: %v", read, m.to.name, err)}
+			}
+		}
+		// fills computed and collation key columns before they are sorted on
+		err = dst.beforeWrite(out)
+		if err == nil {
+			err = sorter.Add(out)
+		}
+		out.Free()
+		if err != nil {
+			return 0, &FlintDBError{Message: fmt.Sprintf("row %d: %v", read, err)}
+		}
+	}
+	if err := sorter.Sort(); err != nil {
+		return 0, err
+	}
+
+	var inserted int64
+	for ; inserted < sorter.Rows(); inserted++ {
+		row, err := sorter.Read(inserted)
+		if err != nil {
+			return inserted, err
+		}
+		_, err = dst.Insert(row)
+		row.Free()
+		if err != nil {
+			return inserted, &FlintDBError{Message: fmt.Sprintf("row %d in key order: %v", inserted+1, err)}
+		}
+	}
+	return inserted, nil
+}
@@ -0,0 +1,284 @@
+#include <stdio.h>
+#include <string.h>
+#include <stdlib.h>
+#include <assert.h>
+#include <strings.h>
+#include <zlib.h>
+#ifdef HAVE_LZ4
+#include <lz4.h>
+#endif
+#ifdef HAVE_ZSTD
+#include <zstd.h>
+#endif
+// #include <snappy-c.h>
+#include "flintdb.h"
+#include "internal.h"
+
+#define Z_DEF_MEM_LEVEL 8
+#define FORMAT_Z       1
+#define FORMAT_LZ4     2
+#define FORMAT_ZSTD    3
+// #define FORMAT_SNAPPY  4
+
+
+i32 compress_z(const char *in, const i32 len, char *out, i32 out_len, char **e) {
+    z_stream deflator;
+    deflator.zalloc = Z_NULL;
+    deflator.zfree = Z_NULL;
+    deflator.opaque = Z_NULL;
+
+    deflator.avail_in = (uInt)len;
+    deflator.next_in = (Bytef *)in;
+    deflator.avail_out = (uInt)out_len;
+    deflator.next_out = (Bytef *)out;
+
+    int nowrap = 1;
+    int windowBits = nowrap ? -MAX_WBITS : MAX_WBITS;
+    int ok = deflateInit2(&deflator, Z_DEFAULT_COMPRESSION, Z_DEFLATED, windowBits, Z_DEF_MEM_LEVEL, Z_DEFAULT_STRATEGY);
+    // int ok = deflateInit(&deflator, Z_DEFAULT_COMPRESSION);
+    assert(ok == Z_OK);
+    if (Z_OK == ok) {
+        deflate(&deflator, Z_FINISH);
+        deflateEnd(&deflator);
+    }
+    return deflator.total_out;
+}
+
+i32 decompress_z(const char *in, const i32 len, char *out, i32 out_len, char **e) {
+    z_stream inflator;
+    inflator.zalloc = Z_NULL;
+    inflator.zfree = Z_NULL;
+    inflator.opaque = Z_NULL;
+
+    inflator.avail_in = (uInt)len;
+    inflator.next_in = (Bytef *)in;
+    inflator.avail_out = (uInt)out_len;
+    inflator.next_out = (Bytef *)out;
+
+    int nowrap = 1;
+    int windowBits = nowrap ? -MAX_WBITS : MAX_WBITS;
+    int ok = inflateInit2(&inflator, windowBits);
+    assert(ok == Z_OK);
+    if (Z_OK == ok) {
+        inflate(&inflator, Z_FINISH);
+        inflateEnd(&inflator);
+    }
+    return inflator.total_out;
+}
+
+#ifdef HAVE_LZ4
+i32 compress_lz4(const char *in, const i32 len, char *out, i32 out_len, char **e) {
+    i32 n = LZ4_compress_default(in, out, len, out_len);
+    if (n <= 0) THROW(e, "lz4 compression failed");
+    return n;
+
+EXCEPTION:
+    return -1;
+}
+
+i32 decompress_lz4(const char *in, const i32 len, char *out, i32 out_len, char **e) {
+    i32 n = LZ4_decompress_safe(in, out, len, out_len);
+    if (n < 0) THROW(e, "lz4 data is corrupted");
+    return n;
+
+EXCEPTION:
+    return -1;
+}
+#endif
+
+#ifdef HAVE_ZSTD
+i32 compress_zstd(const char *in, const i32 len, char *out, i32 out_len, char **e) {
+    // compression level 1~22, default : 3
+    size_t n = ZSTD_compress(out, out_len, in, len, 3);
+    if (ZSTD_isError(n)) THROW(e, "zstd compression failed: %s", ZSTD_getErrorName(n));
+    return (i32)n;
+
+EXCEPTION:
+    return -1;
+}
+
+i32 decompress_zstd(const char *in, const i32 len, char *out, i32 out_len, char **e) {
+    size_t n = ZSTD_decompress(out, out_len, in, len);
+    if (ZSTD_isError(n)) THROW(e, "zstd data is corrupted: %s", ZSTD_getErrorName(n));
+    return (i32)n;
+
+EXCEPTION:
+    return -1;
+}
+#endif
+
+// // brew install snappy
+// i32 compress_snappy(const char *in, const i32 len, char *out, i32 out_len, char **e) {
+//     size_t l = out_len;
+//     snappy_status ok = snappy_compress(in, len, out, &l);
+//     if (ok == SNAPPY_OK) {
+//         return l;
+//     }
+//     return 0;
+// }
+
+// i32 decompress_snappy(const char *in, const i32 len, char *out, i32 out_len, char **e) {
+//     size_t l = out_len;
+//     snappy_status ok = snappy_uncompress(in, len, out, &l);
+//     if (ok == SNAPPY_OK) {
+//         return l;
+//     }
+//     return 0;
+// }
+
+// Encode message to compress internally
+i32 stream_compress(u8 format, const char *in, const i32 len, char *out, i32 out_len, char **e) {
+	switch(format & 0x7F) {
+	// case FORMAT_SNAPPY:
+	// 	return compress_snappy(in, len, out, out_len, e);
+#ifdef HAVE_LZ4
+	case FORMAT_LZ4:
+		return compress_lz4(in, len, out, out_len, e);
+#endif
+#ifdef HAVE_ZSTD
+	case FORMAT_ZSTD:
+		return compress_zstd(in, len, out, out_len, e);
+#endif
+	case FORMAT_Z:
+		return compress_z(in, len, out, out_len, e);
+	}
+	memcpy(out, in, len);
+    return len;
+}
+
+// Decode compressed message  
+i32 stream_decompress(u8 format, const char *in, const i32 len, char *out, i32 out_len, char **e) {
+	switch(format & 0x7F) {
+	// case FORMAT_SNAPPY:
+	// 	return decompress_snappy(in, len, out, out_len, e);
+#ifdef HAVE_LZ4
+	case FORMAT_LZ4:
+		return decompress_lz4(in, len, out, out_len, e);
+#endif
+#ifdef HAVE_ZSTD
+	case FORMAT_ZSTD:
+		return decompress_zstd(in, len, out, out_len, e);
+#endif
+	case FORMAT_Z:
+		return decompress_z(in, len, out, out_len, e);
+	}
+	memcpy(out, in, len);
+    return len;
+}
+
+// Maps a table COMPRESSOR name to its format; 0 for none
+u8 compress_format(const char *name, char **e) {
+    if (!name || !*name || strcasecmp(name, "none") == 0 || strcasecmp(name, "mmap") == 0)
+        return 0;
+    if (strcasecmp(name, "deflate") == 0)
+        return FORMAT_Z;
+#ifdef HAVE_LZ4
+    if (strcasecmp(name, "lz4") == 0)
+        return FORMAT_LZ4;
+#endif
+#ifdef HAVE_ZSTD
+    if (strcasecmp(name, "zstd") == 0)
+        return FORMAT_ZSTD;
+#endif
+    THROW(e, "Compressor not supported: %s", name);
+
+EXCEPTION:
+    return 0;
+}
+
+// Upper bound of the compressed size of len bytes
+i32 compress_bound(u8 format, i32 len) {
+    switch (format & 0x7F) {
+#ifdef HAVE_LZ4
+    case FORMAT_LZ4:
+        return LZ4_compressBound(len);
+#endif
+#ifdef HAVE_ZSTD
+    case FORMAT_ZSTD:
+        return (i32)ZSTD_compressBound(len);
+#endif
+    case FORMAT_Z:
+        return (i32)compressBound((uLong)len) + 16;
+    }
+    return len;
+}
+
+#define CHECKSUM_CRC32  1
+#define CHECKSUM_XXHASH 2
+
+#define XXH_PRIME32_1 0x9E3779B1U
+#define XXH_PRIME32_2 0x85EBCA77U
+#define XXH_PRIME32_3 0xC2B2AE3DU
+#define XXH_PRIME32_4 0x27D4EB2FU
+#define XXH_PRIME32_5 0x165667B1U
+
+static inline u32 xxh_rotl32(u32 x, int r) { return (x << r) | (x >> (32 - r)); }
+
+static inline u32 xxh_read32(const u8 *p) { return (u32)p[0] | ((u32)p[1] << 8) | ((u32)p[2] << 16) | ((u32)p[3] << 24); }
+
+static inline u32 xxh32_round(u32 acc, u32 input) {
+    acc += input * XXH_PRIME32_2;
+    return xxh_rotl32(acc, 13) * XXH_PRIME32_1;
+}
+
+// XXH32 with seed 0
+static u32 xxhash32(const char *data, i32 len) {
+    const u8 *p = (const u8 *)data;
+    const u8 *end = p + len;
+    u32 h;
+    if (len >= 16) {
+        const u8 *limit = end - 16;
+        u32 v1 = XXH_PRIME32_1 + XXH_PRIME32_2;
+        u32 v2 = XXH_PRIME32_2;
+        u32 v3 = 0;
+        u32 v4 = 0 - XXH_PRIME32_1;
+        do {
+            v1 = xxh32_round(v1, xxh_read32(p)); p += 4;
+            v2 = xxh32_round(v2, xxh_read32(p)); p += 4;
+            v3 = xxh32_round(v3, xxh_read32(p)); p += 4;
+            v4 = xxh32_round(v4, xxh_read32(p)); p += 4;
+        } while (p <= limit);
+        h = xxh_rotl32(v1, 1) + xxh_rotl32(v2, 7) + xxh_rotl32(v3, 12) + xxh_rotl32(v4, 18);
+    } else {
+        h = XXH_PRIME32_5;
+    }
+    h += (u32)len;
+    for (; p + 4 <= end; p += 4) {
+        h += xxh_read32(p) * XXH_PRIME32_3;
+        h = xxh_rotl32(h, 17) * XXH_PRIME32_4;
+    }
+    for (; p < end; p++) {
+        h += (*p) * XXH_PRIME32_5;
+        h = xxh_rotl32(h, 11) * XXH_PRIME32_1;
+    }
+    h ^= h >> 15;
+    h *= XXH_PRIME32_2;
+    h ^= h >> 13;
+    h *= XXH_PRIME32_3;
+    h ^= h >> 16;
+    return h;
+}
+
+// Maps a table CHECKSUM name to its format; 0 for none
+u8 checksum_format(const char *name, char **e) {
+    if (!name || !*name || strcasecmp(name, "none") == 0)
+        return 0;
+    if (strcasecmp(name, "crc32") == 0)
+        return CHECKSUM_CRC32;
+    if (strcasecmp(name, "xxhash") == 0)
+        return CHECKSUM_XXHASH;
+    THROW(e, "Checksum not supported: %s", name);
+
+EXCEPTION:
+    return 0;
+}
+
+u32 checksum_of(u8 format, const char *data, i32 len) {
+    switch (format) {
+    case CHECKSUM_CRC32:
+        return (u32)crc32(0L, (const Bytef *)data, (uInt)len);
+    case CHECKSUM_XXHASH:
+        return xxhash32(data, len);
+    }
+    return 0;
+}
@@ -0,0 +1,21 @@
+#!/bin/sh
+# Copies the engine sources from c/src into csrc and writes a bundle_*.c file
+# in the package for each, which cgo compiles unless built with -tags
+# flintdb_lib. Run through go generate from the package directory.
+
+set -e
+src=../../../src
+
+rm -f csrc/*.c csrc/*.h bundle_*.c
+for f in "$src"/*.c "$src"/*.h; do
+	name=$(basename "$f")
+	case "$name" in
+	cli.c | testcase*.c) continue ;;
+	esac
+	cp "$f" csrc/
+	case "$name" in
+	*.c)
+		printf '//go:build !flintdb_lib\n\n// Code generated by csrc/sync.sh. DO NOT EDIT.\n\n#include "csrc/%s"\n' "$name" >"bundle_$name"
+		;;
+	esac
+done
@@ -0,0 +1,1734 @@
+#include "flintdb.h"
+#include "internal.h"
+#include "runtime.h"
+#include "bplustree.h"
+#include "storage.h"
+#include "buffer.h"
+#include "hashmap.h"
+#include "sql.h"
+#include "filter.h"
+#include "list.h"
+#include "wal.h"
+#include "error_codes.h"
+
+#include <stdio.h>
+#include <string.h>
+#include <stdlib.h>
+#include <limits.h>
+#include <unistd.h>
+#include <dirent.h>
+#include <sys/fcntl.h>
+#include <assert.h>
+#include <ctype.h>
+#include <pthread.h>
+#include <stdatomic.h>
+
+// Use pthread recursive mutex for table-level locking
+// Recursive mutex allows same thread to acquire lock multiple times (needed for transaction + operations)
+#define TABLE_LOCK_T pthread_mutex_t
+#define TABLE_LOCK_INIT(lock) do { \
+    pthread_mutexattr_t attr; \
+    pthread_mutexattr_init(&attr); \
+    pthread_mutexattr_settype(&attr, PTHREAD_MUTEX_RECURSIVE); \
+    pthread_mutex_init(lock, &attr); \
+    pthread_mutexattr_destroy(&attr); \
+} while(0)
+#define TABLE_LOCK(lock) pthread_mutex_lock(lock)
+#define TABLE_UNLOCK(lock) pthread_mutex_unlock(lock)
+#define TABLE_LOCK_DESTROY(lock) pthread_mutex_destroy(lock)
+
+
+#define SIGNATURE "ITBL" // TODO: => "LOTS" (LOcal Table Storage)
+#define HEAD_SZ 8 // signature(4B) + version(4B)
+#define I16_BYTES 2
+
+#define DEFAULT_STORAGE_INCREMENT (1024 * 1024 * 16)
+#ifndef DEFAULT_TABLE_CACHE_LIMIT
+#define DEFAULT_TABLE_CACHE_LIMIT (1024 * 1024 * 1)
+#endif
+#define COMPRESSED_BLOCK_BYTES 128 // data bytes of a storage block of a compressed table
+
+#define DEFAULT_TABLE_CACHE_MIN (1024 * 256) // Do not allow too small capacity (구조적 제약)
+
+
+static int row_compare_get(void *o, const void *a, i64 b);
+// forward decl
+static int meta_index_ordinal(const struct flintdb_meta *m, const char *name);
+
+static const struct flintdb_row * table_read(struct flintdb_table *me, i64 rowid, char **e);
+static const struct flintdb_row * table_read_unlocked(struct flintdb_table *me, i64 rowid, char **e);
+
+// sorter compare functions (used by index initialization and rollback refresh)
+static int sorter_primary_cmpr(void *o, i64 a, i64 b);
+static int sorter_index_cmpr(void *o, i64 a, i64 b);
+
+struct sorter {
+    char name[MAX_COLUMN_NAME_LIMIT];
+    char algorithm[32]; // "bptree"
+    struct bplustree tree;
+    struct flintdb_table *table; // parent table
+
+    struct {
+        int length;
+        int a[MAX_INDEX_KEYS_LIMIT];
+    } keys;
+};
+
+struct flintdb_table_priv {
+    char file[PATH_MAX];
+    enum flintdb_open_mode mode;
+    struct flintdb_meta meta;
+    int row_bytes; // fixed row bytes from meta
+
+    struct {
+        int length;
+        struct sorter s[MAX_INDEX_KEYS_LIMIT];
+    } sorters;
+    
+    struct wal *wal; // write-ahead log
+    struct storage *storage; 
+    struct buffer *header;
+    struct formatter formatter;
+    struct hashmap *cache; // rowid -> row*
+    i64 cache_hits, cache_misses; // of read
+    // Reusable raw row buffer pool (new generic buffer_pool)
+    struct buffer_pool *raw_pool;
+    u8 compress; // block compression format of meta.compressor, 0 for none
+    u8 checksum; // row checksum of meta.checksum, 0 for none
+    TABLE_LOCK_T lock; // table-level lock (os_unfair_lock on macOS, spinlock on Linux)
+};
+
+struct flintdb_transaction_priv {
+    struct flintdb_table *table;
+    struct flintdb_table_priv *tpriv;
+    i64 id;
+    i8 done;
+
+    // Snapshot of index counts at begin; used to restore after rollback.
+    // (B+Tree count is stored in its header mapping, not WAL-managed blocks.)
+    int count_len;
+    i64 counts[MAX_INDEX_KEYS_LIMIT];
+};
+
+static i64 tx_id(const struct flintdb_transaction *me) {
+    if (!me || !me->priv) return -1;
+    const struct flintdb_transaction_priv *p = (const struct flintdb_transaction_priv*)me->priv;
+    return p->id;
+}
+
+// Forward decls for in-transaction table ops (assumes table lock is held and WAL tx started)
+static i64 table_apply_in_tx(struct flintdb_table *me, struct flintdb_row *r, i8 upsert, char **e);
+static i64 table_apply_at_in_tx(struct flintdb_table *me, i64 rowid, struct flintdb_row *r, char **e);
+static i64 table_delete_in_tx(struct flintdb_table *me, i64 rowid, char **e);
+
+static i64 tx_apply(struct flintdb_transaction *me, struct flintdb_row *r, i8 upsert, char **e) {
+    if (!me || !me->priv) {
+        if (e) *e = "transaction is null";
+        return NOT_FOUND;
+    }
+    struct flintdb_transaction_priv *p = (struct flintdb_transaction_priv*)me->priv;
+    if (p->done) {
+        if (e) *e = "transaction already finished";
+        return NOT_FOUND;
+    }
+    return table_apply_in_tx(p->table, r, upsert, e);
+}
+
+static i64 tx_apply_at(struct flintdb_transaction *me, i64 rowid, struct flintdb_row *r, char **e) {
+    if (!me || !me->priv) {
+        if (e) *e = "transaction is null";
+        return NOT_FOUND;
+    }
+    struct flintdb_transaction_priv *p = (struct flintdb_transaction_priv*)me->priv;
+    if (p->done) {
+        if (e) *e = "transaction already finished";
+        return NOT_FOUND;
+    }
+    return table_apply_at_in_tx(p->table, rowid, r, e);
+}
+
+static i64 tx_delete_at(struct flintdb_transaction *me, i64 rowid, char **e) {
+    if (!me || !me->priv) {
+        if (e) *e = "transaction is null";
+        return NOT_FOUND;
+    }
+    struct flintdb_transaction_priv *p = (struct flintdb_transaction_priv*)me->priv;
+    if (p->done) {
+        if (e) *e = "transaction already finished";
+        return NOT_FOUND;
+    }
+    return table_delete_in_tx(p->table, rowid, e);
+}
+
+static void tx_commit(struct flintdb_transaction *me, char **e) {
+    if (!me || !me->priv) {
+        if (e) *e = "transaction is null";
+        return;
+    }
+    struct flintdb_transaction_priv *p = (struct flintdb_transaction_priv*)me->priv;
+    if (p->done) return;
+
+    // Flush index metadata (root+count) into WAL-managed storages before commit.
+    for (int i = 0; i < p->tpriv->sorters.length; i++) {
+        struct sorter *s = &p->tpriv->sorters.s[i];
+        if (s->tree.flush_meta) {
+            s->tree.flush_meta(&s->tree, e);
+            if (e && *e) break;
+        }
+    }
+    if (e && *e) {
+        // Best-effort rollback; do not deadlock the table.
+        if (p->id > 0) p->tpriv->wal->rollback(p->tpriv->wal, p->id, NULL);
+        p->done = 1;
+        TABLE_UNLOCK(&p->tpriv->lock);
+        return;
+    }
+
+    // commit WAL then unlock (match Java TransactionImpl)
+    p->tpriv->wal->commit(p->tpriv->wal, p->id, e);
+    if (e && *e) {
+        // Best-effort rollback; do not deadlock the table.
+        if (p->id > 0) p->tpriv->wal->rollback(p->tpriv->wal, p->id, NULL);
+        p->done = 1;
+        TABLE_UNLOCK(&p->tpriv->lock);
+        return;
+    }
+    p->done = 1;
+    TABLE_UNLOCK(&p->tpriv->lock);
+}
+
+static void table_refresh_after_rollback(struct flintdb_table *table) {
+    if (!table || !table->priv) return;
+    struct flintdb_table_priv *priv = (struct flintdb_table_priv*)table->priv;
+
+    // Drop row cache (may contain uncommitted rows)
+    if (priv->cache) priv->cache->clear(priv->cache);
+
+    // Re-open all indexes to reset in-memory B+Tree state (count/root/cache).
+    // This is important because WAL rollback discards staged pages, but the in-memory
+    // B+Tree structs may still reflect uncommitted inserts/deletes.
+    i32 cache_limit = priv->meta.cache;
+    if (cache_limit <= DEFAULT_TABLE_CACHE_LIMIT) cache_limit = DEFAULT_TABLE_CACHE_LIMIT;
+    if (priv->mode == FLINTDB_RDONLY) cache_limit = cache_limit / 2;
+    if (cache_limit < DEFAULT_TABLE_CACHE_MIN) cache_limit = DEFAULT_TABLE_CACHE_MIN;
+
+    for (int i = 0; i < priv->sorters.length; i++) {
+        struct sorter *s = &priv->sorters.s[i];
+
+        // Close without flushing root pointer.
+        int saved_mode = s->tree.mode;
+        s->tree.mode = FLINTDB_RDONLY;
+        s->tree.close(&s->tree);
+        s->tree.mode = saved_mode;
+
+        char ixf[PATH_MAX] = {0};
+        snprintf(ixf, sizeof(ixf), "%s.i.%s", priv->file, s->name);
+
+        char *e = NULL;
+        if (i == 0) {
+            bplustree_init(&s->tree, ixf, cache_limit * 1, priv->mode, TYPE_DEFAULT, s, &sorter_primary_cmpr, priv->wal, &e);
+        } else {
+            bplustree_init(&s->tree, ixf, cache_limit * 1, priv->mode, TYPE_DEFAULT, s, &sorter_index_cmpr, priv->wal, &e);
+        }
+        if (e) {
+            WARN("table_refresh_after_rollback: index reopen failed: %s", e);
+            // best-effort: keep going
+        }
+    }
+}
+
+static void tx_rollback(struct flintdb_transaction *me, char **e) {
+    (void)e; // rollback is best-effort; errors are ignored (same as existing table_* exception cleanup)
+    if (!me || !me->priv) return;
+    struct flintdb_transaction_priv *p = (struct flintdb_transaction_priv*)me->priv;
+    if (p->done) return;
+    if (p->id > 0) {
+        p->tpriv->wal->rollback(p->tpriv->wal, p->id, NULL);
+    }
+
+    // Restore in-memory counts (best-effort). Persisted counts come from WAL-managed meta.
+    for (int i = 0; i < p->count_len; i++) {
+        struct sorter *s = &p->tpriv->sorters.s[i];
+        s->tree.count = p->counts[i];
+        s->tree.meta_dirty = 0;
+    }
+
+    // Reset in-memory state to match rolled-back storage.
+    table_refresh_after_rollback(p->table);
+    p->done = 1;
+    TABLE_UNLOCK(&p->tpriv->lock);
+}
+
+static i8 tx_validate(struct flintdb_transaction *me, struct flintdb_table *t, char **e) {
+    if (!me || !me->priv) THROW(e, "transaction is null");
+
+    struct flintdb_transaction_priv *p = (struct flintdb_transaction_priv*)me->priv;
+    if (p->done) THROW(e, "transaction already finished");
+    if (p->table != t) THROW(e, "transaction does not belong to the specified table");
+    return 1;
+
+EXCEPTION:
+    return 0;
+}
+
+static void tx_close(struct flintdb_transaction *me) {
+    if (!me) return;
+    if (me->priv) {
+        struct flintdb_transaction_priv *p = (struct flintdb_transaction_priv*)me->priv;
+        if (!p->done) {
+            // rollback() unlocks; do not unlock twice
+            tx_rollback(me, NULL);
+        }
+        FREE(me->priv);
+        me->priv = NULL;
+    }
+    FREE(me);
+}
+
+struct find_context {
+    struct flintdb_table *table;
+    struct limit limit;
+    struct filter_layers *filters;
+    enum order order;
+    i8 index;
+    struct flintdb_cursor_i64 *base_cursor; // B+Tree cursor
+    i64 scanned; // rowids taken from base_cursor
+    i64 deadline; // epoch milliseconds after which next fails, 0 for none
+};
+
+
+static i64 table_rows(const struct flintdb_table *me, char **e) {
+    if (!me || !me->priv)
+        return -1;
+        
+    struct flintdb_table_priv *priv = (struct flintdb_table_priv*)me->priv;
+    assert(priv);
+    assert(priv->sorters.length > 0);
+    struct sorter *s = &priv->sorters.s[0];
+    assert(s->tree.count_get);
+    return s->tree.count_get(&s->tree);
+}
+
+static i64 table_bytes(const struct flintdb_table *me, char **e) {
+    if (!me || !me->priv)
+        return -1;
+        
+    struct flintdb_table_priv *priv = (struct flintdb_table_priv*)me->priv;
+    i64 total = priv->storage->bytes_get(priv->storage);
+    if (total < 0) return -1;
+    for(int i=0; i<priv->sorters.length; i++) {
+        struct sorter *s = &priv->sorters.s[i];
+        if (s->tree.bytes_get)
+            total += s->tree.bytes_get(&s->tree);
+    }
+    return total;
+}
+
+static const struct flintdb_meta * table_meta(const struct flintdb_table *me, char **e) {
+    if (!me || !me->priv)
+        return NULL;
+
+    struct flintdb_table_priv *priv = (struct flintdb_table_priv*)me->priv;
+    return &priv->meta;
+}
+
+static void row_cache_dealloc(keytype k, valtype v) {
+    // i64 rowid = k; // for debug
+    struct flintdb_row *r = (struct flintdb_row *)v;
+    r->free(r);
+}
+
+// Borrow/return helpers for raw row buffers via buffer_pool. Not thread-safe by design.
+static inline struct buffer * table_borrow_raw_buffer(struct flintdb_table_priv *priv) {
+    if (priv && priv->raw_pool) {
+        struct buffer *b = priv->raw_pool->borrow(priv->raw_pool, (u32)priv->row_bytes);
+        if (b) return b;
+    }
+    return buffer_alloc((u32)priv->row_bytes);
+}
+
+static inline void table_return_raw_buffer(struct flintdb_table_priv *priv, struct buffer *b) {
+    if (!b) return;
+    if (priv && priv->raw_pool) {
+        priv->raw_pool->return_buffer(priv->raw_pool, b);
+    } else {
+        b->free(b);
+    }
+}
+
+// Packs an encoded row for storage: compressed, its length and then the
+// compressed bytes, followed by the checksum of the bytes before it.
+static struct buffer * table_pack(struct flintdb_table_priv *priv, struct buffer *raw, char **e) {
+    i32 n = raw->remaining(raw);
+    i32 bound = priv->compress ? compress_bound(priv->compress, n) : 0;
+    struct buffer *out = buffer_alloc((u32)(4 + (priv->compress ? bound : n) + 4));
+    if (!out) THROW(e, "Out of memory");
+    if (priv->compress) {
+        out->i32_put(out, n, NULL);
+        i32 z = stream_compress(priv->compress, raw->array + raw->position, n, out->array + out->position, bound, e);
+        if (e && *e) THROW_S(e);
+        if (z <= 0) THROW(e, "failed to compress row");
+        out->position += z;
+    } else {
+        out->array_put(out, raw->array + raw->position, n, NULL);
+    }
+    if (priv->checksum)
+        out->i32_put(out, (i32)checksum_of(priv->checksum, out->array, (i32)out->position), NULL);
+    out->flip(out);
+    return out;
+
+    EXCEPTION:
+    if (out) out->free(out);
+    return NULL;
+}
+
+// Unpacks the row at rowid read from storage: verifies its checksum and
+// decompresses it into a buffer of its own, freeing buf.
+static struct buffer * table_unpack(struct flintdb_table_priv *priv, struct buffer *buf, i64 rowid, char **e) {
+    struct buffer *out = NULL;
+    if (priv->checksum) {
+        if (buf->remaining(buf) < 4) THROW(e, "DB_ERR[%d] checksum mismatch at rowid %lld", DB_ERR_CHECKSUM_MISMATCH, rowid);
+        u32 pos = buf->position;
+        buf->position = buf->limit - 4;
+        u32 sum = (u32)buf->i32_get(buf, NULL);
+        buf->position = pos;
+        buf->limit -= 4;
+        if (sum != checksum_of(priv->checksum, buf->array + pos, buf->remaining(buf)))
+            THROW(e, "DB_ERR[%d] checksum mismatch at rowid %lld", DB_ERR_CHECKSUM_MISMATCH, rowid);
+    }
+    if (!priv->compress) return buf;
+
+    i32 n = buf->i32_get(buf, e);
+    if (e && *e) THROW_S(e);
+    if (n <= 0 || n > priv->row_bytes) THROW(e, "bad compressed row length: %d", n);
+    out = buffer_alloc((u32)n);
+    if (!out) THROW(e, "Out of memory");
+    i32 z = stream_decompress(priv->compress, buf->array + buf->position, buf->remaining(buf), out->array, n, e);
+    if (e && *e) THROW_S(e);
+    if (z != n) THROW(e, "compressed row is corrupted: %d of %d bytes", z, n);
+    buf->free(buf);
+    return out;
+
+    EXCEPTION:
+    if (out) out->free(out);
+    buf->free(buf);
+    return NULL;
+}
+
+// Copies the strings a row decoded from buf refers to, before buf is freed.
+static void table_row_own_strings(struct flintdb_row *r) {
+    for (int i = 0; i < r->length; i++) {
+        struct flintdb_variant *v = &r->array[i];
+        if (v->type == VARIANT_STRING && !v->value.b.owned && v->value.b.data)
+            flintdb_variant_string_set(v, v->value.b.data, v->value.b.length);
+    }
+}
+
+static i64 table_apply_in_tx(struct flintdb_table *me, struct flintdb_row *r, i8 upsert, char **e) {
+    struct flintdb_table_priv* priv = (struct flintdb_table_priv*)me->priv;
+    assert(r);
+    assert(priv);
+    struct flintdb_meta *m = &priv->meta;
+    struct formatter *fmt = &priv->formatter;
+    struct storage *storage = priv->storage;
+    assert(fmt);
+    assert(m);
+    assert(storage);
+
+    struct buffer *raw = NULL;
+    struct buffer *packed = NULL;
+    raw = table_borrow_raw_buffer(priv);
+    if (!raw) THROW(e, "Out of memory");
+    if (m->columns.length != r->meta->columns.length) 
+        THROW(e, "DB_ERR[%d] column count mismatch: %d != %d", DB_ERR_COLUMN_MISMATCH, m->columns.length, r->meta->columns.length);
+    int enc = fmt->encode(fmt, r, raw, e);
+    // DEBUG("row bytes: %d\n", raw->remaining(raw));
+    if (enc != 0) THROW(e, "failed to encode row");
+
+    assert(raw->remaining(raw) > 0);
+    assert(raw->remaining(raw) <= priv->row_bytes);
+    if (raw->remaining(raw) > priv->row_bytes) 
+        THROW(e, "DB_ERR[%d] row bytes exceeded requested: %d, max: %d", DB_ERR_ROW_BYTES_EXCEEDED, raw->remaining(raw), priv->row_bytes);
+    if (priv->compress || priv->checksum) {
+        packed = table_pack(priv, raw, e);
+        if (!packed) THROW_S(e);
+    }
+    struct sorter *primary = &priv->sorters.s[0];
+    // DEBUG("before compare_get, row.id=%lld, primary=%p, tree=%p, r=%p", r->rowid, (void*)primary, (void*)&primary->tree, (void*)r);
+    i64 rowid = r->rowid > NOT_FOUND 
+                ? r->rowid 
+                : primary->tree.compare_get(&primary->tree, primary, r, row_compare_get, e);
+    // DEBUG("after compare_get, rowid=%lld, e=%s", rowid, e?*e?*e:"NULL":"NULL");
+    if (e && *e) THROW(e, "failed to lookup row"); 
+
+    if (NOT_FOUND == rowid) {
+        rowid = storage->write(storage, packed ? packed : raw, e);
+        // DEBUG("table_apply storage->write => %lld", rowid);
+        assert(rowid != NOT_FOUND);
+        if (e && *e) THROW_S(e);
+
+        r->rowid = rowid;
+        // DEBUG("primary->tree.put begin key=%lld", rowid);
+        primary->tree.put(&primary->tree, rowid, e);
+        // DEBUG("primary->tree.put end key=%lld", rowid);
+        if (e && *e) THROW_S(e);
+
+        for(int i=1; i<priv->sorters.length; i++) {
+            struct sorter *sorter = &priv->sorters.s[i];
+            // DEBUG("secondary sorter[%d] put begin", i);
+            sorter->tree.put(&sorter->tree, rowid, e);
+            // DEBUG("secondary sorter[%d] put end", i);
+            if (e && *e) THROW_S(e); 
+        }
+
+        // put cache
+        // me->read(me, rowid, e);
+    } else {
+        if (!upsert) THROW(e, "DB_ERR[%d] duplicate key on rowid: %lld", DB_ERR_DUPLICATE_KEY, rowid);
+        
+        r->rowid = rowid;
+        for(int i=1; i<priv->sorters.length; i++) {
+            struct sorter *sorter = &priv->sorters.s[i];
+            // DEBUG("update secondary sorter[%d] delete begin", i);
+            if (sorter->tree.delete(&sorter->tree, rowid, e) == NOT_FOUND)
+                THROW(e, "index[%s] key : %lld not found", sorter->name, rowid);
+            // DEBUG("update secondary sorter[%d] delete end", i);
+        }
+        // In update path, do NOT cache the caller-provided row pointer 'r'.
+        // Caching 'r' here transfers ownership to the cache and causes use-after-free
+        // when the caller frees 'r' after apply(). Instead, clear stale cache entry
+        // and repopulate from storage after write_at so cache owns its own copy.
+        priv->cache->remove(priv->cache, rowid);
+        // DEBUG("update storage->write_at begin");
+        storage->write_at(storage, rowid, packed ? packed : raw, e);
+        // DEBUG("update storage->write_at end");
+        if (e && *e) THROW_S(e);
+
+        // Repopulate cache with freshly written row (owned by cache)
+        // This ensures subsequent reads and index comparators see updated values
+        // without retaining the caller's 'r' pointer.
+        // (void)me->read(me, rowid, NULL);
+        // // priv->cache->put(priv->cache, (keytype)rowid, (valtype)r, row_cache_dealloc);
+
+        for(int i=1; i<priv->sorters.length; i++) {
+            struct sorter *sorter = &priv->sorters.s[i];
+            // DEBUG("update secondary sorter[%d] put begin", i);
+            sorter->tree.put(&sorter->tree, rowid, e);
+            // DEBUG("update secondary sorter[%d] put end", i);
+            if (e && *e) THROW_S(e);
+        }
+    }
+
+    // raw buffer no longer needed after write
+    if (raw) table_return_raw_buffer(priv, raw);
+    if (packed) packed->free(packed);
+    return rowid;
+
+    EXCEPTION:
+    if (raw) table_return_raw_buffer(priv, raw);
+    if (packed) packed->free(packed);
+    return NOT_FOUND;
+}
+
+static i64 table_apply(struct flintdb_table *me, struct flintdb_row *r, i8 upsert, char **e) {
+    struct flintdb_table_priv* priv = (struct flintdb_table_priv*)me->priv;
+    assert(priv);
+    i64 transaction = 0;
+    TABLE_LOCK(&priv->lock);
+    // Begin WAL transaction (will set transaction on all storages)
+    transaction = priv->wal->begin(priv->wal, e);
+    if (e && *e) goto EXCEPTION;
+
+    i64 rowid = table_apply_in_tx(me, r, upsert, e);
+    if (e && *e) goto EXCEPTION;
+    if (rowid == NOT_FOUND) goto EXCEPTION;
+
+    for (int i = 0; i < priv->sorters.length; i++) {
+        struct sorter *s = &priv->sorters.s[i];
+        if (s->tree.flush_meta) {
+            s->tree.flush_meta(&s->tree, e);
+            if (e && *e) goto EXCEPTION;
+        }
+    }
+
+    priv->wal->commit(priv->wal, transaction, e);
+    if (e && *e) goto EXCEPTION;
+
+    TABLE_UNLOCK(&priv->lock);
+    return rowid;
+
+    EXCEPTION:
+    if (transaction > 0) priv->wal->rollback(priv->wal, transaction, NULL);
+    TABLE_UNLOCK(&priv->lock);
+    return NOT_FOUND;
+}
+
+static i64 table_apply_at_in_tx(struct flintdb_table *me, i64 rowid, struct flintdb_row *r, char **e) {
+    // Ensure 'raw' is always defined before any THROW can jump to EXCEPTION
+    struct buffer *raw = NULL;
+    struct buffer *packed = NULL;
+    
+    struct flintdb_table_priv* priv = (struct flintdb_table_priv*)me->priv;
+    if (rowid <= NOT_FOUND) THROW(e, "bad rowid: %lld", rowid);
+    assert(r);
+    assert(priv);
+    struct flintdb_meta *m = &priv->meta;
+    struct formatter *fmt = &priv->formatter;
+    struct storage *storage = priv->storage;
+    assert(fmt);
+    assert(m);
+    assert(storage);
+
+    raw = table_borrow_raw_buffer(priv);
+    if (!raw) THROW(e, "Out of memory");
+    if (m->columns.length != r->meta->columns.length)
+        THROW(e, "DB_ERR[%d] column count mismatch: %d != %d", DB_ERR_COLUMN_MISMATCH, m->columns.length, r->meta->columns.length);
+
+
+    if (fmt->encode(fmt, r, raw, e) != 0) THROW(e, "failed to encode row");
+
+    assert(raw->remaining(raw) > 0);
+    assert(raw->remaining(raw) <= priv->row_bytes);
+    if (raw->remaining(raw) > priv->row_bytes) 
+        THROW(e, "DB_ERR[%d] row bytes exceeded requested: %d, max: %d", DB_ERR_ROW_BYTES_EXCEEDED, raw->remaining(raw), priv->row_bytes);
+    if (priv->compress || priv->checksum) {
+        packed = table_pack(priv, raw, e);
+        if (!packed) THROW_S(e);
+    }
+
+    r->rowid = rowid;
+
+    for(int i=1; i<priv->sorters.length; i++) {
+        struct sorter *sorter = &priv->sorters.s[i];
+        if (sorter->tree.delete(&sorter->tree, rowid, e) == NOT_FOUND)
+            THROW(e, "index[%s] key : %lld not found", sorter->name, rowid);
+        if (e && *e) THROW_S(e); 
+    }
+
+    priv->cache->remove(priv->cache, rowid);
+    // priv->cache->put(priv->cache, (keytype)rowid, (valtype)r, row_cache_dealloc);
+    storage->write_at(storage, rowid, packed ? packed : raw, e);
+    if (e && *e) THROW_S(e);  
+
+    for(int i=1; i<priv->sorters.length; i++) {
+        struct sorter *sorter = &priv->sorters.s[i];
+        sorter->tree.put(&sorter->tree, rowid, e);
+        if (e && *e) THROW_S(e); 
+    }
+
+    // raw buffer no longer needed after write
+    if (raw) table_return_raw_buffer(priv, raw);
+    if (packed) packed->free(packed);
+    return rowid;
+
+    EXCEPTION:
+    if (raw) table_return_raw_buffer(priv, raw);
+    if (packed) packed->free(packed);
+    return NOT_FOUND;
+}
+
+static i64 table_apply_at(struct flintdb_table *me, i64 rowid, struct flintdb_row *r, char **e) {
+    struct flintdb_table_priv* priv = (struct flintdb_table_priv*)me->priv;
+    assert(priv);
+    i64 transaction = 0;
+    TABLE_LOCK(&priv->lock);
+    transaction = priv->wal->begin(priv->wal, e);
+    if (e && *e) goto EXCEPTION;
+
+    i64 ok = table_apply_at_in_tx(me, rowid, r, e);
+    if (e && *e) goto EXCEPTION;
+    if (ok == NOT_FOUND) goto EXCEPTION;
+
+    for (int i = 0; i < priv->sorters.length; i++) {
+        struct sorter *s = &priv->sorters.s[i];
+        if (s->tree.flush_meta) {
+            s->tree.flush_meta(&s->tree, e);
+            if (e && *e) goto EXCEPTION;
+        }
+    }
+
+    priv->wal->commit(priv->wal, transaction, e);
+    if (e && *e) goto EXCEPTION;
+
+    TABLE_UNLOCK(&priv->lock);
+    return ok;
+
+    EXCEPTION:
+    if (transaction > 0) priv->wal->rollback(priv->wal, transaction, NULL);
+    TABLE_UNLOCK(&priv->lock);
+    return NOT_FOUND;
+}
+
+static i64 table_delete_in_tx(struct flintdb_table *me, i64 rowid, char **e) {
+    struct flintdb_table_priv* priv = (struct flintdb_table_priv*)me->priv;
+    if (rowid <= NOT_FOUND) THROW(e, "bad rowid: %lld", rowid);
+    assert(priv);
+    struct storage *storage = priv->storage;
+    struct hashmap *cache = priv->cache;
+    assert(storage);
+    assert(cache);
+
+    struct sorter *primary = &priv->sorters.s[0];
+    const struct flintdb_row *r = table_read_unlocked(me, rowid, e);
+    if (e && *e) THROW_S(e);
+    if (r == NULL) THROW(e, "table_read(%lld) not found", rowid);
+
+    for(int i=1; i<priv->sorters.length; i++) {
+        struct sorter *sorter = &priv->sorters.s[i];
+        if (sorter->tree.delete(&sorter->tree, rowid, e) == NOT_FOUND)
+            THROW(e, "index[%s] key : %lld not found", sorter->name, rowid);
+        if (e && *e) THROW_S(e); 
+    }
+    if (primary->tree.delete(&primary->tree, rowid, e) == NOT_FOUND)
+        THROW(e, "primary[%s] key : %lld not found", primary->name, rowid);
+    if (e && *e) THROW_S(e); 
+
+    cache->remove(cache, rowid);
+    storage->delete(storage, rowid, e);
+    if (e && *e) THROW_S(e);
+
+    return 1; // ok
+
+    EXCEPTION:
+    return NOT_FOUND;
+}
+
+static i64 table_delete(struct flintdb_table *me, i64 rowid, char **e) {
+    struct flintdb_table_priv* priv = (struct flintdb_table_priv*)me->priv;
+    assert(priv);
+    i64 transaction = 0;
+    TABLE_LOCK(&priv->lock);
+    transaction = priv->wal->begin(priv->wal, e);
+    if (e && *e) goto EXCEPTION;
+
+    i64 ok = table_delete_in_tx(me, rowid, e);
+    if (e && *e) goto EXCEPTION;
+    if (ok == NOT_FOUND) goto EXCEPTION;
+
+    for (int i = 0; i < priv->sorters.length; i++) {
+        struct sorter *s = &priv->sorters.s[i];
+        if (s->tree.flush_meta) {
+            s->tree.flush_meta(&s->tree, e);
+            if (e && *e) goto EXCEPTION;
+        }
+    }
+
+    priv->wal->commit(priv->wal, transaction, e);
+    if (e && *e) goto EXCEPTION;
+
+    TABLE_UNLOCK(&priv->lock);
+    return ok;
+
+    EXCEPTION:
+    if (transaction > 0) priv->wal->rollback(priv->wal, transaction, NULL);
+    TABLE_UNLOCK(&priv->lock);
+    return NOT_FOUND;
+}
+
+static void find_close(struct flintdb_cursor_i64 *c) {
+    if (!c) return;
+    struct find_context *ctx = (struct find_context *)c->p;
+    if (ctx) {
+        if (ctx->base_cursor) {
+            ctx->base_cursor->close(ctx->base_cursor);
+            ctx->base_cursor = NULL;
+        }
+        if (ctx->filters) {
+            filter_layers_free(ctx->filters);
+            ctx->filters = NULL;
+        }
+        FREE(ctx);
+    }
+    FREE(c);
+}
+
+// find_expired reports whether the deadline of ctx has passed, looking at
+// the clock once every 256 rows scanned.
+static inline int find_expired(const struct find_context *ctx) {
+    if (ctx->deadline <= 0 || (ctx->scanned & 0xFF) != 0) return 0;
+    struct timespec now;
+    flintdb_timespec_utc(&now);
+    return (i64)now.tv_sec * 1000 + now.tv_nsec / 1000000 >= ctx->deadline;
+}
+
+static i64 find_next(struct flintdb_cursor_i64 *c, char **e) {
+    if (!c) return NOT_FOUND;
+    struct find_context *ctx = (struct find_context *)c->p;
+    if (!ctx) return NOT_FOUND;
+    
+    struct flintdb_table *table = ctx->table;
+    if (!table) return NOT_FOUND;
+    
+    // Check offset: skip rows until offset is reached
+    while (ctx->limit.priv.o > 0) {
+        if (!ctx->base_cursor) return NOT_FOUND;
+        i64 rowid = ctx->base_cursor->next(ctx->base_cursor, e);
+        if (rowid == NOT_FOUND) return NOT_FOUND;
+        if (e && *e) return NOT_FOUND;
+        ctx->scanned++;
+        if (find_expired(ctx)) THROW(e, "query timed out after %lld rows", (long long)ctx->scanned);
+        
+        // Apply both indexable and non-indexable filters
+        const struct flintdb_row *r = table->read(table, rowid, e);
+        if (e && *e) return NOT_FOUND;
+        if (!r) continue;
+        
+        int match = 1; // assume no match
+        if (ctx->filters) {
+            // Apply indexable filter (first layer)
+            if (ctx->filters->first) {
+                match = filter_compare(ctx->filters->first, (struct flintdb_row *)r, e);
+                if (e && *e) return NOT_FOUND;
+                if (match != 0) continue; // not matched, skip this row
+            }
+            
+            // Apply non-indexable filter (second layer)
+            if (ctx->filters->second) {
+                match = filter_compare(ctx->filters->second, (struct flintdb_row *)r, e);
+                if (e && *e) return NOT_FOUND;
+                if (match != 0) continue; // not matched, skip this row
+            }
+        }
+        
+        ctx->limit.priv.o--;
+    }
+    
+    // Check limit counter
+    while (ctx->limit.priv.n > 0) {
+        if (!ctx->base_cursor) return NOT_FOUND;
+        i64 rowid = ctx->base_cursor->next(ctx->base_cursor, e);
+        if (rowid == NOT_FOUND) return NOT_FOUND;
+        if (e && *e) return NOT_FOUND;
+        ctx->scanned++;
+        if (find_expired(ctx)) THROW(e, "query timed out after %lld rows", (long long)ctx->scanned);
+        
+        // Apply both indexable and non-indexable filters
+        const struct flintdb_row *r = table->read(table, rowid, e);
+        if (e && *e) return NOT_FOUND;
+        if (!r) continue;
+        
+        int match = 1; // assume no match
+        if (ctx->filters) {
+            // Apply indexable filter (first layer) 
+            if (ctx->filters->first) {
+                match = filter_compare(ctx->filters->first, (struct flintdb_row *)r, e);
+                if (e && *e) return NOT_FOUND;
+                if (match != 0) continue; // not matched, skip this row
+            }
+            
+            // Apply non-indexable filter (second layer)
+            if (ctx->filters->second) {
+                match = filter_compare(ctx->filters->second, (struct flintdb_row *)r, e);
+                if (e && *e) return NOT_FOUND;
+                if (match != 0) continue; // not matched, skip this row
+            }
+        }
+        
+        // Found a matching row
+        ctx->limit.priv.n--;
+        return rowid;
+    }
+    
+    return NOT_FOUND;
+
+    EXCEPTION:
+    return NOT_FOUND;
+}
+
+// HOT_PATH
+static int find_row_compare(void *obj, i64 key) {
+    // Tri-state comparator for B+Tree range scans.
+    // B+Tree expects: 0 = in range (continue scan), non-zero = out of range (stop)
+    // filter_compare returns: 0 = match, non-zero = no match
+    // So we need to invert the logic: return 0 when filter matches, non-zero otherwise
+    struct find_context *ctx = (struct find_context *)obj;
+    assert(ctx);
+    // If there is no indexable filter, treat all rows as in-range
+    if (!ctx->filters || !ctx->filters->first) return 0;
+    const struct flintdb_row *r = ctx->table->read(ctx->table, key, NULL);
+    // flintdb_print_row((struct flintdb_row *)r);
+    assert(r);
+    
+    // filter_compare returns 0 on match, so we return it as-is
+    // B+Tree will continue scanning while this returns 0 (match)
+    return filter_compare(ctx->filters->first, (struct flintdb_row *)r, NULL);
+}
+
+static struct flintdb_cursor_i64 * table_find(const struct flintdb_table *me, 
+    i8 index, 
+    enum order order, 
+    struct limit limit, 
+    struct filter *filter, 
+    char **e) {
+
+    struct flintdb_table_priv* priv = (struct flintdb_table_priv*)me->priv;
+    assert(priv);
+    assert(index > -1 && index < priv->sorters.length);
+    struct flintdb_meta *meta = &priv->meta;
+
+    struct sorter *sorter = &priv->sorters.s[index];
+    struct flintdb_cursor_i64 *c = (struct flintdb_cursor_i64*)CALLOC(1, sizeof(struct flintdb_cursor_i64));
+    if (!c) { if (c) FREE(c); return NULL; }
+    struct find_context *impl = (struct find_context*)CALLOC(1, sizeof(struct find_context));
+
+    // TRACE("table_find: filter=%p, index=%d", (void*)filter, index);
+
+    struct filter_layers *filters = filter_split(filter, &priv->meta, &meta->indexes.a[index], e);
+    if (e && *e) {
+        WARN("table_find: filter_split failed: %s", *e);
+        FREE(impl); FREE(c); return NULL;
+    }
+    
+    // TRACE("table_find: filters=%p, first=%p, second=%p", 
+    //       (void*)filters, 
+    //       filters ? (void*)filters->first : NULL, 
+    //       filters ? (void*)filters->second : NULL);
+
+    impl->table = (struct flintdb_table *)me;  // cast away const
+    impl->filters = filters;
+    impl->limit = limit;
+    impl->order = order;
+    impl->index = index;
+    // initialize limit counters
+    impl->limit.priv.n = impl->limit.priv.limit < 0 ? INT_MAX : impl->limit.priv.limit;
+    impl->limit.priv.o = impl->limit.priv.offset;
+
+    struct flintdb_cursor_i64 *base = sorter->tree.find(&sorter->tree, order, impl, find_row_compare, e);
+    if (e && *e) { 
+        WARN("table_find: B+Tree find failed: %s", *e);
+        if (filters) filter_layers_free(filters);
+        FREE(impl); 
+        FREE(c); 
+        return NULL; 
+    }
+    if (!base) { 
+        // WARN("table_find: B+Tree find returned NULL (no error, but empty result)");
+        if (filters) filter_layers_free(filters);
+        FREE(impl); 
+        FREE(c); 
+        return NULL; 
+    }
+    
+    impl->base_cursor = base;  // store base cursor in context
+    c->p = impl;
+    c->close = find_close;
+    c->next = find_next;
+    return c;
+}
+
+static int table_find_index_from_hint(const struct flintdb_meta *meta, 
+    const struct flintdb_sql *q, 
+    int *index, // output best index
+    enum order *order // output preferred order
+    ) {
+    // User-specified index hint takes precedence
+    char hint[SQL_OBJECT_STRING_LIMIT];
+    const char *src = q->index;
+    strncpy_safe(hint, src, sizeof(hint));
+    // tokenize by space: name [order]
+    char *save = NULL;
+    char *name = strtok_r(hint, " ", &save);
+    char *orderkw = strtok_r(NULL, " ", &save);
+    *index = meta_index_ordinal(meta, name);
+    if (orderkw && strncasecmp(orderkw, "DESC", 4) == 0) *order = DESC;
+    return 1; // found hint
+}
+
+// Parses a find query "[USE INDEX(name [DESC])] [WHERE ...] [LIMIT ...]" on file into
+// the index, order, limit and compiled filter table_find takes; the caller frees *f.
+static int table_query_parse(const struct flintdb_meta *meta, const char *file, const char *where, 
+    int *index, enum order *ord, struct limit *l, struct filter **f, char **e) {
+    // Build a SELECT statement like Java: "SELECT * FROM <file> USE INDEX(primary ASC|DESC) WHERE <where>"
+    char sql[SQL_STRING_LIMIT];
+    const char *w = where ? where : "";
+    struct flintdb_sql *q = NULL;
+    *f = NULL;
+
+    if (!strempty(w))
+        snprintf(sql, sizeof(sql), "SELECT * FROM %s %s", file, w); // snprintf(sql, sizeof(sql), "SELECT * FROM %s WHERE %s", file, w);
+    else 
+        snprintf(sql, sizeof(sql), "SELECT * FROM %s", file);
+
+    // printf("DEBUG: table_query_parse: sql='%s'\n", sql);
+
+    // Parse SQL to extract index hint, WHERE, and LIMIT
+    q = flintdb_sql_parse(sql, e);
+    if (q == NULL) THROW_S(e);
+
+    // Determine index and order from index hint like Java
+    *index = PRIMARY_INDEX; // default to primary
+    *ord = ASC;
+    if (!strempty(q->index)) 
+        table_find_index_from_hint(meta, q, index, ord);
+
+    // Compile WHERE with selected index so filter.c can derive indexable conditions and range
+    *f = filter_compile(q->where, (struct flintdb_meta *)meta, e);
+    if (e && *e) THROW_S(e);
+
+    // Parse LIMIT
+    *l = !strempty(q->limit) ? limit_parse(q->limit) : NOLIMIT;
+    flintdb_sql_free(q);
+    return 0;
+
+    EXCEPTION:
+    if (*f) filter_free(*f);
+    *f = NULL;
+    if (q) flintdb_sql_free(q);
+    return -1;
+}
+
+static struct flintdb_cursor_i64 * table_find_where(const struct flintdb_table *me, const char *where, char **e) {
+    struct flintdb_table_priv* priv = (struct flintdb_table_priv*)me->priv;
+    assert(priv);
+
+    int index;
+    enum order ord;
+    struct limit l;
+    struct filter *f = NULL;
+    if (table_query_parse(&priv->meta, priv->file, where, &index, &ord, &l, &f, e) != 0)
+        return NULL;
+
+    // DEBUG("table_find_where: filter=%p, where='%s', index=%d", (void*)f, where, index);
+
+    struct flintdb_cursor_i64 *result = table_find(me, (i8)index, ord, l, f, e);
+    
+    // Clean up filter after use (table_find copies it via filter_split)
+    if (f) filter_free(f);
+    return result;
+}
+
+int flintdb_query_parse(const struct flintdb_meta *meta, const char *query, int *index, i8 *desc, i32 *offset, i32 *limit, char **e) {
+    struct filter *f = NULL;
+    struct filter_layers *layers = NULL;
+    if (!meta || meta->indexes.length <= 0) THROW(e, "query needs a meta with a primary key");
+
+    int ix;
+    enum order ord;
+    struct limit l;
+    if (table_query_parse(meta, "query", query, &ix, &ord, &l, &f, e) != 0) THROW_S(e);
+
+    // Split the filter for the chosen index as table_find does
+    layers = filter_split(f, (struct flintdb_meta *)meta, (struct flintdb_index *)&meta->indexes.a[ix], e);
+    if (e && *e) THROW_S(e);
+
+    if (index) *index = ix;
+    if (desc) *desc = ord == DESC;
+    if (offset) *offset = l.priv.offset;
+    if (limit) *limit = l.priv.limit;
+    if (layers) filter_layers_free(layers);
+    if (f) filter_free(f);
+    return 0;
+
+    EXCEPTION:
+    if (layers) filter_layers_free(layers);
+    if (f) filter_free(f);
+    return -1;
+}
+
+static const struct flintdb_row * table_one(const struct flintdb_table *me, i8 index, u16 argc, const char **argv, char **e) {
+    struct flintdb_table_priv* priv = (struct flintdb_table_priv*)me->priv;
+    assert(priv);
+    assert(index > -1 && index < priv->sorters.length);
+
+    struct sorter *sorter = &priv->sorters.s[index];
+    struct flintdb_row *r = flintdb_row_from_argv(&priv->meta, argc, argv, e); // Row.create(meta, row)
+    assert(r);
+    i64 i = sorter->tree.compare_get(&sorter->tree, sorter, r, row_compare_get, e);
+    r->free(r);
+    return i < 0 ? NULL : ((struct flintdb_table*)me)->read((struct flintdb_table*)me, i, e);
+}
+
+static inline int table_row_from_buffer(struct flintdb_table *me, struct buffer *buf, struct flintdb_row **out, char **e) {
+    assert(me);
+    assert(buf);
+
+    struct flintdb_table_priv *priv = (struct flintdb_table_priv*)me->priv;
+    struct flintdb_meta *m = &priv->meta;
+    assert(m);
+    struct formatter *f = &priv->formatter;
+    struct flintdb_row *r = flintdb_row_new(m, e);
+    if (!r) return -1;
+    if (e && *e) {
+        FREE(r);
+        return -1;
+    }
+
+    if (f->decode(f, buf, r, e) != 0) {
+        FREE(r);
+        return -1;
+    }
+    r->meta = m;
+    assert(r->meta);
+
+    *out = r;
+    return 0;
+}
+
+// Streaming read: decode into caller-owned row buffer, skip cache entirely.
+// Used by SELECT scans to eliminate per-row allocations and cache retention.
+HOT_PATH
+static int table_read_stream(struct flintdb_table *me, i64 rowid, struct flintdb_row *dest, char **e) {
+    if (!me || !dest) {
+        if (e) *e = STRDUP("table_read_stream: NULL parameter");
+        return -1;
+    }
+    if (!me->priv) {
+        if (e) *e = STRDUP("table_read_stream: NULL priv");
+        return -1;
+    }
+
+    struct flintdb_table_priv *priv = (struct flintdb_table_priv*)me->priv;
+    struct buffer *buf = priv->storage->read(priv->storage, rowid, e);
+    if (e && *e) return -1;
+    if (!buf) {
+        if (e) *e = STRDUP("table_read_stream: NULL buffer");
+        return -1;
+    }
+    if (priv->compress || priv->checksum) {
+        buf = table_unpack(priv, buf, rowid, e);
+        if (!buf) return -1;
+    }
+
+    struct formatter *f = &priv->formatter;
+    if (f->decode(f, buf, dest, e) != 0) {
+        buf->free(buf);
+        return -1;
+    }
+    if (priv->compress) table_row_own_strings(dest);
+    buf->free(buf);
+
+    dest->rowid = rowid;
+    dest->meta = &priv->meta;
+    return 0;
+}
+
+// Internal read without lock (caller must hold lock)
+static const struct flintdb_row * table_read_unlocked(struct flintdb_table *me, i64 rowid, char **e) {
+    if (!me) return NULL;
+    if (!me->priv) return NULL;
+
+    struct flintdb_table_priv *priv = (struct flintdb_table_priv*)me->priv;
+    struct hashmap *cache = priv->cache;
+    assert(cache);
+    struct flintdb_row *cached = (struct flintdb_row *)cache->get(cache, rowid);
+    if (cached && cached != (struct flintdb_row*)HASHMAP_INVALID_VAL) {
+        priv->cache_hits++;
+        return cached;
+    }
+    priv->cache_misses++;
+
+    struct buffer *buf = priv->storage->read(priv->storage, rowid, e);
+    if (e && *e) THROW_S(e);
+    if (!buf) THROW(e, "table_read_unlocked: storage read returned NULL buffer");
+    if (priv->compress || priv->checksum) {
+        buf = table_unpack(priv, buf, rowid, e);
+        if (!buf) THROW_S(e);
+    }
+
+    struct flintdb_row *out = NULL;
+    if (table_row_from_buffer(me, buf, &out, e) != 0) {
+        if (buf) buf->free(buf);
+        return NULL;
+    }   
+    if (priv->compress) table_row_own_strings(out);
+    buf->free(buf);
+
+    out->rowid = rowid;
+    cache->put(cache, rowid, (valtype)out, row_cache_dealloc);
+    return out;
+
+EXCEPTION:
+    return NULL;
+}
+
+HOT_PATH
+static const struct flintdb_row * table_read(struct flintdb_table *me, i64 rowid, char **e) {
+    if (!me) return NULL;
+    if (!me->priv) return NULL;
+
+    struct flintdb_table_priv *priv = (struct flintdb_table_priv*)me->priv;
+    TABLE_LOCK(&priv->lock);
+    const struct flintdb_row *result = table_read_unlocked(me, rowid, e);
+    TABLE_UNLOCK(&priv->lock);
+    return result;
+}
+
+static void table_close(struct flintdb_table *me) {
+    if (!me) return;
+
+    // LOG("closing table %p", (void*)me);
+
+    if (me->priv) {
+        struct flintdb_table_priv *priv = (struct flintdb_table_priv*)me->priv;
+        DEBUG("closing %d sorter(s)", priv->sorters.length);
+        for(int i=0; i<(priv->sorters.length); i++) {
+            struct sorter *s = &priv->sorters.s[i];
+            assert(s);
+            assert(s->tree.close);
+            DEBUG("closing sorter[%d] %s", i, s->name);
+            s->tree.close(&s->tree);
+        }
+
+        DEBUG("clearing cache");
+        if (priv->cache) {
+            priv->cache->clear(priv->cache);
+            priv->cache->free(priv->cache);
+            priv->cache = NULL;
+        }
+
+        // Destroy table-level lock
+        TABLE_LOCK_DESTROY(&priv->lock);
+
+        // Free table-local header mapping created via storage.mmap in table_open
+        DEBUG("freeing header slice");
+        if (priv->header) {
+            priv->header->free(priv->header);
+            priv->header = NULL;
+        }
+
+        DEBUG("closing storage");
+        if (priv->storage) {
+            if (!priv->storage->managed_by_wal) {
+                priv->storage->close(priv->storage);
+                DEBUG("freeing wrapped storage");
+                FREE(priv->storage);
+                DEBUG("wrapped storage freed");
+            }
+            priv->storage = NULL;
+        }
+        DEBUG("storage closed");
+
+        DEBUG("closing wal");
+        if (priv->wal) {
+            priv->wal->close(priv->wal);
+            priv->wal = NULL;
+        }
+
+        DEBUG("closing formatter");
+        priv->formatter.close(&priv->formatter);
+        DEBUG("closing meta");
+        flintdb_meta_close(&priv->meta);
+
+        // Free raw buffer pool
+        DEBUG("freeing raw buffer pool");
+        if (priv->raw_pool) {
+            priv->raw_pool->free(priv->raw_pool);
+            priv->raw_pool = NULL;
+        }
+
+        DEBUG("freeing table priv");
+        FREE(me->priv);
+        me->priv = NULL;
+    }
+
+    DEBUG("freeing table object");
+    FREE(me);
+}
+
+
+struct flintdb_transaction * flintdb_transaction_begin(struct flintdb_table *table, char **e) {
+    if (!table || !table->priv) THROW(e, "table is null"); 
+
+    struct flintdb_table_priv *tpriv = (struct flintdb_table_priv*)table->priv;
+    if (!tpriv->wal) THROW(e, "WAL is not initialized");
+
+    struct flintdb_transaction *tx = (struct flintdb_transaction*)CALLOC(1, sizeof(struct flintdb_transaction));
+    if (!tx) THROW(e, "Out of memory");
+    struct flintdb_transaction_priv *p = (struct flintdb_transaction_priv*)CALLOC(1, sizeof(struct flintdb_transaction_priv));
+    if (!p) {
+        FREE(tx);
+        THROW(e, "Out of memory");
+    }
+
+    // Acquire the table lock first, then start WAL tx (match Java TransactionImpl semantics)
+    TABLE_LOCK(&tpriv->lock);
+
+    // Snapshot current index counts so rollback can restore tbl->rows deterministically.
+    p->count_len = tpriv->sorters.length;
+    if (p->count_len > MAX_INDEX_KEYS_LIMIT) p->count_len = MAX_INDEX_KEYS_LIMIT;
+    for (int i = 0; i < p->count_len; i++) {
+        struct sorter *s = &tpriv->sorters.s[i];
+        p->counts[i] = (s->tree.count_get ? s->tree.count_get(&s->tree) : s->tree.count);
+    }
+
+    i64 id = tpriv->wal->begin(tpriv->wal, e);
+    if (e && *e) {
+        TABLE_UNLOCK(&tpriv->lock);
+        FREE(p);
+        FREE(tx);
+        return NULL;
+    }
+
+    p->table = table;
+    p->tpriv = tpriv;
+    p->id = id;
+    p->done = 0;
+
+    tx->id = tx_id;
+    tx->apply = tx_apply;
+    tx->apply_at = tx_apply_at;
+    tx->delete_at = tx_delete_at;
+    tx->commit = tx_commit;
+    tx->rollback = tx_rollback;
+    tx->close = tx_close;
+    tx->validate = tx_validate;
+    tx->priv = p;
+    return tx;
+
+    EXCEPTION:
+    return NULL;
+}
+
+int flintdb_table_drop(const char *file, char **e) { // delete <table>, <table>.desc, <table>.i.*
+    char dir[PATH_MAX] = {0};
+    getdir(file, dir);
+    // DEBUG("dir: %s", dir);
+
+    if (!dir_exists(dir)) return 0; // nothing to do
+
+    DIR *d = opendir(dir);
+    DEBUG("opendir: %s, %p", dir, d);
+    if (!d) THROW(e, "Failed to open directory: %s", dir); 
+
+    // Compute basename once; getname requires a valid buffer, not NULL
+    char base[PATH_MAX] = {0};
+    getname(file, base);
+    size_t base_len = strlen(base);
+
+    struct dirent* de = readdir(d);
+    while (de) {
+        if (0 == strncmp(de->d_name, base, base_len)) {
+            char f[PATH_MAX] = {0, };
+            snprintf(f, PATH_MAX, "%s%c%s", dir, PATH_CHAR, de->d_name);
+            // printf("rm : %s\n", f);
+            if (unlink(f) != 0) {
+                THROW(e, "Failed to remove file: %s", f);
+            }
+        }
+        de = readdir(d);
+    }
+
+    closedir(d);
+    return 0;
+
+    EXCEPTION:
+    if (d) closedir(d);
+    return -1;
+}
+
+int row_bytes(const struct flintdb_meta *m) {
+    if (!m) return -1;
+
+    int n = I16_BYTES;  // column count
+    for(int i=0; i<m->columns.length; i++) {
+        const struct flintdb_column *c = &m->columns.a[i];
+        enum flintdb_variant_type  t = c->type;
+        n += I16_BYTES; // type
+        if (VARIANT_STRING == t || VARIANT_DECIMAL == t || VARIANT_BYTES == t || VARIANT_BLOB == t) {
+            assert(c->bytes > 0);
+            n += I16_BYTES; // bytes length
+            n += c->bytes;  // bytes
+        } else if (VARIANT_INT8 == t || VARIANT_UINT8 == t) {
+            n += 1;
+        } else if (VARIANT_INT16 == t || VARIANT_UINT16 == t) {
+            n += 2;
+        } else if (VARIANT_INT32 == t || VARIANT_UINT32 == t || VARIANT_FLOAT == t) {
+            n += 4;
+        } else if (VARIANT_INT64 == t || VARIANT_DOUBLE == t || VARIANT_TIME == t) {
+            n += 8;
+        } else if (VARIANT_DATE == t) {
+            n += 3; // 24bit
+        } else if (VARIANT_NULL == t || VARIANT_ZERO == t) {
+            // nothing
+        } else if (VARIANT_UUID == t || VARIANT_IPV6 == t) {
+            n += 16; // fixed 16 bytes
+        } else {
+            PANIC("Unsupported column type: %d", t);
+        }
+    }
+    return n;
+}
+
+HOT_PATH
+HOT_PATH
+static int sorter_primary_cmpr(void *o, i64 a, i64 b) {
+    if (a == b) return 0;
+
+    struct sorter *s = (struct sorter*)o;
+    assert(s);
+    assert(s->keys.length > 0);
+    struct flintdb_table *t = s->table;
+    assert(t);
+
+    const struct flintdb_row *r1 = table_read_unlocked(t, a, NULL);
+    const struct flintdb_row *r2 = table_read_unlocked(t, b, NULL);
+    assert(r1);
+    assert(r2);
+    int cmp = 0;
+    char *e = NULL;
+    for(int i=0; i<s->keys.length; i++) {
+        int key = s->keys.a[i];
+        struct flintdb_variant *v1 = r1->get(r1, key, &e);
+        struct flintdb_variant *v2 = r2->get(r2, key, &e);
+        cmp = flintdb_variant_compare(v1, v2);
+        if (cmp != 0) break;
+    }
+
+    if (e) 
+        WARN("%s", e);
+    return cmp;
+}
+
+static int sorter_index_cmpr(void *o, i64 a, i64 b) {
+    if (a == b) return 0;
+
+    struct sorter *s = (struct sorter*)o;
+    assert(s);
+    assert(s->keys.length > 0);
+    struct flintdb_table *t = s->table;
+    assert(t);
+
+    const struct flintdb_row *r1 = table_read_unlocked(t, a, NULL);
+    const struct flintdb_row *r2 = table_read_unlocked(t, b, NULL);
+    assert(r1);
+    assert(r2);
+
+    if (r1->rowid == r2->rowid && r1->rowid != NOT_FOUND) return 0; // avoid unnecessary compare if rowid is same
+
+    int cmp = 0;
+    char *e = NULL;
+    for(int i=0; i<s->keys.length; i++) {
+        int key = s->keys.a[i];
+        struct flintdb_variant *v1 = r1->get(r1, key, &e);
+        struct flintdb_variant *v2 = r2->get(r2, key, &e);
+        cmp = flintdb_variant_compare(v1, v2);
+        if (cmp != 0) break;
+    }
+    if (cmp == 0) cmp = a < b ? -1 : 1; // rows sharing a key, kept apart in rowid order
+
+    if (e) 
+        WARN("%s", e);
+    return cmp;
+}
+
+HOT_PATH
+HOT_PATH
+static int row_compare_get(void *o, const void *a, i64 b) {
+    // DEBUG("o=%p a=%p b=%lld", o, a, b);
+    struct sorter *s = (struct sorter*)o;
+    assert(s);
+    assert(s->keys.length > 0);
+    struct flintdb_table *t = s->table;
+    assert(t);
+
+    const struct flintdb_row *r1 = (const struct flintdb_row *)a;
+    const struct flintdb_row *r2 = table_read_unlocked(t, b, NULL);
+    // DEBUG("r1=%p r2=%p", (void*)r1, (void*)r2);
+    assert(r1);
+    assert(r2);
+    
+    // DEBUG("r1.id=%lld r2.id=%lld", r1->rowid, r2->rowid);
+    if (r1->rowid == r2->rowid && r1->rowid != NOT_FOUND) return 0; // avoid unnecessary compare if rowid is same
+
+    int cmp = 0;
+    char *e = NULL;
+    for(int i=0; i<s->keys.length; i++) {
+        int key = s->keys.a[i];
+        // DEBUG("key[%d]=%d", i, key);
+        struct flintdb_variant *v1 = r1->get(r1, key, &e);
+        struct flintdb_variant *v2 = r2->get(r2, key, &e);
+        // DEBUG("v1=%p v2=%p", (void*)v1, (void*)v2);
+        cmp = flintdb_variant_compare(v1, v2);
+        // DEBUG("cmp=%d", cmp);
+        if (cmp != 0) break;
+    }
+
+    if (e) 
+        WARN("%s", e);
+    return cmp;
+}
+
+// Resolve index ordinal by name (case-insensitive). Defaults to primary when name is null/empty.
+static int meta_index_ordinal(const struct flintdb_meta *m, const char *name) {
+    if (!m || m->indexes.length <= 0) return 0;
+    if (!name || !*name) return 0; // default PRIMARY
+    // PRIMARY is always the first
+    if (strncasecmp(name, PRIMARY_NAME, sizeof(PRIMARY_NAME)-1) == 0) return 0;
+    for (int i = 0; i < m->indexes.length; i++) {
+        if (strncasecmp(m->indexes.a[i].name, name, MAX_COLUMN_NAME_LIMIT-1) == 0) return i;
+    }
+    return 0; // fallback to PRIMARY
+}
+
+static int table_wal_refresh(const void *obj, i64 rowid) {
+    struct flintdb_table *table = (struct flintdb_table *)obj;
+    assert(table);
+    struct flintdb_table_priv* priv = (struct flintdb_table_priv*)table->priv;
+    assert(priv);
+    struct hashmap *cache = priv->cache;
+    assert(cache);
+    // Invalidate cache entry for the updated rowid
+    cache->remove(cache, rowid);
+    return 0; // success
+}
+
+static struct flintdb_table * table_open(const char *file, enum flintdb_open_mode mode, const struct flintdb_meta *meta, u8 mapped, char **e) {
+    struct flintdb_table *table = NULL;
+    struct flintdb_table_priv *priv = NULL;
+    struct wal *wal = NULL;
+    struct flintdb_meta m = {0};
+
+    if (!file) THROW(e, "file is NULL");
+
+    if (NULL == meta) {
+        // read meta from <file>.desc
+        char desc[PATH_MAX] = {0};
+        snprintf(desc, sizeof(desc), "%s%s", file, META_NAME_SUFFIX);
+        if (access(desc, F_OK) != 0) THROW(e, "desc file does not exist: %s", desc);
+        
+        m = flintdb_meta_open(desc, e);
+        if (m.columns.length <= 0) THROW(e, "meta has no columns");
+        if (m.indexes.length == 0) THROW(e, "meta has no indexes");
+    } else if (mode == FLINTDB_RDWR) {
+        if (meta->indexes.length == 0) THROW(e, "meta has no indexes");
+        if ((strncasecmp(meta->storage, TYPE_MEMORY, sizeof(TYPE_MEMORY)-1) == 0)) {
+            // In-memory table: do not write meta to disk
+            memcpy(&m, meta, sizeof(struct flintdb_meta));
+        } else {
+            // write meta to <file>.desc if not exists
+            char dir[PATH_MAX] = {0};
+            getdir(file, dir);
+            mkdirs(dir, S_IRWXU); // ensure directory exists
+
+            char desc[PATH_MAX] = {0}; // <file>.desc
+            snprintf(desc, sizeof(desc), "%s%s", file, META_NAME_SUFFIX); 
+            if (access(desc, F_OK) != 0) {
+                // write meta to <file>.desc
+                if (meta->columns.length <= 0) THROW(e, "meta has no columns");
+                if (flintdb_meta_write(meta, desc, e) != 0) THROW_S(e);
+                memcpy(&m, meta, sizeof(struct flintdb_meta));
+            } else {
+                // read existing meta and compare
+                m = flintdb_meta_open(desc, e);
+                if (m.columns.length <= 0) THROW(e, "existing meta has no columns");
+                if (flintdb_meta_compare(&m, meta) != 0)
+                    THROW(e, "meta does not match existing: %s", desc);
+            }
+        }
+    } else {
+        // read-only with a meta: the table must exist and match it
+        char desc[PATH_MAX] = {0};
+        snprintf(desc, sizeof(desc), "%s%s", file, META_NAME_SUFFIX);
+        if (access(desc, F_OK) == 0) {
+            m = flintdb_meta_open(desc, e);
+            if (m.columns.length <= 0) THROW(e, "existing meta has no columns");
+            if (flintdb_meta_compare(&m, meta) != 0)
+                THROW(e, "meta does not match existing: %s", desc);
+        } else if (storage_vfs_find(meta->storage)) {
+            // a VFS table without a local desc: the caller's meta is its schema
+            if (meta->columns.length <= 0) THROW(e, "meta has no columns");
+            if (meta->indexes.length == 0) THROW(e, "meta has no indexes");
+            memcpy(&m, meta, sizeof(struct flintdb_meta));
+        } else {
+            THROW(e, "desc file does not exist: %s", desc);
+        }
+    }
+    // the row cache size is not part of the schema; the caller's meta decides it
+    if (meta && meta->cache > 0)
+        m.cache = meta->cache;
+    // a WAL_SYNC the caller's meta sets overrides the table's for this open
+    if (meta && meta->wal_sync != WAL_SYNC_DEFAULT)
+        m.wal_sync = meta->wal_sync;
+    // files of a VFS table live in the VFS
+    if (mode == FLINTDB_RDONLY && !storage_vfs_find(m.storage) && access(file, F_OK) != 0)
+        THROW(e, "file does not exist: %s", file);
+
+    u8 compress = compress_format(m.compressor, e);
+    if (e && *e) THROW_S(e);
+    u8 checksum = checksum_format(m.checksum, e);
+    if (e && *e) THROW_S(e);
+
+    table = CALLOC(1, sizeof(struct flintdb_table));
+    if (!table) THROW(e, "Failed to allocate memory for table_priv");
+   
+    priv = CALLOC(1, sizeof(struct flintdb_table_priv));
+    if (!priv) THROW(e, "Failed to allocate memory for table_priv");
+    table->priv = priv;
+    snprintf(priv->file, sizeof(priv->file), "%s", file);
+    priv->mode = mode;
+    priv->meta = m;
+    priv->meta.priv = NULL; // ensure no dangling pointer to local meta
+    priv->row_bytes = row_bytes(&m);
+    if (priv->row_bytes <= 0) THROW(e, "Failed to calculate row bytes");
+    priv->compress = compress;
+    priv->checksum = checksum;
+    // IMPORTANT: bind formatter to the persistent meta stored in priv, not the local 'meta' copy
+    if (formatter_init(FORMAT_BIN, &priv->meta, &priv->formatter, e) != 0) THROW_S(e);
+
+    // Initialize reusable raw buffer pool using generic buffer_pool
+    // capacity: 32 slots, align(min capacity): row_bytes, preload: 8
+    priv->raw_pool = buffer_pool_create(32, (u32)priv->row_bytes, 8);
+
+    struct storage_opts opts = {
+        .block_bytes = row_bytes(&m),
+        // Honor meta.increment when provided; fallback to default if not set
+        .increment = (m.increment > 0 ? (i32)m.increment : DEFAULT_STORAGE_INCREMENT),
+        .mode = mode,
+        // compressed rows take as many small blocks as they need
+        .compact = (compress && m.compact <= 0) ? (priv->row_bytes < COMPRESSED_BLOCK_BYTES ? priv->row_bytes : COMPRESSED_BLOCK_BYTES) : m.compact,
+        .mapped = mapped,
+    };
+    strncpy_safe(opts.file, file, sizeof(opts.file));
+    strncpy_safe(opts.type, strempty(m.storage) ? "" : m.storage, sizeof(opts.type));
+    
+    char wal_file[PATH_MAX] = {0};
+    snprintf(wal_file, sizeof(wal_file), "%s%s", file, ".wal");
+
+    // wal = &WAL_NONE; // disable WAL for testing
+    wal = (mode == FLINTDB_RDONLY || strempty(priv->meta.wal) || 0 == strncasecmp(priv->meta.wal, WAL_OPT_OFF, sizeof(WAL_OPT_OFF)-1) || 0 == strncasecmp(priv->meta.storage, TYPE_MEMORY, sizeof(TYPE_MEMORY)-1))
+        ? &WAL_NONE 
+        : wal_open(wal_file, (const struct flintdb_meta *)&priv->meta, e);
+    if (e && *e) THROW_S(e);
+    assert(wal);
+    priv->wal = wal;
+    priv->storage = wal_wrap(wal, &opts, table_wal_refresh, table, e);
+    if (e && *e) THROW_S(e);
+    if (!priv->storage) THROW(e, "Failed to allocate memory for storage");
+
+    // LRU cache
+    i32 cache_limit = priv->meta.cache; 
+    if (cache_limit <= DEFAULT_TABLE_CACHE_LIMIT) cache_limit = DEFAULT_TABLE_CACHE_LIMIT;
+    if (mode == FLINTDB_RDONLY) cache_limit = cache_limit / 2; // smaller cache for read-only tables
+    if (cache_limit < DEFAULT_TABLE_CACHE_MIN) cache_limit = DEFAULT_TABLE_CACHE_MIN;
+    priv->cache = lruhashmap_new(cache_limit * 2, cache_limit, &hashmap_int_hash, &hashmap_int_cmpr);
+
+    if (!priv->cache) THROW(e, "Failed to create row cache");
+
+    // Initialize table-level lock (os_unfair_lock on macOS, spinlock on Linux)
+    TABLE_LOCK_INIT(&priv->lock);
+
+    // priv->header = priv->storage->mmap(priv->storage, 0, HEAD_SZ, NULL);
+    priv->header = priv->storage->head(priv->storage, 0, HEAD_SZ, NULL);
+
+    if (mode & FLINTDB_RDWR) {
+        struct buffer h = {0, };
+        struct buffer p = {0, };
+        priv->header->slice(priv->header, 0, HEAD_SZ, &h, e);
+        h.slice(&h, 0, HEAD_SZ, &p, e);
+        p.i32_get(&p, NULL);
+        if (0 == p.i32_get(&p, NULL)) {
+            h.array_put(&h, SIGNATURE, 4, NULL);
+            h.i32_put(&h, 1, NULL); // version
+        }
+        //p.free(&p); // uneccessary
+        //h.free(&h); // uneccessary
+    }
+
+    priv->sorters.length = m.indexes.length;
+    for(int i=0; i<m.indexes.length; i++) {
+        struct sorter *s = &priv->sorters.s[i];
+        s->table = table;
+        strncpy_safe(s->name, m.indexes.a[i].name, sizeof(s->name));
+        strncpy_safe(s->algorithm, "bptree", sizeof(s->algorithm)); // currently only bptree is supported
+
+        if (i == 0 && strncasecmp(PRIMARY_NAME, s->name, sizeof(PRIMARY_NAME)) != 0) 
+            THROW(e, "The first index must set to primary key");
+
+        char ixf[PATH_MAX] = {0};
+        snprintf(ixf, sizeof(ixf), "%s.i.%s", file, s->name);
+
+        // map this index's key names to column indices
+        const struct flintdb_index *idx = &m.indexes.a[i];
+        s->keys.length = idx->keys.length;
+        for (int j = 0; j < idx->keys.length; j++) {
+            int c = flintdb_column_at(&priv->meta, idx->keys.a[j]);
+            assert(c != -1);
+            s->keys.a[j] = c;
+            DEBUG("%s[%d] column:%s => %d", s->name, j, idx->keys.a[j], s->keys.a[j]);
+        }
+
+        if (i == 0) {
+            bplustree_init(&s->tree, ixf, cache_limit * 1, mode, priv->meta.storage, s, &sorter_primary_cmpr, wal, e);
+            if (e && *e) THROW_S(e);
+        } else {
+            bplustree_init(&s->tree, ixf, cache_limit * 1, mode, priv->meta.storage, s, &sorter_index_cmpr, wal, e);
+            if (e && *e)  THROW_S(e);
+        }
+    }
+
+    // After a crash, every file has now been repaired: end the recovery
+    wal->recover(wal, e);
+    if (e && *e) THROW_S(e);
+
+    //  
+    table->rows = table_rows;
+    table->bytes = table_bytes;
+    table->meta = table_meta;
+    table->apply = table_apply;
+    table->apply_at = table_apply_at;
+    table->delete_at = table_delete;
+    // table->find = table_find;
+    table->find = table_find_where;
+    table->one = table_one;
+    table->read = table_read;
+    table->read_stream = table_read_stream;
+    table->close = table_close;
+
+    DEBUG("table: %s (mode=%d) meta: columns=%d indexes=%d cache=%d storage=%s wal=%s increment=%d", 
+        file, 
+        mode, 
+        priv->meta.columns.length, 
+        priv->meta.indexes.length, 
+        cache_limit, 
+        priv->meta.storage, 
+        priv->meta.wal,
+        priv->meta.increment
+    );
+    return table;
+
+    EXCEPTION:
+    if (table) 
+        table_close(table);
+    return NULL;
+}
+
+struct flintdb_table * flintdb_table_open(const char *file, enum flintdb_open_mode mode, const struct flintdb_meta *meta, char **e) {
+    return table_open(file, mode, meta, 0, e);
+}
+
+struct flintdb_table * flintdb_table_open_mapped(const char *file, const struct flintdb_meta *meta, char **e) {
+    return table_open(file, FLINTDB_RDONLY, meta, 1, e);
+}
+
+i64 flintdb_cursor_scanned(const struct flintdb_cursor_i64 *c) {
+    if (!c || c->next != find_next) return 0;
+    const struct find_context *ctx = (const struct find_context *)c->p;
+    return ctx ? ctx->scanned : 0;
+}
+
+void flintdb_cursor_deadline(struct flintdb_cursor_i64 *c, i64 deadline) {
+    if (!c || c->next != find_next || !c->p) return;
+    ((struct find_context *)c->p)->deadline = deadline;
+}
+
+void flintdb_table_cache_stats(const struct flintdb_table *table, i64 *hits, i64 *misses) {
+    const struct flintdb_table_priv *priv = table ? (const struct flintdb_table_priv *)table->priv : NULL;
+    if (hits) *hits = priv ? priv->cache_hits : 0;
+    if (misses) *misses = priv ? priv->cache_misses : 0;
+}
+
+i64 flintdb_table_lookup(struct flintdb_table *table, int index, const struct flintdb_row *key, char **e) {
+    struct flintdb_table_priv *priv = table ? (struct flintdb_table_priv *)table->priv : NULL;
+    if (!priv || !key) THROW(e, "lookup needs a table and a key");
+    if (index < 0 || index >= priv->sorters.length) THROW(e, "no index %d", index);
+
+    struct sorter *s = &priv->sorters.s[index];
+    TABLE_LOCK(&priv->lock);
+    i64 rowid = s->tree.compare_get(&s->tree, s, key, row_compare_get, e);
+    TABLE_UNLOCK(&priv->lock);
+    if (e && *e) THROW_S(e);
+    return rowid < 0 ? NOT_FOUND : rowid;
+
+    EXCEPTION:
+    return NOT_FOUND;
+}
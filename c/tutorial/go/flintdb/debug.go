@@ -0,0 +1,143 @@
+//go:build flintdb_debug
+
+package flintdb
+
+// Built with -tags flintdb_debug, the wrapper tracks each table, file, cursor
+// and owned row holding C memory, with the stack that created it. Handles
+// still open when their table or file is closed, and all still open at
+// Cleanup, are reported as leaks to the logger set with SetLogger, or to
+// stderr without one. Tables, files and cursors also panic when a goroutine
+// calls one of their methods while another goroutine is inside one.
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+type trackedHandle struct {
+	kind  string
+	owner interface{} // table or file the handle came from, if any
+	stack string
+}
+
+var tracked struct {
+	sync.Mutex
+	handles map[interface{}]trackedHandle
+}
+
+// trackHandle records h, a handle of kind created from owner, until
+// releaseHandle.
+func trackHandle(kind string, h, owner interface{}) {
+	pc := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pc[:runtime.Callers(3, pc)])
+	var b strings.Builder
+	for {
+		f, more := frames.Next()
+		fmt.Fprintf(&b, "%s\n\t%s:%d\n", f.Function, f.File, f.Line)
+		if !more {
+			break
+		}
+	}
+	tracked.Lock()
+	defer tracked.Unlock()
+	if tracked.handles == nil {
+		tracked.handles = map[interface{}]trackedHandle{}
+	}
+	tracked.handles[h] = trackedHandle{kind: kind, owner: owner, stack: b.String()}
+}
+
+// releaseHandle forgets h, reporting the handles created from it that are
+// still open.
+func releaseHandle(h interface{}) {
+	tracked.Lock()
+	delete(tracked.handles, h)
+	var leaked []trackedHandle
+	for x, th := range tracked.handles {
+		if th.owner == h {
+			leaked = append(leaked, th)
+			delete(tracked.handles, x)
+		}
+	}
+	tracked.Unlock()
+	for _, th := range leaked {
+		reportLeak(th)
+	}
+}
+
+// reportLeaks reports every handle still open.
+func reportLeaks() {
+	tracked.Lock()
+	handles := tracked.handles
+	tracked.handles = nil
+	tracked.Unlock()
+	for _, th := range handles {
+		reportLeak(th)
+	}
+}
+
+func reportLeak(th trackedHandle) {
+	if logger.Load() != nil {
+		logEvent(slog.LevelWarn, "leaked handle", "kind", th.kind, "stack", th.stack)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "flintdb: leaked %s, created at:\n%s", th.kind, th.stack)
+}
+
+// Leaks describes the tables, files, cursors and rows not yet closed or
+// freed, each with the stack that created it. It is empty unless built with
+// -tags flintdb_debug.
+func Leaks() []string {
+	tracked.Lock()
+	defer tracked.Unlock()
+	var leaks []string
+	for _, th := range tracked.handles {
+		leaks = append(leaks, fmt.Sprintf("%s, created at:\n%s", th.kind, th.stack))
+	}
+	return leaks
+}
+
+// handleUse records the goroutine inside a method of a handle that needs one
+// goroutine at a time. Methods calling each other on the same goroutine nest.
+type handleUse struct {
+	g     atomic.Int64
+	depth int
+}
+
+// enter marks the handle, kind name, as used by the calling goroutine, and
+// panics if another goroutine is using it.
+func (u *handleUse) enter(kind, name string) {
+	id := goid()
+	for !u.g.CompareAndSwap(0, id) {
+		other := u.g.Load()
+		if other == id {
+			break
+		}
+		if other != 0 {
+			panic(fmt.Sprintf("flintdb: %s %s used by goroutine %d while goroutine %d is using it; it needs one goroutine at a time", kind, name, id, other))
+		}
+	}
+	u.depth++
+}
+
+func (u *handleUse) leave() {
+	if u.depth--; u.depth == 0 {
+		u.g.Store(0)
+	}
+}
+
+// goid returns the id of the calling goroutine, from its stack header.
+func goid() int64 {
+	var buf [64]byte
+	s := strings.TrimPrefix(string(buf[:runtime.Stack(buf[:], false)]), "goroutine ")
+	if i := strings.IndexByte(s, ' '); i > 0 {
+		s = s[:i]
+	}
+	id, _ := strconv.ParseInt(s, 10, 64)
+	return id
+}
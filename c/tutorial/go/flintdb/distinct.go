@@ -0,0 +1,142 @@
+package flintdb
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// WithDistinct makes a find return one row, the first found, of each
+// distinct combination of the values of columns, in ascending order of them.
+// When the leading keys of an index are the columns, the find walks that
+// index; otherwise the rows are sorted in a temporary file. A WithOrderBy
+// alongside it must name the same columns, in any order and direction. A
+// LIMIT of the query counts distinct rows.
+func WithDistinct(columns ...string) QueryOption {
+	return func(o *queryOptions) {
+		o.distinct = columns
+	}
+}
+
+// distinctOrder resolves the keys of WithOrderBy and WithDistinct to the
+// order rows are found in.
+func (t *Table) distinctOrder(orderBy, distinct []string) ([]orderKey, error) {
+	if len(orderBy) == 0 {
+		return t.orderKeys(distinct)
+	}
+	order, err := t.orderKeys(orderBy)
+	if err != nil || len(distinct) == 0 {
+		return order, err
+	}
+	columns, err := t.orderKeys(distinct)
+	if err != nil {
+		return nil, err
+	}
+	same := len(columns) == len(order)
+	for _, c := range columns {
+		found := false
+		for _, k := range order {
+			found = found || k.index == c.index
+		}
+		same = same && found
+	}
+	if !same {
+		return nil, &FlintDBError{Message: fmt.Sprintf("order by must name the distinct columns: %s", strings.Join(distinct, ", "))}
+	}
+	return order, nil
+}
+
+// distinctRows returns the first rowid of each run of equal keys query
+// finds, with the query's LIMIT counting runs; query walks an index in the
+// order of the keys.
+func (t *Table) distinctRows(query string, order []orderKey, timeout time.Duration) ([]int64, error) {
+	query, offset, limit := splitLimit(query)
+	cursor, err := t.find(query, queryOptions{timeout: timeout})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close()
+	var rows []int64
+	var last []interface{}
+	for skip := offset; limit < 0 || len(rows) < limit; {
+		rowid, err := cursor.Next()
+		if err != nil {
+			return nil, err
+		}
+		if rowid < 0 {
+			break
+		}
+		row, err := t.Read(rowid)
+		if err != nil {
+			return nil, err
+		}
+		values := make([]interface{}, len(order))
+		for i, k := range order {
+			if values[i], err = row.Get(k.index); err != nil {
+				break
+			}
+		}
+		row.Free()
+		if err != nil {
+			return nil, err
+		}
+		if last != nil && sameValues(last, values) {
+			continue
+		}
+		last = values
+		if skip > 0 {
+			skip--
+			continue
+		}
+		rows = append(rows, rowid)
+	}
+	return rows, nil
+}
+
+func sameValues(a, b []interface{}) bool {
+	for i := range a {
+		switch x := a[i].(type) {
+		case []byte:
+			if y, ok := b[i].([]byte); !ok || !bytes.Equal(x, y) {
+				return false
+			}
+		case time.Time:
+			if y, ok := b[i].(time.Time); !ok || !x.Equal(y) {
+				return false
+			}
+		default:
+			if a[i] != b[i] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Distinct returns the distinct combinations of the values of columns in
+// the rows query finds, in ascending order, as for a dropdown of the values
+// a filter can take. It is Select of the rows WithDistinct finds.
+func (t *Table) Distinct(query string, columns ...string) ([][]interface{}, error) {
+	indexes, err := t.projection(columns)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := t.Find(query, WithDistinct(columns...))
+	if err != nil {
+		return nil, err
+	}
+	cursor := &CursorValues{table: t, rows: rows, columns: indexes}
+	defer cursor.Close()
+	var values [][]interface{}
+	for {
+		v, err := cursor.Next()
+		if err != nil {
+			return nil, err
+		}
+		if v == nil {
+			return values, nil
+		}
+		values = append(values, v)
+	}
+}
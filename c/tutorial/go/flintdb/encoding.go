@@ -0,0 +1,129 @@
+package flintdb
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/ianaindex"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// SetEncoding sets the character encoding of delimited files by its IANA
+// name or alias, such as UTF-8 (the default), UTF-16, Shift_JIS or
+// ISO-8859-1. Rows hold UTF-8; GenericFile, GenericReader and GenericWriter
+// convert as they read and write.
+func (m *Meta) SetEncoding(name string) error {
+	enc, err := ianaindex.IANA.Encoding(name)
+	if err != nil || enc == nil {
+		return &FlintDBError{Message: fmt.Sprintf("unsupported encoding: %s", name)}
+	}
+	if enc == unicode.UTF8 {
+		enc = nil
+	}
+	m.encoding = enc
+	return nil
+}
+
+// encodedFile is the UTF-8 copy the engine reads and writes in place of a
+// GenericFile in another encoding.
+type encodedFile struct {
+	path     string
+	temp     string
+	encoding encoding.Encoding
+	writable bool
+}
+
+// openEncoded decodes path, if it exists, into a temporary UTF-8 file. The
+// copy keeps the text format's extension but not the compression.
+func openEncoded(path string, mode uint32, enc encoding.Encoding) (*encodedFile, error) {
+	if strings.HasSuffix(path, ".zst") {
+		return nil, &FlintDBError{Message: fmt.Sprintf("cannot convert the encoding of zstd file: %s", path)}
+	}
+	dir, err := os.MkdirTemp("", "flintdb-encoding-")
+	if err != nil {
+		return nil, err
+	}
+	x := &encodedFile{path: path, temp: filepath.Join(dir, strings.TrimSuffix(filepath.Base(path), ".gz")), encoding: enc, writable: mode != FLINTDB_RDONLY}
+	if err := x.decode(); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	return x, nil
+}
+
+func (x *encodedFile) decode() error {
+	in, err := os.Open(x.path)
+	if os.IsNotExist(err) && x.writable {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	var r io.Reader = in
+	if strings.HasSuffix(x.path, ".gz") {
+		gz, err := gzip.NewReader(in)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	out, err := os.Create(x.temp)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, transform.NewReader(r, x.encoding.NewDecoder())); err != nil {
+		out.Close()
+		return &FlintDBError{Message: fmt.Sprintf("%s: %v", x.path, err)}
+	}
+	return out.Close()
+}
+
+// close encodes the UTF-8 copy back to path if the file was writable, and
+// removes the copy.
+func (x *encodedFile) close() error {
+	defer os.RemoveAll(filepath.Dir(x.temp))
+	if !x.writable {
+		return nil
+	}
+	in, err := os.Open(x.temp)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(x.path)
+	if err != nil {
+		return err
+	}
+	var w io.Writer = out
+	var gz *gzip.Writer
+	if strings.HasSuffix(x.path, ".gz") {
+		gz = gzip.NewWriter(out)
+		w = gz
+	}
+	tw := transform.NewWriter(w, x.encoding.NewEncoder())
+	_, err = io.Copy(tw, in)
+	if err == nil {
+		err = tw.Close()
+	}
+	if err == nil && gz != nil {
+		err = gz.Close()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return &FlintDBError{Message: fmt.Sprintf("%s: %v", x.path, err)}
+	}
+	return nil
+}
@@ -0,0 +1,297 @@
+package flintdb
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Formats for Table.Export and Table.Import.
+const (
+	FORMAT_CSV   = "csv"
+	FORMAT_TSV   = "tsv"
+	FORMAT_JSON  = "json"  // one array of objects
+	FORMAT_JSONL = "jsonl" // one object per line
+)
+
+const (
+	exportDateLayout = "2006-01-02"
+	exportTimeLayout = "2006-01-02 15:04:05"
+)
+
+type exportColumn struct {
+	name  string
+	index int
+	kind  int
+}
+
+// exportColumns returns the table's columns without collation key columns.
+func (t *Table) exportColumns() []exportColumn {
+	var columns []exportColumn
+	for i := 0; i < int(t.meta.columns.length); i++ {
+		hidden := false
+		for _, cc := range t.collated {
+			hidden = hidden || cc.keyIndex == i
+		}
+		if !hidden {
+			c := &t.meta.columns.a[i]
+			columns = append(columns, exportColumn{name: cstring(c.name[:]), index: i, kind: int(c._type)})
+		}
+	}
+	return columns
+}
+
+// Columns returns the names of the table's columns, without collation key
+// columns.
+func (t *Table) Columns() []string {
+	var names []string
+	for _, c := range t.exportColumns() {
+		names = append(names, c.name)
+	}
+	return names
+}
+
+// Column describes a column of a table.
+type Column struct {
+	Name      string
+	Type      int // a VARIANT_ type
+	Size      int // bytes of a STRING or BYTES value
+	Precision int
+	NotNull   bool
+}
+
+// ColumnTypes describes the table's columns, in the order of Columns.
+func (t *Table) ColumnTypes() []Column {
+	var columns []Column
+	for _, c := range t.exportColumns() {
+		mc := &t.meta.columns.a[c.index]
+		columns = append(columns, Column{Name: c.name, Type: c.kind, Size: int(mc.bytes), Precision: int(mc.precision), NotNull: mc.nullspec == SPEC_NOT_NULL})
+	}
+	return columns
+}
+
+// ExportTransform changes the values of a row, in the order of columns,
+// before ExportContext writes them, such as to mask them.
+type ExportTransform func(columns []string, values []interface{}) error
+
+type exportTransformKey struct{}
+
+// ContextWithExportTransform returns ctx with fn applied to each row
+// ExportContext writes with it.
+func ContextWithExportTransform(ctx context.Context, fn ExportTransform) context.Context {
+	return context.WithValue(ctx, exportTransformKey{}, fn)
+}
+
+// Export writes the rows matching query to w in format. header adds a line
+// of column names to CSV and TSV. NULL is written as an empty CSV field, \N
+// in TSV and null in JSON; dates and times are in UTC and bytes in hex.
+func (t *Table) Export(w io.Writer, format string, query string, header bool) error {
+	return t.ExportContext(context.Background(), w, format, query, header)
+}
+
+// ExportContext is Export with its progress reported to the ProgressFunc of
+// ctx, the total of rows known for an empty query only, and its rows changed
+// by the ExportTransform of ctx.
+func (t *Table) ExportContext(ctx context.Context, w io.Writer, format string, query string, header bool) error {
+	total := int64(-1)
+	if strings.TrimSpace(query) == "" {
+		if rows, err := t.Rows(); err == nil {
+			total = rows
+		}
+	}
+	prog := progressOf(ctx, "export", t.path, total, -1)
+	if prog != nil {
+		w = progressWriter{w: w, p: prog}
+		defer prog.done()
+	}
+	columns := t.exportColumns()
+	transform, _ := ctx.Value(exportTransformKey{}).(ExportTransform)
+	var names []string
+	if transform != nil {
+		names = t.Columns()
+	}
+	bw := bufio.NewWriter(w)
+	var write func(values []interface{}) error
+	var end func() error
+
+	switch format {
+	case FORMAT_CSV:
+		cw := csv.NewWriter(bw)
+		record := make([]string, len(columns))
+		write = func(values []interface{}) error {
+			for i, v := range values {
+				record[i] = ""
+				if v != nil {
+					record[i] = exportText(v, columns[i].kind)
+				}
+			}
+			return cw.Write(record)
+		}
+		end = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+		if header {
+			names := make([]string, len(columns))
+			for i, c := range columns {
+				names[i] = c.name
+			}
+			if err := cw.Write(names); err != nil {
+				return err
+			}
+		}
+	case FORMAT_TSV:
+		write = func(values []interface{}) error {
+			for i, v := range values {
+				if i > 0 {
+					bw.WriteByte('\t')
+				}
+				if v == nil {
+					bw.WriteString(`\N`)
+				} else {
+					bw.WriteString(escapeTSV(exportText(v, columns[i].kind)))
+				}
+			}
+			return bw.WriteByte('\n')
+		}
+		if header {
+			for i, c := range columns {
+				if i > 0 {
+					bw.WriteByte('\t')
+				}
+				bw.WriteString(escapeTSV(c.name))
+			}
+			bw.WriteByte('\n')
+		}
+	case FORMAT_JSON, FORMAT_JSONL:
+		first := true
+		write = func(values []interface{}) error {
+			switch {
+			case format == FORMAT_JSONL:
+			case first:
+				bw.WriteString("[\n")
+			default:
+				bw.WriteString(",\n")
+			}
+			first = false
+			bw.WriteByte('{')
+			for i, v := range values {
+				if i > 0 {
+					bw.WriteByte(',')
+				}
+				key, _ := json.Marshal(columns[i].name)
+				bw.Write(key)
+				bw.WriteByte(':')
+				value, err := exportJSON(v, columns[i].kind)
+				if err != nil {
+					return err
+				}
+				bw.Write(value)
+			}
+			bw.WriteByte('}')
+			if format == FORMAT_JSONL {
+				bw.WriteByte('\n')
+			}
+			return nil
+		}
+		end = func() error {
+			if format == FORMAT_JSON {
+				if first {
+					bw.WriteString("[")
+				}
+				bw.WriteString("\n]\n")
+			}
+			return nil
+		}
+	default:
+		return &FlintDBError{Message: fmt.Sprintf("unsupported format: %s", format)}
+	}
+
+	cursor, err := t.Find(query)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close()
+	values := make([]interface{}, len(columns))
+	for {
+		rowid, err := cursor.Next()
+		if err != nil {
+			return err
+		}
+		if rowid < 0 {
+			break
+		}
+		row, err := t.Read(rowid)
+		if err != nil {
+			return err
+		}
+		for i, c := range columns {
+			if values[i], err = row.Get(c.index); err != nil {
+				return err
+			}
+		}
+		if transform != nil {
+			if err := transform(names, values); err != nil {
+				return err
+			}
+		}
+		if err := write(values); err != nil {
+			return err
+		}
+		prog.add(1, 0)
+	}
+	if end != nil {
+		if err := end(); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+func exportText(v interface{}, kind int) string {
+	switch v := v.(type) {
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case string:
+		return v
+	case time.Time:
+		if kind == VARIANT_DATE {
+			return v.UTC().Format(exportDateLayout)
+		}
+		return v.UTC().Format(exportTimeLayout)
+	case []byte:
+		return hex.EncodeToString(v)
+	}
+	return fmt.Sprint(v)
+}
+
+var tsvEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+func escapeTSV(s string) string {
+	return tsvEscaper.Replace(s)
+}
+
+func exportJSON(v interface{}, kind int) ([]byte, error) {
+	switch x := v.(type) {
+	case nil:
+		return []byte("null"), nil
+	case float64:
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return []byte("null"), nil
+		}
+		return json.Marshal(x)
+	case int64:
+		return json.Marshal(x)
+	}
+	return json.Marshal(exportText(v, kind))
+}
@@ -0,0 +1,840 @@
+package flintdb
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// expr is a compiled expression evaluated against a row. Expressions use the
+// same column names and literal syntax as WHERE clauses, plus arithmetic,
+// IS [NOT] NULL, IN (...), [NOT] LIKE, GLOB and REGEXP, and a few scalar
+// functions. Values are nil, bool,
+// int64, float64, string, []byte or time.Time.
+type expr interface {
+	eval(row *Row) (interface{}, error)
+}
+
+type exprLiteral struct{ value interface{} }
+
+type exprColumn struct {
+	name  string
+	index int
+}
+
+type exprUnary struct {
+	op      string
+	operand expr
+}
+
+type exprBinary struct {
+	op          string
+	left, right expr
+	fold        bool // compare strings after case folding
+}
+
+type exprIsNull struct {
+	operand expr
+	not     bool
+}
+
+type exprIn struct {
+	operand expr
+	list    []expr
+	not     bool
+}
+
+type exprMatch struct {
+	op      string // LIKE, GLOB or REGEXP
+	operand expr
+	pattern expr
+	not     bool
+	fold    bool
+	re      *regexp.Regexp // of a literal pattern, compiled once
+}
+
+type exprCall struct {
+	name string
+	fn   exprFunc
+	args []expr
+}
+
+type exprFunc func(args []interface{}) (interface{}, error)
+
+var exprFuncs = map[string]exprFunc{
+	"length": func(args []interface{}) (interface{}, error) {
+		if err := exprArity("length", args, 1); err != nil || args[0] == nil {
+			return nil, err
+		}
+		return int64(len([]rune(exprString(args[0])))), nil
+	},
+	"lower": func(args []interface{}) (interface{}, error) {
+		if err := exprArity("lower", args, 1); err != nil || args[0] == nil {
+			return nil, err
+		}
+		return strings.ToLower(exprString(args[0])), nil
+	},
+	"upper": func(args []interface{}) (interface{}, error) {
+		if err := exprArity("upper", args, 1); err != nil || args[0] == nil {
+			return nil, err
+		}
+		return strings.ToUpper(exprString(args[0])), nil
+	},
+	"trim": func(args []interface{}) (interface{}, error) {
+		if err := exprArity("trim", args, 1); err != nil || args[0] == nil {
+			return nil, err
+		}
+		return strings.TrimSpace(exprString(args[0])), nil
+	},
+	"abs": func(args []interface{}) (interface{}, error) {
+		if err := exprArity("abs", args, 1); err != nil || args[0] == nil {
+			return nil, err
+		}
+		switch v := args[0].(type) {
+		case int64:
+			if v < 0 {
+				return -v, nil
+			}
+			return v, nil
+		case float64:
+			return math.Abs(v), nil
+		}
+		return nil, &FlintDBError{Message: fmt.Sprintf("abs: not a number: %v", args[0])}
+	},
+	"coalesce": func(args []interface{}) (interface{}, error) {
+		for _, arg := range args {
+			if arg != nil {
+				return arg, nil
+			}
+		}
+		return nil, nil
+	},
+}
+
+func init() {
+	exprFuncs["lowercase"] = exprFuncs["lower"]
+	exprFuncs["uppercase"] = exprFuncs["upper"]
+}
+
+func exprArity(name string, args []interface{}, n int) error {
+	if len(args) != n {
+		return &FlintDBError{Message: fmt.Sprintf("%s: expected %d argument(s), got %d", name, n, len(args))}
+	}
+	return nil
+}
+
+func exprString(v interface{}) string {
+	switch s := v.(type) {
+	case string:
+		return s
+	case []byte:
+		return string(s)
+	}
+	return fmt.Sprint(v)
+}
+
+func (x *exprLiteral) eval(row *Row) (interface{}, error) { return x.value, nil }
+
+func (x *exprColumn) eval(row *Row) (interface{}, error) { return row.Get(x.index) }
+
+func (x *exprUnary) eval(row *Row) (interface{}, error) {
+	v, err := x.operand.eval(row)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	switch x.op {
+	case "NOT":
+		b, ok := v.(bool)
+		if !ok {
+			return nil, &FlintDBError{Message: fmt.Sprintf("NOT: not a boolean: %v", v)}
+		}
+		return !b, nil
+	case "-":
+		switch n := v.(type) {
+		case int64:
+			return -n, nil
+		case float64:
+			return -n, nil
+		}
+		return nil, &FlintDBError{Message: fmt.Sprintf("-: not a number: %v", v)}
+	}
+	return nil, &FlintDBError{Message: "unknown operator: " + x.op}
+}
+
+func (x *exprBinary) eval(row *Row) (interface{}, error) {
+	left, err := x.left.eval(row)
+	if err != nil {
+		return nil, err
+	}
+
+	// AND/OR follow SQL three-valued logic and short-circuit where possible
+	switch x.op {
+	case "AND":
+		if left == false {
+			return false, nil
+		}
+		right, err := x.right.eval(row)
+		if err != nil {
+			return nil, err
+		}
+		if right == false {
+			return false, nil
+		}
+		if left == nil || right == nil {
+			return nil, nil
+		}
+		return true, nil
+	case "OR":
+		if left == true {
+			return true, nil
+		}
+		right, err := x.right.eval(row)
+		if err != nil {
+			return nil, err
+		}
+		if right == true {
+			return true, nil
+		}
+		if left == nil || right == nil {
+			return nil, nil
+		}
+		return false, nil
+	}
+
+	right, err := x.right.eval(row)
+	if err != nil {
+		return nil, err
+	}
+	if left == nil || right == nil {
+		return nil, nil
+	}
+
+	switch x.op {
+	case "=", "!=", "<", "<=", ">", ">=":
+		if x.fold {
+			if l, ok := left.(string); ok {
+				if r, ok := right.(string); ok {
+					left, right = foldCase(l), foldCase(r)
+				}
+			}
+		}
+		c, err := compareValues(left, right)
+		if err != nil {
+			return nil, err
+		}
+		switch x.op {
+		case "=":
+			return c == 0, nil
+		case "!=":
+			return c != 0, nil
+		case "<":
+			return c < 0, nil
+		case "<=":
+			return c <= 0, nil
+		case ">":
+			return c > 0, nil
+		default:
+			return c >= 0, nil
+		}
+	case "||":
+		return exprString(left) + exprString(right), nil
+	default:
+		return arithmetic(x.op, left, right)
+	}
+}
+
+func (x *exprIsNull) eval(row *Row) (interface{}, error) {
+	v, err := x.operand.eval(row)
+	if err != nil {
+		return nil, err
+	}
+	return (v == nil) != x.not, nil
+}
+
+func (x *exprIn) eval(row *Row) (interface{}, error) {
+	v, err := x.operand.eval(row)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	for _, item := range x.list {
+		candidate, err := item.eval(row)
+		if err != nil {
+			return nil, err
+		}
+		if candidate == nil {
+			continue
+		}
+		c, err := compareValues(v, candidate)
+		if err != nil {
+			return nil, err
+		}
+		if c == 0 {
+			return !x.not, nil
+		}
+	}
+	return x.not, nil
+}
+
+func (x *exprMatch) eval(row *Row) (interface{}, error) {
+	v, err := x.operand.eval(row)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	re := x.re
+	if re == nil {
+		pattern, err := x.pattern.eval(row)
+		if err != nil || pattern == nil {
+			return nil, err
+		}
+		if re, err = compilePattern(x.op, exprString(pattern), x.fold); err != nil {
+			return nil, err
+		}
+	}
+	return re.MatchString(exprString(v)) != x.not, nil
+}
+
+// compilePattern compiles a pattern of op to a regular expression. LIKE
+// matches the whole string, with % for any run of characters and _ for one;
+// GLOB also matches the whole string, with *, ? and [...] classes, [!...]
+// negated; REGEXP is RE2 syntax matching anywhere in the string. With fold
+// the pattern ignores case.
+func compilePattern(op, pattern string, fold bool) (*regexp.Regexp, error) {
+	var b strings.Builder
+	if fold {
+		b.WriteString("(?i)")
+	}
+	if op == "REGEXP" {
+		return regexp.Compile(b.String() + pattern)
+	}
+	b.WriteString("(?s)^")
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		switch r := runes[i]; {
+		case op == "LIKE" && r == '%', op == "GLOB" && r == '*':
+			b.WriteString(".*")
+		case op == "LIKE" && r == '_', op == "GLOB" && r == '?':
+			b.WriteString(".")
+		case op == "GLOB" && r == '[':
+			end := i + 1
+			if end < len(runes) && (runes[end] == '!' || runes[end] == '^') {
+				end++
+			}
+			if end < len(runes) && runes[end] == ']' {
+				end++ // a leading ] is a member of the class
+			}
+			for end < len(runes) && runes[end] != ']' {
+				end++
+			}
+			if end == len(runes) {
+				b.WriteString(regexp.QuoteMeta(string(r)))
+				continue
+			}
+			class := runes[i+1 : end]
+			b.WriteString("[")
+			if class[0] == '!' || class[0] == '^' {
+				b.WriteString("^")
+				class = class[1:]
+			}
+			for _, c := range class {
+				if c == '\\' || c == '[' || c == ']' {
+					b.WriteString("\\")
+				}
+				b.WriteRune(c)
+			}
+			b.WriteString("]")
+			i = end
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+func (x *exprCall) eval(row *Row) (interface{}, error) {
+	args := make([]interface{}, len(x.args))
+	for i, arg := range x.args {
+		v, err := arg.eval(row)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	return x.fn(args)
+}
+
+// compareValues orders two non-NULL values, coercing integers and floats.
+func compareValues(a, b interface{}) (int, error) {
+	switch x := a.(type) {
+	case int64:
+		switch y := b.(type) {
+		case int64:
+			return compareOrdered(x, y), nil
+		case float64:
+			return compareOrdered(float64(x), y), nil
+		}
+	case float64:
+		switch y := b.(type) {
+		case int64:
+			return compareOrdered(x, float64(y)), nil
+		case float64:
+			return compareOrdered(x, y), nil
+		}
+	case string:
+		if y, ok := b.(string); ok {
+			return strings.Compare(x, y), nil
+		}
+	case []byte:
+		if y, ok := b.([]byte); ok {
+			return bytes.Compare(x, y), nil
+		}
+	case bool:
+		if y, ok := b.(bool); ok {
+			if x == y {
+				return 0, nil
+			}
+			if !x {
+				return -1, nil
+			}
+			return 1, nil
+		}
+	case time.Time:
+		if y, ok := b.(time.Time); ok {
+			return x.Compare(y), nil
+		}
+	}
+	return 0, &FlintDBError{Message: fmt.Sprintf("cannot compare %T with %T", a, b)}
+}
+
+func compareOrdered[T int64 | float64](a, b T) int {
+	if a < b {
+		return -1
+	}
+	if a > b {
+		return 1
+	}
+	return 0
+}
+
+func arithmetic(op string, a, b interface{}) (interface{}, error) {
+	x, xInt := a.(int64)
+	y, yInt := b.(int64)
+	if xInt && yInt {
+		switch op {
+		case "+":
+			return x + y, nil
+		case "-":
+			return x - y, nil
+		case "*":
+			return x * y, nil
+		case "/", "%":
+			if y == 0 {
+				return nil, &FlintDBError{Message: "division by zero"}
+			}
+			if op == "/" {
+				return x / y, nil
+			}
+			return x % y, nil
+		}
+	}
+
+	fx, ok1 := toFloat(a)
+	fy, ok2 := toFloat(b)
+	if !ok1 || !ok2 {
+		return nil, &FlintDBError{Message: fmt.Sprintf("%s: not a number: %v %s %v", op, a, op, b)}
+	}
+	switch op {
+	case "+":
+		return fx + fy, nil
+	case "-":
+		return fx - fy, nil
+	case "*":
+		return fx * fy, nil
+	case "/":
+		if fy == 0 {
+			return nil, &FlintDBError{Message: "division by zero"}
+		}
+		return fx / fy, nil
+	case "%":
+		if fy == 0 {
+			return nil, &FlintDBError{Message: "division by zero"}
+		}
+		return math.Mod(fx, fy), nil
+	}
+	return nil, &FlintDBError{Message: "unknown operator: " + op}
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+// parseExpr compiles src, resolving column names with columnAt.
+func parseExpr(src string, columnAt func(string) int) (expr, error) {
+	return parseFoldedExpr(src, columnAt, nil)
+}
+
+// parseFoldedExpr is parseExpr with comparisons and patterns on the columns
+// folded reports made after case folding.
+func parseFoldedExpr(src string, columnAt func(string) int, folded func(int) bool) (expr, error) {
+	tokens, err := tokenizeExpr(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{tokens: tokens, columnAt: columnAt, folded: folded}
+	x, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, p.errorf("unexpected %q", p.tokens[p.pos].text)
+	}
+	return x, nil
+}
+
+type exprTokenKind int
+
+const (
+	tokenIdent exprTokenKind = iota
+	tokenNumber
+	tokenString
+	tokenSymbol
+)
+
+type exprToken struct {
+	kind exprTokenKind
+	text string
+	pos  int
+	end  int // offset just past the token, including quotes
+}
+
+func tokenizeExpr(src string) ([]exprToken, error) {
+	var tokens []exprToken
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '\'' || c == '"':
+			end := strings.IndexByte(src[i+1:], c)
+			if end < 0 {
+				return nil, &FlintDBError{Message: fmt.Sprintf("unterminated string literal at %d", i)}
+			}
+			tokens = append(tokens, exprToken{kind: tokenString, text: src[i+1 : i+1+end], pos: i, end: i + end + 2})
+			i += end + 2
+		case c >= '0' && c <= '9' || c == '.' && i+1 < len(src) && src[i+1] >= '0' && src[i+1] <= '9':
+			start := i
+			for i < len(src) && (src[i] >= '0' && src[i] <= '9' || src[i] == '.' || src[i] == 'e' || src[i] == 'E' ||
+				(src[i] == '+' || src[i] == '-') && (src[i-1] == 'e' || src[i-1] == 'E')) {
+				i++
+			}
+			tokens = append(tokens, exprToken{kind: tokenNumber, text: src[start:i], pos: start, end: i})
+		case c == '_' || unicode.IsLetter(rune(c)):
+			start := i
+			for i < len(src) && (src[i] == '_' || unicode.IsLetter(rune(src[i])) || unicode.IsDigit(rune(src[i]))) {
+				i++
+			}
+			tokens = append(tokens, exprToken{kind: tokenIdent, text: src[start:i], pos: start, end: i})
+		default:
+			if i+1 < len(src) {
+				switch src[i : i+2] {
+				case "<=", ">=", "<>", "!=", "==", "||":
+					tokens = append(tokens, exprToken{kind: tokenSymbol, text: src[i : i+2], pos: i, end: i + 2})
+					i += 2
+					continue
+				}
+			}
+			if !strings.ContainsRune("=<>+-*/%(),", rune(c)) {
+				return nil, &FlintDBError{Message: fmt.Sprintf("unexpected character %q at %d", c, i)}
+			}
+			tokens = append(tokens, exprToken{kind: tokenSymbol, text: string(c), pos: i, end: i + 1})
+			i++
+		}
+	}
+	return tokens, nil
+}
+
+type exprParser struct {
+	tokens   []exprToken
+	pos      int
+	columnAt func(string) int
+	folded   func(int) bool
+}
+
+// folds reports whether comparisons on x are made after case folding.
+func (p *exprParser) folds(x expr) bool {
+	col, ok := x.(*exprColumn)
+	return ok && p.folded != nil && p.folded(col.index)
+}
+
+func (p *exprParser) errorf(format string, args ...interface{}) error {
+	return &FlintDBError{Message: "expression: " + fmt.Sprintf(format, args...)}
+}
+
+func (p *exprParser) peek() *exprToken {
+	if p.pos < len(p.tokens) {
+		return &p.tokens[p.pos]
+	}
+	return nil
+}
+
+// keyword consumes the next token if it is the given case-insensitive keyword.
+func (p *exprParser) keyword(kw string) bool {
+	if t := p.peek(); t != nil && t.kind == tokenIdent && strings.EqualFold(t.text, kw) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) symbol(syms ...string) string {
+	if t := p.peek(); t != nil && t.kind == tokenSymbol {
+		for _, s := range syms {
+			if t.text == s {
+				p.pos++
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+func (p *exprParser) parseOr() (expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("OR") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &exprBinary{op: "OR", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseAnd() (expr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("AND") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &exprBinary{op: "AND", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseNot() (expr, error) {
+	if p.keyword("NOT") {
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &exprUnary{op: "NOT", operand: operand}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *exprParser) parseComparison() (expr, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.keyword("IS") {
+		not := p.keyword("NOT")
+		if !p.keyword("NULL") {
+			return nil, p.errorf("expected NULL after IS")
+		}
+		return &exprIsNull{operand: left, not: not}, nil
+	}
+
+	start := p.pos
+	not := p.keyword("NOT")
+	if p.keyword("IN") {
+		list, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+		return &exprIn{operand: left, list: list, not: not}, nil
+	}
+	for _, op := range []string{"LIKE", "GLOB", "REGEXP"} {
+		if !p.keyword(op) {
+			continue
+		}
+		pattern, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		x := &exprMatch{op: op, operand: left, pattern: pattern, not: not, fold: p.folds(left)}
+		if lit, ok := pattern.(*exprLiteral); ok && lit.value != nil {
+			if x.re, err = compilePattern(op, exprString(lit.value), x.fold); err != nil {
+				return nil, p.errorf("%v", err)
+			}
+		}
+		return x, nil
+	}
+	p.pos = start
+
+	if op := p.symbol("=", "==", "!=", "<>", "<", "<=", ">", ">="); op != "" {
+		switch op {
+		case "==":
+			op = "="
+		case "<>":
+			op = "!="
+		}
+		right, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		return &exprBinary{op: op, left: left, right: right, fold: p.folds(left) || p.folds(right)}, nil
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseList() ([]expr, error) {
+	if p.symbol("(") == "" {
+		return nil, p.errorf("expected (")
+	}
+	var list []expr
+	if p.symbol(")") != "" {
+		return list, nil
+	}
+	for {
+		item, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, item)
+		if p.symbol(")") != "" {
+			return list, nil
+		}
+		if p.symbol(",") == "" {
+			return nil, p.errorf("expected , or )")
+		}
+	}
+}
+
+func (p *exprParser) parseAdditive() (expr, error) {
+	left, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := p.symbol("+", "-", "||")
+		if op == "" {
+			return left, nil
+		}
+		right, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = &exprBinary{op: op, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseMultiplicative() (expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := p.symbol("*", "/", "%")
+		if op == "" {
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &exprBinary{op: op, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseUnary() (expr, error) {
+	if p.symbol("-") != "" {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &exprUnary{op: "-", operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (expr, error) {
+	t := p.peek()
+	if t == nil {
+		return nil, p.errorf("unexpected end of input")
+	}
+	p.pos++
+
+	switch t.kind {
+	case tokenNumber:
+		if i, err := strconv.ParseInt(t.text, 10, 64); err == nil {
+			return &exprLiteral{value: i}, nil
+		}
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, p.errorf("invalid number %q", t.text)
+		}
+		return &exprLiteral{value: f}, nil
+	case tokenString:
+		return &exprLiteral{value: t.text}, nil
+	case tokenSymbol:
+		if t.text == "(" {
+			x, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if p.symbol(")") == "" {
+				return nil, p.errorf("expected )")
+			}
+			return x, nil
+		}
+		return nil, p.errorf("unexpected %q", t.text)
+	}
+
+	switch strings.ToUpper(t.text) {
+	case "NULL":
+		return &exprLiteral{value: nil}, nil
+	case "TRUE":
+		return &exprLiteral{value: true}, nil
+	case "FALSE":
+		return &exprLiteral{value: false}, nil
+	}
+
+	if p.symbol("(") != "" {
+		fn, ok := exprFuncs[strings.ToLower(t.text)]
+		if !ok {
+			return nil, p.errorf("unknown function %s", t.text)
+		}
+		p.pos--
+		args, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+		return &exprCall{name: strings.ToLower(t.text), fn: fn, args: args}, nil
+	}
+
+	index := p.columnAt(t.text)
+	if index < 0 {
+		return nil, p.errorf("column not found: %s", t.text)
+	}
+	return &exprColumn{name: t.text, index: index}, nil
+}
@@ -0,0 +1,47 @@
+package flintdb
+
+import (
+	"fmt"
+	"os"
+)
+
+// lockSuffix names the file WithFileLock locks next to a table.
+const lockSuffix = ".lock"
+
+// WithFileLock makes the table hold a lock on its .lock file while it is
+// open, so that no other process, nor another handle in this one, opens the
+// table for writing meanwhile: FLINTDB_RDWR takes the lock exclusively and
+// FLINTDB_RDONLY shares it. An open that cannot get the lock fails at once
+// rather than waiting. The lock is advisory, ignored by opens without this
+// option; it uses flock on Unix and LockFileEx on Windows.
+func WithFileLock() OpenOption {
+	return func(o *openOptions) {
+		o.fileLock = true
+	}
+}
+
+// lockTable opens and locks the .lock file of the table at path.
+func lockTable(path string, mode uint32) (*os.File, error) {
+	flag := os.O_RDONLY | os.O_CREATE
+	if mode == FLINTDB_RDWR {
+		flag = os.O_RDWR | os.O_CREATE
+	}
+	f, err := os.OpenFile(path+lockSuffix, flag, 0644)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := tryLockFile(f, mode == FLINTDB_RDWR)
+	if err == nil && !ok {
+		err = &FlintDBError{Message: fmt.Sprintf("table is locked by another handle: %s", path)}
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+func unlockTable(f *os.File) {
+	unlockFile(f)
+	f.Close()
+}
@@ -0,0 +1,16 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd || windows)
+
+package flintdb
+
+import (
+	"os"
+	"runtime"
+)
+
+func tryLockFile(f *os.File, exclusive bool) (bool, error) {
+	return false, &FlintDBError{Message: "file locks are not supported on " + runtime.GOOS}
+}
+
+func unlockFile(f *os.File) error {
+	return nil
+}
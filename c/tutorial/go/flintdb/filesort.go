@@ -0,0 +1,37 @@
+package flintdb
+
+/*
+#include <stdint.h>
+#include "flintdb.h"
+*/
+import "C"
+import "runtime/cgo"
+
+// filesortFunc is the comparison of a SortFunc call, reached from the
+// engine's sort through a cgo.Handle.
+type filesortFunc struct {
+	cmp      func(a, b *Row) int
+	meta     *C.struct_flintdb_meta
+	panicked interface{} // recovered from cmp, rethrown once the sort returns
+}
+
+//export flintdbFilesortCompare
+func flintdbFilesortCompare(h C.uintptr_t, a, b *C.struct_flintdb_row) (c C.int) {
+	f := cgo.Handle(h).Value().(*filesortFunc)
+	if f.panicked != nil {
+		return 0
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			f.panicked = p
+			c = 0
+		}
+	}()
+	switch r := f.cmp(&Row{inner: a, meta: f.meta}, &Row{inner: b, meta: f.meta}); {
+	case r < 0:
+		return -1
+	case r > 0:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,149 @@
+package flintdb
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// findInBatch is how many values one query of FindIn tests on a column no
+// index leads with.
+const findInBatch = 100
+
+// FindIn returns the rows whose column equals one of values, a slice such as
+// []int64 or []string, however many it holds. When an index leads with
+// column, each distinct value is one probe of it and the rows come in the
+// order of values; otherwise the values are tested in batches of 100, each
+// one scan of the table. Nil values match nothing, as in SQL.
+func (t *Table) FindIn(column string, values interface{}) (*CursorInt64, error) {
+	list := reflect.ValueOf(values)
+	if list.Kind() != reflect.Slice && list.Kind() != reflect.Array {
+		return nil, &FlintDBError{Message: fmt.Sprintf("values must be a slice, got %T", values)}
+	}
+	if t.columnAt(column) < 0 {
+		return nil, &FlintDBError{Message: fmt.Sprintf("column not found: %s", column)}
+	}
+	var keys []interface{}
+	var literals []string
+	seen := map[string]bool{}
+	for i := 0; i < list.Len(); i++ {
+		v := list.Index(i).Interface()
+		if v == nil {
+			continue
+		}
+		literal, err := formatLiteral(v)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[literal] {
+			seen[literal] = true
+			keys = append(keys, v)
+			literals = append(literals, literal)
+		}
+	}
+
+	ordinal, index := t.leadingIndex(column)
+	var rows []int64
+	var err error
+	switch {
+	case ordinal == 0 && int(t.meta.indexes.a[0].keys.length) == 1:
+		rows, err = t.lookupIn(column, keys)
+	case ordinal >= 0:
+		rows, err = t.probeIn(index, column, literals)
+	default:
+		rows, err = t.scanIn(column, literals)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &CursorInt64{rows: rows}, nil
+}
+
+// leadingIndex returns the ordinal and name of the first index whose first
+// key is column, or -1.
+func (t *Table) leadingIndex(column string) (int, string) {
+	for i := 0; i < int(t.meta.indexes.length); i++ {
+		name := cstring(t.meta.indexes.a[i].name[:])
+		if _, columns, err := t.indexKeys(name); err == nil && strings.EqualFold(columns[0], column) {
+			return i, name
+		}
+	}
+	return -1, ""
+}
+
+// lookupIn probes the primary index, keyed by column alone, once per key.
+func (t *Table) lookupIn(column string, keys []interface{}) ([]int64, error) {
+	probe, err := t.CreateRow()
+	if err != nil {
+		return nil, err
+	}
+	defer probe.Free()
+	var rows []int64
+	for _, key := range keys {
+		if err := probe.SetByName(column, key); err != nil {
+			return nil, err
+		}
+		if err := t.fillCollationKeys(probe); err != nil {
+			return nil, err
+		}
+		rowid, err := t.lookup(0, probe)
+		if err != nil {
+			return nil, err
+		}
+		if rowid >= 0 {
+			rows = append(rows, rowid)
+		}
+	}
+	return rows, nil
+}
+
+// probeIn walks index, which leads with column, once per literal.
+func (t *Table) probeIn(index, column string, literals []string) ([]int64, error) {
+	var rows []int64
+	for _, literal := range literals {
+		found, err := t.findAll(fmt.Sprintf("USE INDEX(%s) WHERE %s = %s", index, column, literal))
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, found...)
+	}
+	return rows, nil
+}
+
+// scanIn tests column against the literals in batches of findInBatch.
+func (t *Table) scanIn(column string, literals []string) ([]int64, error) {
+	var rows []int64
+	for start := 0; start < len(literals); start += findInBatch {
+		batch := literals[start:min(start+findInBatch, len(literals))]
+		conds := make([]string, len(batch))
+		for i, literal := range batch {
+			conds[i] = column + " = " + literal
+		}
+		found, err := t.findAll("WHERE " + strings.Join(conds, " OR "))
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, found...)
+	}
+	return rows, nil
+}
+
+// findAll returns every rowid query finds.
+func (t *Table) findAll(query string) ([]int64, error) {
+	cursor, err := t.Find(query)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close()
+	var rows []int64
+	for {
+		rowid, err := cursor.Next()
+		if err != nil {
+			return nil, err
+		}
+		if rowid < 0 {
+			return rows, nil
+		}
+		rows = append(rows, rowid)
+	}
+}
@@ -0,0 +1,140 @@
+//go:build arrow
+
+package flintdb
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/apache/arrow-go/v18/arrow/flight"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+)
+
+// FlightServer serves registered tables over Arrow Flight. A ticket names a
+// table, optionally followed by a newline and a query; DoGet streams the
+// matching rows as ToArrow does. DoPut inserts the uploaded records into the
+// table named by the descriptor's path, as InsertArrow does, and answers
+// with the row count. Calls run one at a time, since tables are not safe for
+// concurrent use.
+type FlightServer struct {
+	flight.BaseFlightServer
+	mu     sync.Mutex
+	tables map[string]*Table
+	server flight.Server
+}
+
+func NewFlightServer() *FlightServer {
+	return &FlightServer{tables: map[string]*Table{}}
+}
+
+// Register serves t under name. The server does not close registered tables.
+func (s *FlightServer) Register(name string, t *Table) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tables[name] = t
+}
+
+// ListenAndServe serves on addr, such as "localhost:8815", until Shutdown.
+func (s *FlightServer) ListenAndServe(addr string) error {
+	server := flight.NewServerWithMiddleware(nil)
+	if err := server.Init(addr); err != nil {
+		return err
+	}
+	server.RegisterFlightService(s)
+	s.mu.Lock()
+	s.server = server
+	s.mu.Unlock()
+	return server.Serve()
+}
+
+// Shutdown stops the server after the calls in progress.
+func (s *FlightServer) Shutdown() {
+	s.mu.Lock()
+	server := s.server
+	s.mu.Unlock()
+	if server != nil {
+		server.Shutdown()
+	}
+}
+
+// table returns the table named by a ticket and the ticket's query.
+func (s *FlightServer) table(ticket []byte) (*Table, string, error) {
+	name, query, _ := strings.Cut(string(ticket), "\n")
+	t, ok := s.tables[name]
+	if !ok {
+		return nil, "", &FlintDBError{Message: fmt.Sprintf("no such table: %s", name)}
+	}
+	return t, query, nil
+}
+
+// GetFlightInfo describes the rows of a command descriptor holding a ticket,
+// or of a whole table named by a path descriptor.
+func (s *FlightServer) GetFlightInfo(_ context.Context, desc *flight.FlightDescriptor) (*flight.FlightInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ticket := desc.Cmd
+	if desc.Type == flight.DescriptorPATH && len(desc.Path) > 0 {
+		ticket = []byte(desc.Path[0])
+	}
+	t, _, err := s.table(ticket)
+	if err != nil {
+		return nil, err
+	}
+	return &flight.FlightInfo{
+		Schema:           flight.SerializeSchema(t.arrowSchema(), memory.DefaultAllocator),
+		FlightDescriptor: desc,
+		Endpoint:         []*flight.FlightEndpoint{{Ticket: &flight.Ticket{Ticket: ticket}}},
+		TotalRecords:     -1,
+		TotalBytes:       -1,
+	}, nil
+}
+
+func (s *FlightServer) DoGet(tkt *flight.Ticket, stream flight.FlightService_DoGetServer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, query, err := s.table(tkt.Ticket)
+	if err != nil {
+		return err
+	}
+	w := flight.NewRecordWriter(stream, ipc.WithSchema(t.arrowSchema()))
+	if err := t.eachArrow(query, w.Write); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func (s *FlightServer) DoPut(stream flight.FlightService_DoPutServer) error {
+	r, err := flight.NewRecordReader(stream)
+	if err != nil {
+		return err
+	}
+	defer r.Release()
+	desc := r.LatestFlightDescriptor()
+	if desc == nil || len(desc.Path) == 0 {
+		return &FlintDBError{Message: "DoPut needs a path descriptor naming the table"}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tables[desc.Path[0]]
+	if !ok {
+		return &FlintDBError{Message: fmt.Sprintf("no such table: %s", desc.Path[0])}
+	}
+	var inserted int64
+	for r.Next() {
+		n, err := t.InsertArrow(r.Record())
+		inserted += n
+		if err != nil {
+			return err
+		}
+	}
+	if err := r.Err(); err != nil {
+		return err
+	}
+	return stream.Send(&flight.PutResult{AppMetadata: []byte(strconv.FormatInt(inserted, 10))})
+}
@@ -2,11 +2,13 @@
 package flintdb
 
 /*
-#cgo CFLAGS: -I../../../src
-#cgo LDFLAGS: -L../../../lib -lflintdb
 #include "flintdb.h"
+#include <stdint.h>
 #include <stdlib.h>
 #include <string.h>
+#include <unistd.h>
+#include <limits.h>
+#include <stdio.h>
 
 static void row_free_wrapper(struct flintdb_row *r) {
     if (r && r->free) r->free(r);
@@ -20,14 +22,126 @@ static void row_i64_set_wrapper(struct flintdb_row *r, int col_idx, long long va
     if (r && r->i64_set) r->i64_set(r, col_idx, value, e);
 }
 
-static void row_string_set_wrapper(struct flintdb_row *r, int col_idx, const char *value, char **e) {
-    if (r && r->string_set) r->string_set(r, col_idx, value, e);
+static void row_string_set_wrapper(struct flintdb_row *r, int col_idx, const char *value, unsigned int length, char **e) {
+    if (!r || !r->get) return;
+    struct flintdb_variant *v = r->get(r, col_idx, e);
+    if (v) flintdb_variant_string_set(v, value, length);
 }
 
 static void row_f64_set_wrapper(struct flintdb_row *r, int col_idx, double value, char **e) {
     if (r && r->f64_set) r->f64_set(r, col_idx, value, e);
 }
 
+static void row_cast_set_variant(struct flintdb_row *r, int col_idx, struct flintdb_variant *v, char **e) {
+    if (r && r->set) r->set(r, col_idx, v, e);
+    flintdb_variant_free(v);
+}
+
+static void row_i64_cast_set_wrapper(struct flintdb_row *r, int col_idx, long long value, char **e) {
+    struct flintdb_variant v;
+    flintdb_variant_init(&v);
+    flintdb_variant_i64_set(&v, value);
+    row_cast_set_variant(r, col_idx, &v, e);
+}
+
+static void row_f64_cast_set_wrapper(struct flintdb_row *r, int col_idx, double value, char **e) {
+    struct flintdb_variant v;
+    flintdb_variant_init(&v);
+    flintdb_variant_f64_set(&v, value);
+    row_cast_set_variant(r, col_idx, &v, e);
+}
+
+static void row_string_cast_set_wrapper(struct flintdb_row *r, int col_idx, const char *value, unsigned int length, char **e) {
+    struct flintdb_variant v;
+    flintdb_variant_init(&v);
+    flintdb_variant_string_set(&v, value, length);
+    row_cast_set_variant(r, col_idx, &v, e);
+}
+
+static void row_bytes_cast_set_wrapper(struct flintdb_row *r, int col_idx, const char *value, unsigned int length, char **e) {
+    struct flintdb_variant v;
+    flintdb_variant_init(&v);
+    flintdb_variant_bytes_set(&v, value, length);
+    row_cast_set_variant(r, col_idx, &v, e);
+}
+
+static void row_time_cast_set_wrapper(struct flintdb_row *r, int col_idx, long long value, char **e) {
+    struct flintdb_variant v;
+    flintdb_variant_init(&v);
+    flintdb_variant_time_set(&v, (time_t)value);
+    row_cast_set_variant(r, col_idx, &v, e);
+}
+
+static void row_null_set_wrapper(struct flintdb_row *r, int col_idx, char **e) {
+    struct flintdb_variant v;
+    flintdb_variant_init(&v);
+    flintdb_variant_null_set(&v);
+    row_cast_set_variant(r, col_idx, &v, e);
+}
+
+static int row_i32_get_wrapper(const struct flintdb_row *r, int col_idx, char **e) {
+    if (r && r->i32_get) return r->i32_get(r, col_idx, e);
+    return 0;
+}
+
+static long long row_i64_get_wrapper(const struct flintdb_row *r, int col_idx, char **e) {
+    if (r && r->i64_get) return r->i64_get(r, col_idx, e);
+    return 0;
+}
+
+static const char* row_string_get_wrapper(const struct flintdb_row *r, int col_idx, char **e) {
+    if (r && r->string_get) return r->string_get(r, col_idx, e);
+    return NULL;
+}
+
+static double row_f64_get_wrapper(const struct flintdb_row *r, int col_idx, char **e) {
+    if (r && r->f64_get) return r->f64_get(r, col_idx, e);
+    return 0;
+}
+
+static int row_is_nil_wrapper(const struct flintdb_row *r, int col_idx, char **e) {
+    if (r && r->is_nil) return r->is_nil(r, col_idx, e);
+    return 1;
+}
+
+static int row_type_wrapper(const struct flintdb_row *r, int col_idx, char **e) {
+    if (!r || !r->get) return VARIANT_NULL;
+    struct flintdb_variant *v = r->get(r, col_idx, e);
+    return v ? v->type : VARIANT_NULL;
+}
+
+static const char* row_bytes_get_wrapper(const struct flintdb_row *r, int col_idx, unsigned int *length, char **e) {
+    if (r && r->bytes_get) return r->bytes_get(r, col_idx, length, e);
+    return NULL;
+}
+
+// the string or bytes of a column in the row's own storage, not copied
+static const char* row_string_ref_wrapper(const struct flintdb_row *r, int col_idx, unsigned int *length, char **e) {
+    *length = 0;
+    if (!r || !r->get) return NULL;
+    struct flintdb_variant *v = r->get(r, col_idx, e);
+    if (!v || (v->type != VARIANT_STRING && v->type != VARIANT_BYTES)) return NULL;
+    *length = v->value.b.length;
+    return v->value.b.data;
+}
+
+static long long row_time_get_wrapper(const struct flintdb_row *r, int col_idx, char **e) {
+    if (r && r->time_get) return (long long)r->time_get(r, col_idx, e);
+    return 0;
+}
+
+static long long row_date_get_wrapper(const struct flintdb_row *r, int col_idx, char **e) {
+    if (r && r->date_get) return (long long)r->date_get(r, col_idx, e);
+    return 0;
+}
+
+static int row_variant_to_string_wrapper(const struct flintdb_row *r, int col_idx, char *out, unsigned int len, char **e) {
+    if (!r || !r->get) return -1;
+    struct flintdb_variant *v = r->get(r, col_idx, e);
+    if (!v) return -1;
+    return flintdb_variant_to_string(v, out, len);
+}
+
 static void table_close_wrapper(struct flintdb_table *t) {
     if (t && t->close) t->close(t);
 }
@@ -47,16 +161,117 @@ static long long table_delete_at_wrapper(struct flintdb_table *t, long long rowi
     return -1;
 }
 
+static long long table_rows_wrapper(const struct flintdb_table *t, char **e) {
+    if (t && t->rows) return t->rows(t, e);
+    return -1;
+}
+
 static const struct flintdb_row* table_read_wrapper(struct flintdb_table *t, long long rowid, char **e) {
     if (t && t->read) return t->read(t, rowid, e);
     return NULL;
 }
 
+static int table_read_stream_wrapper(struct flintdb_table *t, long long rowid, struct flintdb_row *r, char **e) {
+    if (t && t->read_stream) return t->read_stream(t, rowid, r, e);
+    return -1;
+}
+
+// read column col of count rows into out, one call for all of them; each
+// returns how many rows were read, stopping at the first error or missing row
+static long long table_read_column_i64_wrapper(struct flintdb_table *t, int col, const long long *rowids, long long count, long long *out, char **e) {
+    if (!t || !t->read) return 0;
+    long long i = 0;
+    for (; i < count; i++) {
+        const struct flintdb_row *r = t->read(t, rowids[i], e);
+        if (!r || (e && *e)) break;
+        out[i] = r->i64_get(r, col, e);
+        if (e && *e) break;
+    }
+    return i;
+}
+
+static long long table_read_column_f64_wrapper(struct flintdb_table *t, int col, const long long *rowids, long long count, double *out, char **e) {
+    if (!t || !t->read) return 0;
+    long long i = 0;
+    for (; i < count; i++) {
+        const struct flintdb_row *r = t->read(t, rowids[i], e);
+        if (!r || (e && *e)) break;
+        out[i] = r->f64_get(r, col, e);
+        if (e && *e) break;
+    }
+    return i;
+}
+
+// strings are appended to *data, which the caller frees, with their lengths
+// in lengths; NULL is empty
+static long long table_read_column_string_wrapper(struct flintdb_table *t, int col, const long long *rowids, long long count, unsigned int *lengths, char **data, char **e) {
+    *data = NULL;
+    if (!t || !t->read) return 0;
+    size_t size = 0, capacity = 0;
+    long long i = 0;
+    for (; i < count; i++) {
+        const struct flintdb_row *r = t->read(t, rowids[i], e);
+        if (!r || (e && *e)) break;
+        struct flintdb_variant *v = r->get(r, col, e);
+        if (!v || (e && *e)) break;
+        const char *s = NULL;
+        size_t n = 0;
+        if (v->type == VARIANT_STRING || v->type == VARIANT_BYTES) {
+            s = v->value.b.data;
+            n = v->value.b.length;
+        } else if (v->type != VARIANT_NULL) {
+            s = flintdb_variant_string_get(v);
+            n = s ? strlen(s) : 0;
+        }
+        if (size + n > capacity) {
+            capacity = (size + n) * 2 + 64;
+            char *grown = realloc(*data, capacity);
+            if (!grown) {
+                if (e) *e = "out of memory";
+                break;
+            }
+            *data = grown;
+        }
+        if (n) memcpy(*data + size, s, n);
+        size += n;
+        lengths[i] = (unsigned int)n;
+    }
+    return i;
+}
+
 static struct flintdb_cursor_i64* table_find_wrapper(struct flintdb_table *t, const char *query, char **e) {
     if (t && t->find) return t->find(t, query, e);
     return NULL;
 }
 
+static struct flintdb_table* table_open_mode(const char *file, enum flintdb_open_mode mode, const struct flintdb_meta *meta, int mapped, char **e) {
+    if (mapped) return flintdb_table_open_mapped(file, meta, e);
+    return flintdb_table_open(file, mode, meta, e);
+}
+
+// Opens a table whose row cache holds cache rows instead of the size in its
+// schema, read-only over a whole-file mapping if mapped is set; with a NULL
+// meta the schema is read from <file>.desc.
+static struct flintdb_table* table_open_wrapper(const char *file, enum flintdb_open_mode mode, const struct flintdb_meta *meta, int cache, int sync, int mapped, char **e) {
+    if (cache <= 0 && sync == WAL_SYNC_DEFAULT) return table_open_mode(file, mode, meta, mapped, e);
+    if (meta) {
+        struct flintdb_meta m = *meta;
+        if (cache > 0) m.cache = cache;
+        if (sync != WAL_SYNC_DEFAULT) m.wal_sync = sync;
+        return table_open_mode(file, mode, &m, mapped, e);
+    }
+    char desc[PATH_MAX];
+    snprintf(desc, sizeof(desc), "%s%s", file, META_NAME_SUFFIX);
+    if (access(desc, F_OK) != 0) return table_open_mode(file, mode, NULL, mapped, e);
+    struct flintdb_meta m = flintdb_meta_open(desc, e);
+    if (e && *e) return NULL;
+    if (cache > 0) m.cache = cache;
+    if (sync != WAL_SYNC_DEFAULT) m.wal_sync = sync;
+    struct flintdb_table *t = table_open_mode(file, mode, &m, mapped, e);
+    flintdb_meta_close(&m);
+    return t;
+}
+
 static const struct flintdb_meta* table_meta_wrapper(const struct flintdb_table *t, char **e) {
     if (t && t->meta) return t->meta(t, e);
     return NULL;
@@ -98,11 +313,222 @@ static struct flintdb_row* cursor_row_next_wrapper(struct flintdb_cursor_row *c,
     if (c && c->next) return c->next(c, e);
     return NULL;
 }
+
+// tags of the values InsertBatch packs (batch.go), each followed by its
+// value in native byte order: 8 bytes for numbers and times, a u32 length
+// and the data for strings and bytes, nothing for defaults and NULL
+enum { PACKED_DEFAULT, PACKED_NULL, PACKED_INT64, PACKED_DOUBLE, PACKED_STRING, PACKED_BYTES, PACKED_TIME };
+
+// inserts count packed rows of columns values each; returns how many were
+// inserted, stopping at the first error
+static long long table_apply_packed_wrapper(struct flintdb_table *t, struct flintdb_meta *m, const char *p, long long count, int columns, i8 upsert, char **e) {
+    if (!t || !t->apply) return 0;
+    long long applied = 0;
+    for (; applied < count; applied++) {
+        struct flintdb_row *r = flintdb_row_new(m, e);
+        if (!r) break;
+        for (int i = 0; i < columns && !(e && *e); i++) {
+            struct flintdb_variant v;
+            flintdb_variant_init(&v);
+            long long x;
+            double d;
+            unsigned int n;
+            switch (*p++) {
+            case PACKED_DEFAULT:
+                continue;
+            case PACKED_NULL:
+                flintdb_variant_null_set(&v);
+                break;
+            case PACKED_INT64:
+                memcpy(&x, p, 8); p += 8;
+                flintdb_variant_i64_set(&v, x);
+                break;
+            case PACKED_DOUBLE:
+                memcpy(&d, p, 8); p += 8;
+                flintdb_variant_f64_set(&v, d);
+                break;
+            case PACKED_STRING:
+                memcpy(&n, p, 4); p += 4;
+                flintdb_variant_string_set(&v, p, n); p += n;
+                break;
+            case PACKED_BYTES:
+                memcpy(&n, p, 4); p += 4;
+                flintdb_variant_bytes_set(&v, p, n); p += n;
+                break;
+            case PACKED_TIME:
+                memcpy(&x, p, 8); p += 8;
+                flintdb_variant_time_set(&v, (time_t)x);
+                break;
+            }
+            row_cast_set_variant(r, i, &v, e);
+        }
+        if (!(e && *e)) t->apply(t, r, upsert, e);
+        r->free(r);
+        if (e && *e) break;
+    }
+    return applied;
+}
+
+static void filesort_close_wrapper(struct flintdb_filesort *s) {
+    if (s && s->close) s->close(s);
+}
+
+static long long filesort_add_wrapper(struct flintdb_filesort *s, struct flintdb_row *r, char **e) {
+    if (s && s->add) return s->add(s, r, e);
+    return -1;
+}
+
+static long long filesort_rows_wrapper(const struct flintdb_filesort *s) {
+    if (s && s->rows) return s->rows(s);
+    return 0;
+}
+
+// the engine leaves the row's offset in the sort file as its rowid, which a
+// table would take for the rowid of an existing row
+static struct flintdb_row* filesort_read_wrapper(const struct flintdb_filesort *s, long long i, char **e) {
+    if (!s || !s->read) return NULL;
+    struct flintdb_row *r = s->read(s, i, e);
+    if (r) r->rowid = -1;
+    return r;
+}
+
+struct filesort_keys {
+    const int *columns;
+    const i8 *desc;
+    int count;
+};
+
+static int row_column_compare_wrapper(const struct flintdb_row *a, const struct flintdb_row *b, int col_idx) {
+    char *e = NULL;
+    return flintdb_variant_compare(a->get(a, col_idx, &e), b->get(b, col_idx, &e));
+}
+
+static int filesort_keys_compare(const void *obj, const struct flintdb_row *a, const struct flintdb_row *b) {
+    const struct filesort_keys *k = (const struct filesort_keys *)obj;
+    for (int i = 0; i < k->count; i++) {
+        int c = row_column_compare_wrapper(a, b, k->columns[i]);
+        if (c != 0) return k->desc[i] ? -c : c;
+    }
+    return 0;
+}
+
+static int filesort_keys_compare_wrapper(const struct flintdb_row *a, const struct flintdb_row *b, const int *columns, const i8 *desc, int count) {
+    struct filesort_keys k = {columns, desc, count};
+    return filesort_keys_compare(&k, a, b);
+}
+
+// sorts, then drops duplicates if unique and keeps the first top rows if top > 0
+static long long filesort_sort_wrapper(struct flintdb_filesort *s, int (*cmpr)(const void *, const struct flintdb_row *, const struct flintdb_row *), const void *ctx, int unique, long long top, char **e) {
+    if (!s || !s->sort) return -1;
+    long long n = s->sort(s, cmpr, ctx, e);
+    if (n >= 0 && unique) n = flintdb_filesort_unique(s, cmpr, ctx, e);
+    if (n >= 0 && top > 0) n = flintdb_filesort_limit(s, top);
+    return n;
+}
+
+static long long filesort_sort_keys_wrapper(struct flintdb_filesort *s, const int *columns, const i8 *desc, int count, int unique, long long top, char **e) {
+    struct filesort_keys k = {columns, desc, count};
+    return filesort_sort_wrapper(s, filesort_keys_compare, &k, unique, top, e);
+}
+
+// implemented in Go (filesort.go); h is the cgo.Handle of the comparison
+extern int flintdbFilesortCompare(uintptr_t h, struct flintdb_row *a, struct flintdb_row *b);
+
+static int filesort_func_compare(const void *obj, const struct flintdb_row *a, const struct flintdb_row *b) {
+    return flintdbFilesortCompare((uintptr_t)obj, (struct flintdb_row *)a, (struct flintdb_row *)b);
+}
+
+static long long filesort_sort_func_wrapper(struct flintdb_filesort *s, uintptr_t h, int unique, long long top, char **e) {
+    return filesort_sort_wrapper(s, filesort_func_compare, (const void *)h, unique, top, e);
+}
+
+// implemented in Go (vfs.go); ctx and files are cgo.Handles, and errors are
+// written to the buffer passed
+extern uintptr_t flintdbVFSOpen(uintptr_t ctx, char *file, int mode, char *err, int errlen);
+extern int64_t flintdbVFSReadAt(uintptr_t f, void *buf, int64_t size, int64_t offset, char *err, int errlen);
+extern int64_t flintdbVFSWriteAt(uintptr_t f, void *buf, int64_t size, int64_t offset, char *err, int errlen);
+extern int flintdbVFSSync(uintptr_t f, char *err, int errlen);
+extern int64_t flintdbVFSSize(uintptr_t f, char *err, int errlen);
+extern void flintdbVFSClose(uintptr_t f);
+
+static _Thread_local char vfs_error[512];
+
+static void* vfs_open(void *ctx, const char *file, enum flintdb_open_mode mode, char **e) {
+    vfs_error[0] = 0;
+    uintptr_t f = flintdbVFSOpen((uintptr_t)ctx, (char *)file, (int)mode, vfs_error, sizeof(vfs_error));
+    if (vfs_error[0] && e) *e = vfs_error;
+    return (void *)f;
+}
+
+static i64 vfs_read_at(void *f, void *buf, i64 size, i64 offset, char **e) {
+    vfs_error[0] = 0;
+    i64 n = flintdbVFSReadAt((uintptr_t)f, buf, size, offset, vfs_error, sizeof(vfs_error));
+    if (vfs_error[0] && e) *e = vfs_error;
+    return n;
+}
+
+static i64 vfs_write_at(void *f, const void *buf, i64 size, i64 offset, char **e) {
+    vfs_error[0] = 0;
+    i64 n = flintdbVFSWriteAt((uintptr_t)f, (void *)buf, size, offset, vfs_error, sizeof(vfs_error));
+    if (vfs_error[0] && e) *e = vfs_error;
+    return n;
+}
+
+static int vfs_sync(void *f, char **e) {
+    vfs_error[0] = 0;
+    int rc = flintdbVFSSync((uintptr_t)f, vfs_error, sizeof(vfs_error));
+    if (vfs_error[0] && e) *e = vfs_error;
+    return rc;
+}
+
+static i64 vfs_size(void *f, char **e) {
+    vfs_error[0] = 0;
+    i64 n = flintdbVFSSize((uintptr_t)f, vfs_error, sizeof(vfs_error));
+    if (vfs_error[0] && e) *e = vfs_error;
+    return n;
+}
+
+static void vfs_close(void *f) {
+    flintdbVFSClose((uintptr_t)f);
+}
+
+static int vfs_register_wrapper(const char *name, uintptr_t ctx, char **e) {
+    struct flintdb_vfs vfs = {(void *)ctx, vfs_open, vfs_read_at, vfs_write_at, vfs_sync, vfs_size, vfs_close};
+    return flintdb_vfs_register(name, &vfs, e);
+}
+
+// implemented in Go (logging.go)
+extern void flintdbLog(char *level, char *file, int line, char *func, char *message);
+
+static void log_handler_wrapper(int on) {
+    flintdb_log_handler(on ? (flintdb_log_fn)flintdbLog : NULL);
+}
+
+// Parses a CREATE TABLE statement, as stored in a .desc file, into out.
+static int meta_parse_wrapper(const char *sql, struct flintdb_meta *out, char **e) {
+    struct flintdb_sql *q = flintdb_sql_parse(sql, e);
+    if (!q) return -1;
+    int ok = flintdb_sql_to_meta(q, out, e);
+    flintdb_sql_free(q);
+    return ok;
+}
 */
 import "C"
 import (
+	"container/heap"
+	"context"
 	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/cgo"
+	"strings"
+	"sync"
+	"time"
 	"unsafe"
+
+	"golang.org/x/text/encoding"
 )
 
 type FlintDBError struct {
@@ -116,22 +542,41 @@ func (e *FlintDBError) Error() string {
 func checkError(e *C.char) error {
 	if e != nil {
 		msg := C.GoString(e)
+		if err := checksumError(msg); err != nil {
+			return err
+		}
 		return &FlintDBError{Message: msg}
 	}
 	return nil
 }
 
+// cstring converts a fixed-size C char array field to a Go string.
+func cstring(a []C.char) string {
+	return C.GoString(&a[0])
+}
+
+// copyCString writes s into a fixed-size C char array field, truncating it
+// to leave room for the terminator.
+func copyCString(a []C.char, s string) {
+	n := copy(unsafe.Slice((*byte)(unsafe.Pointer(&a[0])), len(a)-1), s)
+	a[n] = 0
+}
+
 const (
 	VARIANT_INT32  = C.VARIANT_INT32
 	VARIANT_INT64  = C.VARIANT_INT64
 	VARIANT_STRING = C.VARIANT_STRING
 	VARIANT_DOUBLE = C.VARIANT_DOUBLE
 	VARIANT_FLOAT  = C.VARIANT_FLOAT
+	VARIANT_DATE   = C.VARIANT_DATE
+	VARIANT_TIME   = C.VARIANT_TIME
+	VARIANT_BYTES  = C.VARIANT_BYTES
 )
 
 const (
 	FLINTDB_RDONLY = C.FLINTDB_RDONLY
 	FLINTDB_RDWR   = C.FLINTDB_RDWR
+	FLINTDB_APPEND = C.FLINTDB_APPEND // GenericFile: keep the rows and write after them
 )
 
 const (
@@ -141,13 +586,28 @@ const (
 
 const PRIMARY_NAME = C.PRIMARY_NAME
 
+const TABLE_NAME_SUFFIX = C.TABLE_NAME_SUFFIX
+
+const META_NAME_SUFFIX = C.META_NAME_SUFFIX
+
+const (
+	INDEX_BPTREE = "bptree"
+	INDEX_HASH   = "hash"
+)
+
+const MAX_COLUMN_NAME_LIMIT = C.MAX_COLUMN_NAME_LIMIT
+
 type Meta struct {
-	inner C.struct_flintdb_meta
+	inner    C.struct_flintdb_meta
+	ext      metaExt
+	encoding encoding.Encoding // of delimited files; nil for UTF-8
+	crlf     bool              // GenericWriter ends lines with \r\n
+	names    columnNames
 }
 
 func NewMeta(path string) (*Meta, error) {
 	var e *C.char
-	cpath := C.CString(path)
+	cpath := cPath(path)
 	defer C.free(unsafe.Pointer(cpath))
 
 	meta := C.flintdb_meta_new(cpath, &e)
@@ -172,17 +632,33 @@ func (m *Meta) AddColumn(name string, variantType int, size int, precision int,
 	defer C.free(unsafe.Pointer(ccomment))
 
 	C.flintdb_meta_columns_add(&m.inner, cname, C.enum_flintdb_variant_type(variantType), C.i32(size), C.i16(precision), C.enum_flintdb_null_spec(nullspec), cdefault, ccomment, &e)
+	m.names = nil
 	return checkError(e)
 }
 
-func (m *Meta) AddIndex(name string, columns []string) error {
+// AddIndex declares an index. The optional algorithm is INDEX_BPTREE, the
+// default, or INDEX_HASH.
+func (m *Meta) AddIndex(name string, columns []string, algorithm ...string) error {
+	if len(algorithm) > 1 {
+		return &FlintDBError{Message: "too many arguments"}
+	}
+	if len(algorithm) == 1 && algorithm[0] == INDEX_HASH {
+		return m.addHashIndex(name, columns)
+	}
+	if len(algorithm) == 1 && algorithm[0] != INDEX_BPTREE && algorithm[0] != "" {
+		return &FlintDBError{Message: fmt.Sprintf("unknown index algorithm: %s", algorithm[0])}
+	}
+	if m.hasSideIndex(name) {
+		return &FlintDBError{Message: fmt.Sprintf("duplicate index: %s", name)}
+	}
+
 	var e *C.char
 	cname := C.CString(name)
 	defer C.free(unsafe.Pointer(cname))
 
 	var keys [8][C.MAX_COLUMN_NAME_LIMIT]C.char
 	for i, col := range columns {
-		ccol := C.CString(col)
+		ccol := C.CString(m.indexColumn(col))
 		C.strncpy(&keys[i][0], ccol, C.MAX_COLUMN_NAME_LIMIT-1)
 		C.free(unsafe.Pointer(ccol))
 	}
@@ -204,10 +680,30 @@ func (m *Meta) ToSQL() (string, error) {
 	return C.GoString(&sql[0]), nil
 }
 
+// Schema returns the CREATE TABLE statement of the table.
+func (t *Table) Schema() (string, error) {
+	var e *C.char
+	var sql [2048]C.char
+
+	if C.flintdb_meta_to_sql_string(t.meta, &sql[0], 2048, &e) != 0 {
+		if err := checkError(e); err != nil {
+			return "", err
+		}
+	}
+	return C.GoString(&sql[0]), nil
+}
+
 func (m *Meta) ColumnAt(name string) int {
-	cname := C.CString(name)
-	defer C.free(unsafe.Pointer(cname))
-	return int(C.flintdb_column_at(&m.inner, cname))
+	return m.columnNames().lookup(&m.inner, name)
+}
+
+// columnNames returns the meta's column names, built on first use after the
+// last AddColumn.
+func (m *Meta) columnNames() columnNames {
+	if m.names == nil {
+		m.names = newColumnNames(&m.inner)
+	}
+	return m.names
 }
 
 func (m *Meta) SetFormatTSV() {
@@ -218,16 +714,117 @@ func (m *Meta) SetFormatTSV() {
 	m.inner.delimiter = '\t'
 }
 
+func (m *Meta) setDelimiter(d byte) {
+	m.inner.delimiter = C.char(d)
+}
+
+// SetHeader sets whether delimited files have a header line, the default.
+// A header is written when the file is created, and on read its names map
+// the fields to columns, so a file with its columns in another order still
+// reads correctly and one missing a column fails.
+func (m *Meta) SetHeader(present bool) {
+	m.inner.absent_header = 1
+	if present {
+		m.inner.absent_header = 0
+	}
+}
+
+func (m *Meta) SetFormatCSV() {
+	m.inner.format[0] = 'c'
+	m.inner.format[1] = 's'
+	m.inner.format[2] = 'v'
+	m.inner.format[3] = 0
+	m.inner.delimiter = ','
+}
+
+// SetNullString sets the token delimited files use for NULL, such as \N
+// (MySQL, Hive), NULL or "" for empty fields, instead of the format's default.
+// The token is NULL only in nullable columns; a NOT NULL string column reads
+// it as text. GenericFile quotes a CSV string equal to the token.
+func (m *Meta) SetNullString(token string) error {
+	if token == "" {
+		token = `""`
+	}
+	if len(token) >= C.MAX_COLUMN_NAME_LIMIT {
+		return &FlintDBError{Message: fmt.Sprintf("null string too long: %s", token)}
+	}
+	ctoken := C.CString(token)
+	defer C.free(unsafe.Pointer(ctoken))
+	C.strncpy(&m.inner.nil_str[0], ctoken, C.MAX_COLUMN_NAME_LIMIT-1)
+	return nil
+}
+
+// SetCRLF sets whether GenericWriter ends lines with \r\n, as Windows tools
+// such as Excel expect, rather than \n. Delimited files read either way.
+func (m *Meta) SetCRLF(crlf bool) {
+	m.crlf = crlf
+}
+
+// Compressors of table data blocks for Meta.SetCompressor.
+const (
+	COMPRESSOR_NONE    = ""
+	COMPRESSOR_LZ4     = "lz4"
+	COMPRESSOR_ZSTD    = "zstd"
+	COMPRESSOR_DEFLATE = "deflate"
+)
+
+// SetCompressor compresses each row of a table before it is stored, in
+// blocks of 128 bytes unless COMPACT sets another size, trading CPU on every
+// read and write for smaller files; text with short values in wide columns
+// shrinks the most. LZ4 and ZSTD need the engine built with them. The
+// compressor is saved with the schema and cannot change once rows exist.
+func (m *Meta) SetCompressor(name string) error {
+	switch name {
+	case COMPRESSOR_NONE, COMPRESSOR_LZ4, COMPRESSOR_ZSTD, COMPRESSOR_DEFLATE:
+	default:
+		return &FlintDBError{Message: fmt.Sprintf("unknown compressor: %s", name)}
+	}
+	for i := range m.inner.compressor {
+		m.inner.compressor[i] = 0
+	}
+	for i := 0; i < len(name); i++ {
+		m.inner.compressor[i] = C.char(name[i])
+	}
+	return nil
+}
+
+// Write-ahead log modes for Meta.SetWAL.
+const (
+	WAL_OFF      = ""
+	WAL_LOG      = "log"
+	WAL_TRUNCATE = "truncate"
+)
+
+// SetWAL logs the changes of a table opened for writing to its .wal file.
+// Each insert, update and delete is a transaction whose old pages are logged
+// before they change; when a process dies mid-write, the next open for
+// writing rolls the unfinished change back and repairs the data and index
+// files. WAL_TRUNCATE empties the log every 10000 transactions, WAL_LOG keeps
+// it. It guards against a killed process, not power loss. The mode is saved
+// with the schema.
+func (m *Meta) SetWAL(mode string) error {
+	switch mode {
+	case WAL_OFF, WAL_LOG, WAL_TRUNCATE:
+	default:
+		return &FlintDBError{Message: fmt.Sprintf("unknown WAL mode: %s", mode)}
+	}
+	copyCString(m.inner.wal[:], mode)
+	return nil
+}
+
 type Row struct {
 	inner *C.struct_flintdb_row
 	meta  *C.struct_flintdb_meta
-	owned bool // true if we own the row and should free it
+	owned bool        // true if we own the row and should free it
+	names columnNames // of the table, file or meta the row came from; nil for none
 }
 
 func (r *Row) Free() {
 	// Only free if we own the row
 	if r.inner != nil && r.owned {
 		C.row_free_wrapper(r.inner)
+		releaseHandle(r)
+		r.inner = nil
 	}
 }
 
@@ -245,9 +842,7 @@ func (r *Row) SetInt64(colIdx int, value int64) error {
 
 func (r *Row) SetString(colIdx int, value string) error {
 	var e *C.char
-	cvalue := C.CString(value)
-	defer C.free(unsafe.Pointer(cvalue))
-	C.row_string_set_wrapper(r.inner, C.int(colIdx), cvalue, &e)
+	C.row_string_set_wrapper(r.inner, C.int(colIdx), stringData(value), C.uint(len(value)), &e)
 	return checkError(e)
 }
 
@@ -257,136 +852,776 @@ func (r *Row) SetDouble(colIdx int, value float64) error {
 	return checkError(e)
 }
 
+// Set stores a Go value, casting it to the column type the way the engine
+// casts SQL values. nil stores NULL.
+func (r *Row) Set(colIdx int, value interface{}) error {
+	var e *C.char
+	switch v := value.(type) {
+	case nil:
+		C.row_null_set_wrapper(r.inner, C.int(colIdx), &e)
+	case bool:
+		var i C.longlong
+		if v {
+			i = 1
+		}
+		C.row_i64_cast_set_wrapper(r.inner, C.int(colIdx), i, &e)
+	case int:
+		C.row_i64_cast_set_wrapper(r.inner, C.int(colIdx), C.longlong(v), &e)
+	case int8:
+		C.row_i64_cast_set_wrapper(r.inner, C.int(colIdx), C.longlong(v), &e)
+	case int16:
+		C.row_i64_cast_set_wrapper(r.inner, C.int(colIdx), C.longlong(v), &e)
+	case int32:
+		C.row_i64_cast_set_wrapper(r.inner, C.int(colIdx), C.longlong(v), &e)
+	case int64:
+		C.row_i64_cast_set_wrapper(r.inner, C.int(colIdx), C.longlong(v), &e)
+	case uint8:
+		C.row_i64_cast_set_wrapper(r.inner, C.int(colIdx), C.longlong(v), &e)
+	case uint16:
+		C.row_i64_cast_set_wrapper(r.inner, C.int(colIdx), C.longlong(v), &e)
+	case uint32:
+		C.row_i64_cast_set_wrapper(r.inner, C.int(colIdx), C.longlong(v), &e)
+	case float32:
+		C.row_f64_cast_set_wrapper(r.inner, C.int(colIdx), C.double(v), &e)
+	case float64:
+		C.row_f64_cast_set_wrapper(r.inner, C.int(colIdx), C.double(v), &e)
+	case string:
+		C.row_string_cast_set_wrapper(r.inner, C.int(colIdx), stringData(v), C.uint(len(v)), &e)
+	case []byte:
+		var data *C.char
+		if len(v) > 0 {
+			data = (*C.char)(unsafe.Pointer(&v[0]))
+		}
+		C.row_bytes_cast_set_wrapper(r.inner, C.int(colIdx), data, C.uint(len(v)), &e)
+	case time.Time:
+		C.row_time_cast_set_wrapper(r.inner, C.int(colIdx), C.longlong(v.Unix()), &e)
+	default:
+		return &FlintDBError{Message: fmt.Sprintf("unsupported value type: %T", value)}
+	}
+	return checkError(e)
+}
+
+func (r *Row) SetByName(colName string, value interface{}) error {
+	return r.Set(r.columnAt(colName), value)
+}
+
 func (r *Row) SetInt32ByName(colName string, value int32) error {
-	cname := C.CString(colName)
-	defer C.free(unsafe.Pointer(cname))
-	idx := int(C.flintdb_column_at(r.meta, cname))
-	return r.SetInt32(idx, value)
+	return r.SetInt32(r.columnAt(colName), value)
 }
 
 func (r *Row) SetInt64ByName(colName string, value int64) error {
-	cname := C.CString(colName)
-	defer C.free(unsafe.Pointer(cname))
-	idx := int(C.flintdb_column_at(r.meta, cname))
-	return r.SetInt64(idx, value)
+	return r.SetInt64(r.columnAt(colName), value)
 }
 
 func (r *Row) SetStringByName(colName string, value string) error {
-	cname := C.CString(colName)
-	defer C.free(unsafe.Pointer(cname))
-	idx := int(C.flintdb_column_at(r.meta, cname))
-	return r.SetString(idx, value)
+	return r.SetString(r.columnAt(colName), value)
 }
 
 func (r *Row) SetDoubleByName(colName string, value float64) error {
-	cname := C.CString(colName)
-	defer C.free(unsafe.Pointer(cname))
-	idx := int(C.flintdb_column_at(r.meta, cname))
-	return r.SetDouble(idx, value)
+	return r.SetDouble(r.columnAt(colName), value)
 }
 
-func (r *Row) Print() {
-	C.flintdb_print_row(r.inner)
+func (r *Row) GetInt32(colIdx int) (int32, error) {
+	var e *C.char
+	value := C.row_i32_get_wrapper(r.inner, C.int(colIdx), &e)
+	return int32(value), checkError(e)
 }
 
-type Table struct {
-	inner *C.struct_flintdb_table
-	meta  *C.struct_flintdb_meta
+func (r *Row) GetInt64(colIdx int) (int64, error) {
+	var e *C.char
+	value := C.row_i64_get_wrapper(r.inner, C.int(colIdx), &e)
+	return int64(value), checkError(e)
 }
 
-func TableOpen(path string, mode uint32, meta *Meta) (*Table, error) {
+func (r *Row) GetString(colIdx int) (string, error) {
 	var e *C.char
-	cpath := C.CString(path)
-	defer C.free(unsafe.Pointer(cpath))
-
-	var metaPtr *C.struct_flintdb_meta
-	if meta != nil {
-		metaPtr = &meta.inner
+	value := C.row_string_get_wrapper(r.inner, C.int(colIdx), &e)
+	if err := checkError(e); err != nil {
+		return "", err
+	}
+	if value == nil {
+		return "", nil
 	}
+	return C.GoString(value), nil
+}
 
-	tbl := C.flintdb_table_open(cpath, C.enum_flintdb_open_mode(mode), metaPtr, &e)
+// GetStringRef returns the text of column colIdx like GetString, but for
+// STRING and BYTES columns as a view of the row's own storage instead of a
+// copy, saving an allocation per value on wide scans. The view is valid
+// only as long as the row is: until it is freed or, for a row a cursor or
+// Table.Read lends, until the next Next or Read. Copy what must outlive it.
+// NULL is nil.
+func (r *Row) GetStringRef(colIdx int) ([]byte, error) {
+	var e *C.char
+	var length C.uint
+	data := C.row_string_ref_wrapper(r.inner, C.int(colIdx), &length, &e)
 	if err := checkError(e); err != nil {
 		return nil, err
 	}
-	if tbl == nil {
-		return nil, &FlintDBError{Message: "failed to open table"}
+	if data != nil {
+		return unsafe.Slice((*byte)(unsafe.Pointer(data)), int(length)), nil
 	}
-
-	var tableMeta *C.struct_flintdb_meta
-	if metaPtr != nil {
-		tableMeta = metaPtr
-	} else {
-		tableMeta = (*C.struct_flintdb_meta)(C.table_meta_wrapper(tbl, &e))
-		if err := checkError(e); err != nil {
-			return nil, err
-		}
+	isNull, err := r.IsNull(colIdx)
+	if err != nil || isNull {
+		return nil, err
 	}
-
-	return &Table{inner: tbl, meta: tableMeta}, nil
+	s, err := r.GetString(colIdx)
+	return []byte(s), err
 }
 
-func (t *Table) Close() {
-	if t.inner != nil {
-		C.table_close_wrapper(t.inner)
-	}
+func (r *Row) GetDouble(colIdx int) (float64, error) {
+	var e *C.char
+	value := C.row_f64_get_wrapper(r.inner, C.int(colIdx), &e)
+	return float64(value), checkError(e)
 }
 
-func TableDrop(path string) {
-	cpath := C.CString(path)
-	defer C.free(unsafe.Pointer(cpath))
-	C.flintdb_table_drop(cpath, nil)
+func (r *Row) IsNull(colIdx int) (bool, error) {
+	var e *C.char
+	isNil := C.row_is_nil_wrapper(r.inner, C.int(colIdx), &e)
+	return isNil != 0, checkError(e)
 }
 
-func (t *Table) CreateRow() (*Row, error) {
+// Get returns the column value as a Go value: nil for NULL, int64 for integer
+// types, float64 for DOUBLE/FLOAT, string for STRING and DECIMAL, []byte for
+// BYTES/UUID/IPV6 and time.Time for DATE/TIME.
+func (r *Row) Get(colIdx int) (interface{}, error) {
 	var e *C.char
-	row := C.flintdb_row_new(t.meta, &e)
+	variantType := C.row_type_wrapper(r.inner, C.int(colIdx), &e)
 	if err := checkError(e); err != nil {
 		return nil, err
 	}
-	if row == nil {
-		return nil, &FlintDBError{Message: "failed to create row"}
+
+	switch variantType {
+	case C.VARIANT_NULL, C.VARIANT_ZERO:
+		return nil, nil
+	case C.VARIANT_INT8, C.VARIANT_UINT8, C.VARIANT_INT16, C.VARIANT_UINT16, C.VARIANT_INT32, C.VARIANT_UINT32, C.VARIANT_INT64:
+		return r.GetInt64(colIdx)
+	case C.VARIANT_DOUBLE, C.VARIANT_FLOAT:
+		return r.GetDouble(colIdx)
+	case C.VARIANT_STRING:
+		return r.GetString(colIdx)
+	case C.VARIANT_BYTES, C.VARIANT_UUID, C.VARIANT_IPV6:
+		var length C.uint
+		data := C.row_bytes_get_wrapper(r.inner, C.int(colIdx), &length, &e)
+		if err := checkError(e); err != nil {
+			return nil, err
+		}
+		return C.GoBytes(unsafe.Pointer(data), C.int(length)), nil
+	case C.VARIANT_DATE:
+		value := C.row_date_get_wrapper(r.inner, C.int(colIdx), &e)
+		if err := checkError(e); err != nil {
+			return nil, err
+		}
+		return time.Unix(int64(value), 0), nil
+	case C.VARIANT_TIME:
+		value := C.row_time_get_wrapper(r.inner, C.int(colIdx), &e)
+		if err := checkError(e); err != nil {
+			return nil, err
+		}
+		return time.Unix(int64(value), 0), nil
+	default:
+		var buf [128]C.char
+		if C.row_variant_to_string_wrapper(r.inner, C.int(colIdx), &buf[0], C.uint(len(buf)), &e) < 0 {
+			if err := checkError(e); err != nil {
+				return nil, err
+			}
+			return nil, &FlintDBError{Message: "failed to convert value"}
+		}
+		return C.GoString(&buf[0]), nil
 	}
+}
 
-	return &Row{inner: row, meta: t.meta, owned: true}, nil
+func (r *Row) GetInt32ByName(colName string) (int32, error) {
+	return r.GetInt32(r.columnAt(colName))
 }
 
-func (t *Table) Insert(row *Row) (int64, error) {
-	var e *C.char
-	rowid := C.table_apply_wrapper(t.inner, row.inner, 0, &e)
-	if err := checkError(e); err != nil {
-		return -1, err
-	}
-	if rowid < 0 {
-		return -1, &FlintDBError{Message: "failed to insert row"}
+func (r *Row) GetInt64ByName(colName string) (int64, error) {
+	return r.GetInt64(r.columnAt(colName))
+}
+
+func (r *Row) GetStringByName(colName string) (string, error) {
+	return r.GetString(r.columnAt(colName))
+}
+
+func (r *Row) GetDoubleByName(colName string) (float64, error) {
+	return r.GetDouble(r.columnAt(colName))
+}
+
+func (r *Row) GetByName(colName string) (interface{}, error) {
+	return r.Get(r.columnAt(colName))
+}
+
+func (r *Row) columnAt(colName string) int {
+	return r.names.lookup(r.meta, colName)
+}
+
+// columnNames maps the column names of a schema to their indexes, built
+// once when the table or file is opened so that the ByName accessors need
+// no call into the engine. Names match regardless of case, as they do in
+// flintdb_column_at.
+type columnNames map[string]int
+
+func newColumnNames(meta *C.struct_flintdb_meta) columnNames {
+	names := make(columnNames, 2*int(meta.columns.length))
+	for i := 0; i < int(meta.columns.length); i++ {
+		name := cstring(meta.columns.a[i].name[:])
+		names[name] = i
+		names[strings.ToLower(name)] = i
 	}
-	return int64(rowid), nil
+	return names
 }
 
-func (t *Table) UpdateAt(rowid int64, row *Row) error {
-	var e *C.char
-	result := C.table_apply_at_wrapper(t.inner, C.longlong(rowid), row.inner, &e)
-	if err := checkError(e); err != nil {
-		return err
+// lookup returns the index of column name, or -1. A nil map asks the
+// engine.
+func (c columnNames) lookup(meta *C.struct_flintdb_meta, name string) int {
+	if c == nil {
+		cname := C.CString(name)
+		defer C.free(unsafe.Pointer(cname))
+		return int(C.flintdb_column_at(meta, cname))
 	}
-	if result < 0 {
-		return &FlintDBError{Message: "failed to update row"}
+	if i, ok := c[name]; ok {
+		return i
 	}
-	return nil
+	if i, ok := c[strings.ToLower(name)]; ok {
+		return i
+	}
+	return -1
 }
 
-func (t *Table) DeleteAt(rowid int64) error {
+// registerVFS registers the VFS behind h with the engine under name.
+func registerVFS(name string, h cgo.Handle) error {
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
 	var e *C.char
-	result := C.table_delete_at_wrapper(t.inner, C.longlong(rowid), &e)
-	if err := checkError(e); err != nil {
+	C.vfs_register_wrapper(cname, C.uintptr_t(h), &e)
+	return checkError(e)
+}
+
+// logToGo sends the engine's log lines to flintdbLog, or back to stdout.
+func logToGo(on bool) {
+	var flag C.int
+	if on {
+		flag = 1
+	}
+	C.log_handler_wrapper(flag)
+}
+
+// parseMeta returns the schema in the text of a .desc file.
+func parseMeta(desc string) (*Meta, error) {
+	csql := C.CString(desc)
+	defer C.free(unsafe.Pointer(csql))
+	m := &Meta{}
+	var e *C.char
+	ok := C.meta_parse_wrapper(csql, &m.inner, &e)
+	if err := checkError(e); err != nil {
+		return nil, err
+	}
+	if ok != 0 {
+		return nil, &FlintDBError{Message: "failed to parse meta"}
+	}
+	return m, nil
+}
+
+// stringData returns the bytes of s for a C call that takes their length,
+// without copying them; C must not keep the pointer.
+func stringData(s string) *C.char {
+	if len(s) == 0 {
+		return nil
+	}
+	return (*C.char)(unsafe.Pointer(unsafe.StringData(s)))
+}
+
+// cPath returns path as a C string with the separators of the OS, since the
+// engine splits paths at '\' on Windows and at '/' elsewhere. The caller
+// frees it.
+func cPath(path string) *C.char {
+	return C.CString(filepath.FromSlash(path))
+}
+
+func (r *Row) Print() {
+	C.flintdb_print_row(r.inner)
+}
+
+type Table struct {
+	inner *C.struct_flintdb_table
+	meta  *C.struct_flintdb_meta
+	path  string
+	mode  uint32
+
+	computed    []computedColumn
+	collated    []collatedColumn
+	sideIndexes []*sideIndexFile
+	checks      []check
+	foreignKeys []foreignKey
+	names       columnNames
+	cacheRows   int
+	mapped      bool
+	fsID        int          // of an OpenTableFS table
+	frozen      sync.RWMutex // read-locked by open snapshots, write-locked by writes
+	rowLocks    rowLocks
+	history     *rowHistory  // of a table with Meta.SetHistory
+	stats       *TableStats  // of the last Analyze, loaded by Stats
+	queryCache  *queryCache  // of WithQueryCache
+	rowCaches   []rowEvicter // CachedTables reading the table
+	metrics     Metrics      // of WithMetrics
+	cacheSeen   [2]int64     // row cache hits and misses last reported to metrics
+	fileLock    *os.File     // the .lock file of WithFileLock
+	quota       *fileQuota   // of WithMaxFileSize
+	use         handleUse    // goroutine inside a method, in flintdb_debug builds
+}
+
+// OpenOption configures how TableOpen opens a table.
+type OpenOption func(*openOptions)
+
+type openOptions struct {
+	cacheRows   int
+	sync        int
+	mapped      bool
+	metrics     Metrics
+	fileLock    bool
+	queryCache  int
+	maxFileSize int64
+}
+
+// WithCacheSize sets how many decoded rows the table keeps in its LRU row
+// cache, so repeated point reads of hot rows skip the disk. Writes evict the
+// rows they change, so reads stay current. The engine keeps at least 1M rows
+// (half that for read-only opens), so this only raises the cache; the size is
+// not stored with the schema.
+func WithCacheSize(rows int) OpenOption {
+	return func(o *openOptions) {
+		o.cacheRows = rows
+	}
+}
+
+// WithReadOnlyMmap maps the whole data file read-only, for mostly static
+// reference tables queried from many goroutines. Read then decodes each row
+// straight from the mapping into a row of its own, bypassing the shared row
+// cache, so it may be called concurrently; string and bytes values point into
+// the mapping and stay valid until the table is closed. The table must be
+// opened FLINTDB_RDONLY; other methods still need one goroutine at a time.
+func WithReadOnlyMmap() OpenOption {
+	return func(o *openOptions) {
+		o.mapped = true
+	}
+}
+
+// Durability levels for WithDurability: how the write-ahead log of a table
+// syncs its commits to disk.
+const (
+	DURABILITY_DEFAULT = 0  // the table's WAL_SYNC, else $FLINTDB_WAL_SYNC
+	DURABILITY_OFF     = -1 // leave writing to the OS
+	DURABILITY_NORMAL  = 1  // fdatasync, fsync on macOS
+	DURABILITY_FULL    = 2  // fsync, F_FULLFSYNC on macOS
+)
+
+// WithDurability sets how the table's write-ahead log syncs while it is
+// open. An existing table keeps the WAL_SYNC saved with its schema; a table
+// this open creates saves level as its WAL_SYNC. It has no effect on tables
+// without a WAL or opened FLINTDB_RDONLY.
+func WithDurability(level int) OpenOption {
+	return func(o *openOptions) {
+		o.sync = level
+	}
+}
+
+func TableOpen(path string, mode uint32, meta *Meta, opts ...OpenOption) (*Table, error) {
+	return TableOpenContext(context.Background(), path, mode, meta, opts...)
+}
+
+// TableOpenContext is TableOpen with its span, if a Tracer is set, a child of
+// the span in ctx. The open gives up with ctx's error once ctx is done: before
+// the engine opens the table, after it has, and between the rows of a side
+// index it rebuilds. The engine's WAL recovery runs to its end once begun.
+func TableOpenContext(ctx context.Context, path string, mode uint32, meta *Meta, opts ...OpenOption) (*Table, error) {
+	_, span := startSpan(ctx, "flintdb.open", path)
+	t, err := tableOpen(ctx, path, mode, meta, opts)
+	if span != nil {
+		span.SetAttribute("flintdb.mode", int64(mode))
+		span.End(err)
+	}
+	return t, err
+}
+
+func tableOpen(ctx context.Context, path string, mode uint32, meta *Meta, opts []OpenOption) (*Table, error) {
+	var o openOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.mapped && mode != FLINTDB_RDONLY {
+		return nil, &FlintDBError{Message: "read-only mmap needs FLINTDB_RDONLY"}
+	}
+	switch o.sync {
+	case DURABILITY_DEFAULT, DURABILITY_OFF, DURABILITY_NORMAL, DURABILITY_FULL:
+	default:
+		return nil, &FlintDBError{Message: fmt.Sprintf("unknown durability: %d", o.sync)}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var lock *os.File
+	if o.fileLock {
+		var err error
+		if lock, err = lockTable(path, mode); err != nil {
+			return nil, err
+		}
+		// Until the table holds it
+		defer func() {
+			if lock != nil {
+				unlockTable(lock)
+			}
+		}()
+	}
+
+	var e *C.char
+	cpath := cPath(path)
+	defer C.free(unsafe.Pointer(cpath))
+
+	var mapped C.int
+	if o.mapped {
+		mapped = 1
+	}
+
+	// Pass &meta.inner directly: cgo then checks only the C struct, not the
+	// Go-side settings Meta carries next to it.
+	var tbl *C.struct_flintdb_table
+	if meta != nil {
+		tbl = C.table_open_wrapper(cpath, C.enum_flintdb_open_mode(mode), &meta.inner, C.int(o.cacheRows), C.int(o.sync), mapped, &e)
+	} else {
+		tbl = C.table_open_wrapper(cpath, C.enum_flintdb_open_mode(mode), nil, C.int(o.cacheRows), C.int(o.sync), mapped, &e)
+	}
+	if err := checkError(e); err != nil {
+		return nil, err
+	}
+	if tbl == nil {
+		return nil, &FlintDBError{Message: "failed to open table"}
+	}
+
+	tableMeta := (*C.struct_flintdb_meta)(C.table_meta_wrapper(tbl, &e))
+	if err := checkError(e); err != nil {
+		C.table_close_wrapper(tbl)
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		C.table_close_wrapper(tbl)
+		return nil, err
+	}
+
+	var ext metaExt
+	var err error
+	if meta != nil {
+		ext = meta.ext
+		err = syncMetaExt(path, ext, mode == FLINTDB_RDWR)
+	} else {
+		ext, _, err = readMetaExt(path)
+	}
+	if err != nil {
+		C.table_close_wrapper(tbl)
+		return nil, err
+	}
+
+	t := &Table{inner: tbl, meta: tableMeta, path: path, mode: mode, names: newColumnNames(tableMeta), cacheRows: o.cacheRows, mapped: o.mapped, metrics: o.metrics, fileLock: lock}
+	lock = nil
+	if o.queryCache > 0 {
+		t.queryCache = newQueryCache(o.queryCache)
+	}
+	trackHandle("table "+path, t, nil)
+	if o.maxFileSize > 0 && mode == FLINTDB_RDWR {
+		if t.quota, err = newFileQuota(path, o.maxFileSize); err != nil {
+			t.Close()
+			return nil, err
+		}
+	}
+	if err := t.loadCollations(); err != nil {
+		t.Close()
+		return nil, err
+	}
+	if err := t.openSideIndexes(ctx, ext); err != nil {
+		t.Close()
+		return nil, err
+	}
+	if ext.History != nil {
+		if t.history, err = openHistory(t, *ext.History); err != nil {
+			t.Close()
+			return nil, err
+		}
+	}
+	if t.metrics != nil {
+		t.metrics.Handles(path, 1)
+	}
+	logEvent(slog.LevelInfo, "table opened", "table", path, "writable", mode == FLINTDB_RDWR)
+	return t, nil
+}
+
+// openReader opens another read-only handle on t's file for reading rows,
+// without the settings and side indexes TableOpen loads.
+func (t *Table) openReader() (*Table, error) {
+	var e *C.char
+	cpath := cPath(t.path)
+	defer C.free(unsafe.Pointer(cpath))
+
+	var mapped C.int
+	if t.mapped {
+		mapped = 1
+	}
+	// An OpenTableFS table has no .desc on disk to read the schema from.
+	var meta *C.struct_flintdb_meta
+	if t.fsID != 0 {
+		meta = t.meta
+	}
+	tbl := C.table_open_wrapper(cpath, C.enum_flintdb_open_mode(FLINTDB_RDONLY), meta, C.int(t.cacheRows), 0, mapped, &e)
+	if err := checkError(e); err != nil {
+		return nil, err
+	}
+	if tbl == nil {
+		return nil, &FlintDBError{Message: "failed to open table"}
+	}
+	tableMeta := (*C.struct_flintdb_meta)(C.table_meta_wrapper(tbl, &e))
+	if err := checkError(e); err != nil {
+		C.table_close_wrapper(tbl)
+		return nil, err
+	}
+	if t.fsID != 0 {
+		acquireFS(t.fsID)
+	}
+	reader := &Table{inner: tbl, meta: tableMeta, path: t.path, mode: FLINTDB_RDONLY, names: t.names, cacheRows: t.cacheRows, mapped: t.mapped, fsID: t.fsID}
+	trackHandle("table "+t.path, reader, nil)
+	return reader, nil
+}
+
+// openTableFS opens the table of OpenTableFS by its engine file name, with
+// the schema meta read from its fs.FS.
+func openTableFS(fsID int, path string, meta *C.struct_flintdb_meta, opts []OpenOption) (*Table, error) {
+	var o openOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	var e *C.char
+	cpath := C.CString(path)
+	defer C.free(unsafe.Pointer(cpath))
+
+	tbl := C.table_open_wrapper(cpath, C.enum_flintdb_open_mode(FLINTDB_RDONLY), meta, C.int(o.cacheRows), 0, 0, &e)
+	if err := checkError(e); err != nil {
+		return nil, err
+	}
+	if tbl == nil {
+		return nil, &FlintDBError{Message: "failed to open table"}
+	}
+	tableMeta := (*C.struct_flintdb_meta)(C.table_meta_wrapper(tbl, &e))
+	if err := checkError(e); err != nil {
+		C.table_close_wrapper(tbl)
+		return nil, err
+	}
+
+	t := &Table{inner: tbl, meta: tableMeta, path: path, mode: FLINTDB_RDONLY, names: newColumnNames(tableMeta), cacheRows: o.cacheRows}
+	trackHandle("table "+path, t, nil)
+	if err := t.loadCollations(); err != nil {
+		t.Close()
+		return nil, err
+	}
+	t.fsID = fsID
+	return t, nil
+}
+
+func (t *Table) Close() {
+	t.use.enter("table", t.path)
+	defer t.use.leave()
+	t.closeSideIndexes()
+	if t.history != nil {
+		t.history.close()
+		t.history = nil
+	}
+	if t.inner != nil {
+		C.table_close_wrapper(t.inner)
+		releaseHandle(t)
+		if t.metrics != nil {
+			t.metrics.Handles(t.path, -1)
+		}
+		t.inner = nil
+		if t.fsID == 0 {
+			logEvent(slog.LevelDebug, "table closed", "table", t.path)
+		}
+	}
+	if t.fileLock != nil {
+		unlockTable(t.fileLock)
+		t.fileLock = nil
+	}
+	if t.fsID != 0 {
+		releaseFS(t.fsID)
+		t.fsID = 0
+	}
+}
+
+func TableDrop(path string) {
+	cpath := cPath(path)
+	defer C.free(unsafe.Pointer(cpath))
+	C.flintdb_table_drop(cpath, nil)
+	os.Remove(path + lockSuffix)
+}
+
+func (t *Table) CreateRow() (*Row, error) {
+	var e *C.char
+	row := C.flintdb_row_new(t.meta, &e)
+	if err := checkError(e); err != nil {
+		return nil, err
+	}
+	if row == nil {
+		return nil, &FlintDBError{Message: "failed to create row"}
+	}
+
+	r := &Row{inner: row, meta: t.meta, owned: true, names: t.names}
+	trackHandle("row", r, t)
+	return r, nil
+}
+
+func (t *Table) Insert(row *Row) (_ int64, err error) {
+	if t.metrics != nil {
+		defer t.observe("insert", time.Now(), &err)
+	}
+	t.use.enter("table", t.path)
+	defer t.use.leave()
+	t.frozen.Lock()
+	defer t.frozen.Unlock()
+	return t.insert(row)
+}
+
+// insert is Insert with the table locked.
+func (t *Table) insert(row *Row) (int64, error) {
+	if err := t.quota.admit(); err != nil {
+		return -1, err
+	}
+	t.queryCache.invalidate()
+	if err := t.beforeWrite(row); err != nil {
+		return -1, err
+	}
+
+	var e *C.char
+	rowid := C.table_apply_wrapper(t.inner, row.inner, 0, &e)
+	if err := checkError(e); err != nil {
+		return -1, err
+	}
+	if rowid < 0 {
+		return -1, &FlintDBError{Message: "failed to insert row"}
+	}
+	t.evictRow(int64(rowid))
+	if err := t.indexRow(int64(rowid), row); err != nil {
+		return int64(rowid), err
+	}
+	if t.history != nil {
+		return int64(rowid), t.history.record(int64(rowid), row)
+	}
+	return int64(rowid), nil
+}
+
+// applyPacked inserts rows packed by a RowBatch with one call into the
+// engine and returns how many were inserted.
+func (t *Table) applyPacked(buf []byte, rows int, columns int) (int64, error) {
+	if rows == 0 {
+		return 0, nil
+	}
+	t.use.enter("table", t.path)
+	defer t.use.leave()
+	t.frozen.Lock()
+	defer t.frozen.Unlock()
+	if err := t.quota.admit(); err != nil {
+		return 0, err
+	}
+	t.queryCache.invalidate()
+	var e *C.char
+	n := C.table_apply_packed_wrapper(t.inner, t.meta, (*C.char)(unsafe.Pointer(&buf[0])), C.longlong(rows), C.int(columns), 0, &e)
+	return int64(n), checkError(e)
+}
+
+func (t *Table) UpdateAt(rowid int64, row *Row) (err error) {
+	if t.metrics != nil {
+		defer t.observe("update", time.Now(), &err)
+	}
+	t.use.enter("table", t.path)
+	defer t.use.leave()
+	t.frozen.Lock()
+	defer t.frozen.Unlock()
+	return t.updateAt(rowid, row)
+}
+
+// updateAt is UpdateAt with the table locked.
+func (t *Table) updateAt(rowid int64, row *Row) error {
+	if err := t.quota.admit(); err != nil {
+		return err
+	}
+	t.queryCache.invalidate()
+	defer t.evictRow(rowid)
+	if err := t.beforeWrite(row); err != nil {
+		return err
+	}
+
+	var e *C.char
+	result := C.table_apply_at_wrapper(t.inner, C.longlong(rowid), row.inner, &e)
+	if err := checkError(e); err != nil {
+		return err
+	}
+	if result < 0 {
+		return &FlintDBError{Message: "failed to update row"}
+	}
+	if err := t.indexRow(rowid, row); err != nil {
+		return err
+	}
+	if t.history != nil {
+		return t.history.record(rowid, row)
+	}
+	return nil
+}
+
+func (t *Table) DeleteAt(rowid int64) (err error) {
+	if t.metrics != nil {
+		defer t.observe("delete", time.Now(), &err)
+	}
+	t.use.enter("table", t.path)
+	defer t.use.leave()
+	t.frozen.Lock()
+	defer t.frozen.Unlock()
+	return t.deleteAt(rowid)
+}
+
+// deleteAt is DeleteAt with the table locked.
+func (t *Table) deleteAt(rowid int64) error {
+	t.queryCache.invalidate()
+	defer t.evictRow(rowid)
+	var e *C.char
+	result := C.table_delete_at_wrapper(t.inner, C.longlong(rowid), &e)
+	if err := checkError(e); err != nil {
 		return err
 	}
 	if result < 0 {
 		return &FlintDBError{Message: "failed to delete row"}
 	}
+	t.unindexRow(rowid)
+	if t.history != nil {
+		return t.history.record(rowid, nil)
+	}
 	return nil
 }
 
-func (t *Table) Read(rowid int64) (*Row, error) {
+func (t *Table) Rows() (int64, error) {
+	t.use.enter("table", t.path)
+	defer t.use.leave()
+	var e *C.char
+	n := C.table_rows_wrapper(t.inner, &e)
+	if err := checkError(e); err != nil {
+		return -1, err
+	}
+	return int64(n), nil
+}
+
+func (t *Table) Read(rowid int64) (_ *Row, err error) {
+	if t.metrics != nil {
+		defer t.observe("read", time.Now(), &err)
+	}
+	if t.mapped {
+		return t.readMapped(rowid)
+	}
+	t.use.enter("table", t.path)
+	defer t.use.leave()
 	var e *C.char
 	row := C.table_read_wrapper(t.inner, C.longlong(rowid), &e)
 	if err := checkError(e); err != nil {
@@ -395,7 +1630,117 @@ func (t *Table) Read(rowid int64) (*Row, error) {
 	if row == nil {
 		return nil, &FlintDBError{Message: "row not found"}
 	}
-	return &Row{inner: (*C.struct_flintdb_row)(unsafe.Pointer(row)), meta: t.meta, owned: false}, nil
+	return &Row{inner: (*C.struct_flintdb_row)(unsafe.Pointer(row)), meta: t.meta, owned: false, names: t.names}, nil
+}
+
+// readMapped decodes the row at rowid from a WithReadOnlyMmap table into a
+// row of its own, freed by Free or when it is garbage collected.
+func (t *Table) readMapped(rowid int64) (*Row, error) {
+	var e *C.char
+	row := C.flintdb_row_new(t.meta, &e)
+	if err := checkError(e); err != nil {
+		return nil, err
+	}
+	if row == nil {
+		return nil, &FlintDBError{Message: "failed to create row"}
+	}
+	C.table_read_stream_wrapper(t.inner, C.longlong(rowid), row, &e)
+	if err := checkError(e); err != nil {
+		C.row_free_wrapper(row)
+		return nil, err
+	}
+	r := &Row{inner: row, meta: t.meta, owned: true, names: t.names}
+	runtime.SetFinalizer(r, (*Row).Free)
+	return r, nil
+}
+
+// ReadColumnInt64 returns column col of the rows at rowids, read with one
+// call into the engine rather than one per row, for aggregating a column
+// over many rows. Values are converted as GetInt64 converts them; NULL is 0.
+func (t *Table) ReadColumnInt64(col string, rowids []int64) ([]int64, error) {
+	t.use.enter("table", t.path)
+	defer t.use.leave()
+	idx, err := t.readColumnIndex(col)
+	if err != nil || len(rowids) == 0 {
+		return nil, err
+	}
+	out := make([]int64, len(rowids))
+	var e *C.char
+	n := C.table_read_column_i64_wrapper(t.inner, C.int(idx), (*C.longlong)(unsafe.Pointer(&rowids[0])), C.longlong(len(rowids)), (*C.longlong)(unsafe.Pointer(&out[0])), &e)
+	if err := readColumnError(e, n, rowids); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ReadColumnFloat64 is ReadColumnInt64 for float64 values, converted as
+// GetDouble converts them.
+func (t *Table) ReadColumnFloat64(col string, rowids []int64) ([]float64, error) {
+	t.use.enter("table", t.path)
+	defer t.use.leave()
+	idx, err := t.readColumnIndex(col)
+	if err != nil || len(rowids) == 0 {
+		return nil, err
+	}
+	out := make([]float64, len(rowids))
+	var e *C.char
+	n := C.table_read_column_f64_wrapper(t.inner, C.int(idx), (*C.longlong)(unsafe.Pointer(&rowids[0])), C.longlong(len(rowids)), (*C.double)(unsafe.Pointer(&out[0])), &e)
+	if err := readColumnError(e, n, rowids); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ReadColumnString is ReadColumnInt64 for the text of the values; NULL is
+// "". The strings share one allocation.
+func (t *Table) ReadColumnString(col string, rowids []int64) ([]string, error) {
+	t.use.enter("table", t.path)
+	defer t.use.leave()
+	idx, err := t.readColumnIndex(col)
+	if err != nil || len(rowids) == 0 {
+		return nil, err
+	}
+	lengths := make([]C.uint, len(rowids))
+	var data *C.char
+	var e *C.char
+	n := C.table_read_column_string_wrapper(t.inner, C.int(idx), (*C.longlong)(unsafe.Pointer(&rowids[0])), C.longlong(len(rowids)), &lengths[0], &data, &e)
+	defer C.free(unsafe.Pointer(data))
+	if err := readColumnError(e, n, rowids); err != nil {
+		return nil, err
+	}
+	total := 0
+	for _, l := range lengths {
+		total += int(l)
+	}
+	all := C.GoStringN(data, C.int(total))
+	out := make([]string, len(rowids))
+	for i, l := range lengths {
+		out[i], all = all[:l], all[l:]
+	}
+	return out, nil
+}
+
+func (t *Table) readColumnIndex(col string) (int, error) {
+	idx := t.columnAt(col)
+	if idx < 0 {
+		return -1, &FlintDBError{Message: fmt.Sprintf("column not found: %s", col)}
+	}
+	return idx, nil
+}
+
+// readColumnError reports why a column read stopped after n of rowids.
+func readColumnError(e *C.char, n C.longlong, rowids []int64) error {
+	if err := checkError(e); err != nil {
+		return err
+	}
+	if int(n) < len(rowids) {
+		return &FlintDBError{Message: fmt.Sprintf("rowid %d: row not found", rowids[n])}
+	}
+	return nil
+}
+
+func (t *Table) columnAt(colName string) int {
+	return t.names.lookup(t.meta, colName)
 }
 
 func (t *Table) One(va ...interface{}) (*Row, error) {
@@ -403,10 +1748,91 @@ func (t *Table) One(va ...interface{}) (*Row, error) {
 }
 
 type CursorInt64 struct {
-	inner *C.struct_flintdb_cursor_i64
+	inner     *C.struct_flintdb_cursor_i64
+	rows      []int64 // rowids found in Go, such as by a hash index, used when inner is nil
+	table     *Table  // reported to on Close, for a table with metrics
+	span      Span    // of the drain, ended on Close
+	returned  int64
+	steps     int64     // calls of the engine cursor
+	err       error     // of the last Next
+	deadline  time.Time // of WithTimeout
+	maxRows   int64     // of WithMaxRows, 0 for none
+	truncate  bool      // end the cursor at maxRows rather than fail
+	truncated bool
+	slow      *slowQuery
+	fill      *queryFill // of WithQueryCache, until the cursor ends
+	filter    *postFilter
+	use       handleUse
+}
+
+func (t *Table) Find(query string, opts ...QueryOption) (*CursorInt64, error) {
+	return t.FindContext(context.Background(), query, opts...)
+}
+
+// FindContext is Find with its spans, if a Tracer is set, children of the
+// span in ctx: one for the find, and one for draining the cursor that ends
+// when it is closed.
+func (t *Table) FindContext(ctx context.Context, query string, opts ...QueryOption) (*CursorInt64, error) {
+	_, span := startSpan(ctx, "flintdb.find", t.path)
+	o := queryOptionsOf(opts)
+	threshold := slowQueryThreshold()
+	if t.metrics == nil && span == nil && threshold == 0 {
+		return t.find(query, o)
+	}
+	start := time.Now()
+	c, err := t.find(query, o)
+	if threshold > 0 && c != nil {
+		c.slow = &slowQuery{table: t, query: query, start: start, threshold: threshold}
+	}
+	if t.metrics != nil {
+		t.observe("find", start, &err)
+		if c != nil {
+			c.table = t
+		}
+	}
+	if span != nil {
+		span.SetAttribute("flintdb.query", query)
+		span.End(err)
+		if c != nil {
+			_, c.span = startSpan(ctx, "flintdb.cursor", t.path)
+			c.span.SetAttribute("flintdb.query", query)
+		}
+	}
+	return c, err
 }
 
-func (t *Table) Find(query string) (*CursorInt64, error) {
+func (t *Table) find(query string, o queryOptions) (*CursorInt64, error) {
+	if len(o.orderBy) > 0 || len(o.distinct) > 0 {
+		return t.findOrdered(query, o)
+	}
+	t.use.enter("table", t.path)
+	defer t.use.leave()
+	var deadline time.Time
+	if o.timeout > 0 {
+		deadline = time.Now().Add(o.timeout)
+	}
+	query, filter, err := t.splitPostFilter(query, o.ignoreCase)
+	if err != nil {
+		return nil, err
+	}
+	query = t.rewriteCollated(query)
+	var fill *queryFill
+	if t.queryCache != nil && filter == nil {
+		if rows, ok := t.queryCache.get(query); ok {
+			return &CursorInt64{rows: rows, deadline: deadline, maxRows: o.maxRows, truncate: o.truncate}, nil
+		}
+		if o.maxRows == 0 {
+			fill = t.queryCache.fill(query)
+		}
+	}
+	rows, hashed, err := t.findHashed(query)
+	if err != nil {
+		return nil, err
+	}
+	if hashed {
+		return &CursorInt64{rows: rows, deadline: deadline, maxRows: o.maxRows, truncate: o.truncate, fill: fill, filter: filter}, nil
+	}
+
 	var e *C.char
 	cquery := C.CString(query)
 	defer C.free(unsafe.Pointer(cquery))
@@ -415,16 +1841,87 @@ func (t *Table) Find(query string) (*CursorInt64, error) {
 	if err := checkError(e); err != nil {
 		return nil, err
 	}
-	if cursor == nil {
-		return nil, &FlintDBError{Message: "failed to create cursor"}
-	}
+	// The engine returns no cursor when nothing matches; Next on an empty cursor reports -1
 
-	return &CursorInt64{inner: cursor}, nil
+	c := &CursorInt64{inner: cursor, deadline: deadline, maxRows: o.maxRows, truncate: o.truncate, fill: fill, filter: filter}
+	if c.expired() {
+		// Sorting for an ORDER BY no index serves can outlast the timeout
+		if cursor != nil {
+			C.cursor_i64_close_wrapper(cursor)
+		}
+		return nil, ErrTimeout
+	}
+	if cursor != nil {
+		if !deadline.IsZero() {
+			// Rounded up, so the engine never stops before expired reports it
+			ms := (deadline.UnixNano() + int64(time.Millisecond) - 1) / int64(time.Millisecond)
+			C.flintdb_cursor_deadline(cursor, C.i64(ms))
+		}
+		trackHandle("cursor "+query, c, t)
+	}
+	return c, nil
 }
 
 func (c *CursorInt64) Next() (int64, error) {
+	c.use.enter("cursor", "")
+	defer c.use.leave()
+	if c.expired() {
+		c.err = ErrTimeout
+		return -1, ErrTimeout
+	}
+	if c.truncated {
+		return -1, nil
+	}
+	rowid, err := c.next()
+	if err != nil {
+		if c.expired() {
+			err = ErrTimeout
+		}
+		c.err = err
+		return -1, err
+	}
+	if rowid >= 0 {
+		if c.maxRows > 0 && c.returned == c.maxRows {
+			return c.overLimit()
+		}
+		c.returned++
+	}
+	c.record(rowid)
+	return rowid, nil
+}
+
+// next returns the next rowid found that passes the post filter of the
+// find, if it has one.
+func (c *CursorInt64) next() (int64, error) {
+	for {
+		rowid, err := c.found()
+		if err != nil || rowid < 0 || c.filter == nil {
+			return rowid, err
+		}
+		ok, done, err := c.filter.pass(rowid)
+		switch {
+		case err != nil:
+			return -1, err
+		case done:
+			return -1, nil
+		case ok:
+			return rowid, nil
+		case c.expired():
+			return -1, ErrTimeout
+		}
+	}
+}
+
+// found returns the next rowid found by Go, or else by the engine.
+func (c *CursorInt64) found() (int64, error) {
+	if c.inner == nil && len(c.rows) > 0 {
+		rowid := c.rows[0]
+		c.rows = c.rows[1:]
+		return rowid, nil
+	}
 	var e *C.char
 	rowid := C.cursor_i64_next_wrapper(c.inner, &e)
+	c.steps++
 	if err := checkError(e); err != nil {
 		return -1, err
 	}
@@ -432,19 +1929,50 @@ func (c *CursorInt64) Next() (int64, error) {
 }
 
 func (c *CursorInt64) Close() {
+	c.use.enter("cursor", "")
+	defer c.use.leave()
+	if c.slow != nil {
+		c.logSlow()
+	}
 	if c.inner != nil {
 		C.cursor_i64_close_wrapper(c.inner)
+		releaseHandle(c)
+		c.inner = nil
+	}
+	if c.table != nil {
+		c.report()
+	}
+	if c.span != nil {
+		c.span.SetAttribute("flintdb.rows", c.returned)
+		c.span.End(c.err)
+		c.span = nil
 	}
 }
 
 type GenericFile struct {
-	inner *C.struct_flintdb_genericfile
-	meta  *C.struct_flintdb_meta
+	inner   *C.struct_flintdb_genericfile
+	meta    *C.struct_flintdb_meta
+	encoded *encodedFile            // UTF-8 copy of a file in another encoding
+	batch   []*C.struct_flintdb_row // WriteMany's rows
+	names   columnNames
+	use     handleUse
 }
 
+// GenericFileOpen opens a delimited text file. Files ending in .gz or .zst,
+// such as data.tsv.gz or data.csv.zst, are compressed and decompressed as
+// they are written and read; zstd needs an engine built with libzstd.
 func GenericFileOpen(path string, mode uint32, meta *Meta) (*GenericFile, error) {
+	var encoded *encodedFile
+	if meta != nil && meta.encoding != nil {
+		x, err := openEncoded(path, mode, meta.encoding)
+		if err != nil {
+			return nil, err
+		}
+		encoded, path = x, x.temp
+	}
+
 	var e *C.char
-	cpath := C.CString(path)
+	cpath := cPath(path)
 	defer C.free(unsafe.Pointer(cpath))
 
 	var metaPtr *C.struct_flintdb_meta
@@ -453,11 +1981,16 @@ func GenericFileOpen(path string, mode uint32, meta *Meta) (*GenericFile, error)
 	}
 
 	file := C.flintdb_genericfile_open(cpath, C.enum_flintdb_open_mode(mode), metaPtr, &e)
-	if err := checkError(e); err != nil {
-		return nil, err
+	err := checkError(e)
+	if err == nil && file == nil {
+		err = &FlintDBError{Message: "failed to open generic file"}
 	}
-	if file == nil {
-		return nil, &FlintDBError{Message: "failed to open generic file"}
+	if err != nil {
+		if encoded != nil {
+			encoded.writable = false
+			encoded.close()
+		}
+		return nil, err
 	}
 
 	var fileMeta *C.struct_flintdb_meta
@@ -470,17 +2003,31 @@ func GenericFileOpen(path string, mode uint32, meta *Meta) (*GenericFile, error)
 		}
 	}
 
-	return &GenericFile{inner: file, meta: fileMeta}, nil
+	f := &GenericFile{inner: file, meta: fileMeta, encoded: encoded, names: newColumnNames(fileMeta)}
+	trackHandle("file "+path, f, nil)
+	return f, nil
 }
 
-func (f *GenericFile) Close() {
+// Close closes the file. For a file in another encoding it converts the rows
+// written, and the error reports a character the encoding cannot represent.
+func (f *GenericFile) Close() error {
+	f.use.enter("file", "")
+	defer f.use.leave()
 	if f.inner != nil {
 		C.genericfile_close_wrapper(f.inner)
+		releaseHandle(f)
+		f.inner = nil
+	}
+	if f.encoded != nil {
+		x := f.encoded
+		f.encoded = nil
+		return x.close()
 	}
+	return nil
 }
 
 func GenericFileDrop(path string) {
-	cpath := C.CString(path)
+	cpath := cPath(path)
 	defer C.free(unsafe.Pointer(cpath))
 	C.flintdb_genericfile_drop(cpath, nil)
 }
@@ -495,10 +2042,14 @@ func (f *GenericFile) CreateRow() (*Row, error) {
 		return nil, &FlintDBError{Message: "failed to create row"}
 	}
 
-	return &Row{inner: row, meta: f.meta, owned: true}, nil
+	r := &Row{inner: row, meta: f.meta, owned: true, names: f.names}
+	trackHandle("row", r, f)
+	return r, nil
 }
 
 func (f *GenericFile) Write(row *Row) error {
+	f.use.enter("file", "")
+	defer f.use.leave()
 	var e *C.char
 	ret := C.genericfile_write_wrapper(f.inner, row.inner, &e)
 	if err := checkError(e); err != nil {
@@ -510,12 +2061,121 @@ func (f *GenericFile) Write(row *Row) error {
 	return nil
 }
 
+// Offset returns the byte offset where the next row written will start, in
+// the uncompressed, UTF-8 text. In FLINTDB_APPEND mode it continues the
+// existing file.
+func (f *GenericFile) Offset() int64 {
+	return int64(C.flintdb_genericfile_position(f.inner, nil))
+}
+
+// Line returns the 1-based line number the next row written will start on,
+// counting the header.
+func (f *GenericFile) Line() int64 {
+	var line C.i64
+	C.flintdb_genericfile_position(f.inner, &line)
+	return int64(line)
+}
+
+// Columns returns the names of the file's columns.
+func (f *GenericFile) Columns() []string {
+	names := make([]string, int(f.meta.columns.length))
+	for i := range names {
+		names[i] = cstring(f.meta.columns.a[i].name[:])
+	}
+	return names
+}
+
+// MalformedLine is a line a lenient GenericFile skipped.
+type MalformedLine struct {
+	Line   int64 // 1-based, counting the header
+	Text   string
+	Reason string
+}
+
+// SetLenient makes Find skip malformed lines, such as a wrong field count or
+// an unparsable number, instead of reading them with NULLs. Text files only.
+func (f *GenericFile) SetLenient(lenient bool) error {
+	var e *C.char
+	var flag C.i8
+	if lenient {
+		flag = 1
+	}
+	C.flintdb_genericfile_lenient(f.inner, flag, &e)
+	return checkError(e)
+}
+
+// Malformed returns the lines skipped by the last lenient Find, the first
+// 1000 of them, and how many were skipped in all.
+func (f *GenericFile) Malformed() ([]MalformedLine, int64) {
+	total := int64(C.flintdb_genericfile_malformed(f.inner, -1, nil, nil, nil))
+	var lines []MalformedLine
+	for i := 0; int64(i) < total; i++ {
+		var line C.i64
+		var text, reason *C.char
+		C.flintdb_genericfile_malformed(f.inner, C.int(i), &line, &text, &reason)
+		if text == nil {
+			break
+		}
+		lines = append(lines, MalformedLine{Line: int64(line), Text: C.GoString(text), Reason: C.GoString(reason)})
+	}
+	return lines, total
+}
+
+// WriteMany writes rows with one call into the engine rather than one per
+// row, for large exports. The rows must have the file's columns.
+func (f *GenericFile) WriteMany(rows []*Row) error {
+	f.use.enter("file", "")
+	defer f.use.leave()
+	if len(rows) == 0 {
+		return nil
+	}
+	f.batch = f.batch[:0]
+	for i, row := range rows {
+		if row.meta.columns.length != f.meta.columns.length {
+			return &FlintDBError{Message: fmt.Sprintf("row %d: expected %d columns, found %d", i, f.meta.columns.length, row.meta.columns.length)}
+		}
+		f.batch = append(f.batch, row.inner)
+	}
+	var e *C.char
+	C.flintdb_genericfile_write_many(f.inner, &f.batch[0], C.int(len(f.batch)), &e)
+	return checkError(e)
+}
+
+// SetWriteBuffer sets the size of the buffer rows are written through, which
+// defaults to the IO_BUFSZ environment variable or 1MB. Set it before the
+// first write.
+func (f *GenericFile) SetWriteBuffer(bytes int) error {
+	var e *C.char
+	C.flintdb_genericfile_buffer(f.inner, C.size_t(bytes), &e)
+	return checkError(e)
+}
+
+// newRow creates a row of m itself, for readers and writers without a C
+// handle of their own.
+func (m *Meta) newRow() (*Row, error) {
+	var e *C.char
+	row := C.flintdb_row_new(&m.inner, &e)
+	if err := checkError(e); err != nil {
+		return nil, err
+	}
+	if row == nil {
+		return nil, &FlintDBError{Message: "failed to create row"}
+	}
+	r := &Row{inner: row, meta: &m.inner, owned: true, names: m.columnNames()}
+	trackHandle("row", r, nil)
+	return r, nil
+}
+
 type CursorRow struct {
 	inner *C.struct_flintdb_cursor_row
 	meta  *C.struct_flintdb_meta
+	names columnNames
+	use   handleUse
 }
 
 func (f *GenericFile) Find(query string) (*CursorRow, error) {
+	f.use.enter("file", "")
+	defer f.use.leave()
 	var e *C.char
 	cquery := C.CString(query)
 	defer C.free(unsafe.Pointer(cquery))
@@ -528,10 +2188,14 @@ func (f *GenericFile) Find(query string) (*CursorRow, error) {
 		return nil, &FlintDBError{Message: "failed to create cursor"}
 	}
 
-	return &CursorRow{inner: cursor, meta: f.meta}, nil
+	c := &CursorRow{inner: cursor, meta: f.meta, names: f.names}
+	trackHandle("cursor", c, f)
+	return c, nil
 }
 
 func (c *CursorRow) Next() (*Row, error) {
+	c.use.enter("cursor", "")
+	defer c.use.leave()
 	var e *C.char
 	row := C.cursor_row_next_wrapper(c.inner, &e)
 	if err := checkError(e); err != nil {
@@ -541,16 +2205,495 @@ func (c *CursorRow) Next() (*Row, error) {
 		return nil, nil
 	}
 	// Return borrowed row - cursor owns it, don't free
-	return &Row{inner: row, meta: c.meta, owned: false}, nil
+	return &Row{inner: row, meta: c.meta, owned: false, names: c.names}, nil
 }
 
 func (c *CursorRow) Close() {
+	c.use.enter("cursor", "")
+	defer c.use.leave()
 	if c.inner != nil {
 		C.cursor_row_close_wrapper(c.inner)
+		releaseHandle(c)
+		c.inner = nil
+	}
+}
+
+// Filesort sorts rows of a Meta with the engine's external merge sort. Rows
+// are encoded into a memory-mapped file as they are added and read back from
+// it as the sort compares them, so more rows than fit in memory can be
+// sorted: the OS pages the file out to disk under memory pressure, and
+// FilesortOptions.MemoryLimit bounds how much of it is mapped. With
+// FilesortOptions.Parallel the rows go to a new file every RunRows rows; the
+// files are sorted concurrently and merged into one.
+type Filesort struct {
+	inner   *C.struct_flintdb_filesort // file rows are added to
+	meta    *Meta
+	path    string
+	base    string // path of the merged file
+	opts    FilesortOptions
+	runs    []filesortRun // full files before inner, in the order added
+	seq     int
+	columns []C.int // key columns
+	desc    []C.i8
+	unique  bool
+	top     int64
+}
+
+// filesortRun is one sort file of a parallel sort.
+type filesortRun struct {
+	inner *C.struct_flintdb_filesort
+	path  string
+}
+
+// filesortRunRows is the default FilesortOptions.RunRows.
+const filesortRunRows = 1 << 20
+
+// FilesortOptions controls NewFilesortOptions.
+type FilesortOptions struct {
+	TempDir     string // directory of the sort file when no path is given; "" means os.TempDir()
+	MemoryLimit int64  // bytes of each sort file kept mapped at once, least recently used unmapped first; 0 means no limit
+	Parallel    int    // sort files sorted at once; 0 or 1 sorts a single file
+	RunRows     int64  // rows per sort file with Parallel; 0 means 1M
+}
+
+// NewFilesort creates a sorter for rows of meta stored in the file at path,
+// or in a temporary file if path is empty. Each key is a column name,
+// optionally followed by ASC or DESC as in ORDER BY; rows are ordered by the
+// first key, then the next. The meta must stay open while the sorter is.
+func NewFilesort(path string, meta *Meta, keys ...string) (*Filesort, error) {
+	return NewFilesortOptions(path, meta, FilesortOptions{}, keys...)
+}
+
+// NewFilesortOptions is NewFilesort with control over where the sort file
+// goes, how much of it is held in memory and how many cores sort it. The
+// files of a parallel sort are named after the first, with a numeric suffix.
+func NewFilesortOptions(path string, meta *Meta, opts FilesortOptions, keys ...string) (*Filesort, error) {
+	s := &Filesort{meta: meta, path: path, opts: opts}
+	if s.opts.RunRows <= 0 {
+		s.opts.RunRows = filesortRunRows
+	}
+	for _, key := range keys {
+		name, desc, err := parseSortKey(key)
+		if err != nil {
+			return nil, err
+		}
+		column := meta.ColumnAt(name)
+		if column < 0 {
+			return nil, &FlintDBError{Message: fmt.Sprintf("sort key column not found: %s", name)}
+		}
+		s.columns = append(s.columns, C.int(column))
+		if desc {
+			s.desc = append(s.desc, 1)
+		} else {
+			s.desc = append(s.desc, 0)
+		}
+	}
+	if s.path == "" {
+		file, err := os.CreateTemp(opts.TempDir, "flintdb_sort_*.tmp")
+		if err != nil {
+			return nil, err
+		}
+		file.Close()
+		os.Remove(file.Name())
+		s.path = file.Name()
+	}
+	s.base = s.path
+
+	inner, err := s.newFile(s.path)
+	if err != nil {
+		return nil, err
+	}
+	s.inner = inner
+	return s, nil
+}
+
+// parseSortKey splits a sort key, a column name optionally followed by ASC
+// or DESC.
+func parseSortKey(key string) (string, bool, error) {
+	fields := strings.Fields(key)
+	if len(fields) == 0 || len(fields) > 2 {
+		return "", false, &FlintDBError{Message: fmt.Sprintf("bad sort key: %q", key)}
+	}
+	if len(fields) == 1 {
+		return fields[0], false, nil
+	}
+	switch strings.ToUpper(fields[1]) {
+	case "ASC":
+		return fields[0], false, nil
+	case "DESC":
+		return fields[0], true, nil
+	}
+	return "", false, &FlintDBError{Message: fmt.Sprintf("bad sort key: %q", key)}
+}
+
+// newFile creates an empty sort file at path.
+func (s *Filesort) newFile(path string) (*C.struct_flintdb_filesort, error) {
+	var e *C.char
+	cpath := cPath(path)
+	defer C.free(unsafe.Pointer(cpath))
+	inner := C.flintdb_filesort_new_bounded(cpath, &s.meta.inner, C.i64(s.opts.MemoryLimit), &e)
+	if err := checkError(e); err != nil {
+		return nil, err
+	}
+	if inner == nil {
+		return nil, &FlintDBError{Message: "failed to create filesort"}
+	}
+	return inner, nil
+}
+
+func (s *Filesort) CreateRow() (*Row, error) {
+	return s.meta.newRow()
+}
+
+// Add appends a copy of row; the caller still owns row.
+func (s *Filesort) Add(row *Row) error {
+	if s.opts.Parallel > 1 && int64(C.filesort_rows_wrapper(s.inner)) >= s.opts.RunRows {
+		s.seq++
+		path := fmt.Sprintf("%s.%d", s.base, s.seq)
+		inner, err := s.newFile(path)
+		if err != nil {
+			return err
+		}
+		s.runs = append(s.runs, filesortRun{inner: s.inner, path: s.path})
+		s.inner, s.path = inner, path
+	}
+	var e *C.char
+	C.filesort_add_wrapper(s.inner, row.inner, &e)
+	return checkError(e)
+}
+
+func (s *Filesort) Rows() int64 {
+	n := int64(C.filesort_rows_wrapper(s.inner))
+	for _, run := range s.runs {
+		n += int64(C.filesort_rows_wrapper(run.inner))
+	}
+	return n
+}
+
+// SetUnique makes the sorts that follow keep only the first row added of
+// each run of rows with equal keys, for deduplication.
+func (s *Filesort) SetUnique(unique bool) {
+	s.unique = unique
+}
+
+// SetTopN makes the sorts that follow keep only the first n rows in sorted
+// order; 0 keeps every row.
+func (s *Filesort) SetTopN(n int64) {
+	s.top = n
+}
+
+// Sort orders the rows added so far by the sorter's keys. Without keys the
+// rows keep the order they were added in.
+func (s *Filesort) Sort() error {
+	if len(s.columns) == 0 {
+		return s.sort(func(inner *C.struct_flintdb_filesort) error {
+			if s.top > 0 {
+				C.flintdb_filesort_limit(inner, C.i64(s.top))
+			}
+			return nil
+		}, nil)
+	}
+	return s.sortKeys(s.columns, s.desc)
+}
+
+func (s *Filesort) uniqueFlag() C.int {
+	if s.unique {
+		return 1
+	}
+	return 0
+}
+
+func (s *Filesort) sortKeys(columns []C.int, desc []C.i8) error {
+	return s.sort(func(inner *C.struct_flintdb_filesort) error {
+		var e *C.char
+		C.filesort_sort_keys_wrapper(inner, &columns[0], &desc[0], C.int(len(columns)), s.uniqueFlag(), C.longlong(s.top), &e)
+		return checkError(e)
+	}, func(a, b *Row) int {
+		return int(C.filesort_keys_compare_wrapper(a.inner, b.inner, &columns[0], &desc[0], C.int(len(columns))))
+	})
+}
+
+// sort sorts each sort file with sortFile, Parallel of them at a time, and
+// merges them into one by cmp. A nil cmp keeps the order the rows were added
+// in and ignores SetUnique.
+func (s *Filesort) sort(sortFile func(*C.struct_flintdb_filesort) error, cmp func(a, b *Row) int) error {
+	if len(s.runs) == 0 {
+		return sortFile(s.inner)
+	}
+	files := append(s.runs, filesortRun{inner: s.inner, path: s.path})
+	errs := make([]error, len(files))
+	sem := make(chan struct{}, s.opts.Parallel)
+	var wg sync.WaitGroup
+	for i, f := range files {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			// the engine reports errors through a thread-local buffer
+			runtime.LockOSThread()
+			defer runtime.UnlockOSThread()
+			errs[i] = sortFile(f.inner)
+			<-sem
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return s.merge(files, cmp)
+}
+
+// merge writes the rows of sorted files to a new sort file in cmp order,
+// ties going to the file added to first, and replaces the files with it.
+func (s *Filesort) merge(files []filesortRun, cmp func(a, b *Row) int) (err error) {
+	path := s.base + ".merge"
+	out, err := s.newFile(path)
+	if err != nil {
+		return err
+	}
+	h := &filesortHeap{cmp: cmp}
+	var last *Row
+	defer func() {
+		for _, m := range h.items {
+			m.row.Free()
+		}
+		if last != nil {
+			last.Free()
+		}
+		if out != nil {
+			C.filesort_close_wrapper(out)
+			os.Remove(path)
+		}
+	}()
+
+	next := func(m *filesortMerge) error {
+		if m.next >= int64(C.filesort_rows_wrapper(files[m.file].inner)) {
+			m.row = nil
+			return nil
+		}
+		row, err := readFilesort(files[m.file].inner, m.next, s.meta)
+		m.row = row
+		m.next++
+		return err
+	}
+	for i := range files {
+		m := &filesortMerge{file: i}
+		if err := next(m); err != nil {
+			return err
+		}
+		if m.row != nil {
+			h.items = append(h.items, m)
+		}
+	}
+	heap.Init(h)
+	var n int64
+	for len(h.items) > 0 && (s.top == 0 || n < s.top) {
+		m := h.items[0]
+		if !s.unique || cmp == nil || last == nil || cmp(last, m.row) != 0 {
+			var e *C.char
+			C.filesort_add_wrapper(out, m.row.inner, &e)
+			if err := checkError(e); err != nil {
+				return err
+			}
+			n++
+		}
+		if last != nil {
+			last.Free()
+		}
+		last = m.row
+		if err := next(m); err != nil {
+			return err
+		}
+		if m.row == nil {
+			heap.Pop(h)
+		} else {
+			heap.Fix(h, 0)
+		}
+	}
+
+	for _, f := range files {
+		C.filesort_close_wrapper(f.inner)
+		os.Remove(f.path)
+	}
+	if err := os.Rename(path, s.base); err != nil {
+		// the merged file stays usable under its own name
+		s.inner, s.path = out, path
+	} else {
+		s.inner, s.path = out, s.base
+	}
+	s.runs, out = nil, nil
+	return nil
+}
+
+// filesortMerge is a sort file's next row in a merge.
+type filesortMerge struct {
+	row  *Row
+	file int
+	next int64
+}
+
+// filesortHeap orders the next rows of the files being merged.
+type filesortHeap struct {
+	items []*filesortMerge
+	cmp   func(a, b *Row) int
+}
+
+func (h *filesortHeap) Len() int { return len(h.items) }
+
+func (h *filesortHeap) Less(i, j int) bool {
+	a, b := h.items[i], h.items[j]
+	if h.cmp != nil {
+		if c := h.cmp(a.row, b.row); c != 0 {
+			return c < 0
+		}
+	}
+	return a.file < b.file
+}
+
+func (h *filesortHeap) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+
+func (h *filesortHeap) Push(x interface{}) { h.items = append(h.items, x.(*filesortMerge)) }
+
+func (h *filesortHeap) Pop() interface{} {
+	m := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return m
+}
+
+// SortKey orders rows by one column. Collation applies to STRING columns;
+// the zero value is binary.
+type SortKey struct {
+	Column    string
+	Desc      bool
+	Collation Collation
+}
+
+// SortBy orders the rows added so far by keys instead of the sorter's own.
+// Binary keys are compared by the engine; a key with another collation
+// compares the rows in Go, which is slower.
+func (s *Filesort) SortBy(keys ...SortKey) error {
+	columns := make([]C.int, len(keys))
+	desc := make([]C.i8, len(keys))
+	collated := false
+	for i, key := range keys {
+		column := s.meta.ColumnAt(key.Column)
+		if column < 0 {
+			return &FlintDBError{Message: fmt.Sprintf("sort key column not found: %s", key.Column)}
+		}
+		if key.Collation.key != nil {
+			if s.meta.inner.columns.a[column]._type != VARIANT_STRING {
+				return &FlintDBError{Message: fmt.Sprintf("collation on non-STRING column: %s", key.Column)}
+			}
+			collated = true
+		}
+		columns[i] = C.int(column)
+		if key.Desc {
+			desc[i] = 1
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	if !collated {
+		return s.sortKeys(columns, desc)
+	}
+	return s.SortFunc(func(a, b *Row) int {
+		for i, key := range keys {
+			var c int
+			if key.Collation.key != nil {
+				x, _ := a.GetString(int(columns[i]))
+				y, _ := b.GetString(int(columns[i]))
+				c = key.Collation.Compare(x, y)
+			} else {
+				c = int(C.row_column_compare_wrapper(a.inner, b.inner, columns[i]))
+			}
+			if c != 0 {
+				if key.Desc {
+					return -c
+				}
+				return c
+			}
+		}
+		return 0
+	})
+}
+
+// SortFunc orders the rows added so far by cmp, which returns a negative
+// number, zero or a positive number as a sorts before, with or after b.
+// Rows comparing equal keep the order they were added in. The rows passed
+// to cmp are valid only during the call. With Parallel, cmp is called from
+// several goroutines at once.
+func (s *Filesort) SortFunc(cmp func(a, b *Row) int) error {
+	var mu sync.Mutex
+	var funcs []*filesortFunc
+	err := s.sort(func(inner *C.struct_flintdb_filesort) error {
+		f := &filesortFunc{cmp: cmp, meta: &s.meta.inner}
+		mu.Lock()
+		funcs = append(funcs, f)
+		mu.Unlock()
+		h := cgo.NewHandle(f)
+		defer h.Delete()
+		var e *C.char
+		C.filesort_sort_func_wrapper(inner, C.uintptr_t(h), s.uniqueFlag(), C.longlong(s.top), &e)
+		if f.panicked != nil {
+			return &FlintDBError{Message: "sort comparison panicked"}
+		}
+		return checkError(e)
+	}, cmp)
+	for _, f := range funcs {
+		if f.panicked != nil {
+			panic(f.panicked)
+		}
+	}
+	return err
+}
+
+// Read returns row i, 0-based, in sorted order once Sort has run. The caller
+// frees the row.
+func (s *Filesort) Read(i int64) (*Row, error) {
+	for _, run := range s.runs {
+		n := int64(C.filesort_rows_wrapper(run.inner))
+		if i < n {
+			return readFilesort(run.inner, i, s.meta)
+		}
+		i -= n
+	}
+	return readFilesort(s.inner, i, s.meta)
+}
+
+func readFilesort(inner *C.struct_flintdb_filesort, i int64, meta *Meta) (*Row, error) {
+	var e *C.char
+	row := C.filesort_read_wrapper(inner, C.longlong(i), &e)
+	if err := checkError(e); err != nil {
+		return nil, err
+	}
+	if row == nil {
+		return nil, &FlintDBError{Message: "failed to read row"}
+	}
+	r := &Row{inner: row, meta: &meta.inner, owned: true}
+	trackHandle("row", r, nil)
+	return r, nil
+}
+
+// Close releases the sorter and removes its files.
+func (s *Filesort) Close() {
+	for _, run := range s.runs {
+		C.filesort_close_wrapper(run.inner)
+		os.Remove(run.path)
+	}
+	s.runs = nil
+	if s.inner != nil {
+		C.filesort_close_wrapper(s.inner)
+		s.inner = nil
+		os.Remove(s.path)
 	}
 }
 
-// Cleanup releases all FlintDB resources
+// Cleanup releases all FlintDB resources. Built with -tags flintdb_debug,
+// it first reports the handles still open as leaks.
 func Cleanup() {
+	reportLeaks()
 	C.flintdb_cleanup(nil)
 }
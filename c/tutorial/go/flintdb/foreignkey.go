@@ -0,0 +1,138 @@
+package flintdb
+
+import "fmt"
+
+// ForeignKeyViolation is a child row whose column value has no match in the parent table.
+type ForeignKeyViolation struct {
+	RowID int64
+	Value interface{}
+}
+
+type foreignKey struct {
+	column       string
+	parent       *Table
+	parentColumn string
+}
+
+// CheckForeignKey scans child and reports every row whose childCol value is
+// missing from parentCol in parent. NULL values are not checked. parentCol
+// should be indexed, since every distinct child value is probed once.
+func CheckForeignKey(child *Table, childCol string, parent *Table, parentCol string) ([]ForeignKeyViolation, error) {
+	if err := child.requireColumn(childCol); err != nil {
+		return nil, err
+	}
+	if err := parent.requireColumn(parentCol); err != nil {
+		return nil, err
+	}
+
+	cursor, err := child.Find("")
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close()
+
+	var violations []ForeignKeyViolation
+	found := make(map[string]bool)
+	for {
+		rowid, err := cursor.Next()
+		if err != nil {
+			return nil, err
+		}
+		if rowid < 0 {
+			break
+		}
+
+		row, err := child.Read(rowid)
+		if err != nil {
+			return nil, err
+		}
+		value, err := row.GetByName(childCol)
+		if err != nil {
+			return nil, err
+		}
+		if value == nil {
+			continue
+		}
+
+		literal, err := formatLiteral(value)
+		if err != nil {
+			return nil, err
+		}
+		ok, seen := found[literal]
+		if !seen {
+			ok, err = parent.containsLiteral(parentCol, literal)
+			if err != nil {
+				return nil, err
+			}
+			found[literal] = ok
+		}
+		if !ok {
+			violations = append(violations, ForeignKeyViolation{RowID: rowid, Value: value})
+		}
+	}
+	return violations, nil
+}
+
+// AddForeignKey makes Insert and UpdateAt fail when the value of col has no
+// match in parentCol of parent. The parent table must stay open while t is used.
+func (t *Table) AddForeignKey(col string, parent *Table, parentCol string) error {
+	if err := t.requireColumn(col); err != nil {
+		return err
+	}
+	if err := parent.requireColumn(parentCol); err != nil {
+		return err
+	}
+	t.foreignKeys = append(t.foreignKeys, foreignKey{column: col, parent: parent, parentColumn: parentCol})
+	return nil
+}
+
+func (t *Table) checkForeignKeys(row *Row) error {
+	for _, fk := range t.foreignKeys {
+		value, err := row.GetByName(fk.column)
+		if err != nil {
+			return err
+		}
+		if value == nil {
+			continue
+		}
+
+		literal, err := formatLiteral(value)
+		if err != nil {
+			return err
+		}
+		ok, err := fk.parent.containsLiteral(fk.parentColumn, literal)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return &ConstraintError{
+				Constraint: "FOREIGN KEY",
+				Column:     fk.column,
+				Value:      value,
+				Detail:     fmt.Sprintf("no matching %s in parent table", fk.parentColumn),
+			}
+		}
+	}
+	return nil
+}
+
+func (t *Table) containsLiteral(col string, literal string) (bool, error) {
+	cursor, err := t.Find(fmt.Sprintf("WHERE %s = %s LIMIT 1", col, literal))
+	if err != nil {
+		return false, err
+	}
+	defer cursor.Close()
+
+	rowid, err := cursor.Next()
+	if err != nil {
+		return false, err
+	}
+	return rowid >= 0, nil
+}
+
+func (t *Table) requireColumn(col string) error {
+	if t.columnAt(col) < 0 {
+		return &FlintDBError{Message: fmt.Sprintf("column not found: %s", col)}
+	}
+	return nil
+}
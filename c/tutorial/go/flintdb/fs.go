@@ -0,0 +1,149 @@
+package flintdb
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// fsVFSName is the storage of tables opened by OpenTableFS. Their engine file
+// names are fsPrefix, the id of their fs.FS, a slash and the path in it.
+const (
+	fsVFSName = "GOFS"
+	fsPrefix  = "gofs:"
+)
+
+var fsTables = struct {
+	sync.Mutex
+	once sync.Once
+	err  error
+	next int
+	open map[int]*fsEntry
+}{open: map[int]*fsEntry{}}
+
+type fsEntry struct {
+	fsys fs.FS
+	refs int
+}
+
+// OpenTableFS opens the table at path in fsys read-only, such as a small
+// lookup table embedded in the binary with //go:embed. Its .desc, data and
+// index files are all read from fsys; side indexes and the other settings of
+// its .ext.json are not loaded.
+func OpenTableFS(fsys fs.FS, path string, opts ...OpenOption) (*Table, error) {
+	fsTables.once.Do(func() { fsTables.err = RegisterVFS(fsVFSName, fsVFS{}) })
+	if fsTables.err != nil {
+		return nil, fsTables.err
+	}
+	desc, err := fs.ReadFile(fsys, path+META_NAME_SUFFIX)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := parseMeta(string(desc))
+	if err != nil {
+		return nil, err
+	}
+	defer meta.Close()
+	if err := meta.SetStorage(fsVFSName); err != nil {
+		return nil, err
+	}
+
+	fsTables.Lock()
+	fsTables.next++
+	id := fsTables.next
+	fsTables.open[id] = &fsEntry{fsys: fsys, refs: 1}
+	fsTables.Unlock()
+
+	t, err := openTableFS(id, fmt.Sprintf("%s%d/%s", fsPrefix, id, path), &meta.inner, opts)
+	if err != nil {
+		releaseFS(id)
+		return nil, err
+	}
+	return t, nil
+}
+
+// acquireFS keeps the fs.FS of id registered until a matching releaseFS.
+func acquireFS(id int) {
+	fsTables.Lock()
+	fsTables.open[id].refs++
+	fsTables.Unlock()
+}
+
+func releaseFS(id int) {
+	fsTables.Lock()
+	defer fsTables.Unlock()
+	if x := fsTables.open[id]; x != nil {
+		if x.refs--; x.refs == 0 {
+			delete(fsTables.open, id)
+		}
+	}
+}
+
+// fsVFS serves the files of tables opened by OpenTableFS from their fs.FS.
+type fsVFS struct{}
+
+func (fsVFS) Open(name string, mode uint32) (VFSFile, error) {
+	if mode != FLINTDB_RDONLY {
+		return nil, &FlintDBError{Message: fmt.Sprintf("read-only file system: %s", name)}
+	}
+	rest, ok := strings.CutPrefix(name, fsPrefix)
+	ids, path, found := strings.Cut(rest, "/")
+	if !ok || !found {
+		return nil, &FlintDBError{Message: fmt.Sprintf("not a file system table: %s", name)}
+	}
+	id, err := strconv.Atoi(ids)
+	if err != nil {
+		return nil, &FlintDBError{Message: fmt.Sprintf("not a file system table: %s", name)}
+	}
+	fsTables.Lock()
+	x := fsTables.open[id]
+	fsTables.Unlock()
+	if x == nil {
+		return nil, &FlintDBError{Message: fmt.Sprintf("file system closed: %s", name)}
+	}
+
+	f, err := x.fsys.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if r, ok := f.(io.ReaderAt); ok {
+		return &fsFile{ReaderAt: r, size: st.Size(), f: f}, nil
+	}
+	// Files without ReadAt are read into memory once.
+	data, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+	return &fsFile{ReaderAt: bytes.NewReader(data), size: int64(len(data))}, nil
+}
+
+type fsFile struct {
+	io.ReaderAt
+	size int64
+	f    fs.File
+}
+
+func (f *fsFile) WriteAt(p []byte, off int64) (int, error) {
+	return 0, &FlintDBError{Message: "read-only file system"}
+}
+
+func (f *fsFile) Sync() error { return nil }
+
+func (f *fsFile) Size() (int64, error) { return f.size, nil }
+
+func (f *fsFile) Close() error {
+	if f.f != nil {
+		return f.f.Close()
+	}
+	return nil
+}
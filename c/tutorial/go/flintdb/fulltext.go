@@ -0,0 +1,209 @@
+package flintdb
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+type fullTextDef struct {
+	Name    string   `json:"name"`
+	Columns []string `json:"columns"`
+}
+
+// AddFullTextIndex declares an inverted index over the words of STRING
+// columns, queried with Table.Search and kept up to date by Insert, UpdateAt
+// and DeleteAt.
+func (m *Meta) AddFullTextIndex(name string, columns []string) error {
+	if err := m.checkSideIndexName(name); err != nil {
+		return err
+	}
+	if len(columns) == 0 {
+		return &FlintDBError{Message: "full-text index needs at least one column"}
+	}
+	for _, col := range columns {
+		idx := m.ColumnAt(col)
+		if idx < 0 {
+			return &FlintDBError{Message: fmt.Sprintf("column not found: %s", col)}
+		}
+		if int(m.inner.columns.a[idx]._type) != VARIANT_STRING {
+			return &FlintDBError{Message: fmt.Sprintf("full-text index requires STRING columns: %s", col)}
+		}
+	}
+	m.ext.FullText = append(m.ext.FullText, fullTextDef{Name: name, Columns: append([]string(nil), columns...)})
+	return nil
+}
+
+// SearchResult is a row matched by Table.Search.
+type SearchResult struct {
+	RowID int64
+	Score float64
+}
+
+type fullTextDoc struct {
+	Terms  map[string]int32 // term frequencies
+	Length int
+}
+
+type fullTextIndex struct {
+	columns  []int
+	docs     map[int64]fullTextDoc
+	postings map[string]map[int64]int32
+	totalLen int
+}
+
+// fullTextTerms splits s into case-folded words.
+func fullTextTerms(s string) []string {
+	return strings.FieldsFunc(foldCase(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func newFullTextIndex(t *Table, def fullTextDef) (*fullTextIndex, error) {
+	x := &fullTextIndex{
+		docs:     map[int64]fullTextDoc{},
+		postings: map[string]map[int64]int32{},
+	}
+	for _, col := range def.Columns {
+		idx := t.columnAt(col)
+		if idx < 0 {
+			return nil, &FlintDBError{Message: fmt.Sprintf("full-text index %s: column not found: %s", def.Name, col)}
+		}
+		x.columns = append(x.columns, idx)
+	}
+	return x, nil
+}
+
+func (x *fullTextIndex) put(rowid int64, row *Row) error {
+	doc := fullTextDoc{Terms: map[string]int32{}}
+	for _, col := range x.columns {
+		null, err := row.IsNull(col)
+		if err != nil {
+			return err
+		}
+		if null {
+			continue
+		}
+		s, err := row.GetString(col)
+		if err != nil {
+			return err
+		}
+		for _, term := range fullTextTerms(s) {
+			doc.Terms[term]++
+			doc.Length++
+		}
+	}
+	x.remove(rowid)
+	x.insert(rowid, doc)
+	return nil
+}
+
+func (x *fullTextIndex) insert(rowid int64, doc fullTextDoc) {
+	if doc.Length == 0 {
+		return
+	}
+	x.docs[rowid] = doc
+	x.totalLen += doc.Length
+	for term, tf := range doc.Terms {
+		p := x.postings[term]
+		if p == nil {
+			p = map[int64]int32{}
+			x.postings[term] = p
+		}
+		p[rowid] = tf
+	}
+}
+
+func (x *fullTextIndex) remove(rowid int64) {
+	doc, ok := x.docs[rowid]
+	if !ok {
+		return
+	}
+	delete(x.docs, rowid)
+	x.totalLen -= doc.Length
+	for term := range doc.Terms {
+		p := x.postings[term]
+		delete(p, rowid)
+		if len(p) == 0 {
+			delete(x.postings, term)
+		}
+	}
+}
+
+// The postings are derived from the documents, so only those are saved.
+func (x *fullTextIndex) encode(w io.Writer) error {
+	return gob.NewEncoder(w).Encode(x.docs)
+}
+
+func (x *fullTextIndex) decode(r io.Reader) error {
+	var docs map[int64]fullTextDoc
+	if err := gob.NewDecoder(r).Decode(&docs); err != nil {
+		return err
+	}
+	for rowid, doc := range docs {
+		x.insert(rowid, doc)
+	}
+	return nil
+}
+
+// BM25 parameters.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// Search returns the rows containing any word of query in a full-text
+// indexed column, best match first. Words are case-folded; rows are ranked
+// by BM25, summed over the table's full-text indexes.
+func (t *Table) Search(query string) ([]SearchResult, error) {
+	terms := map[string]bool{}
+	for _, term := range fullTextTerms(query) {
+		terms[term] = true
+	}
+
+	found := false
+	scores := map[int64]float64{}
+	for _, s := range t.sideIndexes {
+		x, ok := s.index.(*fullTextIndex)
+		if !ok {
+			continue
+		}
+		found = true
+		s.mu.RLock()
+		n := float64(len(x.docs))
+		avgLen := float64(x.totalLen) / math.Max(n, 1)
+		for term := range terms {
+			p := x.postings[term]
+			if len(p) == 0 {
+				continue
+			}
+			df := float64(len(p))
+			idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+			for rowid, tf := range p {
+				dl := float64(x.docs[rowid].Length)
+				f := float64(tf)
+				scores[rowid] += idf * f * (bm25K1 + 1) / (f + bm25K1*(1-bm25B+bm25B*dl/avgLen))
+			}
+		}
+		s.mu.RUnlock()
+	}
+	if !found {
+		return nil, &FlintDBError{Message: "table has no full-text index"}
+	}
+
+	results := make([]SearchResult, 0, len(scores))
+	for rowid, score := range scores {
+		results = append(results, SearchResult{RowID: rowid, Score: score})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].RowID < results[j].RowID
+	})
+	return results, nil
+}
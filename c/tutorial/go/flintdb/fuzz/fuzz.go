@@ -0,0 +1,157 @@
+// Package fuzz holds fuzz targets for the engine's query parser and delimited
+// line decoder, which malformed WHERE clauses and mangled TSV or CSV lines
+// reach through the C layer. Go fuzzes only from test files, so a test of
+// another module wires the targets up:
+//
+//	func FuzzQuery(f *testing.F)         { fuzz.Query(f) }
+//	func FuzzDelimitedLine(f *testing.F) { fuzz.DelimitedLine(f) }
+//
+// and go test -fuzz FuzzQuery runs one. Without -fuzz, go test runs each
+// target on its seed corpus.
+package fuzz
+
+import (
+	"strings"
+	"testing"
+
+	flintdb "flintdb-tutorial/flintdb"
+)
+
+// Queries are the seed corpus of Query: the forms Find takes, and broken
+// ones.
+var Queries = []string{
+	"",
+	"WHERE id = 1",
+	"WHERE id > 10 LIMIT 5",
+	"WHERE id >= 1 AND id < 100 LIMIT 10, 20",
+	"USE INDEX(ix_name DESC) WHERE name = 'a' LIMIT 1",
+	"USE INDEX(nope) WHERE id > 1",
+	"WHERE name LIKE 'a%' OR score < 1.5",
+	"WHERE born >= '2024-01-01' AND data IS NOT NULL",
+	"WHERE (id = 1 OR (name = 'x' AND small IN (1, 2, 3)))",
+	"WHERE name = 'it''s'",
+	"WHERE id >",
+	"WHERE ((",
+	"WHERE name = 'abc",
+	"WHERE id > 1 AND",
+	"WHERE nope = 1",
+	"LIMIT x",
+	"LIMIT -1, -1",
+	"USE INDEX(",
+	"WHERE id = 99999999999999999999999",
+}
+
+// Lines are the seed corpus of DelimitedLine, with fields in the column order
+// of Meta.
+var Lines = []string{
+	"1\tAda\t1.5\t2024-01-02\t2024-01-02 03:04:05\t00ff\t7",
+	"1,Ada,1.5,2024-01-02,2024-01-02 03:04:05,00ff,7",
+	`2,"Lovelace, Ada","",,,,`,
+	"3\t\\N\t\\N\t\\N\t\\N\t\\N\t\\N",
+	"4\tesc\\tap\\\\ed\t-0\t0000-00-00\t25:61:61\tzz\t99999999999",
+	"",
+	"\t\t\t",
+	`"unterminated`,
+	"5,too,many,fields,,,,,,,,",
+	"x\ty",
+	"0" + strings.Repeat("\t", 40) + "x", // more fields than the decoder first makes room for
+}
+
+var columns = []struct {
+	name string
+	kind int
+	size int
+}{
+	{"id", flintdb.VARIANT_INT64, 0},
+	{"name", flintdb.VARIANT_STRING, 32},
+	{"score", flintdb.VARIANT_DOUBLE, 0},
+	{"born", flintdb.VARIANT_DATE, 0},
+	{"seen", flintdb.VARIANT_TIME, 0},
+	{"data", flintdb.VARIANT_BYTES, 16},
+	{"small", flintdb.VARIANT_INT32, 0},
+}
+
+// Meta returns the meta the targets parse against: a column of each type,
+// a primary key on id and an index on name.
+func Meta() (*flintdb.Meta, error) {
+	meta, err := flintdb.NewMeta("fuzz" + flintdb.TABLE_NAME_SUFFIX)
+	if err != nil {
+		return nil, err
+	}
+	for i, c := range columns {
+		spec := uint32(flintdb.SPEC_NULLABLE)
+		if i == 0 {
+			spec = flintdb.SPEC_NOT_NULL
+		}
+		if err := meta.AddColumn(c.name, c.kind, c.size, 0, spec, "", ""); err != nil {
+			meta.Close()
+			return nil, err
+		}
+	}
+	if err := meta.AddIndex(flintdb.PRIMARY_NAME, []string{"id"}); err != nil {
+		meta.Close()
+		return nil, err
+	}
+	if err := meta.AddIndex("ix_name", []string{"name"}); err != nil {
+		meta.Close()
+		return nil, err
+	}
+	return meta, nil
+}
+
+// Query fuzzes ParseQuery, failing on a crash or a parse that makes no sense.
+func Query(f *testing.F) {
+	meta, err := Meta()
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Cleanup(meta.Close)
+	for _, q := range Queries {
+		f.Add(q)
+	}
+	f.Fuzz(func(t *testing.T, query string) {
+		q, err := flintdb.ParseQuery(meta, query)
+		if err != nil {
+			return
+		}
+		if q.Index != flintdb.PRIMARY_NAME && q.Index != "ix_name" {
+			t.Fatalf("%q: unknown index %q", query, q.Index)
+		}
+		if q.Offset < 0 || q.Limit < -1 {
+			t.Fatalf("%q: offset %d, limit %d", query, q.Offset, q.Limit)
+		}
+	})
+}
+
+// DelimitedLine fuzzes ParseDelimitedLine as TSV and as CSV, failing on a
+// crash or a decoded row whose values cannot be read.
+func DelimitedLine(f *testing.F) {
+	tsv, err := Meta()
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Cleanup(tsv.Close)
+	csv, err := Meta()
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Cleanup(csv.Close)
+	csv.SetFormatCSV()
+	for _, line := range Lines {
+		f.Add(line)
+	}
+	f.Fuzz(func(t *testing.T, line string) {
+		for _, meta := range []*flintdb.Meta{tsv, csv} {
+			row, err := flintdb.ParseDelimitedLine(meta, line)
+			if err != nil {
+				continue
+			}
+			for i := range columns {
+				if _, err := row.Get(i); err != nil {
+					t.Errorf("%q: column %d: %v", line, i, err)
+				}
+			}
+			row.Free()
+		}
+	})
+}
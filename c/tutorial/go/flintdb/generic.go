@@ -0,0 +1,310 @@
+package flintdb
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/text/transform"
+)
+
+// textFormat is the delimited text layout of a meta, with the engine's
+// defaults: a quote character selects CSV-style quoting, no quote selects
+// backslash escapes, and NULL is "NULL" in CSV and \N otherwise.
+type textFormat struct {
+	delimiter rune
+	quoted    bool
+	null      string
+	header    bool
+	crlf      bool
+}
+
+func (m *Meta) textFormat() textFormat {
+	f := textFormat{delimiter: rune(m.inner.delimiter), quoted: m.inner.quote != 0, null: `\N`, header: m.inner.absent_header == 0, crlf: m.crlf}
+	if cstring(m.inner.format[:]) == "csv" {
+		f.null = "NULL"
+	}
+	if f.delimiter == 0 {
+		f.delimiter = '\t'
+	}
+	if s := cstring(m.inner.nil_str[:]); s == `""` {
+		f.null = ""
+	} else if s != "" {
+		f.null = s
+	}
+	return f
+}
+
+// GenericReader parses the rows of a delimited text stream, such as stdin or
+// an HTTP body, the way GenericFile reads a file.
+type GenericReader struct {
+	meta    *Meta
+	format  textFormat
+	csv     *csv.Reader
+	text    *bufio.Reader
+	row     *Row
+	line    int64
+	fields  int   // fields per line
+	fieldOf []int // column -> field, from the header
+}
+
+// OpenGenericReader reads rows of meta from r. Unless meta marks the header
+// absent, the first line is a header whose names map fields to columns.
+// Unlike GenericFile, a quoted CSV field equal to the NULL token is NULL too.
+func OpenGenericReader(r io.Reader, meta *Meta) (*GenericReader, error) {
+	if meta == nil || meta.inner.columns.length == 0 {
+		return nil, &FlintDBError{Message: "meta has no columns"}
+	}
+	g := &GenericReader{meta: meta, format: meta.textFormat()}
+	if meta.encoding != nil {
+		r = transform.NewReader(r, meta.encoding.NewDecoder())
+	}
+	if g.format.quoted {
+		g.csv = csv.NewReader(r)
+		g.csv.Comma = g.format.delimiter
+		g.csv.FieldsPerRecord = -1
+	} else {
+		g.text = bufio.NewReader(r)
+	}
+	row, err := meta.newRow()
+	if err != nil {
+		return nil, err
+	}
+	g.row = row
+	g.fields = int(meta.inner.columns.length)
+	if g.format.header {
+		if err := g.header(); err != nil {
+			g.Close()
+			return nil, err
+		}
+	}
+	return g, nil
+}
+
+func (g *GenericReader) header() error {
+	names, err := g.record()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	g.fields = len(names)
+	g.fieldOf = make([]int, g.meta.inner.columns.length)
+	for i := range g.fieldOf {
+		name := cstring(g.meta.inner.columns.a[i].name[:])
+		g.fieldOf[i] = -1
+		for k, n := range names {
+			if !g.format.quoted {
+				n = unescapeTSV(n)
+			}
+			if strings.EqualFold(n, name) {
+				g.fieldOf[i] = k
+				break
+			}
+		}
+		if g.fieldOf[i] < 0 {
+			return &FlintDBError{Message: fmt.Sprintf("header has no column: %s", name)}
+		}
+	}
+	return nil
+}
+
+// record returns the raw fields of the next line.
+func (g *GenericReader) record() ([]string, error) {
+	g.line++
+	if g.csv != nil {
+		return g.csv.Read()
+	}
+	text, err := g.text.ReadString('\n')
+	if text == "" && err != nil {
+		return nil, err
+	}
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	text = strings.TrimSuffix(strings.TrimSuffix(text, "\n"), "\r")
+	return splitEscaped(text, g.format.delimiter), nil
+}
+
+// Next returns the next row, or nil at the end of the stream. The row belongs
+// to the reader and is valid until the next call.
+func (g *GenericReader) Next() (*Row, error) {
+	fields, err := g.record()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	columns := &g.meta.inner.columns
+	if len(fields) != g.fields {
+		return nil, &FlintDBError{Message: fmt.Sprintf("line %d: expected %d fields, found %d", g.line, g.fields, len(fields))}
+	}
+	for i := 0; i < int(columns.length); i++ {
+		f := fields[i]
+		if g.fieldOf != nil {
+			f = fields[g.fieldOf[i]]
+		}
+		var v interface{}
+		if f == g.format.null && columns.a[i].nullspec == SPEC_NOT_NULL && columns.a[i]._type == VARIANT_STRING {
+			v = f
+		} else if f != g.format.null {
+			if !g.format.quoted {
+				f = unescapeTSV(f)
+			}
+			if v, err = importText(f, int(columns.a[i]._type)); err != nil {
+				return nil, &FlintDBError{Message: fmt.Sprintf("line %d: %s: %v", g.line, cstring(columns.a[i].name[:]), err)}
+			}
+		}
+		if err := g.row.Set(i, v); err != nil {
+			return nil, &FlintDBError{Message: fmt.Sprintf("line %d: %s: %v", g.line, cstring(columns.a[i].name[:]), err)}
+		}
+	}
+	return g.row, nil
+}
+
+func (g *GenericReader) Close() {
+	if g.row != nil {
+		g.row.Free()
+		g.row = nil
+	}
+}
+
+// splitEscaped splits a backslash-escaped line on delim, keeping the escapes
+// for unescapeTSV except those of the delimiter itself.
+func splitEscaped(line string, delim rune) []string {
+	var fields []string
+	var b strings.Builder
+	escaped := false
+	for _, c := range line {
+		switch {
+		case escaped && c == delim:
+			b.WriteRune(c)
+			escaped = false
+		case escaped:
+			b.WriteByte('\\')
+			b.WriteRune(c)
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case c == delim:
+			fields = append(fields, b.String())
+			b.Reset()
+		default:
+			b.WriteRune(c)
+		}
+	}
+	if escaped {
+		b.WriteByte('\\')
+	}
+	return append(fields, b.String())
+}
+
+// GenericWriter writes rows as delimited text to a stream, the way
+// GenericFile writes a file. Close flushes the output.
+type GenericWriter struct {
+	meta    *Meta
+	format  textFormat
+	w       *bufio.Writer
+	encoder *transform.Writer // between w and the stream, for another encoding
+	csv     *csv.Writer
+	record  []string
+}
+
+// OpenGenericWriter writes rows of meta to w, starting with a header line
+// unless meta marks the header absent.
+func OpenGenericWriter(w io.Writer, meta *Meta) (*GenericWriter, error) {
+	if meta == nil || meta.inner.columns.length == 0 {
+		return nil, &FlintDBError{Message: "meta has no columns"}
+	}
+	g := &GenericWriter{meta: meta, format: meta.textFormat()}
+	if meta.encoding != nil {
+		g.encoder = transform.NewWriter(w, meta.encoding.NewEncoder())
+		w = g.encoder
+	}
+	g.w = bufio.NewWriter(w)
+	g.record = make([]string, meta.inner.columns.length)
+	if g.format.quoted {
+		g.csv = csv.NewWriter(g.w)
+		g.csv.Comma = g.format.delimiter
+		g.csv.UseCRLF = g.format.crlf
+	}
+	if g.format.header {
+		for i := range g.record {
+			g.record[i] = cstring(meta.inner.columns.a[i].name[:])
+		}
+		if err := g.writeRecord(g.record); err != nil {
+			return nil, err
+		}
+	}
+	return g, nil
+}
+
+func (g *GenericWriter) CreateRow() (*Row, error) {
+	return g.meta.newRow()
+}
+
+func (g *GenericWriter) Write(row *Row) error {
+	columns := &g.meta.inner.columns
+	for i := range g.record {
+		v, err := row.Get(i)
+		if err != nil {
+			return err
+		}
+		switch {
+		case v == nil:
+			g.record[i] = g.format.null
+		case g.format.quoted:
+			g.record[i] = exportText(v, int(columns.a[i]._type))
+		default:
+			g.record[i] = escapeDelimited(exportText(v, int(columns.a[i]._type)), g.format.delimiter)
+		}
+	}
+	return g.writeRecord(g.record)
+}
+
+func (g *GenericWriter) writeRecord(record []string) error {
+	if g.csv != nil {
+		return g.csv.Write(record)
+	}
+	for i, f := range record {
+		if i > 0 {
+			g.w.WriteRune(g.format.delimiter)
+		}
+		g.w.WriteString(f)
+	}
+	if g.format.crlf {
+		g.w.WriteByte('\r')
+	}
+	return g.w.WriteByte('\n')
+}
+
+// Close flushes the rows written; it does not close the underlying writer.
+func (g *GenericWriter) Close() error {
+	if g.csv != nil {
+		g.csv.Flush()
+		if err := g.csv.Error(); err != nil {
+			return err
+		}
+	}
+	if err := g.w.Flush(); err != nil {
+		return err
+	}
+	if g.encoder != nil {
+		return g.encoder.Close()
+	}
+	return nil
+}
+
+// escapeDelimited escapes a field for backslash mode, including a delimiter
+// other than tab.
+func escapeDelimited(s string, delim rune) string {
+	s = escapeTSV(s)
+	if delim != '\t' {
+		s = strings.ReplaceAll(s, string(delim), `\`+string(delim))
+	}
+	return s
+}
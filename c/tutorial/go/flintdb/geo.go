@@ -0,0 +1,257 @@
+package flintdb
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+type geoDef struct {
+	Name string `json:"name"`
+	Lat  string `json:"lat"`
+	Lon  string `json:"lon"`
+}
+
+// AddGeoIndex declares a spatial index on a pair of DOUBLE or FLOAT columns
+// holding latitude and longitude in degrees, queried with Table.FindNear.
+// A table has at most one.
+func (m *Meta) AddGeoIndex(name string, latCol string, lonCol string) error {
+	if err := m.checkSideIndexName(name); err != nil {
+		return err
+	}
+	if len(m.ext.Geo) > 0 {
+		return &FlintDBError{Message: "table already has a geo index"}
+	}
+	for _, col := range []string{latCol, lonCol} {
+		idx := m.ColumnAt(col)
+		if idx < 0 {
+			return &FlintDBError{Message: fmt.Sprintf("column not found: %s", col)}
+		}
+		if t := int(m.inner.columns.a[idx]._type); t != VARIANT_DOUBLE && t != VARIANT_FLOAT {
+			return &FlintDBError{Message: fmt.Sprintf("geo index requires DOUBLE or FLOAT columns: %s", col)}
+		}
+	}
+	m.ext.Geo = append(m.ext.Geo, geoDef{Name: name, Lat: latCol, Lon: lonCol})
+	return nil
+}
+
+// NearResult is a row matched by Table.FindNear.
+type NearResult struct {
+	RowID    int64
+	Distance float64 // meters
+}
+
+const (
+	earthRadius = 6371008.8 // mean radius, meters
+	geoCellDeg  = 0.1       // grid cell size, about 11 km of latitude
+	geoMaxCells = 1 << 16   // larger searches scan all points instead
+)
+
+type geoPoint struct {
+	Lat, Lon float64
+}
+
+type geoCell struct {
+	lat, lon int32
+}
+
+// geoCellsAround is the number of cells around a circle of latitude.
+var geoCellsAround = int32(math.Round(360 / geoCellDeg))
+
+func cellOf(p geoPoint) geoCell {
+	return geoCell{int32(math.Floor(p.Lat / geoCellDeg)), int32(math.Floor(p.Lon / geoCellDeg))}.wrap()
+}
+
+// wrap folds cells east or west of the antimeridian back into [-180, 180).
+func (c geoCell) wrap() geoCell {
+	half := geoCellsAround / 2
+	for c.lon >= half {
+		c.lon -= geoCellsAround
+	}
+	for c.lon < -half {
+		c.lon += geoCellsAround
+	}
+	return c
+}
+
+// geoIndex buckets points into a fixed grid of geoCellDeg degree cells.
+type geoIndex struct {
+	lat, lon int
+	points   map[int64]geoPoint
+	cells    map[geoCell]map[int64]struct{}
+}
+
+func newGeoIndex(t *Table, def geoDef) (*geoIndex, error) {
+	lat, lon := t.columnAt(def.Lat), t.columnAt(def.Lon)
+	if lat < 0 || lon < 0 {
+		return nil, &FlintDBError{Message: fmt.Sprintf("geo index %s: column not found", def.Name)}
+	}
+	return &geoIndex{
+		lat:    lat,
+		lon:    lon,
+		points: map[int64]geoPoint{},
+		cells:  map[geoCell]map[int64]struct{}{},
+	}, nil
+}
+
+// put leaves out rows with a NULL or out-of-range coordinate.
+func (x *geoIndex) put(rowid int64, row *Row) error {
+	x.remove(rowid)
+	var p geoPoint
+	for i, col := range []int{x.lat, x.lon} {
+		null, err := row.IsNull(col)
+		if err != nil {
+			return err
+		}
+		if null {
+			return nil
+		}
+		v, err := row.GetDouble(col)
+		if err != nil {
+			return err
+		}
+		if i == 0 {
+			p.Lat = v
+		} else {
+			p.Lon = v
+		}
+	}
+	if !(p.Lat >= -90 && p.Lat <= 90 && p.Lon >= -180 && p.Lon <= 180) {
+		return nil
+	}
+	x.insert(rowid, p)
+	return nil
+}
+
+func (x *geoIndex) insert(rowid int64, p geoPoint) {
+	x.points[rowid] = p
+	c := cellOf(p)
+	bucket := x.cells[c]
+	if bucket == nil {
+		bucket = map[int64]struct{}{}
+		x.cells[c] = bucket
+	}
+	bucket[rowid] = struct{}{}
+}
+
+func (x *geoIndex) remove(rowid int64) {
+	p, ok := x.points[rowid]
+	if !ok {
+		return
+	}
+	delete(x.points, rowid)
+	c := cellOf(p)
+	delete(x.cells[c], rowid)
+	if len(x.cells[c]) == 0 {
+		delete(x.cells, c)
+	}
+}
+
+func (x *geoIndex) encode(w io.Writer) error {
+	return gob.NewEncoder(w).Encode(x.points)
+}
+
+func (x *geoIndex) decode(r io.Reader) error {
+	var points map[int64]geoPoint
+	if err := gob.NewDecoder(r).Decode(&points); err != nil {
+		return err
+	}
+	for rowid, p := range points {
+		x.insert(rowid, p)
+	}
+	return nil
+}
+
+// haversine returns the great-circle distance between a and b in meters.
+func haversine(a, b geoPoint) float64 {
+	rad := math.Pi / 180
+	dLat := (b.Lat - a.Lat) * rad
+	dLon := (b.Lon - a.Lon) * rad
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(a.Lat*rad)*math.Cos(b.Lat*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// near returns the grid cells that may hold points within radius of center,
+// or nil when there are too many and every point should be checked instead.
+func (x *geoIndex) near(center geoPoint, radius float64) []geoCell {
+	dLat := radius / earthRadius * 180 / math.Pi
+	minLat, maxLat := center.Lat-dLat, center.Lat+dLat
+	if minLat <= -90 || maxLat >= 90 {
+		return nil // the circle covers a pole
+	}
+	// Longitude degrees shrink with latitude; use the widest point of the circle.
+	cos := math.Min(math.Cos(minLat*math.Pi/180), math.Cos(maxLat*math.Pi/180))
+	dLon := dLat / cos
+	if dLon >= 180 {
+		return nil
+	}
+
+	minLon, maxLon := center.Lon-dLon, center.Lon+dLon
+	loLat, hiLat := int32(math.Floor(minLat/geoCellDeg)), int32(math.Floor(maxLat/geoCellDeg))
+	loLon, hiLon := int32(math.Floor(minLon/geoCellDeg)), int32(math.Floor(maxLon/geoCellDeg))
+	if int64(hiLat-loLat+1)*int64(hiLon-loLon+1) > geoMaxCells {
+		return nil
+	}
+	seen := map[geoCell]bool{}
+	var cells []geoCell
+	for lat := loLat; lat <= hiLat; lat++ {
+		for lon := loLon; lon <= hiLon; lon++ {
+			c := geoCell{lat, lon}.wrap()
+			if !seen[c] {
+				seen[c] = true
+				cells = append(cells, c)
+			}
+		}
+	}
+	return cells
+}
+
+// FindNear returns the rows of the table's geo index within radius meters of
+// (lat, lon), nearest first.
+func (t *Table) FindNear(lat float64, lon float64, radius float64) ([]NearResult, error) {
+	var s *sideIndexFile
+	var x *geoIndex
+	for _, si := range t.sideIndexes {
+		if g, ok := si.index.(*geoIndex); ok {
+			s, x = si, g
+			break
+		}
+	}
+	if x == nil {
+		return nil, &FlintDBError{Message: "table has no geo index"}
+	}
+	if math.IsNaN(radius) || radius < 0 {
+		return nil, &FlintDBError{Message: fmt.Sprintf("invalid radius: %v", radius)}
+	}
+	center := geoPoint{lat, lon}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var results []NearResult
+	match := func(rowid int64, p geoPoint) {
+		if d := haversine(center, p); d <= radius {
+			results = append(results, NearResult{RowID: rowid, Distance: d})
+		}
+	}
+	if cells := x.near(center, radius); cells != nil {
+		for _, c := range cells {
+			for rowid := range x.cells[c] {
+				match(rowid, x.points[rowid])
+			}
+		}
+	} else {
+		for rowid, p := range x.points {
+			match(rowid, p)
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Distance != results[j].Distance {
+			return results[i].Distance < results[j].Distance
+		}
+		return results[i].RowID < results[j].RowID
+	})
+	return results, nil
+}
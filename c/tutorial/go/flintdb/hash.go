@@ -0,0 +1,387 @@
+package flintdb
+
+import (
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type hashDef struct {
+	Name    string   `json:"name"`
+	Columns []string `json:"columns"`
+}
+
+// addHashIndex declares an index answering only "col = value" lookups. It
+// keeps a 64-bit hash of the key per row instead of the key itself, so it is
+// smaller than a B+tree index and a lookup is a single map access.
+func (m *Meta) addHashIndex(name string, columns []string) error {
+	if err := m.checkSideIndexName(name); err != nil {
+		return err
+	}
+	if len(columns) == 0 {
+		return &FlintDBError{Message: "invalid key count for index"}
+	}
+	def := hashDef{Name: name}
+	for _, col := range columns {
+		if m.ColumnAt(col) < 0 {
+			return &FlintDBError{Message: fmt.Sprintf("column not found: %s", col)}
+		}
+		def.Columns = append(def.Columns, m.indexColumn(col))
+	}
+	m.ext.Hash = append(m.ext.Hash, def)
+	return nil
+}
+
+type hashIndex struct {
+	name    string
+	columns []int
+	names   []string
+	keys    map[int64]uint64 // rowid -> key hash
+	buckets map[uint64][]int64
+}
+
+func newHashIndex(t *Table, def hashDef) (*hashIndex, error) {
+	x := &hashIndex{
+		name:    def.Name,
+		names:   def.Columns,
+		keys:    map[int64]uint64{},
+		buckets: map[uint64][]int64{},
+	}
+	for _, col := range def.Columns {
+		idx := t.columnAt(col)
+		if idx < 0 {
+			return nil, &FlintDBError{Message: fmt.Sprintf("hash index %s: column not found: %s", def.Name, col)}
+		}
+		x.columns = append(x.columns, idx)
+	}
+	return x, nil
+}
+
+// hash returns false when a key column is NULL; such rows match no lookup.
+func (x *hashIndex) hash(row *Row) (uint64, bool, error) {
+	return hashKey(row, x.columns)
+}
+
+// hashKey hashes the typed values of columns, or returns false when one of
+// them is NULL.
+func hashKey(row *Row, columns []int) (uint64, bool, error) {
+	h := fnv.New64a()
+	var buf [9]byte
+	for _, col := range columns {
+		v, err := row.Get(col)
+		if err != nil {
+			return 0, false, err
+		}
+		switch v := v.(type) {
+		case nil:
+			return 0, false, nil
+		case int64:
+			buf[0] = 'i'
+			binary.LittleEndian.PutUint64(buf[1:], uint64(v))
+			h.Write(buf[:])
+		case float64:
+			if v == 0 {
+				v = 0 // -0 equals 0
+			}
+			buf[0] = 'f'
+			binary.LittleEndian.PutUint64(buf[1:], math.Float64bits(v))
+			h.Write(buf[:])
+		case time.Time:
+			buf[0] = 't'
+			binary.LittleEndian.PutUint64(buf[1:], uint64(v.Unix()))
+			h.Write(buf[:])
+		case string:
+			buf[0] = 's'
+			binary.LittleEndian.PutUint64(buf[1:], uint64(len(v)))
+			h.Write(buf[:])
+			h.Write([]byte(v))
+		case []byte:
+			buf[0] = 'b'
+			binary.LittleEndian.PutUint64(buf[1:], uint64(len(v)))
+			h.Write(buf[:])
+			h.Write(v)
+		default:
+			fmt.Fprintf(h, "%T:%v", v, v)
+		}
+	}
+	return h.Sum64(), true, nil
+}
+
+// equal reports whether a and b have the same key, guarding against hash
+// collisions.
+func (x *hashIndex) equal(a, b *Row) (bool, error) {
+	for _, col := range x.columns {
+		va, err := a.Get(col)
+		if err != nil {
+			return false, err
+		}
+		vb, err := b.Get(col)
+		if err != nil {
+			return false, err
+		}
+		if va == nil || vb == nil {
+			return false, nil
+		}
+		c, err := compareValues(va, vb)
+		if err != nil || c != 0 {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func (x *hashIndex) put(rowid int64, row *Row) error {
+	x.remove(rowid)
+	key, ok, err := x.hash(row)
+	if err != nil || !ok {
+		return err
+	}
+	x.insert(rowid, key)
+	return nil
+}
+
+func (x *hashIndex) insert(rowid int64, key uint64) {
+	x.keys[rowid] = key
+	x.buckets[key] = append(x.buckets[key], rowid)
+}
+
+func (x *hashIndex) remove(rowid int64) {
+	key, ok := x.keys[rowid]
+	if !ok {
+		return
+	}
+	delete(x.keys, rowid)
+	bucket := x.buckets[key]
+	for i, id := range bucket {
+		if id == rowid {
+			bucket = append(bucket[:i], bucket[i+1:]...)
+			break
+		}
+	}
+	if len(bucket) == 0 {
+		delete(x.buckets, key)
+	} else {
+		x.buckets[key] = bucket
+	}
+}
+
+func (x *hashIndex) encode(w io.Writer) error {
+	return gob.NewEncoder(w).Encode(x.keys)
+}
+
+func (x *hashIndex) decode(r io.Reader) error {
+	var keys map[int64]uint64
+	if err := gob.NewDecoder(r).Decode(&keys); err != nil {
+		return err
+	}
+	for rowid, key := range keys {
+		x.insert(rowid, key)
+	}
+	return nil
+}
+
+// equalityQuery is a find query of the form
+// "[USE INDEX(name)] WHERE col = value [AND col = value ...] [LIMIT n]".
+type equalityQuery struct {
+	index  string
+	values map[string]interface{} // lower-cased column -> literal
+	limit  int
+}
+
+func parseEqualityQuery(query string) (*equalityQuery, bool) {
+	tokens, err := tokenizeExpr(query)
+	if err != nil {
+		return nil, false
+	}
+	q := &equalityQuery{values: map[string]interface{}{}, limit: -1}
+	p := &exprParser{tokens: tokens}
+
+	if p.keyword("USE") {
+		if !p.keyword("INDEX") || p.symbol("(") == "" {
+			return nil, false
+		}
+		t := p.peek()
+		if t == nil || t.kind != tokenIdent {
+			return nil, false
+		}
+		q.index = t.text
+		p.pos++
+		p.keyword("ASC")
+		if p.symbol(")") == "" {
+			return nil, false
+		}
+	}
+	if !p.keyword("WHERE") {
+		return nil, false
+	}
+	for {
+		col := p.peek()
+		if col == nil || col.kind != tokenIdent {
+			return nil, false
+		}
+		p.pos++
+		if p.symbol("=", "==") == "" {
+			return nil, false
+		}
+		value, ok := p.literal()
+		if !ok {
+			return nil, false
+		}
+		name := strings.ToLower(col.text)
+		if _, dup := q.values[name]; dup {
+			return nil, false
+		}
+		q.values[name] = value
+		if !p.keyword("AND") {
+			break
+		}
+	}
+	if p.keyword("LIMIT") {
+		t := p.peek()
+		if t == nil || t.kind != tokenNumber {
+			return nil, false
+		}
+		n, err := strconv.Atoi(t.text)
+		if err != nil {
+			return nil, false
+		}
+		q.limit = n
+		p.pos++
+	}
+	return q, p.peek() == nil
+}
+
+// literal reads a string, number or NULL.
+func (p *exprParser) literal() (interface{}, bool) {
+	neg := p.symbol("-") != ""
+	t := p.peek()
+	if t == nil {
+		return nil, false
+	}
+	p.pos++
+	switch {
+	case t.kind == tokenString && !neg:
+		return t.text, true
+	case t.kind == tokenNumber:
+		text := t.text
+		if neg {
+			text = "-" + text
+		}
+		if i, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return i, true
+		}
+		f, err := strconv.ParseFloat(text, 64)
+		return f, err == nil
+	case t.kind == tokenIdent && !neg && strings.EqualFold(t.text, "NULL"):
+		return nil, true
+	}
+	return nil, false
+}
+
+// hashLookup returns the hash index answering query, which must be an
+// equality lookup on exactly its columns, or nil to leave the query to the
+// engine.
+func (t *Table) hashLookup(query string) (*equalityQuery, *sideIndexFile, *hashIndex, error) {
+	q, ok := parseEqualityQuery(query)
+	if !ok {
+		return nil, nil, nil, nil
+	}
+	s, x := t.hashIndexFor(q)
+	if x == nil {
+		return nil, nil, nil, nil
+	}
+	if !x.covers(q) {
+		return nil, nil, nil, &FlintDBError{Message: fmt.Sprintf("hash index %s requires = on each of %s", x.name, strings.Join(x.names, ", "))}
+	}
+	return q, s, x, nil
+}
+
+// findHashed answers query with a hash index when hashLookup finds one. It
+// reports false to leave the query to the engine.
+func (t *Table) findHashed(query string) ([]int64, bool, error) {
+	q, s, x, err := t.hashLookup(query)
+	if err != nil {
+		return nil, true, err
+	}
+	if x == nil {
+		return nil, false, nil
+	}
+
+	for _, v := range q.values {
+		if v == nil {
+			return nil, true, nil // = NULL matches nothing
+		}
+	}
+	probe, err := t.CreateRow()
+	if err != nil {
+		return nil, true, err
+	}
+	defer probe.Free()
+	for i, col := range x.columns {
+		if err := probe.Set(col, q.values[strings.ToLower(x.names[i])]); err != nil {
+			return nil, true, err
+		}
+	}
+	key, _, err := x.hash(probe)
+	if err != nil {
+		return nil, true, err
+	}
+
+	s.mu.RLock()
+	candidates := append([]int64(nil), x.buckets[key]...)
+	s.mu.RUnlock()
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i] < candidates[j] })
+
+	var rows []int64
+	for _, rowid := range candidates {
+		if q.limit >= 0 && len(rows) >= q.limit {
+			break
+		}
+		row, err := t.Read(rowid)
+		if err != nil {
+			return nil, true, err
+		}
+		match, err := x.equal(row, probe)
+		if err != nil {
+			return nil, true, err
+		}
+		if match {
+			rows = append(rows, rowid)
+		}
+	}
+	return rows, true, nil
+}
+
+// hashIndexFor returns the hash index named by q, or without USE INDEX the
+// one whose columns are exactly those compared by q.
+func (t *Table) hashIndexFor(q *equalityQuery) (*sideIndexFile, *hashIndex) {
+	for _, s := range t.sideIndexes {
+		x, ok := s.index.(*hashIndex)
+		if !ok {
+			continue
+		}
+		if q.index != "" && strings.EqualFold(q.index, x.name) || q.index == "" && x.covers(q) {
+			return s, x
+		}
+	}
+	return nil, nil
+}
+
+func (x *hashIndex) covers(q *equalityQuery) bool {
+	if len(x.names) != len(q.values) {
+		return false
+	}
+	for _, name := range x.names {
+		if _, ok := q.values[strings.ToLower(name)]; !ok {
+			return false
+		}
+	}
+	return true
+}
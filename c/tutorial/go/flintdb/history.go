@@ -0,0 +1,544 @@
+package flintdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type historyDef struct {
+	Retain string `json:"retain,omitempty"` // a time.Duration; empty keeps every version
+}
+
+const historySuffix = ".history"
+
+// SetHistory keeps the versions of rows written by Insert, UpdateAt and
+// DeleteAt, for Table.AsOf. Versions replaced more than retain ago are dropped
+// when the table is opened, 0 keeping them all. Rows already in the table
+// when its history starts count as having always been there.
+func (m *Meta) SetHistory(retain time.Duration) error {
+	if retain < 0 {
+		return &FlintDBError{Message: fmt.Sprintf("invalid history retention: %v", retain)}
+	}
+	def := &historyDef{}
+	if retain > 0 {
+		def.Retain = retain.String()
+	}
+	m.ext.History = def
+	return nil
+}
+
+// rowHistory is the append-only log of the versions of a table's rows, with
+// the versions of each row indexed in memory. A record is the time it was
+// written, the rowid and the length of the encoded row, which is 0 for a
+// delete.
+type rowHistory struct {
+	mu       sync.RWMutex
+	file     *os.File
+	size     int64
+	versions map[int64][]rowVersion // oldest first
+}
+
+type rowVersion struct {
+	at     int64 // UnixNano; 0 for rows older than the history
+	offset int64 // of the record; -1 for a delete
+}
+
+const historyHeaderSize = 20
+
+func openHistory(t *Table, def historyDef) (*rowHistory, error) {
+	var retain time.Duration
+	if def.Retain != "" {
+		var err error
+		if retain, err = time.ParseDuration(def.Retain); err != nil {
+			return nil, &FlintDBError{Message: fmt.Sprintf("invalid history retention: %s", def.Retain)}
+		}
+	}
+	h := &rowHistory{versions: map[int64][]rowVersion{}}
+	file := t.path + historySuffix
+	var err error
+	if t.mode != FLINTDB_RDWR {
+		h.file, err = os.Open(file)
+		if errors.Is(err, fs.ErrNotExist) {
+			return h, nil // rows are read as they are now
+		}
+	} else {
+		h.file, err = os.OpenFile(file, os.O_RDWR|os.O_CREATE, 0644)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := h.load(t.mode == FLINTDB_RDWR); err != nil {
+		h.file.Close()
+		return nil, err
+	}
+	if t.mode != FLINTDB_RDWR {
+		return h, nil
+	}
+
+	if h.size == 0 {
+		err = h.baseline(t)
+	} else if retain > 0 {
+		err = h.prune(file, time.Now().Add(-retain).UnixNano())
+	}
+	if err != nil {
+		h.file.Close()
+		return nil, err
+	}
+	return h, nil
+}
+
+// load indexes the records of the log, dropping a record cut short by a
+// crash when writable.
+func (h *rowHistory) load(writable bool) error {
+	st, err := h.file.Stat()
+	if err != nil {
+		return err
+	}
+	var header [historyHeaderSize]byte
+	var offset int64
+	for offset+historyHeaderSize <= st.Size() {
+		if _, err := h.file.ReadAt(header[:], offset); err != nil {
+			return err
+		}
+		at := int64(binary.LittleEndian.Uint64(header[0:]))
+		rowid := int64(binary.LittleEndian.Uint64(header[8:]))
+		n := int64(binary.LittleEndian.Uint32(header[16:]))
+		if offset+historyHeaderSize+n > st.Size() {
+			break
+		}
+		v := rowVersion{at: at, offset: offset}
+		if n == 0 {
+			v.offset = -1
+		}
+		h.versions[rowid] = append(h.versions[rowid], v)
+		offset += historyHeaderSize + n
+	}
+	h.size = offset
+	if offset < st.Size() && writable {
+		return h.file.Truncate(offset)
+	}
+	return nil
+}
+
+// baseline starts the history of a table with its current rows.
+func (h *rowHistory) baseline(t *Table) error {
+	cursor, err := t.Find("")
+	if err != nil {
+		return err
+	}
+	defer cursor.Close()
+	for {
+		rowid, err := cursor.Next()
+		if err != nil {
+			return err
+		}
+		if rowid < 0 {
+			return nil
+		}
+		row, err := t.Read(rowid)
+		if err != nil {
+			return err
+		}
+		if err := h.write(0, rowid, row); err != nil {
+			return err
+		}
+	}
+}
+
+// prune rewrites the log without the versions replaced before cutoff,
+// keeping the one current at cutoff.
+func (h *rowHistory) prune(file string, cutoff int64) error {
+	kept := map[int64][]rowVersion{}
+	dropped := false
+	for rowid, vs := range h.versions {
+		k := 0
+		for i, v := range vs {
+			if v.at <= cutoff {
+				k = i
+			}
+		}
+		// A row deleted by cutoff needs no version to read as missing
+		if vs[k].at <= cutoff && vs[k].offset < 0 {
+			k++
+		}
+		if k > 0 {
+			dropped = true
+		}
+		if k < len(vs) {
+			kept[rowid] = vs[k:]
+		}
+	}
+	if !dropped {
+		return nil
+	}
+
+	type entry struct {
+		rowid int64
+		v     rowVersion
+	}
+	var entries []entry
+	for rowid, vs := range kept {
+		for _, v := range vs {
+			entries = append(entries, entry{rowid, v})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].v.at != entries[j].v.at {
+			return entries[i].v.at < entries[j].v.at
+		}
+		return entries[i].rowid < entries[j].rowid
+	})
+
+	tmp, err := os.Create(file + ".tmp")
+	if err != nil {
+		return err
+	}
+	pruned := &rowHistory{file: tmp, versions: map[int64][]rowVersion{}}
+	for _, x := range entries {
+		var body []byte
+		if x.v.offset >= 0 {
+			if body, err = h.body(x.v.offset); err != nil {
+				break
+			}
+		}
+		if err = pruned.append(x.v.at, x.rowid, body); err != nil {
+			break
+		}
+	}
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), file); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	h.file.Close()
+	h.file, h.size, h.versions = tmp, pruned.size, pruned.versions
+	return nil
+}
+
+func (h *rowHistory) close() {
+	if h.file != nil {
+		h.file.Close()
+	}
+}
+
+// record logs the version of the row at rowid just written, or its delete
+// when row is nil.
+func (h *rowHistory) record(rowid int64, row *Row) error {
+	return h.write(time.Now().UnixNano(), rowid, row)
+}
+
+func (h *rowHistory) write(at int64, rowid int64, row *Row) error {
+	var body []byte
+	if row != nil {
+		var err error
+		if body, err = encodeHistoryRow(row); err != nil {
+			return err
+		}
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	// Later versions of a row must sort after the earlier ones
+	if vs := h.versions[rowid]; len(vs) > 0 && at < vs[len(vs)-1].at {
+		at = vs[len(vs)-1].at
+	}
+	return h.append(at, rowid, body)
+}
+
+func (h *rowHistory) append(at int64, rowid int64, body []byte) error {
+	buf := make([]byte, historyHeaderSize, historyHeaderSize+len(body))
+	binary.LittleEndian.PutUint64(buf[0:], uint64(at))
+	binary.LittleEndian.PutUint64(buf[8:], uint64(rowid))
+	binary.LittleEndian.PutUint32(buf[16:], uint32(len(body)))
+	buf = append(buf, body...)
+	if _, err := h.file.WriteAt(buf, h.size); err != nil {
+		return err
+	}
+	v := rowVersion{at: at, offset: h.size}
+	if len(body) == 0 {
+		v.offset = -1
+	}
+	h.versions[rowid] = append(h.versions[rowid], v)
+	h.size += int64(len(buf))
+	return nil
+}
+
+func (h *rowHistory) body(offset int64) ([]byte, error) {
+	var header [historyHeaderSize]byte
+	if _, err := h.file.ReadAt(header[:], offset); err != nil {
+		return nil, err
+	}
+	body := make([]byte, binary.LittleEndian.Uint32(header[16:]))
+	if _, err := h.file.ReadAt(body, offset+historyHeaderSize); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// encodeHistoryRow encodes the values of row, each as a type byte and its
+// data, tagged as hashKey tags them.
+func encodeHistoryRow(row *Row) ([]byte, error) {
+	var buf []byte
+	for i := 0; i < int(row.meta.columns.length); i++ {
+		v, err := row.Get(i)
+		if err != nil {
+			return nil, err
+		}
+		switch v := v.(type) {
+		case nil:
+			buf = append(buf, 'n')
+		case int64:
+			buf = binary.LittleEndian.AppendUint64(append(buf, 'i'), uint64(v))
+		case float64:
+			buf = binary.LittleEndian.AppendUint64(append(buf, 'f'), math.Float64bits(v))
+		case time.Time:
+			buf = binary.LittleEndian.AppendUint64(append(buf, 't'), uint64(v.Unix()))
+		case string:
+			buf = binary.LittleEndian.AppendUint32(append(buf, 's'), uint32(len(v)))
+			buf = append(buf, v...)
+		case []byte:
+			buf = binary.LittleEndian.AppendUint32(append(buf, 'b'), uint32(len(v)))
+			buf = append(buf, v...)
+		default:
+			return nil, &FlintDBError{Message: fmt.Sprintf("history: unsupported value type: %T", v)}
+		}
+	}
+	return buf, nil
+}
+
+func decodeHistoryRow(body []byte, row *Row) error {
+	for i := 0; len(body) > 0; i++ {
+		var v interface{}
+		tag := body[0]
+		body = body[1:]
+		switch tag {
+		case 'n':
+		case 'i', 'f', 't':
+			if len(body) < 8 {
+				return io.ErrUnexpectedEOF
+			}
+			bits := binary.LittleEndian.Uint64(body)
+			body = body[8:]
+			switch tag {
+			case 'i':
+				v = int64(bits)
+			case 'f':
+				v = math.Float64frombits(bits)
+			default:
+				v = time.Unix(int64(bits), 0)
+			}
+		case 's', 'b':
+			if len(body) < 4 || len(body)-4 < int(binary.LittleEndian.Uint32(body)) {
+				return io.ErrUnexpectedEOF
+			}
+			n := binary.LittleEndian.Uint32(body)
+			if tag == 's' {
+				v = string(body[4 : 4+n])
+			} else {
+				v = body[4 : 4+n]
+			}
+			body = body[4+n:]
+		default:
+			return &FlintDBError{Message: fmt.Sprintf("history: invalid value type: %q", tag)}
+		}
+		if err := row.Set(i, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TableAsOf reads the rows of a table as they were at a point in time; see
+// Table.AsOf.
+type TableAsOf struct {
+	table *Table
+	at    int64
+}
+
+// AsOf returns a view of t as it was at time at, from the history kept by
+// Meta.SetHistory. Times further back than its retention may read rows as
+// missing.
+func (t *Table) AsOf(at time.Time) *TableAsOf {
+	return &TableAsOf{table: t, at: at.UnixNano()}
+}
+
+// Read returns the row at rowid as it was, in a row of its own freed by Free
+// or when it is garbage collected.
+func (v *TableAsOf) Read(rowid int64) (*Row, error) {
+	row, err := v.read(rowid)
+	if err != nil {
+		return nil, err
+	}
+	if row == nil {
+		return nil, &FlintDBError{Message: "row not found"}
+	}
+	runtime.SetFinalizer(row, (*Row).Free)
+	return row, nil
+}
+
+// read returns nil for a row that did not exist at v.at.
+func (v *TableAsOf) read(rowid int64) (*Row, error) {
+	h := v.table.history
+	if h == nil {
+		return nil, &FlintDBError{Message: "table has no history"}
+	}
+	h.mu.RLock()
+	vs := h.versions[rowid]
+	var version *rowVersion
+	for i := range vs {
+		if vs[i].at <= v.at {
+			version = &vs[i]
+		}
+	}
+	var body []byte
+	var err error
+	if version != nil && version.offset >= 0 {
+		body, err = h.body(version.offset)
+	}
+	h.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(vs) == 0 {
+		// Not written since the history started
+		row, err := v.table.Read(rowid)
+		if err != nil {
+			return nil, nil
+		}
+		out, err := v.table.CreateRow()
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < int(v.table.meta.columns.length); i++ {
+			value, err := row.Get(i)
+			if err == nil {
+				err = out.Set(i, value)
+			}
+			if err != nil {
+				out.Free()
+				return nil, err
+			}
+		}
+		return out, nil
+	}
+	if body == nil {
+		return nil, nil
+	}
+	row, err := v.table.CreateRow()
+	if err != nil {
+		return nil, err
+	}
+	if err := decodeHistoryRow(body, row); err != nil {
+		row.Free()
+		return nil, &FlintDBError{Message: fmt.Sprintf("history of row %d: %v", rowid, err)}
+	}
+	return row, nil
+}
+
+// Find returns the rowids, in ascending order, of the rows that existed at
+// the view's time and matched query, which is an optional WHERE expression
+// as AddCheckExpr takes, followed by an optional LIMIT. Every row with a
+// history is read, so it is meant for inspection rather than hot paths.
+func (v *TableAsOf) Find(query string) (*CursorInt64, error) {
+	t := v.table
+	if t.history == nil {
+		return nil, &FlintDBError{Message: "table has no history"}
+	}
+	tokens, err := tokenizeExpr(query)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{tokens: tokens, columnAt: t.columnAt}
+	var where expr
+	if p.keyword("WHERE") {
+		if where, err = p.parseOr(); err != nil {
+			return nil, err
+		}
+	}
+	limit := -1
+	if p.keyword("LIMIT") {
+		tok := p.peek()
+		if tok == nil || tok.kind != tokenNumber {
+			return nil, p.errorf("LIMIT needs a number")
+		}
+		if limit, err = strconv.Atoi(tok.text); err != nil {
+			return nil, p.errorf("invalid LIMIT %s", tok.text)
+		}
+		p.pos++
+	}
+	if p.pos < len(p.tokens) {
+		return nil, p.errorf("unexpected %q", p.tokens[p.pos].text)
+	}
+
+	// Rows of the history, and current rows it has not seen written
+	seen := map[int64]bool{}
+	t.history.mu.RLock()
+	for rowid := range t.history.versions {
+		seen[rowid] = true
+	}
+	t.history.mu.RUnlock()
+	cursor, err := t.Find("")
+	if err != nil {
+		return nil, err
+	}
+	for {
+		rowid, err := cursor.Next()
+		if err != nil {
+			cursor.Close()
+			return nil, err
+		}
+		if rowid < 0 {
+			break
+		}
+		seen[rowid] = true
+	}
+	cursor.Close()
+	rowids := make([]int64, 0, len(seen))
+	for rowid := range seen {
+		rowids = append(rowids, rowid)
+	}
+	sort.Slice(rowids, func(i, j int) bool { return rowids[i] < rowids[j] })
+
+	var found []int64
+	for _, rowid := range rowids {
+		if limit >= 0 && len(found) >= limit {
+			break
+		}
+		row, err := v.read(rowid)
+		if err != nil {
+			return nil, err
+		}
+		if row == nil {
+			continue
+		}
+		match := true
+		if where != nil {
+			value, err := where.eval(row)
+			if err != nil {
+				row.Free()
+				return nil, err
+			}
+			match = value == true
+		}
+		row.Free()
+		if match {
+			found = append(found, rowid)
+		}
+	}
+	return &CursorInt64{rows: found}, nil
+}
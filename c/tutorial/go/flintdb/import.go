@@ -0,0 +1,371 @@
+package flintdb
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ImportOptions controls Table.Import.
+type ImportOptions struct {
+	Header    bool                    // the first CSV/TSV line names the columns; otherwise fields are in table order
+	BatchSize int                     // rows parsed before they are inserted; 0 means 1000
+	MaxErrors int                     // stop after this many bad lines; 0 means no limit
+	Progress  func(lines, rows int64) // called after each batch with the lines read and rows inserted so far
+}
+
+// ImportError reports a line Import could not parse or insert.
+type ImportError struct {
+	Line int64
+	Err  error
+}
+
+func (e *ImportError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+type importRow struct {
+	line int64
+	row  *Row
+}
+
+// Import reads rows in format from r and inserts them, reading values the way
+// Export writes them. Bad lines are skipped and returned as ImportErrors; the
+// error result is for failures that stop the import, such as a read error or
+// an unknown header column. It returns the number of rows inserted.
+func (t *Table) Import(r io.Reader, format string, opts ImportOptions) (int64, []*ImportError, error) {
+	return t.ImportContext(context.Background(), r, format, opts)
+}
+
+// ImportContext is Import with its progress, in lines and bytes read,
+// reported to the ProgressFunc of ctx; the total of bytes is known when r is
+// a file or has a Len method.
+func (t *Table) ImportContext(ctx context.Context, r io.Reader, format string, opts ImportOptions) (int64, []*ImportError, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 1000
+	}
+	im := &importer{table: t, opts: opts, all: t.exportColumns()}
+	if im.progress = progressOf(ctx, "import", t.path, -1, readerSize(r)); im.progress != nil {
+		r = progressReader{r: r, p: im.progress}
+		defer im.progress.done()
+	}
+	var err error
+	switch format {
+	case FORMAT_CSV:
+		err = im.readCSV(r)
+	case FORMAT_TSV:
+		err = im.readTSV(r)
+	case FORMAT_JSONL:
+		err = im.readJSONL(r)
+	default:
+		return 0, nil, &FlintDBError{Message: fmt.Sprintf("unsupported format: %s", format)}
+	}
+	if err == nil {
+		err = im.flush()
+	}
+	for _, b := range im.batch {
+		b.row.Free()
+	}
+	return im.rows, im.errs, err
+}
+
+type importer struct {
+	table   *Table
+	opts    ImportOptions
+	all     []exportColumn
+	columns []exportColumn // field i goes to columns[i]
+	batch   []importRow
+	lines   int64
+	rows    int64
+	errs    []*ImportError
+
+	progress *progress
+	reported int64 // lines counted by progress
+}
+
+// fail records a bad line, returning an error once MaxErrors is exceeded.
+func (im *importer) fail(line int64, err error) error {
+	im.errs = append(im.errs, &ImportError{Line: line, Err: err})
+	if im.opts.MaxErrors > 0 && len(im.errs) >= im.opts.MaxErrors {
+		return &FlintDBError{Message: fmt.Sprintf("import stopped after %d errors", len(im.errs))}
+	}
+	return nil
+}
+
+func (im *importer) column(name string) (exportColumn, bool) {
+	for _, c := range im.all {
+		if strings.EqualFold(c.name, name) {
+			return c, true
+		}
+	}
+	return exportColumn{}, false
+}
+
+// header maps fields to columns by name, or by table order without a header.
+func (im *importer) header(names []string) error {
+	if names == nil {
+		im.columns = im.all
+		return nil
+	}
+	for _, name := range names {
+		c, ok := im.column(strings.TrimSpace(name))
+		if !ok {
+			return &FlintDBError{Message: fmt.Sprintf("column not found: %s", name)}
+		}
+		im.columns = append(im.columns, c)
+	}
+	return nil
+}
+
+// add parses one line of text fields, nil meaning NULL, and queues its row.
+func (im *importer) add(line int64, fields []*string) error {
+	if len(fields) != len(im.columns) {
+		return im.fail(line, &FlintDBError{Message: fmt.Sprintf("expected %d fields, found %d", len(im.columns), len(fields))})
+	}
+	row, err := im.table.CreateRow()
+	if err != nil {
+		return err
+	}
+	for i, f := range fields {
+		var v interface{}
+		if f != nil {
+			if v, err = importText(*f, im.columns[i].kind); err != nil {
+				row.Free()
+				return im.fail(line, &FlintDBError{Message: fmt.Sprintf("%s: %v", im.columns[i].name, err)})
+			}
+		}
+		if err := row.Set(im.columns[i].index, v); err != nil {
+			row.Free()
+			return im.fail(line, &FlintDBError{Message: fmt.Sprintf("%s: %v", im.columns[i].name, err)})
+		}
+	}
+	return im.queue(line, row)
+}
+
+func (im *importer) queue(line int64, row *Row) error {
+	im.batch = append(im.batch, importRow{line: line, row: row})
+	if len(im.batch) >= im.opts.BatchSize {
+		return im.flush()
+	}
+	return nil
+}
+
+// flush inserts the queued rows and reports progress.
+func (im *importer) flush() error {
+	batch := im.batch
+	im.batch = nil
+	var stop error
+	for _, b := range batch {
+		if stop == nil {
+			if _, err := im.table.Insert(b.row); err != nil {
+				stop = im.fail(b.line, err)
+			} else {
+				im.rows++
+			}
+		}
+		b.row.Free()
+	}
+	if im.opts.Progress != nil {
+		im.opts.Progress(im.lines, im.rows)
+	}
+	im.progress.add(im.lines-im.reported, 0)
+	im.reported = im.lines
+	return stop
+}
+
+func (im *importer) readCSV(r io.Reader) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+	first := true
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		line, _ := cr.FieldPos(0)
+		if err != nil {
+			if _, ok := err.(*csv.ParseError); !ok {
+				return err
+			}
+			im.lines++
+			if err := im.fail(int64(line), err); err != nil {
+				return err
+			}
+			continue
+		}
+		im.lines++
+		if first {
+			first = false
+			var names []string
+			if im.opts.Header {
+				names = record
+			}
+			if err := im.header(names); err != nil {
+				return err
+			}
+			if im.opts.Header {
+				continue
+			}
+		}
+		fields := make([]*string, len(record))
+		for i := range record {
+			if record[i] != "" {
+				fields[i] = &record[i]
+			}
+		}
+		if err := im.add(int64(line), fields); err != nil {
+			return err
+		}
+	}
+}
+
+func (im *importer) readTSV(r io.Reader) error {
+	br := bufio.NewReader(r)
+	first := true
+	for {
+		text, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if text == "" && err == io.EOF {
+			return nil
+		}
+		im.lines++
+		text = strings.TrimSuffix(strings.TrimSuffix(text, "\n"), "\r")
+		record := strings.Split(text, "\t")
+		if first {
+			first = false
+			var names []string
+			if im.opts.Header {
+				for _, name := range record {
+					names = append(names, unescapeTSV(name))
+				}
+			}
+			if err := im.header(names); err != nil {
+				return err
+			}
+			if im.opts.Header {
+				continue
+			}
+		}
+		if text != "" {
+			fields := make([]*string, len(record))
+			for i, f := range record {
+				if f != `\N` {
+					s := unescapeTSV(f)
+					fields[i] = &s
+				}
+			}
+			if err := im.add(im.lines, fields); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+func (im *importer) readJSONL(r io.Reader) error {
+	br := bufio.NewReader(r)
+	for {
+		text, err := br.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if len(text) == 0 && err == io.EOF {
+			return nil
+		}
+		im.lines++
+		if text = bytes.TrimSpace(text); len(text) > 0 {
+			if err := im.addJSON(im.lines, text); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+// addJSON queues the row of one JSON object, matching keys to column names.
+// Missing keys are left to the column defaults.
+func (im *importer) addJSON(line int64, text []byte) error {
+	d := json.NewDecoder(bytes.NewReader(text))
+	d.UseNumber()
+	var object map[string]interface{}
+	if err := d.Decode(&object); err != nil {
+		return im.fail(line, err)
+	}
+	row, err := im.table.CreateRow()
+	if err != nil {
+		return err
+	}
+	for key, value := range object {
+		c, ok := im.column(key)
+		if !ok {
+			row.Free()
+			return im.fail(line, &FlintDBError{Message: fmt.Sprintf("column not found: %s", key)})
+		}
+		var v interface{}
+		switch x := value.(type) {
+		case nil:
+		case bool:
+			v = x
+		case json.Number:
+			v, err = importText(x.String(), c.kind)
+		case string:
+			v, err = importText(x, c.kind)
+		default:
+			err = &FlintDBError{Message: fmt.Sprintf("unsupported JSON value: %T", value)}
+		}
+		if err == nil {
+			err = row.Set(c.index, v)
+		}
+		if err != nil {
+			row.Free()
+			return im.fail(line, &FlintDBError{Message: fmt.Sprintf("%s: %v", key, err)})
+		}
+	}
+	return im.queue(line, row)
+}
+
+// importText parses a field written by exportText for a column of kind.
+// Types without a Go parser are passed as strings for the engine to cast.
+func importText(s string, kind int) (interface{}, error) {
+	switch kind {
+	case VARIANT_INT32, VARIANT_INT64:
+		return strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	case VARIANT_DOUBLE, VARIANT_FLOAT:
+		return strconv.ParseFloat(strings.TrimSpace(s), 64)
+	case VARIANT_DATE, VARIANT_TIME:
+		s = strings.TrimSpace(s)
+		for _, layout := range []string{exportTimeLayout, exportDateLayout, time.RFC3339Nano} {
+			if ts, err := time.Parse(layout, s); err == nil {
+				return ts, nil
+			}
+		}
+		return nil, &FlintDBError{Message: fmt.Sprintf("invalid time: %q", s)}
+	case VARIANT_BYTES:
+		return hex.DecodeString(s)
+	}
+	return s, nil
+}
+
+var tsvUnescaper = strings.NewReplacer(`\\`, `\`, `\t`, "\t", `\n`, "\n", `\r`, "\r")
+
+func unescapeTSV(s string) string {
+	if strings.IndexByte(s, '\\') < 0 {
+		return s
+	}
+	return tsvUnescaper.Replace(s)
+}
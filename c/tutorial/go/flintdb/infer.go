@@ -0,0 +1,221 @@
+package flintdb
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// inferDelimiters are the delimiters InferMeta recognizes, in order of
+// preference on a tie.
+const inferDelimiters = "\t,|;"
+
+// InferMeta builds a Meta for the delimited file at path from its first
+// sampleRows lines: the delimiter, whether the first line is a header, and
+// for each column the narrowest of INT64, DOUBLE, DATE, TIME and STRING that
+// fits every sampled value. Columns are nullable and strings are sized from
+// the sample. Files ending in .gz are decompressed.
+func InferMeta(path string, sampleRows int) (*Meta, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var r io.Reader = file
+	switch {
+	case strings.HasSuffix(path, ".gz"):
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	case strings.HasSuffix(path, ".zst"):
+		return nil, &FlintDBError{Message: fmt.Sprintf("cannot sample zstd file: %s", path)}
+	}
+	return InferMetaReader(r, path, sampleRows)
+}
+
+// InferMetaReader is InferMeta for a stream; name is the Meta's name.
+func InferMetaReader(r io.Reader, name string, sampleRows int) (*Meta, error) {
+	if sampleRows <= 0 {
+		sampleRows = 1000
+	}
+	br := bufio.NewReader(r)
+	first, err := br.Peek(4096)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+	if i := strings.IndexByte(string(first), '\n'); i >= 0 {
+		first = first[:i]
+	}
+	delimiter := sniffDelimiter(string(first))
+
+	cr := csv.NewReader(br)
+	cr.Comma = rune(delimiter)
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	var sample [][]string
+	for len(sample) <= sampleRows {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		sample = append(sample, record)
+	}
+	if len(sample) == 0 {
+		return nil, &FlintDBError{Message: "no rows to infer a schema from"}
+	}
+
+	header := inferHeader(sample)
+	rows := sample
+	if header {
+		rows = sample[1:]
+	} else if len(rows) > sampleRows {
+		rows = rows[:sampleRows]
+	}
+	width := len(sample[0])
+	for _, row := range rows {
+		if len(row) > width {
+			width = len(row)
+		}
+	}
+
+	m, err := NewMeta(name)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < width; i++ {
+		column := fmt.Sprintf("col%d", i+1)
+		if header && i < len(sample[0]) && strings.TrimSpace(sample[0][i]) != "" {
+			column = strings.TrimSpace(sample[0][i])
+		}
+		kind, size := inferColumn(rows, i)
+		if err := m.AddColumn(column, kind, size, 0, SPEC_NULLABLE, "", ""); err != nil {
+			m.Close()
+			return nil, err
+		}
+	}
+	switch delimiter {
+	case ',':
+		m.SetFormatCSV()
+	case '\t':
+		m.SetFormatTSV()
+	default:
+		m.SetFormatTSV()
+		m.setDelimiter(delimiter)
+	}
+	m.SetHeader(header)
+	return m, nil
+}
+
+// sniffDelimiter picks the delimiter occurring most often in line.
+func sniffDelimiter(line string) byte {
+	best, count := byte('\t'), 0
+	for i := 0; i < len(inferDelimiters); i++ {
+		if n := strings.Count(line, inferDelimiters[i:i+1]); n > count {
+			best, count = inferDelimiters[i], n
+		}
+	}
+	return best
+}
+
+// inferHeader reports whether the first sampled line names the columns: its
+// fields are distinct and not empty, and it either holds text where the
+// rows below hold another type, or every column is text.
+func inferHeader(sample [][]string) bool {
+	if len(sample) < 2 {
+		return false
+	}
+	seen := map[string]bool{}
+	for _, f := range sample[0] {
+		f = strings.ToLower(strings.TrimSpace(f))
+		if f == "" || seen[f] {
+			return false
+		}
+		seen[f] = true
+	}
+	allText := true
+	for i, f := range sample[0] {
+		kind, _ := inferColumn(sample[1:], i)
+		if kind == VARIANT_STRING {
+			continue
+		}
+		allText = false
+		if _, fits := inferFits(strings.TrimSpace(f), kind); !fits {
+			return true
+		}
+	}
+	return allText
+}
+
+// inferColumn returns the narrowest type fitting every value of column i,
+// and the size to declare for it.
+func inferColumn(rows [][]string, i int) (int, int) {
+	kinds := []int{VARIANT_INT64, VARIANT_DOUBLE, VARIANT_DATE, VARIANT_TIME}
+	longest := 0
+	for _, row := range rows {
+		if i >= len(row) {
+			continue
+		}
+		f := strings.TrimSpace(row[i])
+		if len(row[i]) > longest {
+			longest = len(row[i])
+		}
+		if f == "" || f == `\N` || strings.EqualFold(f, "NULL") {
+			continue
+		}
+		var fitting []int
+		for _, k := range kinds {
+			if _, fits := inferFits(f, k); fits {
+				fitting = append(fitting, k)
+			}
+		}
+		kinds = fitting
+	}
+	if len(kinds) > 0 {
+		switch kinds[0] {
+		case VARIANT_INT64, VARIANT_DOUBLE:
+			return kinds[0], 8
+		case VARIANT_DATE:
+			return kinds[0], 4
+		case VARIANT_TIME:
+			return kinds[0], 8
+		}
+	}
+	size := 16
+	for size < longest {
+		size *= 2
+	}
+	return VARIANT_STRING, size
+}
+
+func inferFits(s string, kind int) (interface{}, bool) {
+	switch kind {
+	case VARIANT_INT64:
+		v, err := strconv.ParseInt(s, 10, 64)
+		return v, err == nil
+	case VARIANT_DOUBLE:
+		v, err := strconv.ParseFloat(s, 64)
+		return v, err == nil
+	case VARIANT_DATE:
+		v, err := time.Parse(exportDateLayout, s)
+		return v, err == nil
+	case VARIANT_TIME:
+		for _, layout := range []string{exportTimeLayout, time.RFC3339Nano, "2006-01-02 15:04:05.0"} {
+			if v, err := time.Parse(layout, s); err == nil {
+				return v, true
+			}
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,14 @@
+//go:build flintdb_lib
+
+package flintdb
+
+// Built with -tags flintdb_lib, the package links the library make builds in
+// lib rather than compiling the engine itself; see bundle.go. CGO_LDFLAGS
+// may point elsewhere with -L.
+
+/*
+#cgo CFLAGS: -I${SRCDIR}/csrc
+#cgo LDFLAGS: -L${SRCDIR}/../../../lib -lflintdb
+#cgo windows CFLAGS: -D_POSIX_=1 -DPATH_MAX=4096
+*/
+import "C"
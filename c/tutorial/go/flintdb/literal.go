@@ -0,0 +1,70 @@
+package flintdb
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// maxLiteralBytes mirrors the fixed-size string buffer of the C filter parser.
+const maxLiteralBytes = 255
+
+// formatLiteral renders a Go value as a right-hand value for a WHERE clause.
+// Integers are quoted so the C parser reads them with parse_i64 instead of
+// strtod, which would lose precision above 2^53.
+func formatLiteral(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "NULL", nil
+	case int:
+		return "'" + strconv.FormatInt(int64(v), 10) + "'", nil
+	case int8:
+		return "'" + strconv.FormatInt(int64(v), 10) + "'", nil
+	case int16:
+		return "'" + strconv.FormatInt(int64(v), 10) + "'", nil
+	case int32:
+		return "'" + strconv.FormatInt(int64(v), 10) + "'", nil
+	case int64:
+		return "'" + strconv.FormatInt(v, 10) + "'", nil
+	case uint8:
+		return "'" + strconv.FormatUint(uint64(v), 10) + "'", nil
+	case uint16:
+		return "'" + strconv.FormatUint(uint64(v), 10) + "'", nil
+	case uint32:
+		return "'" + strconv.FormatUint(uint64(v), 10) + "'", nil
+	case uint64:
+		return "'" + strconv.FormatUint(v, 10) + "'", nil
+	case float32:
+		return formatFloatLiteral(float64(v))
+	case float64:
+		return formatFloatLiteral(v)
+	case string:
+		return quoteString(v)
+	default:
+		return "", &FlintDBError{Message: fmt.Sprintf("unsupported literal type: %T", value)}
+	}
+}
+
+func formatFloatLiteral(v float64) (string, error) {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return "", &FlintDBError{Message: fmt.Sprintf("unsupported literal value: %v", v)}
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64), nil
+}
+
+// quoteString picks a quote character the value does not contain; the C parser
+// has no escape sequences, so a value containing both quote characters cannot
+// be expressed.
+func quoteString(s string) (string, error) {
+	if len(s) > maxLiteralBytes {
+		return "", &FlintDBError{Message: "string literal too long"}
+	}
+	if !strings.Contains(s, "'") {
+		return "'" + s + "'", nil
+	}
+	if !strings.Contains(s, "\"") {
+		return "\"" + s + "\"", nil
+	}
+	return "", &FlintDBError{Message: "string literal contains both quote characters"}
+}
@@ -0,0 +1,236 @@
+package flintdb
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// LoadSource yields the records Load inserts, each the values of one row,
+// and nil after the last. A *CursorValues of Select is one; ChanSource
+// adapts a channel.
+type LoadSource interface {
+	Next() ([]interface{}, error)
+}
+
+type chanSource <-chan []interface{}
+
+// ChanSource returns a LoadSource of the records sent on ch until it is
+// closed.
+func ChanSource(ch <-chan []interface{}) LoadSource {
+	return chanSource(ch)
+}
+
+func (ch chanSource) Next() ([]interface{}, error) {
+	return <-ch, nil
+}
+
+// LoadOptions controls Load.
+type LoadOptions struct {
+	Columns   []string // the columns of the values of a record; nil means table order
+	Workers   int      // goroutines converting records; 0 means GOMAXPROCS
+	BatchSize int      // rows a worker converts before they are inserted; 0 means 1000
+	MaxErrors int      // stop after this many bad records; 0 means no limit
+}
+
+// LoadError reports a record Load could not convert or insert, and the
+// worker that converted it.
+type LoadError struct {
+	Record int64 // from 1, in the order of the source
+	Worker int
+	Err    error
+}
+
+func (e *LoadError) Error() string {
+	return fmt.Sprintf("record %d: %v", e.Record, e.Err)
+}
+
+// loadBatch is what a worker hands the writer: rows packed from records,
+// and the records it could not convert.
+type loadBatch struct {
+	worker  int
+	rows    *RowBatch
+	records []int64 // of the rows
+	errs    []*LoadError
+}
+
+type loadRecord struct {
+	n      int64
+	values []interface{}
+}
+
+// Load inserts the records of source into t and returns the number of rows
+// inserted. Records are read on one goroutine and converted to rows on
+// Workers: text is parsed for the column types as Import parses it and NULL
+// in a NOT NULL column is rejected. The rows are inserted in batches by the
+// goroutine calling Load, the only one using t, so they are stored out of
+// the order of the source. Bad records are skipped and returned as
+// LoadErrors, ordered by record; the error result is for failures that stop
+// the load, such as a source error, MaxErrors or ctx ending, which suits
+// running Load in an errgroup.
+func Load(ctx context.Context, t *Table, source LoadSource, opts LoadOptions) (int64, []*LoadError, error) {
+	if opts.Workers <= 0 {
+		opts.Workers = runtime.GOMAXPROCS(0)
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 1000
+	}
+	all := t.exportColumns()
+	columns := all
+	if opts.Columns != nil {
+		columns = nil
+		for _, name := range opts.Columns {
+			found := false
+			for _, c := range all {
+				if strings.EqualFold(c.name, name) {
+					columns, found = append(columns, c), true
+					break
+				}
+			}
+			if !found {
+				return 0, nil, &FlintDBError{Message: fmt.Sprintf("column not found: %s", name)}
+			}
+		}
+	}
+	slots := make([]int, int(t.meta.columns.length))
+	for i := range slots {
+		slots[i] = -1
+	}
+	for k, c := range columns {
+		slots[c.index] = k
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	records := make(chan loadRecord, opts.Workers*2)
+	batches := make(chan loadBatch, opts.Workers)
+	sourceErr := make(chan error, 1)
+	go func() {
+		defer close(records)
+		for n := int64(1); ; n++ {
+			values, err := source.Next()
+			if err != nil || values == nil {
+				sourceErr <- err
+				return
+			}
+			select {
+			case records <- loadRecord{n: n, values: values}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	var wg sync.WaitGroup
+	for w := 0; w < opts.Workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			t.loadWorker(ctx, w, columns, slots, records, batches, opts.BatchSize)
+		}(w)
+	}
+	go func() {
+		wg.Wait()
+		close(batches)
+	}()
+
+	var rows int64
+	var errs []*LoadError
+	var stop error
+	for b := range batches {
+		if stop != nil {
+			continue // drained for the workers to end
+		}
+		errs = append(errs, b.errs...)
+		for rest, done := b.rows, 0; rest.rows > 0; {
+			n, err := t.insertBatch(rest)
+			rows += n
+			if err == nil {
+				break
+			}
+			done += int(n)
+			errs = append(errs, &LoadError{Record: b.records[done], Worker: b.worker, Err: err})
+			done++
+			rest = b.rows.skip(done)
+		}
+		if opts.MaxErrors > 0 && len(errs) >= opts.MaxErrors {
+			stop = &FlintDBError{Message: fmt.Sprintf("load stopped after %d errors", len(errs))}
+			cancel()
+		}
+	}
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Record < errs[j].Record })
+	if stop == nil {
+		select {
+		case stop = <-sourceErr:
+		default:
+		}
+	}
+	if stop == nil {
+		stop = ctx.Err()
+	}
+	return rows, errs, stop
+}
+
+// loadWorker converts records to rows, sending them to the writer in
+// batches of size.
+func (t *Table) loadWorker(ctx context.Context, w int, columns []exportColumn, slots []int, records <-chan loadRecord, batches chan<- loadBatch, size int) {
+	b := loadBatch{worker: w, rows: &RowBatch{columns: len(slots)}}
+	send := func() bool {
+		select {
+		case batches <- b:
+			b = loadBatch{worker: w, rows: &RowBatch{columns: len(slots)}}
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	for r := range records {
+		values, err := t.loadValues(columns, r.values)
+		if err == nil {
+			err = b.rows.addSlots(slots, values)
+		}
+		if err != nil {
+			b.errs = append(b.errs, &LoadError{Record: r.n, Worker: w, Err: err})
+		} else {
+			b.records = append(b.records, r.n)
+		}
+		if b.rows.rows+len(b.errs) >= size && !send() {
+			return
+		}
+	}
+	if b.rows.rows+len(b.errs) > 0 {
+		send()
+	}
+}
+
+// loadValues returns the values of a record converted for columns, in a
+// copy if any is converted.
+func (t *Table) loadValues(columns []exportColumn, values []interface{}) ([]interface{}, error) {
+	if len(values) != len(columns) {
+		return nil, &FlintDBError{Message: fmt.Sprintf("expected %d values, found %d", len(columns), len(values))}
+	}
+	out := values
+	for i, c := range columns {
+		switch v := values[i].(type) {
+		case nil:
+			if t.meta.columns.a[c.index].nullspec == SPEC_NOT_NULL {
+				return nil, &FlintDBError{Message: fmt.Sprintf("%s: NULL in a NOT NULL column", c.name)}
+			}
+		case string:
+			if c.kind == VARIANT_STRING {
+				continue
+			}
+			converted, err := importText(v, c.kind)
+			if err != nil {
+				return nil, &FlintDBError{Message: fmt.Sprintf("%s: %v", c.name, err)}
+			}
+			if &out[0] == &values[0] {
+				out = append([]interface{}(nil), values...)
+			}
+			out[i] = converted
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,97 @@
+package flintdb
+
+/*
+#include "flintdb.h"
+*/
+import "C"
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	logger    atomic.Pointer[slog.Logger]
+	slowAfter atomic.Int64 // nanoseconds, of SetSlowQueryThreshold
+)
+
+// SetLogger sends the wrapper's events, such as opens, compactions and check
+// problems, and the engine's log lines, such as a WAL recovery rolling back
+// unfinished transactions, to l as structured records. Events carry the
+// table's path as "table"; engine lines carry the C "file", "line" and
+// "func" and are logged at Info, or Warn for warnings. nil restores the
+// default: no wrapper events, and engine lines on stdout.
+func SetLogger(l *slog.Logger) {
+	logger.Store(l)
+	logToGo(l != nil)
+}
+
+// logEvent logs msg to the logger set with SetLogger, if any.
+func logEvent(level slog.Level, msg string, args ...interface{}) {
+	if l := logger.Load(); l != nil {
+		l.Log(context.Background(), level, msg, args...)
+	}
+}
+
+// SetSlowQueryThreshold logs, at Warn, each Find that takes longer than d
+// from the find until its cursor is closed, to catch full scans. Records
+// carry the "table", "query", "duration", the "scanned" rows read and
+// filtered, the "rows" returned and the "index" walked. 0 stops it; it needs
+// a logger set with SetLogger.
+func SetSlowQueryThreshold(d time.Duration) {
+	slowAfter.Store(int64(d))
+}
+
+// slowQueryThreshold returns the threshold of SetSlowQueryThreshold, or 0
+// if there is no logger to log slow queries to.
+func slowQueryThreshold() time.Duration {
+	if logger.Load() == nil {
+		return 0
+	}
+	return time.Duration(slowAfter.Load())
+}
+
+// slowQuery is a find timed for the slow query log.
+type slowQuery struct {
+	table     *Table
+	query     string
+	start     time.Time
+	threshold time.Duration
+}
+
+// logSlow logs the cursor's find if it ran past the threshold, before the
+// engine cursor is closed.
+func (c *CursorInt64) logSlow() {
+	q := c.slow
+	c.slow = nil
+	d := time.Since(q.start)
+	if d < q.threshold {
+		return
+	}
+	scanned := c.returned
+	if c.inner != nil {
+		scanned = int64(C.flintdb_cursor_scanned(c.inner))
+	}
+	index := ""
+	if plan, err := q.table.Explain(q.query, q.table.Columns()...); err == nil {
+		index = plan.Index
+	}
+	logEvent(slog.LevelWarn, "slow query", "table", q.table.path, "query", q.query,
+		"duration", d, "scanned", scanned, "rows", c.returned, "index", index)
+}
+
+//export flintdbLog
+func flintdbLog(level, file *C.char, line C.int, fn, message *C.char) {
+	l := logger.Load()
+	if l == nil {
+		return
+	}
+	lv := slog.LevelInfo
+	if C.GoString(level) == "WARN" {
+		lv = slog.LevelWarn
+	}
+	l.Log(context.Background(), lv, strings.TrimSpace(C.GoString(message)),
+		"file", C.GoString(file), "line", int(line), "func", C.GoString(fn))
+}
@@ -0,0 +1,90 @@
+package flintdb
+
+/*
+#include "flintdb.h"
+*/
+import "C"
+import (
+	"fmt"
+	"strings"
+)
+
+// LookupByKey returns the rowid of the row with the given values of the key
+// columns of the index named indexName, one value per column, or -1. It
+// probes the index once, without a query or cursor, so it is the cheapest
+// check of a key. Of rows sharing the key in a non-unique index it returns
+// any one.
+func (t *Table) LookupByKey(indexName string, keyValues ...interface{}) (int64, error) {
+	ordinal, columns, err := t.indexKeys(indexName)
+	if err != nil {
+		return -1, err
+	}
+	if len(keyValues) != len(columns) {
+		return -1, &FlintDBError{Message: fmt.Sprintf("index %s has %d columns, got %d values", indexName, len(columns), len(keyValues))}
+	}
+
+	probe, err := t.CreateRow()
+	if err != nil {
+		return -1, err
+	}
+	defer probe.Free()
+	for k, v := range keyValues {
+		if v == nil {
+			return -1, nil
+		}
+		if err := probe.SetByName(columns[k], v); err != nil {
+			return -1, err
+		}
+	}
+	if err := t.fillCollationKeys(probe); err != nil {
+		return -1, err
+	}
+	return t.lookup(ordinal, probe)
+}
+
+// indexKeys returns the ordinal of the index named name and its key columns
+// as callers name them, mapping collation key columns back to the collated
+// column.
+func (t *Table) indexKeys(name string) (int, []string, error) {
+	for i := 0; i < int(t.meta.indexes.length); i++ {
+		index := &t.meta.indexes.a[i]
+		if !strings.EqualFold(cstring(index.name[:]), name) {
+			continue
+		}
+		columns := make([]string, int(index.keys.length))
+		for k := range columns {
+			columns[k] = cstring(index.keys.a[k][:])
+			for _, cc := range t.collated {
+				if collationKeyColumn(cc.column) == columns[k] {
+					columns[k] = cc.column
+				}
+			}
+		}
+		return i, columns, nil
+	}
+	return -1, nil, &FlintDBError{Message: fmt.Sprintf("index not found: %s", name)}
+}
+
+// lookup probes the index at ordinal for the key values row holds.
+func (t *Table) lookup(ordinal int, row *Row) (int64, error) {
+	t.use.enter("table", t.path)
+	defer t.use.leave()
+	var e *C.char
+	rowid := C.flintdb_table_lookup(t.inner, C.int(ordinal), row.inner, &e)
+	if err := checkError(e); err != nil {
+		return -1, err
+	}
+	return int64(rowid), nil
+}
+
+// ExistsWhere reports whether query, a find query, matches any row. It stops
+// at the first match.
+func (t *Table) ExistsWhere(query string) (bool, error) {
+	cursor, err := t.Find(query)
+	if err != nil {
+		return false, err
+	}
+	defer cursor.Close()
+	rowid, err := cursor.Next()
+	return rowid >= 0, err
+}
@@ -0,0 +1,321 @@
+package flintdb
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// checkDetail is the number of rowids a Check problem lists at most.
+const checkDetail = 10
+
+// Check verifies that every index of the table lists each row once and that
+// each row can be read, checksums included. It returns one line per problem
+// found; the error is for a failure that stops the check.
+func (t *Table) Check() ([]string, error) {
+	return t.CheckContext(context.Background())
+}
+
+// CheckContext is Check with its progress reported to the ProgressFunc of
+// ctx, counting a row once per index walked and once more when it is read.
+// The cancellation of ctx stops the check, which returns the problems found
+// so far and the error of ctx.
+func (t *Table) CheckContext(ctx context.Context) ([]string, error) {
+	rows, err := t.Rows()
+	if err != nil {
+		return nil, err
+	}
+	prog := progressOf(ctx, "check", t.path, rows*int64(t.meta.indexes.length+1), -1)
+	defer prog.done()
+	var problems []string
+	var primary map[int64]bool
+	for i := 0; i < int(t.meta.indexes.length); i++ {
+		name := cstring(t.meta.indexes.a[i].name[:])
+		seen, dups, err := t.indexRowids(ctx, name, prog)
+		if err != nil {
+			return problems, fmt.Errorf("index %s: %w", name, err)
+		}
+		if int64(len(seen)) != rows {
+			problems = append(problems, fmt.Sprintf("index %s lists %d rows, the table has %d", name, len(seen), rows))
+		}
+		if len(dups) > 0 {
+			problems = append(problems, fmt.Sprintf("index %s lists rows more than once: %s", name, rowidList(dups)))
+		}
+		if primary == nil {
+			primary = seen
+			continue
+		}
+		var missing, extra []int64
+		for rowid := range primary {
+			if !seen[rowid] {
+				missing = append(missing, rowid)
+			}
+		}
+		for rowid := range seen {
+			if !primary[rowid] {
+				extra = append(extra, rowid)
+			}
+		}
+		if len(missing) > 0 {
+			problems = append(problems, fmt.Sprintf("index %s misses rows: %s", name, rowidList(missing)))
+		}
+		if len(extra) > 0 {
+			problems = append(problems, fmt.Sprintf("index %s lists rows missing from %s: %s", name, PRIMARY_NAME, rowidList(extra)))
+		}
+	}
+
+	for _, p := range problems {
+		logEvent(slog.LevelWarn, "table check problem", "table", t.path, "problem", p)
+	}
+	var unreadable []string
+	for rowid := range primary {
+		if err := ctx.Err(); err != nil {
+			return append(problems, unreadable...), err
+		}
+		if _, err := t.Read(rowid); err != nil {
+			unreadable = append(unreadable, fmt.Sprintf("row %d: %v", rowid, err))
+		}
+		prog.add(1, 0)
+	}
+	if len(unreadable) > checkDetail {
+		unreadable = append(unreadable[:checkDetail], fmt.Sprintf("%d more unreadable rows", len(unreadable)-checkDetail))
+	}
+	for _, p := range unreadable {
+		logEvent(slog.LevelWarn, "table check problem", "table", t.path, "problem", p)
+	}
+	return append(problems, unreadable...), nil
+}
+
+// Ping checks cheaply that the table is usable, for health and readiness
+// checks: the handle is open, its data and index files can be opened and
+// start with their signatures, and, when it was opened FLINTDB_RDWR, that
+// they can be written. It reads no rows. The files of a table on a VFS, in
+// memory or in an fs.FS are not checked.
+func (t *Table) Ping() error {
+	if t.inner == nil {
+		return &FlintDBError{Message: "table is closed"}
+	}
+	storage := cstring(t.meta.storage[:])
+	if t.fsID != 0 || (storage != "" && !strings.EqualFold(storage, "MMAP")) {
+		return nil
+	}
+	if err := pingFile(t.path, "ITBL", t.mode); err != nil {
+		return err
+	}
+	for i := 0; i < int(t.meta.indexes.length); i++ {
+		file := fmt.Sprintf("%s.i.%s", t.path, cstring(t.meta.indexes.a[i].name[:]))
+		if err := pingFile(file, "B+T1", t.mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pingFile opens file, in mode, and checks that it starts with signature.
+func pingFile(file, signature string, mode uint32) error {
+	flag := os.O_RDONLY
+	if mode == FLINTDB_RDWR {
+		flag = os.O_RDWR
+	}
+	f, err := os.OpenFile(file, flag, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	head := make([]byte, len(signature))
+	if _, err := io.ReadFull(f, head); err != nil || string(head) != signature {
+		return &FlintDBError{Message: fmt.Sprintf("bad signature: %s", file)}
+	}
+	return nil
+}
+
+// indexRowids walks the index name and returns the rowids it lists, and the
+// ones it lists more than once.
+func (t *Table) indexRowids(ctx context.Context, name string, prog *progress) (map[int64]bool, []int64, error) {
+	cursor, err := t.Find(fmt.Sprintf("USE INDEX(%s)", name))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer cursor.Close()
+	seen := map[int64]bool{}
+	var dups []int64
+	for {
+		rowid, err := cursor.Next()
+		if err != nil {
+			return nil, nil, err
+		}
+		if rowid < 0 {
+			return seen, dups, nil
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		if seen[rowid] {
+			dups = append(dups, rowid)
+		}
+		seen[rowid] = true
+		prog.add(1, 0)
+	}
+}
+
+func rowidList(rowids []int64) string {
+	var b strings.Builder
+	for i, rowid := range rowids {
+		if i == checkDetail {
+			fmt.Fprintf(&b, " and %d more", len(rowids)-checkDetail)
+			break
+		}
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprint(&b, rowid)
+	}
+	return b.String()
+}
+
+// Compact rewrites the table at path, which must not be open, without the
+// space of deleted rows and with its indexes rebuilt. Rows are renumbered, so
+// tables keeping a history are refused. Each file of the table is replaced
+// by its rewritten copy with a rename.
+func Compact(path string) error {
+	return CompactContext(context.Background(), path)
+}
+
+// CompactContext is Compact with its span, if a Tracer is set, a child of
+// the span in ctx, and its progress reported to the ProgressFunc of ctx. The
+// cancellation of ctx while rows are copied stops the compaction and leaves
+// the table as it was; once the files are being replaced it runs to the end.
+func CompactContext(ctx context.Context, path string) error {
+	ctx, span := startSpan(ctx, "flintdb.compact", path)
+	start := time.Now()
+	err := compact(ctx, path)
+	if span != nil {
+		span.End(err)
+	}
+	if err == nil {
+		logEvent(slog.LevelInfo, "table compacted", "table", path, "duration", time.Since(start))
+	}
+	return err
+}
+
+func compact(ctx context.Context, path string) error {
+	desc, err := os.ReadFile(path + META_NAME_SUFFIX)
+	if err != nil {
+		return err
+	}
+	meta, err := parseMeta(string(desc))
+	if err != nil {
+		return err
+	}
+	defer meta.Close()
+	if meta.ext, _, err = readMetaExt(path); err != nil {
+		return err
+	}
+	if meta.ext.History != nil {
+		return &FlintDBError{Message: "cannot compact a table with history: rows are renumbered"}
+	}
+
+	dir, base := filepath.Split(path)
+	tmp := filepath.Join(dir, ".compact-"+base)
+	TableDrop(tmp)
+	if err := compactTo(ctx, path, tmp, meta); err != nil {
+		TableDrop(tmp)
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		TableDrop(tmp)
+		return err
+	}
+
+	entries, err := os.ReadDir(filepath.Clean(dir))
+	if err != nil {
+		return err
+	}
+	replaced := map[string]bool{}
+	for _, entry := range entries {
+		if name := entry.Name(); strings.HasPrefix(name, ".compact-"+base) {
+			target := filepath.Join(dir, base+strings.TrimPrefix(name, ".compact-"+base))
+			if err := os.Rename(filepath.Join(dir, name), target); err != nil {
+				return err
+			}
+			replaced[target] = true
+		}
+	}
+	// The schema file keeps the table's own name
+	if err := os.WriteFile(filepath.Join(dir, base+META_NAME_SUFFIX), desc, 0644); err != nil {
+		return err
+	}
+	// Files the copy has no counterpart of, such as the old WAL
+	for _, entry := range entries {
+		if f := filepath.Join(dir, entry.Name()); strings.HasPrefix(entry.Name(), base+".") && !replaced[f] && entry.Name() != base+lockSuffix {
+			os.Remove(f)
+		}
+	}
+	return nil
+}
+
+// compactTo copies the rows of the table at path into a new table at tmp.
+func compactTo(ctx context.Context, path, tmp string, meta *Meta) error {
+	src, err := TableOpenContext(ctx, path, FLINTDB_RDONLY, nil)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := TableOpenContext(ctx, tmp, FLINTDB_RDWR, meta)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	total, err := src.Rows()
+	if err != nil {
+		return err
+	}
+	prog := progressOf(ctx, "compact", path, total, -1)
+	defer prog.done()
+	cursor, err := src.FindContext(ctx, "")
+	if err != nil {
+		return err
+	}
+	defer cursor.Close()
+	columns := int(src.meta.columns.length)
+	for {
+		rowid, err := cursor.Next()
+		if err != nil {
+			return err
+		}
+		if rowid < 0 {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		in, err := src.Read(rowid)
+		if err != nil {
+			return fmt.Errorf("row %d: %w", rowid, err)
+		}
+		out, err := dst.CreateRow()
+		if err != nil {
+			return err
+		}
+		for i := 0; i < columns && err == nil; i++ {
+			var v interface{}
+			if v, err = in.Get(i); err == nil {
+				err = out.Set(i, v)
+			}
+		}
+		if err == nil {
+			_, err = dst.Insert(out)
+		}
+		out.Free()
+		if err != nil {
+			return fmt.Errorf("row %d: %w", rowid, err)
+		}
+		prog.add(1, 0)
+	}
+}
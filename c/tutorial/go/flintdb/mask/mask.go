@@ -0,0 +1,229 @@
+// Package mask replaces sensitive column values in copies and exports of
+// tables, so that extracts of production data can be shared with
+// developers:
+//
+//	m, err := mask.New(users, mask.Rules{
+//		"email": mask.Hash(key),
+//		"card":  mask.Redact(4),
+//		"name":  mask.Fake(key),
+//	})
+//	n, err := flintdb.CopyTable(users, "dev/users.flintdb", m.Transform)
+//	err = users.ExportContext(flintdb.ContextWithExportTransform(ctx, m.Values), w, flintdb.FORMAT_CSV, "", true)
+//
+// Hash and Fake are keyed and deterministic: a value masks to the same
+// result in every table masked with one key, so joins on masked columns
+// still match, and without the key a guess of the original cannot be
+// checked.
+package mask
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	flintdb "flintdb-tutorial/flintdb"
+	"flintdb-tutorial/flintdb/seed"
+)
+
+// A Strategy masks v, a value of column c as Row.Get returns it. NULL is
+// never passed and stays NULL.
+type Strategy interface {
+	Mask(c flintdb.Column, v interface{}) (interface{}, error)
+}
+
+// StrategyFunc adapts a function to a Strategy.
+type StrategyFunc func(c flintdb.Column, v interface{}) (interface{}, error)
+
+func (f StrategyFunc) Mask(c flintdb.Column, v interface{}) (interface{}, error) {
+	return f(c, v)
+}
+
+// Rules maps column names, matched ignoring case, to the strategies masking
+// them.
+type Rules map[string]Strategy
+
+// Masker applies Rules to the rows of one table.
+type Masker struct {
+	columns    []flintdb.Column
+	strategies []Strategy
+}
+
+// New returns a Masker applying rules to the rows of t.
+func New(t *flintdb.Table, rules Rules) (*Masker, error) {
+	names := make([]string, 0, len(rules))
+	for name := range rules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	types := t.ColumnTypes()
+	m := &Masker{}
+	for _, name := range names {
+		found := false
+		for _, c := range types {
+			if strings.EqualFold(c.Name, name) {
+				m.columns = append(m.columns, c)
+				m.strategies = append(m.strategies, rules[name])
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("column not found: %s", name)
+		}
+	}
+	return m, nil
+}
+
+// Transform masks row in place, for flintdb.CopyTable.
+func (m *Masker) Transform(row *flintdb.Row) (*flintdb.Row, bool, error) {
+	for i, c := range m.columns {
+		v, err := row.GetByName(c.Name)
+		if err == nil && v != nil {
+			if v, err = m.strategies[i].Mask(c, v); err == nil {
+				err = row.SetByName(c.Name, v)
+			}
+		}
+		if err != nil {
+			return nil, false, fmt.Errorf("%s: %w", c.Name, err)
+		}
+	}
+	return row, true, nil
+}
+
+// Values masks values, named by columns, in place; it is a
+// flintdb.ExportTransform.
+func (m *Masker) Values(columns []string, values []interface{}) error {
+	for i, c := range m.columns {
+		for k, name := range columns {
+			if !strings.EqualFold(name, c.Name) || values[k] == nil {
+				continue
+			}
+			v, err := m.strategies[i].Mask(c, values[k])
+			if err != nil {
+				return fmt.Errorf("%s: %w", c.Name, err)
+			}
+			values[k] = v
+		}
+	}
+	return nil
+}
+
+// Hash replaces a value with an HMAC-SHA256 of it under key: hex text cut
+// to the size of a STRING column, the digest cut or repeated to the length
+// of a BYTES value, and a non-negative number of an integer column. Text cut
+// short makes collisions likelier; keep a STRING column hashed for joins at
+// 16 bytes or more. DOUBLE, DATE and TIME columns are refused.
+func Hash(key []byte) Strategy {
+	return StrategyFunc(func(c flintdb.Column, v interface{}) (interface{}, error) {
+		d := digest(key, v)
+		switch c.Type {
+		case flintdb.VARIANT_STRING:
+			s := hex.EncodeToString(d)
+			if c.Size > 0 && c.Size < len(s) {
+				s = s[:c.Size]
+			}
+			return s, nil
+		case flintdb.VARIANT_BYTES:
+			b, _ := v.([]byte)
+			out := make([]byte, len(b))
+			for i := range out {
+				out[i] = d[i%len(d)]
+			}
+			return out, nil
+		case flintdb.VARIANT_INT32:
+			return int64(binary.BigEndian.Uint32(d) & math.MaxInt32), nil
+		case flintdb.VARIANT_INT64:
+			return int64(binary.BigEndian.Uint64(d) & math.MaxInt64), nil
+		}
+		return nil, fmt.Errorf("hash cannot mask a %s column", typeName(c.Type))
+	})
+}
+
+// Redact replaces all but the last keep characters of a string with '*', as
+// card numbers are shown, and all but the last keep bytes of BYTES with
+// zeros. Other columns are refused.
+func Redact(keep int) Strategy {
+	return StrategyFunc(func(c flintdb.Column, v interface{}) (interface{}, error) {
+		switch v := v.(type) {
+		case string:
+			r := []rune(v)
+			for i := 0; i < len(r)-keep; i++ {
+				r[i] = '*'
+			}
+			return string(r), nil
+		case []byte:
+			out := append([]byte(nil), v...)
+			for i := 0; i < len(out)-keep; i++ {
+				out[i] = 0
+			}
+			return out, nil
+		}
+		return nil, fmt.Errorf("redact cannot mask a %s column", typeName(c.Type))
+	})
+}
+
+// Null replaces a value with NULL. NOT NULL columns are refused.
+func Null() Strategy {
+	return StrategyFunc(func(c flintdb.Column, v interface{}) (interface{}, error) {
+		if c.NotNull {
+			return nil, fmt.Errorf("NULL in a NOT NULL column")
+		}
+		return nil, nil
+	})
+}
+
+// Fake replaces a value with a made-up one of the column's type, drawn as
+// seed.Rows draws them: a name for a name column, an address for an email
+// column and so on. The draw is seeded with an HMAC of the value under key,
+// so equal values get equal fakes. Fakes repeat across different values;
+// use Hash for columns that must stay unique.
+func Fake(key []byte) Strategy {
+	return StrategyFunc(func(c flintdb.Column, v interface{}) (interface{}, error) {
+		d := digest(key, v)
+		rng := rand.New(rand.NewSource(int64(binary.BigEndian.Uint64(d))))
+		return seed.Value(rng, c), nil
+	})
+}
+
+// digest returns the HMAC-SHA256 of v under key, v written as text, so a
+// number and the same number kept in a STRING column hash alike.
+func digest(key []byte, v interface{}) []byte {
+	var b []byte
+	switch v := v.(type) {
+	case string:
+		b = []byte(v)
+	case []byte:
+		b = v
+	case int64:
+		b = strconv.AppendInt(nil, v, 10)
+	case float64:
+		b = strconv.AppendFloat(nil, v, 'g', -1, 64)
+	case time.Time:
+		b = strconv.AppendInt(nil, v.Unix(), 10)
+	default:
+		b = fmt.Append(nil, v)
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(b)
+	return mac.Sum(nil)
+}
+
+func typeName(kind int) string {
+	switch kind {
+	case flintdb.VARIANT_DOUBLE, flintdb.VARIANT_FLOAT:
+		return "DOUBLE"
+	case flintdb.VARIANT_DATE:
+		return "DATE"
+	case flintdb.VARIANT_TIME:
+		return "TIME"
+	}
+	return "type " + strconv.Itoa(kind)
+}
@@ -0,0 +1,69 @@
+package flintdb
+
+import "fmt"
+
+// Conflict policies for Table.Merge: what to do with a row whose primary key
+// the table already holds.
+const (
+	ON_CONFLICT_ERROR  = 0 // stop with ErrConflict
+	ON_CONFLICT_UPDATE = 1 // replace the stored row
+	ON_CONFLICT_SKIP   = 2 // keep the stored row
+)
+
+// ErrConflict is returned by Merge with ON_CONFLICT_ERROR for a row whose
+// primary key the table already holds.
+var ErrConflict = &FlintDBError{Message: "primary key already exists"}
+
+// MergeResult counts the rows Merge wrote.
+type MergeResult struct {
+	Inserted int64
+	Updated  int64
+	Skipped  int64
+}
+
+// Merge writes rows keyed by their primary key: a row with a new key is
+// inserted, and one with a key the table holds is handled by onConflict.
+// The first error stops the merge; the result counts the rows before it,
+// so rows[Inserted+Updated+Skipped] is the row that failed. The rows stay
+// the caller's to free.
+func (t *Table) Merge(rows []*Row, onConflict int) (MergeResult, error) {
+	var result MergeResult
+	switch onConflict {
+	case ON_CONFLICT_ERROR, ON_CONFLICT_UPDATE, ON_CONFLICT_SKIP:
+	default:
+		return result, &FlintDBError{Message: fmt.Sprintf("unknown conflict policy: %d", onConflict)}
+	}
+	if int(t.meta.indexes.length) == 0 {
+		return result, &FlintDBError{Message: "table has no primary index"}
+	}
+	for _, row := range rows {
+		// Key columns may be computed or collation keys
+		if err := t.computeColumns(row); err != nil {
+			return result, err
+		}
+		if err := t.fillCollationKeys(row); err != nil {
+			return result, err
+		}
+		rowid, err := t.lookup(0, row)
+		if err != nil {
+			return result, err
+		}
+		switch {
+		case rowid < 0:
+			if _, err := t.Insert(row); err != nil {
+				return result, err
+			}
+			result.Inserted++
+		case onConflict == ON_CONFLICT_UPDATE:
+			if err := t.UpdateAt(rowid, row); err != nil {
+				return result, err
+			}
+			result.Updated++
+		case onConflict == ON_CONFLICT_SKIP:
+			result.Skipped++
+		default:
+			return result, ErrConflict
+		}
+	}
+	return result, nil
+}
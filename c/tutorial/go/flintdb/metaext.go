@@ -0,0 +1,70 @@
+package flintdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+// metaExt holds schema settings implemented by this package rather than the
+// C engine. It is stored as JSON next to the table's .desc file, under a name
+// sharing the table's basename so TableDrop removes it with the rest.
+type metaExt struct {
+	FullText []fullTextDef `json:"fulltext,omitempty"`
+	Geo      []geoDef      `json:"geo,omitempty"`
+	Hash     []hashDef     `json:"hash,omitempty"`
+	Bloom    *bloomDef     `json:"bloom,omitempty"`
+	History  *historyDef   `json:"history,omitempty"`
+}
+
+const metaExtSuffix = ".ext.json"
+
+func (x *metaExt) empty() bool {
+	return len(x.FullText) == 0 && len(x.Geo) == 0 && len(x.Hash) == 0 && x.Bloom == nil && x.History == nil
+}
+
+func readMetaExt(path string) (metaExt, bool, error) {
+	var x metaExt
+	data, err := os.ReadFile(path + metaExtSuffix)
+	if errors.Is(err, fs.ErrNotExist) {
+		return x, false, nil
+	}
+	if err != nil {
+		return x, false, err
+	}
+	if err := json.Unmarshal(data, &x); err != nil {
+		return x, false, &FlintDBError{Message: fmt.Sprintf("invalid %s%s: %v", path, metaExtSuffix, err)}
+	}
+	return x, true, nil
+}
+
+// syncMetaExt reconciles the settings of a Meta passed to TableOpen with the
+// ones stored for the table: they are written for a new table and must match
+// for an existing one, like the .desc file itself.
+func syncMetaExt(path string, x metaExt, writable bool) error {
+	stored, found, err := readMetaExt(path)
+	if err != nil {
+		return err
+	}
+	want, err := json.MarshalIndent(x, "", "  ")
+	if err != nil {
+		return err
+	}
+	if found {
+		have, err := json.MarshalIndent(stored, "", "  ")
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(have, want) {
+			return &FlintDBError{Message: fmt.Sprintf("meta mismatch: %s%s differs", path, metaExtSuffix)}
+		}
+		return nil
+	}
+	if x.empty() || !writable {
+		return nil
+	}
+	return os.WriteFile(path+metaExtSuffix, append(want, '\n'), 0644)
+}
@@ -0,0 +1,66 @@
+package flintdb
+
+/*
+#include "flintdb.h"
+*/
+import "C"
+import "time"
+
+// Metrics receives measurements of the tables opened WithMetrics, labelled
+// with the table's path. Methods are called from the goroutine using the
+// table, so they should only update counters and histograms; an adapter to
+// Prometheus or another metrics library implements it.
+type Metrics interface {
+	// Operation records one insert, update, delete, read or find of table,
+	// how long it took and its error. A find is timed until the cursor is
+	// ready; its rows are reported by Scanned.
+	Operation(table, op string, d time.Duration, err error)
+	// Scanned records the rows a cursor of table returned, when it is closed.
+	Scanned(table string, rows int64)
+	// EngineCalls records cgo calls into the engine made for table: one per
+	// operation and one per cursor step.
+	EngineCalls(table string, calls int64)
+	// Cache records the row cache hits and misses of table since the last
+	// report. Reads of a WithReadOnlyMmap table bypass the cache.
+	Cache(table string, hits, misses int64)
+	// Handles records a handle of table opened, 1, or closed, -1.
+	Handles(table string, delta int)
+}
+
+// WithMetrics reports the operations on the table to m.
+func WithMetrics(m Metrics) OpenOption {
+	return func(o *openOptions) {
+		o.metrics = m
+	}
+}
+
+// observe reports an operation started at start, for a deferred call with
+// the address of the method's error.
+func (t *Table) observe(op string, start time.Time, err *error) {
+	t.metrics.Operation(t.path, op, time.Since(start), *err)
+	t.metrics.EngineCalls(t.path, 1)
+	if op == "read" && !t.mapped {
+		t.reportCache()
+	}
+}
+
+// reportCache reports the row cache hits and misses since the last report.
+func (t *Table) reportCache() {
+	var hits, misses C.i64
+	C.flintdb_table_cache_stats(t.inner, &hits, &misses)
+	h, m := int64(hits)-t.cacheSeen[0], int64(misses)-t.cacheSeen[1]
+	if h != 0 || m != 0 {
+		t.cacheSeen = [2]int64{int64(hits), int64(misses)}
+		t.metrics.Cache(t.path, h, m)
+	}
+}
+
+// report reports the rows of a cursor being closed.
+func (c *CursorInt64) report() {
+	t := c.table
+	c.table = nil
+	t.metrics.Scanned(t.path, c.returned)
+	if c.steps > 0 {
+		t.metrics.EngineCalls(t.path, c.steps)
+	}
+}
@@ -0,0 +1,25 @@
+//go:build !flintdb_debug
+
+package flintdb
+
+// Without -tags flintdb_debug handles are not tracked, nor checked for use
+// from several goroutines; see debug.go.
+
+func trackHandle(kind string, h, owner interface{}) {}
+
+func releaseHandle(h interface{}) {}
+
+func reportLeaks() {}
+
+type handleUse struct{}
+
+func (*handleUse) enter(kind, name string) {}
+
+func (*handleUse) leave() {}
+
+// Leaks describes the tables, files, cursors and rows not yet closed or
+// freed, each with the stack that created it. It is empty unless built with
+// -tags flintdb_debug.
+func Leaks() []string {
+	return nil
+}
@@ -0,0 +1,235 @@
+package flintdb
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// WithOrderBy returns the rows of a find in the order of keys, each a column
+// name optionally followed by ASC or DESC as in ORDER BY. When the leading
+// keys of an index are the columns, all in one direction, the find walks
+// that index; otherwise the rows found are sorted in a temporary file.
+// Either way a LIMIT of the query applies to the ordered rows. A USE INDEX
+// hint naming an index that does not serve the order forces the sort.
+func WithOrderBy(keys ...string) QueryOption {
+	return func(o *queryOptions) {
+		o.orderBy = keys
+	}
+}
+
+// orderKey is a key of WithOrderBy resolved to the column sorted on, the
+// collation key column of a collated column.
+type orderKey struct {
+	column string
+	index  int
+	desc   bool
+}
+
+func (t *Table) orderKeys(keys []string) ([]orderKey, error) {
+	order := make([]orderKey, 0, len(keys))
+	for _, key := range keys {
+		name, desc, err := parseSortKey(key)
+		if err != nil {
+			return nil, err
+		}
+		i := t.columnAt(name)
+		if i < 0 {
+			return nil, &FlintDBError{Message: fmt.Sprintf("sort key column not found: %s", name)}
+		}
+		k := orderKey{column: cstring(t.meta.columns.a[i].name[:]), index: i, desc: desc}
+		if cc := t.collatedColumn(k.column); cc != nil {
+			k.column, k.index = collationKeyColumn(k.column), cc.keyIndex
+		}
+		order = append(order, k)
+	}
+	return order, nil
+}
+
+// findOrdered is find with WithOrderBy or WithDistinct.
+func (t *Table) findOrdered(query string, o queryOptions) (*CursorInt64, error) {
+	order, err := t.distinctOrder(o.orderBy, o.distinct)
+	if err != nil {
+		return nil, err
+	}
+	distinct := len(o.distinct) > 0
+	o.orderBy, o.distinct = nil, nil
+
+	var deadline time.Time
+	if o.timeout > 0 {
+		deadline = time.Now().Add(o.timeout)
+	}
+	var rows []int64
+	if indexed, ok := t.orderIndex(query, order); !ok {
+		rows, err = t.sortRows(query, order, distinct, o.timeout)
+	} else if !distinct {
+		return t.find(indexed, o)
+	} else {
+		rows, err = t.distinctRows(indexed, order, o.timeout)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c := &CursorInt64{rows: rows, deadline: deadline, maxRows: o.maxRows, truncate: o.truncate}
+	if c.expired() {
+		return nil, ErrTimeout
+	}
+	return c, nil
+}
+
+// orderIndex rewrites query to walk the index whose leading keys are order,
+// reporting false when there is none or the query names another index.
+func (t *Table) orderIndex(query string, order []orderKey) (string, bool) {
+	for _, k := range order[1:] {
+		if k.desc != order[0].desc {
+			return "", false
+		}
+	}
+	tokens, err := tokenizeExpr(query)
+	if err != nil {
+		return "", false
+	}
+	hint, rest := "", query
+	p := &exprParser{tokens: tokens}
+	if p.keyword("USE") {
+		if !p.keyword("INDEX") || p.symbol("(") == "" || p.peek() == nil || p.peek().kind != tokenIdent {
+			return "", false
+		}
+		hint = p.peek().text
+		p.pos++
+		if !p.keyword("ASC") {
+			p.keyword("DESC")
+		}
+		if p.symbol(")") == "" {
+			return "", false
+		}
+		rest = ""
+		if p.pos < len(tokens) {
+			rest = query[tokens[p.pos].pos:]
+		}
+	}
+
+	for i := 0; i < int(t.meta.indexes.length); i++ {
+		index := &t.meta.indexes.a[i]
+		name := cstring(index.name[:])
+		if hint != "" && !strings.EqualFold(name, hint) || int(index.keys.length) < len(order) {
+			continue
+		}
+		served := true
+		for k, key := range order {
+			if !strings.EqualFold(cstring(index.keys.a[k][:]), key.column) {
+				served = false
+				break
+			}
+		}
+		if !served {
+			continue
+		}
+		direction := ""
+		if order[0].desc {
+			direction = " DESC"
+		}
+		return strings.TrimSpace("USE INDEX(" + name + direction + ") " + rest), true
+	}
+	return "", false
+}
+
+// sortRows returns the rowids query finds sorted by order, with the query's
+// LIMIT applied after the sort. With distinct it keeps the first row of each
+// run of equal keys.
+func (t *Table) sortRows(query string, order []orderKey, distinct bool, timeout time.Duration) ([]int64, error) {
+	query, offset, limit := splitLimit(query)
+
+	// The sorted rows hold the keys and the rowid only
+	meta, err := NewMeta("")
+	if err != nil {
+		return nil, err
+	}
+	defer meta.Close()
+	keys := make([]string, len(order))
+	for i, k := range order {
+		column := &t.meta.columns.a[k.index]
+		keys[i] = fmt.Sprintf("k%d", i)
+		if err := meta.AddColumn(keys[i], int(column._type), int(column.bytes), int(column.precision), SPEC_NULLABLE, "", ""); err != nil {
+			return nil, err
+		}
+		if k.desc {
+			keys[i] += " DESC"
+		}
+	}
+	if err := meta.AddColumn("rowid", VARIANT_INT64, 0, 0, SPEC_NOT_NULL, "0", ""); err != nil {
+		return nil, err
+	}
+	sorter, err := NewFilesort("", meta, keys...)
+	if err != nil {
+		return nil, err
+	}
+	defer sorter.Close()
+	sorter.SetUnique(distinct)
+	if limit >= 0 {
+		sorter.SetTopN(int64(offset + limit))
+	}
+
+	cursor, err := t.find(query, queryOptions{timeout: timeout})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close()
+	for {
+		rowid, err := cursor.Next()
+		if err != nil {
+			return nil, err
+		}
+		if rowid < 0 {
+			break
+		}
+		if err := t.addSorted(sorter, rowid, order); err != nil {
+			return nil, err
+		}
+	}
+	if err := sorter.Sort(); err != nil {
+		return nil, err
+	}
+
+	var rows []int64
+	for i := int64(offset); i < sorter.Rows(); i++ {
+		row, err := sorter.Read(i)
+		if err != nil {
+			return nil, err
+		}
+		rowid, err := row.GetInt64(len(order))
+		row.Free()
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, rowid)
+	}
+	return rows, nil
+}
+
+// addSorted adds the keys of the row at rowid to sorter.
+func (t *Table) addSorted(sorter *Filesort, rowid int64, order []orderKey) error {
+	row, err := t.Read(rowid)
+	if err != nil {
+		return err
+	}
+	defer row.Free()
+	out, err := sorter.CreateRow()
+	if err != nil {
+		return err
+	}
+	defer out.Free()
+	for i, k := range order {
+		v, err := row.Get(k.index)
+		if err == nil {
+			err = out.Set(i, v)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := out.SetInt64(len(order), rowid); err != nil {
+		return err
+	}
+	return sorter.Add(out)
+}
@@ -0,0 +1,166 @@
+package flintdb
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"strings"
+)
+
+// Page is a page of the rows FindPage found, in primary key order.
+type Page struct {
+	Rows []*Row // freed by the caller
+	// Next is the token of the page after this one, or "" if this is the
+	// last.
+	Next string
+}
+
+// pageToken is what a page token encodes: the primary key of the last row
+// of the page, as the engine prints each value, and a hash of the query it
+// pages through.
+type pageToken struct {
+	Query uint32   `json:"q"`
+	Key   []string `json:"k"`
+}
+
+// FindPage returns up to pageSize rows query finds, starting after the page
+// token names, or at the first row for token "". Pages follow the primary
+// key: each starts with a range on the key past the last row of the page
+// before, so deep pages cost no more than the first and rows inserted or
+// deleted meanwhile neither repeat nor shift others. query is a WHERE clause
+// or ""; USE INDEX, ORDER BY and LIMIT would break the key order. A token
+// only continues the query it came from.
+func (t *Table) FindPage(query string, pageSize int, token string) (*Page, error) {
+	if pageSize <= 0 {
+		return nil, &FlintDBError{Message: fmt.Sprintf("page size must be positive, got %d", pageSize)}
+	}
+	tokens, err := tokenizeExpr(query)
+	if err != nil {
+		return nil, err
+	}
+	for i, tok := range tokens {
+		if i == 0 && tok.kind == tokenIdent && strings.EqualFold(tok.text, "WHERE") {
+			continue
+		}
+		if i == 0 || tok.kind == tokenIdent && (strings.EqualFold(tok.text, "LIMIT") || strings.EqualFold(tok.text, "ORDER") || strings.EqualFold(tok.text, "USE")) {
+			return nil, &FlintDBError{Message: fmt.Sprintf("FindPage takes a WHERE clause only: %s", query)}
+		}
+	}
+	if int(t.meta.indexes.length) == 0 {
+		return nil, &FlintDBError{Message: "table has no primary index"}
+	}
+	primary := &t.meta.indexes.a[0]
+	keys := make([]string, int(primary.keys.length))
+	for k := range keys {
+		keys[k] = cstring(primary.keys.a[k][:])
+	}
+	h := fnv.New32a()
+	h.Write([]byte(query))
+
+	var conds []string
+	if token != "" {
+		var pt pageToken
+		b, err := base64.RawURLEncoding.DecodeString(token)
+		if err == nil {
+			err = json.Unmarshal(b, &pt)
+		}
+		if err != nil || len(pt.Key) != len(keys) {
+			return nil, &FlintDBError{Message: "invalid page token"}
+		}
+		if pt.Query != h.Sum32() {
+			return nil, &FlintDBError{Message: "page token is of another query"}
+		}
+		after, err := keysetAfter(keys, pt.Key)
+		if err != nil {
+			return nil, err
+		}
+		if len(keys) > 1 {
+			// A range on the first key column the engine can walk the index by
+			first, _ := quoteString(pt.Key[0])
+			conds = append(conds, keys[0]+" >= "+first)
+		}
+		conds = append(conds, after)
+	}
+	if len(tokens) > 1 {
+		where := query[tokens[1].pos:]
+		for _, tok := range tokens {
+			if tok.kind == tokenIdent && strings.EqualFold(tok.text, "OR") {
+				where = "(" + where + ")"
+				break
+			}
+		}
+		conds = append(conds, where)
+	}
+	q := fmt.Sprintf("LIMIT %d", pageSize+1)
+	if len(conds) > 0 {
+		q = "WHERE " + strings.Join(conds, " AND ") + " " + q
+	}
+
+	page := &Page{}
+	cursor, err := t.Find(q)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close()
+	for {
+		rowid, err := cursor.Next()
+		if err != nil {
+			page.free()
+			return nil, err
+		}
+		if rowid < 0 {
+			break
+		}
+		if len(page.Rows) == pageSize {
+			if page.Next, err = t.pageToken(h.Sum32(), keys, page.Rows[pageSize-1]); err != nil {
+				page.free()
+				return nil, err
+			}
+			break
+		}
+		row, err := t.Read(rowid)
+		if err != nil {
+			page.free()
+			return nil, err
+		}
+		page.Rows = append(page.Rows, row)
+	}
+	return page, nil
+}
+
+// keysetAfter returns the condition on the key columns keys for the rows
+// after the key values.
+func keysetAfter(keys, values []string) (string, error) {
+	literals := make([]string, len(values))
+	for i, v := range values {
+		var err error
+		if literals[i], err = quoteString(v); err != nil {
+			return "", err
+		}
+	}
+	return tupleCondition(keys, literals, ">"), nil
+}
+
+func (t *Table) pageToken(query uint32, keys []string, row *Row) (string, error) {
+	pt := pageToken{Query: query, Key: make([]string, len(keys))}
+	for i, k := range keys {
+		s, err := row.GetString(t.columnAt(k))
+		if err != nil {
+			return "", err
+		}
+		pt.Key[i] = s
+	}
+	b, err := json.Marshal(pt)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func (p *Page) free() {
+	for _, row := range p.Rows {
+		row.Free()
+	}
+	p.Rows = nil
+}
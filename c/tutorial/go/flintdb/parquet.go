@@ -0,0 +1,129 @@
+package flintdb
+
+import "fmt"
+
+// Parquet files are read and written through GenericFile by the engine's
+// parquet plugin (libflintdb_parquet, built with Apache Arrow); opening one
+// fails without it.
+
+// parquetType returns the column type and size a column of kind is written
+// to Parquet with: integers as INT64, floats as DOUBLE (Parquet DOUBLE),
+// strings as STRING (UTF8) and bytes as BYTES (BINARY). DATE, TIME and
+// DECIMAL, which the plugin does not write, become text in Export's layouts,
+// and Import and CopyRows parse them back.
+func parquetType(kind, size int) (int, int) {
+	switch kind {
+	case VARIANT_INT32, VARIANT_INT64:
+		return VARIANT_INT64, 8
+	case VARIANT_FLOAT, VARIANT_DOUBLE:
+		return VARIANT_DOUBLE, 8
+	case VARIANT_STRING, VARIANT_BYTES:
+		return kind, size
+	case VARIANT_DATE, VARIANT_TIME:
+		return VARIANT_STRING, len(exportTimeLayout)
+	}
+	if size < 64 {
+		size = 64
+	}
+	return VARIANT_STRING, size
+}
+
+// ExportParquet writes the rows matching query to a new Parquet file at path
+// and returns how many were written.
+func (t *Table) ExportParquet(path, query string) (int64, error) {
+	meta, err := t.FileMeta(path)
+	if err != nil {
+		return 0, err
+	}
+	defer meta.Close()
+	f, err := GenericFileOpen(path, FLINTDB_RDWR, meta)
+	if err != nil {
+		return 0, err
+	}
+	n, err := t.ExportTo(f, query)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return n, err
+}
+
+// ImportParquet inserts the rows of the Parquet file at path, matching its
+// columns to the table's by name as CopyRows does, and returns how many were
+// inserted.
+func (t *Table) ImportParquet(path string) (int64, error) {
+	f, err := GenericFileOpen(path, FLINTDB_RDONLY, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return CopyRows(t, f, "")
+}
+
+// ExportParquet writes the rows of f matching query to a new Parquet file at
+// path and returns how many were written.
+func (f *GenericFile) ExportParquet(path, query string) (int64, error) {
+	meta, err := NewMeta(path)
+	if err != nil {
+		return 0, err
+	}
+	defer meta.Close()
+	columns := &f.meta.columns
+	for i := 0; i < int(columns.length); i++ {
+		col := &columns.a[i]
+		kind, size := parquetType(int(col._type), int(col.bytes))
+		if err := meta.AddColumn(cstring(col.name[:]), kind, size, int(col.precision), SPEC_NULLABLE, "", ""); err != nil {
+			return 0, err
+		}
+	}
+	dst, err := GenericFileOpen(path, FLINTDB_RDWR, meta)
+	if err != nil {
+		return 0, err
+	}
+	n, err := f.exportRows(dst, query)
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	return n, err
+}
+
+// exportRows writes the rows of f matching query to dst, which has the same
+// columns in order.
+func (f *GenericFile) exportRows(dst *GenericFile, query string) (int64, error) {
+	cursor, err := f.Find(query)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close()
+	out, err := dst.CreateRow()
+	if err != nil {
+		return 0, err
+	}
+	defer out.Free()
+
+	var written int64
+	for {
+		in, err := cursor.Next()
+		if err != nil {
+			return written, err
+		}
+		if in == nil {
+			return written, nil
+		}
+		for i := 0; i < int(f.meta.columns.length); i++ {
+			v, err := in.Get(i)
+			if err == nil {
+				v, err = coerceValue(v, int(f.meta.columns.a[i]._type), int(dst.meta.columns.a[i]._type))
+			}
+			if err == nil {
+				err = out.Set(i, v)
+			}
+			if err != nil {
+				return written, &FlintDBError{Message: fmt.Sprintf("row %d: %s: %v", written+1, cstring(f.meta.columns.a[i].name[:]), err)}
+			}
+		}
+		if err := dst.Write(out); err != nil {
+			return written, err
+		}
+		written++
+	}
+}
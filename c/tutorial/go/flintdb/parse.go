@@ -0,0 +1,69 @@
+package flintdb
+
+/*
+#include "flintdb.h"
+#include <stdlib.h>
+*/
+import "C"
+import (
+	"unsafe"
+)
+
+// Query is a Table.Find query as the engine reads it.
+type Query struct {
+	Index  string // the rows come from; the primary index unless USE INDEX names another
+	Desc   bool   // the rows come in descending index order
+	Offset int
+	Limit  int // -1 for none
+}
+
+// ParseQuery parses query the way Find on a table of meta does, without a
+// table, and fails where Find would. Any string is safe to pass, which makes
+// it a fuzzing entry point to the engine's query parser.
+func ParseQuery(meta *Meta, query string) (*Query, error) {
+	if meta == nil {
+		return nil, &FlintDBError{Message: "meta is nil"}
+	}
+	var e *C.char
+	cquery := C.CString(query)
+	defer C.free(unsafe.Pointer(cquery))
+
+	var index C.int
+	var desc C.i8
+	var offset, limit C.i32
+	C.flintdb_query_parse(&meta.inner, cquery, &index, &desc, &offset, &limit, &e)
+	if err := checkError(e); err != nil {
+		return nil, err
+	}
+	return &Query{
+		Index:  cstring(meta.inner.indexes.a[index].name[:]),
+		Desc:   desc != 0,
+		Offset: int(offset),
+		Limit:  int(limit),
+	}, nil
+}
+
+// ParseDelimitedLine decodes line, without its line break, as a delimited
+// file of meta holds it: TSV, or CSV after SetFormatCSV. It fails where a
+// find on the file would skip or stop at the line. Any string is safe to
+// pass, which makes it a fuzzing entry point to the engine's line decoder.
+// The caller frees the row.
+func ParseDelimitedLine(meta *Meta, line string) (*Row, error) {
+	if meta == nil {
+		return nil, &FlintDBError{Message: "meta is nil"}
+	}
+	var e *C.char
+	cline := C.CString(line)
+	defer C.free(unsafe.Pointer(cline))
+
+	row := C.flintdb_row_decode_line(&meta.inner, cline, C.u32(len(line)), &e)
+	if err := checkError(e); err != nil {
+		return nil, err
+	}
+	if row == nil {
+		return nil, &FlintDBError{Message: "failed to create row"}
+	}
+	r := &Row{inner: row, meta: &meta.inner, owned: true, names: meta.columnNames()}
+	trackHandle("row", r, nil)
+	return r, nil
+}
@@ -0,0 +1,362 @@
+package flintdb
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Partition periods for PartitionedTableOpen.
+const (
+	PARTITION_DAY   = "day"
+	PARTITION_MONTH = "month"
+)
+
+// partitionShift splits a PartitionedTable rowid into the partition's period
+// number, counted from 1970 in the high bits, and the rowid in its file.
+const partitionShift = 40
+
+// PartitionedTable keeps one table file per day or month of a timestamp
+// column, named after the period, such as logs.20260116.flintdb. Periods
+// are in UTC. The column is DATE or TIME, or INT64 holding Unix seconds.
+type PartitionedTable struct {
+	path   string
+	mode   uint32
+	meta   *Meta
+	column string
+	period string
+
+	mu    sync.Mutex
+	parts map[int64]*Table // period number -> table
+}
+
+// PartitionedTableOpen opens the partitions of path found on disk. New
+// partitions are created from meta when rows for their period are inserted.
+func PartitionedTableOpen(path string, mode uint32, meta *Meta, column string, period string) (*PartitionedTable, error) {
+	if period != PARTITION_DAY && period != PARTITION_MONTH {
+		return nil, &FlintDBError{Message: fmt.Sprintf("invalid partition period: %s", period)}
+	}
+	p := &PartitionedTable{path: path, mode: mode, meta: meta, column: column, period: period, parts: map[int64]*Table{}}
+
+	files, err := filepath.Glob(strings.TrimSuffix(path, TABLE_NAME_SUFFIX) + ".*" + TABLE_NAME_SUFFIX)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		n, ok := p.periodOfFile(file)
+		if !ok {
+			continue
+		}
+		t, err := TableOpen(file, mode, nil)
+		if err != nil {
+			p.Close()
+			return nil, err
+		}
+		p.parts[n] = t
+	}
+	return p, nil
+}
+
+func (p *PartitionedTable) layout() string {
+	if p.period == PARTITION_DAY {
+		return "20060102"
+	}
+	return "200601"
+}
+
+func (p *PartitionedTable) periodOfFile(file string) (int64, bool) {
+	name := strings.TrimSuffix(file, TABLE_NAME_SUFFIX)
+	name = name[strings.LastIndexByte(name, '.')+1:]
+	if len(name) != len(p.layout()) {
+		return 0, false
+	}
+	start, err := time.Parse(p.layout(), name)
+	if err != nil || start.Year() < 1970 {
+		return 0, false
+	}
+	return p.periodOf(start), true
+}
+
+func (p *PartitionedTable) periodOf(ts time.Time) int64 {
+	ts = ts.UTC()
+	if p.period == PARTITION_DAY {
+		return int64(time.Date(ts.Year(), ts.Month(), ts.Day(), 0, 0, 0, 0, time.UTC).Unix() / 86400)
+	}
+	return int64(ts.Year()-1970)*12 + int64(ts.Month()-1)
+}
+
+// periodStart returns the first instant of period n.
+func (p *PartitionedTable) periodStart(n int64) time.Time {
+	if p.period == PARTITION_DAY {
+		return time.Unix(n*86400, 0).UTC()
+	}
+	return time.Date(1970+int(n/12), time.Month(n%12+1), 1, 0, 0, 0, 0, time.UTC)
+}
+
+func (p *PartitionedTable) partitionPath(n int64) string {
+	return fmt.Sprintf("%s.%s%s", strings.TrimSuffix(p.path, TABLE_NAME_SUFFIX), p.periodStart(n).Format(p.layout()), TABLE_NAME_SUFFIX)
+}
+
+func (p *PartitionedTable) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, t := range p.parts {
+		t.Close()
+	}
+	p.parts = map[int64]*Table{}
+}
+
+// Ping pings each open partition, as Table.Ping does.
+func (p *PartitionedTable) Ping() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, t := range p.parts {
+		if err := t.Ping(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Partitions returns the start of each partition's period, oldest first.
+func (p *PartitionedTable) Partitions() []time.Time {
+	var starts []time.Time
+	for _, n := range p.periods() {
+		starts = append(starts, p.periodStart(n))
+	}
+	return starts
+}
+
+func (p *PartitionedTable) periods() []int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	periods := make([]int64, 0, len(p.parts))
+	for n := range p.parts {
+		periods = append(periods, n)
+	}
+	sort.Slice(periods, func(i, j int) bool { return periods[i] < periods[j] })
+	return periods
+}
+
+func (p *PartitionedTable) partition(n int64) *Table {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.parts[n]
+}
+
+// CreateRow returns a row for Insert. Rows are made by a partition, so with
+// none on disk yet it creates the one for the current period.
+func (p *PartitionedTable) CreateRow() (*Row, error) {
+	if t := p.anyPartition(); t != nil {
+		return t.CreateRow()
+	}
+	if p.meta == nil {
+		return nil, &FlintDBError{Message: "partitioned table has no partitions and no meta"}
+	}
+	t, err := p.open(-1)
+	if err != nil {
+		return nil, err
+	}
+	return t.CreateRow()
+}
+
+func (p *PartitionedTable) anyPartition() *Table {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, t := range p.parts {
+		return t
+	}
+	return nil
+}
+
+// open returns the partition for period n, creating it if needed. n < 0
+// opens the partition of the current time.
+func (p *PartitionedTable) open(n int64) (*Table, error) {
+	if n < 0 {
+		n = p.periodOf(time.Now())
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if t := p.parts[n]; t != nil {
+		return t, nil
+	}
+	if p.mode != FLINTDB_RDWR || p.meta == nil {
+		return nil, &FlintDBError{Message: fmt.Sprintf("partition not found: %s", p.periodStart(n).Format(p.layout()))}
+	}
+	t, err := TableOpen(p.partitionPath(n), p.mode, p.meta)
+	if err != nil {
+		return nil, err
+	}
+	p.parts[n] = t
+	return t, nil
+}
+
+// periodOfRow returns the period of row's timestamp.
+func (p *PartitionedTable) periodOfRow(row *Row) (int64, error) {
+	v, err := row.GetByName(p.column)
+	if err != nil {
+		return 0, err
+	}
+	var ts time.Time
+	switch v := v.(type) {
+	case time.Time:
+		ts = v
+	case int64:
+		ts = time.Unix(v, 0)
+	case nil:
+		return 0, &FlintDBError{Message: fmt.Sprintf("partition column is NULL: %s", p.column)}
+	default:
+		return 0, &FlintDBError{Message: fmt.Sprintf("partition column is not a timestamp: %s", p.column)}
+	}
+	if ts.UTC().Year() < 1970 {
+		return 0, &FlintDBError{Message: fmt.Sprintf("timestamp before 1970: %v", ts)}
+	}
+	return p.periodOf(ts), nil
+}
+
+func (p *PartitionedTable) rowID(n int64, rowid int64) int64 {
+	return n<<partitionShift | rowid
+}
+
+func (p *PartitionedTable) localID(rowid int64) (*Table, int64, error) {
+	if rowid < 0 {
+		return nil, -1, &FlintDBError{Message: fmt.Sprintf("invalid rowid: %d", rowid)}
+	}
+	t := p.partition(rowid >> partitionShift)
+	if t == nil {
+		return nil, -1, &FlintDBError{Message: "row not found"}
+	}
+	return t, rowid & (1<<partitionShift - 1), nil
+}
+
+// Insert stores row in the partition of its timestamp and returns a rowid
+// valid across partitions.
+func (p *PartitionedTable) Insert(row *Row) (int64, error) {
+	n, err := p.periodOfRow(row)
+	if err != nil {
+		return -1, err
+	}
+	t, err := p.open(n)
+	if err != nil {
+		return -1, err
+	}
+	rowid, err := t.Insert(row)
+	if err != nil {
+		return -1, err
+	}
+	return p.rowID(n, rowid), nil
+}
+
+func (p *PartitionedTable) Read(rowid int64) (*Row, error) {
+	t, local, err := p.localID(rowid)
+	if err != nil {
+		return nil, err
+	}
+	return t.Read(local)
+}
+
+// UpdateAt fails if the new timestamp falls in another partition.
+func (p *PartitionedTable) UpdateAt(rowid int64, row *Row) error {
+	t, local, err := p.localID(rowid)
+	if err != nil {
+		return err
+	}
+	n, err := p.periodOfRow(row)
+	if err != nil {
+		return err
+	}
+	if n != rowid>>partitionShift {
+		return &FlintDBError{Message: fmt.Sprintf("update moves row to another partition: %s", p.column)}
+	}
+	return t.UpdateAt(local, row)
+}
+
+func (p *PartitionedTable) DeleteAt(rowid int64) error {
+	t, local, err := p.localID(rowid)
+	if err != nil {
+		return err
+	}
+	return t.DeleteAt(local)
+}
+
+// Find runs query on every partition, oldest first.
+func (p *PartitionedTable) Find(query string) (*CursorInt64, error) {
+	return p.find(p.periods(), query)
+}
+
+// FindBetween is Find limited to the partitions overlapping [from, to); the
+// query should still bound the timestamp column for an exact range.
+func (p *PartitionedTable) FindBetween(from time.Time, to time.Time, query string) (*CursorInt64, error) {
+	var periods []int64
+	for _, n := range p.periods() {
+		if p.periodStart(n).Before(to) && p.periodStart(n+1).After(from) {
+			periods = append(periods, n)
+		}
+	}
+	return p.find(periods, query)
+}
+
+func (p *PartitionedTable) find(periods []int64, query string) (*CursorInt64, error) {
+	rest, offset, limit := splitLimit(query)
+	if limit >= 0 {
+		rest += fmt.Sprintf(" LIMIT %d", offset+limit)
+	}
+	var rows []int64
+	for _, n := range periods {
+		if limit >= 0 && len(rows) >= offset+limit {
+			break
+		}
+		t := p.partition(n)
+		if t == nil {
+			continue
+		}
+		cursor, err := t.Find(rest)
+		if err != nil {
+			return nil, err
+		}
+		for {
+			rowid, err := cursor.Next()
+			if err != nil {
+				cursor.Close()
+				return nil, err
+			}
+			if rowid < 0 {
+				break
+			}
+			rows = append(rows, p.rowID(n, rowid))
+		}
+		cursor.Close()
+	}
+	if offset > len(rows) {
+		offset = len(rows)
+	}
+	rows = rows[offset:]
+	if limit >= 0 && limit < len(rows) {
+		rows = rows[:limit]
+	}
+	return &CursorInt64{rows: rows}, nil
+}
+
+// DropBefore removes the partitions whose whole period is before t and
+// returns how many were dropped.
+func (p *PartitionedTable) DropBefore(t time.Time) (int, error) {
+	if p.mode != FLINTDB_RDWR {
+		return 0, &FlintDBError{Message: "partitioned table is read-only"}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	dropped := 0
+	for n, table := range p.parts {
+		if p.periodStart(n + 1).After(t) {
+			continue
+		}
+		table.Close()
+		delete(p.parts, n)
+		TableDrop(p.partitionPath(n))
+		dropped++
+	}
+	return dropped, nil
+}
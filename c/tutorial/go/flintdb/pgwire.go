@@ -0,0 +1,773 @@
+package flintdb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PGServer is an experimental PostgreSQL wire-protocol frontend to registered
+// tables, so psql and other PostgreSQL clients can inspect them. It speaks
+// the simple query protocol only, without authentication or TLS; JDBC
+// clients such as DBeaver need preferQueryMode=simple. Statements are
+// translated onto the table:
+//
+//	SELECT *|expr [AS name], ... [FROM table [query]]
+//	SELECT COUNT(*) FROM table [query]
+//	INSERT INTO table [(column, ...)] VALUES (expr, ...), ...
+//	UPDATE table SET column = expr, ... [query]
+//	DELETE FROM table [query]
+//
+// where query is what Table.Find takes, such as "WHERE id > 10 LIMIT 5", and
+// a table name that is not an identifier is double-quoted. Each row of an
+// INSERT, UPDATE or DELETE is written on its own: a failure leaves the rows
+// before it written. Statements run one at a time, since tables are not safe
+// for concurrent use.
+type PGServer struct {
+	mu       sync.Mutex // held by a running statement
+	tables   map[string]*Table
+	state    sync.Mutex // guards listener and conns
+	listener net.Listener
+	conns    map[net.Conn]bool
+	wg       sync.WaitGroup
+}
+
+func NewPGServer() *PGServer {
+	return &PGServer{tables: map[string]*Table{}, conns: map[net.Conn]bool{}}
+}
+
+// Register serves t under name. The server does not close registered tables.
+func (s *PGServer) Register(name string, t *Table) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tables[name] = t
+}
+
+// ListenAndServe serves on addr, such as "localhost:5432", until Shutdown.
+func (s *PGServer) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s.state.Lock()
+	s.listener = l
+	s.state.Unlock()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.state.Lock()
+			closed := s.listener == nil
+			s.state.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+		s.state.Lock()
+		s.conns[conn] = true
+		s.state.Unlock()
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			c := &pgConn{server: s, conn: conn, r: bufio.NewReader(conn), w: bufio.NewWriter(conn)}
+			c.serve()
+			conn.Close()
+			s.state.Lock()
+			delete(s.conns, conn)
+			s.state.Unlock()
+		}()
+	}
+}
+
+// Shutdown stops listening, closes the connections and waits for their
+// statements to finish.
+func (s *PGServer) Shutdown() {
+	s.state.Lock()
+	if s.listener != nil {
+		s.listener.Close()
+		s.listener = nil
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.state.Unlock()
+	s.wg.Wait()
+}
+
+// table returns the table a name token refers to; unquoted names match
+// regardless of case.
+func (s *PGServer) table(tok *exprToken) (*Table, error) {
+	if tok == nil || tok.kind != tokenIdent && tok.kind != tokenString {
+		return nil, &pgError{code: "42601", message: "expected a table name"}
+	}
+	if t, ok := s.tables[tok.text]; ok {
+		return t, nil
+	}
+	if tok.kind == tokenIdent {
+		for name, t := range s.tables {
+			if strings.EqualFold(name, tok.text) {
+				return t, nil
+			}
+		}
+	}
+	return nil, &pgError{code: "42P01", message: fmt.Sprintf("no such table: %s", tok.text)}
+}
+
+// pgError is an error reported with a SQLSTATE code other than XX000.
+type pgError struct {
+	code    string
+	message string
+}
+
+func (e *pgError) Error() string { return e.message }
+
+// Type OIDs of the values sent.
+const (
+	pgBytea     = 17
+	pgInt8      = 20
+	pgText      = 25
+	pgFloat8    = 701
+	pgDate      = 1082
+	pgTimestamp = 1114
+)
+
+func pgType(kind int) uint32 {
+	switch kind {
+	case VARIANT_INT32, VARIANT_INT64:
+		return pgInt8
+	case VARIANT_DOUBLE, VARIANT_FLOAT:
+		return pgFloat8
+	case VARIANT_BYTES:
+		return pgBytea
+	case VARIANT_DATE:
+		return pgDate
+	case VARIANT_TIME:
+		return pgTimestamp
+	}
+	return pgText
+}
+
+type pgColumn struct {
+	name string
+	oid  uint32
+}
+
+// pgConn is one client connection.
+type pgConn struct {
+	server *PGServer
+	conn   net.Conn
+	r      *bufio.Reader
+	w      *bufio.Writer
+	buf    []byte // body of the message being built
+}
+
+// Protocol codes of the startup message.
+const (
+	pgProtocol3   = 196608
+	pgSSLRequest  = 80877103
+	pgGSSRequest  = 80877104
+	pgCancelQuery = 80877102
+)
+
+func (c *pgConn) serve() {
+	if !c.startup() {
+		return
+	}
+	failed := false // an extended query message was refused; skip to Sync
+	for {
+		kind, body, err := c.read()
+		if err != nil {
+			return
+		}
+		switch kind {
+		case 'Q':
+			c.query(strings.TrimRight(string(body), "\x00"))
+			c.ready()
+		case 'X':
+			return
+		case 'S':
+			failed = false
+			c.ready()
+		case 'H':
+		default:
+			if !failed {
+				c.error(&pgError{code: "0A000", message: "only the simple query protocol is supported"})
+				failed = true
+			}
+		}
+		if c.w.Flush() != nil {
+			return
+		}
+	}
+}
+
+// startup reads the startup message, refusing SSL and GSS encryption, and
+// accepts the client without authentication.
+func (c *pgConn) startup() bool {
+	for {
+		var head [8]byte
+		if _, err := io.ReadFull(c.r, head[:]); err != nil {
+			return false
+		}
+		size := binary.BigEndian.Uint32(head[:4])
+		if size < 8 || size > 1<<16 {
+			return false
+		}
+		if _, err := io.CopyN(io.Discard, c.r, int64(size-8)); err != nil {
+			return false
+		}
+		switch binary.BigEndian.Uint32(head[4:]) {
+		case pgSSLRequest, pgGSSRequest:
+			if _, err := c.conn.Write([]byte{'N'}); err != nil {
+				return false
+			}
+			continue
+		case pgProtocol3:
+		default:
+			return false
+		}
+
+		c.begin()
+		c.buf = binary.BigEndian.AppendUint32(c.buf, 0)
+		c.send('R')
+		for _, p := range [][2]string{
+			{"server_version", "14.0"},
+			{"server_encoding", "UTF8"},
+			{"client_encoding", "UTF8"},
+			{"DateStyle", "ISO, MDY"},
+			{"integer_datetimes", "on"},
+			{"standard_conforming_strings", "on"},
+		} {
+			c.begin()
+			c.string(p[0])
+			c.string(p[1])
+			c.send('S')
+		}
+		c.ready()
+		return c.w.Flush() == nil
+	}
+}
+
+// read reads a message of the client.
+func (c *pgConn) read() (byte, []byte, error) {
+	var head [5]byte
+	if _, err := io.ReadFull(c.r, head[:]); err != nil {
+		return 0, nil, err
+	}
+	size := binary.BigEndian.Uint32(head[1:])
+	if size < 4 || size > 1<<30 {
+		return 0, nil, &FlintDBError{Message: "invalid message length"}
+	}
+	body := make([]byte, size-4)
+	if _, err := io.ReadFull(c.r, body); err != nil {
+		return 0, nil, err
+	}
+	return head[0], body, nil
+}
+
+func (c *pgConn) begin() { c.buf = c.buf[:0] }
+
+func (c *pgConn) string(s string) {
+	c.buf = append(append(c.buf, s...), 0)
+}
+
+func (c *pgConn) send(kind byte) {
+	c.w.WriteByte(kind)
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], uint32(len(c.buf)+4))
+	c.w.Write(size[:])
+	c.w.Write(c.buf)
+}
+
+func (c *pgConn) ready() {
+	c.begin()
+	c.buf = append(c.buf, 'I')
+	c.send('Z')
+}
+
+func (c *pgConn) error(err error) {
+	code, message := "XX000", err.Error()
+	if e, ok := err.(*pgError); ok {
+		code = e.code
+	}
+	c.begin()
+	for _, f := range [][2]string{{"S", "ERROR"}, {"V", "ERROR"}, {"C", code}, {"M", message}} {
+		c.buf = append(c.buf, f[0][0])
+		c.string(f[1])
+	}
+	c.buf = append(c.buf, 0)
+	c.send('E')
+}
+
+func (c *pgConn) describe(columns []pgColumn) {
+	c.begin()
+	c.buf = binary.BigEndian.AppendUint16(c.buf, uint16(len(columns)))
+	for _, col := range columns {
+		c.string(col.name)
+		c.buf = binary.BigEndian.AppendUint32(c.buf, 0) // table
+		c.buf = binary.BigEndian.AppendUint16(c.buf, 0) // column number
+		c.buf = binary.BigEndian.AppendUint32(c.buf, col.oid)
+		c.buf = binary.BigEndian.AppendUint16(c.buf, 0xffff) // variable size
+		c.buf = binary.BigEndian.AppendUint32(c.buf, 0xffffffff)
+		c.buf = binary.BigEndian.AppendUint16(c.buf, 0) // text format
+	}
+	c.send('T')
+}
+
+func (c *pgConn) dataRow(values []interface{}, columns []pgColumn) {
+	c.begin()
+	c.buf = binary.BigEndian.AppendUint16(c.buf, uint16(len(values)))
+	for i, v := range values {
+		if v == nil {
+			c.buf = binary.BigEndian.AppendUint32(c.buf, 0xffffffff)
+			continue
+		}
+		text := pgFormat(v, columns[i].oid)
+		c.buf = binary.BigEndian.AppendUint32(c.buf, uint32(len(text)))
+		c.buf = append(c.buf, text...)
+	}
+	c.send('D')
+}
+
+// pgFormat returns the text form of a non-NULL value.
+func pgFormat(v interface{}, oid uint32) string {
+	switch v := v.(type) {
+	case bool:
+		if v {
+			return "t"
+		}
+		return "f"
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case []byte:
+		return `\x` + hex.EncodeToString(v)
+	case time.Time:
+		if oid == pgDate {
+			return v.UTC().Format("2006-01-02")
+		}
+		return v.UTC().Format("2006-01-02 15:04:05")
+	}
+	return exprString(v)
+}
+
+// query runs the statements of a simple query message until one fails.
+func (c *pgConn) query(sql string) {
+	statements := pgSplit(sql)
+	if len(statements) == 0 {
+		c.begin()
+		c.send('I')
+		return
+	}
+	for _, stmt := range statements {
+		tag, err := c.statement(stmt)
+		if err != nil {
+			c.error(err)
+			return
+		}
+		c.begin()
+		c.string(tag)
+		c.send('C')
+	}
+}
+
+// pgSplit splits sql into statements at the semicolons outside quotes.
+func pgSplit(sql string) []string {
+	var statements []string
+	var quote byte
+	start := 0
+	for i := 0; i <= len(sql); i++ {
+		if i < len(sql) && (quote != 0 || sql[i] != ';') {
+			switch c := sql[i]; {
+			case c == quote:
+				quote = 0
+			case quote == 0 && (c == '\'' || c == '"'):
+				quote = c
+			}
+			continue
+		}
+		if stmt := strings.TrimSpace(sql[start:i]); stmt != "" {
+			statements = append(statements, stmt)
+		}
+		start = i + 1
+	}
+	return statements
+}
+
+// statement runs one statement and returns its command tag.
+func (c *pgConn) statement(sql string) (string, error) {
+	tokens, err := tokenizeExpr(sql)
+	if err != nil {
+		return "", err
+	}
+	c.server.mu.Lock()
+	defer c.server.mu.Unlock()
+	p := &exprParser{tokens: tokens, columnAt: func(string) int { return -1 }}
+	switch {
+	case p.keyword("SELECT"):
+		return c.selectRows(sql, p)
+	case p.keyword("INSERT"):
+		return c.server.insert(p)
+	case p.keyword("UPDATE"):
+		return c.server.update(sql, p)
+	case p.keyword("DELETE"):
+		return c.server.delete(sql, p)
+	}
+	return "", &pgError{code: "0A000", message: fmt.Sprintf("unsupported statement: %s", sql)}
+}
+
+// rest returns the text of sql from the parser's position, the Find query
+// of a statement.
+func (p *exprParser) rest(sql string) string {
+	if t := p.peek(); t != nil {
+		return sql[t.pos:]
+	}
+	return ""
+}
+
+func (c *pgConn) selectRows(sql string, p *exprParser) (string, error) {
+	// The select list ends at the first FROM outside parentheses
+	var items [][]exprToken
+	start, depth := p.pos, 0
+	for ; p.pos < len(p.tokens); p.pos++ {
+		tok := p.tokens[p.pos]
+		if tok.kind == tokenSymbol && tok.text == "(" {
+			depth++
+		} else if tok.kind == tokenSymbol && tok.text == ")" {
+			depth--
+		} else if depth == 0 && tok.kind == tokenSymbol && tok.text == "," {
+			items = append(items, p.tokens[start:p.pos])
+			start = p.pos + 1
+		} else if depth == 0 && tok.kind == tokenIdent && strings.EqualFold(tok.text, "FROM") {
+			break
+		}
+	}
+	items = append(items, p.tokens[start:p.pos])
+	for _, item := range items {
+		if len(item) == 0 {
+			return "", &pgError{code: "42601", message: "empty select list item"}
+		}
+	}
+
+	var t *Table
+	query := ""
+	if p.keyword("FROM") {
+		var err error
+		if t, err = c.server.table(p.peek()); err != nil {
+			return "", err
+		}
+		p.pos++
+		query = p.rest(sql)
+	}
+
+	if t != nil && len(items) == 1 && pgCount(items[0]) {
+		rowids, err := matching(t, query)
+		if err != nil {
+			return "", err
+		}
+		columns := []pgColumn{{name: "count", oid: pgInt8}}
+		c.describe(columns)
+		c.dataRow([]interface{}{int64(len(rowids))}, columns)
+		return "SELECT 1", nil
+	}
+
+	columnAt := p.columnAt
+	if t != nil {
+		columnAt = t.columnAt
+	}
+	var columns []pgColumn
+	var exprs []expr
+	for _, item := range items {
+		if t != nil && len(item) == 1 && item[0].kind == tokenSymbol && item[0].text == "*" {
+			for _, col := range t.exportColumns() {
+				columns = append(columns, pgColumn{name: col.name, oid: pgType(col.kind)})
+				exprs = append(exprs, &exprColumn{name: col.name, index: col.index})
+			}
+			continue
+		}
+		name := ""
+		if n := len(item); n > 2 && item[n-2].kind == tokenIdent && strings.EqualFold(item[n-2].text, "AS") {
+			name, item = item[n-1].text, item[:n-2]
+		}
+		src := sql[item[0].pos:item[len(item)-1].end]
+		x, err := parseExpr(src, columnAt)
+		if err != nil {
+			return "", err
+		}
+		col := pgColumn{name: name, oid: pgText}
+		if x, ok := x.(*exprColumn); ok {
+			col.oid = pgType(int(t.meta.columns.a[x.index]._type))
+		}
+		if col.name == "" {
+			col.name = src
+		}
+		columns = append(columns, col)
+		exprs = append(exprs, x)
+	}
+	c.describe(columns)
+
+	values := make([]interface{}, len(exprs))
+	if t == nil {
+		for i, x := range exprs {
+			v, err := x.eval(nil)
+			if err != nil {
+				return "", err
+			}
+			values[i] = v
+		}
+		c.dataRow(values, columns)
+		return "SELECT 1", nil
+	}
+	cursor, err := t.Find(query)
+	if err != nil {
+		return "", err
+	}
+	defer cursor.Close()
+	n := 0
+	for {
+		rowid, err := cursor.Next()
+		if err != nil {
+			return "", err
+		}
+		if rowid < 0 {
+			return fmt.Sprintf("SELECT %d", n), nil
+		}
+		row, err := t.Read(rowid)
+		if err != nil {
+			return "", err
+		}
+		for i, x := range exprs {
+			if values[i], err = x.eval(row); err != nil {
+				row.Free()
+				return "", err
+			}
+		}
+		row.Free()
+		c.dataRow(values, columns)
+		n++
+	}
+}
+
+// pgCount reports whether a select list item is COUNT(*).
+func pgCount(item []exprToken) bool {
+	if len(item) != 4 || !strings.EqualFold(item[0].text, "COUNT") {
+		return false
+	}
+	return item[1].text == "(" && item[2].text == "*" && item[3].text == ")"
+}
+
+// matching returns the rowids of the rows matching query, collected before
+// any of them is written.
+func matching(t *Table, query string) ([]int64, error) {
+	cursor, err := t.Find(query)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close()
+	var rowids []int64
+	for {
+		rowid, err := cursor.Next()
+		if err != nil {
+			return nil, err
+		}
+		if rowid < 0 {
+			return rowids, nil
+		}
+		rowids = append(rowids, rowid)
+	}
+}
+
+func (s *PGServer) insert(p *exprParser) (string, error) {
+	if !p.keyword("INTO") {
+		return "", &pgError{code: "42601", message: "expected INTO"}
+	}
+	t, err := s.table(p.peek())
+	if err != nil {
+		return "", err
+	}
+	p.pos++
+	var indexes []int
+	if p.symbol("(") != "" {
+		for {
+			tok := p.peek()
+			if tok == nil || tok.kind != tokenIdent {
+				return "", &pgError{code: "42601", message: "expected a column name"}
+			}
+			index := t.columnAt(tok.text)
+			if index < 0 {
+				return "", &pgError{code: "42703", message: fmt.Sprintf("column not found: %s", tok.text)}
+			}
+			indexes = append(indexes, index)
+			p.pos++
+			if p.symbol(")") != "" {
+				break
+			}
+			if p.symbol(",") == "" {
+				return "", &pgError{code: "42601", message: "expected , or )"}
+			}
+		}
+	} else {
+		for _, col := range t.exportColumns() {
+			indexes = append(indexes, col.index)
+		}
+	}
+	if !p.keyword("VALUES") {
+		return "", &pgError{code: "42601", message: "expected VALUES"}
+	}
+
+	n := 0
+	for {
+		list, err := p.parseList()
+		if err != nil {
+			return "", err
+		}
+		if len(list) != len(indexes) {
+			return "", &pgError{code: "42601", message: fmt.Sprintf("expected %d values, found %d", len(indexes), len(list))}
+		}
+		row, err := t.CreateRow()
+		if err != nil {
+			return "", err
+		}
+		for i, x := range list {
+			var v interface{}
+			if v, err = x.eval(nil); err == nil {
+				err = row.Set(indexes[i], v)
+			}
+			if err != nil {
+				break
+			}
+		}
+		if err == nil {
+			_, err = t.Insert(row)
+		}
+		row.Free()
+		if err != nil {
+			return "", fmt.Errorf("row %d: %w", n+1, err)
+		}
+		n++
+		if p.symbol(",") == "" {
+			break
+		}
+	}
+	if p.peek() != nil {
+		return "", &pgError{code: "42601", message: fmt.Sprintf("unexpected %q", p.peek().text)}
+	}
+	return fmt.Sprintf("INSERT 0 %d", n), nil
+}
+
+func (s *PGServer) update(sql string, p *exprParser) (string, error) {
+	t, err := s.table(p.peek())
+	if err != nil {
+		return "", err
+	}
+	p.pos++
+	if !p.keyword("SET") {
+		return "", &pgError{code: "42601", message: "expected SET"}
+	}
+	p.columnAt = t.columnAt
+	type assignment struct {
+		index int
+		value expr
+	}
+	var sets []assignment
+	for {
+		tok := p.peek()
+		if tok == nil || tok.kind != tokenIdent {
+			return "", &pgError{code: "42601", message: "expected a column name"}
+		}
+		index := t.columnAt(tok.text)
+		if index < 0 {
+			return "", &pgError{code: "42703", message: fmt.Sprintf("column not found: %s", tok.text)}
+		}
+		p.pos++
+		if p.symbol("=") == "" {
+			return "", &pgError{code: "42601", message: "expected ="}
+		}
+		x, err := p.parseOr()
+		if err != nil {
+			return "", err
+		}
+		sets = append(sets, assignment{index, x})
+		if p.symbol(",") == "" {
+			break
+		}
+	}
+
+	rowids, err := matching(t, p.rest(sql))
+	if err != nil {
+		return "", err
+	}
+	for n, rowid := range rowids {
+		if err := updateRow(t, rowid, func(old, row *Row) error {
+			for _, set := range sets {
+				v, err := set.value.eval(old)
+				if err == nil {
+					err = row.Set(set.index, v)
+				}
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}); err != nil {
+			return "", fmt.Errorf("row %d: %w (%d rows updated)", rowid, err, n)
+		}
+	}
+	return fmt.Sprintf("UPDATE %d", len(rowids)), nil
+}
+
+// updateRow replaces the row at rowid with a copy of it changed by set.
+func updateRow(t *Table, rowid int64, set func(old, row *Row) error) error {
+	old, err := t.Read(rowid)
+	if err != nil {
+		return err
+	}
+	defer old.Free()
+	row, err := t.CreateRow()
+	if err != nil {
+		return err
+	}
+	defer row.Free()
+	for i := 0; i < int(t.meta.columns.length); i++ {
+		var v interface{}
+		if v, err = old.Get(i); err == nil {
+			err = row.Set(i, v)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := set(old, row); err != nil {
+		return err
+	}
+	return t.UpdateAt(rowid, row)
+}
+
+func (s *PGServer) delete(sql string, p *exprParser) (string, error) {
+	if !p.keyword("FROM") {
+		return "", &pgError{code: "42601", message: "expected FROM"}
+	}
+	t, err := s.table(p.peek())
+	if err != nil {
+		return "", err
+	}
+	p.pos++
+	rowids, err := matching(t, p.rest(sql))
+	if err != nil {
+		return "", err
+	}
+	for n, rowid := range rowids {
+		if err := t.DeleteAt(rowid); err != nil {
+			return "", fmt.Errorf("row %d: %w (%d rows deleted)", rowid, err, n)
+		}
+	}
+	return fmt.Sprintf("DELETE %d", len(rowids)), nil
+}
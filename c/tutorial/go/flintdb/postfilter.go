@@ -0,0 +1,163 @@
+package flintdb
+
+import (
+	"strings"
+)
+
+// postFilter is the part of a find's WHERE the engine cannot evaluate,
+// tested in Go on each row the engine finds, and the LIMIT of the find,
+// which then counts only rows that pass.
+type postFilter struct {
+	table *Table
+	match expr
+	skip  int
+	limit int // -1 for none
+}
+
+// splitPostFilter takes the conditions of query the engine cannot evaluate
+// out of its WHERE into a post filter: [NOT] LIKE with a pattern the
+// engine's LIKE, which knows only a % at either end, would get wrong, GLOB
+// and REGEXP, and with ignoreCase comparisons of string columns without
+// CollationNoCase to strings. Conditions are split at the ANDs of the WHERE;
+// one that also has an OR outside parentheses goes to the post filter whole.
+// Without such conditions query is returned as is, with a nil filter.
+func (t *Table) splitPostFilter(query string, ignoreCase bool) (string, *postFilter, error) {
+	upper := strings.ToUpper(query)
+	if !ignoreCase && !strings.Contains(upper, "LIKE") && !strings.Contains(upper, "GLOB") && !strings.Contains(upper, "REGEXP") {
+		return query, nil, nil
+	}
+	rest, offset, limit := splitLimit(query)
+	tokens, err := tokenizeExpr(rest)
+	if err != nil {
+		return query, nil, nil // the engine reports it
+	}
+	where := -1
+	for i, tok := range tokens {
+		if tok.kind == tokenIdent && strings.EqualFold(tok.text, "WHERE") {
+			where = i
+			break
+		}
+	}
+	if where < 0 || where == len(tokens)-1 {
+		return query, nil, nil
+	}
+
+	// The conditions between the ANDs, or the whole WHERE if it has an OR
+	var conds [][]exprToken
+	depth, start, or := 0, where+1, false
+	for i := where + 1; i <= len(tokens); i++ {
+		if i < len(tokens) {
+			tok := tokens[i]
+			switch {
+			case tok.kind == tokenSymbol && tok.text == "(":
+				depth++
+			case tok.kind == tokenSymbol && tok.text == ")":
+				depth--
+			case depth == 0 && tok.kind == tokenIdent && strings.EqualFold(tok.text, "OR"):
+				or = true
+			}
+			if depth != 0 || tok.kind != tokenIdent || !strings.EqualFold(tok.text, "AND") {
+				continue
+			}
+		}
+		conds = append(conds, tokens[start:i])
+		start = i + 1
+	}
+	if or {
+		conds = [][]exprToken{tokens[where+1:]}
+	}
+
+	var engine, post []string
+	for _, cond := range conds {
+		if len(cond) == 0 {
+			return query, nil, nil
+		}
+		text := rest[cond[0].pos:cond[len(cond)-1].end]
+		if t.engineEvaluates(cond, ignoreCase) {
+			engine = append(engine, text)
+		} else {
+			post = append(post, "("+text+")")
+		}
+	}
+	if len(post) == 0 {
+		return query, nil, nil
+	}
+	match, err := parseFoldedExpr(strings.Join(post, " AND "), t.columnAt, func(i int) bool {
+		return ignoreCase && int(t.meta.columns.a[i]._type) == VARIANT_STRING || t.foldsCase(i)
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	query = strings.TrimSpace(rest[:tokens[where].pos])
+	if len(engine) > 0 {
+		query = strings.TrimSpace(query + " WHERE " + strings.Join(engine, " AND "))
+	}
+	return query, &postFilter{table: t, match: match, skip: offset, limit: limit}, nil
+}
+
+// engineEvaluates reports whether the engine evaluates the condition cond
+// as SQL does, ignoring case with ignoreCase.
+func (t *Table) engineEvaluates(cond []exprToken, ignoreCase bool) bool {
+	for i, tok := range cond {
+		if tok.kind != tokenIdent {
+			continue
+		}
+		if ignoreCase && i+2 < len(cond) && (isComparison(cond[i+1]) || isLike(cond[i+1])) && cond[i+2].kind == tokenString {
+			if c := t.columnAt(tok.text); c >= 0 && int(t.meta.columns.a[c]._type) == VARIANT_STRING && !t.foldsCase(c) {
+				return false
+			}
+		}
+		switch strings.ToUpper(tok.text) {
+		case "GLOB", "REGEXP":
+			return false
+		case "LIKE":
+			if i > 0 && strings.EqualFold(cond[i-1].text, "NOT") || i+1 == len(cond) || cond[i+1].kind != tokenString || !engineLike(cond[i+1].text) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// engineLike reports whether the engine's LIKE matches pattern as SQL does:
+// it knows only a % at either end, and takes a * anywhere for a wildcard.
+func engineLike(pattern string) bool {
+	inner := strings.TrimSuffix(strings.TrimPrefix(pattern, "%"), "%")
+	return !strings.ContainsAny(inner, "%_*") && (inner != "" || len(pattern) < 2)
+}
+
+// foldsCase reports whether the column at index has CollationNoCase, whose
+// keys the engine compares for it.
+func (t *Table) foldsCase(index int) bool {
+	for _, cc := range t.collated {
+		if cc.index == index {
+			return cc.collation.name == CollationNoCase.name
+		}
+	}
+	return false
+}
+
+// pass reports whether the row at rowid passes f, counting it against the
+// LIMIT of the find; done is set once the limit is reached.
+func (f *postFilter) pass(rowid int64) (ok, done bool, err error) {
+	if f.limit == 0 {
+		return false, true, nil
+	}
+	row, err := f.table.Read(rowid)
+	if err != nil {
+		return false, false, err
+	}
+	v, err := f.match.eval(row)
+	row.Free()
+	if err != nil || v != true {
+		return false, false, err
+	}
+	if f.skip > 0 {
+		f.skip--
+		return false, false, nil
+	}
+	if f.limit > 0 {
+		f.limit--
+	}
+	return true, false, nil
+}
@@ -0,0 +1,74 @@
+package flintdb
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// FindPrefix returns the rows whose STRING column starts with prefix, as an
+// autocomplete does. The find is a range of keys: from prefix to the first
+// string after every string starting with it, on the index that leads with
+// column if there is one. On a column with CollationNoCase the prefix
+// matches ignoring case. On a column with a locale collation, whose keys do
+// not share the prefix of their strings, or WithCaseInsensitive on a column
+// without CollationNoCase, the prefix is tested in Go on each row instead.
+func (t *Table) FindPrefix(column, prefix string, opts ...QueryOption) (*CursorInt64, error) {
+	i := t.columnAt(column)
+	if i < 0 {
+		return nil, &FlintDBError{Message: fmt.Sprintf("column not found: %s", column)}
+	}
+	if int(t.meta.columns.a[i]._type) != VARIANT_STRING {
+		return nil, &FlintDBError{Message: fmt.Sprintf("prefix column must be STRING: %s", column)}
+	}
+	name := cstring(t.meta.columns.a[i].name[:])
+
+	key, from := name, prefix
+	if cc := t.collatedColumn(name); cc != nil {
+		if cc.collation.name != CollationNoCase.name {
+			return t.findPrefixMatch(name, prefix, opts)
+		}
+		key, from = collationKeyColumn(name), foldCase(prefix)
+	} else if queryOptionsOf(opts).ignoreCase {
+		return t.findPrefixMatch(name, prefix, opts)
+	}
+
+	literal, err := quoteString(from)
+	if err != nil {
+		return nil, err
+	}
+	query := "WHERE " + key + " >= " + literal
+	if to, ok := nextPrefix(from); ok {
+		if literal, err = quoteString(to); err != nil {
+			return nil, err
+		}
+		query += " AND " + key + " < " + literal
+	}
+	if _, index := t.leadingIndex(name); index != "" {
+		query = "USE INDEX(" + index + ") " + query
+	}
+	return t.Find(query, opts...)
+}
+
+// findPrefixMatch finds the rows whose column starts with prefix with a
+// REGEXP, which the find tests in Go.
+func (t *Table) findPrefixMatch(column, prefix string, opts []QueryOption) (*CursorInt64, error) {
+	literal, err := quoteString("^" + regexp.QuoteMeta(prefix))
+	if err != nil {
+		return nil, err
+	}
+	return t.Find("WHERE "+column+" REGEXP "+literal, opts...)
+}
+
+// nextPrefix returns the least string greater than every string starting
+// with prefix, or false when there is none, for an empty prefix or one of
+// 0xff bytes only.
+func nextPrefix(prefix string) (string, bool) {
+	b := []byte(prefix)
+	for n := len(b); n > 0; n-- {
+		if b[n-1] < 0xff {
+			b[n-1]++
+			return string(b[:n]), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,144 @@
+package flintdb
+
+import (
+	"context"
+	"io"
+	"os"
+	"time"
+)
+
+// progressInterval is how often an operation reports its progress at most.
+const progressInterval = 250 * time.Millisecond
+
+// Progress is how far a long operation has got, as reported to the
+// ProgressFunc of ContextWithProgress.
+type Progress struct {
+	Op         string // "import", "export", "compact", "check" or "copy"
+	Table      string
+	Rows       int64 // rows processed so far
+	Total      int64 // rows to process, or -1 when not known
+	Bytes      int64 // bytes read or written so far
+	TotalBytes int64 // bytes to read, or -1 when not known
+	Elapsed    time.Duration
+	Done       bool // the last report, made when the operation ends
+}
+
+// Percent returns how much of the operation is done, 0 to 100, by rows when
+// their total is known, else by bytes; -1 when neither total is.
+func (p Progress) Percent() float64 {
+	switch {
+	case p.Done:
+		return 100
+	case p.Total > 0:
+		return min(100, float64(p.Rows)*100/float64(p.Total))
+	case p.TotalBytes > 0:
+		return min(100, float64(p.Bytes)*100/float64(p.TotalBytes))
+	case p.Total == 0 || p.TotalBytes == 0:
+		return 100
+	}
+	return -1
+}
+
+// ETA returns the time left at the rate so far, or -1 when Percent is not
+// known or nothing is done yet.
+func (p Progress) ETA() time.Duration {
+	percent := p.Percent()
+	if percent <= 0 {
+		return -1
+	}
+	return time.Duration(float64(p.Elapsed) * (100 - percent) / percent)
+}
+
+// ProgressFunc receives the progress of an operation, on the goroutine
+// running it: at most every 250ms, and once more when it ends.
+type ProgressFunc func(Progress)
+
+type progressKey struct{}
+
+// ContextWithProgress returns ctx with fn receiving the progress of the
+// operations run with it: ImportContext, ExportContext, CompactContext,
+// CheckContext and CopyTableContext.
+func ContextWithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// progress tracks an operation for the ProgressFunc of its context. A nil
+// progress, of a context without one, does nothing.
+type progress struct {
+	fn    ProgressFunc
+	p     Progress
+	start time.Time
+	last  time.Time
+}
+
+func progressOf(ctx context.Context, op, table string, total, totalBytes int64) *progress {
+	fn, _ := ctx.Value(progressKey{}).(ProgressFunc)
+	if fn == nil {
+		return nil
+	}
+	now := time.Now()
+	return &progress{fn: fn, p: Progress{Op: op, Table: table, Total: total, TotalBytes: totalBytes}, start: now, last: now}
+}
+
+// add counts rows and bytes processed, reporting if it is time to.
+func (p *progress) add(rows, bytes int64) {
+	if p == nil {
+		return
+	}
+	p.p.Rows += rows
+	p.p.Bytes += bytes
+	if now := time.Now(); now.Sub(p.last) >= progressInterval {
+		p.last = now
+		p.p.Elapsed = now.Sub(p.start)
+		p.fn(p.p)
+	}
+}
+
+// done makes the last report.
+func (p *progress) done() {
+	if p == nil {
+		return
+	}
+	p.p.Elapsed = time.Since(p.start)
+	p.p.Done = true
+	p.fn(p.p)
+}
+
+// progressReader counts the bytes read from r.
+type progressReader struct {
+	r io.Reader
+	p *progress
+}
+
+func (r progressReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	r.p.add(0, int64(n))
+	return n, err
+}
+
+// progressWriter counts the bytes written to w.
+type progressWriter struct {
+	w io.Writer
+	p *progress
+}
+
+func (w progressWriter) Write(b []byte) (int, error) {
+	n, err := w.w.Write(b)
+	w.p.add(0, int64(n))
+	return n, err
+}
+
+// readerSize returns the bytes left in r, when r tells them, or -1.
+func readerSize(r io.Reader) int64 {
+	switch r := r.(type) {
+	case interface{ Len() int }:
+		return int64(r.Len())
+	case *os.File:
+		if fi, err := r.Stat(); err == nil && fi.Mode().IsRegular() {
+			if at, err := r.Seek(0, io.SeekCurrent); err == nil {
+				return fi.Size() - at
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,87 @@
+package flintdb
+
+import (
+	"time"
+)
+
+// ErrTimeout is returned by a find, or by Next on its cursor, once the time
+// WithTimeout allows it has passed.
+var ErrTimeout = &FlintDBError{Message: "query timed out"}
+
+// ErrTooManyRows is returned by Next on the cursor of a find that matches
+// more rows than WithMaxRows allows.
+var ErrTooManyRows = &FlintDBError{Message: "query matches too many rows"}
+
+// QueryOption configures a single Find.
+type QueryOption func(*queryOptions)
+
+type queryOptions struct {
+	timeout    time.Duration
+	maxRows    int64
+	truncate   bool
+	orderBy    []string
+	distinct   []string
+	ignoreCase bool
+}
+
+// WithTimeout limits a find to d, from the call to Find until its cursor is
+// drained: Next fails with ErrTimeout once d has passed, stopping a scan the
+// engine is in the middle of, so an unindexed query cannot hold an API call
+// for long. The cursor must still be closed.
+func WithTimeout(d time.Duration) QueryOption {
+	return func(o *queryOptions) {
+		o.timeout = d
+	}
+}
+
+// WithMaxRows limits a find to n rows, as a guard on queries built from user
+// input: Next fails with ErrTooManyRows when a row past the nth matches.
+// With truncate, Next reports the end after n rows instead and the cursor's
+// Truncated method returns true.
+func WithMaxRows(n int64, truncate bool) QueryOption {
+	return func(o *queryOptions) {
+		o.maxRows = n
+		o.truncate = truncate
+	}
+}
+
+// WithCaseInsensitive makes the comparisons and LIKE patterns of a find
+// against strings ignore case. On columns with CollationNoCase they are
+// made by the engine, on the collation keys and so on indexes of the
+// column; on other string columns they are made in Go on each row the rest
+// of the query finds, so give columns searched this way often the
+// collation.
+func WithCaseInsensitive() QueryOption {
+	return func(o *queryOptions) {
+		o.ignoreCase = true
+	}
+}
+
+func queryOptionsOf(opts []QueryOption) queryOptions {
+	var o queryOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// expired reports whether c has a deadline that has passed.
+func (c *CursorInt64) expired() bool {
+	return !c.deadline.IsZero() && !time.Now().Before(c.deadline)
+}
+
+// overLimit ends c at a row past its WithMaxRows limit.
+func (c *CursorInt64) overLimit() (int64, error) {
+	if c.truncate {
+		c.truncated = true
+		return -1, nil
+	}
+	c.err = ErrTooManyRows
+	return -1, ErrTooManyRows
+}
+
+// Truncated reports whether the cursor ended at the limit of WithMaxRows with
+// rows left that the find matched.
+func (c *CursorInt64) Truncated() bool {
+	return c.truncated
+}
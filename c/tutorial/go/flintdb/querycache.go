@@ -0,0 +1,131 @@
+package flintdb
+
+import "container/list"
+
+// queryCacheMaxRows bounds the rowids a cached find keeps; larger results
+// are not cached.
+const queryCacheMaxRows = 100000
+
+// WithQueryCache keeps the rowids found by the last entries distinct queries,
+// so a repeated Find or Count, as from a dashboard polling the same filters,
+// skips the engine. Results of more than 100000 rows are not cached. Every
+// write through this handle invalidates the cache; writes through other
+// handles or processes are not seen, so use it on a table only this handle
+// writes.
+func WithQueryCache(entries int) OpenOption {
+	return func(o *openOptions) {
+		o.queryCache = entries
+	}
+}
+
+// queryCache is an LRU cache of find results. A result is keyed by the
+// generation of the table it was found in, which each write advances.
+type queryCache struct {
+	entries    int
+	generation uint64
+	order      *list.List // of *queryCacheEntry, most recently used first
+	byKey      map[queryCacheKey]*list.Element
+}
+
+type queryCacheKey struct {
+	generation uint64
+	query      string
+}
+
+type queryCacheEntry struct {
+	key  queryCacheKey
+	rows []int64
+}
+
+func newQueryCache(entries int) *queryCache {
+	return &queryCache{entries: entries, order: list.New(), byKey: map[queryCacheKey]*list.Element{}}
+}
+
+// invalidate runs before a write, dropping every cached result.
+func (q *queryCache) invalidate() {
+	if q == nil {
+		return
+	}
+	q.generation++
+	q.order.Init()
+	clear(q.byKey)
+}
+
+func (q *queryCache) get(query string) ([]int64, bool) {
+	el, ok := q.byKey[queryCacheKey{q.generation, query}]
+	if !ok {
+		return nil, false
+	}
+	q.order.MoveToFront(el)
+	return el.Value.(*queryCacheEntry).rows, true
+}
+
+func (q *queryCache) put(key queryCacheKey, rows []int64) {
+	if key.generation != q.generation {
+		return // found before a write
+	}
+	if el, ok := q.byKey[key]; ok {
+		el.Value.(*queryCacheEntry).rows = rows
+		q.order.MoveToFront(el)
+		return
+	}
+	q.byKey[key] = q.order.PushFront(&queryCacheEntry{key: key, rows: rows})
+	for q.order.Len() > q.entries {
+		last := q.order.Back()
+		delete(q.byKey, last.Value.(*queryCacheEntry).key)
+		q.order.Remove(last)
+	}
+}
+
+// queryFill collects the rowids a cursor returns, to cache them once it
+// reaches its end.
+type queryFill struct {
+	cache *queryCache
+	key   queryCacheKey
+	rows  []int64
+}
+
+func (q *queryCache) fill(query string) *queryFill {
+	return &queryFill{cache: q, key: queryCacheKey{q.generation, query}}
+}
+
+// record passes a rowid Next returns, or the -1 ending the cursor, to the
+// cursor's fill.
+func (c *CursorInt64) record(rowid int64) {
+	switch {
+	case c.fill == nil:
+	case rowid < 0:
+		c.fill.cache.put(c.fill.key, c.fill.rows)
+		c.fill = nil
+	case len(c.fill.rows) == queryCacheMaxRows:
+		c.fill = nil
+	default:
+		c.fill.rows = append(c.fill.rows, rowid)
+	}
+}
+
+// Count returns how many rows query finds, served by the WithQueryCache
+// cache when it holds the query.
+func (t *Table) Count(query string) (int64, error) {
+	if t.queryCache != nil {
+		if rows, ok := t.queryCache.get(t.rewriteCollated(query)); ok {
+			return int64(len(rows)), nil
+		}
+	}
+	cursor, err := t.Find(query)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close()
+	var n int64
+	for {
+		rowid, err := cursor.Next()
+		if err != nil {
+			return n, err
+		}
+		if rowid < 0 {
+			return n, nil
+		}
+		n++
+	}
+}
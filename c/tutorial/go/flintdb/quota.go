@@ -0,0 +1,92 @@
+package flintdb
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// quotaCheckWrites is how many writes pass between measurements of the files
+// of a table opened WithMaxFileSize.
+const quotaCheckWrites = 64
+
+// ErrQuotaExceeded is returned by writes to a table opened WithMaxFileSize
+// whose files have reached that size.
+var ErrQuotaExceeded = &FlintDBError{Message: "table files have reached their maximum size"}
+
+// WithMaxFileSize makes inserts and updates fail with ErrQuotaExceeded once
+// the files of the table, its data, indexes, WAL and the files kept next to
+// them, total bytes, so a device runs out of quota before it runs out of
+// disk. The files are measured when the table is opened and then every 64
+// writes, and the engine grows them by megabytes at a time, so they may end
+// up past bytes by one such step; leave room for it. Deletes are not refused, and tables opened read-only have no
+// quota.
+func WithMaxFileSize(bytes int64) OpenOption {
+	return func(o *openOptions) {
+		o.maxFileSize = bytes
+	}
+}
+
+// fileQuota tracks the size of the files of a table against its maximum.
+type fileQuota struct {
+	path   string
+	max    int64
+	used   int64 // at the last measurement
+	writes int   // since the last measurement
+}
+
+func newFileQuota(path string, max int64) (*fileQuota, error) {
+	q := &fileQuota{path: path, max: max}
+	return q, q.measure()
+}
+
+// measure sums the sizes of the file at path and the files named path.*.
+func (q *fileQuota) measure() error {
+	dir, base := filepath.Split(q.path)
+	entries, err := os.ReadDir(filepath.Clean(dir))
+	if err != nil {
+		return err
+	}
+	var used int64
+	for _, entry := range entries {
+		if name := entry.Name(); name != base && !strings.HasPrefix(name, base+".") {
+			continue
+		}
+		if info, err := entry.Info(); err == nil && info.Mode().IsRegular() {
+			used += info.Size()
+		}
+	}
+	q.used, q.writes = used, 0
+	return nil
+}
+
+// admit returns ErrQuotaExceeded when the files have reached the maximum,
+// measuring them every quotaCheckWrites calls and on every call once they
+// have. A nil quota admits every write.
+func (q *fileQuota) admit() error {
+	if q == nil {
+		return nil
+	}
+	if q.writes++; q.writes >= quotaCheckWrites || q.used >= q.max {
+		if err := q.measure(); err != nil {
+			return err
+		}
+	}
+	if q.used >= q.max {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+// Headroom returns how many bytes the files of the table may grow by before
+// writes fail with ErrQuotaExceeded, measuring them now, or -1 for a table
+// opened without WithMaxFileSize.
+func (t *Table) Headroom() (int64, error) {
+	if t.quota == nil {
+		return -1, nil
+	}
+	if err := t.quota.measure(); err != nil {
+		return -1, err
+	}
+	return max(0, t.quota.max-t.quota.used), nil
+}
@@ -0,0 +1,86 @@
+package flintdb
+
+import "fmt"
+
+// RangeBound is a bound of the key range of FindRange: key values, one for
+// each of the first columns of the index, compared as a tuple. A bound with
+// fewer values than the index has columns bounds the key prefix, so
+// From("x") starts at the first row with "x" in the first column and To("x")
+// ends at the last.
+type RangeBound struct {
+	values []interface{}
+	op     string
+}
+
+// From bounds a range from the rows with key values at least values.
+func From(values ...interface{}) RangeBound { return RangeBound{values: values, op: ">="} }
+
+// After bounds a range from the rows with key values greater than values.
+func After(values ...interface{}) RangeBound { return RangeBound{values: values, op: ">"} }
+
+// To bounds a range to the rows with key values at most values.
+func To(values ...interface{}) RangeBound { return RangeBound{values: values, op: "<="} }
+
+// Before bounds a range to the rows with key values less than values.
+func Before(values ...interface{}) RangeBound { return RangeBound{values: values, op: "<"} }
+
+// FindRange finds the rows between two bounds on the key columns of the
+// index named indexName, in index order. from is From or After, to is To or
+// Before; a bound without values leaves that end open. Equal From and To
+// bounds find the rows with those key values.
+func (t *Table) FindRange(indexName string, from, to RangeBound, opts ...QueryOption) (*CursorInt64, error) {
+	_, columns, err := t.indexKeys(indexName)
+	if err != nil {
+		return nil, err
+	}
+	if from.op == "<" || from.op == "<=" {
+		return nil, &FlintDBError{Message: "range start must be From or After"}
+	}
+	if to.op == ">" || to.op == ">=" {
+		return nil, &FlintDBError{Message: "range end must be To or Before"}
+	}
+
+	var conds []string
+	for _, b := range []RangeBound{from, to} {
+		if len(b.values) == 0 {
+			continue
+		}
+		if len(b.values) > len(columns) {
+			return nil, &FlintDBError{Message: fmt.Sprintf("index %s has %d columns, got %d values", indexName, len(columns), len(b.values))}
+		}
+		literals := make([]string, len(b.values))
+		for i, v := range b.values {
+			if v == nil {
+				return nil, &FlintDBError{Message: "range bound values cannot be NULL"}
+			}
+			if literals[i], err = formatLiteral(v); err != nil {
+				return nil, err
+			}
+		}
+		if len(literals) > 1 {
+			// A range on the first key column the engine can walk the index by
+			conds = append(conds, columns[0]+" "+b.op[:1]+"= "+literals[0])
+		}
+		conds = append(conds, tupleCondition(columns[:len(literals)], literals, b.op))
+	}
+
+	query := "USE INDEX(" + indexName + ")"
+	for i, c := range conds {
+		if i == 0 {
+			query += " WHERE " + c
+		} else {
+			query += " AND " + c
+		}
+	}
+	return t.Find(query, opts...)
+}
+
+// tupleCondition returns the condition comparing the key columns keys, as a
+// tuple, to literals with op: one of <, <=, > and >=. The engine's AND and
+// OR bind left to right, hence the parentheses.
+func tupleCondition(keys, literals []string, op string) string {
+	if len(keys) == 1 {
+		return keys[0] + " " + op + " " + literals[0]
+	}
+	return fmt.Sprintf("(%s %s %s OR (%s = %s AND %s))", keys[0], op[:1], literals[0], keys[0], literals[0], tupleCondition(keys[1:], literals[1:], op))
+}
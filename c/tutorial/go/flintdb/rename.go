@@ -0,0 +1,156 @@
+package flintdb
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// RenameTable moves the table at oldPath, its data, .desc, index, WAL and
+// side files, to newPath, which must not hold a table. Each file moves with
+// a rename, the data file last, and a failed rename moves the files already
+// renamed back, so the table ends up whole under one of the names. The table
+// must not be open, and the two paths must be on one file system.
+func RenameTable(oldPath, newPath string) error {
+	if err := checkDistinctTables(oldPath, newPath); err != nil {
+		return err
+	}
+	files, err := tableFiles(oldPath)
+	if err != nil {
+		return err
+	}
+	if !hasDataFile(files) {
+		return &FlintDBError{Message: fmt.Sprintf("table not found: %s", oldPath)}
+	}
+	if err := checkNoTable(newPath); err != nil {
+		return err
+	}
+	if err := moveFiles(tableMoves(oldPath, newPath, files)); err != nil {
+		return err
+	}
+	os.Remove(oldPath + lockSuffix)
+	return nil
+}
+
+// SwapTables exchanges the files of the tables at a and b, as for a
+// blue/green rebuild: build the new table beside the live one, swap them and
+// drop the old one. The files move through a temporary name next to a with
+// renames, undone in reverse if one fails; a swap stopped by a crash leaves
+// the files of a under that name, .swap- and a's file name, which makes the
+// next swap of a fail until they are moved back by hand. Neither table may
+// be open.
+func SwapTables(a, b string) error {
+	if err := checkDistinctTables(a, b); err != nil {
+		return err
+	}
+	filesA, err := tableFiles(a)
+	if err != nil {
+		return err
+	}
+	filesB, err := tableFiles(b)
+	if err != nil {
+		return err
+	}
+	for path, files := range map[string][]string{a: filesA, b: filesB} {
+		if !hasDataFile(files) {
+			return &FlintDBError{Message: fmt.Sprintf("table not found: %s", path)}
+		}
+	}
+	dir, base := filepath.Split(a)
+	tmp := filepath.Join(dir, ".swap-"+base)
+	if files, err := tableFiles(tmp); err != nil {
+		return err
+	} else if len(files) > 0 {
+		return &FlintDBError{Message: fmt.Sprintf("files of an interrupted swap remain: %s", tmp)}
+	}
+	var moves []fileMove
+	moves = append(moves, tableMoves(a, tmp, filesA)...)
+	moves = append(moves, tableMoves(b, a, filesB)...)
+	moves = append(moves, tableMoves(tmp, b, filesA)...)
+	return moveFiles(moves)
+}
+
+// tableFiles returns the suffixes after path of the names of the files of
+// the table at path: "" for the data file, then ".desc", ".i.primary" and so
+// on. The lock file of WithFileLock is not one of them.
+func tableFiles(path string) ([]string, error) {
+	dir, base := filepath.Split(path)
+	entries, err := os.ReadDir(filepath.Clean(dir))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var suffixes []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if name == base || strings.HasPrefix(name, base+".") && name != base+lockSuffix {
+			suffixes = append(suffixes, name[len(base):])
+		}
+	}
+	return suffixes, nil
+}
+
+func hasDataFile(suffixes []string) bool {
+	for _, s := range suffixes {
+		if s == "" {
+			return true
+		}
+	}
+	return false
+}
+
+func checkNoTable(path string) error {
+	files, err := tableFiles(path)
+	if err != nil {
+		return err
+	}
+	if len(files) > 0 {
+		return &FlintDBError{Message: fmt.Sprintf("table already exists: %s", path)}
+	}
+	return nil
+}
+
+// checkDistinctTables refuses two paths naming one table, or one whose files
+// the other's would take for its own, such as t.flintdb and t.flintdb.new.
+func checkDistinctTables(a, b string) error {
+	a, b = filepath.Clean(a), filepath.Clean(b)
+	if a == b || strings.HasPrefix(a, b+".") || strings.HasPrefix(b, a+".") {
+		return &FlintDBError{Message: fmt.Sprintf("table names overlap: %s and %s", a, b)}
+	}
+	return nil
+}
+
+type fileMove struct {
+	from, to string
+}
+
+// tableMoves returns the renames moving the files of the table at from to
+// to, the data file last.
+func tableMoves(from, to string, suffixes []string) []fileMove {
+	var moves []fileMove
+	for _, s := range suffixes {
+		if s != "" {
+			moves = append(moves, fileMove{from: from + s, to: to + s})
+		}
+	}
+	return append(moves, fileMove{from: from, to: to})
+}
+
+// moveFiles renames the files of moves in order. If one fails, those already
+// renamed are renamed back, newest first.
+func moveFiles(moves []fileMove) error {
+	for i, m := range moves {
+		if err := os.Rename(m.from, m.to); err != nil {
+			for k := i - 1; k >= 0; k-- {
+				os.Rename(moves[k].to, moves[k].from)
+			}
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,350 @@
+package flintdb
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// RotateOptions sets when a RotatingTable starts a new generation: before an
+// insert into a generation holding MaxRows rows, or whose files have reached
+// MaxBytes. Zero means no limit. Sizes are measured as for WithMaxFileSize,
+// so MaxBytes should be well above the megabytes an empty table takes; a
+// generation always gets at least one row.
+type RotateOptions struct {
+	MaxRows  int64
+	MaxBytes int64
+}
+
+// RotatingTable appends rows to the newest of a series of table files, its
+// generations, named with a number counted from 1, such as
+// events.000003.flintdb, and reads across all of them as one table. Its
+// rowids encode the generation, as those of a PartitionedTable encode the
+// period.
+type RotatingTable struct {
+	path string
+	mode uint32
+	meta *Meta
+	opts RotateOptions
+
+	mu    sync.Mutex
+	gens  map[int64]*Table // generation number -> table
+	last  int64            // the newest generation, 0 with none
+	rows  int64            // of the newest generation
+	bytes *fileQuota       // of the newest generation, with MaxBytes
+}
+
+// RotatingTableOpen opens the generations of path found on disk. The first
+// generation is created from meta by the first insert.
+func RotatingTableOpen(path string, mode uint32, meta *Meta, opts RotateOptions) (*RotatingTable, error) {
+	if opts.MaxRows < 0 || opts.MaxBytes < 0 {
+		return nil, &FlintDBError{Message: "invalid rotation thresholds"}
+	}
+	r := &RotatingTable{path: path, mode: mode, meta: meta, opts: opts, gens: map[int64]*Table{}}
+	files, err := filepath.Glob(strings.TrimSuffix(path, TABLE_NAME_SUFFIX) + ".*" + TABLE_NAME_SUFFIX)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		n, ok := generationOfFile(file)
+		if !ok {
+			continue
+		}
+		t, err := TableOpen(file, mode, nil)
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+		r.gens[n] = t
+		r.last = max(r.last, n)
+	}
+	if r.last > 0 && mode == FLINTDB_RDWR {
+		if err := r.track(r.gens[r.last]); err != nil {
+			r.Close()
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+func generationOfFile(file string) (int64, bool) {
+	name := strings.TrimSuffix(file, TABLE_NAME_SUFFIX)
+	name = name[strings.LastIndexByte(name, '.')+1:]
+	if len(name) != 6 {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(name, 10, 64)
+	return n, err == nil && n > 0
+}
+
+func (r *RotatingTable) generationPath(n int64) string {
+	return fmt.Sprintf("%s.%06d%s", strings.TrimSuffix(r.path, TABLE_NAME_SUFFIX), n, TABLE_NAME_SUFFIX)
+}
+
+// track starts counting the rows and bytes of t, the newest generation.
+func (r *RotatingTable) track(t *Table) error {
+	rows, err := t.Rows()
+	if err != nil {
+		return err
+	}
+	r.rows, r.bytes = rows, nil
+	if r.opts.MaxBytes > 0 {
+		r.bytes, err = newFileQuota(t.path, r.opts.MaxBytes)
+	}
+	return err
+}
+
+func (r *RotatingTable) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, t := range r.gens {
+		t.Close()
+	}
+	r.gens = map[int64]*Table{}
+}
+
+// Ping pings each generation, as Table.Ping does.
+func (r *RotatingTable) Ping() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, t := range r.gens {
+		if err := t.Ping(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Generations returns the paths of the generations, oldest first.
+func (r *RotatingTable) Generations() []string {
+	var paths []string
+	for _, n := range r.generations() {
+		paths = append(paths, r.generationPath(n))
+	}
+	return paths
+}
+
+func (r *RotatingTable) generations() []int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	gens := make([]int64, 0, len(r.gens))
+	for n := range r.gens {
+		gens = append(gens, n)
+	}
+	sort.Slice(gens, func(i, j int) bool { return gens[i] < gens[j] })
+	return gens
+}
+
+func (r *RotatingTable) generation(n int64) *Table {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.gens[n]
+}
+
+// CreateRow returns a row for Insert, creating the first generation if there
+// is none yet.
+func (r *RotatingTable) CreateRow() (*Row, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t := r.gens[r.last]
+	if t == nil {
+		var err error
+		if t, err = r.rotate(); err != nil {
+			return nil, err
+		}
+	}
+	return t.CreateRow()
+}
+
+// rotate creates the generation after the newest, with r.mu held.
+func (r *RotatingTable) rotate() (*Table, error) {
+	if r.mode != FLINTDB_RDWR || r.meta == nil {
+		return nil, &FlintDBError{Message: "rotating table is read-only or has no meta"}
+	}
+	t, err := TableOpen(r.generationPath(r.last+1), r.mode, r.meta)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.track(t); err != nil {
+		t.Close()
+		return nil, err
+	}
+	r.last++
+	r.gens[r.last] = t
+	return t, nil
+}
+
+// full reports whether the newest generation has reached a threshold.
+func (r *RotatingTable) full() (bool, error) {
+	if r.opts.MaxRows > 0 && r.rows >= r.opts.MaxRows {
+		return true, nil
+	}
+	if r.rows == 0 {
+		return false, nil // else files over MaxBytes when empty would rotate on every insert
+	}
+	if err := r.bytes.admit(); err != nil {
+		if errors.Is(err, ErrQuotaExceeded) {
+			return true, nil
+		}
+		return false, err
+	}
+	return false, nil
+}
+
+// Rotate starts a new generation for the inserts that follow, whatever the
+// thresholds.
+func (r *RotatingTable) Rotate() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, err := r.rotate()
+	return err
+}
+
+// Insert stores row in the newest generation, first starting a new one if
+// that has reached a threshold, and returns a rowid valid across
+// generations.
+func (r *RotatingTable) Insert(row *Row) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t := r.gens[r.last]
+	full := t == nil
+	if !full {
+		var err error
+		if full, err = r.full(); err != nil {
+			return -1, err
+		}
+	}
+	if full {
+		var err error
+		if t, err = r.rotate(); err != nil {
+			return -1, err
+		}
+	}
+	rowid, err := t.Insert(row)
+	if err != nil {
+		return -1, err
+	}
+	r.rows++
+	return r.rowID(r.last, rowid), nil
+}
+
+func (r *RotatingTable) rowID(n int64, rowid int64) int64 {
+	return n<<partitionShift | rowid
+}
+
+func (r *RotatingTable) localID(rowid int64) (*Table, int64, error) {
+	if rowid < 0 {
+		return nil, -1, &FlintDBError{Message: fmt.Sprintf("invalid rowid: %d", rowid)}
+	}
+	t := r.generation(rowid >> partitionShift)
+	if t == nil {
+		return nil, -1, &FlintDBError{Message: "row not found"}
+	}
+	return t, rowid & (1<<partitionShift - 1), nil
+}
+
+func (r *RotatingTable) Read(rowid int64) (*Row, error) {
+	t, local, err := r.localID(rowid)
+	if err != nil {
+		return nil, err
+	}
+	return t.Read(local)
+}
+
+func (r *RotatingTable) UpdateAt(rowid int64, row *Row) error {
+	t, local, err := r.localID(rowid)
+	if err != nil {
+		return err
+	}
+	return t.UpdateAt(local, row)
+}
+
+func (r *RotatingTable) DeleteAt(rowid int64) error {
+	t, local, err := r.localID(rowid)
+	if err != nil {
+		return err
+	}
+	return t.DeleteAt(local)
+}
+
+func (r *RotatingTable) Rows() (int64, error) {
+	var total int64
+	for _, n := range r.generations() {
+		if t := r.generation(n); t != nil {
+			rows, err := t.Rows()
+			if err != nil {
+				return -1, err
+			}
+			total += rows
+		}
+	}
+	return total, nil
+}
+
+// Find runs query on every generation, oldest first, so rows come in the
+// order they were appended when the query keeps each file's order.
+func (r *RotatingTable) Find(query string) (*CursorInt64, error) {
+	rest, offset, limit := splitLimit(query)
+	if limit >= 0 {
+		rest += fmt.Sprintf(" LIMIT %d", offset+limit)
+	}
+	var rows []int64
+	for _, n := range r.generations() {
+		if limit >= 0 && len(rows) >= offset+limit {
+			break
+		}
+		t := r.generation(n)
+		if t == nil {
+			continue
+		}
+		cursor, err := t.Find(rest)
+		if err != nil {
+			return nil, err
+		}
+		for {
+			rowid, err := cursor.Next()
+			if err != nil {
+				cursor.Close()
+				return nil, err
+			}
+			if rowid < 0 {
+				break
+			}
+			rows = append(rows, r.rowID(n, rowid))
+		}
+		cursor.Close()
+	}
+	if offset > len(rows) {
+		offset = len(rows)
+	}
+	rows = rows[offset:]
+	if limit >= 0 && limit < len(rows) {
+		rows = rows[:limit]
+	}
+	return &CursorInt64{rows: rows}, nil
+}
+
+// DropOldest removes all but the newest keep generations and returns how
+// many were dropped.
+func (r *RotatingTable) DropOldest(keep int) (int, error) {
+	if r.mode != FLINTDB_RDWR {
+		return 0, &FlintDBError{Message: "rotating table is read-only"}
+	}
+	gens := r.generations()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	dropped := 0
+	for _, n := range gens[:max(0, len(gens)-max(keep, 0))] {
+		if t := r.gens[n]; t != nil {
+			t.Close()
+			delete(r.gens, n)
+			TableDrop(r.generationPath(n))
+			dropped++
+		}
+	}
+	return dropped, nil
+}
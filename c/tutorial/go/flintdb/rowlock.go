@@ -0,0 +1,60 @@
+package flintdb
+
+import (
+	"fmt"
+	"sync"
+)
+
+// rowLocks holds the row locks of a table, each kept only while it is held
+// or waited for.
+type rowLocks struct {
+	mu   sync.Mutex
+	rows map[int64]*rowLock
+}
+
+type rowLock struct {
+	sync.Mutex
+	refs int
+}
+
+// LockRow takes the lock of the row at rowid, waiting while another
+// goroutine holds it, for a read-modify-write of the row such as Read, change
+// and UpdateAt. Row locks are advisory: they only exclude other LockRow calls
+// on the same Table, and its methods still need one goroutine at a time.
+func (t *Table) LockRow(rowid int64) {
+	t.rowLocks.mu.Lock()
+	if t.rowLocks.rows == nil {
+		t.rowLocks.rows = map[int64]*rowLock{}
+	}
+	l := t.rowLocks.rows[rowid]
+	if l == nil {
+		l = &rowLock{}
+		t.rowLocks.rows[rowid] = l
+	}
+	l.refs++
+	t.rowLocks.mu.Unlock()
+	l.Lock()
+}
+
+// UnlockRow releases the lock LockRow took on rowid. It panics if the row is
+// not locked.
+func (t *Table) UnlockRow(rowid int64) {
+	t.rowLocks.mu.Lock()
+	defer t.rowLocks.mu.Unlock()
+	l := t.rowLocks.rows[rowid]
+	if l == nil {
+		panic(fmt.Sprintf("flintdb: unlock of unlocked row %d", rowid))
+	}
+	if l.refs--; l.refs == 0 {
+		delete(t.rowLocks.rows, rowid)
+	}
+	l.Unlock()
+}
+
+// WithRowLock calls fn holding the lock of the row at rowid and returns its
+// error. The lock is released even if fn panics.
+func (t *Table) WithRowLock(rowid int64, fn func() error) error {
+	t.LockRow(rowid)
+	defer t.UnlockRow(rowid)
+	return fn()
+}
@@ -0,0 +1,53 @@
+package flintdb
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+)
+
+// Sample returns up to n rows drawn uniformly at random from those query
+// finds, or from the whole table for query "", in rowid order. It reservoir
+// samples the rowids of one pass of the cursor and reads only the rows
+// drawn, so a preview of a huge table decodes n rows. The rows are freed by
+// the caller.
+func (t *Table) Sample(query string, n int) ([]*Row, error) {
+	if n <= 0 {
+		return nil, &FlintDBError{Message: fmt.Sprintf("sample size must be positive, got %d", n)}
+	}
+	cursor, err := t.Find(query)
+	if err != nil {
+		return nil, err
+	}
+	reservoir := make([]int64, 0, n)
+	for seen := int64(0); ; seen++ {
+		rowid, err := cursor.Next()
+		if err != nil {
+			cursor.Close()
+			return nil, err
+		}
+		if rowid < 0 {
+			break
+		}
+		if len(reservoir) < n {
+			reservoir = append(reservoir, rowid)
+		} else if j := rand.Int63n(seen + 1); j < int64(n) {
+			reservoir[j] = rowid
+		}
+	}
+	cursor.Close()
+
+	sort.Slice(reservoir, func(i, j int) bool { return reservoir[i] < reservoir[j] })
+	rows := make([]*Row, 0, len(reservoir))
+	for _, rowid := range reservoir {
+		row, err := t.Read(rowid)
+		if err != nil {
+			for _, r := range rows {
+				r.Free()
+			}
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
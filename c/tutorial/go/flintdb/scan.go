@@ -0,0 +1,113 @@
+package flintdb
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// ForEach calls fn with each row query finds, in the order Find returns
+// them, and frees the row after the call, so fn must copy what it keeps. The
+// first error returned by fn, or the cancellation of ctx, stops the scan and
+// is returned; the cursor is closed either way.
+func (t *Table) ForEach(ctx context.Context, query string, fn func(rowid int64, row *Row) error, opts ...QueryOption) error {
+	cursor, err := t.FindContext(ctx, query, opts...)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close()
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rowid, err := cursor.Next()
+		if err != nil || rowid < 0 {
+			return err
+		}
+		row, err := t.Read(rowid)
+		if err != nil {
+			return err
+		}
+		err = fn(rowid, row)
+		row.Free()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// ScanParallel calls fn for every row of the table, splitting the rowids
+// into parts contiguous ranges read by concurrent workers, each through its
+// own read-only handle. fn is called from several goroutines and the row is
+// valid only during the call. The first error returned by fn, or the
+// cancellation of ctx, stops the scan.
+func (t *Table) ScanParallel(ctx context.Context, parts int, fn func(*Row) error) error {
+	if parts < 1 {
+		parts = 1
+	}
+	cursor, err := t.Find("")
+	if err != nil {
+		return err
+	}
+	var rowids []int64
+	for {
+		rowid, err := cursor.Next()
+		if err != nil {
+			cursor.Close()
+			return err
+		}
+		if rowid < 0 {
+			break
+		}
+		rowids = append(rowids, rowid)
+	}
+	cursor.Close()
+	sort.Slice(rowids, func(i, j int) bool { return rowids[i] < rowids[j] })
+	if parts > len(rowids) {
+		parts = len(rowids)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	errs := make([]error, parts)
+	var wg sync.WaitGroup
+	for i := 0; i < parts; i++ {
+		lo, hi := len(rowids)*i/parts, len(rowids)*(i+1)/parts
+		wg.Add(1)
+		go func(i int, rowids []int64) {
+			defer wg.Done()
+			if errs[i] = t.scanRange(ctx, rowids, fn); errs[i] != nil {
+				cancel()
+			}
+		}(i, rowids[lo:hi])
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil && err != context.Canceled {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+func (t *Table) scanRange(ctx context.Context, rowids []int64, fn func(*Row) error) error {
+	handle, err := t.openReader()
+	if err != nil {
+		return err
+	}
+	defer handle.Close()
+	for _, rowid := range rowids {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		row, err := handle.Read(rowid)
+		if err != nil {
+			return err
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package flintdb
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// Policies for TableOpenChecked: how far the stored schema of a table may
+// differ from the one expected. The ALLOW_ policies combine with |.
+const (
+	SCHEMA_STRICT                 = 0 // the same columns in the same order, and the same indexes
+	SCHEMA_ALLOW_EXTRA_COLUMNS    = 1 // the table may have columns and indexes not expected
+	SCHEMA_ALLOW_MISSING_NULLABLE = 2 // the table may lack expected columns that are nullable
+)
+
+// ErrSchemaMismatch matches every SchemaError with errors.Is.
+var ErrSchemaMismatch = &FlintDBError{Message: "schema does not match"}
+
+// SchemaError lists how the stored schema of a table differs from the one
+// TableOpenChecked expected, one difference an entry.
+type SchemaError struct {
+	Path        string
+	Differences []string
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("FlintDB error: schema of %s does not match: %s", e.Path, strings.Join(e.Differences, "; "))
+}
+
+func (e *SchemaError) Is(target error) bool {
+	return target == ErrSchemaMismatch
+}
+
+// TableOpenChecked opens the table at path after comparing its stored schema
+// with expected under policy, failing with a SchemaError that names every
+// difference instead of opening a table whose rows would be read with the
+// wrong layout. Columns are matched by name, ignoring case; whatever the
+// policy, a column found in both must have the same type, precision and size
+// of a STRING or BYTES value. A table that passes is opened with its own
+// schema, so columns it lacks are not found by the ByName accessors. A table
+// that does not exist yet is created from expected in RDWR mode, as
+// TableOpen does.
+func TableOpenChecked(path string, mode uint32, expected *Meta, policy int, opts ...OpenOption) (*Table, error) {
+	if expected == nil {
+		return nil, &FlintDBError{Message: "expected meta is nil"}
+	}
+	desc, err := os.ReadFile(path + META_NAME_SUFFIX)
+	if errors.Is(err, fs.ErrNotExist) {
+		return TableOpen(path, mode, expected, opts...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	stored, err := parseMeta(string(desc))
+	if err != nil {
+		return nil, err
+	}
+	defer stored.Close()
+	if diffs := schemaDifferences(stored, expected, policy); len(diffs) > 0 {
+		return nil, &SchemaError{Path: path, Differences: diffs}
+	}
+	return TableOpen(path, mode, nil, opts...)
+}
+
+// schemaDifferences describes how stored differs from expected beyond what
+// policy allows.
+func schemaDifferences(stored, expected *Meta, policy int) []string {
+	var diffs []string
+	columnOf := func(m *Meta, name string) int {
+		for i := 0; i < int(m.inner.columns.length); i++ {
+			if strings.EqualFold(cstring(m.inner.columns.a[i].name[:]), name) {
+				return i
+			}
+		}
+		return -1
+	}
+	for i := 0; i < int(expected.inner.columns.length); i++ {
+		want := &expected.inner.columns.a[i]
+		name := cstring(want.name[:])
+		k := columnOf(stored, name)
+		if k < 0 {
+			if policy&SCHEMA_ALLOW_MISSING_NULLABLE == 0 || want.nullspec == SPEC_NOT_NULL {
+				diffs = append(diffs, fmt.Sprintf("column %s is missing", name))
+			}
+			continue
+		}
+		have := &stored.inner.columns.a[k]
+		switch {
+		case have._type != want._type:
+			diffs = append(diffs, fmt.Sprintf("column %s is %s, expected %s", name, variantName(int(have._type)), variantName(int(want._type))))
+		case (want._type == VARIANT_STRING || want._type == VARIANT_BYTES) && have.bytes != want.bytes:
+			diffs = append(diffs, fmt.Sprintf("column %s has size %d, expected %d", name, have.bytes, want.bytes))
+		case max(have.precision, 0) != max(want.precision, 0):
+			diffs = append(diffs, fmt.Sprintf("column %s has precision %d, expected %d", name, max(have.precision, 0), max(want.precision, 0)))
+		case policy == SCHEMA_STRICT && k != i:
+			diffs = append(diffs, fmt.Sprintf("column %s is column %d, expected %d", name, k+1, i+1))
+		}
+	}
+	if policy&SCHEMA_ALLOW_EXTRA_COLUMNS == 0 {
+		for i := 0; i < int(stored.inner.columns.length); i++ {
+			if name := cstring(stored.inner.columns.a[i].name[:]); columnOf(expected, name) < 0 {
+				diffs = append(diffs, fmt.Sprintf("column %s is not expected", name))
+			}
+		}
+	}
+
+	indexKeys := func(m *Meta) map[string]string {
+		keys := map[string]string{}
+		for i := 0; i < int(m.inner.indexes.length); i++ {
+			index := &m.inner.indexes.a[i]
+			var columns []string
+			for k := 0; k < int(index.keys.length); k++ {
+				columns = append(columns, strings.ToLower(cstring(index.keys.a[k][:])))
+			}
+			keys[strings.ToLower(cstring(index.name[:]))] = strings.Join(columns, ", ")
+		}
+		return keys
+	}
+	have, want := indexKeys(stored), indexKeys(expected)
+	for i := 0; i < int(expected.inner.indexes.length); i++ {
+		name := cstring(expected.inner.indexes.a[i].name[:])
+		key := strings.ToLower(name)
+		if columns, ok := have[key]; !ok {
+			diffs = append(diffs, fmt.Sprintf("index %s is missing", name))
+		} else if columns != want[key] {
+			diffs = append(diffs, fmt.Sprintf("index %s is on (%s), expected (%s)", name, columns, want[key]))
+		}
+	}
+	if policy&SCHEMA_ALLOW_EXTRA_COLUMNS == 0 {
+		for i := 0; i < int(stored.inner.indexes.length); i++ {
+			if name := cstring(stored.inner.indexes.a[i].name[:]); want[strings.ToLower(name)] == "" {
+				diffs = append(diffs, fmt.Sprintf("index %s is not expected", name))
+			}
+		}
+	}
+	return diffs
+}
+
+func variantName(kind int) string {
+	switch kind {
+	case VARIANT_INT32:
+		return "INT"
+	case VARIANT_INT64:
+		return "INT64"
+	case VARIANT_STRING:
+		return "STRING"
+	case VARIANT_DOUBLE:
+		return "DOUBLE"
+	case VARIANT_FLOAT:
+		return "FLOAT"
+	case VARIANT_DATE:
+		return "DATE"
+	case VARIANT_TIME:
+		return "TIME"
+	case VARIANT_BYTES:
+		return "BYTES"
+	}
+	return fmt.Sprintf("type %d", kind)
+}
@@ -0,0 +1,226 @@
+// Package seed fills tables with generated rows for benchmarks and
+// reproducible test fixtures: the same Options give the same rows.
+//
+//	err := seed.Rows(table, 10000, seed.Options{Seed: 1, Unique: []string{"email"}})
+package seed
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+
+	flintdb "flintdb-tutorial/flintdb"
+)
+
+// Options configures Rows.
+type Options struct {
+	Seed int64 // of the random number generator
+	// Unique lists columns, besides those of the primary key, whose values
+	// no two generated rows share.
+	Unique []string
+	// NullRate is the fraction of NULLs in nullable columns other than key
+	// and Unique columns.
+	NullRate float64
+}
+
+var (
+	firstNames = []string{"James", "Mary", "John", "Patricia", "Robert", "Jennifer", "Michael", "Linda", "David", "Elizabeth", "Wei", "Yuki", "Minjun", "Sofia", "Lucas", "Amara", "Mateo", "Aisha", "Noah", "Olga"}
+	lastNames  = []string{"Smith", "Johnson", "Williams", "Brown", "Jones", "Garcia", "Miller", "Davis", "Kim", "Lee", "Park", "Chen", "Tanaka", "Silva", "Rossi", "Novak", "Okafor", "Haddad", "Larsen", "Kowalski"}
+	cities     = []string{"New York", "London", "Tokyo", "Seoul", "Paris", "Berlin", "Sydney", "Toronto", "Madrid", "Singapore", "Mumbai", "Sao Paulo", "Cairo", "Lagos", "Stockholm", "Dublin"}
+	countries  = []string{"US", "GB", "JP", "KR", "FR", "DE", "AU", "CA", "ES", "SG", "IN", "BR", "EG", "NG", "SE", "IE"}
+	statuses   = []string{"active", "inactive", "pending", "suspended"}
+	words      = []string{"alpha", "bravo", "cedar", "delta", "ember", "flint", "grove", "harbor", "iris", "juniper", "kestrel", "lumen", "meadow", "nova", "orbit", "pine", "quartz", "river", "sierra", "tundra"}
+)
+
+// epoch is the earliest generated date or time.
+var epoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Rows inserts n generated rows into t. Values fit their column types and
+// look like real data where the column name suggests what it holds, such as
+// name, email, city, country, phone, status, age, price or lat and lon.
+// Primary key and Unique columns get values derived from the row's number,
+// counted on from the rows t already has.
+func Rows(t *flintdb.Table, n int, opts Options) error {
+	columns := t.ColumnTypes()
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = c.Name
+	}
+	plan, err := t.Explain("", names...)
+	if err != nil {
+		return err
+	}
+	keys := append(append([]string(nil), plan.Keys...), opts.Unique...)
+	unique := make([]bool, len(columns))
+	for i, c := range columns {
+		for _, u := range keys {
+			unique[i] = unique[i] || strings.EqualFold(c.Name, u)
+		}
+	}
+	first, err := t.Rows()
+	if err != nil {
+		return err
+	}
+
+	g := &generator{rng: rand.New(rand.NewSource(opts.Seed))}
+	for r := 0; r < n; r++ {
+		if err := g.insert(t, columns, unique, first+int64(r)+1, opts.NullRate); err != nil {
+			return fmt.Errorf("row %d: %w", r, err)
+		}
+	}
+	return nil
+}
+
+// Value returns a random value for c drawn from rng, as Rows generates the
+// values of columns that are not keys.
+func Value(rng *rand.Rand, c flintdb.Column) interface{} {
+	return (&generator{rng: rng}).value(c)
+}
+
+type generator struct {
+	rng *rand.Rand
+}
+
+// insert inserts the row numbered ordinal.
+func (g *generator) insert(t *flintdb.Table, columns []flintdb.Column, unique []bool, ordinal int64, nullRate float64) error {
+	row, err := t.CreateRow()
+	if err != nil {
+		return err
+	}
+	defer row.Free()
+	for i, c := range columns {
+		var v interface{}
+		if unique[i] {
+			v = g.unique(c, ordinal)
+		} else if !c.NotNull && nullRate > 0 && g.rng.Float64() < nullRate {
+			v = nil
+		} else {
+			v = g.value(c)
+		}
+		if err := row.Set(i, v); err != nil {
+			return fmt.Errorf("column %s: %w", c.Name, err)
+		}
+	}
+	_, err = t.Insert(row)
+	return err
+}
+
+func (g *generator) pick(list []string) string {
+	return list[g.rng.Intn(len(list))]
+}
+
+// value returns a random value for c.
+func (g *generator) value(c flintdb.Column) interface{} {
+	name := strings.ToLower(c.Name)
+	switch c.Type {
+	case flintdb.VARIANT_INT32, flintdb.VARIANT_INT64:
+		switch {
+		case strings.Contains(name, "age"):
+			return int64(18 + g.rng.Intn(70))
+		case strings.Contains(name, "year"):
+			return int64(1990 + g.rng.Intn(36))
+		case strings.Contains(name, "count"), strings.Contains(name, "qty"), strings.Contains(name, "quantity"):
+			return int64(g.rng.Intn(100))
+		case c.Type == flintdb.VARIANT_INT32:
+			return int64(g.rng.Intn(100000))
+		}
+		return g.rng.Int63n(1000000)
+	case flintdb.VARIANT_DOUBLE, flintdb.VARIANT_FLOAT:
+		switch {
+		case strings.Contains(name, "lat"):
+			return round(g.rng.Float64()*180-90, 6)
+		case strings.Contains(name, "lon"), strings.Contains(name, "lng"):
+			return round(g.rng.Float64()*360-180, 6)
+		}
+		return round(g.rng.Float64()*1000, 2)
+	case flintdb.VARIANT_STRING:
+		return fit(g.text(name), "", c.Size)
+	case flintdb.VARIANT_DATE:
+		return epoch.AddDate(0, 0, g.rng.Intn(6*365))
+	case flintdb.VARIANT_TIME:
+		return epoch.Add(time.Duration(g.rng.Int63n(6*365*24*3600)) * time.Second)
+	case flintdb.VARIANT_BYTES:
+		b := make([]byte, byteLength(c))
+		g.rng.Read(b)
+		return b
+	}
+	return g.rng.Int63n(1000000)
+}
+
+// unique returns the value of c for the row numbered ordinal.
+func (g *generator) unique(c flintdb.Column, ordinal int64) interface{} {
+	switch c.Type {
+	case flintdb.VARIANT_DOUBLE, flintdb.VARIANT_FLOAT:
+		return float64(ordinal)
+	case flintdb.VARIANT_STRING:
+		name := strings.ToLower(c.Name)
+		if strings.Contains(name, "email") {
+			return fit(strings.ToLower(g.pick(firstNames)+"."+g.pick(lastNames)), fmt.Sprintf("%d@example.com", ordinal), c.Size)
+		}
+		return fit(g.text(name), fmt.Sprintf("-%d", ordinal), c.Size)
+	case flintdb.VARIANT_DATE:
+		return epoch.AddDate(0, 0, int(ordinal))
+	case flintdb.VARIANT_TIME:
+		return epoch.Add(time.Duration(ordinal) * time.Second)
+	case flintdb.VARIANT_BYTES:
+		b := make([]byte, byteLength(c))
+		g.rng.Read(b)
+		var key [8]byte
+		binary.BigEndian.PutUint64(key[:], uint64(ordinal))
+		copy(b, key[8-min(8, len(b)):])
+		return b
+	}
+	return ordinal
+}
+
+// text returns a string suited to a column named name.
+func (g *generator) text(name string) string {
+	switch {
+	case strings.Contains(name, "email"):
+		return strings.ToLower(g.pick(firstNames)+"."+g.pick(lastNames)) + "@example.com"
+	case strings.Contains(name, "first"):
+		return g.pick(firstNames)
+	case strings.Contains(name, "last"), strings.Contains(name, "surname"):
+		return g.pick(lastNames)
+	case strings.Contains(name, "name"):
+		return g.pick(firstNames) + " " + g.pick(lastNames)
+	case strings.Contains(name, "city"):
+		return g.pick(cities)
+	case strings.Contains(name, "country"):
+		return g.pick(countries)
+	case strings.Contains(name, "phone"):
+		return fmt.Sprintf("+1-555-%03d-%04d", g.rng.Intn(1000), g.rng.Intn(10000))
+	case strings.Contains(name, "status"):
+		return g.pick(statuses)
+	}
+	n := 1 + g.rng.Intn(3)
+	parts := make([]string, n)
+	for i := range parts {
+		parts[i] = g.pick(words)
+	}
+	return strings.Join(parts, " ")
+}
+
+// fit cuts s so that s followed by suffix fits in size bytes, if size is
+// set; the suffix, which makes a value unique, is kept whole.
+func fit(s, suffix string, size int) string {
+	if size > 0 && len(s)+len(suffix) > size {
+		s = s[:max(0, size-len(suffix))]
+	}
+	return s + suffix
+}
+
+func byteLength(c flintdb.Column) int {
+	if c.Size > 0 && c.Size < 16 {
+		return c.Size
+	}
+	return 16
+}
+
+func round(f float64, digits int) float64 {
+	p := math.Pow(10, float64(digits))
+	return math.Round(f*p) / p
+}
@@ -0,0 +1,175 @@
+package flintdb
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Plan describes how Table.Find and Table.Select run a query.
+type Plan struct {
+	Index      string   // index walked to find the rows
+	Algorithm  string   // INDEX_BPTREE or INDEX_HASH
+	Keys       []string // key columns of Index
+	Descending bool
+	// Covering is set when Keys include every column the query filters on or
+	// returns. Index entries hold rowids rather than key values, so matched
+	// rows are still read from the data file.
+	Covering bool
+	// Estimate is how many rows the query is expected to find, from the
+	// statistics of Table.Analyze, or -1 if the table has none.
+	Estimate int64
+}
+
+// Explain returns the plan for query, with columns being the ones Select
+// would return.
+func (t *Table) Explain(query string, columns ...string) (*Plan, error) {
+	if _, err := t.projection(columns); err != nil {
+		return nil, err
+	}
+	query = t.rewriteCollated(query)
+	tokens, err := tokenizeExpr(query)
+	if err != nil {
+		return nil, err
+	}
+
+	_, _, x, err := t.hashLookup(query)
+	if err != nil {
+		return nil, err
+	}
+	var plan *Plan
+	if x != nil {
+		plan = &Plan{Index: x.name, Algorithm: INDEX_HASH, Keys: append([]string(nil), x.names...)}
+	} else {
+		plan = t.enginePlan(tokens)
+	}
+
+	used := append([]string(nil), columns...)
+	where := false
+	for _, tok := range tokens {
+		if tok.kind != tokenIdent {
+			continue
+		}
+		if !where {
+			where = strings.EqualFold(tok.text, "WHERE")
+		} else if t.columnAt(tok.text) >= 0 {
+			used = append(used, tok.text)
+		}
+	}
+	plan.Covering = true
+	for _, col := range used {
+		if !containsFold(plan.Keys, col) {
+			plan.Covering = false
+			break
+		}
+	}
+	plan.Estimate = -1
+	stats, err := t.Stats()
+	if err != nil {
+		return nil, err
+	}
+	if stats != nil {
+		plan.Estimate = stats.estimate(query)
+	}
+	return plan, nil
+}
+
+// enginePlan mirrors the engine's choice of index: the one named by
+// USE INDEX, or the primary index when there is no hint or it names no index.
+func (t *Table) enginePlan(tokens []exprToken) *Plan {
+	plan := &Plan{}
+	ordinal := 0
+	p := &exprParser{tokens: tokens}
+	if p.keyword("USE") && p.keyword("INDEX") && p.symbol("(") != "" {
+		if name := p.peek(); name != nil && name.kind == tokenIdent {
+			for i := 0; i < int(t.meta.indexes.length); i++ {
+				if strings.EqualFold(cstring(t.meta.indexes.a[i].name[:]), name.text) {
+					ordinal = i
+				}
+			}
+			p.pos++
+			plan.Descending = p.keyword("DESC")
+		}
+	}
+	if int(t.meta.indexes.length) == 0 {
+		return plan
+	}
+
+	index := &t.meta.indexes.a[ordinal]
+	plan.Index = cstring(index.name[:])
+	plan.Algorithm = cstring(index.algorithm[:])
+	if plan.Algorithm == "" {
+		plan.Algorithm = INDEX_BPTREE
+	}
+	for k := 0; k < int(index.keys.length); k++ {
+		plan.Keys = append(plan.Keys, cstring(index.keys.a[k][:]))
+	}
+	return plan
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// CursorValues iterates over the projected values of the rows matched by
+// Table.Select.
+type CursorValues struct {
+	table   *Table
+	rows    *CursorInt64
+	columns []int
+}
+
+// Select finds the rows matching query like Find and returns the values of
+// the given columns for each.
+func (t *Table) Select(query string, columns ...string) (*CursorValues, error) {
+	indexes, err := t.projection(columns)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := t.Find(query)
+	if err != nil {
+		return nil, err
+	}
+	return &CursorValues{table: t, rows: rows, columns: indexes}, nil
+}
+
+func (t *Table) projection(columns []string) ([]int, error) {
+	if len(columns) == 0 {
+		return nil, &FlintDBError{Message: "select needs at least one column"}
+	}
+	indexes := make([]int, len(columns))
+	for i, col := range columns {
+		indexes[i] = t.columnAt(col)
+		if indexes[i] < 0 {
+			return nil, &FlintDBError{Message: fmt.Sprintf("column not found: %s", col)}
+		}
+	}
+	return indexes, nil
+}
+
+// Next returns the values of the next row, or nil after the last one.
+func (c *CursorValues) Next() ([]interface{}, error) {
+	rowid, err := c.rows.Next()
+	if err != nil || rowid < 0 {
+		return nil, err
+	}
+	row, err := c.table.Read(rowid)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]interface{}, len(c.columns))
+	for i, col := range c.columns {
+		if values[i], err = row.Get(col); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+func (c *CursorValues) Close() {
+	c.rows.Close()
+}
@@ -0,0 +1,436 @@
+package flintdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ShardOptions selects how ShardedTableOpen spreads rows over its files:
+// by a hash of Column into Shards files, or, when Bounds is set, by ranges
+// of Column, file i holding keys in [Bounds[i-1], Bounds[i]).
+type ShardOptions struct {
+	Column string
+	Shards int
+	Bounds []interface{}
+}
+
+const shardConfigSuffix = ".shards.json"
+
+type shardConfig struct {
+	Column string        `json:"column"`
+	Shards int           `json:"shards"`
+	Bounds []interface{} `json:"bounds,omitempty"`
+}
+
+// ShardedTable spreads the rows of one logical table over several table
+// files by a key column. Its rowids encode the shard, so Read, UpdateAt and
+// DeleteAt go straight to the right file.
+type ShardedTable struct {
+	shards []*Table
+	config shardConfig
+	column int
+}
+
+// shardPath names shard i of path, keeping the table file suffix the engine
+// expects.
+func shardPath(path string, i int) string {
+	base := strings.TrimSuffix(path, TABLE_NAME_SUFFIX)
+	return fmt.Sprintf("%s.%d%s", base, i, TABLE_NAME_SUFFIX)
+}
+
+func readShardConfig(path string) (shardConfig, bool, error) {
+	var c shardConfig
+	data, err := os.ReadFile(path + shardConfigSuffix)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, false, nil
+	}
+	if err != nil {
+		return c, false, err
+	}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(&c); err != nil {
+		return c, false, &FlintDBError{Message: fmt.Sprintf("invalid %s%s: %v", path, shardConfigSuffix, err)}
+	}
+	for i, b := range c.Bounds {
+		if n, ok := b.(json.Number); ok {
+			if v, err := strconv.ParseInt(string(n), 10, 64); err == nil {
+				c.Bounds[i] = v
+			} else if v, err := n.Float64(); err == nil {
+				c.Bounds[i] = v
+			}
+		}
+	}
+	return c, true, nil
+}
+
+// ShardedTableOpen opens the shards of path, creating them from meta in
+// RDWR mode. The sharding is stored with the table; reopening needs only a
+// zero ShardOptions.
+func ShardedTableOpen(path string, mode uint32, meta *Meta, opts ShardOptions) (*ShardedTable, error) {
+	stored, found, err := readShardConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	config := stored
+	if opts.Column != "" {
+		config = shardConfig{Column: opts.Column, Shards: opts.Shards}
+		if len(opts.Bounds) > 0 {
+			config.Shards = len(opts.Bounds) + 1
+		}
+		for _, b := range opts.Bounds {
+			config.Bounds = append(config.Bounds, shardBound(b))
+		}
+		if err := config.check(meta); err != nil {
+			return nil, err
+		}
+		want, err := json.Marshal(config)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			have, err := json.Marshal(stored)
+			if err != nil {
+				return nil, err
+			}
+			if !bytes.Equal(have, want) {
+				return nil, &FlintDBError{Message: fmt.Sprintf("shard mismatch: %s%s differs", path, shardConfigSuffix)}
+			}
+		} else if mode == FLINTDB_RDWR {
+			if err := os.WriteFile(path+shardConfigSuffix, append(want, '\n'), 0644); err != nil {
+				return nil, err
+			}
+		}
+	} else if !found {
+		return nil, &FlintDBError{Message: fmt.Sprintf("no shard configuration: %s%s", path, shardConfigSuffix)}
+	}
+
+	s := &ShardedTable{config: config}
+	for i := 0; i < config.Shards; i++ {
+		t, err := TableOpen(shardPath(path, i), mode, meta)
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+		s.shards = append(s.shards, t)
+	}
+	s.column = s.shards[0].columnAt(config.Column)
+	if s.column < 0 {
+		s.Close()
+		return nil, &FlintDBError{Message: fmt.Sprintf("column not found: %s", config.Column)}
+	}
+	return s, nil
+}
+
+// shardBound widens a bound to the types Row.Get returns.
+func shardBound(v interface{}) interface{} {
+	switch v := v.(type) {
+	case int:
+		return int64(v)
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case uint8:
+		return int64(v)
+	case uint16:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case float32:
+		return float64(v)
+	}
+	return v
+}
+
+func (c *shardConfig) check(meta *Meta) error {
+	if meta != nil && meta.ColumnAt(c.Column) < 0 {
+		return &FlintDBError{Message: fmt.Sprintf("column not found: %s", c.Column)}
+	}
+	if c.Shards < 1 {
+		return &FlintDBError{Message: fmt.Sprintf("invalid shard count: %d", c.Shards)}
+	}
+	for i := 1; i < len(c.Bounds); i++ {
+		d, err := compareValues(c.Bounds[i-1], c.Bounds[i])
+		if err != nil {
+			return err
+		}
+		if d >= 0 {
+			return &FlintDBError{Message: "shard bounds must be ascending"}
+		}
+	}
+	return nil
+}
+
+// ShardedTableDrop removes every shard of path.
+func ShardedTableDrop(path string) {
+	config, found, err := readShardConfig(path)
+	if err != nil || !found {
+		return
+	}
+	for i := 0; i < config.Shards; i++ {
+		TableDrop(shardPath(path, i))
+	}
+	_ = os.Remove(path + shardConfigSuffix)
+}
+
+func (s *ShardedTable) Close() {
+	for _, t := range s.shards {
+		t.Close()
+	}
+	s.shards = nil
+}
+
+// Ping pings each shard, as Table.Ping does.
+func (s *ShardedTable) Ping() error {
+	if s.shards == nil {
+		return &FlintDBError{Message: "table is closed"}
+	}
+	for _, t := range s.shards {
+		if err := t.Ping(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Shards returns the underlying tables, for settings made per table such as
+// checks and foreign keys.
+func (s *ShardedTable) Shards() []*Table {
+	return s.shards
+}
+
+func (s *ShardedTable) CreateRow() (*Row, error) {
+	return s.shards[0].CreateRow()
+}
+
+// shardOf routes row by its key; a NULL key goes to the first shard.
+func (s *ShardedTable) shardOf(row *Row) (int, error) {
+	if len(s.config.Bounds) == 0 {
+		h, ok, err := hashKey(row, []int{s.column})
+		if err != nil || !ok {
+			return 0, err
+		}
+		return int(h % uint64(len(s.shards))), nil
+	}
+	v, err := row.Get(s.column)
+	if err != nil || v == nil {
+		return 0, err
+	}
+	var cmpErr error
+	i := sort.Search(len(s.config.Bounds), func(i int) bool {
+		d, err := compareValues(v, s.config.Bounds[i])
+		if err != nil {
+			cmpErr = err
+		}
+		return d < 0
+	})
+	return i, cmpErr
+}
+
+func (s *ShardedTable) globalID(shard int, rowid int64) int64 {
+	return rowid*int64(len(s.shards)) + int64(shard)
+}
+
+func (s *ShardedTable) localID(rowid int64) (*Table, int64, error) {
+	if rowid < 0 {
+		return nil, -1, &FlintDBError{Message: fmt.Sprintf("invalid rowid: %d", rowid)}
+	}
+	n := int64(len(s.shards))
+	return s.shards[rowid%n], rowid / n, nil
+}
+
+func (s *ShardedTable) Insert(row *Row) (int64, error) {
+	shard, err := s.shardOf(row)
+	if err != nil {
+		return -1, err
+	}
+	rowid, err := s.shards[shard].Insert(row)
+	if err != nil {
+		return -1, err
+	}
+	return s.globalID(shard, rowid), nil
+}
+
+func (s *ShardedTable) Read(rowid int64) (*Row, error) {
+	t, local, err := s.localID(rowid)
+	if err != nil {
+		return nil, err
+	}
+	return t.Read(local)
+}
+
+// UpdateAt fails if the new key belongs to another shard; delete and insert
+// the row instead.
+func (s *ShardedTable) UpdateAt(rowid int64, row *Row) error {
+	t, local, err := s.localID(rowid)
+	if err != nil {
+		return err
+	}
+	shard, err := s.shardOf(row)
+	if err != nil {
+		return err
+	}
+	if s.shards[shard] != t {
+		return &FlintDBError{Message: fmt.Sprintf("update moves row to another shard: %s", s.config.Column)}
+	}
+	return t.UpdateAt(local, row)
+}
+
+func (s *ShardedTable) DeleteAt(rowid int64) error {
+	t, local, err := s.localID(rowid)
+	if err != nil {
+		return err
+	}
+	return t.DeleteAt(local)
+}
+
+func (s *ShardedTable) Rows() (int64, error) {
+	var total int64
+	for _, t := range s.shards {
+		n, err := t.Rows()
+		if err != nil {
+			return -1, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// Find runs query on the shards in parallel and returns their rows shard by
+// shard, so with range sharding a scan in key order stays in key order. An
+// equality on the shard key is sent to its shard only.
+func (s *ShardedTable) Find(query string) (*CursorInt64, error) {
+	shards, err := s.shardsFor(query)
+	if err != nil {
+		return nil, err
+	}
+	rest, offset, limit := splitLimit(query)
+	if limit >= 0 {
+		rest += fmt.Sprintf(" LIMIT %d", offset+limit)
+	}
+
+	found := make([][]int64, len(shards))
+	errs := make([]error, len(shards))
+	var wg sync.WaitGroup
+	for i, shard := range shards {
+		wg.Add(1)
+		go func(i, shard int) {
+			defer wg.Done()
+			found[i], errs[i] = s.findIn(shard, rest)
+		}(i, shard)
+	}
+	wg.Wait()
+
+	var rows []int64
+	for i := range shards {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		rows = append(rows, found[i]...)
+	}
+	if offset > len(rows) {
+		offset = len(rows)
+	}
+	rows = rows[offset:]
+	if limit >= 0 && limit < len(rows) {
+		rows = rows[:limit]
+	}
+	return &CursorInt64{rows: rows}, nil
+}
+
+func (s *ShardedTable) findIn(shard int, query string) ([]int64, error) {
+	cursor, err := s.shards[shard].Find(query)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close()
+	var rows []int64
+	for {
+		rowid, err := cursor.Next()
+		if err != nil {
+			return nil, err
+		}
+		if rowid < 0 {
+			return rows, nil
+		}
+		rows = append(rows, s.globalID(shard, rowid))
+	}
+}
+
+// shardsFor prunes the shards a query can match. Collated keys are never
+// pruned: equal keys under the collation may differ in bytes.
+func (s *ShardedTable) shardsFor(query string) ([]int, error) {
+	all := make([]int, len(s.shards))
+	for i := range all {
+		all[i] = i
+	}
+	q, ok := parseEqualityQuery(query)
+	if !ok || s.shards[0].collatedColumn(s.config.Column) != nil {
+		return all, nil
+	}
+	value, ok := q.values[strings.ToLower(s.config.Column)]
+	if !ok {
+		return all, nil
+	}
+	probe, err := s.CreateRow()
+	if err != nil {
+		return nil, err
+	}
+	defer probe.Free()
+	if err := probe.Set(s.column, value); err != nil {
+		return nil, err
+	}
+	shard, err := s.shardOf(probe)
+	if err != nil {
+		return nil, err
+	}
+	return []int{shard}, nil
+}
+
+// splitLimit removes a trailing "LIMIT [offset,] n" from query, reporting a
+// limit of -1 when there is none.
+func splitLimit(query string) (string, int, int) {
+	tokens, err := tokenizeExpr(query)
+	if err != nil {
+		return query, 0, -1
+	}
+	for i, t := range tokens {
+		if t.kind != tokenIdent || !strings.EqualFold(t.text, "LIMIT") {
+			continue
+		}
+		var nums []int
+		rest := tokens[i+1:]
+		for j, n := range rest {
+			if j%2 == 1 {
+				if n.kind != tokenSymbol || n.text != "," {
+					return query, 0, -1
+				}
+				continue
+			}
+			v, err := strconv.Atoi(n.text)
+			if n.kind != tokenNumber || err != nil {
+				return query, 0, -1
+			}
+			nums = append(nums, v)
+		}
+		switch len(nums) {
+		case 1:
+			return query[:t.pos], 0, nums[0]
+		case 2:
+			return query[:t.pos], nums[0], nums[1]
+		}
+		return query, 0, -1
+	}
+	return query, 0, -1
+}
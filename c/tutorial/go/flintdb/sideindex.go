@@ -0,0 +1,235 @@
+package flintdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// sideIndex is an index kept by this package rather than the engine, such as
+// a full-text or geo index.
+type sideIndex interface {
+	put(rowid int64, row *Row) error // replaces any entry for rowid
+	remove(rowid int64)
+	encode(w io.Writer) error
+	decode(r io.Reader) error
+}
+
+// sideIndexFile holds a sideIndex in memory and saves it to file on Close.
+// The saved copy is removed by the first write after opening, so a table that
+// was not closed cleanly gets the index rebuilt from its rows on next open.
+type sideIndexFile struct {
+	mu    sync.RWMutex
+	name  string
+	file  string
+	index sideIndex
+	dirty bool
+}
+
+// checkSideIndexName validates the name of a new side index, which is also
+// part of its file name.
+func (m *Meta) checkSideIndexName(name string) error {
+	if name == "" || strings.IndexFunc(name, func(r rune) bool {
+		return r != '_' && (r > unicode.MaxASCII || !unicode.IsLetter(r) && !unicode.IsDigit(r))
+	}) >= 0 {
+		return &FlintDBError{Message: fmt.Sprintf("invalid index name: %q", name)}
+	}
+	if m.hasSideIndex(name) {
+		return &FlintDBError{Message: fmt.Sprintf("duplicate index: %s", name)}
+	}
+	for i := 0; i < int(m.inner.indexes.length); i++ {
+		if strings.EqualFold(cstring(m.inner.indexes.a[i].name[:]), name) {
+			return &FlintDBError{Message: fmt.Sprintf("duplicate index: %s", name)}
+		}
+	}
+	return nil
+}
+
+func (m *Meta) hasSideIndex(name string) bool {
+	var names []string
+	for _, def := range m.ext.FullText {
+		names = append(names, def.Name)
+	}
+	for _, def := range m.ext.Geo {
+		names = append(names, def.Name)
+	}
+	for _, def := range m.ext.Hash {
+		names = append(names, def.Name)
+	}
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *Table) openSideIndexes(ctx context.Context, x metaExt) error {
+	for _, def := range x.FullText {
+		index, err := newFullTextIndex(t, def)
+		if err != nil {
+			return err
+		}
+		t.addSideIndex("fts", def.Name, index)
+	}
+	for _, def := range x.Geo {
+		index, err := newGeoIndex(t, def)
+		if err != nil {
+			return err
+		}
+		t.addSideIndex("geo", def.Name, index)
+	}
+	for _, def := range x.Hash {
+		index, err := newHashIndex(t, def)
+		if err != nil {
+			return err
+		}
+		t.addSideIndex("hash", def.Name, index)
+	}
+	if x.Bloom != nil {
+		index, err := newBloomFilter(t, *x.Bloom)
+		if err != nil {
+			return err
+		}
+		t.addSideIndex("bloom", PRIMARY_NAME, index)
+	}
+	return t.loadSideIndexes(ctx)
+}
+
+func (t *Table) addSideIndex(kind, name string, index sideIndex) {
+	t.sideIndexes = append(t.sideIndexes, &sideIndexFile{
+		name:  name,
+		file:  t.path + "." + kind + "." + name,
+		index: index,
+	})
+}
+
+// loadSideIndexes loads the saved indexes and rebuilds the others with a
+// single scan of the table, which stops when ctx is done.
+func (t *Table) loadSideIndexes(ctx context.Context) error {
+	var missing []*sideIndexFile
+	for _, s := range t.sideIndexes {
+		ok, err := s.load()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			missing = append(missing, s)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	cursor, err := t.Find("")
+	if err != nil {
+		return err
+	}
+	defer cursor.Close()
+	for {
+		rowid, err := cursor.Next()
+		if err != nil {
+			return err
+		}
+		if rowid < 0 {
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		row, err := t.Read(rowid)
+		if err != nil {
+			return err
+		}
+		for _, s := range missing {
+			if err := s.index.put(rowid, row); err != nil {
+				return fmt.Errorf("index %s: %w", s.name, err)
+			}
+		}
+	}
+	for _, s := range missing {
+		s.dirty = true
+	}
+	return nil
+}
+
+func (t *Table) closeSideIndexes() {
+	for _, s := range t.sideIndexes {
+		if s.dirty && t.mode == FLINTDB_RDWR {
+			_ = s.save()
+		}
+	}
+	t.sideIndexes = nil
+}
+
+// indexRow runs after the row is stored at rowid.
+func (t *Table) indexRow(rowid int64, row *Row) error {
+	for _, s := range t.sideIndexes {
+		s.mu.Lock()
+		s.touch()
+		err := s.index.put(rowid, row)
+		s.mu.Unlock()
+		if err != nil {
+			return fmt.Errorf("index %s: %w", s.name, err)
+		}
+	}
+	return nil
+}
+
+func (t *Table) unindexRow(rowid int64) {
+	for _, s := range t.sideIndexes {
+		s.mu.Lock()
+		s.touch()
+		s.index.remove(rowid)
+		s.mu.Unlock()
+	}
+}
+
+// touch drops the saved copy before the in-memory index diverges from it.
+func (s *sideIndexFile) touch() {
+	if !s.dirty {
+		_ = os.Remove(s.file)
+		s.dirty = true
+	}
+}
+
+func (s *sideIndexFile) load() (bool, error) {
+	f, err := os.Open(s.file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	if err := s.index.decode(f); err != nil {
+		return false, nil // unreadable copy: rebuild
+	}
+	return true, nil
+}
+
+func (s *sideIndexFile) save() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	tmp := s.file + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err := s.index.encode(f); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, s.file)
+}
@@ -0,0 +1,38 @@
+package flintdb
+
+// Snapshot is a read-only view of a table opened by Table.Snapshot. It has
+// the read methods of Table, such as Read, Find and Rows.
+type Snapshot struct {
+	*Table
+	parent *Table
+}
+
+// Snapshot opens a read-only view of t for a consistent scan, such as an
+// export. The view reads through a handle of its own, and Insert, UpdateAt
+// and DeleteAt on t wait until it is closed, so it never sees a write half
+// applied. Close the snapshot before writing to t from the goroutine that
+// holds it. Writes through other handles of the file are not held back.
+func (t *Table) Snapshot() (*Snapshot, error) {
+	t.frozen.RLock()
+	handle, err := t.openReader()
+	if err != nil {
+		t.frozen.RUnlock()
+		return nil, err
+	}
+	if err := handle.loadCollations(); err != nil {
+		handle.Close()
+		t.frozen.RUnlock()
+		return nil, err
+	}
+	return &Snapshot{Table: handle, parent: t}, nil
+}
+
+// Close closes the view and lets the writes it held back through.
+func (s *Snapshot) Close() {
+	if s.parent == nil {
+		return
+	}
+	s.Table.Close()
+	s.parent.frozen.RUnlock()
+	s.parent = nil
+}
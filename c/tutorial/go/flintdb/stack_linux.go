@@ -0,0 +1,26 @@
+//go:build !android
+
+package flintdb
+
+// With musl, as on Alpine, threads get 128 KiB stacks unless created asking
+// for more, and Go creates its threads with the default. Engine calls can
+// need more than that, so the default is raised to 1 MiB before Go starts
+// any; glibc's is 8 MiB already and is left alone.
+
+/*
+#ifndef _GNU_SOURCE
+#define _GNU_SOURCE
+#endif
+#include <pthread.h>
+
+__attribute__((constructor)) static void flintdb_thread_stack_init(void) {
+    pthread_attr_t attr;
+    size_t size = 0;
+    if (pthread_getattr_default_np(&attr) != 0) return;
+    if (pthread_attr_getstacksize(&attr, &size) == 0 && size < (1u << 20)) {
+        if (pthread_attr_setstacksize(&attr, 1u << 20) == 0) pthread_setattr_default_np(&attr);
+    }
+    pthread_attr_destroy(&attr);
+}
+*/
+import "C"
@@ -0,0 +1,21 @@
+//go:build flintdb_static
+
+package flintdb
+
+// Built with -tags flintdb_static, binaries link fully statically and need
+// no shared libraries, so they run in scratch and Alpine images as they are.
+// The netgo and osusergo tags keep the Go side from needing libc lookups:
+//
+//	go build -tags 'flintdb_static netgo osusergo' .
+//
+// With musl, as in a golang:alpine build stage, the C toolchain and static
+// zlib come from apk add gcc musl-dev zlib-dev zlib-static. A static binary
+// cannot dlopen plugins or the webui's cJSON; with glibc, linking warns so.
+// With -tags flintdb_lib it links lib/libflintdb.a, and a library built with
+// zstd or lz4 also needs CGO_LDFLAGS=-lzstd or -llz4.
+
+/*
+#cgo LDFLAGS: -static -lz -lm -lpthread
+#cgo linux LDFLAGS: -ldl
+*/
+import "C"
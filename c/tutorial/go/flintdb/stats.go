@@ -0,0 +1,460 @@
+package flintdb
+
+/*
+#include "flintdb.h"
+*/
+import "C"
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"math"
+	"math/bits"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	statsSuffix      = ".stats.json"
+	statsBuckets     = 32    // histogram buckets per column
+	statsSampleRows  = 30000 // rows sampled for the histograms
+	hllPrecision     = 12    // log2 of the HyperLogLog registers
+	defaultSelection = 1.0 / 3
+)
+
+// TableStats holds the statistics Table.Analyze computed.
+type TableStats struct {
+	Rows     int64         `json:"rows"`
+	Analyzed time.Time     `json:"analyzed"`
+	Columns  []ColumnStats `json:"columns"`
+	// Headroom is what Table.Headroom returned when Stats or Analyze was
+	// called: the bytes left under WithMaxFileSize, or -1.
+	Headroom int64 `json:"-"`
+}
+
+// ColumnStats summarizes the values of a column. Values are as the engine
+// prints them; numeric columns are ordered by number, the others as text.
+type ColumnStats struct {
+	Name     string `json:"name"`
+	Nulls    int64  `json:"nulls"`
+	Min      string `json:"min,omitempty"`
+	Max      string `json:"max,omitempty"`
+	Distinct int64  `json:"distinct"` // a HyperLogLog estimate
+	// Histogram holds the upper bounds of equi-depth buckets, each holding
+	// about as many of the non-NULL values, drawn from a sample of the rows.
+	Histogram []string `json:"histogram,omitempty"`
+
+	numeric bool
+}
+
+// Column returns the statistics of the column named name, or nil.
+func (s *TableStats) Column(name string) *ColumnStats {
+	for i := range s.Columns {
+		if strings.EqualFold(s.Columns[i].Name, name) {
+			return &s.Columns[i]
+		}
+	}
+	return nil
+}
+
+// Analyze scans the table to compute the statistics Stats returns and
+// Explain estimates rows with, and saves them next to the table when it is
+// open for writing. They are not kept up to date by writes: analyze again
+// after large changes.
+func (t *Table) Analyze() (*TableStats, error) {
+	columns := t.exportColumns()
+	type columnState struct {
+		stats    ColumnStats
+		min, max interface{}
+		hll      *hyperLogLog
+	}
+	states := make([]*columnState, len(columns))
+	for i, c := range columns {
+		states[i] = &columnState{stats: ColumnStats{Name: c.name, numeric: numericKind(c.kind)}, hll: newHyperLogLog(hllPrecision)}
+	}
+	var sample [][]*string // rows of values, nil for NULL
+
+	cursor, err := t.Find("")
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close()
+	stats := &TableStats{Analyzed: time.Now().UTC()}
+	for {
+		rowid, err := cursor.Next()
+		if err != nil {
+			return nil, err
+		}
+		if rowid < 0 {
+			break
+		}
+		row, err := t.Read(rowid)
+		if err != nil {
+			return nil, err
+		}
+		values := make([]*string, len(columns))
+		for i, c := range columns {
+			s := states[i]
+			null, err := row.IsNull(c.index)
+			if err == nil && !null {
+				var text string
+				if text, err = row.GetString(c.index); err == nil {
+					values[i] = &text
+				}
+			}
+			if err != nil {
+				row.Free()
+				return nil, err
+			}
+			if values[i] == nil {
+				s.stats.Nulls++
+				continue
+			}
+			key := s.stats.key(*values[i])
+			if s.min == nil || compareKeys(key, s.min) < 0 {
+				s.min, s.stats.Min = key, *values[i]
+			}
+			if s.max == nil || compareKeys(key, s.max) > 0 {
+				s.max, s.stats.Max = key, *values[i]
+			}
+			s.hll.add(*values[i])
+		}
+		row.Free()
+
+		if len(sample) < statsSampleRows {
+			sample = append(sample, values)
+		} else if j := rand.Int63n(stats.Rows + 1); j < statsSampleRows {
+			sample[j] = values
+		}
+		stats.Rows++
+	}
+
+	for i, s := range states {
+		s.stats.Distinct = s.hll.count()
+		if n := stats.Rows - s.stats.Nulls; s.stats.Distinct > n {
+			s.stats.Distinct = n
+		}
+		var values []string
+		for _, row := range sample {
+			if row[i] != nil {
+				values = append(values, *row[i])
+			}
+		}
+		s.stats.Histogram = s.stats.histogram(values)
+		stats.Columns = append(stats.Columns, s.stats)
+	}
+	if t.mode == FLINTDB_RDWR {
+		data, err := json.MarshalIndent(stats, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(t.path+statsSuffix, append(data, '\n'), 0644); err != nil {
+			return nil, err
+		}
+	}
+	t.stats = stats
+	return stats, nil
+}
+
+// Stats returns the statistics of the last Analyze, or nil if the table
+// has not been analyzed.
+func (t *Table) Stats() (*TableStats, error) {
+	stats, err := t.loadStats()
+	if stats != nil && err == nil {
+		stats.Headroom, err = t.Headroom()
+	}
+	return stats, err
+}
+
+func (t *Table) loadStats() (*TableStats, error) {
+	if t.stats != nil {
+		return t.stats, nil
+	}
+	data, err := os.ReadFile(t.path + statsSuffix)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	stats := &TableStats{}
+	if err := json.Unmarshal(data, stats); err != nil {
+		return nil, &FlintDBError{Message: fmt.Sprintf("invalid %s%s: %v", t.path, statsSuffix, err)}
+	}
+	for _, c := range t.exportColumns() {
+		if cs := stats.Column(c.name); cs != nil {
+			cs.numeric = numericKind(c.kind)
+		}
+	}
+	t.stats = stats
+	return stats, nil
+}
+
+func numericKind(kind int) bool {
+	switch kind {
+	case C.VARIANT_INT8, C.VARIANT_UINT8, C.VARIANT_INT16, C.VARIANT_UINT16, C.VARIANT_INT32, C.VARIANT_UINT32,
+		C.VARIANT_INT64, C.VARIANT_DOUBLE, C.VARIANT_FLOAT, C.VARIANT_DECIMAL:
+		return true
+	}
+	return false
+}
+
+// key returns text as the value the column's values are ordered by.
+func (c *ColumnStats) key(text string) interface{} {
+	if c.numeric {
+		if f, err := strconv.ParseFloat(text, 64); err == nil {
+			return f
+		}
+	}
+	return text
+}
+
+// compareKeys orders keys, numbers before text.
+func compareKeys(a, b interface{}) int {
+	x, xNum := a.(float64)
+	y, yNum := b.(float64)
+	switch {
+	case xNum && yNum:
+		return compareOrdered(x, y)
+	case xNum:
+		return -1
+	case yNum:
+		return 1
+	}
+	return strings.Compare(a.(string), b.(string))
+}
+
+func (c *ColumnStats) histogram(values []string) []string {
+	if len(values) == 0 {
+		return nil
+	}
+	keys := make([]interface{}, len(values))
+	for i, v := range values {
+		keys[i] = c.key(v)
+	}
+	order := make([]int, len(values))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool { return compareKeys(keys[order[i]], keys[order[j]]) < 0 })
+	buckets := statsBuckets
+	if buckets > len(values) {
+		buckets = len(values)
+	}
+	bounds := make([]string, buckets)
+	for b := range bounds {
+		bounds[b] = values[order[(b+1)*len(values)/buckets-1]]
+	}
+	return bounds
+}
+
+// below returns the share of the non-NULL values less than value, or not
+// greater with orEqual, read from the histogram.
+func (c *ColumnStats) below(value interface{}, orEqual bool) float64 {
+	if len(c.Histogram) == 0 {
+		return 0.5
+	}
+	n := 0
+	for _, bound := range c.Histogram {
+		cmp := compareKeys(c.key(bound), value)
+		if cmp < 0 || orEqual && cmp == 0 {
+			n++
+		}
+	}
+	return float64(n) / float64(len(c.Histogram))
+}
+
+// estimate returns how many rows query is expected to find.
+func (s *TableStats) estimate(query string) int64 {
+	tokens, err := tokenizeExpr(query)
+	if err != nil {
+		return -1
+	}
+	where, limit := -1, len(tokens)
+	for i, tok := range tokens {
+		if tok.kind != tokenIdent {
+			continue
+		}
+		switch {
+		case strings.EqualFold(tok.text, "WHERE") && where < 0:
+			where = i + 1
+		case strings.EqualFold(tok.text, "LIMIT"):
+			limit = i
+		}
+	}
+	share := 1.0
+	if where >= 0 && where < limit {
+		end := len(query)
+		if limit < len(tokens) {
+			end = tokens[limit].pos
+		}
+		share = defaultSelection
+		x, err := parseExpr(query[tokens[where].pos:end], func(name string) int {
+			for i := range s.Columns {
+				if strings.EqualFold(s.Columns[i].Name, name) {
+					return i
+				}
+			}
+			return -1
+		})
+		if err == nil {
+			share = s.selection(x)
+		}
+	}
+	rows := int64(math.Round(share * float64(s.Rows)))
+	if limit < len(tokens)-1 {
+		last := tokens[len(tokens)-1]
+		if n, err := strconv.ParseInt(last.text, 10, 64); err == nil && last.kind == tokenNumber && n < rows {
+			rows = n
+		}
+	}
+	return rows
+}
+
+// selection returns the share of the rows x holds for.
+func (s *TableStats) selection(x expr) float64 {
+	switch x := x.(type) {
+	case *exprBinary:
+		switch x.op {
+		case "AND":
+			return s.selection(x.left) * s.selection(x.right)
+		case "OR":
+			l, r := s.selection(x.left), s.selection(x.right)
+			return l + r - l*r
+		}
+		col, lit, flipped := comparison(x)
+		if col == nil {
+			break
+		}
+		c := &s.Columns[col.index]
+		if lit.value == nil {
+			return 0
+		}
+		v := c.key(exprString(lit.value))
+		op := x.op
+		if flipped {
+			op = map[string]string{"<": ">", "<=": ">=", ">": "<", ">=": "<="}[op]
+		}
+		values := c.values(s.Rows)
+		switch op {
+		case "=":
+			return values * c.equal(v)
+		case "!=":
+			return values * (1 - c.equal(v))
+		case "<":
+			return values * c.below(v, false)
+		case "<=":
+			return values * c.below(v, true)
+		case ">":
+			return values * (1 - c.below(v, true))
+		case ">=":
+			return values * (1 - c.below(v, false))
+		}
+	case *exprUnary:
+		if x.op == "NOT" {
+			return 1 - s.selection(x.operand)
+		}
+	case *exprIsNull:
+		if col, ok := x.operand.(*exprColumn); ok && s.Rows > 0 {
+			nulls := float64(s.Columns[col.index].Nulls) / float64(s.Rows)
+			if x.not {
+				return 1 - nulls
+			}
+			return nulls
+		}
+	case *exprIn:
+		if col, ok := x.operand.(*exprColumn); ok {
+			c := &s.Columns[col.index]
+			share := 0.0
+			for _, item := range x.list {
+				if lit, ok := item.(*exprLiteral); ok && lit.value != nil {
+					share += c.equal(c.key(exprString(lit.value)))
+				}
+			}
+			share = math.Min(1, share) * c.values(s.Rows)
+			if x.not {
+				return c.values(s.Rows) - share
+			}
+			return share
+		}
+	}
+	return defaultSelection
+}
+
+// comparison returns the column and literal x compares, with flipped set
+// when the literal comes first.
+func comparison(x *exprBinary) (*exprColumn, *exprLiteral, bool) {
+	if col, ok := x.left.(*exprColumn); ok {
+		if lit, ok := x.right.(*exprLiteral); ok {
+			return col, lit, false
+		}
+	}
+	if col, ok := x.right.(*exprColumn); ok {
+		if lit, ok := x.left.(*exprLiteral); ok {
+			return col, lit, true
+		}
+	}
+	return nil, nil, false
+}
+
+// values returns the share of rows with a value in the column.
+func (c *ColumnStats) values(rows int64) float64 {
+	if rows == 0 {
+		return 0
+	}
+	return float64(rows-c.Nulls) / float64(rows)
+}
+
+// equal returns the share of the non-NULL values equal to value.
+func (c *ColumnStats) equal(value interface{}) float64 {
+	if c.Distinct == 0 || compareKeys(value, c.key(c.Min)) < 0 || compareKeys(value, c.key(c.Max)) > 0 {
+		return 0
+	}
+	return 1 / float64(c.Distinct)
+}
+
+// hyperLogLog estimates the number of distinct strings added to it.
+type hyperLogLog struct {
+	registers []uint8
+	p         uint
+}
+
+func newHyperLogLog(p uint) *hyperLogLog {
+	return &hyperLogLog{registers: make([]uint8, 1<<p), p: p}
+}
+
+func (h *hyperLogLog) add(s string) {
+	f := fnv.New64a()
+	f.Write([]byte(s))
+	x := f.Sum64()
+	x ^= x >> 33 // fnv mixes the high bits poorly
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	i := x >> (64 - h.p)
+	rank := uint8(bits.LeadingZeros64(x<<h.p|1<<(h.p-1)) + 1)
+	if rank > h.registers[i] {
+		h.registers[i] = rank
+	}
+}
+
+func (h *hyperLogLog) count() int64 {
+	m := float64(len(h.registers))
+	sum, zeros := 0.0, 0
+	for _, r := range h.registers {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+	estimate := 0.7213 / (1 + 1.079/m) * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros)) // linear counting
+	}
+	return int64(math.Round(estimate))
+}
@@ -0,0 +1,46 @@
+package flintdb
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Tracer starts spans around table opens, finds, cursor drains, batch
+// inserts and compactions, so their time shows in distributed traces.
+// Install one with SetTracer, such as the OpenTelemetry adapter of package
+// flintdbotel; without one nothing is traced.
+type Tracer interface {
+	// Start starts a span named name as a child of the span in ctx.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a span started by a Tracer. Attribute values are strings and
+// int64s; flintdb.table is the table's path and flintdb.query the query.
+type Span interface {
+	SetAttribute(key string, value interface{})
+	End(err error)
+}
+
+var tracer atomic.Pointer[Tracer]
+
+// SetTracer traces the operations of every table with tr; nil stops tracing.
+// The methods without a context start their spans as roots.
+func SetTracer(tr Tracer) {
+	if tr == nil {
+		tracer.Store(nil)
+		return
+	}
+	tracer.Store(&tr)
+}
+
+// startSpan starts a span on table if a Tracer is set, and returns a nil
+// Span otherwise.
+func startSpan(ctx context.Context, name, table string) (context.Context, Span) {
+	tr := tracer.Load()
+	if tr == nil {
+		return ctx, nil
+	}
+	ctx, span := (*tr).Start(ctx, name)
+	span.SetAttribute("flintdb.table", table)
+	return ctx, span
+}
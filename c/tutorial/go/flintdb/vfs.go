@@ -0,0 +1,179 @@
+package flintdb
+
+/*
+#include <stdint.h>
+#include "flintdb.h"
+*/
+import "C"
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime/cgo"
+	"unsafe"
+)
+
+// VFS is a file backend for table data and index files, such as encrypted
+// files, an object storage gateway or a test fake. Register it under a name
+// with RegisterVFS and choose it per table with Meta.SetStorage; tables
+// without one use the engine's own files. The .desc schema and WAL files
+// stay on the local file system, and TableDrop does not remove VFS files.
+type VFS interface {
+	// Open opens name for FLINTDB_RDONLY or FLINTDB_RDWR, creating it in
+	// FLINTDB_RDWR mode if it does not exist.
+	Open(name string, mode uint32) (VFSFile, error)
+}
+
+// VFSFile is a file opened by a VFS. ReadAt returns io.EOF with fewer bytes
+// at the end of the file; WriteAt past the end grows the file. The engine
+// writes changed blocks through as it goes and calls Sync when a table is
+// closed.
+type VFSFile interface {
+	io.ReaderAt
+	io.WriterAt
+	Sync() error
+	Size() (int64, error)
+	Close() error
+}
+
+// RegisterVFS registers vfs as the storage name, of at most 15 bytes, for
+// tables whose Meta.SetStorage names it. Register before opening such tables;
+// registering a name again replaces its VFS for tables opened afterwards.
+func RegisterVFS(name string, vfs VFS) error {
+	// The handle is never deleted: open tables may still use the VFS.
+	h := cgo.NewHandle(vfs)
+	if err := registerVFS(name, h); err != nil {
+		h.Delete()
+		return err
+	}
+	return nil
+}
+
+// SetStorage sets the storage of a table: a name registered with
+// RegisterVFS, or one of the engine's own, MMAP (the default) and MEMORY.
+// The name is saved with the schema, so a VFS table needs its VFS registered
+// whenever it is opened.
+func (m *Meta) SetStorage(name string) error {
+	if len(name) >= len(m.inner.storage) {
+		return &FlintDBError{Message: fmt.Sprintf("storage name too long: %s", name)}
+	}
+	for i := range m.inner.storage {
+		m.inner.storage[i] = 0
+	}
+	for i := 0; i < len(name); i++ {
+		m.inner.storage[i] = C.char(name[i])
+	}
+	return nil
+}
+
+// OSVFS is a VFS over the operating system's files, a base for wrappers such
+// as encryption.
+type OSVFS struct{}
+
+func (OSVFS) Open(name string, mode uint32) (VFSFile, error) {
+	flag := os.O_RDONLY
+	if mode == FLINTDB_RDWR {
+		flag = os.O_RDWR | os.O_CREATE
+	}
+	f, err := os.OpenFile(name, flag, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return osFile{f}, nil
+}
+
+type osFile struct {
+	*os.File
+}
+
+func (f osFile) Size() (int64, error) {
+	st, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return st.Size(), nil
+}
+
+// vfsError copies err into the engine's error buffer of n bytes.
+func vfsError(buf *C.char, n C.int, err error) {
+	b := unsafe.Slice((*byte)(unsafe.Pointer(buf)), int(n))
+	msg := err.Error()
+	if msg == "" {
+		msg = "vfs error"
+	}
+	b[copy(b[:len(b)-1], msg)] = 0
+}
+
+// vfsRecover turns a panic in a VFS method into an error for the engine, as
+// it cannot unwind through C.
+func vfsRecover(buf *C.char, n C.int) {
+	if p := recover(); p != nil {
+		vfsError(buf, n, fmt.Errorf("vfs panic: %v", p))
+	}
+}
+
+//export flintdbVFSOpen
+func flintdbVFSOpen(ctx C.uintptr_t, file *C.char, mode C.int, errbuf *C.char, errlen C.int) C.uintptr_t {
+	defer vfsRecover(errbuf, errlen)
+	f, err := cgo.Handle(ctx).Value().(VFS).Open(C.GoString(file), uint32(mode))
+	if err != nil {
+		vfsError(errbuf, errlen, err)
+		return 0
+	}
+	if f == nil {
+		vfsError(errbuf, errlen, fmt.Errorf("vfs opened no file: %s", C.GoString(file)))
+		return 0
+	}
+	return C.uintptr_t(cgo.NewHandle(f))
+}
+
+//export flintdbVFSReadAt
+func flintdbVFSReadAt(h C.uintptr_t, buf unsafe.Pointer, size, offset C.int64_t, errbuf *C.char, errlen C.int) C.int64_t {
+	defer vfsRecover(errbuf, errlen)
+	n, err := cgo.Handle(h).Value().(VFSFile).ReadAt(unsafe.Slice((*byte)(buf), int(size)), int64(offset))
+	if err != nil && err != io.EOF {
+		vfsError(errbuf, errlen, err)
+		return -1
+	}
+	return C.int64_t(n)
+}
+
+//export flintdbVFSWriteAt
+func flintdbVFSWriteAt(h C.uintptr_t, buf unsafe.Pointer, size, offset C.int64_t, errbuf *C.char, errlen C.int) C.int64_t {
+	defer vfsRecover(errbuf, errlen)
+	n, err := cgo.Handle(h).Value().(VFSFile).WriteAt(unsafe.Slice((*byte)(buf), int(size)), int64(offset))
+	if err != nil {
+		vfsError(errbuf, errlen, err)
+		return -1
+	}
+	return C.int64_t(n)
+}
+
+//export flintdbVFSSync
+func flintdbVFSSync(h C.uintptr_t, errbuf *C.char, errlen C.int) C.int {
+	defer vfsRecover(errbuf, errlen)
+	if err := cgo.Handle(h).Value().(VFSFile).Sync(); err != nil {
+		vfsError(errbuf, errlen, err)
+		return -1
+	}
+	return 0
+}
+
+//export flintdbVFSSize
+func flintdbVFSSize(h C.uintptr_t, errbuf *C.char, errlen C.int) C.int64_t {
+	defer vfsRecover(errbuf, errlen)
+	n, err := cgo.Handle(h).Value().(VFSFile).Size()
+	if err != nil {
+		vfsError(errbuf, errlen, err)
+		return -1
+	}
+	return C.int64_t(n)
+}
+
+//export flintdbVFSClose
+func flintdbVFSClose(h C.uintptr_t) {
+	defer func() { recover() }()
+	handle := cgo.Handle(h)
+	defer handle.Delete()
+	handle.Value().(VFSFile).Close()
+}
@@ -0,0 +1,158 @@
+package flintdb
+
+import "fmt"
+
+// Kinds of write a Batch holds.
+const (
+	batchInsert = iota
+	batchUpdate
+	batchDelete
+)
+
+// Batch collects inserts, updates and deletes of a table in Go for Commit to
+// apply all or none of. Values are copied as writes are added, so the rows
+// stay the caller's to change and free.
+type Batch struct {
+	table  *Table
+	writes []batchWrite
+}
+
+type batchWrite struct {
+	kind   int
+	rowid  int64
+	values []interface{} // of an insert or update
+}
+
+// Batch returns an empty batch of writes to t.
+func (t *Table) Batch() *Batch {
+	return &Batch{table: t}
+}
+
+// Insert adds an insert of row.
+func (b *Batch) Insert(row *Row) error {
+	values, err := rowColumns(row)
+	if err != nil {
+		return err
+	}
+	b.writes = append(b.writes, batchWrite{kind: batchInsert, rowid: -1, values: values})
+	return nil
+}
+
+// UpdateAt adds an update of the row at rowid to row.
+func (b *Batch) UpdateAt(rowid int64, row *Row) error {
+	values, err := rowColumns(row)
+	if err != nil {
+		return err
+	}
+	b.writes = append(b.writes, batchWrite{kind: batchUpdate, rowid: rowid, values: values})
+	return nil
+}
+
+// DeleteAt adds a delete of the row at rowid.
+func (b *Batch) DeleteAt(rowid int64) {
+	b.writes = append(b.writes, batchWrite{kind: batchDelete, rowid: rowid})
+}
+
+// Len returns the number of writes added since the last Reset or Commit.
+func (b *Batch) Len() int {
+	return len(b.writes)
+}
+
+// Reset empties the batch.
+func (b *Batch) Reset() {
+	b.writes = b.writes[:0]
+}
+
+// Commit applies the writes of b in the order they were added, holding the
+// table's write lock throughout, so snapshots and other goroutines see all of
+// them or none. The first failing write stops the commit and the writes
+// before it are undone, newest first: an insert is deleted, an update puts
+// back the row it replaced and a delete inserts the row again, at a new
+// rowid. Each write is still its own commit to the engine, so a crash during
+// Commit can leave part of the batch applied. On success the batch is reset;
+// on failure it keeps its writes.
+func (b *Batch) Commit() error {
+	t := b.table
+	t.use.enter("table", t.path)
+	defer t.use.leave()
+	t.frozen.Lock()
+	defer t.frozen.Unlock()
+
+	undo := make([]*batchWrite, 0, len(b.writes))
+	for i, w := range b.writes {
+		u, err := t.applyWrite(w)
+		if u != nil {
+			undo = append(undo, u)
+		}
+		if err == nil {
+			continue
+		}
+		err = &FlintDBError{Message: fmt.Sprintf("write %d: %v", i+1, err)}
+		for k := len(undo) - 1; k >= 0; k-- {
+			if _, uerr := t.applyWrite(*undo[k]); uerr != nil {
+				return &FlintDBError{Message: fmt.Sprintf("%v; undo of write %d: %v", err, k+1, uerr)}
+			}
+		}
+		return err
+	}
+	b.Reset()
+	return nil
+}
+
+// applyWrite applies w with the table locked and returns the write undoing
+// it, or nil when w failed without changing the table. An update is undone
+// even when it fails, since rewriting the row it replaced changes nothing.
+func (t *Table) applyWrite(w batchWrite) (*batchWrite, error) {
+	var before []interface{}
+	if w.kind != batchInsert {
+		row, err := t.Read(w.rowid)
+		if err != nil {
+			return nil, err
+		}
+		if before, err = rowColumns(row); err != nil {
+			return nil, err
+		}
+	}
+	var row *Row
+	if w.kind != batchDelete {
+		var err error
+		if row, err = t.CreateRow(); err != nil {
+			return nil, err
+		}
+		defer row.Free()
+		for i, v := range w.values {
+			if err := row.Set(i, v); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	switch w.kind {
+	case batchInsert:
+		// A row stored whose side index or history failed is undone too
+		rowid, err := t.insert(row)
+		if rowid < 0 {
+			return nil, err
+		}
+		return &batchWrite{kind: batchDelete, rowid: rowid}, err
+	case batchUpdate:
+		return &batchWrite{kind: batchUpdate, rowid: w.rowid, values: before}, t.updateAt(w.rowid, row)
+	}
+	if err := t.deleteAt(w.rowid); err != nil {
+		return nil, err
+	}
+	return &batchWrite{kind: batchInsert, rowid: -1, values: before}, nil
+}
+
+// rowColumns returns the values of the columns of row, in column order.
+func rowColumns(row *Row) ([]interface{}, error) {
+	values := make([]interface{}, int(row.meta.columns.length))
+	for i := range values {
+		v, err := row.Get(i)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
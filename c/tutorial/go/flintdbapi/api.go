@@ -0,0 +1,53 @@
+// Package flintdbapi describes FlintDB tables, cursors and delimited files by
+// small interfaces, for business logic to depend on instead of the cgo
+// types. Table.API and GenericFile.API of package flintdb implement them
+// over the engine; NewFakeTable and NewFakeGenericFile implement them in
+// memory, so unit tests need neither the C library nor files. This package
+// does not use cgo.
+package flintdbapi
+
+// Values holds the values of a row by column name. A table or file returns
+// every column, NULL as nil; one given to Insert, UpdateAt or Write may
+// leave columns out.
+type Values map[string]interface{}
+
+// TableAPI is a table, such as a *flintdb.Table through its API method.
+type TableAPI interface {
+	Columns() []string
+	// Insert inserts a row of values, the columns left out keeping their
+	// defaults, and returns its rowid.
+	Insert(values Values) (int64, error)
+	Read(rowid int64) (Values, error)
+	// UpdateAt sets the columns in values of the row at rowid.
+	UpdateAt(rowid int64, values Values) error
+	DeleteAt(rowid int64) error
+	// Find returns a cursor over the rowids of the rows query matches, such
+	// as "WHERE id > 10 LIMIT 5".
+	Find(query string) (CursorAPI, error)
+	Rows() (int64, error)
+	Close()
+}
+
+// CursorAPI iterates over the rowids found by TableAPI.Find; Next returns
+// -1 after the last one.
+type CursorAPI interface {
+	Next() (int64, error)
+	Close()
+}
+
+// GenericFileAPI is a delimited text file, such as a *flintdb.GenericFile
+// through its API method.
+type GenericFileAPI interface {
+	Columns() []string
+	Write(values Values) error
+	// Find returns a cursor over the rows query matches.
+	Find(query string) (RowCursorAPI, error)
+	Close() error
+}
+
+// RowCursorAPI iterates over the rows found by GenericFileAPI.Find; Next
+// returns nil after the last one.
+type RowCursorAPI interface {
+	Next() (Values, error)
+	Close()
+}
@@ -0,0 +1,310 @@
+package flintdbapi
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var errClosed = errors.New("flintdbapi: closed")
+
+// FakeTable is a TableAPI keeping its rows in memory. Rowids count from 0 in
+// insertion order and Find returns rows in rowid order; there are no
+// indexes or constraints. Queries are limited to
+// [WHERE column op literal [AND ...]] [LIMIT n], with op one of = != <> < <=
+// > >= and literal a number, a 'quoted' string or NULL. Values are stored as
+// the engine stores them: integers and bools as int64, floats as float64.
+type FakeTable struct {
+	mu      sync.Mutex
+	columns []string
+	rows    map[int64]Values
+	next    int64
+	closed  bool
+}
+
+// NewFakeTable returns an empty FakeTable of columns.
+func NewFakeTable(columns ...string) *FakeTable {
+	return &FakeTable{columns: columns, rows: map[int64]Values{}}
+}
+
+func (t *FakeTable) Columns() []string {
+	return append([]string(nil), t.columns...)
+}
+
+func (t *FakeTable) Insert(values Values) (int64, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return -1, errClosed
+	}
+	row := Values{}
+	for _, col := range t.columns {
+		row[col] = nil
+	}
+	if err := setValues(t.columns, row, values); err != nil {
+		return -1, err
+	}
+	rowid := t.next
+	t.next++
+	t.rows[rowid] = row
+	return rowid, nil
+}
+
+func (t *FakeTable) Read(rowid int64) (Values, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return nil, errClosed
+	}
+	row, ok := t.rows[rowid]
+	if !ok {
+		return nil, errors.New("row not found")
+	}
+	return copyValues(row), nil
+}
+
+func (t *FakeTable) UpdateAt(rowid int64, values Values) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return errClosed
+	}
+	row, ok := t.rows[rowid]
+	if !ok {
+		return errors.New("row not found")
+	}
+	changed := copyValues(row)
+	if err := setValues(t.columns, changed, values); err != nil {
+		return err
+	}
+	t.rows[rowid] = changed
+	return nil
+}
+
+func (t *FakeTable) DeleteAt(rowid int64) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return errClosed
+	}
+	if _, ok := t.rows[rowid]; !ok {
+		return errors.New("row not found")
+	}
+	delete(t.rows, rowid)
+	return nil
+}
+
+func (t *FakeTable) Find(query string) (CursorAPI, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return nil, errClosed
+	}
+	q, err := ParseFilter(query, t.columns)
+	if err != nil {
+		return nil, err
+	}
+	rowids := make([]int64, 0, len(t.rows))
+	for rowid := range t.rows {
+		rowids = append(rowids, rowid)
+	}
+	sort.Slice(rowids, func(i, j int) bool { return rowids[i] < rowids[j] })
+	var found []int64
+	for _, rowid := range rowids {
+		if q.Limit() >= 0 && len(found) == q.Limit() {
+			break
+		}
+		if q.Match(t.rows[rowid]) {
+			found = append(found, rowid)
+		}
+	}
+	return &fakeCursor{rowids: found}, nil
+}
+
+func (t *FakeTable) Rows() (int64, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return -1, errClosed
+	}
+	return int64(len(t.rows)), nil
+}
+
+func (t *FakeTable) Close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closed = true
+}
+
+type fakeCursor struct {
+	rowids []int64
+}
+
+func (c *fakeCursor) Next() (int64, error) {
+	if len(c.rowids) == 0 {
+		return -1, nil
+	}
+	rowid := c.rowids[0]
+	c.rowids = c.rowids[1:]
+	return rowid, nil
+}
+
+func (c *fakeCursor) Close() {
+	c.rowids = nil
+}
+
+// FakeGenericFile is a GenericFileAPI keeping the rows written in memory,
+// found in the order written with the queries of FakeTable.
+type FakeGenericFile struct {
+	mu      sync.Mutex
+	columns []string
+	rows    []Values
+	closed  bool
+}
+
+// NewFakeGenericFile returns an empty FakeGenericFile of columns.
+func NewFakeGenericFile(columns ...string) *FakeGenericFile {
+	return &FakeGenericFile{columns: columns}
+}
+
+func (f *FakeGenericFile) Columns() []string {
+	return append([]string(nil), f.columns...)
+}
+
+func (f *FakeGenericFile) Write(values Values) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return errClosed
+	}
+	row := Values{}
+	for _, col := range f.columns {
+		row[col] = nil
+	}
+	if err := setValues(f.columns, row, values); err != nil {
+		return err
+	}
+	f.rows = append(f.rows, row)
+	return nil
+}
+
+func (f *FakeGenericFile) Find(query string) (RowCursorAPI, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return nil, errClosed
+	}
+	q, err := ParseFilter(query, f.columns)
+	if err != nil {
+		return nil, err
+	}
+	var found []Values
+	for _, row := range f.rows {
+		if q.Limit() >= 0 && len(found) == q.Limit() {
+			break
+		}
+		if q.Match(row) {
+			found = append(found, copyValues(row))
+		}
+	}
+	return &fakeRowCursor{rows: found}, nil
+}
+
+func (f *FakeGenericFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	return nil
+}
+
+type fakeRowCursor struct {
+	rows []Values
+}
+
+func (c *fakeRowCursor) Next() (Values, error) {
+	if len(c.rows) == 0 {
+		return nil, nil
+	}
+	row := c.rows[0]
+	c.rows = c.rows[1:]
+	return row, nil
+}
+
+func (c *fakeRowCursor) Close() {
+	c.rows = nil
+}
+
+// columnOf returns the column of columns named name, regardless of case as
+// the engine matches names.
+func columnOf(columns []string, name string) (string, bool) {
+	for _, col := range columns {
+		if strings.EqualFold(col, name) {
+			return col, true
+		}
+	}
+	return "", false
+}
+
+// setValues sets values in row, converted as the engine would store them.
+func setValues(columns []string, row, values Values) error {
+	for name, v := range values {
+		col, ok := columnOf(columns, name)
+		if !ok {
+			return fmt.Errorf("column not found: %s", name)
+		}
+		stored, err := storedValue(v)
+		if err != nil {
+			return err
+		}
+		row[col] = stored
+	}
+	return nil
+}
+
+// storedValue converts v, of a type flintdb.Row.Set takes, to the type the
+// engine would return.
+func storedValue(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case nil, int64, float64, string, time.Time:
+		return v, nil
+	case bool:
+		if v {
+			return int64(1), nil
+		}
+		return int64(0), nil
+	case int:
+		return int64(v), nil
+	case int8:
+		return int64(v), nil
+	case int16:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case uint8:
+		return int64(v), nil
+	case uint16:
+		return int64(v), nil
+	case uint32:
+		return int64(v), nil
+	case float32:
+		return float64(v), nil
+	case []byte:
+		return append([]byte(nil), v...), nil
+	default:
+		return nil, fmt.Errorf("unsupported value type: %T", v)
+	}
+}
+
+func copyValues(row Values) Values {
+	c := make(Values, len(row))
+	for col, v := range row {
+		if b, ok := v.([]byte); ok {
+			v = append([]byte(nil), b...)
+		}
+		c[col] = v
+	}
+	return c
+}
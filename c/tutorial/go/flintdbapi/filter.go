@@ -0,0 +1,213 @@
+package flintdbapi
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Filter is a query of the form the fakes take, for code that matches rows
+// without the engine, such as the cgo-free reader of package flintdbfile:
+// conditions joined by AND and a limit.
+type Filter struct {
+	conds []filterCond
+	limit int
+}
+
+type filterCond struct {
+	column string
+	op     string
+	value  interface{} // int64, float64, string or nil for NULL
+}
+
+// ParseFilter parses query, [WHERE column op literal [AND ...]] [LIMIT n],
+// over columns. The columns it names become those of columns, whatever their
+// case in query.
+func ParseFilter(query string, columns []string) (*Filter, error) {
+	tokens, err := filterTokens(query)
+	if err != nil {
+		return nil, err
+	}
+	q := &Filter{limit: -1}
+	pos := 0
+	if pos < len(tokens) && strings.EqualFold(tokens[pos], "WHERE") {
+		for pos++; ; pos++ {
+			if pos+3 > len(tokens) {
+				return nil, fmt.Errorf("incomplete condition in %q", query)
+			}
+			col, ok := columnOf(columns, tokens[pos])
+			if !ok {
+				return nil, fmt.Errorf("column not found: %s", tokens[pos])
+			}
+			op := tokens[pos+1]
+			switch op {
+			case "=", "!=", "<>", "<", "<=", ">", ">=":
+			default:
+				return nil, fmt.Errorf("unsupported operator %s in %q", op, query)
+			}
+			value, err := filterLiteral(tokens[pos+2])
+			if err != nil {
+				return nil, err
+			}
+			q.conds = append(q.conds, filterCond{column: col, op: op, value: value})
+			pos += 3
+			if pos == len(tokens) || !strings.EqualFold(tokens[pos], "AND") {
+				break
+			}
+		}
+	}
+	if pos < len(tokens) && strings.EqualFold(tokens[pos], "LIMIT") {
+		if pos+1 == len(tokens) {
+			return nil, fmt.Errorf("missing limit in %q", query)
+		}
+		n, err := strconv.Atoi(tokens[pos+1])
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid limit %s in %q", tokens[pos+1], query)
+		}
+		q.limit = n
+		pos += 2
+	}
+	if pos != len(tokens) {
+		return nil, fmt.Errorf("unsupported query %q: queries take [WHERE column op literal [AND ...]] [LIMIT n]", query)
+	}
+	return q, nil
+}
+
+// filterTokens splits query into words, 'quoted' strings and operators.
+func filterTokens(query string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			i++
+		case c == '\'':
+			j := i + 1
+			for ; j < len(query); j++ {
+				if query[j] == '\'' {
+					if j+1 < len(query) && query[j+1] == '\'' {
+						j++
+						continue
+					}
+					break
+				}
+			}
+			if j == len(query) {
+				return nil, fmt.Errorf("unterminated string in %q", query)
+			}
+			tokens = append(tokens, query[i:j+1])
+			i = j + 1
+		case strings.IndexByte("=!<>", c) >= 0:
+			j := i + 1
+			if j < len(query) && strings.IndexByte("=>", query[j]) >= 0 {
+				j++
+			}
+			tokens = append(tokens, query[i:j])
+			i = j
+		default:
+			j := i
+			for j < len(query) && strings.IndexByte(" \t\r\n'=!<>", query[j]) < 0 {
+				j++
+			}
+			tokens = append(tokens, query[i:j])
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+// Limit returns the limit of f, or -1 if it has none.
+func (f *Filter) Limit() int {
+	return f.limit
+}
+
+// Equal returns the literal f requires column to equal, nil for NULL, and
+// whether there is one.
+func (f *Filter) Equal(column string) (interface{}, bool) {
+	for _, c := range f.conds {
+		if c.op == "=" && strings.EqualFold(c.column, column) {
+			return c.value, true
+		}
+	}
+	return nil, false
+}
+
+func filterLiteral(token string) (interface{}, error) {
+	if strings.HasPrefix(token, "'") {
+		return strings.ReplaceAll(token[1:len(token)-1], "''", "'"), nil
+	}
+	if strings.EqualFold(token, "NULL") {
+		return nil, nil
+	}
+	if n, err := strconv.ParseInt(token, 10, 64); err == nil {
+		return n, nil
+	}
+	if f, err := strconv.ParseFloat(token, 64); err == nil {
+		return f, nil
+	}
+	return nil, fmt.Errorf("unsupported literal %s", token)
+}
+
+// Match reports whether row meets the conditions of f.
+func (f *Filter) Match(row Values) bool {
+	for _, c := range f.conds {
+		v := row[c.column]
+		if v == nil || c.value == nil {
+			// A NULL matches only = NULL, and a value only != NULL.
+			switch {
+			case c.value == nil && c.op == "=" && v == nil:
+			case c.value == nil && (c.op == "!=" || c.op == "<>") && v != nil:
+			default:
+				return false
+			}
+			continue
+		}
+		cmp := compareValues(v, c.value)
+		var ok bool
+		switch c.op {
+		case "=":
+			ok = cmp == 0
+		case "!=", "<>":
+			ok = cmp != 0
+		case "<":
+			ok = cmp < 0
+		case "<=":
+			ok = cmp <= 0
+		case ">":
+			ok = cmp > 0
+		case ">=":
+			ok = cmp >= 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// compareValues compares numbers as numbers and other values by their
+// text.
+func compareValues(a, b interface{}) int {
+	x, xok := number(a)
+	y, yok := number(b)
+	if xok && yok {
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+func number(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
@@ -0,0 +1,357 @@
+package flintdbfile
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Column types, with the values of the VARIANT_ types of package flintdb.
+const (
+	VARIANT_NULL    = 0
+	VARIANT_INT32   = 2
+	VARIANT_UINT32  = 3
+	VARIANT_INT8    = 4
+	VARIANT_UINT8   = 5
+	VARIANT_INT16   = 6
+	VARIANT_UINT16  = 7
+	VARIANT_INT64   = 8
+	VARIANT_DOUBLE  = 9
+	VARIANT_FLOAT   = 10
+	VARIANT_STRING  = 11
+	VARIANT_DECIMAL = 12
+	VARIANT_BYTES   = 13
+	VARIANT_DATE    = 14
+	VARIANT_TIME    = 15
+	VARIANT_UUID    = 16
+	VARIANT_IPV6    = 17
+	VARIANT_BLOB    = 18
+	VARIANT_OBJECT  = 31
+)
+
+// PRIMARY_NAME is the name of the primary key index.
+const PRIMARY_NAME = "primary"
+
+// typeNames maps the type names of a CREATE TABLE, and their SQL aliases,
+// to column types.
+var typeNames = map[string]int{
+	"INT": VARIANT_INT32, "UINT": VARIANT_UINT32, "INT8": VARIANT_INT8, "UINT8": VARIANT_UINT8,
+	"INT16": VARIANT_INT16, "UINT16": VARIANT_UINT16, "INT64": VARIANT_INT64,
+	"DOUBLE": VARIANT_DOUBLE, "FLOAT": VARIANT_FLOAT, "DATE": VARIANT_DATE, "TIME": VARIANT_TIME,
+	"UUID": VARIANT_UUID, "IPV6": VARIANT_IPV6, "STRING": VARIANT_STRING, "DECIMAL": VARIANT_DECIMAL,
+	"BYTES": VARIANT_BYTES, "BLOB": VARIANT_BLOB, "OBJECT": VARIANT_OBJECT,
+	"VARCHAR": VARIANT_STRING, "CHAR": VARIANT_STRING, "TEXT": VARIANT_STRING, "NUMERIC": VARIANT_DECIMAL,
+	"BINARY": VARIANT_BYTES, "VARBINARY": VARIANT_BYTES, "JSON": VARIANT_OBJECT,
+	"DATETIME": VARIANT_TIME, "TIMESTAMP": VARIANT_TIME,
+}
+
+// Column is a column of a table.
+type Column struct {
+	Name      string
+	Type      int // a VARIANT_ type
+	Size      int // bytes of a STRING, DECIMAL or BYTES value
+	Precision int // digits after the point of a DECIMAL
+	NotNull   bool
+	Default   string
+	Comment   string
+}
+
+// Index is an index of a table by its key columns.
+type Index struct {
+	Name string
+	Keys []string
+}
+
+// Schema is a table as the CREATE TABLE of its .desc file describes it.
+type Schema struct {
+	Name    string
+	Columns []Column
+	Indexes []Index // the primary key first
+	// Options holds the options after the column list, such as STORAGE,
+	// COMPRESSOR, CHECKSUM and COMPACT, by upper-case name.
+	Options map[string]string
+}
+
+// ParseSchema parses the CREATE TABLE statement a .desc file holds.
+func ParseSchema(sql string) (*Schema, error) {
+	s := strings.TrimSpace(sql)
+	const create = "CREATE TABLE"
+	if len(s) < len(create) || !strings.EqualFold(s[:len(create)], create) {
+		return nil, fmt.Errorf("flintdbfile: not a CREATE TABLE: %.40q", s)
+	}
+	s = s[len(create):]
+	open := strings.IndexByte(s, '(')
+	if open < 0 {
+		return nil, fmt.Errorf("flintdbfile: CREATE TABLE has no column list")
+	}
+	end := closing(s, open)
+	if end < 0 {
+		return nil, fmt.Errorf("flintdbfile: CREATE TABLE column list is not closed")
+	}
+	schema := &Schema{Name: strings.TrimSpace(s[:open]), Options: map[string]string{}}
+	for _, def := range splitTop(s[open+1:end], ',') {
+		words := tokens(def)
+		if len(words) == 0 {
+			continue
+		}
+		var err error
+		switch {
+		case len(words) >= 3 && strings.EqualFold(words[0], "PRIMARY") && strings.EqualFold(words[1], "KEY"):
+			schema.Indexes = append([]Index{{Name: PRIMARY_NAME, Keys: keyList(words[2])}}, schema.Indexes...)
+		case len(words) >= 3 && strings.EqualFold(words[0], "KEY"):
+			schema.Indexes = append(schema.Indexes, Index{Name: words[1], Keys: keyList(words[2])})
+		default:
+			err = schema.addColumn(words)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, opt := range splitTop(s[end+1:], ',') {
+		k, v, ok := strings.Cut(strings.TrimSpace(opt), "=")
+		if ok {
+			schema.Options[strings.ToUpper(strings.TrimSpace(k))] = strings.TrimSpace(v)
+		}
+	}
+	if len(schema.Columns) == 0 {
+		return nil, fmt.Errorf("flintdbfile: table %s has no columns", schema.Name)
+	}
+	if len(schema.Indexes) == 0 || schema.Indexes[0].Name != PRIMARY_NAME {
+		return nil, fmt.Errorf("flintdbfile: table %s has no primary key", schema.Name)
+	}
+	return schema, nil
+}
+
+func (s *Schema) addColumn(words []string) error {
+	if len(words) < 2 {
+		return fmt.Errorf("flintdbfile: bad column definition: %s", strings.Join(words, " "))
+	}
+	c := Column{Name: words[0], Size: -1}
+	tname, params := words[1], ""
+	if i := strings.IndexByte(tname, '('); i > 0 && strings.HasSuffix(tname, ")") {
+		tname, params = tname[:i], tname[i:]
+	} else if len(words) > 2 && strings.HasPrefix(words[2], "(") {
+		params, words = words[2], append(words[:2:2], words[3:]...)
+	}
+	t, ok := typeNames[strings.ToUpper(tname)]
+	if !ok {
+		return fmt.Errorf("flintdbfile: column %s: unknown type %s", c.Name, tname)
+	}
+	c.Type = t
+	if params != "" {
+		parts := splitTop(params[1:len(params)-1], ',')
+		if len(parts) >= 1 && strings.TrimSpace(parts[0]) != "" {
+			c.Size, _ = strconv.Atoi(strings.TrimSpace(parts[0]))
+		}
+		if len(parts) >= 2 {
+			c.Precision, _ = strconv.Atoi(strings.TrimSpace(parts[1]))
+		}
+	}
+	for i := 2; i < len(words); i++ {
+		switch strings.ToUpper(words[i]) {
+		case "NOT":
+			if i+1 < len(words) && strings.EqualFold(words[i+1], "NULL") {
+				c.NotNull = true
+				i++
+			}
+		case "DEFAULT":
+			if i+1 < len(words) {
+				c.Default = unquote(words[i+1])
+				i++
+			}
+		case "COMMENT":
+			if i+1 < len(words) {
+				c.Comment = unquote(words[i+1])
+				i++
+			}
+		}
+	}
+	if c.Size < 0 {
+		c.Size = fixedBytes(c.Type)
+	}
+	switch c.Type {
+	case VARIANT_STRING, VARIANT_DECIMAL, VARIANT_BYTES, VARIANT_BLOB:
+		if c.Size <= 0 {
+			return fmt.Errorf("flintdbfile: column %s has no size", c.Name)
+		}
+	}
+	s.Columns = append(s.Columns, c)
+	return nil
+}
+
+// Column returns the index of the column named name, or -1.
+func (s *Schema) Column(name string) int {
+	for i, c := range s.Columns {
+		if strings.EqualFold(c.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// optionBytes returns the option named name, a size like 4K, or def if it
+// is absent.
+func (s *Schema) optionBytes(name string, def int) int {
+	v, ok := s.Options[name]
+	if !ok || v == "" {
+		return def
+	}
+	m := 1
+	switch v[len(v)-1] {
+	case 'K', 'k':
+		m = 1024
+	case 'M', 'm':
+		m = 1024 * 1024
+	case 'G', 'g':
+		m = 1024 * 1024 * 1024
+	}
+	if m > 1 {
+		v = v[:len(v)-1]
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return def
+	}
+	return n * m
+}
+
+// rowBytes returns the most bytes an encoded row of s takes.
+func (s *Schema) rowBytes() int {
+	n := 2 // column count
+	for _, c := range s.Columns {
+		n += 2 // type
+		switch c.Type {
+		case VARIANT_STRING, VARIANT_DECIMAL, VARIANT_BYTES, VARIANT_BLOB:
+			n += 2 + c.Size
+		default:
+			n += fixedBytes(c.Type)
+		}
+	}
+	return n
+}
+
+// fixedBytes returns the bytes a value of a fixed-size type takes.
+func fixedBytes(t int) int {
+	switch t {
+	case VARIANT_INT8, VARIANT_UINT8:
+		return 1
+	case VARIANT_INT16, VARIANT_UINT16:
+		return 2
+	case VARIANT_INT32, VARIANT_UINT32, VARIANT_FLOAT:
+		return 4
+	case VARIANT_INT64, VARIANT_DOUBLE, VARIANT_TIME:
+		return 8
+	case VARIANT_DATE:
+		return 3
+	case VARIANT_UUID, VARIANT_IPV6:
+		return 16
+	}
+	return 0
+}
+
+// closing returns the index of the parenthesis closing the one at open, or
+// -1.
+func closing(s string, open int) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '\'':
+			i = quoteEnd(s, i)
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// quoteEnd returns the index of the quote closing the one at i.
+func quoteEnd(s string, i int) int {
+	for i++; i < len(s); i++ {
+		if s[i] == '\\' {
+			i++
+		} else if s[i] == '\'' {
+			return i
+		}
+	}
+	return len(s)
+}
+
+// splitTop splits s at the seps outside quotes and parentheses.
+func splitTop(s string, sep byte) []string {
+	var parts []string
+	depth, from := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\'':
+			i = quoteEnd(s, i)
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case sep:
+			if depth == 0 {
+				parts = append(parts, s[from:i])
+				from = i + 1
+			}
+		}
+	}
+	return append(parts, s[from:])
+}
+
+// tokens splits a column or key definition into words, keeping quoted
+// values and parenthesized lists, with what they are attached to, whole.
+func tokens(s string) []string {
+	var words []string
+	from := -1
+	for i := 0; i <= len(s); i++ {
+		if i == len(s) || s[i] == ' ' || s[i] == '\t' || s[i] == '\n' || s[i] == '\r' {
+			if from >= 0 {
+				words = append(words, s[from:i])
+				from = -1
+			}
+			continue
+		}
+		if from < 0 {
+			from = i
+		}
+		switch s[i] {
+		case '\'':
+			i = quoteEnd(s, i)
+		case '(':
+			if end := closing(s, i); end > 0 {
+				i = end
+			}
+		}
+	}
+	return words
+}
+
+// keyList returns the column names of a parenthesized key list.
+func keyList(group string) []string {
+	group = strings.TrimSuffix(strings.TrimPrefix(group, "("), ")")
+	var keys []string
+	for _, k := range splitTop(group, ',') {
+		keys = append(keys, strings.ReplaceAll(strings.TrimSpace(k), " ", ""))
+	}
+	return keys
+}
+
+// unquote returns a quoted value without its quotes and escapes.
+func unquote(v string) string {
+	if len(v) < 2 || v[0] != '\'' || v[len(v)-1] != '\'' {
+		return v
+	}
+	v = v[1 : len(v)-1]
+	var sb strings.Builder
+	for i := 0; i < len(v); i++ {
+		if v[i] == '\\' && i+1 < len(v) {
+			i++
+		}
+		sb.WriteByte(v[i])
+	}
+	return sb.String()
+}
@@ -0,0 +1,252 @@
+package flintdbfile
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	headerBytes      = 16384 // file header before the first block
+	blockHeaderBytes = 16    // status, mark, chunk length, total length, next block
+	nodeBytes        = 1008  // data bytes of a block of an index file
+
+	statusSet = '+'
+	markData  = 'D'
+	nextEnd   = -1
+)
+
+// errDeleted is returned by storage.read for a block that holds no record.
+var errDeleted = errors.New("flintdbfile: row not found")
+
+// storage reads the records of a table or index file: fixed-size blocks
+// after the file header, a record that does not fit in one continuing in
+// the blocks its next links to.
+type storage struct {
+	r          io.ReaderAt
+	name       string
+	blockBytes int64
+}
+
+func (s *storage) block(i int64, b []byte) error {
+	if i < 0 {
+		return fmt.Errorf("flintdbfile: %s: bad block %d", s.name, i)
+	}
+	n, err := s.r.ReadAt(b, headerBytes+i*s.blockBytes)
+	if n == len(b) {
+		return nil
+	}
+	if err == io.EOF {
+		return errDeleted
+	}
+	return fmt.Errorf("flintdbfile: %s: block %d: %w", s.name, i, err)
+}
+
+// read returns the record starting at block i.
+func (s *storage) read(i int64) ([]byte, error) {
+	b := make([]byte, s.blockBytes)
+	if err := s.block(i, b); err != nil {
+		return nil, err
+	}
+	if b[0] != statusSet || b[1] != markData {
+		return nil, errDeleted
+	}
+	limit := int64(int16(binary.LittleEndian.Uint16(b[2:])))
+	length := int64(int32(binary.LittleEndian.Uint32(b[4:])))
+	next := int64(binary.LittleEndian.Uint64(b[8:]))
+	if limit < 0 || limit > s.blockBytes-blockHeaderBytes {
+		return nil, fmt.Errorf("flintdbfile: %s: block %d is corrupted", s.name, i)
+	}
+	data := append([]byte(nil), b[blockHeaderBytes:blockHeaderBytes+limit]...)
+	for next > nextEnd && length > limit && int64(len(data)) < length {
+		if err := s.block(next, b); err != nil {
+			return nil, err
+		}
+		if b[0] != statusSet {
+			break
+		}
+		chunk := int64(int16(binary.LittleEndian.Uint16(b[2:])))
+		if chunk < 0 || chunk > s.blockBytes-blockHeaderBytes {
+			return nil, fmt.Errorf("flintdbfile: %s: block %d is corrupted", s.name, next)
+		}
+		data = append(data, b[blockHeaderBytes:blockHeaderBytes+chunk]...)
+		next = int64(binary.LittleEndian.Uint64(b[8:]))
+	}
+	return data, nil
+}
+
+// node is a node of a B+tree index. The keys of a leaf are rowids in key
+// order. Those of an internal node are the leaves whose first rowids divide
+// its children: children[i] holds the rows before the first row of leaf
+// keys[i], children[i+1] those from it.
+type node struct {
+	leaf     bool
+	right    int64 // the next leaf
+	keys     []int64
+	children []int64
+}
+
+const internalMark = -2
+
+// btree reads a B+tree index file.
+type btree struct {
+	s     *storage
+	root  int64 // -1 for an empty tree
+	count int64
+}
+
+func openBTree(r io.ReaderAt, name string) (*btree, error) {
+	t := &btree{s: &storage{r: r, name: name, blockBytes: blockHeaderBytes + nodeBytes}, root: -1}
+	b, err := t.s.read(0)
+	if err == errDeleted {
+		return t, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < 12 || string(b[:4]) != "ROOT" {
+		return t, nil
+	}
+	t.root = int64(binary.LittleEndian.Uint64(b[4:]))
+	if len(b) >= 24 && string(b[12:16]) == "CNT!" {
+		t.count = int64(binary.LittleEndian.Uint64(b[16:]))
+	}
+	return t, nil
+}
+
+func (t *btree) node(offset int64) (*node, error) {
+	b, err := t.s.read(offset)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < 16 {
+		return nil, fmt.Errorf("flintdbfile: %s: node %d is corrupted", t.s.name, offset)
+	}
+	first := int64(binary.LittleEndian.Uint64(b))
+	second := int64(binary.LittleEndian.Uint64(b[8:]))
+	b = b[16:]
+	if first != internalMark {
+		n := &node{leaf: true, right: second}
+		for ; len(b) >= 8; b = b[8:] {
+			k := int64(binary.LittleEndian.Uint64(b))
+			if k == nextEnd {
+				break
+			}
+			n.keys = append(n.keys, k)
+		}
+		return n, nil
+	}
+	n := &node{children: []int64{second}}
+	for ; len(b) >= 16; b = b[16:] {
+		n.keys = append(n.keys, int64(binary.LittleEndian.Uint64(b)))
+		n.children = append(n.children, int64(binary.LittleEndian.Uint64(b[8:])))
+	}
+	return n, nil
+}
+
+// first returns the first rowid of the leaf at offset.
+func (t *btree) first(offset int64) (int64, error) {
+	n, err := t.node(offset)
+	if err != nil {
+		return -1, err
+	}
+	if !n.leaf || len(n.keys) == 0 {
+		return -1, fmt.Errorf("flintdbfile: %s: node %d is not a leaf", t.s.name, offset)
+	}
+	return n.keys[0], nil
+}
+
+// search returns the rowid of the row compare reports equal to the key
+// searched for, or -1. compare returns the key's order to the row at rowid.
+func (t *btree) search(compare func(rowid int64) (int, error)) (int64, error) {
+	offset := t.root
+	for offset > 0 {
+		n, err := t.node(offset)
+		if err != nil {
+			return -1, err
+		}
+		at := func(i int) (int, error) {
+			if n.leaf {
+				return compare(n.keys[i])
+			}
+			rowid, err := t.first(n.keys[i])
+			if err != nil {
+				return 0, err
+			}
+			return compare(rowid)
+		}
+		i, d, err := position(len(n.keys), at)
+		if err != nil {
+			return -1, err
+		}
+		switch {
+		case n.leaf && d == 0:
+			return n.keys[i], nil
+		case n.leaf:
+			return -1, nil
+		case d == 0:
+			return t.first(n.keys[i])
+		case d < 0:
+			offset = n.children[i]
+		default:
+			offset = n.children[i+1]
+		}
+	}
+	return -1, nil
+}
+
+// position binary searches n keys for the one cmp reports equal and returns
+// its index and 0. Otherwise it returns the key before the one searched for
+// and 1, or the key after it and -1.
+func position(n int, cmp func(i int) (int, error)) (int, int, error) {
+	low, high, c := 0, n-1, 0
+	for low <= high {
+		mid := (low + high) / 2
+		var err error
+		if c, err = cmp(mid); err != nil {
+			return 0, 0, err
+		}
+		switch {
+		case c > 0:
+			low = mid + 1
+		case c < 0:
+			high = mid - 1
+		default:
+			return mid, 0, nil
+		}
+	}
+	if c > 0 {
+		return high, 1, nil
+	}
+	return low, -1, nil
+}
+
+// each calls fn with the rowids of the tree in key order until it returns
+// false or an error.
+func (t *btree) each(fn func(rowid int64) (bool, error)) error {
+	offset := t.root
+	for offset > 0 {
+		n, err := t.node(offset)
+		if err != nil {
+			return err
+		}
+		if n.leaf {
+			break
+		}
+		offset = n.children[0]
+	}
+	for offset > 0 {
+		n, err := t.node(offset)
+		if err != nil {
+			return err
+		}
+		for _, rowid := range n.keys {
+			if more, err := fn(rowid); !more || err != nil {
+				return err
+			}
+		}
+		offset = n.right
+	}
+	return nil
+}
@@ -0,0 +1,693 @@
+// Package flintdbfile reads FlintDB tables from their files without the C
+// engine: the schema in the .desc file, the rows in the table file and the
+// primary key index. It does not use cgo, so programs that only read
+// tables, such as report generators, cross-compile with CGO_ENABLED=0 and
+// build for js/wasm and wasip1:
+//
+//	t, _ := flintdbfile.Open("temp/orders.flintdb")
+//	defer t.Close()
+//	rowid, _ := t.Lookup(int64(42))
+//	values, _ := t.Read(rowid)
+//
+// Find takes the queries of the fakes of package flintdbapi, such as
+// "WHERE region = 'EU' LIMIT 10", and command flintdbread prints its rows
+// as TSV or JSON lines, under wasmtime or node as well.
+//
+// OpenFS reads tables from an fs.FS, such as an embed.FS or files a browser
+// fetched. It reads what was on disk when the table was last closed or
+// checkpointed, not changes still in its write-ahead log. Tables compressed
+// with lz4 or zstd, or with a storage other than MMAP or DIO, are not read.
+package flintdbfile
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"math"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"flintdb-tutorial/flintdbapi"
+)
+
+const (
+	descSuffix  = ".desc"
+	indexSuffix = ".i."
+
+	compressedBlockBytes = 128 // data bytes of a block of a compressed table
+)
+
+var errClosed = errors.New("flintdbfile: table is closed")
+
+// Table is a table opened for reading.
+type Table struct {
+	path     string
+	schema   *Schema
+	data     *storage
+	primary  *btree
+	keys     []int // columns of the primary key
+	deflate  bool
+	checksum func([]byte) uint32
+	files    []io.Closer
+}
+
+// opener opens the file of a table named name.
+type opener func(name string) (io.ReaderAt, io.Closer, error)
+
+// Open opens the table at path, the file named like "orders.flintdb".
+func Open(path string) (*Table, error) {
+	return open(path, func(name string) (io.ReaderAt, io.Closer, error) {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		return f, f, nil
+	})
+}
+
+// OpenFS opens the table at path in fsys. Files of fsys that are not
+// io.ReaderAt are read into memory.
+func OpenFS(fsys fs.FS, path string) (*Table, error) {
+	return open(path, func(name string) (io.ReaderAt, io.Closer, error) {
+		f, err := fsys.Open(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		if r, ok := f.(io.ReaderAt); ok {
+			return r, f, nil
+		}
+		defer f.Close()
+		b, err := io.ReadAll(f)
+		if err != nil {
+			return nil, nil, err
+		}
+		return bytes.NewReader(b), io.NopCloser(nil), nil
+	})
+}
+
+func open(path string, openFile opener) (_ *Table, err error) {
+	t := &Table{path: path}
+	defer func() {
+		if err != nil {
+			t.Close()
+		}
+	}()
+	file := func(name string) (io.ReaderAt, error) {
+		r, c, err := openFile(name)
+		if err != nil {
+			return nil, err
+		}
+		t.files = append(t.files, c)
+		return r, nil
+	}
+
+	desc, err := file(path + descSuffix)
+	if err != nil {
+		return nil, err
+	}
+	sql, err := io.ReadAll(io.NewSectionReader(desc, 0, math.MaxInt64))
+	if err != nil {
+		return nil, err
+	}
+	if t.schema, err = ParseSchema(string(sql)); err != nil {
+		return nil, err
+	}
+	for _, k := range t.schema.Indexes[0].Keys {
+		i := t.schema.Column(k)
+		if i < 0 {
+			return nil, fmt.Errorf("flintdbfile: primary key column %s does not exist", k)
+		}
+		t.keys = append(t.keys, i)
+	}
+	if err = t.formats(); err != nil {
+		return nil, err
+	}
+
+	r, err := file(path)
+	if err != nil {
+		return nil, err
+	}
+	var head [4]byte
+	if _, err = r.ReadAt(head[:], 0); err != nil || string(head[:]) != "ITBL" {
+		return nil, fmt.Errorf("flintdbfile: %s is not a table file", path)
+	}
+	rowBytes := t.schema.rowBytes()
+	blockData := t.schema.optionBytes("COMPACT", -1)
+	if blockData <= 0 {
+		blockData = rowBytes
+		if t.deflate && rowBytes > compressedBlockBytes {
+			blockData = compressedBlockBytes
+		}
+	}
+	t.data = &storage{r: r, name: path, blockBytes: int64(blockHeaderBytes + blockData)}
+
+	ix := path + indexSuffix + PRIMARY_NAME
+	r, err = file(ix)
+	if err != nil {
+		return nil, err
+	}
+	if t.primary, err = openBTree(r, ix); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// formats checks that the table's storage, compressor and checksum are
+// ones the reader knows.
+func (t *Table) formats() error {
+	opts := t.schema.Options
+	switch strings.ToUpper(opts["STORAGE"]) {
+	case "", "MMAP", "DIO":
+	default:
+		return fmt.Errorf("flintdbfile: storage not supported: %s", opts["STORAGE"])
+	}
+	switch strings.ToLower(opts["COMPRESSOR"]) {
+	case "", "none", "mmap":
+	case "deflate":
+		t.deflate = true
+	default:
+		return fmt.Errorf("flintdbfile: compressor not supported: %s", opts["COMPRESSOR"])
+	}
+	switch strings.ToLower(opts["CHECKSUM"]) {
+	case "", "none":
+	case "crc32":
+		t.checksum = crc32.ChecksumIEEE
+	case "xxhash":
+		t.checksum = xxhash32
+	default:
+		return fmt.Errorf("flintdbfile: checksum not supported: %s", opts["CHECKSUM"])
+	}
+	return nil
+}
+
+// Close closes the table's files.
+func (t *Table) Close() {
+	for _, f := range t.files {
+		f.Close()
+	}
+	t.files = nil
+	t.data = nil
+}
+
+// Schema returns the table's schema.
+func (t *Table) Schema() *Schema {
+	return t.schema
+}
+
+// Columns returns the names of the table's columns.
+func (t *Table) Columns() []string {
+	names := make([]string, len(t.schema.Columns))
+	for i, c := range t.schema.Columns {
+		names[i] = c.Name
+	}
+	return names
+}
+
+// Rows returns the number of rows of the table.
+func (t *Table) Rows() (int64, error) {
+	if t.data == nil {
+		return -1, errClosed
+	}
+	return t.primary.count, nil
+}
+
+// Read returns the values of the row at rowid, NULL as nil. Values are of
+// the types Row.Get of package flintdb returns: int64, float64, string,
+// []byte and time.Time, and a string for a DECIMAL.
+func (t *Table) Read(rowid int64) (flintdbapi.Values, error) {
+	row, err := t.row(rowid)
+	if err != nil {
+		return nil, err
+	}
+	values := make(flintdbapi.Values, len(row))
+	for i, v := range row {
+		values[t.schema.Columns[i].Name] = v
+	}
+	return values, nil
+}
+
+// Lookup returns the rowid of the row whose primary key is key, one value
+// per key column, or -1 if there is none.
+func (t *Table) Lookup(key ...interface{}) (int64, error) {
+	if t.data == nil {
+		return -1, errClosed
+	}
+	if len(key) != len(t.keys) {
+		return -1, fmt.Errorf("flintdbfile: primary key has %d columns, got %d values", len(t.keys), len(key))
+	}
+	want := make([]interface{}, len(key))
+	for i, k := range key {
+		c := t.schema.Columns[t.keys[i]]
+		v, err := keyValue(c, k)
+		if err != nil {
+			return -1, fmt.Errorf("flintdbfile: column %s: %w", c.Name, err)
+		}
+		want[i] = v
+	}
+	return t.primary.search(func(rowid int64) (int, error) {
+		row, err := t.row(rowid)
+		if err != nil {
+			return 0, err
+		}
+		for i, col := range t.keys {
+			if c := compare(t.schema.Columns[col].Type, want[i], row[col]); c != 0 {
+				return c, nil
+			}
+		}
+		return 0, nil
+	})
+}
+
+// Scan calls fn with the rows of the table in primary key order until fn
+// returns an error, which Scan returns.
+func (t *Table) Scan(fn func(rowid int64, values flintdbapi.Values) error) error {
+	if t.data == nil {
+		return errClosed
+	}
+	return t.primary.each(func(rowid int64) (bool, error) {
+		values, err := t.Read(rowid)
+		if err != nil {
+			return false, err
+		}
+		return true, fn(rowid, values)
+	})
+}
+
+// Find returns a cursor over the rowids of the rows query matches, in
+// primary key order. Queries are those flintdbapi.ParseFilter takes, such as
+// "WHERE id = 42" or "WHERE price > 10 AND qty <= 5 LIMIT 20"; one setting
+// every primary key column with = looks the row up instead of scanning.
+func (t *Table) Find(query string) (flintdbapi.CursorAPI, error) {
+	if t.data == nil {
+		return nil, errClosed
+	}
+	f, err := flintdbapi.ParseFilter(query, t.Columns())
+	if err != nil {
+		return nil, fmt.Errorf("flintdbfile: %w", err)
+	}
+	c := &cursor{}
+	if f.Limit() == 0 {
+		return c, nil
+	}
+	if key, ok := t.equalKey(f); ok {
+		rowid, err := t.Lookup(key...)
+		if err != nil {
+			return nil, err
+		}
+		if rowid >= 0 {
+			values, err := t.Read(rowid)
+			if err != nil {
+				return nil, err
+			}
+			if f.Match(values) {
+				c.rowids = append(c.rowids, rowid)
+			}
+		}
+		return c, nil
+	}
+	err = t.primary.each(func(rowid int64) (bool, error) {
+		values, err := t.Read(rowid)
+		if err != nil {
+			return false, err
+		}
+		if f.Match(values) {
+			c.rowids = append(c.rowids, rowid)
+		}
+		return f.Limit() < 0 || len(c.rowids) < f.Limit(), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// equalKey returns the primary key f sets with =, if it sets every column
+// of it to a value Lookup takes.
+func (t *Table) equalKey(f *flintdbapi.Filter) ([]interface{}, bool) {
+	key := make([]interface{}, len(t.keys))
+	for i, col := range t.keys {
+		c := t.schema.Columns[col]
+		v, ok := f.Equal(c.Name)
+		if !ok || v == nil {
+			return nil, false
+		}
+		if _, err := keyValue(c, v); err != nil {
+			return nil, false
+		}
+		key[i] = v
+	}
+	return key, true
+}
+
+// cursor is a flintdbapi.CursorAPI over the rowids Find found.
+type cursor struct {
+	rowids []int64
+}
+
+func (c *cursor) Next() (int64, error) {
+	if len(c.rowids) == 0 {
+		return -1, nil
+	}
+	rowid := c.rowids[0]
+	c.rowids = c.rowids[1:]
+	return rowid, nil
+}
+
+func (c *cursor) Close() {
+	c.rowids = nil
+}
+
+// row reads and decodes the row at rowid, the values in column order.
+func (t *Table) row(rowid int64) ([]interface{}, error) {
+	if t.data == nil {
+		return nil, errClosed
+	}
+	b, err := t.data.read(rowid)
+	if err != nil {
+		return nil, err
+	}
+	if b, err = t.unpack(b, rowid); err != nil {
+		return nil, err
+	}
+	return decode(t.schema, b, rowid)
+}
+
+// unpack verifies the checksum of a row read from storage and
+// decompresses it.
+func (t *Table) unpack(b []byte, rowid int64) ([]byte, error) {
+	if t.checksum != nil {
+		if len(b) < 4 {
+			return nil, fmt.Errorf("flintdbfile: checksum mismatch at rowid %d", rowid)
+		}
+		n := len(b) - 4
+		if binary.LittleEndian.Uint32(b[n:]) != t.checksum(b[:n]) {
+			return nil, fmt.Errorf("flintdbfile: checksum mismatch at rowid %d", rowid)
+		}
+		b = b[:n]
+	}
+	if !t.deflate {
+		return b, nil
+	}
+	if len(b) < 4 {
+		return nil, fmt.Errorf("flintdbfile: compressed row is corrupted at rowid %d", rowid)
+	}
+	n := int32(binary.LittleEndian.Uint32(b))
+	if n <= 0 || int(n) > t.schema.rowBytes() {
+		return nil, fmt.Errorf("flintdbfile: bad compressed row length: %d", n)
+	}
+	out := make([]byte, n)
+	if _, err := io.ReadFull(flate.NewReader(bytes.NewReader(b[4:])), out); err != nil {
+		return nil, fmt.Errorf("flintdbfile: compressed row is corrupted at rowid %d: %w", rowid, err)
+	}
+	return out, nil
+}
+
+// decode decodes a row in the engine's binary row format: the column
+// count, then per column a type, 0 for NULL, and the value.
+func decode(s *Schema, b []byte, rowid int64) ([]interface{}, error) {
+	short := fmt.Errorf("flintdbfile: row at rowid %d is corrupted", rowid)
+	if len(b) < 2 || int(int16(binary.LittleEndian.Uint16(b))) != len(s.Columns) {
+		return nil, fmt.Errorf("flintdbfile: row at rowid %d has no column count", rowid)
+	}
+	b = b[2:]
+	next := func(n int) []byte {
+		if len(b) < n {
+			return nil
+		}
+		v := b[:n]
+		b = b[n:]
+		return v
+	}
+	row := make([]interface{}, len(s.Columns))
+	for i, c := range s.Columns {
+		tag := next(2)
+		if tag == nil {
+			return nil, short
+		}
+		kind := int(int16(binary.LittleEndian.Uint16(tag)))
+		switch kind {
+		case VARIANT_NULL:
+			continue
+		case VARIANT_STRING, VARIANT_DECIMAL, VARIANT_BYTES, VARIANT_BLOB, VARIANT_OBJECT:
+			ln := next(2)
+			if ln == nil {
+				return nil, short
+			}
+			n := int(int16(binary.LittleEndian.Uint16(ln)))
+			if n < 0 {
+				n = 0
+			}
+			v := next(n)
+			if v == nil {
+				return nil, short
+			}
+			switch kind {
+			case VARIANT_STRING:
+				row[i] = string(v)
+			case VARIANT_DECIMAL:
+				row[i] = decimalString(v, c.Precision)
+			default:
+				row[i] = append([]byte{}, v...)
+			}
+			continue
+		}
+		v := next(fixedBytes(kind))
+		if v == nil {
+			return nil, short
+		}
+		switch kind {
+		case VARIANT_INT8:
+			row[i] = int64(int8(v[0]))
+		case VARIANT_UINT8:
+			row[i] = int64(v[0])
+		case VARIANT_INT16:
+			row[i] = int64(int16(binary.LittleEndian.Uint16(v)))
+		case VARIANT_UINT16:
+			row[i] = int64(binary.LittleEndian.Uint16(v))
+		case VARIANT_INT32:
+			row[i] = int64(int32(binary.LittleEndian.Uint32(v)))
+		case VARIANT_UINT32:
+			row[i] = int64(binary.LittleEndian.Uint32(v))
+		case VARIANT_INT64:
+			row[i] = int64(binary.LittleEndian.Uint64(v))
+		case VARIANT_DOUBLE:
+			row[i] = math.Float64frombits(binary.LittleEndian.Uint64(v))
+		case VARIANT_FLOAT:
+			row[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(v)))
+		case VARIANT_DATE:
+			row[i] = time.Unix(dateDays(uint32(v[0])<<16|uint32(v[1])<<8|uint32(v[2]))*86400, 0)
+		case VARIANT_TIME:
+			row[i] = time.Unix(int64(binary.LittleEndian.Uint64(v))/1000, 0)
+		case VARIANT_UUID, VARIANT_IPV6:
+			row[i] = append([]byte{}, v...)
+		default:
+			return nil, fmt.Errorf("flintdbfile: row at rowid %d: unknown type %d", rowid, kind)
+		}
+	}
+	return row, nil
+}
+
+// dateDays returns the days since the epoch of a DATE value: the year,
+// month and day packed in 24 bits, or older values, days since the epoch.
+func dateDays(v uint32) int64 {
+	year, month, day := int(v>>9), time.Month(v>>5&0x0f), int(v&0x1f)
+	if year < 1900 || year > 9999 || month < 1 || month > 12 || day < 1 {
+		return int64(v)
+	}
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Unix() / 86400
+}
+
+// decimalString formats a DECIMAL value, the little-endian two's complement
+// of its unscaled value, with scale digits after the point.
+func decimalString(v []byte, scale int) string {
+	if len(v) > 16 {
+		v = v[:16]
+	}
+	be := make([]byte, len(v))
+	for i := range v {
+		be[len(v)-1-i] = v[i]
+	}
+	n := new(big.Int).SetBytes(be)
+	if len(v) > 0 && v[len(v)-1]&0x80 != 0 {
+		n.Sub(n, new(big.Int).Lsh(big.NewInt(1), uint(8*len(v))))
+	}
+	digits := new(big.Int).Abs(n).String()
+	if scale <= 0 {
+		if n.Sign() < 0 {
+			return "-" + digits
+		}
+		return digits
+	}
+	if len(digits) <= scale {
+		digits = strings.Repeat("0", scale-len(digits)+1) + digits
+	}
+	s := digits[:len(digits)-scale] + "." + digits[len(digits)-scale:]
+	if n.Sign() < 0 {
+		s = "-" + s
+	}
+	return s
+}
+
+// keyValue converts v to the value a column of c reads back as, for
+// comparing with the columns of rows.
+func keyValue(c Column, v interface{}) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	switch c.Type {
+	case VARIANT_INT8, VARIANT_UINT8, VARIANT_INT16, VARIANT_UINT16, VARIANT_INT32, VARIANT_UINT32, VARIANT_INT64:
+		switch x := v.(type) {
+		case int:
+			return int64(x), nil
+		case int8:
+			return int64(x), nil
+		case int16:
+			return int64(x), nil
+		case int32:
+			return int64(x), nil
+		case int64:
+			return x, nil
+		case uint8:
+			return int64(x), nil
+		case uint16:
+			return int64(x), nil
+		case uint32:
+			return int64(x), nil
+		}
+	case VARIANT_DOUBLE, VARIANT_FLOAT:
+		switch x := v.(type) {
+		case float32:
+			return float64(x), nil
+		case float64:
+			if c.Type == VARIANT_FLOAT {
+				return float64(float32(x)), nil
+			}
+			return x, nil
+		case int:
+			return float64(x), nil
+		case int64:
+			return float64(x), nil
+		}
+	case VARIANT_STRING:
+		if x, ok := v.(string); ok {
+			return x, nil
+		}
+	case VARIANT_DECIMAL:
+		if x, ok := v.(string); ok {
+			return x, nil
+		}
+	case VARIANT_BYTES, VARIANT_BLOB, VARIANT_UUID, VARIANT_IPV6:
+		if x, ok := v.([]byte); ok {
+			return x, nil
+		}
+	case VARIANT_DATE, VARIANT_TIME:
+		if x, ok := v.(time.Time); ok {
+			return x, nil
+		}
+	}
+	return nil, fmt.Errorf("cannot compare %T with a column of type %d", v, c.Type)
+}
+
+// compare orders two values of a column of type t the way the engine does,
+// NULL first.
+func compare(t int, a, b interface{}) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	switch x := a.(type) {
+	case int64:
+		if y, ok := b.(int64); ok {
+			return cmp(x < y, x > y)
+		}
+	case float64:
+		if y, ok := b.(float64); ok {
+			return cmp(x < y, x > y)
+		}
+	case string:
+		y, ok := b.(string)
+		if ok && t == VARIANT_DECIMAL {
+			dx, okx := new(big.Rat).SetString(x)
+			dy, oky := new(big.Rat).SetString(y)
+			if okx && oky {
+				return dx.Cmp(dy)
+			}
+		}
+		if ok {
+			return strings.Compare(x, y)
+		}
+	case []byte:
+		if y, ok := b.([]byte); ok {
+			return bytes.Compare(x, y)
+		}
+	case time.Time:
+		if y, ok := b.(time.Time); ok {
+			return cmp(x.Unix() < y.Unix(), x.Unix() > y.Unix())
+		}
+	}
+	return 0
+}
+
+func cmp(less, greater bool) int {
+	switch {
+	case less:
+		return -1
+	case greater:
+		return 1
+	}
+	return 0
+}
+
+// xxhash32 returns the XXH32 with seed 0 of b, the engine's xxhash row
+// checksum.
+func xxhash32(b []byte) uint32 {
+	const (
+		p1 uint32 = 0x9E3779B1
+		p2 uint32 = 0x85EBCA77
+		p3 uint32 = 0xC2B2AE3D
+		p4 uint32 = 0x27D4EB2F
+		p5 uint32 = 0x165667B1
+	)
+	rotl := func(x uint32, r uint) uint32 { return x<<r | x>>(32-r) }
+	round := func(acc, in uint32) uint32 { return rotl(acc+in*p2, 13) * p1 }
+	n := len(b)
+	var h uint32
+	if n >= 16 {
+		v1, v2, v3, v4 := p1, p2, uint32(0), uint32(0)
+		v1 += p2
+		v4 -= p1
+		for ; len(b) >= 16; b = b[16:] {
+			v1 = round(v1, binary.LittleEndian.Uint32(b))
+			v2 = round(v2, binary.LittleEndian.Uint32(b[4:]))
+			v3 = round(v3, binary.LittleEndian.Uint32(b[8:]))
+			v4 = round(v4, binary.LittleEndian.Uint32(b[12:]))
+		}
+		h = rotl(v1, 1) + rotl(v2, 7) + rotl(v3, 12) + rotl(v4, 18)
+	} else {
+		h = p5
+	}
+	h += uint32(n)
+	for ; len(b) >= 4; b = b[4:] {
+		h = rotl(h+binary.LittleEndian.Uint32(b)*p3, 17) * p4
+	}
+	for _, c := range b {
+		h = rotl(h+uint32(c)*p5, 11) * p1
+	}
+	h ^= h >> 15
+	h *= p2
+	h ^= h >> 13
+	h *= p3
+	h ^= h >> 16
+	return h
+}
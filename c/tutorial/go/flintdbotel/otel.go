@@ -0,0 +1,52 @@
+// Package flintdbotel traces FlintDB operations with OpenTelemetry:
+//
+//	flintdb.SetTracer(flintdbotel.New(otel.Tracer("flintdb")))
+package flintdbotel
+
+import (
+	"context"
+	"fmt"
+
+	flintdb "flintdb-tutorial/flintdb"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// New returns a flintdb.Tracer starting its spans with tr.
+func New(tr trace.Tracer) flintdb.Tracer {
+	return tracer{tr}
+}
+
+type tracer struct {
+	tr trace.Tracer
+}
+
+func (t tracer) Start(ctx context.Context, name string) (context.Context, flintdb.Span) {
+	ctx, s := t.tr.Start(ctx, name, trace.WithSpanKind(trace.SpanKindInternal))
+	return ctx, span{s}
+}
+
+type span struct {
+	s trace.Span
+}
+
+func (s span) SetAttribute(key string, value interface{}) {
+	switch v := value.(type) {
+	case string:
+		s.s.SetAttributes(attribute.String(key, v))
+	case int64:
+		s.s.SetAttributes(attribute.Int64(key, v))
+	default:
+		s.s.SetAttributes(attribute.String(key, fmt.Sprint(v)))
+	}
+}
+
+func (s span) End(err error) {
+	if err != nil {
+		s.s.RecordError(err)
+		s.s.SetStatus(codes.Error, err.Error())
+	}
+	s.s.End()
+}
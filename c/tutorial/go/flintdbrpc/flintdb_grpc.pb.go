@@ -0,0 +1,382 @@
+// FlintDB service for the tables and files a Go sidecar serves.
+//
+// Regenerate the Go code with:
+//
+//	protoc --go_out=. --go_opt=paths=source_relative \
+//	    --go-grpc_out=. --go-grpc_opt=paths=source_relative flintdb.proto
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.29.3
+// source: flintdb.proto
+
+package flintdbrpc
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	FlintDB_List_FullMethodName   = "/flintdb.FlintDB/List"
+	FlintDB_Schema_FullMethodName = "/flintdb.FlintDB/Schema"
+	FlintDB_Find_FullMethodName   = "/flintdb.FlintDB/Find"
+	FlintDB_Read_FullMethodName   = "/flintdb.FlintDB/Read"
+	FlintDB_Insert_FullMethodName = "/flintdb.FlintDB/Insert"
+	FlintDB_Update_FullMethodName = "/flintdb.FlintDB/Update"
+	FlintDB_Delete_FullMethodName = "/flintdb.FlintDB/Delete"
+)
+
+// FlintDBClient is the client API for FlintDB service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// FlintDB reads and writes the tables and delimited files registered with
+// the server, each under a name. Row operations by rowid are for tables;
+// Find and Insert work on files too.
+type FlintDBClient interface {
+	// List returns the names the server serves.
+	List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error)
+	// Schema returns the columns of a table or file.
+	Schema(ctx context.Context, in *SchemaRequest, opts ...grpc.CallOption) (*SchemaResponse, error)
+	// Find streams the rows matching a query, such as "WHERE id > 10 LIMIT 5".
+	Find(ctx context.Context, in *FindRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Row], error)
+	// Read returns a table row by rowid.
+	Read(ctx context.Context, in *ReadRequest, opts ...grpc.CallOption) (*Row, error)
+	// Insert adds rows to a table, or appends them to a file.
+	Insert(ctx context.Context, in *InsertRequest, opts ...grpc.CallOption) (*InsertResponse, error)
+	// Update replaces a table row.
+	Update(ctx context.Context, in *UpdateRequest, opts ...grpc.CallOption) (*UpdateResponse, error)
+	// Delete removes a table row.
+	Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error)
+}
+
+type flintDBClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewFlintDBClient(cc grpc.ClientConnInterface) FlintDBClient {
+	return &flintDBClient{cc}
+}
+
+func (c *flintDBClient) List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListResponse)
+	err := c.cc.Invoke(ctx, FlintDB_List_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *flintDBClient) Schema(ctx context.Context, in *SchemaRequest, opts ...grpc.CallOption) (*SchemaResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SchemaResponse)
+	err := c.cc.Invoke(ctx, FlintDB_Schema_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *flintDBClient) Find(ctx context.Context, in *FindRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Row], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &FlintDB_ServiceDesc.Streams[0], FlintDB_Find_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[FindRequest, Row]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type FlintDB_FindClient = grpc.ServerStreamingClient[Row]
+
+func (c *flintDBClient) Read(ctx context.Context, in *ReadRequest, opts ...grpc.CallOption) (*Row, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Row)
+	err := c.cc.Invoke(ctx, FlintDB_Read_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *flintDBClient) Insert(ctx context.Context, in *InsertRequest, opts ...grpc.CallOption) (*InsertResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(InsertResponse)
+	err := c.cc.Invoke(ctx, FlintDB_Insert_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *flintDBClient) Update(ctx context.Context, in *UpdateRequest, opts ...grpc.CallOption) (*UpdateResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UpdateResponse)
+	err := c.cc.Invoke(ctx, FlintDB_Update_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *flintDBClient) Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteResponse)
+	err := c.cc.Invoke(ctx, FlintDB_Delete_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// FlintDBServer is the server API for FlintDB service.
+// All implementations must embed UnimplementedFlintDBServer
+// for forward compatibility.
+//
+// FlintDB reads and writes the tables and delimited files registered with
+// the server, each under a name. Row operations by rowid are for tables;
+// Find and Insert work on files too.
+type FlintDBServer interface {
+	// List returns the names the server serves.
+	List(context.Context, *ListRequest) (*ListResponse, error)
+	// Schema returns the columns of a table or file.
+	Schema(context.Context, *SchemaRequest) (*SchemaResponse, error)
+	// Find streams the rows matching a query, such as "WHERE id > 10 LIMIT 5".
+	Find(*FindRequest, grpc.ServerStreamingServer[Row]) error
+	// Read returns a table row by rowid.
+	Read(context.Context, *ReadRequest) (*Row, error)
+	// Insert adds rows to a table, or appends them to a file.
+	Insert(context.Context, *InsertRequest) (*InsertResponse, error)
+	// Update replaces a table row.
+	Update(context.Context, *UpdateRequest) (*UpdateResponse, error)
+	// Delete removes a table row.
+	Delete(context.Context, *DeleteRequest) (*DeleteResponse, error)
+	mustEmbedUnimplementedFlintDBServer()
+}
+
+// UnimplementedFlintDBServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedFlintDBServer struct{}
+
+func (UnimplementedFlintDBServer) List(context.Context, *ListRequest) (*ListResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method List not implemented")
+}
+func (UnimplementedFlintDBServer) Schema(context.Context, *SchemaRequest) (*SchemaResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Schema not implemented")
+}
+func (UnimplementedFlintDBServer) Find(*FindRequest, grpc.ServerStreamingServer[Row]) error {
+	return status.Errorf(codes.Unimplemented, "method Find not implemented")
+}
+func (UnimplementedFlintDBServer) Read(context.Context, *ReadRequest) (*Row, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Read not implemented")
+}
+func (UnimplementedFlintDBServer) Insert(context.Context, *InsertRequest) (*InsertResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Insert not implemented")
+}
+func (UnimplementedFlintDBServer) Update(context.Context, *UpdateRequest) (*UpdateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Update not implemented")
+}
+func (UnimplementedFlintDBServer) Delete(context.Context, *DeleteRequest) (*DeleteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
+}
+func (UnimplementedFlintDBServer) mustEmbedUnimplementedFlintDBServer() {}
+func (UnimplementedFlintDBServer) testEmbeddedByValue()                 {}
+
+// UnsafeFlintDBServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to FlintDBServer will
+// result in compilation errors.
+type UnsafeFlintDBServer interface {
+	mustEmbedUnimplementedFlintDBServer()
+}
+
+func RegisterFlintDBServer(s grpc.ServiceRegistrar, srv FlintDBServer) {
+	// If the following call pancis, it indicates UnimplementedFlintDBServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&FlintDB_ServiceDesc, srv)
+}
+
+func _FlintDB_List_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FlintDBServer).List(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FlintDB_List_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FlintDBServer).List(ctx, req.(*ListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FlintDB_Schema_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SchemaRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FlintDBServer).Schema(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FlintDB_Schema_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FlintDBServer).Schema(ctx, req.(*SchemaRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FlintDB_Find_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(FindRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(FlintDBServer).Find(m, &grpc.GenericServerStream[FindRequest, Row]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type FlintDB_FindServer = grpc.ServerStreamingServer[Row]
+
+func _FlintDB_Read_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReadRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FlintDBServer).Read(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FlintDB_Read_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FlintDBServer).Read(ctx, req.(*ReadRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FlintDB_Insert_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InsertRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FlintDBServer).Insert(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FlintDB_Insert_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FlintDBServer).Insert(ctx, req.(*InsertRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FlintDB_Update_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FlintDBServer).Update(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FlintDB_Update_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FlintDBServer).Update(ctx, req.(*UpdateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FlintDB_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FlintDBServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FlintDB_Delete_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FlintDBServer).Delete(ctx, req.(*DeleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// FlintDB_ServiceDesc is the grpc.ServiceDesc for FlintDB service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var FlintDB_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "flintdb.FlintDB",
+	HandlerType: (*FlintDBServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "List",
+			Handler:    _FlintDB_List_Handler,
+		},
+		{
+			MethodName: "Schema",
+			Handler:    _FlintDB_Schema_Handler,
+		},
+		{
+			MethodName: "Read",
+			Handler:    _FlintDB_Read_Handler,
+		},
+		{
+			MethodName: "Insert",
+			Handler:    _FlintDB_Insert_Handler,
+		},
+		{
+			MethodName: "Update",
+			Handler:    _FlintDB_Update_Handler,
+		},
+		{
+			MethodName: "Delete",
+			Handler:    _FlintDB_Delete_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Find",
+			Handler:       _FlintDB_Find_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "flintdb.proto",
+}
@@ -0,0 +1,325 @@
+// Package flintdbrpc serves FlintDB tables and delimited files over gRPC, so
+// programs in other languages can use data a Go process manages. The
+// service is defined in flintdb.proto; clients in Go use NewFlintDBClient.
+package flintdbrpc
+
+import (
+	"context"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	flintdb "flintdb-tutorial/flintdb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Server serves registered tables and files. Calls run one at a time, since
+// tables are not safe for concurrent use; a Find holds the others off until
+// its last row is sent.
+type Server struct {
+	UnimplementedFlintDBServer
+	mu     sync.Mutex
+	tables map[string]*flintdb.Table
+	files  map[string]*flintdb.GenericFile
+	server *grpc.Server
+}
+
+func NewServer() *Server {
+	return &Server{tables: map[string]*flintdb.Table{}, files: map[string]*flintdb.GenericFile{}}
+}
+
+// Register serves t under name. The server does not close registered tables.
+func (s *Server) Register(name string, t *flintdb.Table) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tables[name] = t
+}
+
+// RegisterFile serves f under name. Insert appends to it if it was opened
+// for writing. The server does not close registered files.
+func (s *Server) RegisterFile(name string, f *flintdb.GenericFile) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[name] = f
+}
+
+// ListenAndServe serves on addr, such as "localhost:50051", until Shutdown.
+func (s *Server) ListenAndServe(addr string, opts ...grpc.ServerOption) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	server := grpc.NewServer(opts...)
+	RegisterFlintDBServer(server, s)
+	s.mu.Lock()
+	s.server = server
+	s.mu.Unlock()
+	return server.Serve(lis)
+}
+
+// Shutdown stops the server after the calls in progress.
+func (s *Server) Shutdown() {
+	s.mu.Lock()
+	server := s.server
+	s.mu.Unlock()
+	if server != nil {
+		server.GracefulStop()
+	}
+}
+
+func (s *Server) table(name string) (*flintdb.Table, error) {
+	t, ok := s.tables[name]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "no such table: %s", name)
+	}
+	return t, nil
+}
+
+func (s *Server) List(context.Context, *ListRequest) (*ListResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resp := &ListResponse{}
+	for name := range s.tables {
+		resp.Tables = append(resp.Tables, name)
+	}
+	for name := range s.files {
+		resp.Files = append(resp.Files, name)
+	}
+	sort.Strings(resp.Tables)
+	sort.Strings(resp.Files)
+	return resp, nil
+}
+
+func (s *Server) Schema(_ context.Context, req *SchemaRequest) (*SchemaResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if f, ok := s.files[req.Table]; ok {
+		return &SchemaResponse{Columns: f.Columns()}, nil
+	}
+	t, err := s.table(req.Table)
+	if err != nil {
+		return nil, err
+	}
+	sql, err := t.Schema()
+	if err != nil {
+		return nil, err
+	}
+	return &SchemaResponse{Columns: t.Columns(), Sql: sql}, nil
+}
+
+func (s *Server) Find(req *FindRequest, stream FlintDB_FindServer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if f, ok := s.files[req.Table]; ok {
+		return findFile(f, req.Query, stream)
+	}
+	t, err := s.table(req.Table)
+	if err != nil {
+		return err
+	}
+	cursor, err := t.Find(req.Query)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close()
+	columns := t.Columns()
+	for {
+		rowid, err := cursor.Next()
+		if err != nil {
+			return err
+		}
+		if rowid < 0 {
+			return nil
+		}
+		row, err := t.Read(rowid)
+		if err != nil {
+			return err
+		}
+		out, err := toRow(rowid, row, columns)
+		row.Free()
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(out); err != nil {
+			return err
+		}
+	}
+}
+
+func findFile(f *flintdb.GenericFile, query string, stream FlintDB_FindServer) error {
+	cursor, err := f.Find(query)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close()
+	columns := f.Columns()
+	for {
+		row, err := cursor.Next()
+		if err != nil {
+			return err
+		}
+		if row == nil {
+			return nil
+		}
+		out, err := toRow(-1, row, columns)
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(out); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) Read(_ context.Context, req *ReadRequest) (*Row, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, err := s.table(req.Table)
+	if err != nil {
+		return nil, err
+	}
+	row, err := t.Read(req.Rowid)
+	if err != nil {
+		return nil, status.Errorf(codes.NotFound, "row %d: %v", req.Rowid, err)
+	}
+	defer row.Free()
+	return toRow(req.Rowid, row, t.Columns())
+}
+
+func (s *Server) Insert(_ context.Context, req *InsertRequest) (*InsertResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if f, ok := s.files[req.Table]; ok {
+		columns := f.Columns()
+		for _, in := range req.Rows {
+			row, err := f.CreateRow()
+			if err != nil {
+				return nil, err
+			}
+			if err = fromRow(row, in, columns); err == nil {
+				err = f.Write(row)
+			}
+			row.Free()
+			if err != nil {
+				return nil, err
+			}
+		}
+		return &InsertResponse{}, nil
+	}
+	t, err := s.table(req.Table)
+	if err != nil {
+		return nil, err
+	}
+	columns := t.Columns()
+	resp := &InsertResponse{}
+	for _, in := range req.Rows {
+		row, err := t.CreateRow()
+		if err != nil {
+			return resp, err
+		}
+		var rowid int64
+		if err = fromRow(row, in, columns); err == nil {
+			rowid, err = t.Insert(row)
+		}
+		row.Free()
+		if err != nil {
+			return resp, err
+		}
+		resp.Rowids = append(resp.Rowids, rowid)
+	}
+	return resp, nil
+}
+
+func (s *Server) Update(_ context.Context, req *UpdateRequest) (*UpdateResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, err := s.table(req.Table)
+	if err != nil {
+		return nil, err
+	}
+	if req.Row == nil {
+		return nil, status.Error(codes.InvalidArgument, "no row to update")
+	}
+	row, err := t.CreateRow()
+	if err != nil {
+		return nil, err
+	}
+	defer row.Free()
+	if err := fromRow(row, req.Row, t.Columns()); err != nil {
+		return nil, err
+	}
+	if err := t.UpdateAt(req.Row.Rowid, row); err != nil {
+		return nil, err
+	}
+	return &UpdateResponse{}, nil
+}
+
+func (s *Server) Delete(_ context.Context, req *DeleteRequest) (*DeleteResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, err := s.table(req.Table)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.DeleteAt(req.Rowid); err != nil {
+		return nil, err
+	}
+	return &DeleteResponse{}, nil
+}
+
+// toRow converts the values of row in columns.
+func toRow(rowid int64, row *flintdb.Row, columns []string) (*Row, error) {
+	out := &Row{Rowid: rowid, Values: make([]*Value, len(columns))}
+	for i, name := range columns {
+		v, err := row.GetByName(name)
+		if err != nil {
+			return nil, err
+		}
+		out.Values[i] = toValue(v)
+	}
+	return out, nil
+}
+
+func toValue(v interface{}) *Value {
+	switch v := v.(type) {
+	case int64:
+		return &Value{Kind: &Value_Int{Int: v}}
+	case float64:
+		return &Value{Kind: &Value_Real{Real: v}}
+	case string:
+		return &Value{Kind: &Value_Text{Text: v}}
+	case []byte:
+		return &Value{Kind: &Value_Blob{Blob: v}}
+	case time.Time:
+		return &Value{Kind: &Value_Int{Int: v.Unix()}}
+	}
+	return &Value{}
+}
+
+// fromRow sets the columns of row from the values of in.
+func fromRow(row *flintdb.Row, in *Row, columns []string) error {
+	if len(in.Values) != len(columns) {
+		return status.Errorf(codes.InvalidArgument, "expected %d values, found %d", len(columns), len(in.Values))
+	}
+	for i, v := range in.Values {
+		var value interface{}
+		switch k := v.GetKind().(type) {
+		case *Value_Int:
+			value = k.Int
+		case *Value_Real:
+			value = k.Real
+		case *Value_Text:
+			value = k.Text
+		case *Value_Blob:
+			value = k.Blob
+		}
+		if err := row.SetByName(columns[i], value); err != nil {
+			return status.Errorf(codes.InvalidArgument, "column %s: %v", columns[i], err)
+		}
+	}
+	return nil
+}
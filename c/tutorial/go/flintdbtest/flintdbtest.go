@@ -0,0 +1,102 @@
+// Package flintdbtest helps tests that use FlintDB tables:
+//
+//	table := flintdbtest.NewTempTable(t, meta)
+//	flintdbtest.SeedRows(t, table, []map[string]interface{}{{"id": 1, "name": "a"}})
+//	flintdbtest.AssertRowsEqual(t, table, "WHERE id = 1", []map[string]interface{}{{"name": "a"}})
+//
+// and checks that arbitrary rows of a schema read back as written:
+//
+//	flintdbtest.CheckRoundTrips(t, meta, 1000, 1)
+package flintdbtest
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	flintdb "flintdb-tutorial/flintdb"
+)
+
+// NewTempTable creates a table of meta in a directory removed when the test
+// ends, and closes it before then.
+func NewTempTable(t testing.TB, meta *flintdb.Meta) *flintdb.Table {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test"+flintdb.TABLE_NAME_SUFFIX)
+	table, err := flintdb.TableOpen(path, flintdb.FLINTDB_RDWR, meta)
+	if err != nil {
+		t.Fatalf("open %s: %v", path, err)
+	}
+	t.Cleanup(table.Close)
+	return table
+}
+
+// SeedRows inserts rows, each mapping column names to values as Row.Set
+// takes them, and returns their rowids. Columns left out keep their
+// defaults. It stops the test at the first row that fails.
+func SeedRows(t testing.TB, table *flintdb.Table, rows []map[string]interface{}) []int64 {
+	t.Helper()
+	rowids := make([]int64, 0, len(rows))
+	for i, values := range rows {
+		row, err := table.CreateRow()
+		if err != nil {
+			t.Fatalf("seed row %d: %v", i, err)
+		}
+		for col, v := range values {
+			if err = row.SetByName(col, v); err != nil {
+				err = fmt.Errorf("column %s: %w", col, err)
+				break
+			}
+		}
+		var rowid int64
+		if err == nil {
+			rowid, err = table.Insert(row)
+		}
+		row.Free()
+		if err != nil {
+			t.Fatalf("seed row %d: %v", i, err)
+		}
+		rowids = append(rowids, rowid)
+	}
+	return rowids
+}
+
+// AssertRowsEqual checks that query finds as many rows as want, in order,
+// and that each has the values of its map in want; columns a map leaves out
+// are not compared. Values compare by their fmt.Sprint text, so an int 1
+// matches the int64 1 of an INT64 column and nil matches NULL.
+func AssertRowsEqual(t testing.TB, table *flintdb.Table, query string, want []map[string]interface{}) {
+	t.Helper()
+	cursor, err := table.Find(query)
+	if err != nil {
+		t.Fatalf("find %q: %v", query, err)
+	}
+	defer cursor.Close()
+	n := 0
+	for {
+		rowid, err := cursor.Next()
+		if err != nil {
+			t.Fatalf("find %q: %v", query, err)
+		}
+		if rowid < 0 {
+			break
+		}
+		if n < len(want) {
+			row, err := table.Read(rowid)
+			if err != nil {
+				t.Fatalf("find %q: row %d: %v", query, rowid, err)
+			}
+			for col, w := range want[n] {
+				g, err := row.GetByName(col)
+				if err != nil {
+					t.Errorf("find %q: row %d: column %s: %v", query, n, col, err)
+				} else if fmt.Sprint(g) != fmt.Sprint(w) {
+					t.Errorf("find %q: row %d: %s = %v, want %v", query, n, col, g, w)
+				}
+			}
+		}
+		n++
+	}
+	if n != len(want) {
+		t.Errorf("find %q: found %d rows, want %d", query, n, len(want))
+	}
+}
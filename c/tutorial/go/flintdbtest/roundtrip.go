@@ -0,0 +1,296 @@
+package flintdbtest
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	flintdb "flintdb-tutorial/flintdb"
+)
+
+// The range of DATE and TIME values a table stores.
+var (
+	minDate = time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC)
+	maxDate = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+	minTime = time.Date(1, 1, 1, 0, 0, 0, 0, time.UTC)
+	maxTime = time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC)
+)
+
+// Size of generated STRING and BYTES values of columns without a size.
+const defaultSize = 64
+
+// RowGenerator generates arbitrary rows that fit the columns of a table,
+// for property-based tests. A quarter of the values are edge cases: NULL,
+// empty and full-length strings and bytes, the extremes of each type, NaN
+// and infinities, and the first and last date and time.
+//
+// With testing/quick, Values fills the map arguments of the property:
+//
+//	g, _ := flintdbtest.NewRowGenerator(table)
+//	quick.Check(func(row map[string]interface{}) bool {
+//		return flintdbtest.RoundTrip(table, row) == nil
+//	}, &quick.Config{Values: g.Values()})
+//
+// With rapid, draw a seed and pass rand.New(rand.NewSource(seed)) to Row.
+type RowGenerator struct {
+	columns []flintdb.Column
+	keys    map[string]bool
+}
+
+// NewRowGenerator returns a generator of rows of t. It fails if t has a
+// column of a type it cannot generate.
+func NewRowGenerator(t *flintdb.Table) (*RowGenerator, error) {
+	columns := t.ColumnTypes()
+	g := &RowGenerator{columns: columns, keys: map[string]bool{}}
+	for _, c := range columns {
+		switch c.Type {
+		case flintdb.VARIANT_INT32, flintdb.VARIANT_INT64, flintdb.VARIANT_DOUBLE, flintdb.VARIANT_FLOAT,
+			flintdb.VARIANT_STRING, flintdb.VARIANT_BYTES, flintdb.VARIANT_DATE, flintdb.VARIANT_TIME:
+		default:
+			return nil, fmt.Errorf("column %s: cannot generate values of type %d", c.Name, c.Type)
+		}
+	}
+	plan, err := t.Explain("", t.Columns()...)
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range plan.Keys {
+		g.keys[strings.ToLower(k)] = true
+	}
+	return g, nil
+}
+
+// Row returns a row mapping each column to a value Row.Set takes.
+func (g *RowGenerator) Row(r *rand.Rand) map[string]interface{} {
+	row := make(map[string]interface{}, len(g.columns))
+	for _, c := range g.columns {
+		row[c.Name] = g.value(r, c)
+	}
+	return row
+}
+
+// Values returns a testing/quick Config.Values that sets each argument, of
+// type map[string]interface{}, to a row from Row.
+func (g *RowGenerator) Values() func([]reflect.Value, *rand.Rand) {
+	return func(args []reflect.Value, r *rand.Rand) {
+		for i := range args {
+			args[i] = reflect.ValueOf(g.Row(r))
+		}
+	}
+}
+
+// EdgeRows returns rows of the edge cases of every column at once: the
+// smallest values, the largest, and NULL or zero.
+func (g *RowGenerator) EdgeRows() []map[string]interface{} {
+	rows := make([]map[string]interface{}, 3)
+	for i := range rows {
+		rows[i] = make(map[string]interface{}, len(g.columns))
+		for _, c := range g.columns {
+			edges := g.edges(c)
+			rows[i][c.Name] = edges[i%len(edges)]
+		}
+	}
+	return rows
+}
+
+func (g *RowGenerator) value(r *rand.Rand, c flintdb.Column) interface{} {
+	if r.Intn(4) == 0 {
+		edges := g.edges(c)
+		return edges[r.Intn(len(edges))]
+	}
+	switch c.Type {
+	case flintdb.VARIANT_INT32:
+		return int64(int32(r.Uint32()))
+	case flintdb.VARIANT_INT64:
+		return int64(r.Uint64())
+	case flintdb.VARIANT_DOUBLE:
+		f := math.Float64frombits(r.Uint64())
+		for math.IsNaN(f) && g.keys[strings.ToLower(c.Name)] {
+			f = math.Float64frombits(r.Uint64())
+		}
+		return f
+	case flintdb.VARIANT_FLOAT:
+		f := math.Float32frombits(r.Uint32())
+		for math.IsNaN(float64(f)) && g.keys[strings.ToLower(c.Name)] {
+			f = math.Float32frombits(r.Uint32())
+		}
+		return float64(f)
+	case flintdb.VARIANT_STRING:
+		return randomString(r, r.Intn(size(c)+1))
+	case flintdb.VARIANT_BYTES:
+		b := make([]byte, r.Intn(size(c)+1))
+		r.Read(b)
+		return b
+	case flintdb.VARIANT_DATE:
+		days := r.Int63n(int64(maxDate.Sub(minDate)/(24*time.Hour)) + 1)
+		return minDate.AddDate(0, 0, int(days))
+	case flintdb.VARIANT_TIME:
+		return time.Unix(minTime.Unix()+r.Int63n(maxTime.Unix()-minTime.Unix()+1), 0).UTC()
+	}
+	return nil
+}
+
+// edges returns the edge cases of c: its smallest value, its largest, and
+// NULL if it is nullable or else its zero, then others.
+func (g *RowGenerator) edges(c flintdb.Column) []interface{} {
+	var low, high, zero interface{}
+	var others []interface{}
+	special := []interface{}{math.Inf(-1), math.Inf(1), math.NaN()}
+	if g.keys[strings.ToLower(c.Name)] {
+		special = nil // NaN keys do not sort
+	}
+	switch c.Type {
+	case flintdb.VARIANT_INT32:
+		low, high, zero, others = int64(math.MinInt32), int64(math.MaxInt32), int64(0), []interface{}{int64(-1)}
+	case flintdb.VARIANT_INT64:
+		low, high, zero, others = int64(math.MinInt64), int64(math.MaxInt64), int64(0), []interface{}{int64(-1)}
+	case flintdb.VARIANT_DOUBLE:
+		low, high, zero, others = -math.MaxFloat64, math.MaxFloat64, 0.0, append([]interface{}{math.SmallestNonzeroFloat64}, special...)
+	case flintdb.VARIANT_FLOAT:
+		low, high, zero, others = -float64(math.MaxFloat32), float64(math.MaxFloat32), 0.0, append([]interface{}{float64(math.SmallestNonzeroFloat32)}, special...)
+	case flintdb.VARIANT_STRING:
+		low, high, zero, others = "", strings.Repeat("z", size(c)), "", []interface{}{strings.Repeat("é", size(c)/2)}
+	case flintdb.VARIANT_BYTES:
+		low, high, zero, others = []byte{}, bytes.Repeat([]byte{0xff}, size(c)), []byte{}, []interface{}{[]byte{0}}
+	case flintdb.VARIANT_DATE:
+		low, high, zero, others = minDate, maxDate, time.Unix(0, 0).UTC(), []interface{}{time.Date(1969, 12, 31, 0, 0, 0, 0, time.UTC)}
+	case flintdb.VARIANT_TIME:
+		low, high, zero, others = minTime, maxTime, time.Unix(0, 0).UTC(), []interface{}{time.Unix(-1, 0).UTC()}
+	}
+	if !c.NotNull {
+		zero = nil
+	}
+	return append([]interface{}{low, high, zero}, others...)
+}
+
+func size(c flintdb.Column) int {
+	if c.Size > 0 {
+		return c.Size
+	}
+	return defaultSize
+}
+
+// randomString returns up to n bytes of text: ASCII, control characters
+// and multi-byte runes.
+func randomString(r *rand.Rand, n int) string {
+	var sb strings.Builder
+	for sb.Len() < n {
+		var c rune
+		switch r.Intn(8) {
+		case 0:
+			c = rune(1 + r.Intn(31))
+		case 1:
+			c = rune(0x80 + r.Intn(0x780))
+		case 2:
+			c = rune(0x800 + r.Intn(0xd000))
+		case 3:
+			c = rune(0x10000 + r.Intn(0x10000))
+		default:
+			c = rune(0x20 + r.Intn(0x5f))
+		}
+		if sb.Len()+len(string(c)) > n {
+			break
+		}
+		sb.WriteRune(c)
+	}
+	return sb.String()
+}
+
+// RoundTrip inserts row into table, reads it back and deletes it, and
+// returns an error naming the columns whose values came back different.
+// Deleting the row leaves its key free, so generated rows may repeat keys.
+func RoundTrip(table *flintdb.Table, row map[string]interface{}) error {
+	r, err := table.CreateRow()
+	if err != nil {
+		return err
+	}
+	for col, v := range row {
+		if err = r.SetByName(col, v); err != nil {
+			err = fmt.Errorf("column %s: %w", col, err)
+			break
+		}
+	}
+	var rowid int64
+	if err == nil {
+		rowid, err = table.Insert(r)
+	}
+	r.Free()
+	if err != nil {
+		return fmt.Errorf("insert: %w", err)
+	}
+	defer table.DeleteAt(rowid)
+
+	got, err := table.Read(rowid)
+	if err != nil {
+		return fmt.Errorf("read: %w", err)
+	}
+	var diffs []string
+	for _, c := range table.ColumnTypes() {
+		want, ok := row[c.Name]
+		if !ok {
+			continue
+		}
+		g, err := got.GetByName(c.Name)
+		if err != nil {
+			return fmt.Errorf("column %s: %w", c.Name, err)
+		}
+		if !sameValue(want, g) {
+			diffs = append(diffs, fmt.Sprintf("%s = %#v, want %#v", c.Name, g, want))
+		}
+	}
+	if len(diffs) > 0 {
+		return fmt.Errorf("row came back different: %s", strings.Join(diffs, "; "))
+	}
+	return nil
+}
+
+// sameValue reports whether got, as Row.Get returns it, is want as
+// RowGenerator makes it.
+func sameValue(want, got interface{}) bool {
+	switch w := want.(type) {
+	case nil:
+		return got == nil
+	case float64:
+		g, ok := got.(float64)
+		return ok && (g == w || math.IsNaN(g) && math.IsNaN(w))
+	case []byte:
+		g, ok := got.([]byte)
+		return ok && bytes.Equal(g, w)
+	case time.Time:
+		g, ok := got.(time.Time)
+		return ok && g.Unix() == w.Unix()
+	}
+	return got == want
+}
+
+// AssertRoundTrip fails t if row does not read back from table as written.
+func AssertRoundTrip(t testing.TB, table *flintdb.Table, row map[string]interface{}) {
+	t.Helper()
+	if err := RoundTrip(table, row); err != nil {
+		t.Error(err)
+	}
+}
+
+// CheckRoundTrips creates a table of meta and round-trips its edge rows and
+// then n generated rows from seed through it, failing t for each row that
+// does not read back as written.
+func CheckRoundTrips(t testing.TB, meta *flintdb.Meta, n int, seed int64) {
+	t.Helper()
+	table := NewTempTable(t, meta)
+	g, err := NewRowGenerator(table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range g.EdgeRows() {
+		AssertRoundTrip(t, table, row)
+	}
+	r := rand.New(rand.NewSource(seed))
+	for i := 0; i < n; i++ {
+		AssertRoundTrip(t, table, g.Row(r))
+	}
+}
@@ -84,7 +84,7 @@ func tutorialTableCreate() error {
 		_ = rowid
 	}
 
-	fmt.Println("Successfully created table and inserted data.\n")
+	fmt.Print("Successfully created table and inserted data.\n\n")
 	return nil
 }
 
@@ -127,7 +127,7 @@ func tutorialTableFind() error {
 		row.Print()
 	}
 
-	fmt.Println("\nSuccessfully found and read data.\n")
+	fmt.Print("\nSuccessfully found and read data.\n\n")
 	return nil
 }
 
@@ -196,7 +196,7 @@ func tutorialTsvCreate() error {
 		row.Free()
 	}
 
-	fmt.Println("Successfully created TSV file.\n")
+	fmt.Print("Successfully created TSV file.\n\n")
 	return nil
 }
 
@@ -235,7 +235,7 @@ func tutorialTsvFind() error {
 		row.Free()
 	}
 
-	fmt.Println("\nSuccessfully read from TSV file.\n")
+	fmt.Print("\nSuccessfully read from TSV file.\n\n")
 	return nil
 }
 
@@ -349,16 +349,71 @@ func tutorialTableUpdateDelete() error {
 		row.Print()
 	}
 
-	fmt.Println("\nSuccessfully updated and deleted rows.\n")
+	fmt.Print("\nSuccessfully updated and deleted rows.\n\n")
 	return nil
 }
 
 // tutorialFilesort demonstrates how to use filesort for external sorting
-// Note: Full filesort bindings require more complex CGo setup
-// This is a placeholder for the feature
 func tutorialFilesort() error {
-	fmt.Println("--- Filesort feature available in C API ---")
-	fmt.Println("(Go bindings for filesort require additional CGo setup)\n")
+	fmt.Println("--- Running tutorialFilesort ---")
+
+	filepath := "./temp/tutorial_sort.dat"
+
+	// 1. Define the schema for sorting
+	meta, err := flintdb.NewMeta(filepath)
+	if err != nil {
+		return err
+	}
+	defer meta.Close()
+
+	if err := meta.AddColumn("value", flintdb.VARIANT_INT32, 0, 0, flintdb.SPEC_NOT_NULL, "0", "Sort value"); err != nil {
+		return err
+	}
+	if err := meta.AddColumn("label", flintdb.VARIANT_STRING, 20, 0, flintdb.SPEC_NOT_NULL, "", "Label"); err != nil {
+		return err
+	}
+
+	// 2. Create filesort ordered by value
+	sorter, err := flintdb.NewFilesort(filepath, meta, "value")
+	if err != nil {
+		return err
+	}
+	defer sorter.Close()
+
+	// 3. Add rows in random order
+	fmt.Println("Adding unsorted rows...")
+	for _, v := range []int32{5, 2, 8, 1, 9, 3} {
+		row, err := sorter.CreateRow()
+		if err != nil {
+			return err
+		}
+		row.SetInt32(0, v)
+		row.SetString(1, fmt.Sprintf("Item-%d", v))
+		err = sorter.Add(row)
+		row.Free()
+		if err != nil {
+			return err
+		}
+	}
+
+	// 4. Sort by the key columns
+	if err := sorter.Sort(); err != nil {
+		return err
+	}
+
+	// 5. Read sorted results
+	fmt.Println("Reading sorted rows:")
+	count := sorter.Rows()
+	for i := int64(0); i < count; i++ {
+		row, err := sorter.Read(i)
+		if err != nil {
+			return err
+		}
+		row.Print()
+		row.Free()
+	}
+
+	fmt.Printf("\nSuccessfully sorted %d rows.\n\n", count)
 	return nil
 }
 